@@ -1,432 +1,678 @@
 package api
 
 import (
-	"database/sql"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
-	"lablib/config"
 	"lablib/models"
+	"lablib/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func CreateBook(c *gin.Context) {
+func (h *Handler) CreateBook(c *gin.Context) {
+	ctx := c.Request.Context()
 	var book models.Book
 
 	if err := c.ShouldBindJSON(&book); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, errInvalidRequest)
 		return
 	}
-
-	// トランザクション開始
-	tx, err := config.DB.Begin()
+	if err := normalizeBook(&book); err != nil {
+		respondErr(c, err)
+		return
+	}
+	fetchCover, err := queryBool(c, "fetch_cover")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start transaction"})
+		respondErr(c, err)
 		return
 	}
-	defer tx.Rollback()
 
 	// 書籍の作成
 	book.ID = uuid.New()
 	book.CreatedAt = time.Now()
 	book.UpdatedAt = time.Now()
 
-	_, err = tx.Exec(`
-    INSERT INTO books (id, title, author, isbn, jan, ean13, type, total_copies, barcode, location, created_at, updated_at)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-`,
-		book.ID, book.Title, book.Author, book.ISBN,
-		book.JAN, book.EAN13, book.Type, book.TotalCopies,
-		book.Barcode, book.Location, book.CreatedAt, book.UpdatedAt,
-	)
+	var duplicates []DuplicateBookResponse
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		// 同じコードの書籍があっても登録し、警告として返す
+		var err error
+		if duplicates, err = findDuplicateBooks(ctx, tx.Books(), &book); err != nil {
+			return fmt.Errorf("find duplicate books: %w", err)
+		}
+		return createBookWithCopies(ctx, tx, &book)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating book"})
+		respondErr(c, err)
 		return
 	}
 
-	// 書籍コピーの作成
-	for i := 0; i < book.TotalCopies; i++ {
-		copyID := uuid.New()
-		serialNumber := book.ID.String()[:8] + "-" + uuid.New().String()[:4]
-		_, err = tx.Exec(`
-        INSERT INTO book_copies (id, book_id, serial_number, barcode, is_available, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `,
-			copyID, book.ID, serialNumber, book.Barcode, true,
-			time.Now(), time.Now(),
-		)
+	// 表紙画像の取得に失敗しても書籍の登録は取り消さず、結果を返す
+	var cover *CoverFetchResponse
+	if fetchCover != nil && *fetchCover && book.ISBN != "" {
+		f, err := h.fetchCover(ctx, &book)
+		if err == nil {
+			err = setCoverImage(ctx, h.store, book.ID, &f)
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating book copies"})
-			return
+			log.Printf("Cover fetch warning: %s: %v", book.ID, err)
+			f.Status, f.Message = models.CoverResultError, errInternal.Code
 		}
+		h.discardCover(ctx, &f)
+		if f.Status == models.CoverResultImported {
+			book.ImagePath = f.ImagePath
+		}
+		res := newCoverFetchResponse(c, f.Status, f.CoverURL, f.ImagePath, f.Message)
+		cover = &res
 	}
 
-	// 管理者ログの記録
-	/*
-		_, err = tx.Exec(`
-			INSERT INTO admin_logs (id, action, target_id, created_at)
-			VALUES ($1, $2, $3, $4, $5)
-		`,
-			uuid.New(), "create_book", book.ID, time.Now(),
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating admin log"})
-			return
-		}*/
+	respond(c, http.StatusOK, SavedBookResponse{
+		BookResponse: newBookResponse(book, book.TotalCopies),
+		Duplicates:   duplicates,
+		Cover:        cover,
+	})
+}
 
-	// トランザクションのコミット
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error committing transaction"})
-		return
+// createBookWithCopies は書籍と TotalCopies 冊分のコピーを tx に作成する
+func createBookWithCopies(ctx context.Context, tx repository.Store, book *models.Book) error {
+	if err := tx.Books().Create(ctx, book); err != nil {
+		return fmt.Errorf("create book: %w", err)
 	}
 
-	c.JSON(http.StatusOK, book)
+	// 書籍コピーの作成
+	for i := 0; i < book.TotalCopies; i++ {
+		err := tx.Copies().Create(ctx, &models.BookCopy{
+			ID:           uuid.New(),
+			BookID:       book.ID,
+			SerialNumber: newSerialNumber(book.ID),
+			Barcode:      book.Barcode,
+			IsAvailable:  true,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("create book copy: %w", err)
+		}
+	}
+	return nil
 }
 
-// DeleteBook - 書籍削除(管理者のみ)
-func DeleteBook(c *gin.Context) {
-	bookID := c.Param("id")
+// DeleteBook - 書籍の除籍(管理者のみ)。
+// 除籍した書籍は検索・貸出の対象外になるが、コピー・貸出の履歴・ランキングは残し、RestoreBook で復元できる。
+// 既に除籍済みの場合は何もしない。完全に削除する場合は PurgeBook を使う。
+func (h *Handler) DeleteBook(c *gin.Context) {
+	ctx := c.Request.Context()
 
 	// UUIDの検証
-	if _, err := uuid.Parse(bookID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
 	}
+	reason := strings.TrimSpace(c.Query("reason"))
+
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		// 書籍の存在確認
+		book, err := tx.Books().Get(ctx, bookID)
+		if err != nil {
+			return notFoundAs(err, errBookNotFound)
+		}
+		if book.WithdrawnAt != nil {
+			return nil
+		}
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
+		// 貸出中の書籍があるか確認
+		borrowedCount, err := tx.Loans().CountOpenByBook(ctx, bookID)
+		if err != nil {
+			return fmt.Errorf("count open loans: %w", err)
+		}
+		if borrowedCount > 0 {
+			return errBookOnLoan
+		}
+
+		now := time.Now()
+		return notFoundAs(tx.Books().SetWithdrawn(ctx, bookID, &now, reason), errBookNotFound)
+	})
 	if err != nil {
-		log.Printf("Transaction start error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		respondErr(c, err)
 		return
 	}
-	defer tx.Rollback()
 
-	// 書籍の存在確認
-	var exists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", bookID).Scan(&exists)
-	if err != nil {
-		log.Printf("Book existence check error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	log.Printf("Book withdrawn: %s", bookID)
+	respondMessage(c, http.StatusOK, "withdraw_succeeded")
+}
+
+// RestoreBook - 除籍した書籍を復元し、検索・貸出の対象に戻す(管理者のみ)
+func (h *Handler) RestoreBook(c *gin.Context) {
+	ctx := c.Request.Context()
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
 	}
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		book, err := tx.Books().Get(ctx, bookID)
+		if err != nil {
+			return notFoundAs(err, errBookNotFound)
+		}
+		if book.WithdrawnAt == nil {
+			return errBookNotWithdrawn
+		}
+		return notFoundAs(tx.Books().SetWithdrawn(ctx, bookID, nil, ""), errBookNotFound)
+	})
+	if err != nil {
+		respondErr(c, err)
 		return
 	}
 
-	// 貸出中の書籍があるか確認（テーブル名を borrow_records に修正）
-	var borrowedCount int
-	err = tx.QueryRow(`
-        SELECT COUNT(*) 
-        FROM borrow_records br
-        JOIN book_copies bc ON br.book_copy_id = bc.id
-        WHERE bc.book_id = $1 AND br.returned_at IS NULL
-    `, bookID).Scan(&borrowedCount)
+	log.Printf("Book restored: %s", bookID)
+	respondMessage(c, http.StatusOK, "restore_succeeded")
+}
 
-	if err != nil {
-		log.Printf("Borrowed count check error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+// PurgeBook - 除籍した書籍の完全な削除(管理者のみ)。
+// 書籍・コピー・返却済みの貸出履歴・ランキングを削除し、画像・添付ファイル・論文PDFはコミットの成功後に削除する。
+func (h *Handler) PurgeBook(c *gin.Context) {
+	ctx := c.Request.Context()
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
 	}
-	if borrowedCount > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "貸出中の書籍は削除できません"})
+	// 完全な削除は取り消せないため、実行した管理者をログに残す（/admin のミドルウェアで管理者であることは確認済み）
+	actor := currentViewer(c).userID
+	if actor == nil {
+		respondError(c, errAuthRequired)
 		return
 	}
 
-	// 画像ファイルの削除
-	var imagePath sql.NullString
-	err = tx.QueryRow("SELECT image_path FROM books WHERE id = $1", bookID).Scan(&imagePath)
-	if err == nil && imagePath.Valid && imagePath.String != "" {
-		fullPath := filepath.Join("./public/images/books", imagePath.String)
-		if err := os.Remove(fullPath); err != nil {
-			log.Printf("Image deletion warning: %v", err)
+	// コミット後に削除するファイル
+	var files []string
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		book, err := tx.Books().Get(ctx, bookID)
+		if err != nil {
+			return notFoundAs(err, errBookNotFound)
+		}
+		if book.WithdrawnAt == nil {
+			return errBookNotWithdrawn
+		}
+
+		// 除籍中は貸出できないが、除籍前の貸出が残っていないことを確認する
+		borrowedCount, err := tx.Loans().CountOpenByBook(ctx, bookID)
+		if err != nil {
+			return fmt.Errorf("count open loans: %w", err)
+		}
+		if borrowedCount > 0 {
+			return errBookOnLoan
+		}
+
+		if book.ImagePath != "" {
+			files = append(files, bookImageKeys(book.ImagePath)...)
+		}
+		attachments, err := tx.Attachments().ListByBook(ctx, bookID)
+		if err != nil {
+			return fmt.Errorf("list attachments: %w", err)
+		}
+		for _, a := range attachments {
+			files = append(files, AttachmentsPrefix+a.StoragePath)
+		}
+		if thesis, err := tx.Theses().Get(ctx, bookID); err == nil && thesis.PDFPath != "" {
+			files = append(files, ThesisPDFPrefix+thesis.PDFPath)
+		}
+
+		// 返却済みの貸出履歴を削除
+		if err := tx.Loans().DeleteReturnedByBook(ctx, bookID); err != nil {
+			return fmt.Errorf("delete borrow records: %w", err)
+		}
+
+		// 月間ランキングデータの削除
+		if err := tx.Rankings().DeleteByBook(ctx, bookID); err != nil {
+			return fmt.Errorf("delete monthly rankings: %w", err)
+		}
+
+		// book_copiesの削除
+		if err := tx.Copies().DeleteByBook(ctx, bookID); err != nil {
+			return fmt.Errorf("delete book copies: %w", err)
 		}
-	}
 
-	// 返却済みの貸出履歴を削除（テーブル名を borrow_records に修正）
-	_, err = tx.Exec(`
-        DELETE FROM borrow_records 
-        WHERE book_copy_id IN (
-            SELECT id FROM book_copies WHERE book_id = $1
-        ) AND returned_at IS NOT NULL
-    `, bookID)
+		// 書籍本体の削除（論文・添付ファイルの記録も削除される）
+		if err := tx.Books().Delete(ctx, bookID); err != nil {
+			return notFoundAs(err, errBookNotFound)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("Borrow records deletion error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete borrow records"})
+		respondErr(c, err)
 		return
 	}
 
-	// 月間ランキングデータの削除
-	_, err = tx.Exec("DELETE FROM monthly_rankings WHERE book_id = $1", bookID)
-	if err != nil {
-		log.Printf("Monthly rankings deletion warning: %v", err)
-	}
+	// ファイルはロールバックで失われないよう、コミットの成功後に削除する
+	h.deleteBlobs(ctx, files...)
+
+	log.Printf("Book purged: %s by %s", bookID, *actor)
+	respondMessage(c, http.StatusOK, "purge_succeeded")
+}
 
-	// book_copiesの削除
-	_, err = tx.Exec("DELETE FROM book_copies WHERE book_id = $1", bookID)
+// GetWithdrawnBooks - 除籍した書籍の一覧(管理者のみ)。書籍一覧と同じ条件で絞り込める。
+func (h *Handler) GetWithdrawnBooks(c *gin.Context) {
+	filter, err := parseBookFilter(c)
 	if err != nil {
-		log.Printf("Book copies deletion error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book copies"})
+		respondErr(c, err)
 		return
 	}
+	filter.Withdrawn = true
 
-	// 書籍本体の削除
-	result, err := tx.Exec("DELETE FROM books WHERE id = $1", bookID)
+	results, total, err := h.store.Books().Search(c.Request.Context(), filter)
 	if err != nil {
-		log.Printf("Book deletion error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book"})
+		respondInternalError(c, err)
 		return
 	}
+	respond(c, http.StatusOK, newPage(newBookResponses(results), filter.Page, total))
+}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	user, err := newUser(req, models.RoleUser)
+	if err != nil {
+		respondErr(c, err)
 		return
 	}
 
-	// トランザクションコミット
-	if err = tx.Commit(); err != nil {
-		log.Printf("Transaction commit error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+	// 学籍番号の重複は ErrConflict として返る
+	err = h.store.Users().Create(c.Request.Context(), user)
+	if errors.Is(err, repository.ErrConflict) {
+		respondError(c, errDuplicateStudentID)
+		return
+	} else if err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
-	log.Printf("Book deleted successfully: %s", bookID)
-	c.JSON(http.StatusOK, gin.H{"message": "書籍を削除しました"})
+	respond(c, http.StatusOK, newUserResponse(*user))
 }
 
-func CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
-		return
+// changeUserStatus は user の状態を status にする。利用停止・卒業にする場合、返却されていない貸出があれば
+// force を指定しない限り errUserHasOpenLoans を返す。自分自身と匿名化したユーザーは変更できない。
+func changeUserStatus(ctx context.Context, tx repository.Store, user *models.User, status string, force bool, self *uuid.UUID) error {
+	if user.AnonymizedAt != nil {
+		return errAnonymizedUser
+	}
+	if user.Status == status {
+		return nil
+	}
+	now := time.Now()
+	if status == models.UserStatusActive {
+		user.DeactivatedAt = nil
+	} else {
+		if self != nil && *self == user.ID {
+			return errCannotDeactivateSelf
+		}
+		open, err := tx.Loans().CountOpenByUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if open > 0 && !force {
+			return errUserHasOpenLoans
+		}
+		// 卒業から利用停止への変更などでは最初に利用停止にした日時を残す
+		if user.DeactivatedAt == nil {
+			user.DeactivatedAt = &now
+		}
 	}
+	user.Status = status
+	user.UpdatedAt = now
+	return tx.Users().Update(ctx, user)
+}
 
-	// 学籍番号の重複チェック
-	var exists bool
-	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE student_id = $1)", user.StudentID).Scan(&exists)
+// userLoanSummary はユーザーの貸出の概要を集計する
+func userLoanSummary(ctx context.Context, loans repository.LoanRepository, userID uuid.UUID) (UserLoanSummaryResponse, error) {
+	var summary UserLoanSummaryResponse
+	open, _, err := loans.List(ctx, repository.LoanFilter{
+		UserID: userID,
+		Status: "borrowed",
+		Sort:   repository.Sort{Field: "due_date"},
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return summary, fmt.Errorf("list open loans: %w", err)
 	}
-	if exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "この学籍番号は既に登録されています"})
-		return
+	now := time.Now()
+	for _, l := range open {
+		if l.DueDate.Before(now) {
+			summary.OverdueCount++
+		}
 	}
+	summary.OpenCount = len(open)
+	summary.OpenLoans = newLoanResponses(open)
 
-	// パスワードのハッシュ化
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	latest, total, err := loans.List(ctx, repository.LoanFilter{
+		UserID: userID,
+		Sort:   repository.Sort{Field: "borrowed_at", Desc: true},
+		Page:   repository.Page{Limit: 1},
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+		return summary, fmt.Errorf("list loans: %w", err)
+	}
+	summary.TotalCount = total
+	if len(latest) > 0 {
+		summary.LastBorrowedAt = &latest[0].BorrowedAt
+	}
+	return summary, nil
+}
+
+// GetUser - ユーザーの詳細と貸出の概要（管理者のみ）
+func (h *Handler) GetUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
 	}
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
+	user, err := h.store.Users().Get(ctx, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start transaction"})
+		respondErr(c, notFoundAs(err, errUserNotFound))
 		return
 	}
-	defer tx.Rollback()
-
-	// ユーザーの作成
-	user.ID = uuid.New()
-	user.Password = string(hashedPassword)
-	user.Role = "user"
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
-
-	_, err = tx.Exec(`
-		INSERT INTO users (id, student_id, name, password, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`,
-		user.ID, user.StudentID, user.Name, user.Password,
-		user.Role, user.CreatedAt, user.UpdatedAt,
-	)
+	summary, err := userLoanSummary(ctx, h.store.Loans(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user", "detail": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
+	respond(c, http.StatusOK, UserDetailResponse{UserResponse: newUserResponse(*user), Loans: summary})
+}
 
-	// 管理者ログの記録
-	/*
-		_, err = tx.Exec(`
-			INSERT INTO admin_logs (id, action, target_id, created_at)
-			VALUES ($1, $2, $3, $4, $5)
-		`,
-			uuid.New(), "create_user", user.ID, time.Now(),
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating admin log"})
-			return
-		}*/
+// UpdateUser - ユーザーの学籍番号・氏名・メールアドレス・ロール・状態の変更（管理者のみ）。省略した項目は変更しない。
+// 状態の変更は SetUserStatus と同じく、返却されていない貸出があるユーザーは force: true の場合のみ利用停止にできる。
+// 自分自身の管理者権限は外せず、匿名化したユーザーは変更できない。
+func (h *Handler) UpdateUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		respondErr(c, err)
+		return
+	}
+	self := currentViewer(c).userID
 
-	// トランザクションのコミット
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error committing transaction"})
+	var user *models.User
+	var summary UserLoanSummaryResponse
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if user, err = tx.Users().Get(ctx, userID); err != nil {
+			return notFoundAs(err, errUserNotFound)
+		}
+		if user.AnonymizedAt != nil {
+			return errAnonymizedUser
+		}
+		if req.Role != nil && *req.Role != "admin" && user.Role == "admin" && self != nil && *self == user.ID {
+			return errCannotDemoteSelf
+		}
+
+		if req.StudentID != nil {
+			user.StudentID = *req.StudentID
+		}
+		if req.Name != nil {
+			user.Name = *req.Name
+		}
+		if req.Email != nil {
+			user.Email = *req.Email
+		}
+		if req.Role != nil {
+			user.Role = *req.Role
+		}
+		user.UpdatedAt = time.Now()
+		err = tx.Users().Update(ctx, user)
+		if errors.Is(err, repository.ErrConflict) {
+			return errDuplicateStudentID
+		} else if err != nil {
+			return notFoundAs(err, errUserNotFound)
+		}
+		if req.Status != nil {
+			if err := changeUserStatus(ctx, tx, user, *req.Status, req.Force, self); err != nil {
+				return err
+			}
+		}
+
+		summary, err = userLoanSummary(ctx, tx.Loans(), userID)
+		return err
+	})
+	if err != nil {
+		respondErr(c, err)
 		return
 	}
 
-	// パスワードを除外してレスポンスを返す
-	user.Password = ""
-	c.JSON(http.StatusOK, user)
+	log.Printf("User updated: %s", userID)
+	respond(c, http.StatusOK, UserDetailResponse{UserResponse: newUserResponse(*user), Loans: summary})
 }
 
-func DeleteUser(c *gin.Context) {
-	userID := c.Param("id")
+// validate は指定された項目の形式を確認し、氏名・メールアドレスの前後の空白を除く
+func (req *UpdateUserRequest) validate() error {
+	if req.StudentID != nil && !validUserStudentID(*req.StudentID) {
+		return errInvalidUserStudentID
+	}
+	if req.Name != nil {
+		*req.Name = strings.TrimSpace(*req.Name)
+		if err := validateName(*req.Name); err != nil {
+			return err
+		}
+	}
+	if req.Email != nil {
+		*req.Email = strings.TrimSpace(*req.Email)
+		if err := validateEmail(*req.Email); err != nil {
+			return err
+		}
+	}
+	if req.Role != nil && !containsString(models.UserRoles, *req.Role) {
+		return errInvalidRole
+	}
+	if req.Status != nil && !containsString(models.UserStatuses, *req.Status) {
+		return errInvalidUserStatus
+	}
+	return nil
+}
 
-	if userID == "" {
-		c.JSON(400, gin.H{"error": "id is required"})
+// SetUserStatus - ユーザーの利用停止・卒業・利用再開（管理者のみ）。
+// 返却されていない貸出があるユーザーは force: true を指定した場合のみ利用停止にできる（貸出はそのまま残る）。
+func (h *Handler) SetUserStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req UserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	if !containsString(models.UserStatuses, req.Status) {
+		respondError(c, errInvalidUserStatus)
 		return
 	}
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
+	var user *models.User
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if user, err = tx.Users().Get(ctx, userID); err != nil {
+			return notFoundAs(err, errUserNotFound)
+		}
+		return changeUserStatus(ctx, tx, user, req.Status, req.Force, currentViewer(c).userID)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start transaction"})
+		respondErr(c, err)
 		return
 	}
 
-	defer tx.Rollback()
+	log.Printf("User status changed: %s -> %s", userID, user.Status)
+	respond(c, http.StatusOK, newUserResponse(*user))
+}
 
-	// ユーザーの削除
-	_, err = tx.Exec("DELETE FROM users WHERE id = $1", userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting user"})
+// DeleteUser - ユーザーの削除（管理者のみ）。貸出の履歴を残すため行は削除せず、
+// 学籍番号を "~" で始まる仮の値に置き換えて氏名・メールアドレス・パスワードを消す（匿名化）。
+// 返却されていない貸出があるユーザーは匿名化できない。
+func (h *Handler) DeleteUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	// 匿名化は取り消せないため、実行した管理者を記録する（/admin のミドルウェアで管理者であることは確認済み）
+	actor := currentViewer(c).userID
+	if actor == nil {
+		respondError(c, errAuthRequired)
+		return
+	}
+	if *actor == userID {
+		respondError(c, errCannotDeactivateSelf)
 		return
 	}
 
-	// 管理者ログの記録
-	/*
-		_, err = tx.Exec(`
-			INSERT INTO admin_logs (id, action, target_id, created_at)
-			VALUES ($1, $2, $3, $4, $5)
-		`,
-			uuid.New(), "delete_user", userID, time.Now(),
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating admin log"})
-			return
-		}*/
-
-	// トランザクションのコミット
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error committing transaction"})
+	// 仮の学籍番号が他の匿名化したユーザーと重複した場合は、別の値でやり直す
+	// （PostgreSQL では失敗した文の後のトランザクションを続けられないため、トランザクションごとやり直す）
+	var err error
+	for attempt := 0; attempt < maxAnonymizeAttempts; attempt++ {
+		err = h.store.WithTx(ctx, func(tx repository.Store) error {
+			user, err := tx.Users().Get(ctx, userID)
+			if err != nil {
+				return notFoundAs(err, errUserNotFound)
+			}
+			if user.AnonymizedAt != nil {
+				return nil
+			}
+			open, err := tx.Loans().CountOpenByUser(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if open > 0 {
+				return errUserHasOpenLoans
+			}
+			placeholder, err := anonymizedStudentID(userID, attempt)
+			if err != nil {
+				return err
+			}
+			return tx.Users().Anonymize(ctx, userID, placeholder, actor, time.Now())
+		})
+		if !errors.Is(err, repository.ErrConflict) {
+			break
+		}
+	}
+	if err != nil {
+		respondErr(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	log.Printf("User anonymized: %s by %s", userID, *actor)
+	respondMessage(c, http.StatusOK, "user_anonymized")
+}
+
+// maxAnonymizeAttempts は仮の学籍番号が重複した場合に匿名化を試みる回数
+const maxAnonymizeAttempts = 5
+
+// anonymizedStudentID は匿名化したユーザーの仮の学籍番号（"~" と16進数7桁）を返す。
+// 学籍番号に使えない "~" で始めるため、実在の学籍番号や再登録と重複しない。
+// 最初はユーザーIDの先頭、やり直す場合（attempt > 0）は乱数を使う。
+func anonymizedStudentID(userID uuid.UUID, attempt int) (string, error) {
+	if attempt == 0 {
+		return "~" + strings.ReplaceAll(userID.String(), "-", "")[:7], nil
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "~" + hex.EncodeToString(b)[:7], nil
 }
 
 // GetMonthlyRankings - 月間ランキング取得
-func GetMonthlyRankings(c *gin.Context) {
+func (h *Handler) GetMonthlyRankings(c *gin.Context) {
 	month := c.Query("month")
 	if month == "" {
 		month = time.Now().Format("2006-01")
 	}
 
-	rows, err := config.DB.Query(`
-        SELECT mr.id, mr.month, mr.book_id, mr.borrow_count,
-               b.title, b.author, b.type
-        FROM monthly_rankings mr
-        JOIN books b ON mr.book_id = b.id
-        WHERE mr.month = $1
-        ORDER BY mr.borrow_count DESC
-        LIMIT 100
-    `, month)
+	results, err := h.store.Rankings().Monthly(c.Request.Context(), month, 100)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings"})
+		respondInternalError(c, err)
 		return
 	}
-	defer rows.Close()
 
-	var rankings []map[string]interface{}
-	for rows.Next() {
-		var id, month, bookID, title, author, bookType string
-		var borrowCount int
-		err := rows.Scan(&id, &month, &bookID, &borrowCount, &title, &author, &bookType)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning rankings"})
-			return
-		}
-		rankings = append(rankings, map[string]interface{}{
-			"id":           id,
-			"month":        month,
-			"book_id":      bookID,
-			"borrow_count": borrowCount,
-			"title":        title,
-			"author":       author,
-			"type":         bookType,
+	rankings := make([]MonthlyRankingResponse, 0, len(results))
+	for _, mr := range results {
+		rankings = append(rankings, MonthlyRankingResponse{
+			ID:          mr.ID,
+			Month:       mr.Month,
+			BookID:      mr.BookID,
+			Title:       mr.Book.Title,
+			Author:      mr.Book.Author,
+			Type:        mr.Book.Type,
+			BorrowCount: mr.BorrowCount,
 		})
 	}
 
-	c.JSON(http.StatusOK, rankings)
+	respond(c, http.StatusOK, newList(rankings))
 }
 
 // GetAllTimeRankings - 全期間ランキング取得
-func GetAllTimeRankings(c *gin.Context) {
-	rows, err := config.DB.Query(`
-        SELECT b.id, b.title, b.author, b.type,
-               COALESCE(SUM(mr.borrow_count), 0) as total_borrow_count
-        FROM books b
-        LEFT JOIN monthly_rankings mr ON b.id = mr.book_id
-        GROUP BY b.id, b.title, b.author, b.type
-        HAVING COALESCE(SUM(mr.borrow_count), 0) > 0
-        ORDER BY total_borrow_count DESC
-        LIMIT 100
-    `)
+func (h *Handler) GetAllTimeRankings(c *gin.Context) {
+	results, err := h.store.Rankings().AllTime(c.Request.Context(), 100)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching all-time rankings"})
+		respondInternalError(c, err)
 		return
 	}
-	defer rows.Close()
 
-	var rankings []map[string]interface{}
-	for rows.Next() {
-		var bookID, title, author, bookType string
-		var totalBorrowCount int
-		err := rows.Scan(&bookID, &title, &author, &bookType, &totalBorrowCount)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning rankings"})
-			return
-		}
-		rankings = append(rankings, map[string]interface{}{
-			"book_id":      bookID,
-			"title":        title,
-			"author":       author,
-			"type":         bookType,
-			"borrow_count": totalBorrowCount,
+	rankings := make([]RankingResponse, 0, len(results))
+	for _, ar := range results {
+		rankings = append(rankings, RankingResponse{
+			BookID:      ar.BookID,
+			Title:       ar.Book.Title,
+			Author:      ar.Book.Author,
+			Type:        ar.Book.Type,
+			BorrowCount: ar.BorrowCount,
 		})
 	}
 
-	c.JSON(http.StatusOK, rankings)
+	respond(c, http.StatusOK, newList(rankings))
 }
 
-// ユーザー一覧取得
-func GetUsers(c *gin.Context) {
-	rows, err := config.DB.Query("SELECT id, student_id, name, role FROM users ORDER BY student_id")
+// ユーザー一覧取得。query（学籍番号・氏名）・role・status で絞り込む。
+func (h *Handler) GetUsers(c *gin.Context) {
+	filter := repository.UserFilter{Query: c.Query("query"), Role: c.Query("role"), Status: c.Query("status")}
+	if filter.Status != "" && !containsString(models.UserStatuses, filter.Status) {
+		respondError(c, errInvalidFilter)
+		return
+	}
+	var err error
+	if filter.Sort, err = parseSort(c, repository.UserSortFields); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.Page, err = parsePage(c); err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	results, total, err := h.store.Users().List(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		respondInternalError(c, err)
 		return
 	}
-	defer rows.Close()
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.StudentID, &user.Name, &user.Role)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning users"})
-			return
-		}
-		users = append(users, user)
+	users := make([]models.UserResponse, 0, len(results))
+	for _, u := range results {
+		users = append(users, newUserResponse(u))
 	}
-	c.JSON(http.StatusOK, users)
+	respond(c, http.StatusOK, newPage(users, filter.Page, total))
 }
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"lablib/middleware"
+	"lablib/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// createUser はユーザーを作成する
+func (s *testServer) createUser(studentID, name string) models.UserResponse {
+	s.t.Helper()
+	rec := s.do("POST", "/api/v1/admin/users", models.RegisterRequest{StudentID: studentID, Name: name, Password: "password123"}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var u models.UserResponse
+	decode(s.t, rec, &u)
+	return u
+}
+
+// 検索語の % や _ はワイルドカードではなく文字として一致させる
+func TestUserSearchMatchesLiterally(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("s2402", "100%達成")
+	s.createUser("s2403", "一般ユーザー")
+
+	rec := s.do("GET", "/api/v1/admin/users?query=%25", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var list PageResponse[models.UserResponse]
+	decode(t, rec, &list)
+	if len(list.Items) != 1 || list.Items[0].StudentID != "s2402" {
+		t.Fatalf("users matching %% = %+v", list.Items)
+	}
+}
+
+func TestUserEndpoints(t *testing.T) {
+	s := newTestServer(t)
+	u := s.createUser("s2401", "佐藤 花子")
+	if u.Role != "user" {
+		t.Fatalf("created user = %+v", u)
+	}
+	rec := s.do("POST", "/api/v1/admin/users", models.RegisterRequest{StudentID: "s2401", Name: "重複", Password: "password123"}, s.adminToken)
+	expectError(t, rec, errDuplicateStudentID)
+	expectError(t, s.do("POST", "/api/v1/admin/users", models.RegisterRequest{StudentID: "s 2401", Name: "空白", Password: "password123"}, s.adminToken),
+		errInvalidUserStudentID)
+
+	rec = s.do("GET", "/api/v1/admin/users", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var users PageResponse[models.UserResponse]
+	decode(t, rec, &users)
+	if len(users.Items) != 3 {
+		t.Fatalf("users = %+v", users.Items)
+	}
+
+	// 詳細には貸出の概要を含める
+	s.createBook(models.Book{Title: "詳細テスト", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "UD-0001"})
+	expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "UD-0001", UserID: u.ID.String()}, s.adminToken), http.StatusOK)
+	rec = s.do("GET", "/api/v1/admin/users/"+u.ID.String(), nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var detail UserDetailResponse
+	decode(t, rec, &detail)
+	if detail.Name != "佐藤 花子" || detail.Loans.OpenCount != 1 || detail.Loans.TotalCount != 1 || detail.Loans.OverdueCount != 0 ||
+		detail.Loans.LastBorrowedAt == nil || len(detail.Loans.OpenLoans) != 1 || detail.Loans.OpenLoans[0].BookTitle != "詳細テスト" {
+		t.Fatalf("user detail = %+v", detail)
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/users/"+uuid.New().String(), nil, s.adminToken), errUserNotFound)
+
+	// 省略した項目は変更しない
+	name, role, studentID := "佐藤 花子（更新）", "admin", "s2402"
+	rec = s.do("PUT", "/api/v1/admin/users/"+u.ID.String(), UpdateUserRequest{Name: &name, Role: &role}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &detail)
+	if detail.Name != name || detail.Role != "admin" || detail.StudentID != "s2401" || detail.Loans.OpenCount != 1 {
+		t.Fatalf("updated user = %+v", detail)
+	}
+	path := "/api/v1/admin/users/" + u.ID.String()
+	expectError(t, s.do("PUT", path, UpdateUserRequest{StudentID: &s.admin.StudentID}, s.adminToken), errDuplicateStudentID)
+	expectError(t, s.do("PUT", path, UpdateUserRequest{StudentID: strPtr("s 2402")}, s.adminToken), errInvalidUserStudentID)
+	expectError(t, s.do("PUT", path, UpdateUserRequest{Role: strPtr("owner")}, s.adminToken), errInvalidRole)
+	expectError(t, s.do("PUT", path, UpdateUserRequest{Status: strPtr(models.UserStatusGraduated)}, s.adminToken), errUserHasOpenLoans)
+	expectStatus(t, s.do("POST", "/api/v1/books/return", BorrowRequest{Barcode: "UD-0001", UserID: u.ID.String()}, s.adminToken), http.StatusOK)
+	rec = s.do("PUT", path, UpdateUserRequest{StudentID: &studentID, Role: strPtr("user")}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &detail)
+	if detail.StudentID != "s2402" || detail.Role != "user" || detail.Loans.OpenCount != 0 || detail.Loans.TotalCount != 1 {
+		t.Fatalf("updated user = %+v", detail)
+	}
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+uuid.New().String(), UpdateUserRequest{Name: &name}, s.adminToken), errUserNotFound)
+
+	rec = s.do("PUT", "/api/v1/admin/users/"+u.ID.String()+"/status", UserStatusRequest{Status: models.UserStatusGraduated}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var updated models.UserResponse
+	decode(t, rec, &updated)
+	if updated.Status != models.UserStatusGraduated || updated.DeactivatedAt == nil {
+		t.Fatalf("graduated user = %+v", updated)
+	}
+	// 卒業したユーザーはログインできない
+	expectError(t, s.do("POST", "/api/v1/auth/login", models.LoginRequest{StudentID: "s2402", Password: "password123"}, ""), errAccountInactive)
+	rec = s.do("GET", "/api/v1/admin/users?status=graduated", nil, s.adminToken)
+	decode(t, rec, &users)
+	if len(users.Items) != 1 || users.Items[0].ID != u.ID {
+		t.Fatalf("graduated users = %+v", users.Items)
+	}
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+u.ID.String()+"/status", UserStatusRequest{Status: "suspended"}, s.adminToken), errInvalidUserStatus)
+
+	// 管理者以外は削除（匿名化）できない
+	expectError(t, s.do("DELETE", "/api/v1/admin/users/"+u.ID.String(), nil, s.userToken), errAdminRequired)
+	expectError(t, s.do("DELETE", "/api/v1/admin/users/"+u.ID.String(), nil, ""), errAuthRequired)
+	if got := s.userByStudentID("s2402"); got.AnonymizedAt != nil {
+		t.Fatalf("user anonymized without admin: %+v", got)
+	}
+
+	// 削除しても貸出の履歴のためにユーザーは残し、学籍番号・氏名を消す
+	expectStatus(t, s.do("DELETE", "/api/v1/admin/users/"+u.ID.String(), nil, s.adminToken), http.StatusOK)
+	expectError(t, s.do("DELETE", "/api/v1/admin/users/not-a-uuid", nil, s.adminToken), errInvalidID)
+	rec = s.do("GET", "/api/v1/admin/users", nil, s.adminToken)
+	decode(t, rec, &users)
+	var anonymized *models.UserResponse
+	for i, user := range users.Items {
+		if user.ID == u.ID {
+			anonymized = &users.Items[i]
+		}
+	}
+	if len(users.Items) != 3 || anonymized == nil || anonymized.AnonymizedAt == nil || anonymized.Name != "" || !strings.HasPrefix(anonymized.StudentID, "~") {
+		t.Fatalf("users after delete = %+v", users.Items)
+	}
+	rec = s.do("GET", "/api/v1/admin/users/"+u.ID.String(), nil, s.adminToken)
+	decode(t, rec, &detail)
+	if detail.AnonymizedAt == nil || detail.AnonymizedBy == nil || *detail.AnonymizedBy != s.admin.ID ||
+		detail.Name != "" || !strings.HasPrefix(detail.StudentID, "~") || detail.Loans.TotalCount != 1 {
+		t.Fatalf("anonymized user = %+v", detail)
+	}
+	expectError(t, s.do("PUT", path, UpdateUserRequest{Name: &name}, s.adminToken), errAnonymizedUser)
+	// 匿名化したユーザーの学籍番号は再登録できる
+	s.createUser("s2402", "佐藤 花子")
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+u.ID.String()+"/status", UserStatusRequest{Status: models.UserStatusActive}, s.adminToken), errAnonymizedUser)
+
+	// 従来の /api は配列を返す
+	rec = s.do("GET", "/api/admin/users", nil, s.adminToken)
+	var legacy []models.UserResponse
+	decode(t, rec, &legacy)
+	if len(legacy) != 4 {
+		t.Fatalf("legacy users = %s", rec.Body.String())
+	}
+}
+
+func TestUserSelfProtection(t *testing.T) {
+	s := newTestServer(t)
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+s.admin.ID.String(), UpdateUserRequest{Role: strPtr("user")}, s.adminToken), errCannotDemoteSelf)
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+s.admin.ID.String(), UpdateUserRequest{Status: strPtr(models.UserStatusDeactivated)}, s.adminToken),
+		errCannotDeactivateSelf)
+	expectError(t, s.do("DELETE", "/api/v1/admin/users/"+s.admin.ID.String(), nil, s.adminToken), errCannotDeactivateSelf)
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+s.admin.ID.String()+"/status", UserStatusRequest{Status: models.UserStatusDeactivated}, s.adminToken), errCannotDeactivateSelf)
+
+	// 返却されていない貸出があるユーザーは匿名化できず、利用停止は force を指定した場合のみ
+	s.createBook(models.Book{Title: "貸出中", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "BC-0003"})
+	expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "BC-0003", UserID: s.user.ID.String()}, ""), http.StatusOK)
+	expectError(t, s.do("DELETE", "/api/v1/admin/users/"+s.user.ID.String(), nil, s.adminToken), errUserHasOpenLoans)
+	path := "/api/v1/admin/users/" + s.user.ID.String() + "/status"
+	expectError(t, s.do("PUT", path, UserStatusRequest{Status: models.UserStatusDeactivated}, s.adminToken), errUserHasOpenLoans)
+	expectStatus(t, s.do("PUT", path, UserStatusRequest{Status: models.UserStatusDeactivated, Force: true}, s.adminToken), http.StatusOK)
+
+	// 利用停止中は貸出・延長できないが、返却はできる
+	expectError(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "BC-0003", UserID: s.user.ID.String()}, ""), errAccountInactive)
+	expectStatus(t, s.do("POST", "/api/v1/books/return", BorrowRequest{Barcode: "BC-0003", UserID: s.user.ID.String()}, ""), http.StatusOK)
+
+	rec := s.do("PUT", path, UserStatusRequest{Status: models.UserStatusActive}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var u models.UserResponse
+	decode(t, rec, &u)
+	if u.Status != models.UserStatusActive || u.DeactivatedAt != nil {
+		t.Fatalf("reactivated user = %+v", u)
+	}
+	expectError(t, s.do("PUT", "/api/v1/admin/users/"+uuid.New().String()+"/status", UserStatusRequest{Status: models.UserStatusActive}, s.adminToken), errUserNotFound)
+}
+
+func TestRegisterLoginAndMe(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do("POST", "/api/v1/auth/register", map[string]string{"student_id": "s2404", "name": "鈴木 一郎", "password": "password123", "role": "admin"}, "")
+	expectStatus(t, rec, http.StatusOK)
+	var u models.UserResponse
+	decode(t, rec, &u)
+	// 自分で登録したユーザーは常に guest（研究室のメンバーとして扱わない）
+	if u.Role != models.RoleGuest {
+		t.Fatalf("registered user = %+v", u)
+	}
+
+	rec = s.do("POST", "/api/v1/auth/login", models.LoginRequest{StudentID: "s2404", Password: "wrong-password"}, "")
+	expectError(t, rec, errInvalidCredentials)
+	rec = s.do("POST", "/api/v1/auth/login", models.LoginRequest{StudentID: "unknown", Password: "password123"}, "")
+	expectError(t, rec, errInvalidCredentials)
+
+	rec = s.do("POST", "/api/v1/auth/login", models.LoginRequest{StudentID: "s2404", Password: "password123"}, "")
+	expectStatus(t, rec, http.StatusOK)
+	var login models.LoginResponse
+	decode(t, rec, &login)
+	if login.Token == "" || login.User.StudentID != "s2404" || login.User.Role != models.RoleGuest {
+		t.Fatalf("login = %+v", login)
+	}
+
+	expectError(t, s.do("GET", "/api/v1/me", nil, ""), errAuthRequired)
+	rec = s.do("GET", "/api/v1/me", nil, login.Token)
+	expectStatus(t, rec, http.StatusOK)
+	var me MeResponse
+	decode(t, rec, &me)
+	if me.User.StudentID != "s2404" || me.Loans == nil || me.History == nil || me.HistoryTotal != 0 {
+		t.Fatalf("me = %+v", me)
+	}
+
+	// 省略した項目は変更しない
+	name := "鈴木 一郎（変更）"
+	rec = s.do("PUT", "/api/v1/me", UpdateMeRequest{Name: &name}, login.Token)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &u)
+	if u.Name != name || u.Notifications != models.DefaultNotificationPreferences {
+		t.Fatalf("updated me = %+v", u)
+	}
+	email, empty := "ichiro@example.com", ""
+	rec = s.do("PUT", "/api/v1/me", UpdateMeRequest{Email: &email, Notifications: &models.NotificationPreferences{Overdue: true}}, login.Token)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &u)
+	if u.Name != name || u.Email != email || u.Notifications.DueReminder || !u.Notifications.Overdue {
+		t.Fatalf("updated me = %+v", u)
+	}
+	expectError(t, s.do("PUT", "/api/v1/me", UpdateMeRequest{Name: &empty}, login.Token), errInvalidName)
+	bad := "not-an-address"
+	expectError(t, s.do("PUT", "/api/v1/me", UpdateMeRequest{Email: &bad}, login.Token), errInvalidEmail)
+	expectError(t, s.do("PUT", "/api/v1/me", UpdateMeRequest{Name: &name}, ""), errAuthRequired)
+}
+
+func TestMeLoansAndHistory(t *testing.T) {
+	s := newTestServer(t)
+	s.createBook(models.Book{Title: "返却済み", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "ME-0001"})
+	s.createBook(models.Book{Title: "貸出中", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "ME-0002"})
+	for _, barcode := range []string{"ME-0001", "ME-0002"} {
+		expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: barcode, UserID: s.user.ID.String()}, s.userToken), http.StatusOK)
+	}
+	expectStatus(t, s.do("POST", "/api/v1/books/return", BorrowRequest{Barcode: "ME-0001", UserID: s.user.ID.String()}, s.userToken), http.StatusOK)
+
+	rec := s.do("GET", "/api/v1/me", nil, s.userToken)
+	expectStatus(t, rec, http.StatusOK)
+	var me MeResponse
+	decode(t, rec, &me)
+	if len(me.Loans) != 1 || me.Loans[0].BookTitle != "貸出中" || me.OverdueCount != 0 ||
+		len(me.History) != 1 || me.History[0].BookTitle != "返却済み" || me.HistoryTotal != 1 {
+		t.Fatalf("me = %+v", me)
+	}
+
+	// 他の利用者の貸出は含めない
+	rec = s.do("GET", "/api/v1/me/history", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var history BorrowHistoryResponse
+	decode(t, rec, &history)
+	if len(history.Items) != 0 {
+		t.Fatalf("admin history = %+v", history.Items)
+	}
+	rec = s.do("GET", "/api/v1/me/history?status=borrowed", nil, s.userToken)
+	decode(t, rec, &history)
+	if len(history.Items) != 1 || history.Items[0].BookTitle != "貸出中" {
+		t.Fatalf("borrowed history = %+v", history.Items)
+	}
+
+	// 利用停止したユーザーのトークンは使えない
+	expectStatus(t, s.do("PUT", "/api/v1/admin/users/"+s.user.ID.String()+"/status",
+		UserStatusRequest{Status: models.UserStatusDeactivated, Force: true}, s.adminToken), http.StatusOK)
+	expectError(t, s.do("GET", "/api/v1/me", nil, s.userToken), errAccountInactive)
+	expectError(t, s.do("GET", "/api/v1/me/history", nil, s.userToken), errAccountInactive)
+}
+
+// 管理者専用のルートはハンドラーの前に管理者のトークンを確かめる
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	s := newTestServer(t)
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": s.admin.ID.String(), "role": "admin", "exp": time.Now().Add(-time.Minute).Unix(),
+	}).SignedString(middleware.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rt := range routeTable {
+		if !rt.admin {
+			continue
+		}
+		path := "/api/v1/admin" + strings.ReplaceAll(rt.path, ":id", uuid.New().String())
+		for token, want := range map[string]apiError{
+			"":            errAuthRequired,
+			"not-a-token": errAuthRequired,
+			expired:       errAuthRequired,
+			s.userToken:   errAdminRequired,
+		} {
+			rec := s.do(rt.method, path, nil, token)
+			if rec.Code != want.Status || !strings.Contains(rec.Body.String(), `"code":"`+want.Code+`"`) {
+				t.Errorf("%s %s: %d %s, want %s", rt.method, path, rec.Code, rec.Body, want.Code)
+			}
+		}
+	}
+
+	// ロール・状態はトークンの内容ではなく、その時点のユーザーの記録で判定する
+	created := s.createUser("s2406", "元管理者")
+	role := models.RoleAdmin
+	expectStatus(t, s.do("PUT", "/api/v1/admin/users/"+created.ID.String(), UpdateUserRequest{Role: &role}, s.adminToken), http.StatusOK)
+	demotedToken := s.token(s.userByStudentID("s2406"))
+	expectStatus(t, s.do("GET", "/api/v1/admin/users", nil, demotedToken), http.StatusOK)
+	role = models.RoleUser
+	expectStatus(t, s.do("PUT", "/api/v1/admin/users/"+created.ID.String(), UpdateUserRequest{Role: &role}, s.adminToken), http.StatusOK)
+	expectError(t, s.do("GET", "/api/v1/admin/users", nil, demotedToken), errAdminRequired)
+
+	other := s.createUser("s2407", "利用停止する管理者")
+	role = models.RoleAdmin
+	expectStatus(t, s.do("PUT", "/api/v1/admin/users/"+other.ID.String(), UpdateUserRequest{Role: &role}, s.adminToken), http.StatusOK)
+	deactivatedToken := s.token(s.userByStudentID("s2407"))
+	expectStatus(t, s.do("PUT", "/api/v1/admin/users/"+other.ID.String()+"/status",
+		UserStatusRequest{Status: models.UserStatusDeactivated}, s.adminToken), http.StatusOK)
+	expectError(t, s.do("GET", "/api/v1/admin/users", nil, deactivatedToken), errAccountInactive)
+
+	// 存在しないユーザーのトークンは、ロールが admin でも使えない
+	unknown := s.token(&models.User{ID: uuid.New(), Role: models.RoleAdmin})
+	expectError(t, s.do("GET", "/api/v1/admin/users", nil, unknown), errAuthRequired)
+
+	// 従来の /api は {"error": "..."} の形式で返す
+	rec := s.do("GET", "/api/admin/users", nil, s.userToken)
+	expectStatus(t, rec, http.StatusForbidden)
+	var legacy struct{ Error string }
+	decode(t, rec, &legacy)
+	if legacy.Error == "" {
+		t.Fatalf("legacy error = %s", rec.Body)
+	}
+}
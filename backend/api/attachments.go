@@ -0,0 +1,458 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxAttachmentSize = 100 << 20 //100MB
+)
+
+// attachmentType - 添付できるファイルの種類。
+// sniffed は内容から判定される MIME タイプ（http.DetectContentType）で、拡張子と内容が一致する場合のみ受け付ける。
+type attachmentType struct {
+	contentType string
+	sniffed     string
+}
+
+// attachmentTypes は拡張子ごとの添付できるファイルの種類（Office文書は内容が ZIP として判定される）
+var attachmentTypes = map[string]attachmentType{
+	".pdf":  {"application/pdf", "application/pdf"},
+	".docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip"},
+	".xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/zip"},
+	".pptx": {"application/vnd.openxmlformats-officedocument.presentationml.presentation", "application/zip"},
+	".zip":  {"application/zip", "application/zip"},
+	".txt":  {"text/plain; charset=utf-8", "text/plain"},
+	".csv":  {"text/csv; charset=utf-8", "text/plain"},
+	".md":   {"text/markdown; charset=utf-8", "text/plain"},
+	".png":  {"image/png", "image/png"},
+	".jpg":  {"image/jpeg", "image/jpeg"},
+	".jpeg": {"image/jpeg", "image/jpeg"},
+}
+
+// detectAttachmentType は拡張子と先頭のバイト列が一致する場合に保存する Content-Type を返す
+func detectAttachmentType(filename string, head []byte) (string, error) {
+	t, ok := attachmentTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", errUnsupportedFileType
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if sniffed != t.sniffed {
+		return "", errUnsupportedFileType
+	}
+	return t.contentType, nil
+}
+
+// cleanFilename はダウンロード時に使うファイル名からパスと制御文字を除く
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[len(runes)-255:])
+	}
+	return strings.TrimSpace(name)
+}
+
+// parseVisibility は公開範囲を検証する。空の場合は def を返す。
+func parseVisibility(s, def string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "" {
+		return def, nil
+	}
+	if !containsString(models.Visibilities, v) {
+		return "", errInvalidVisibility
+	}
+	return v, nil
+}
+
+// ErrFileRejected は FileScanner がファイルに問題を見つけた場合のエラー
+var ErrFileRejected = errors.New("file rejected by scanner")
+
+// FileScanner - アップロードされた添付ファイルの検査（ウイルススキャンなど）。
+// 保存後・登録前に呼ばれ、問題のあるファイルには ErrFileRejected をラップしたエラーを返す。
+type FileScanner interface {
+	Scan(ctx context.Context, path string) error
+}
+
+// CommandScanner は外部コマンド（clamdscan など）でファイルを検査する。
+// Args の後にファイルのパスを渡して実行し、終了コード1を検出とみなす。
+type CommandScanner struct {
+	Command string
+	Args    []string
+}
+
+func (s CommandScanner) Scan(ctx context.Context, path string) error {
+	out, err := exec.CommandContext(ctx, s.Command, append(s.Args, path)...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return fmt.Errorf("%w: %s", ErrFileRejected, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// viewer はリクエストした利用者。OptionalAuth が設定した user_id・role から作る。
+type viewer struct {
+	userID *uuid.UUID
+	role   string
+}
+
+func currentViewer(c *gin.Context) viewer {
+	v := viewer{role: c.GetString("role")}
+	if id, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		v.userID = &id
+	}
+	return v
+}
+
+// canView は公開範囲 visibility の添付ファイルを閲覧できるかを返す
+func (v viewer) canView(visibility string) bool {
+	switch visibility {
+	case models.VisibilityPublic:
+		return true
+	case models.VisibilityLab:
+		// 自分で登録したユーザー（guest）は研究室のメンバーとして扱わない
+		return v.role == models.RoleUser || v.role == models.RoleAdmin
+	default:
+		return v.role == models.RoleAdmin
+	}
+}
+
+// GetBookAttachments - 書籍の添付ファイル一覧。利用者が閲覧できる公開範囲のもののみ返す。
+func (h *Handler) GetBookAttachments(c *gin.Context) {
+	book, ok := h.findBook(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.store.Attachments().ListByBook(c.Request.Context(), book.ID)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	v := currentViewer(c)
+	items := make([]AttachmentResponse, 0, len(attachments))
+	for _, a := range attachments {
+		if v.canView(a.Visibility) {
+			items = append(items, newAttachmentResponse(a))
+		}
+	}
+	respond(c, http.StatusOK, newList(items))
+}
+
+// DownloadAttachment - 添付ファイルのダウンロード。Range・条件付きリクエストに対応する。
+// 公開範囲外の場合、ログインしていなければ login_required、ログイン済みなら見つからないものとして扱う。
+// ダウンロード数は途中からの Range リクエスト（PDFビューアーの分割取得など）とキャッシュの再検証を除いて記録する。
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	a, err := h.store.Attachments().Get(ctx, id)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errAttachmentNotFound))
+		return
+	}
+
+	v := currentViewer(c)
+	if !v.canView(a.Visibility) {
+		if v.role == "" && a.Visibility == models.VisibilityLab {
+			respondError(c, errLoginRequired)
+		} else {
+			respondError(c, errAttachmentNotFound)
+		}
+		return
+	}
+
+	if _, err := h.blobs.Stat(ctx, AttachmentsPrefix+a.StoragePath); err != nil {
+		respondBlobError(c, err, errAttachmentNotFound)
+		return
+	}
+
+	// キャッシュの再検証（304を返す If-None-Match）も記録しない
+	etag := `"` + a.SHA256 + `"`
+	if r := c.GetHeader("Range"); c.Request.Method == http.MethodGet && c.GetHeader("If-None-Match") != etag &&
+		(r == "" || strings.HasPrefix(r, "bytes=0-")) {
+		err := h.store.Attachments().RecordDownload(ctx, &models.AttachmentDownload{
+			AttachmentID: a.ID,
+			UserID:       v.userID,
+			DownloadedAt: time.Now(),
+		})
+		if err != nil {
+			log.Printf("Attachment download record warning: %v", err)
+		}
+	}
+
+	disposition := "attachment"
+	if inline, _ := queryBool(c, "inline"); inline != nil && *inline {
+		disposition = "inline"
+	}
+	opts := blobOptions{
+		ContentType:        a.ContentType,
+		ContentDisposition: mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}),
+		ETag:               etag,
+	}
+	if a.Visibility != models.VisibilityPublic {
+		opts.CacheControl = "private"
+	}
+	h.serveBlob(c, AttachmentsPrefix+a.StoragePath, errAttachmentNotFound, opts)
+}
+
+// UploadAttachment - 添付ファイルのアップロード（管理者のみ）。
+// multipart の file にファイル、visibility に公開範囲（既定は lab）、description に説明を指定する。
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	book, ok := h.findBook(c)
+	if !ok {
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		respondError(c, errFileRequired)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > MaxAttachmentSize {
+		respondError(c, errFileTooLarge)
+		return
+	}
+	if header.Size == 0 {
+		respondError(c, errFileRequired)
+		return
+	}
+
+	visibility, err := parseVisibility(c.PostForm("visibility"), models.VisibilityLab)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filename := cleanFilename(header.Filename)
+
+	// 形式は拡張子と先頭の内容の両方で判定する
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		respondError(c, errFileRequired)
+		return
+	}
+	head = head[:n]
+	contentType, err := detectAttachmentType(filename, head)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	// 検査のため一時ファイルに保存しながらチェックサムを計算し、検査の後に保存先へ送る
+	tmp, err := os.CreateTemp("", "lablib-attachment-*")
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	// 別のユーザーで動く検査デーモン（clamd など）からも読めるようにする
+	if err := tmp.Chmod(0644); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(io.MultiReader(bytes.NewReader(head), file), MaxAttachmentSize+1))
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if size > MaxAttachmentSize {
+		respondError(c, errFileTooLarge)
+		return
+	}
+
+	scanStatus := models.ScanStatusUnscanned
+	if h.scanner != nil {
+		if err := h.scanner.Scan(ctx, tmp.Name()); err != nil {
+			if errors.Is(err, ErrFileRejected) {
+				log.Printf("Attachment rejected: %s: %v", filename, err)
+				respondError(c, errFileRejected)
+				return
+			}
+			respondInternalError(c, err)
+			return
+		}
+		scanStatus = models.ScanStatusClean
+	}
+
+	storageName := uuid.New().String() + strings.ToLower(filepath.Ext(filename))
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if err := h.blobs.Put(ctx, AttachmentsPrefix+storageName, tmp, size, contentType); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	now := time.Now()
+	attachment := models.Attachment{
+		ID:          uuid.New(),
+		BookID:      book.ID,
+		Filename:    filename,
+		StoragePath: storageName,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Visibility:  visibility,
+		Description: strings.TrimSpace(c.PostForm("description")),
+		ScanStatus:  scanStatus,
+		UploadedBy:  currentViewer(c).userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.store.Attachments().Create(ctx, &attachment); err != nil {
+		h.deleteBlobs(ctx, AttachmentsPrefix+storageName)
+		respondErr(c, notFoundAs(err, errBookNotFound))
+		return
+	}
+
+	respond(c, http.StatusOK, newAttachmentResponse(attachment))
+}
+
+// UpdateAttachment - 添付ファイルのファイル名・公開範囲・説明の更新（管理者のみ）
+func (h *Handler) UpdateAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req UpdateAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+
+	var attachment *models.Attachment
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if attachment, err = tx.Attachments().Get(ctx, id); err != nil {
+			return notFoundAs(err, errAttachmentNotFound)
+		}
+		if req.Filename != nil {
+			name := cleanFilename(*req.Filename)
+			if name == "" {
+				return errMissingFields
+			}
+			// ダウンロード時の Content-Type が変わらないよう拡張子は変更できない
+			if !strings.EqualFold(filepath.Ext(name), filepath.Ext(attachment.Filename)) {
+				return errUnsupportedFileType
+			}
+			attachment.Filename = name
+		}
+		if req.Visibility != nil {
+			if attachment.Visibility, err = parseVisibility(*req.Visibility, attachment.Visibility); err != nil {
+				return err
+			}
+		}
+		if req.Description != nil {
+			attachment.Description = strings.TrimSpace(*req.Description)
+		}
+		attachment.UpdatedAt = time.Now()
+		return notFoundAs(tx.Attachments().Update(ctx, attachment), errAttachmentNotFound)
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, newAttachmentResponse(*attachment))
+}
+
+// DeleteAttachment - 添付ファイルの削除（管理者のみ）。ダウンロードの記録も合わせて削除する。
+func (h *Handler) DeleteAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	attachment, err := h.store.Attachments().Get(ctx, id)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errAttachmentNotFound))
+		return
+	}
+
+	if err := h.store.Attachments().Delete(ctx, id); err != nil {
+		respondErr(c, notFoundAs(err, errAttachmentNotFound))
+		return
+	}
+	h.deleteBlobs(ctx, AttachmentsPrefix+attachment.StoragePath)
+
+	respondMessage(c, http.StatusOK, "attachment_deleted")
+}
+
+// GetAttachmentStats - 添付ファイルのダウンロード統計（管理者のみ）。
+// book_id・from・to で絞り込み、ダウンロード数の多い順に limit 件（既定値50）を返す。
+func (h *Handler) GetAttachmentStats(c *gin.Context) {
+	var filter repository.AttachmentStatsFilter
+	var err error
+	if filter.BookID, err = queryUUID(c, "book_id"); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.From, err = queryTime(c, "from", false); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.To, err = queryTime(c, "to", true); err != nil {
+		respondErr(c, err)
+		return
+	}
+	page, err := parsePage(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter.Limit = page.Limit
+
+	stats, err := h.store.Attachments().Stats(c.Request.Context(), filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	items := make([]AttachmentStatsResponse, 0, len(stats))
+	for _, s := range stats {
+		items = append(items, AttachmentStatsResponse{
+			Attachment:     newAttachmentResponse(s.Attachment),
+			Downloads:      s.Downloads,
+			UniqueUsers:    s.UniqueUsers,
+			LastDownloaded: s.LastDownloaded,
+		})
+	}
+	respond(c, http.StatusOK, newList(items))
+}
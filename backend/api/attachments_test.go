@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+// uploadAttachment は bookID に添付ファイルを visibility の公開範囲でアップロードする
+func (s *testServer) uploadAttachment(bookID uuid.UUID, name string, data []byte, visibility string) AttachmentResponse {
+	s.t.Helper()
+	rec := s.postForm("/api/v1/admin/books/"+bookID.String()+"/attachments", map[string]string{"visibility": visibility},
+		[]formFile{{"file", name, data}}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var res AttachmentResponse
+	decode(s.t, rec, &res)
+	return res
+}
+
+// attachmentIDs は利用者が token で見える bookID の添付ファイルの ID を返す
+func (s *testServer) attachmentIDs(bookID uuid.UUID, token string) map[uuid.UUID]bool {
+	s.t.Helper()
+	rec := s.do("GET", "/api/v1/books/"+bookID.String()+"/attachments", nil, token)
+	expectStatus(s.t, rec, http.StatusOK)
+	var res ListResponse[AttachmentResponse]
+	decode(s.t, rec, &res)
+	ids := make(map[uuid.UUID]bool)
+	for _, a := range res.Items {
+		ids[a.ID] = true
+	}
+	return ids
+}
+
+func TestAttachmentVisibility(t *testing.T) {
+	s := newTestServer(t)
+	bookID := s.createBook(models.Book{Title: "添付テスト", Type: "book", TotalCopies: 1})
+	public := s.uploadAttachment(bookID, "public.txt", []byte("公開"), models.VisibilityPublic)
+	lab := s.uploadAttachment(bookID, "lab.txt", []byte("研究室内"), "")
+	admin := s.uploadAttachment(bookID, "admin.txt", []byte("管理者のみ"), models.VisibilityAdmin)
+	if lab.Visibility != models.VisibilityLab || public.ContentType != "text/plain; charset=utf-8" {
+		t.Fatalf("uploaded = %+v, %+v", public, lab)
+	}
+
+	// 自分で登録したユーザー（guest）は研究室のメンバーとして扱わない
+	expectStatus(t, s.do("POST", "/api/v1/auth/register",
+		models.RegisterRequest{StudentID: "s2405", Name: "登録した人", Password: "password123"}, ""), http.StatusOK)
+	guestToken := s.token(s.userByStudentID("s2405"))
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  []AttachmentResponse
+	}{
+		{"anonymous", "", []AttachmentResponse{public}},
+		{"guest", guestToken, []AttachmentResponse{public}},
+		{"user", s.userToken, []AttachmentResponse{public, lab}},
+		{"admin", s.adminToken, []AttachmentResponse{public, lab, admin}},
+	} {
+		ids := s.attachmentIDs(bookID, tc.token)
+		if len(ids) != len(tc.want) {
+			t.Fatalf("%s: attachments = %v", tc.name, ids)
+		}
+		for _, a := range tc.want {
+			if !ids[a.ID] {
+				t.Fatalf("%s: %s is not listed", tc.name, a.Filename)
+			}
+		}
+	}
+
+	// 見えない添付ファイルは、未ログインなら login_required、ログイン済みなら見つからない
+	expectError(t, s.do("GET", "/api/v1/attachments/"+lab.ID.String(), nil, ""), errLoginRequired)
+	expectError(t, s.do("GET", "/api/v1/attachments/"+admin.ID.String(), nil, ""), errAttachmentNotFound)
+	expectError(t, s.do("GET", "/api/v1/attachments/"+admin.ID.String(), nil, s.userToken), errAttachmentNotFound)
+	expectError(t, s.do("GET", "/api/v1/attachments/"+lab.ID.String(), nil, guestToken), errAttachmentNotFound)
+	rec := s.do("GET", "/api/v1/attachments/"+lab.ID.String(), nil, s.userToken)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != "研究室内" || rec.Header().Get("Cache-Control") != "private" {
+		t.Fatalf("download = %q, headers = %v", rec.Body.String(), rec.Header())
+	}
+
+	// 公開範囲を変更すると一覧にも反映される
+	rec = s.do("PUT", "/api/v1/admin/attachments/"+admin.ID.String(), UpdateAttachmentRequest{Visibility: strPtr("public")}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if ids := s.attachmentIDs(bookID, ""); !ids[admin.ID] {
+		t.Fatalf("attachments after update = %v", ids)
+	}
+	rec = s.do("PUT", "/api/v1/admin/attachments/"+admin.ID.String(), UpdateAttachmentRequest{Visibility: strPtr("everyone")}, s.adminToken)
+	expectError(t, rec, errInvalidVisibility)
+}
+
+func TestAttachmentUploadErrors(t *testing.T) {
+	s := newTestServer(t)
+	bookID := s.createBook(models.Book{Title: "添付テスト", Type: "book", TotalCopies: 1})
+	path := "/api/v1/admin/books/" + bookID.String() + "/attachments"
+
+	expectError(t, s.postForm(path, nil, nil, s.adminToken), errFileRequired)
+	expectError(t, s.postForm(path, map[string]string{"visibility": "everyone"},
+		[]formFile{{"file", "a.txt", []byte("text")}}, s.adminToken), errInvalidVisibility)
+	// 拡張子と内容が一致しないファイルは受け付けない
+	expectError(t, s.postForm(path, nil, []formFile{{"file", "fake.pdf", []byte("text")}}, s.adminToken), errUnsupportedFileType)
+	expectError(t, s.postForm(path, nil, []formFile{{"file", "run.exe", []byte("MZ")}}, s.adminToken), errUnsupportedFileType)
+	expectError(t, s.postForm("/api/v1/admin/books/"+uuid.New().String()+"/attachments", nil,
+		[]formFile{{"file", "a.txt", []byte("text")}}, s.adminToken), errBookNotFound)
+
+	// パスを含むファイル名はファイル名のみにする
+	a := s.uploadAttachment(bookID, `..\..\etc/notes.txt`, []byte("text"), "")
+	if a.Filename != "notes.txt" {
+		t.Fatalf("filename = %q", a.Filename)
+	}
+
+	// スキャナーが拒否したファイルは登録しない
+	s.h.SetFileScanner(rejectScanner{})
+	expectError(t, s.postForm(path, nil, []formFile{{"file", "virus.txt", []byte("EICAR")}}, s.adminToken), errFileRejected)
+	if ids := s.attachmentIDs(bookID, s.adminToken); len(ids) != 1 {
+		t.Fatalf("attachments = %v", ids)
+	}
+}
+
+// rejectScanner は全てのファイルを拒否する FileScanner
+type rejectScanner struct{}
+
+func (rejectScanner) Scan(ctx context.Context, path string) error {
+	return fmt.Errorf("%w: test signature", ErrFileRejected)
+}
+
+func TestAttachmentDownloadStats(t *testing.T) {
+	s := newTestServer(t)
+	bookID := s.createBook(models.Book{Title: "添付テスト", Type: "book", TotalCopies: 1})
+	a := s.uploadAttachment(bookID, "manual.txt", []byte("0123456789"), models.VisibilityPublic)
+	url := "/api/v1/attachments/" + a.ID.String()
+
+	rec := s.do("GET", url, nil, s.userToken)
+	expectStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag != `"`+a.SHA256+`"` || rec.Header().Get("Content-Disposition") != `attachment; filename=manual.txt` {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+
+	// 途中からの Range とキャッシュの再検証はダウンロード数に含めない
+	for _, tc := range []struct {
+		header, value string
+		status        int
+		body          string
+	}{
+		{"Range", "bytes=2-4", http.StatusPartialContent, "234"},
+		{"Range", "bytes=0-1", http.StatusPartialContent, "01"},
+		{"If-None-Match", etag, http.StatusNotModified, ""},
+	} {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set(tc.header, tc.value)
+		rec := s.send(req, "")
+		if rec.Code != tc.status || rec.Body.String() != tc.body {
+			t.Fatalf("%s %s: status = %d, body = %q", tc.header, tc.value, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = s.do("GET", "/api/v1/admin/attachments/stats?book_id="+bookID.String(), nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var stats ListResponse[AttachmentStatsResponse]
+	decode(t, rec, &stats)
+	if len(stats.Items) != 1 || stats.Items[0].Downloads != 2 || stats.Items[0].UniqueUsers != 1 || stats.Items[0].LastDownloaded == nil {
+		t.Fatalf("stats = %+v", stats.Items)
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/attachments/stats?book_id=x", nil, s.adminToken), errInvalidFilter)
+
+	expectStatus(t, s.do("DELETE", "/api/v1/admin/attachments/"+a.ID.String(), nil, s.adminToken), http.StatusOK)
+	expectError(t, s.do("GET", url, nil, ""), errAttachmentNotFound)
+}
+
+func strPtr(s string) *string { return &s }
@@ -1,13 +1,21 @@
 package api
 
 import (
-	"database/sql"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
 	"net/http"
+	"net/mail"
+	"strings"
 	"time"
+	"unicode/utf8"
 
-	"lablib/config"
 	"lablib/middleware"
 	"lablib/models"
+	"lablib/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,36 +23,114 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func Login(c *gin.Context) {
+// 一括登録で発行する資格情報
+const (
+	InviteTTL             = 14 * 24 * time.Hour // 招待の有効期間
+	initialPasswordLength = 12
+	minPasswordLength     = 8
+	// passwordAlphabet は初期パスワードに使う文字（0・O・1・l・I など見分けにくい文字を除く）
+	passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+)
+
+// randomPassword は紙で配布しても読み間違えにくい初期パスワードを生成する
+func randomPassword() (string, error) {
+	b := make([]byte, initialPasswordLength)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// newInviteToken は招待のトークンと、保存に使うそのハッシュを生成する
+func newInviteToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	// 一覧表の CSV で数式と解釈されないよう "-" などを含まない16進数にする
+	token = hex.EncodeToString(b)
+	return token, hashInviteToken(token), nil
+}
+
+// hashInviteToken は招待のトークンの SHA-256（16進数）を返す
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validatePassword は利用者が設定するパスワードの長さを確認する
+func validatePassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return errInvalidPassword
+	}
+	return nil
+}
+
+// maxNameLength は氏名の最大文字数（users.name の長さ）
+const maxNameLength = 50
+
+// validateName は氏名が空でなく maxNameLength 文字以内であることを確認する
+func validateName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return errInvalidName
+	}
+	return nil
+}
+
+// validateEmail はメールアドレスの形式を確認する。空の場合は未設定として受け付ける。
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return errInvalidEmail
+	}
+	return nil
+}
+
+// デフォルトユーザーの学籍番号（QuickBorrowBook の貸出先にも使われる）
+const (
+	DefaultUserStudentID  = "00061204"
+	DefaultAdminStudentID = "00999999"
+)
+
+func (h *Handler) Login(c *gin.Context) {
 	var loginReq models.LoginRequest
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, errInvalidRequest)
 		return
 	}
 
-	var user models.User
-	err := config.DB.QueryRow(`
-		SELECT id, student_id, name, password, role, created_at, updated_at
-		FROM users
-		WHERE student_id = $1
-	`, loginReq.StudentID).Scan(
-		&user.ID, &user.StudentID, &user.Name, &user.Password,
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	user, err := h.store.Users().GetByStudentID(c.Request.Context(), loginReq.StudentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, errInvalidCredentials)
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		respondInternalError(c, err)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginReq.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		respondError(c, errInvalidCredentials)
+		return
+	}
+	// 利用停止・卒業したユーザーはパスワードが正しくてもログインできない
+	if !user.Active() {
+		respondError(c, errAccountInactive)
 		return
 	}
 
+	respondLogin(c, user)
+}
+
+// respondLogin は user のトークン（有効期間24時間）を発行してログインのレスポンスを返す
+func respondLogin(c *gin.Context, user *models.User) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": user.ID.String(),
 		"role":    user.Role,
@@ -53,108 +139,149 @@ func Login(c *gin.Context) {
 
 	tokenString, err := token.SignedString(middleware.JWTSecret)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		respondInternalError(c, err)
 		return
 	}
 
-	userResponse := models.UserResponse{
-		ID:        user.ID,
-		StudentID: user.StudentID,
-		Name:      user.Name,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}
-
-	c.JSON(http.StatusOK, models.LoginResponse{
+	respond(c, http.StatusOK, models.LoginResponse{
 		Token: tokenString,
-		User:  userResponse,
+		User:  newUserResponse(*user),
 	})
 }
 
-func CreateDefaultUsers() error {
-	// 一般ユーザーの作成
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Dependable61204"), bcrypt.DefaultCost)
+// AcceptInvite - 一括登録で発行した招待のトークンでパスワードを設定し、ログインする。
+// 招待は1回だけ使え、使うと同じユーザーの他の招待も無効になる。
+func (h *Handler) AcceptInvite(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		respondErr(c, err)
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		respondInternalError(c, err)
+		return
 	}
 
-	_, err = config.DB.Exec(`
-		INSERT INTO users (id, student_id, name, password, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (student_id) DO NOTHING
-	`,
-		uuid.New(), "00061204", "一般さん", string(hashedPassword),
-		"user", time.Now(), time.Now(),
-	)
+	var user *models.User
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		tokenHash := hashInviteToken(req.Token)
+		invite, err := tx.Users().GetInvite(ctx, tokenHash)
+		if err != nil {
+			return notFoundAs(err, errInvalidInvite)
+		}
+		now := time.Now()
+		if invite.UsedAt != nil || now.After(invite.ExpiresAt) {
+			return errInvalidInvite
+		}
+		if user, err = tx.Users().Get(ctx, invite.UserID); err != nil {
+			return notFoundAs(err, errInvalidInvite)
+		}
+		if !user.Active() {
+			return errAccountInactive
+		}
+		if err := tx.Users().SetPassword(ctx, user.ID, string(hashedPassword)); err != nil {
+			return err
+		}
+		return tx.Users().UseInvite(ctx, tokenHash, now)
+	})
 	if err != nil {
-		return err
+		respondErr(c, err)
+		return
 	}
 
-	// 管理者ユーザーの作成
-	_, err = config.DB.Exec(`
-		INSERT INTO users (id, student_id, name, password, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (student_id) DO NOTHING
-	`,
-		uuid.New(), "00999999", "管理者さん", string(hashedPassword),
-		"admin", time.Now(), time.Now(),
-	)
+	respondLogin(c, user)
+}
+
+// CreateDefaultUsers は一般ユーザーと管理者ユーザーが未登録であれば作成する
+func CreateDefaultUsers(store repository.Store) error {
+	ctx := context.Background()
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Dependable61204"), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
+	defaults := []models.User{
+		{StudentID: DefaultUserStudentID, Name: "一般さん", Role: "user"},
+		{StudentID: DefaultAdminStudentID, Name: "管理者さん", Role: "admin"},
+	}
+	for _, user := range defaults {
+		user.ID = uuid.New()
+		user.Password = string(hashedPassword)
+		user.Status = models.UserStatusActive
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = time.Now()
+
+		// 既に登録済みの場合は何もしない
+		if err := store.Users().Create(ctx, &user); err != nil && !errors.Is(err, repository.ErrConflict) {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *Handler) Register(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
 		return
 	}
-
-	// パスワードのハッシュ化
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	// 自分で登録したユーザーは、管理者がロールを user にするまで研究室のメンバーとして扱わない
+	user, err := newUser(req, models.RoleGuest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+		respondErr(c, err)
 		return
 	}
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start transaction"})
+	err = h.store.Users().Create(c.Request.Context(), user)
+	if errors.Is(err, repository.ErrConflict) {
+		respondError(c, errDuplicateStudentID)
 		return
-	}
-	defer tx.Rollback()
-
-	// ユーザーの作成
-	user.ID = uuid.New()
-	user.Password = string(hashedPassword)
-	user.Role = "user" // 一般ユーザーとして登録
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
-
-	_, err = tx.Exec(`
-		INSERT INTO users (id, student_id, name, password, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`,
-		user.ID, user.StudentID, user.Name, user.Password,
-		user.Role, user.CreatedAt, user.UpdatedAt,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
+	} else if err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
-	// トランザクションのコミット
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error committing transaction"})
-		return
+	// パスワードを除外してレスポンスを返す
+	respond(c, http.StatusOK, newUserResponse(*user))
+}
+
+// newUser は登録のリクエストから利用中のロール role のユーザーを作る。パスワードはハッシュ化する。
+func newUser(req models.RegisterRequest, role string) (*models.User, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.TrimSpace(req.Email)
+	if !validUserStudentID(req.StudentID) {
+		return nil, errInvalidUserStudentID
+	}
+	if err := validateName(req.Name); err != nil {
+		return nil, err
+	}
+	if err := validateEmail(req.Email); err != nil {
+		return nil, err
 	}
 
-	// パスワードを除外してレスポンスを返す
-	user.Password = ""
-	c.JSON(http.StatusOK, user)
+	// パスワードのハッシュ化
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &models.User{
+		ID:        uuid.New(),
+		StudentID: req.StudentID,
+		Name:      req.Name,
+		Email:     req.Email,
+		Password:  string(hashedPassword),
+		Role:      role,
+		Status:    models.UserStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
 }
@@ -2,55 +2,35 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
-	"io/ioutil"
+	"log"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"lablib/codes"
+	"lablib/models"
+	"lablib/repository"
+	"lablib/storage"
+
 	"github.com/boombuler/barcode"
 	"github.com/boombuler/barcode/ean"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
 	"golang.org/x/image/math/fixed"
 )
 
-// バーコードファイル保存ディレクトリ
-const BARCODE_DIR = "./public/barcodes"
-
-// EAN13チェックデジット計算
-func calculateEAN13CheckDigit(code string) int {
-	if len(code) != 12 {
-		return 0
-	}
-
-	oddSum := 0  // 奇数桁の合計
-	evenSum := 0 // 偶数桁の合計
-
-	for i, char := range code {
-		digit, _ := strconv.Atoi(string(char))
-		if (i+1)%2 == 1 { // 奇数桁
-			oddSum += digit
-		} else { // 偶数桁
-			evenSum += digit
-		}
-	}
-
-	// チェックデジット計算
-	total := oddSum + evenSum*3
-	checkDigit := (10 - (total % 10)) % 10
-	return checkDigit
-}
-
 // 卒論用EAN13バーコード生成（6桁学籍番号対応）
 func generateThesisBarcode(year string, studentID string) string {
 	// 年度4桁 + 学籍番号6桁 + 予備00 = 12桁
@@ -67,7 +47,7 @@ func generateThesisBarcode(year string, studentID string) string {
 	}
 
 	// チェックデジット計算
-	checkDigit := calculateEAN13CheckDigit(code12)
+	checkDigit := codes.CheckDigit(code12)
 
 	return code12 + strconv.Itoa(checkDigit)
 }
@@ -136,42 +116,37 @@ func generateBarcodeImageWithNumber(code string, width, height int) (image.Image
 	return finalImg, nil
 }
 
-// バーコード画像生成・保存
-func createAndSaveBarcodeImage(code string, filename string) (string, error) {
-	// ディレクトリ作成
-	err := os.MkdirAll(BARCODE_DIR, 0755)
-	if err != nil {
-		return "", fmt.Errorf("ディレクトリ作成エラー: %v", err)
-	}
+// 保存・プレビューするバーコード画像の幅・高さ（ピクセル）
+const (
+	barcodeImageWidth  = 400
+	barcodeImageHeight = 195
+)
 
+// バーコード画像生成・保存。保存先でのファイル名 storagePath に保存し、ファイルのサイズを返す。
+func (h *Handler) createAndSaveBarcodeImage(ctx context.Context, code string, storagePath string) (int64, error) {
 	// バーコード番号付きの画像生成（高さを195pxに調整）
-	finalImage, err := generateBarcodeImageWithNumber(code, 400, 195)
+	finalImage, err := generateBarcodeImageWithNumber(code, barcodeImageWidth, barcodeImageHeight)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
-	// ファイルパス生成
-	filePath := filepath.Join(BARCODE_DIR, filename+".png")
-
-	// PNG画像ファイル保存
-	file, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("ファイル作成エラー: %v", err)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, finalImage); err != nil {
+		return 0, fmt.Errorf("PNG エンコードエラー: %v", err)
 	}
-	defer file.Close()
 
-	err = png.Encode(file, finalImage)
-	if err != nil {
-		return "", fmt.Errorf("PNG エンコードエラー: %v", err)
+	// PNG画像の保存
+	size := int64(buf.Len())
+	if err := h.blobs.Put(ctx, BarcodesPrefix+storagePath, &buf, size, "image/png"); err != nil {
+		return 0, fmt.Errorf("ファイル保存エラー: %v", err)
 	}
-
-	return filePath, nil
+	return size, nil
 }
 
 // Base64画像データ生成（番号付き・プレーン形式のみ）
 func generateBase64Image(code string) (string, error) {
 	// バーコード番号付きの画像生成
-	finalImage, err := generateBarcodeImageWithNumber(code, 400, 195)
+	finalImage, err := generateBarcodeImageWithNumber(code, barcodeImageWidth, barcodeImageHeight)
 	if err != nil {
 		return "", err
 	}
@@ -185,164 +160,419 @@ func generateBase64Image(code string) (string, error) {
 	return "data:image/png;base64," + base64String, nil
 }
 
-// 卒論バーコード生成API（管理者専用）
-func GenerateThesisBarcode(c *gin.Context) {
-	var req struct {
-		Year       string `json:"year" binding:"required"`        // 年度
-		StudentID  string `json:"student_id" binding:"required"`  // 学籍番号
-		AuthorName string `json:"author_name" binding:"required"` // 作者名
-		Title      string `json:"title" binding:"required"`       // 論文タイトル
-	}
-
+// 卒論バーコード生成API（管理者専用）。
+// バーコード画像の保存と同時に論文を目録に登録する（同じ年度・学籍番号の論文や book_id で指定した論文があれば関連付ける）。
+// 同じコードのバーコードを生成済みの場合は画像を保存し直さず、既存の記録を返す。
+// 登録と画像の保存はどちらかが失敗した場合に両方を取り消す。
+func (h *Handler) GenerateThesisBarcode(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req ThesisBarcodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "必須フィールドが不足しています"})
+		respondError(c, errMissingFields)
 		return
 	}
 
 	// 学籍番号の検証（6桁）
-	cleanStudentID := strings.ReplaceAll(req.StudentID, "-", "")
-	cleanStudentID = strings.ReplaceAll(cleanStudentID, " ", "")
-
-	if len(cleanStudentID) != 6 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "学籍番号は6桁で入力してください"})
+	studentID, err := cleanStudentID(req.StudentID)
+	if err != nil {
+		respondError(c, errInvalidStudentID)
 		return
 	}
-
-	// 数字のみかチェック
-	for _, char := range cleanStudentID {
-		if char < '0' || char > '9' {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "学籍番号は数字のみで入力してください"})
-			return
-		}
+	academicYear, err := parseAcademicYear(req.Year)
+	if err != nil {
+		respondErr(c, err)
+		return
 	}
+	year := strconv.Itoa(academicYear)
 
 	// バーコード生成
-	barcode := generateThesisBarcode(req.Year, cleanStudentID)
+	barcode := generateThesisBarcode(year, studentID)
 
-	// ファイル名生成（年度_学籍番号_タイムスタンプ）
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("thesis_%s_%s_%s", req.Year, cleanStudentID, timestamp)
-
-	// バーコード画像保存（番号付き・プレーン形式）
-	filePath, err := createAndSaveBarcodeImage(barcode, filename)
+	// Base64画像データ生成（プレビュー用、番号付き・プレーン形式）
+	base64Image, err := generateBase64Image(barcode)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
-	// Base64画像データ生成（プレビュー用、番号付き・プレーン形式）
-	base64Image, err := generateBase64Image(barcode)
+	// ダウンロード時のファイル名（年度_学籍番号_タイムスタンプ）。保存先でのファイル名はサーバー側で一意に決める。
+	now := time.Now()
+	record := models.Barcode{
+		ID:          uuid.New(),
+		Code:        barcode,
+		Symbology:   models.BarcodeSymbologyEAN13,
+		Filename:    fmt.Sprintf("thesis_%s_%s_%s.png", year, studentID, now.Format("20060102_150405")),
+		StoragePath: uuid.New().String() + ".png",
+		Params: models.BarcodeParams{
+			Year:       year,
+			StudentID:  studentID,
+			AuthorName: req.AuthorName,
+			Title:      req.Title,
+			Width:      barcodeImageWidth,
+			Height:     barcodeImageHeight,
+		},
+		CreatedBy: currentViewer(c).userID,
+		CreatedAt: now,
+	}
+
+	var book *models.Book
+	var created, existing, saved bool
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if book, created, err = registerThesis(ctx, tx, req, academicYear, studentID, barcode); err != nil {
+			return err
+		}
+		var copyID *uuid.UUID
+		if bc, err := tx.Copies().FindByBarcode(ctx, barcode); err == nil {
+			copyID = &bc.ID
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("find copy: %w", err)
+		}
+
+		// 生成済みのコードは既存の記録を論文・コピーに関連付け直して返す
+		prev, err := tx.Barcodes().FindByCode(ctx, record.Symbology, barcode)
+		switch {
+		case err == nil:
+			existing = true
+			record = *prev
+			if !sameID(record.BookID, &book.ID) || !sameID(record.CopyID, copyID) {
+				record.BookID, record.CopyID = &book.ID, copyID
+				if err := tx.Barcodes().SetLinks(ctx, record.ID, record.BookID, record.CopyID); err != nil {
+					return err
+				}
+			}
+			// 画像が失われている場合のみ同じファイル名で保存し直す
+			if _, err := h.blobs.Stat(ctx, BarcodesPrefix+record.StoragePath); !errors.Is(err, storage.ErrNotExist) {
+				return err
+			}
+			_, err = h.createAndSaveBarcodeImage(ctx, barcode, record.StoragePath)
+			return err
+		case !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+
+		// バーコード画像保存（番号付き・プレーン形式）。目録への登録の確定前に保存し、失敗した場合は登録を取り消す。
+		if record.Size, err = h.createAndSaveBarcodeImage(ctx, barcode, record.StoragePath); err != nil {
+			return err
+		}
+		saved = true
+		record.BookID, record.CopyID = &book.ID, copyID
+		return tx.Barcodes().Create(ctx, &record)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "プレビュー画像生成エラー"})
+		// 画像の保存後に登録が失敗した場合は画像を残さない
+		if saved {
+			h.deleteBlobs(ctx, BarcodesPrefix+record.StoragePath)
+		}
+		respondErr(c, err)
 		return
 	}
 
-	// レスポンス
-	response := map[string]interface{}{
-		"barcode":     barcode,
-		"year":        req.Year,
-		"student_id":  cleanStudentID,
-		"author_name": req.AuthorName,
-		"title":       req.Title,
-		"filename":    filename + ".png",
-		"file_path":   filePath,
-		"image_data":  base64Image,
-		"created_at":  time.Now().Format("2006-01-02 15:04:05"),
-		"status":      "生成完了（プレーン番号付き画像）",
+	status := "生成完了（プレーン番号付き画像）"
+	if existing {
+		status = "生成済み（既存の画像）"
 	}
+	respond(c, http.StatusOK, ThesisBarcodeResponse{
+		ID:         record.ID,
+		Barcode:    barcode,
+		Year:       year,
+		StudentID:  studentID,
+		AuthorName: req.AuthorName,
+		Title:      req.Title,
+		Filename:   record.Filename,
+		FilePath:   BarcodesPrefix + record.StoragePath,
+		ImageData:  base64Image,
+		CreatedAt:  record.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		Status:     status,
+		BookID:     book.ID,
+		CopyID:     record.CopyID,
+		Created:    created,
+		Existing:   existing,
+	})
+}
 
-	c.JSON(http.StatusOK, response)
+// sameID は a と b が同じ ID（または両方 nil）かどうかを返す
+func sameID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
-// 保存されたバーコード一覧取得API（管理者専用）
-func GetSavedBarcodes(c *gin.Context) {
-	files, err := ioutil.ReadDir(BARCODE_DIR)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ディレクトリ読み取りエラー"})
+// 保存されたバーコード一覧取得API（管理者専用）。
+// コード（前方一致）・種類・書籍・コピー・生成者・年度・学籍番号で絞り込む。
+func (h *Handler) GetSavedBarcodes(c *gin.Context) {
+	var filter repository.BarcodeFilter
+	var err error
+	if filter.Sort, err = parseSort(c, repository.BarcodeSortFields); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.Page, err = parsePage(c); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.BookID, err = queryUUID(c, "book_id"); err != nil {
+		respondErr(c, err)
 		return
 	}
+	if filter.CopyID, err = queryUUID(c, "copy_id"); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.CreatedBy, err = queryUUID(c, "created_by"); err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter.Code, filter.Symbology = c.Query("code"), c.Query("symbology")
+	filter.Year, filter.StudentID = c.Query("year"), c.Query("student_id")
 
-	var barcodes []map[string]interface{}
+	barcodes, total, err := h.store.Barcodes().List(c.Request.Context(), filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".png") {
-			// ファイル名から情報抽出
-			nameWithoutExt := strings.TrimSuffix(file.Name(), ".png")
-			parts := strings.Split(nameWithoutExt, "_")
-
-			barcode := map[string]interface{}{
-				"filename":   file.Name(),
-				"file_path":  filepath.Join(BARCODE_DIR, file.Name()),
-				"created_at": file.ModTime().Format("2006-01-02 15:04:05"),
-				"size":       file.Size(),
-			}
+	items := make([]SavedBarcodeResponse, 0, len(barcodes))
+	for _, b := range barcodes {
+		items = append(items, newSavedBarcodeResponse(b))
+	}
+	respond(c, http.StatusOK, SavedBarcodesResponse{newPage(items, filter.Page, total)})
+}
 
-			// ファイル名から年度と学籍番号を抽出
-			if len(parts) >= 3 && parts[0] == "thesis" {
-				barcode["year"] = parts[1]
-				barcode["student_id"] = parts[2]
-			}
+// findBarcode は :id パラメータのバーコードを取得する。見つからない場合はレスポンスを書き込み false を返す。
+func (h *Handler) findBarcode(c *gin.Context) (*models.Barcode, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	b, err := h.store.Barcodes().Get(c.Request.Context(), id)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errBarcodeNotFound))
+		return nil, false
+	}
+	return b, true
+}
 
-			barcodes = append(barcodes, barcode)
-		}
+// バーコード画像ダウンロードAPI（管理者専用）。ID で指定し、保存先が S3 の場合は署名付き URL へリダイレクトする。
+func (h *Handler) DownloadBarcodeImage(c *gin.Context) {
+	b, ok := h.findBarcode(c)
+	if !ok {
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"barcodes": barcodes,
-		"count":    len(barcodes),
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	h.serveBlob(c, BarcodesPrefix+b.StoragePath, errFileNotFound, blobOptions{
+		ContentType:        "application/octet-stream",
+		ContentDisposition: mime.FormatMediaType("attachment", map[string]string{"filename": b.Filename}),
 	})
 }
 
-// バーコード画像ダウンロードAPI（管理者専用）
-func DownloadBarcodeImage(c *gin.Context) {
-	filename := c.Param("filename")
+// バーコード画像削除API（管理者専用）。ID で指定し、記録を削除した後に画像を削除する。
+func (h *Handler) DeleteBarcodeImage(c *gin.Context) {
+	b, ok := h.findBarcode(c)
+	if !ok {
+		return
+	}
 
-	if filename == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイル名が必要です"})
+	ctx := c.Request.Context()
+	if err := h.store.Barcodes().Delete(ctx, b.ID); err != nil {
+		respondErr(c, notFoundAs(err, errBarcodeNotFound))
 		return
 	}
+	h.deleteBlobs(ctx, BarcodesPrefix+b.StoragePath)
 
-	filePath := filepath.Join(BARCODE_DIR, filename)
+	respond(c, http.StatusOK, FileDeletedResponse{
+		Message:  message(c, "file_deleted"),
+		Filename: b.Filename,
+	})
+}
 
-	// ファイル存在チェック
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "ファイルが見つかりません"})
-		return
+// duplicateBarcodeImage は記録のない画像のうち、同じコードのバーコード existing が記録済みのもの
+type duplicateBarcodeImage struct {
+	key      string
+	existing *models.Barcode
+}
+
+// scanSavedBarcodes は記録のないバーコード画像（記録の導入前に保存された画像）を調べ、
+// 記録として作るものと、同じコードのバーコードが記録済みの重複に分けて返す。
+// ファイル名（thesis_年度_学籍番号_タイムスタンプ.png）からバーコードを求め、同じ年度・学籍番号の論文があれば関連付ける。
+func (h *Handler) scanSavedBarcodes(ctx context.Context) ([]models.Barcode, []duplicateBarcodeImage, error) {
+	files, err := h.blobs.List(ctx, BarcodesPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	barcodes, _, err := h.store.Barcodes().List(ctx, repository.BarcodeFilter{})
+	if err != nil {
+		return nil, nil, err
+	}
+	known := map[string]bool{}
+	for _, b := range barcodes {
+		known[b.StoragePath] = true
 	}
 
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(filePath)
+	var records []models.Barcode
+	var duplicates []duplicateBarcodeImage
+	for _, file := range files {
+		name := strings.TrimPrefix(file.Key, BarcodesPrefix)
+		if known[name] || strings.Contains(name, "/") || !strings.HasSuffix(name, ".png") {
+			continue
+		}
+		record := models.Barcode{
+			ID:          uuid.New(),
+			Symbology:   models.BarcodeSymbologyEAN13,
+			Filename:    name,
+			StoragePath: name,
+			Size:        file.Size,
+			CreatedAt:   file.ModTime,
+		}
+		parts := strings.Split(strings.TrimSuffix(name, ".png"), "_")
+		if len(parts) >= 3 && parts[0] == "thesis" {
+			record.Code = generateThesisBarcode(parts[1], parts[2])
+			record.Params = models.BarcodeParams{Year: parts[1], StudentID: parts[2]}
+			if existing, err := h.store.Barcodes().FindByCode(ctx, record.Symbology, record.Code); err == nil {
+				duplicates = append(duplicates, duplicateBarcodeImage{key: file.Key, existing: existing})
+				continue
+			} else if !errors.Is(err, repository.ErrNotFound) {
+				return nil, nil, err
+			}
+			if year, err := strconv.Atoi(parts[1]); err == nil {
+				if thesis, err := h.store.Theses().FindByStudent(ctx, year, parts[2]); err == nil {
+					record.BookID = &thesis.BookID
+					record.Params.Title = thesis.Book.Title
+					for _, a := range thesis.Book.Authors {
+						if a.Role == models.AuthorRoleAuthor {
+							record.Params.AuthorName = a.Name
+							break
+						}
+					}
+				}
+			}
+			if bc, err := h.store.Copies().FindByBarcode(ctx, record.Code); err == nil {
+				record.CopyID = &bc.ID
+			}
+		}
+		records = append(records, record)
+	}
+	return records, duplicates, nil
 }
 
-// バーコード画像削除API（管理者専用）
-func DeleteBarcodeImage(c *gin.Context) {
-	filename := c.Param("filename")
+// RegisterSavedBarcodes は記録のないバーコード画像を記録し、記録した件数と重複の画像の件数を返す。
+// 同じコードのバーコードが記録済みの画像は記録も削除もしない（MigrateSavedBarcodes で削除する）。
+func (h *Handler) RegisterSavedBarcodes(ctx context.Context) (int, int, error) {
+	records, duplicates, err := h.scanSavedBarcodes(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, record := range records {
+		if err := h.store.Barcodes().Create(ctx, &record); err != nil {
+			return i, len(duplicates), err
+		}
+	}
+	return len(records), len(duplicates), nil
+}
 
-	if filename == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイル名が必要です"})
+// MigrateSavedBarcodes - 記録の導入前に保存されたバーコードの移行（管理者のみ）。
+//   - 生成時の指定のない卒論バーコードの記録に、コードから年度・学籍番号を補う
+//   - 同じ種類・コードの記録は最初に生成したものだけを残し、他の記録を削除する
+//   - 削除した記録の画像と、記録済みのコードと重複する記録のない画像を削除する（残す記録の画像がない場合は削除しない）
+//
+// dry_run の場合は対象を返すだけで変更しない。
+func (h *Handler) MigrateSavedBarcodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req BarcodeMigrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
 		return
 	}
+	result := BarcodeMigrationResponse{DryRun: req.DryRun, Duplicates: []DuplicateBarcodeResponse{}}
 
-	filePath := filepath.Join(BARCODE_DIR, filename)
-
-	// ファイル存在チェック
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "ファイルが見つかりません"})
+	barcodes, _, err := h.store.Barcodes().List(ctx, repository.BarcodeFilter{})
+	if err != nil {
+		respondInternalError(c, err)
 		return
 	}
+	// 同じ種類・コードの記録のうち最初に生成したもの
+	first := map[string]models.Barcode{}
+	for _, b := range barcodes {
+		k := b.Symbology + ":" + b.Code
+		if f, ok := first[k]; b.Code != "" && (!ok || b.CreatedAt.Before(f.CreatedAt) ||
+			b.CreatedAt.Equal(f.CreatedAt) && b.ID.String() < f.ID.String()) {
+			first[k] = b
+		}
+	}
 
-	// ファイル削除
-	err := os.Remove(filePath)
+	// remove は重複の画像 key（記録の削除を伴う場合は record）を結果に加え、dry_run でなければ削除する
+	listed := map[string]bool{}
+	remove := func(key string, record *uuid.UUID, kept models.Barcode) error {
+		listed[key] = true
+		dup := DuplicateBarcodeResponse{
+			Filename:  strings.TrimPrefix(key, BarcodesPrefix),
+			Code:      kept.Code,
+			BarcodeID: kept.ID,
+			RecordID:  record,
+		}
+		if _, err := h.blobs.Stat(ctx, BarcodesPrefix+kept.StoragePath); errors.Is(err, storage.ErrNotExist) {
+			dup.Kept = true
+		} else if err != nil {
+			return err
+		}
+		if !req.DryRun {
+			if record != nil {
+				if err := h.store.Barcodes().Delete(ctx, *record); err != nil {
+					return err
+				}
+			}
+			if !dup.Kept {
+				if err := h.blobs.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotExist) {
+					return err
+				}
+			}
+		}
+		result.Duplicates = append(result.Duplicates, dup)
+		return nil
+	}
+
+	for _, b := range barcodes {
+		if f := first[b.Symbology+":"+b.Code]; b.Code != "" && f.ID != b.ID {
+			id := b.ID
+			if err := remove(BarcodesPrefix+b.StoragePath, &id, f); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			continue
+		}
+		// 卒論バーコードのコードは年度（4桁）・学籍番号（6桁）・連番・チェックディジット
+		if b.Params != (models.BarcodeParams{}) || b.Symbology != models.BarcodeSymbologyEAN13 ||
+			len(b.Code) != 13 || strings.Trim(b.Code, "0123456789") != "" {
+			continue
+		}
+		if !req.DryRun {
+			if err := h.store.Barcodes().SetParams(ctx, b.ID, models.BarcodeParams{Year: b.Code[:4], StudentID: b.Code[4:10]}); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+		result.FilledParams++
+	}
+
+	_, duplicates, err := h.scanSavedBarcodes(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ファイル削除エラー"})
+		respondInternalError(c, err)
 		return
 	}
+	for _, d := range duplicates {
+		if listed[d.key] {
+			continue
+		}
+		if err := remove(d.key, nil, *d.existing); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "ファイルが削除されました",
-		"filename": filename,
-	})
+	log.Printf("Saved barcodes migrated: %d params filled, %d duplicates (dry run: %t)",
+		result.FilledParams, len(result.Duplicates), req.DryRun)
+	respond(c, http.StatusOK, result)
 }
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lablib/models"
+	"lablib/storage"
+
+	"github.com/google/uuid"
+)
+
+// useLocalStorage は保存先を一時ディレクトリの Local にし、そのディレクトリを返す
+func (s *testServer) useLocalStorage() string {
+	s.t.Helper()
+	dir := filepath.Join(s.t.TempDir(), "public")
+	if err := os.MkdirAll(filepath.Join(dir, "barcodes"), 0755); err != nil {
+		s.t.Fatal(err)
+	}
+	s.h.SetStorage(storage.NewLocal(dir))
+	return dir
+}
+
+func TestBarcodeDownloadAndDelete(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.useLocalStorage()
+	b := s.generateThesisBarcode("2024", "123456")
+
+	rec := s.do("GET", "/api/v1/admin/barcode/download/"+b.ID.String(), nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Content-Type") != "application/octet-stream" || rec.Body.Len() == 0 {
+		t.Fatalf("download: content type %q, %d bytes", rec.Header().Get("Content-Type"), rec.Body.Len())
+	}
+
+	rec = s.do("DELETE", "/api/v1/admin/barcode/"+b.ID.String(), nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if entries, _ := os.ReadDir(filepath.Join(dir, "barcodes")); len(entries) != 0 {
+		t.Fatalf("barcodes/ after delete: %d files", len(entries))
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/barcode/download/"+b.ID.String(), nil, s.adminToken), errBarcodeNotFound)
+}
+
+// traversalIDs はパスの外のファイルを指そうとする ID
+var traversalIDs = []string{
+	"../secret.txt",
+	"..%2fsecret.txt",
+	"%2e%2e%2fsecret.txt",
+	"%2e%2e/%2e%2e/secret.txt",
+	"..%5csecret.txt",
+	"%5c..%5csecret.txt",
+	"%2fetc%2fpasswd",
+	"/etc/passwd",
+	"C:%5cWindows%5cwin.ini",
+	"..",
+	"thesis_2024_123456_1700000000.png",
+}
+
+func TestBarcodeTraversal(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.useLocalStorage()
+	kept := s.generateThesisBarcode("2024", "123456")
+
+	// barcodes/ の外のファイル
+	outside := []string{filepath.Join(dir, "secret.txt"), filepath.Join(filepath.Dir(dir), "secret.txt")}
+	for _, p := range outside {
+		if err := os.WriteFile(p, []byte("secret"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range traversalIDs {
+		for _, method := range []string{"GET", "DELETE"} {
+			path := "/api/v1/admin/barcode/" + id
+			if method == "GET" {
+				path = "/api/v1/admin/barcode/download/" + id
+			}
+			rec := s.do(method, path, nil, s.adminToken)
+			if rec.Code != http.StatusBadRequest && rec.Code != http.StatusNotFound {
+				t.Errorf("%s %s: status %d, want 400 or 404; body = %s", method, path, rec.Code, rec.Body.String())
+			}
+			if rec.Body.Len() > 0 && string(rec.Body.Bytes()) == "secret" {
+				t.Errorf("%s %s: served a file outside barcodes/", method, path)
+			}
+		}
+	}
+
+	for _, p := range outside {
+		if data, err := os.ReadFile(p); err != nil || string(data) != "secret" {
+			t.Errorf("%s was touched: %q, %v", p, data, err)
+		}
+	}
+	expectStatus(t, s.do("GET", "/api/v1/admin/barcode/download/"+kept.ID.String(), nil, s.adminToken), http.StatusOK)
+}
+
+// 記録の保存先のファイル名が不正な場合（以前のデータなど）も barcodes/ の外は読み書きしない
+func TestBarcodeStoragePathTraversal(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.useLocalStorage()
+	secret := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, storagePath := range []string{"../secret.txt", "..\\secret.txt", "/../secret.txt"} {
+		b := models.Barcode{ID: uuid.New(), Symbology: models.BarcodeSymbologyEAN13, Filename: "x.png", StoragePath: storagePath, CreatedAt: time.Now()}
+		if err := s.store.Barcodes().Create(context.Background(), &b); err != nil {
+			t.Fatal(err)
+		}
+		expectError(t, s.do("GET", "/api/v1/admin/barcode/download/"+b.ID.String(), nil, s.adminToken), errFileNotFound)
+		expectStatus(t, s.do("DELETE", "/api/v1/admin/barcode/"+b.ID.String(), nil, s.adminToken), http.StatusOK)
+	}
+	if data, err := os.ReadFile(secret); err != nil || string(data) != "secret" {
+		t.Fatalf("secret.txt was touched: %q, %v", data, err)
+	}
+}
+
+// 同じコードのバーコードは生成し直さず、既存の記録を返す
+func TestThesisBarcodeReuse(t *testing.T) {
+	s := newTestServer(t)
+	first := s.generateThesisBarcode("2024", "123456")
+	if first.Existing || !first.Created {
+		t.Fatalf("first = %+v", first)
+	}
+	second := s.generateThesisBarcode("2024", "123456")
+	if !second.Existing || second.ID != first.ID || second.Barcode != first.Barcode || second.BookID != first.BookID {
+		t.Fatalf("second = %+v, first = %+v", second, first)
+	}
+	other := s.generateThesisBarcode("2024", "654321")
+	if other.Existing || other.ID == first.ID {
+		t.Fatalf("other = %+v", other)
+	}
+
+	saved := func(query string) []SavedBarcodeResponse {
+		t.Helper()
+		rec := s.do("GET", "/api/v1/admin/barcode/saved"+query, nil, s.adminToken)
+		expectStatus(t, rec, http.StatusOK)
+		var res SavedBarcodesResponse
+		decode(t, rec, &res)
+		if res.Pagination.Total != len(res.Items) {
+			t.Fatalf("%s: total = %d, items = %d", query, res.Pagination.Total, len(res.Items))
+		}
+		return res.Items
+	}
+	items := saved("?student_id=123456")
+	if len(items) != 1 {
+		t.Fatalf("saved = %+v", items)
+	}
+	b := items[0]
+	if b.ID != first.ID || b.Code != first.Barcode || b.Symbology != models.BarcodeSymbologyEAN13 ||
+		b.BookID == nil || *b.BookID != first.BookID || b.CreatedBy == nil || *b.CreatedBy != s.admin.ID ||
+		b.Year != "2024" || b.AuthorName != "卒論 太郎" || b.Title != "卒業論文" ||
+		b.Width != barcodeImageWidth || b.Height != barcodeImageHeight {
+		t.Fatalf("saved barcode = %+v", b)
+	}
+
+	for query, want := range map[string]int{
+		"":                           2,
+		"?code=" + first.Barcode[:4]: 2,
+		"?code=" + first.Barcode:     1,
+		"?symbology=" + models.BarcodeSymbologyEAN13: 2,
+		"?symbology=code128":                         0,
+		"?book_id=" + other.BookID.String():          1,
+		"?created_by=" + s.admin.ID.String():         2,
+		"?year=2023":                                 0,
+	} {
+		if got := saved(query); len(got) != want {
+			t.Errorf("%q: %d barcodes, want %d", query, len(got), want)
+		}
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/barcode/saved?book_id=x", nil, s.adminToken), errInvalidFilter)
+}
+
+// 起動時は重複の画像を残し、移行の API で dry_run を確かめてから削除する
+func TestMigrateSavedBarcodes(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	s.generateThesisBarcode("2024", "123456")
+	for _, name := range []string{"thesis_2024_123456_1700000000.png", "thesis_2024_222222_1700000000.png"} {
+		if err := s.blobs.Put(ctx, BarcodesPrefix+name, bytes.NewReader(testPNG(t)), -1, "image/png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy := models.Barcode{ID: uuid.New(), Code: generateThesisBarcode("2023", "333333"), Symbology: models.BarcodeSymbologyEAN13,
+		Filename: "legacy.png", StoragePath: "legacy.png", CreatedAt: time.Now()}
+	if err := s.store.Barcodes().Create(ctx, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	duplicate := BarcodesPrefix + "thesis_2024_123456_1700000000.png"
+
+	n, dup, err := s.h.RegisterSavedBarcodes(ctx)
+	if err != nil || n != 1 || dup != 1 {
+		t.Fatalf("RegisterSavedBarcodes = %d, %d, %v", n, dup, err)
+	}
+	if _, err := s.blobs.Stat(ctx, duplicate); err != nil {
+		t.Fatalf("duplicate image removed at startup: %v", err)
+	}
+
+	migrate := func(dryRun bool) BarcodeMigrationResponse {
+		t.Helper()
+		rec := s.do("POST", "/api/v1/admin/barcode/migrate", BarcodeMigrationRequest{DryRun: dryRun}, s.adminToken)
+		expectStatus(t, rec, http.StatusOK)
+		var res BarcodeMigrationResponse
+		decode(t, rec, &res)
+		return res
+	}
+	for _, dryRun := range []bool{true, false} {
+		res := migrate(dryRun)
+		if res.DryRun != dryRun || res.FilledParams != 1 || len(res.Duplicates) != 1 ||
+			res.Duplicates[0].Filename != "thesis_2024_123456_1700000000.png" || res.Duplicates[0].RecordID != nil || res.Duplicates[0].Kept {
+			t.Fatalf("migrate(dry_run: %t) = %+v", dryRun, res)
+		}
+		_, err := s.blobs.Stat(ctx, duplicate)
+		b, _ := s.store.Barcodes().Get(ctx, legacy.ID)
+		if dryRun && (err != nil || b.Params != (models.BarcodeParams{})) {
+			t.Fatalf("dry run changed data: %v, %+v", err, b.Params)
+		}
+		if !dryRun && (!errors.Is(err, storage.ErrNotExist) || b.Params != (models.BarcodeParams{Year: "2023", StudentID: "333333"})) {
+			t.Fatalf("after migration: %v, %+v", err, b.Params)
+		}
+	}
+	if res := migrate(false); res.FilledParams != 0 || len(res.Duplicates) != 0 {
+		t.Fatalf("second migration = %+v", res)
+	}
+}
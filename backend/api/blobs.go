@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"lablib/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PublicDir は保存先を設定しない場合にファイルを保存するローカルのディレクトリ
+const PublicDir = "./public"
+
+// 保存先でのファイルの種類ごとのキーの接頭辞（ローカルでは PublicDir 以下のディレクトリになる）
+const (
+	BookImagesPrefix  = "images/books/"
+	BarcodesPrefix    = "barcodes/"
+	AttachmentsPrefix = "attachments/"
+	ThesisPDFPrefix   = "theses/"
+	ImportsPrefix     = "imports/" // 取り込みジョブの CSV・XLSX（完了したら削除する）
+)
+
+// signedURLExpires は保存先が署名付き URL に対応している場合にリダイレクトする URL の有効期限
+const signedURLExpires = 5 * time.Minute
+
+// SetStorage は書籍画像・バーコード画像・添付ファイル・論文PDF・取り込むファイルの保存先を s にする
+func (h *Handler) SetStorage(s storage.Storage) {
+	h.blobs = s
+}
+
+// blobOptions は serveBlob で返すレスポンスヘッダー
+type blobOptions struct {
+	ContentType        string // 空の場合は保存時の Content-Type
+	ContentDisposition string
+	CacheControl       string
+	ETag               string // 空の場合は付けない
+	// Sniff は署名付き URL を使わない場合に Content-Type を内容から判定する
+	// （以前に保存された、拡張子と実際の形式が異なるファイル向け）
+	Sniff bool
+}
+
+// serveBlob は保存先の key のファイルを返す。
+// 保存先が署名付き URL に対応している場合（S3）は、署名付き URL へリダイレクトして保存先から直接配信させる。
+// それ以外は Range・If-None-Match に対応した http.ServeContent で返す。
+// ファイルがない場合は notFound のエラーを返す。
+func (h *Handler) serveBlob(c *gin.Context, key string, notFound apiError, opts blobOptions) {
+	ctx := c.Request.Context()
+
+	if signer, ok := h.blobs.(storage.URLSigner); ok {
+		if _, err := h.blobs.Stat(ctx, key); err != nil {
+			respondBlobError(c, err, notFound)
+			return
+		}
+		if opts.ETag != "" && c.GetHeader("If-None-Match") == opts.ETag {
+			c.Header("ETag", opts.ETag)
+			c.Status(http.StatusNotModified)
+			return
+		}
+		url, err := signer.SignedURL(ctx, key, signedURLExpires, storage.URLOptions{
+			ContentType:        opts.ContentType,
+			ContentDisposition: opts.ContentDisposition,
+			CacheControl:       opts.CacheControl,
+		})
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		// リダイレクト先の URL は期限があるため、リダイレクト自体はキャッシュさせない
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	obj, err := h.blobs.Open(ctx, key)
+	if err != nil {
+		respondBlobError(c, err, notFound)
+		return
+	}
+	defer obj.Close()
+
+	info := obj.Info()
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+	if opts.Sniff {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(obj, head)
+		if _, err := obj.Seek(0, io.SeekStart); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		contentType = http.DetectContentType(head[:n])
+	}
+	c.Header("Content-Type", contentType)
+	if opts.ContentDisposition != "" {
+		c.Header("Content-Disposition", opts.ContentDisposition)
+	}
+	if opts.CacheControl != "" {
+		c.Header("Cache-Control", opts.CacheControl)
+	}
+	if opts.ETag != "" {
+		c.Header("ETag", opts.ETag)
+	}
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, obj)
+}
+
+// respondBlobError は保存先のエラーをレスポンスにする。ファイルがない場合は notFound を返す。
+func respondBlobError(c *gin.Context, err error, notFound apiError) {
+	if errors.Is(err, storage.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+		respondError(c, notFound)
+		return
+	}
+	respondInternalError(c, err)
+}
+
+// readBlob は保存先の key のファイルの内容を読む
+func (h *Handler) readBlob(ctx context.Context, key string) ([]byte, error) {
+	obj, err := h.blobs.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// deleteBlobs は保存先のファイルを削除する。失敗はログに残すだけにする
+// （データベースからは既に削除しているため、ファイルが残っても動作に影響しない）。
+func (h *Handler) deleteBlobs(ctx context.Context, keys ...string) {
+	// リクエストが中断されても削除は行う
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := h.blobs.Delete(ctx, key); err != nil {
+			log.Printf("File deletion warning: %s: %v", key, err)
+		}
+	}
+}
@@ -1,161 +1,223 @@
 package api
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
 
-	"lablib/config"
+	"lablib/imaging"
+	"lablib/models"
+	"lablib/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 const (
-	BookImagesDir     = "./public/images/books"
-	MaxImageSize      = 5 << 20 //5MB
-	AllowedImageTypes = "image/jpeg,image/png,image/webp"
+	MaxImageSize = 5 << 20 //5MB
 )
 
-// GetBookImage - 書籍画像の取得(全ユーザー共通)
-func GetBookImage(c *gin.Context) {
-	bookID := c.Param("id")
+// bookImageCacheControl は書籍画像の Cache-Control。
+// 画像を差し替えるとファイル名が変わるため長めにしてよいが、削除が反映されるよう短めにする。
+const bookImageCacheControl = "public, max-age=300"
 
-	var imagePath string
-	err := config.DB.QueryRow("SELECT image_path FROM books WHERE id = $1", bookID).Scan(&imagePath)
+// findBook は :id パラメータの書籍を取得する。見つからない場合はレスポンスを書き込み false を返す。
+func (h *Handler) findBook(c *gin.Context) (*models.Book, bool) {
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	book, err := h.store.Books().Get(c.Request.Context(), bookID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		respondErr(c, notFoundAs(err, errBookNotFound))
+		return nil, false
+	}
+	return book, true
+}
+
+// bookImageKeys は書籍画像 imagePath の全レンディションの保存先のキーを返す
+func bookImageKeys(imagePath string) []string {
+	keys := make([]string, 0, len(imaging.Sizes))
+	for _, size := range imaging.Sizes {
+		keys = append(keys, BookImagesPrefix+imaging.Filename(imagePath, size))
+	}
+	return keys
+}
+
+// removeBookImage は書籍画像の全レンディションを削除する
+func (h *Handler) removeBookImage(ctx context.Context, imagePath string) {
+	h.deleteBlobs(ctx, bookImageKeys(imagePath)...)
+}
+
+// GetBookImage - 書籍画像の取得(全ユーザー共通)。
+// size（thumb・medium・original、省略時は original）の大きさの画像を返す。
+// 保存先が S3 の場合は署名付き URL へリダイレクトする。
+func (h *Handler) GetBookImage(c *gin.Context) {
+	ctx := c.Request.Context()
+	size := c.DefaultQuery("size", imaging.SizeOriginal)
+	if !containsString(imaging.Sizes, size) {
+		respondError(c, errInvalidImageSize)
 		return
 	}
 
-	if imagePath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+	book, ok := h.findBook(c)
+	if !ok {
 		return
 	}
+	imagePath := book.ImagePath
 
-	fullPath := filepath.Join(BookImagesDir, imagePath)
+	if imagePath == "" {
+		respondError(c, errImageNotFound)
+		return
+	}
 
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image file not found"})
+	name := imaging.Filename(imagePath, size)
+	_, err := h.blobs.Stat(ctx, BookImagesPrefix+name)
+	if errors.Is(err, storage.ErrNotExist) && size != imaging.SizeOriginal {
+		// 縮小画像を生成する前にアップロードされた画像は、初回の取得時に生成する
+		name, err = h.generateBookImage(ctx, imagePath, size)
+	}
+	if err != nil {
+		respondBlobError(c, err, errImageNotFound)
 		return
 	}
 
-	c.File(fullPath)
+	// ファイル名は画像ごとに一意なので、そのまま ETag にする。
+	// 以前の画像は拡張子と実際の形式が異なる場合があるため、Content-Type は内容から判定する。
+	h.serveBlob(c, BookImagesPrefix+name, errImageNotFound, blobOptions{
+		CacheControl: bookImageCacheControl,
+		ETag:         `"` + name + `"`,
+		Sniff:        true,
+	})
 }
 
-// UploadBookImage - 書籍画像のアップロード(管理者のみ)
-func UploadBookImage(c *gin.Context) {
-	bookID := c.Param("id")
-
-	var exists bool
-	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", bookID).Scan(&exists)
+// generateBookImage は元の画像から size の画像を生成し、そのファイル名を返す。
+// 元の画像がデコードできない場合は元の画像のファイル名を返す。
+func (h *Handler) generateBookImage(ctx context.Context, imagePath, size string) (string, error) {
+	data, err := h.readBlob(ctx, BookImagesPrefix+imagePath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return "", err
+	}
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return imagePath, nil
 	}
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	name := imaging.Filename(imagePath, size)
+	if err := h.writeBookImage(ctx, img, name, size); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// writeBookImage は size の大きさの画像を保存先の name に書き出す
+func (h *Handler) writeBookImage(ctx context.Context, img *imaging.Image, name, size string) error {
+	var buf bytes.Buffer
+	if err := img.Encode(&buf, size); err != nil {
+		return err
+	}
+	contentType := "image/jpeg"
+	if img.Format == imaging.FormatPNG {
+		contentType = "image/png"
+	}
+	return h.blobs.Put(ctx, BookImagesPrefix+name, &buf, int64(buf.Len()), contentType)
+}
+
+// saveBookImage は画像 data をデコードし、全ての大きさの画像を保存してファイル名を返す。
+// 画像として扱えない場合は errUnsupportedImageType、画素数が多すぎる場合は errImageTooLarge を返す。
+func (h *Handler) saveBookImage(ctx context.Context, data []byte) (string, error) {
+	img, err := imaging.Decode(data)
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		return "", errImageTooLarge
+	} else if err != nil {
+		return "", errUnsupportedImageType
+	}
+
+	filename := uuid.New().String() + img.Ext()
+	for _, size := range imaging.Sizes {
+		if err := h.writeBookImage(ctx, img, imaging.Filename(filename, size), size); err != nil {
+			h.removeBookImage(ctx, filename)
+			return "", err
+		}
+	}
+	return filename, nil
+}
+
+// UploadBookImage - 書籍画像のアップロード(管理者のみ)。
+// 画像は内容から形式を判定してデコードし、EXIF の向きを補正してメタデータを除いた上で、
+// 縮小画像（thumb・medium）と元の大きさ（original）の画像を保存する。
+func (h *Handler) UploadBookImage(c *gin.Context) {
+	book, ok := h.findBook(c)
+	if !ok {
 		return
 	}
 
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "画像ファイルが見つかりません"})
+		respondError(c, errImageRequired)
 		return
 	}
 	defer file.Close()
 
 	if header.Size > MaxImageSize {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルサイズが大きすぎます（最大5MB）"})
+		respondError(c, errImageTooLarge)
 		return
 	}
 
-	contentType := header.Header.Get("Content-Type")
-	if !strings.Contains(AllowedImageTypes, contentType) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "サポートされていない画像形式です"})
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(file, MaxImageSize+1)); err != nil {
+		respondInternalError(c, err)
 		return
 	}
-
-	if err := os.MkdirAll(BookImagesDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ディレクトリ作成に失敗しました"})
+	if buf.Len() > MaxImageSize {
+		respondError(c, errImageTooLarge)
 		return
 	}
 
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		switch contentType {
-		case "image/jpeg":
-			ext = ".jpg"
-		case "image/png":
-			ext = ".png"
-		case "image/webp":
-			ext = ".webp"
-		}
-	}
-
-	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-	imagePath := filepath.Join(BookImagesDir, filename)
-
-	// 既存画像の削除
-	var oldImagePath string
-	err = config.DB.QueryRow("SELECT image_path FROM books WHERE id = $1", bookID).Scan(&oldImagePath)
-	if err == nil && oldImagePath != "" {
-		oldFullPath := filepath.Join(BookImagesDir, oldImagePath)
-		os.Remove(oldFullPath)
-	}
-
-	dst, err := os.Create(imagePath)
+	ctx := c.Request.Context()
+	filename, err := h.saveBookImage(ctx, buf.Bytes())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ファイル保存に失敗しました"})
+		respondErr(c, err)
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ファイル保存に失敗しました"})
+	if err := h.store.Books().SetImagePath(ctx, book.ID, filename); err != nil {
+		h.removeBookImage(ctx, filename)
+		respondInternalError(c, err)
 		return
 	}
 
-	_, err = config.DB.Exec("UPDATE books SET image_path = $1, updated_at = NOW() WHERE id = $2", filename, bookID)
-	if err != nil {
-		os.Remove(imagePath)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベース更新に失敗しました"})
-		return
+	// 既存画像の削除（新しい画像を登録した後に行う）
+	if book.ImagePath != "" {
+		h.removeBookImage(ctx, book.ImagePath)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":    "画像をアップロードしました",
-		"image_path": filename,
+	respond(c, http.StatusOK, ImageUploadResponse{
+		Message:   message(c, "image_uploaded"),
+		ImagePath: filename,
 	})
 }
 
 // DeleteBookImage - 書籍画像の削除（管理者のみ）
-func DeleteBookImage(c *gin.Context) {
-	bookID := c.Param("id")
-
-	var imagePath string
-	err := config.DB.QueryRow("SELECT image_path FROM books WHERE id = $1", bookID).Scan(&imagePath)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+func (h *Handler) DeleteBookImage(c *gin.Context) {
+	book, ok := h.findBook(c)
+	if !ok {
 		return
 	}
+	imagePath := book.ImagePath
 
 	if imagePath == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No image to delete"})
+		respondError(c, errImageNotFound)
 		return
 	}
 
-	fullPath := filepath.Join(BookImagesDir, imagePath)
-	os.Remove(fullPath)
-
-	_, err = config.DB.Exec("UPDATE books SET image_path = NULL, updated_at = NOW() WHERE id = $1", bookID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database update failed"})
+	if err := h.store.Books().SetImagePath(c.Request.Context(), book.ID, ""); err != nil {
+		respondInternalError(c, err)
 		return
 	}
+	h.removeBookImage(c.Request.Context(), imagePath)
 
-	c.JSON(http.StatusOK, gin.H{"message": "画像を削除しました"})
+	respondMessage(c, http.StatusOK, "image_deleted")
 }
@@ -1,827 +1,671 @@
 package api
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"lablib/config"
+	"lablib/circulation"
+	"lablib/codes"
+	"lablib/metadata"
 	"lablib/models"
+	"lablib/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-func GetBooks(c *gin.Context) {
-	query := c.Query("query")
-	rows, err := config.DB.Query(`
-    SELECT
-        b.id, b.title, b.author, b.isbn, b.jan, b.ean13, b.type, b.total_copies,
-        b.barcode, b.location, b.image_path, b.created_at, b.updated_at,
-        COUNT(bc.id) FILTER (WHERE bc.is_available = true) AS available_copies
-    FROM books b
-    LEFT JOIN book_copies bc ON bc.book_id = b.id
-    WHERE b.title ILIKE $1 OR b.author ILIKE $1 OR b.isbn ILIKE $1 OR b.jan ILIKE $1 OR b.ean13 ILIKE $1
-    GROUP BY b.id
-    ORDER BY b.title
-`, "%"+query+"%")
+// parseBookFilter は書籍一覧・検索に共通のクエリパラメーター（query・type・location・author・year・publisher・language・subject・available・sort・limit・offset）を読み取る
+func parseBookFilter(c *gin.Context) (repository.BookFilter, error) {
+	filter := repository.BookFilter{
+		Query:     c.Query("query"),
+		Type:      c.Query("type"),
+		Location:  c.Query("location"),
+		Author:    c.Query("author"),
+		Publisher: c.Query("publisher"),
+		Language:  strings.ToLower(c.Query("language")),
+		Subject:   c.Query("subject"),
+	}
+	var err error
+	if v := c.Query("year"); v != "" {
+		if filter.Year, err = strconv.Atoi(v); err != nil || filter.Year < 1 {
+			return filter, errInvalidFilter
+		}
+	}
+	if filter.Available, err = queryBool(c, "available"); err != nil {
+		return filter, err
+	}
+	if filter.Sort, err = parseSort(c, repository.BookSortFields); err != nil {
+		return filter, err
+	}
+	if filter.Page, err = parsePage(c); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func newBookResponses(results []models.BookSummary) []BookResponse {
+	books := make([]BookResponse, 0, len(results))
+	for _, b := range results {
+		books = append(books, newBookResponse(b.Book, b.AvailableCopies))
+	}
+	return books
+}
+
+// GetBooks - 書籍一覧。parseBookFilter の条件で絞り込み、sort・limit・offset で並び順と範囲を指定する。
+func (h *Handler) GetBooks(c *gin.Context) {
+	filter, err := parseBookFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		respondErr(c, err)
 		return
 	}
-	defer rows.Close()
 
-	var books []map[string]interface{}
-	for rows.Next() {
-		var book models.Book
-		var barcode, location, imagePath sql.NullString
-		var availableCopies int
-		err := rows.Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN,
-			&book.JAN, &book.EAN13, &book.Type, &book.TotalCopies,
-			&barcode, &location, &imagePath, &book.CreatedAt, &book.UpdatedAt, &availableCopies,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning books"})
-			return
-		}
-		books = append(books, map[string]interface{}{
-			"id":           book.ID,
-			"title":        book.Title,
-			"author":       book.Author,
-			"isbn":         book.ISBN,
-			"jan":          book.JAN,
-			"ean13":        book.EAN13,
-			"type":         book.Type,
-			"total_copies": book.TotalCopies,
-			"barcode":      barcode.String,
-			"location":     location.String,
-			"image_path":   imagePath.String,
-			"created_at":   book.CreatedAt,
-			"updated_at":   book.UpdatedAt,
-			"available":    availableCopies > 0,
-		})
-	}
-
-	c.JSON(http.StatusOK, books)
+	results, total, err := h.store.Books().Search(c.Request.Context(), filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, newPage(newBookResponses(results), filter.Page, total))
 }
 
-func GetBookDetails(c *gin.Context) {
-	bookID := c.Param("id")
-	var book models.Book
-	var barcode, location, imagePath sql.NullString
-	err := config.DB.QueryRow(`
-    	SELECT id, title, author, isbn, jan, ean13, type, total_copies, barcode, location, image_path, created_at, updated_at
-    	FROM books
-    	WHERE id = $1
-	`, bookID).Scan(
-		&book.ID, &book.Title, &book.Author, &book.ISBN,
-		&book.JAN, &book.EAN13, &book.Type, &book.TotalCopies,
-		&barcode, &location, &imagePath, &book.CreatedAt, &book.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+func (h *Handler) GetBookDetails(c *gin.Context) {
+	ctx := c.Request.Context()
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	}
+
+	book, err := h.store.Books().Get(ctx, bookID)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errBookNotFound))
 		return
 	}
 
 	// 貸出可否・貸出中情報を取得
-	var availableCopies int
-	var borrowedBy, borrowedAt, dueDate sql.NullString
-	err = config.DB.QueryRow(`
-        SELECT
-            COUNT(*) FILTER (WHERE is_available = true) AS available_copies
-        FROM book_copies
-        WHERE book_id = $1
-    `, bookID).Scan(&availableCopies)
+	availableCopies, err := h.store.Copies().CountAvailableByBook(ctx, bookID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching availability"})
+		respondInternalError(c, err)
 		return
 	}
 
-	// もし貸出中のコピーがあれば、その情報も取得（最初の1件だけ例示）
-	err = config.DB.QueryRow(`
-        SELECT br.user_id::text, br.borrowed_at::text, br.due_date::text
-        FROM borrow_records br
-        JOIN book_copies bc ON br.book_copy_id = bc.id
-        WHERE bc.book_id = $1 AND br.status = 'borrowed'
-        ORDER BY br.borrowed_at DESC
-        LIMIT 1
-    `, bookID).Scan(&borrowedBy, &borrowedAt, &dueDate)
-	// エラーは無視（貸出中がない場合もある））
-
 	// 貸出履歴の取得
-	rows, err := config.DB.Query(`
-        SELECT br.id, br.user_id, br.book_copy_id, br.borrowed_at, br.due_date, br.returned_at, br.status,
-               u.name as user_name, bc.serial_number
-        FROM borrow_records br
-        JOIN users u ON br.user_id = u.id
-        JOIN book_copies bc ON br.book_copy_id = bc.id
-        WHERE bc.book_id = $1
-        ORDER BY br.borrowed_at DESC
-    `, bookID)
+	records, err := h.store.Loans().ListByBook(ctx, bookID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching borrow history"})
-		return
-	}
-	defer rows.Close()
-
-	var borrowHistory []map[string]interface{}
-	for rows.Next() {
-		var (
-			idStr, userIDStr, bookCopyIDStr string
-			borrowedAt, dueDate             time.Time
-			returnedAt                      sql.NullTime
-			status, userName, serialNumber  string
-		)
-		err := rows.Scan(
-			&idStr, &userIDStr, &bookCopyIDStr,
-			&borrowedAt, &dueDate, &returnedAt, &status,
-			&userName, &serialNumber,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning borrow history"})
-			return
+		respondInternalError(c, err)
+		return
+	}
+
+	detail := BookDetailResponse{
+		Book:          newBookResponse(*book, availableCopies),
+		BorrowHistory: newLoanResponses(records),
+	}
+
+	// 貸出中のコピーがあれば最新の1件を付与
+	for _, l := range detail.BorrowHistory {
+		if l.Status == "borrowed" {
+			l := l
+			detail.CurrentLoan = &l
+			break
 		}
-		borrowHistory = append(borrowHistory, map[string]interface{}{
-			"id":         idStr,
-			"userId":     userIDStr,
-			"userName":   userName,
-			"borrowedAt": borrowedAt,
-			"dueDate":    dueDate,
-			"returnedAt": func() *time.Time {
-				if returnedAt.Valid {
-					return &returnedAt.Time
-				}
-				return nil
-			}(),
-			"status": status,
-		})
-	}
-
-	// レスポンスを組み立て
-	bookMap := map[string]interface{}{
-		"id":           book.ID,
-		"title":        book.Title,
-		"author":       book.Author,
-		"isbn":         book.ISBN,
-		"jan":          book.JAN,
-		"ean13":        book.EAN13,
-		"type":         book.Type,
-		"total_copies": book.TotalCopies,
-		"barcode":      barcode.String,
-		"location":     location.String,
-		"image_path":   imagePath.String,
-		"created_at":   book.CreatedAt,
-		"updated_at":   book.UpdatedAt,
-		"available":    availableCopies > 0,
-	}
-	if borrowedBy.Valid {
-		bookMap["borrowedBy"] = borrowedBy.String
-	}
-	if borrowedAt.Valid {
-		bookMap["borrowedAt"] = borrowedAt.String
-	}
-	if dueDate.Valid {
-		bookMap["dueDate"] = dueDate.String
-	}
-
-	// ...書籍情報の組み立て...
-	c.JSON(http.StatusOK, gin.H{
-		"book":           bookMap,
-		"borrow_history": borrowHistory,
-	})
+	}
+
+	respond(c, http.StatusOK, detail)
 }
 
-func BorrowBook(c *gin.Context) {
+func (h *Handler) BorrowBook(c *gin.Context) {
 	// JSONからバーコードとユーザーIDを受け取る
-	var req struct {
-		Barcode string `json:"barcode"`
-		UserID  string `json:"user_id"`
-	}
+	var req BorrowRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストデータが正しくありません"})
+		respondError(c, errInvalidRequest)
 		return
 	}
-	barcode := req.Barcode
-	userID := req.UserID
 
-	if barcode == "" || userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "バーコードとユーザーIDは必須です"})
+	if req.Barcode == "" || req.UserID == "" {
+		respondError(c, errMissingFields)
 		return
 	}
 
 	// UUIDの変換
-	userUUID, err := uuid.Parse(userID)
+	userID, err := uuid.Parse(req.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なユーザーIDです"})
+		respondError(c, errInvalidID)
 		return
 	}
 
-	// ← ユーザー存在チェックをトランザクション前に実行
-	var userExists bool
-	err = config.DB.QueryRow(`
-        SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)
-    `, userUUID).Scan(&userExists)
+	record, err := h.circulation.Checkout(c.Request.Context(), circulation.CheckoutRequest{
+		UserID:  userID,
+		Barcode: req.Barcode,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー確認エラー"})
+		respondErr(c, err)
 		return
 	}
-	if !userExists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "指定されたユーザーが見つかりません"})
+
+	respond(c, http.StatusOK, CheckoutResponse{
+		Message:        message(c, "checkout_succeeded"),
+		BorrowRecordID: record.ID,
+		DueDate:        record.DueDate,
+	})
+}
+
+// QuickBorrowBook - book_idから直接貸出（ワンクリック貸出用・認証なし）
+func (h *Handler) QuickBorrowBook(c *gin.Context) {
+	var req QuickBorrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
 		return
 	}
 
-	// バーコードからbook_copy_idを取得
-	var bookCopyID string
-	err = config.DB.QueryRow(`
-        SELECT id FROM book_copies WHERE barcode = $1 AND is_available = true LIMIT 1
-    `, barcode).Scan(&bookCopyID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "貸出可能な書籍コピーが見つかりません"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DBエラー"})
+	if req.BookID == "" {
+		respondError(c, errMissingFields)
 		return
 	}
 
-	bookCopyUUID, err := uuid.Parse(bookCopyID)
+	bookID, err := uuid.Parse(req.BookID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "無効な書籍コピーIDです"})
+		respondError(c, errInvalidID)
 		return
 	}
 
-	// ここでbookCopyIDからbookIDを取得
-	var bookID string
-	err = config.DB.QueryRow(`
-        SELECT book_id FROM book_copies WHERE id = $1
-    `, bookCopyID).Scan(&bookID)
+	// デフォルトユーザー（一般ユーザー）を取得
+	user, err := h.store.Users().GetByStudentID(c.Request.Context(), DefaultUserStudentID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍ID取得エラー"})
+		respondInternalError(c, fmt.Errorf("default user: %w", err))
 		return
 	}
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
+	record, err := h.circulation.Checkout(c.Request.Context(), circulation.CheckoutRequest{
+		UserID: user.ID,
+		BookID: bookID,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トランザクション開始失敗"})
+		respondErr(c, err)
 		return
 	}
-	defer tx.Rollback()
 
-	// 貸出記録の作成
-	borrowID := uuid.New()
-	borrowedAt := time.Now()
-	dueDate := borrowedAt.Add(14 * 24 * time.Hour) // 2週間後
+	respond(c, http.StatusOK, CheckoutResponse{
+		Message:        message(c, "checkout_succeeded"),
+		BorrowRecordID: record.ID,
+		DueDate:        record.DueDate,
+	})
+}
 
-	_, err = tx.Exec(`
-        INSERT INTO borrow_records (id, user_id, book_copy_id, borrowed_at, due_date, status)
-        VALUES ($1, $2, $3, $4, $5, $6)
-    `, borrowID, userUUID, bookCopyUUID, borrowedAt, dueDate, "borrowed")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "貸出記録作成エラー: " + err.Error()})
+func (h *Handler) ReturnBook(c *gin.Context) {
+	// JSONからバーコードとユーザーIDを受け取る
+	var req BorrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
 		return
 	}
 
-	// 書籍コピーの状態を更新
-	_, err = tx.Exec(`
-        UPDATE book_copies SET is_available = false WHERE id = $1
-    `, bookCopyID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍コピー状態更新エラー"})
+	if req.Barcode == "" || req.UserID == "" {
+		respondError(c, errMissingFields)
 		return
 	}
 
-	// 月次ランキングの更新
-	month := time.Now().Format("2006-01")
-	var count int
-	err = tx.QueryRow(`
-        SELECT COUNT(*) FROM monthly_rankings WHERE month = $1 AND book_id = $2
-    `, month, bookID).Scan(&count)
+	userID, err := uuid.Parse(req.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ランキング取得エラー"})
+		respondError(c, errInvalidID)
 		return
 	}
-	if count == 0 {
-		// 新規作成
-		_, err = tx.Exec(`
-            INSERT INTO monthly_rankings (id, month, book_id, borrow_count)
-            VALUES ($1, $2, $3, 1)
-        `, uuid.New(), month, bookID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "ランキング作成エラー"})
-			return
-		}
-	} else {
-		// カウントをインクリメント
-		_, err = tx.Exec(`
-            UPDATE monthly_rankings SET borrow_count = borrow_count + 1
-            WHERE month = $1 AND book_id = $2
-        `, month, bookID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "ランキング更新エラー"})
-			return
-		}
-	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "コミットエラー"})
+	record, err := h.circulation.Checkin(c.Request.Context(), circulation.CheckinRequest{
+		UserID:  userID,
+		Barcode: req.Barcode,
+	})
+	if err != nil {
+		respondErr(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "貸出成功",
-		"due_date": dueDate,
+	respond(c, http.StatusOK, ReturnResponse{
+		Message:        message(c, "return_succeeded"),
+		BorrowRecordID: record.ID,
+		ReturnedAt:     *record.ReturnedAt,
 	})
 }
 
-// QuickBorrowBook - book_idから直接貸出（ワンクリック貸出用・認証なし）
-func QuickBorrowBook(c *gin.Context) {
-	var req struct {
-		BookID string `json:"book_id"`
-	}
+// RenewBook - 貸出期限の延長
+func (h *Handler) RenewBook(c *gin.Context) {
+	var req RenewRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストデータが正しくありません"})
+		respondError(c, errInvalidRequest)
 		return
 	}
 
-	if req.BookID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "書籍IDは必須です"})
-		return
-	}
-
-	// デフォルトユーザー（一般ユーザー）を取得
-	var userID string
-	err := config.DB.QueryRow(`
-        SELECT id FROM users WHERE student_id = '00061204' LIMIT 1
-    `).Scan(&userID)
+	recordID, err := uuid.Parse(req.BorrowRecordID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "デフォルトユーザーの取得に失敗しました"})
+		respondError(c, errInvalidID)
 		return
 	}
 
-	userUUID, err := uuid.Parse(userID)
+	record, err := h.circulation.Renew(c.Request.Context(), recordID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なユーザーIDです"})
+		respondErr(c, err)
 		return
 	}
 
-	// 利用可能な書籍コピーを取得
-	var bookCopyID string
-	err = config.DB.QueryRow(`
-        SELECT id FROM book_copies 
-        WHERE book_id = $1 AND is_available = true 
-        LIMIT 1
-    `, req.BookID).Scan(&bookCopyID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "貸出可能な書籍コピーが見つかりません"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DBエラー"})
-		return
-	}
+	respond(c, http.StatusOK, RenewResponse{
+		Message:        message(c, "renew_succeeded"),
+		BorrowRecordID: record.ID,
+		DueDate:        record.DueDate,
+		RenewCount:     record.RenewCount,
+	})
+}
 
-	bookCopyUUID, err := uuid.Parse(bookCopyID)
+// GetBorrowHistory - 貸出履歴。user_id・book_id・status・from・to・overdue で絞り込む。
+func (h *Handler) GetBorrowHistory(c *gin.Context) {
+	filter, err := parseLoanFilter(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "無効な書籍コピーIDです"})
+		respondErr(c, err)
 		return
 	}
-
-	// トランザクション開始
-	tx, err := config.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トランザクション開始失敗"})
+	// 認証ミドルウェアが無効なため、user_id の指定がなければ全履歴を返す
+	if filter.UserID, err = queryUUID(c, "user_id"); err != nil {
+		respondErr(c, err)
 		return
 	}
-	defer tx.Rollback()
-
-	// 貸出記録の作成
-	borrowID := uuid.New()
-	borrowedAt := time.Now()
-	dueDate := borrowedAt.Add(14 * 24 * time.Hour) // 2週間後
 
-	_, err = tx.Exec(`
-        INSERT INTO borrow_records (id, user_id, book_copy_id, borrowed_at, due_date, status)
-        VALUES ($1, $2, $3, $4, $5, $6)
-    `, borrowID, userUUID, bookCopyUUID, borrowedAt, dueDate, "borrowed")
+	records, total, err := h.store.Loans().List(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "貸出記録作成エラー: " + err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
-	// 書籍コピーの状態を更新
-	_, err = tx.Exec(`
-        UPDATE book_copies SET is_available = false WHERE id = $1
-    `, bookCopyID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍コピー状態更新エラー"})
-		return
+	respond(c, http.StatusOK, BorrowHistoryResponse{newPage(newLoanResponses(records), filter.Page, total)})
+}
+
+// parseLoanFilter は貸出履歴の一覧に共通のクエリパラメーター（status・book_id・from・to・overdue・sort・limit・offset）を読み取る
+func parseLoanFilter(c *gin.Context) (repository.LoanFilter, error) {
+	filter := repository.LoanFilter{Status: c.Query("status")}
+	if filter.Status != "" && filter.Status != "borrowed" && filter.Status != "returned" {
+		return filter, errInvalidFilter
 	}
 
-	// 月次ランキングの更新
-	month := time.Now().Format("2006-01")
-	var count int
-	err = tx.QueryRow(`
-        SELECT COUNT(*) FROM monthly_rankings WHERE month = $1 AND book_id = $2
-    `, month, req.BookID).Scan(&count)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ランキング取得エラー"})
-		return
+	var err error
+	var overdue *bool
+	if filter.BookID, err = queryUUID(c, "book_id"); err != nil {
+		return filter, err
 	}
-	if count == 0 {
-		_, err = tx.Exec(`
-            INSERT INTO monthly_rankings (id, month, book_id, borrow_count)
-            VALUES ($1, $2, $3, 1)
-        `, uuid.New(), month, req.BookID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "ランキング作成エラー"})
-			return
-		}
-	} else {
-		_, err = tx.Exec(`
-            UPDATE monthly_rankings SET borrow_count = borrow_count + 1
-            WHERE month = $1 AND book_id = $2
-        `, month, req.BookID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "ランキング更新エラー"})
-			return
-		}
+	if filter.BorrowedFrom, err = queryTime(c, "from", false); err != nil {
+		return filter, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "コミットエラー"})
-		return
+	if filter.BorrowedTo, err = queryTime(c, "to", true); err != nil {
+		return filter, err
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "貸出成功",
-		"due_date": dueDate,
-	})
-}
-
-func ReturnBook(c *gin.Context) {
-	// JSONからバーコードとユーザーIDを受け取る
-	var req struct {
-		Barcode string `json:"barcode"`
-		UserID  string `json:"user_id"`
+	if overdue, err = queryBool(c, "overdue"); err != nil {
+		return filter, err
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストデータが正しくありません"})
-		return
+	filter.Overdue = overdue != nil && *overdue
+	if filter.Sort, err = parseSort(c, repository.LoanSortFields); err != nil {
+		return filter, err
 	}
-	barcode := req.Barcode
-	userID := req.UserID
+	if filter.Page, err = parsePage(c); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
 
-	if barcode == "" || userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "バーコードとユーザーIDは必須です"})
+// 書籍情報自動取得
+func (h *Handler) FetchBookInfo(c *gin.Context) {
+	if c.Query("isbn") == "" {
+		respondError(c, errMissingFields)
 		return
 	}
-
-	// バーコードからbook_copy_idを取得
-	var bookCopyID string
-	err := config.DB.QueryRow(`
-        SELECT id FROM book_copies WHERE barcode = $1 LIMIT 1
-    `, barcode).Scan(&bookCopyID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "書籍コピーが見つかりません"})
+	isbn, err := codes.ISBN13(c.Query("isbn"))
+	if errors.Is(err, codes.ErrBookPrice) {
+		respondError(c, errBookPriceCode)
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DBエラー"})
+		respondError(c, errInvalidISBN)
 		return
 	}
 
-	// 貸出記録の存在確認
-	var borrowRecordID string
-	err = config.DB.QueryRow(`
-        SELECT id FROM borrow_records
-        WHERE book_copy_id = $1 AND user_id = $2 AND status = 'borrowed'
-    `, bookCopyID, userID).Scan(&borrowRecordID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "貸出記録が見つかりません"})
+	// 設定された順序の外部サービスから取得してまとめる
+	record, err := h.bookInfo.Fetch(c.Request.Context(), isbn)
+	if errors.Is(err, metadata.ErrNotFound) {
+		respondError(c, errBookInfoNotFound)
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DBエラー"})
+		log.Printf("Book info fetch warning: %s: %v", isbn, err)
+		respondError(c, errUpstream)
 		return
 	}
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トランザクション開始失敗"})
-		return
+	info := BookInfoResponse{
+		ISBN:        isbn,
+		Title:       record.Title,
+		Author:      strings.Join(record.Authors, ", "),
+		Authors:     record.Authors,
+		Publisher:   record.Publisher,
+		PageCount:   record.PageCount,
+		Description: record.Description,
+		Subjects:    record.Subjects,
+		CoverURL:    record.CoverURL,
+		Sources:     record.Sources,
 	}
-	defer tx.Rollback()
-
-	// 返却日時の更新
-	returnedAt := time.Now()
-	_, err = tx.Exec(`
-        UPDATE borrow_records
-        SET returned_at = $1, status = 'returned'
-        WHERE id = $2
-    `, returnedAt, borrowRecordID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "返却記録更新エラー"})
-		return
+	// 登録時の検証に通らない形式の出版日・言語は渡さない
+	if _, err := parsePublishedDate(record.PublishedDate); err == nil {
+		info.PublishedDate = record.PublishedDate
 	}
+	if languagePattern.MatchString(record.Language) {
+		info.Language = record.Language
+	}
+	respond(c, http.StatusOK, info)
+}
 
-	// 書籍コピーの状態を更新
-	_, err = tx.Exec(`
-        UPDATE book_copies SET is_available = true WHERE id = $1
-    `, bookCopyID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍コピー状態更新エラー"})
-		return
+// publishedDateLayouts は出版日として受け付ける精度ごとの形式
+var publishedDateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// parsePublishedDate は出版日を検証して年を返す
+func parsePublishedDate(s string) (int, error) {
+	for _, layout := range publishedDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Year(), nil
+		}
 	}
+	return 0, errInvalidPublishedDate
+}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "コミットエラー"})
-		return
+// languagePattern は言語コード（"ja"・"en"・"zh-tw" など、小文字化済み）
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// normalizeBook は書誌情報を検証し、保存する形に揃える。
+//   - Authors の空の氏名を除き、役割の省略は "author" とする。Authors が空なら Author を1人目の著者とする
+//   - Author は Authors のうち役割が "author" の氏名を ", " で連結し直す（編者・訳者・指導教員は含めない）
+//   - PublishedDate がある場合は PublishedYear をその年にする（0年は未設定）
+//   - Language は小文字にし、Subjects は空白を除いて重複をなくす
+//   - ISBN は ISBN-13 に揃え、JAN・EAN13 はハイフンを除く。いずれもチェックディジットを検証し、書籍JANコードの2段目は受け付けない
+func normalizeBook(b *models.Book) error {
+	b.Title = strings.TrimSpace(b.Title)
+
+	if err := normalizeCode(&b.ISBN, codes.ISBN13, errInvalidISBN); err != nil {
+		return err
+	}
+	if err := normalizeCode(&b.JAN, codes.JAN, errInvalidJAN); err != nil {
+		return err
+	}
+	if err := normalizeCode(&b.EAN13, codes.EAN13, errInvalidEAN13); err != nil {
+		return err
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "返却成功",
-		"returned_at": returnedAt,
-	})
-}
+	authors := make([]models.BookAuthor, 0, len(b.Authors))
+	for _, a := range b.Authors {
+		a.Name = strings.TrimSpace(a.Name)
+		if a.Name == "" {
+			continue
+		}
+		if a.Role == "" {
+			a.Role = models.AuthorRoleAuthor
+		}
+		if !containsString(models.AuthorRoles, a.Role) {
+			return errInvalidAuthorRole
+		}
+		authors = append(authors, a)
+	}
+	if name := strings.TrimSpace(b.Author); len(authors) == 0 && name != "" {
+		authors = append(authors, models.BookAuthor{Name: name, Role: models.AuthorRoleAuthor})
+	}
+	names := make([]string, 0, len(authors))
+	for _, a := range authors {
+		if a.Role == models.AuthorRoleAuthor {
+			names = append(names, a.Name)
+		}
+	}
+	b.Authors = authors
+	b.Author = strings.Join(names, ", ")
 
-func GetBorrowHistory(c *gin.Context) {
-	// 認証ミドルウェアが無効なため、常に全履歴を返す
-	query := `
-        SELECT br.id, br.user_id, br.book_copy_id, br.borrowed_at, br.due_date, br.returned_at, br.status,
-               b.title, b.author, bc.serial_number, u.name as user_name
-        FROM borrow_records br
-        JOIN book_copies bc ON br.book_copy_id = bc.id
-        JOIN books b ON bc.book_id = b.id
-        JOIN users u ON br.user_id = u.id
-        ORDER BY br.borrowed_at DESC
-    `
-	rows, err := config.DB.Query(query)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching borrow history"})
-		return
-	}
-	defer rows.Close()
-
-	var history []map[string]interface{}
-	for rows.Next() {
-		var (
-			idStr, userIDStr, bookCopyIDStr string
-			borrowedAt, dueDate             time.Time
-			returnedAt                      sql.NullTime
-			status, title, author           string
-			serialNumber, userName          string
-		)
-		err := rows.Scan(
-			&idStr, &userIDStr, &bookCopyIDStr,
-			&borrowedAt, &dueDate, &returnedAt, &status,
-			&title, &author, &serialNumber, &userName,
-		)
+	b.PublishedDate = strings.TrimSpace(b.PublishedDate)
+	if b.PublishedDate != "" {
+		year, err := parsePublishedDate(b.PublishedDate)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning borrow history"})
-			return
+			return err
+		}
+		b.PublishedYear = &year
+	} else if b.PublishedYear != nil {
+		if *b.PublishedYear == 0 {
+			b.PublishedYear = nil
+		} else if *b.PublishedYear < 1 || *b.PublishedYear > 9999 {
+			return errInvalidPublishedDate
 		}
-		history = append(history, map[string]interface{}{
-			"id":         idStr,
-			"userId":     userIDStr,
-			"itemId":     bookCopyIDStr,
-			"itemTitle":  title,
-			"userName":   userName,
-			"borrowedAt": borrowedAt,
-			"dueDate":    dueDate,
-			"returnedAt": func() *time.Time {
-				if returnedAt.Valid {
-					return &returnedAt.Time
-				}
-				return nil
-			}(),
-			"status": status,
-		})
 	}
-	c.JSON(http.StatusOK, history)
-}
 
-// 書籍情報自動取得
-func FetchBookInfo(c *gin.Context) {
-	isbn := c.Query("isbn")
-	if isbn == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ISBNが必要です"})
-		return
+	b.Language = strings.ToLower(strings.TrimSpace(b.Language))
+	if b.Language != "" && !languagePattern.MatchString(b.Language) {
+		return errInvalidLanguage
+	}
+	if b.PageCount < 0 {
+		return errInvalidRequest
 	}
 
-	// Google Books APIから書籍情報を取得
-	url := fmt.Sprintf("https://www.googleapis.com/books/v1/volumes?q=isbn:%s", isbn)
-	resp, err := http.Get(url)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍情報の取得に失敗しました"})
-		return
+	subjects := make([]string, 0, len(b.Subjects))
+	for _, subject := range b.Subjects {
+		if subject = strings.TrimSpace(subject); subject != "" && !containsString(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
 	}
-	defer resp.Body.Close()
+	b.Subjects = subjects
+	return nil
+}
 
-	var result struct {
-		Items []struct {
-			VolumeInfo struct {
-				Title     string   `json:"title"`
-				Authors   []string `json:"authors"`
-				Publisher string   `json:"publisher"`
-			} `json:"volumeInfo"`
-		} `json:"items"`
+// normalizeCode は空でないコードを normalize で検証して置き換える。不正な場合は invalid を返す。
+func normalizeCode(v *string, normalize func(string) (string, error), invalid apiError) error {
+	if strings.TrimSpace(*v) == "" {
+		*v = ""
+		return nil
 	}
+	code, err := normalize(*v)
+	if errors.Is(err, codes.ErrBookPrice) {
+		return errBookPriceCode
+	} else if err != nil {
+		return invalid
+	}
+	*v = code
+	return nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "レスポンスの解析に失敗しました"})
-		return
+// findDuplicateBooks は book と ISBN・JAN・EAN13 のいずれかが同じ他の書籍を返す。
+// ISBN-13 は ISBN-10 で登録された書籍とも比較する。
+func findDuplicateBooks(ctx context.Context, books repository.BookRepository, book *models.Book) ([]DuplicateBookResponse, error) {
+	keys := map[string]string{} // 比較用のコード → book のコード
+	for _, code := range []string{book.ISBN, book.JAN, book.EAN13} {
+		if code == "" {
+			continue
+		}
+		keys[code] = code
+		if isbn10, ok := codes.ISBN10(code); ok {
+			keys[isbn10] = code
+		}
+	}
+	duplicates := []DuplicateBookResponse{}
+	if len(keys) == 0 {
+		return duplicates, nil
 	}
 
-	if len(result.Items) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "書籍情報が見つかりません"})
-		return
+	list := make([]string, 0, len(keys))
+	for k := range keys {
+		list = append(list, k)
+	}
+	found, err := books.FindByCodes(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range found {
+		if b.ID == book.ID {
+			continue
+		}
+		for _, other := range []string{b.ISBN, b.JAN, b.EAN13} {
+			if code, ok := keys[codes.Clean(other)]; ok && other != "" {
+				duplicates = append(duplicates, DuplicateBookResponse{BookID: b.ID, Title: b.Title, Code: code})
+				break
+			}
+		}
 	}
+	return duplicates, nil
+}
 
-	book := result.Items[0].VolumeInfo
-	author := ""
-	if len(book.Authors) > 0 {
-		author = strings.Join(book.Authors, ", ")
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
 	}
+	return false
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"title":     book.Title,
-		"author":    author,
-		"publisher": book.Publisher,
-	})
+// apply は更新リクエストを書籍に反映する。省略された書誌情報の項目（nil）は現在の値のままにする。
+// authors を省略して author を変更した場合は、変更後の author を唯一の著者とする。
+func (req UpdateBookRequest) apply(book *models.Book) {
+	if req.Authors != nil {
+		book.Authors = req.Authors
+	} else if req.Author != book.Author {
+		book.Authors = nil
+	}
+	book.Title = req.Title
+	book.Author = req.Author
+	book.ISBN = req.ISBN
+	setIfPresent(&book.JAN, req.JAN)
+	setIfPresent(&book.EAN13, req.EAN13)
+	book.Location = req.Location
+	book.TotalCopies = req.TotalCopies
+	if req.PublishedYear != nil {
+		book.PublishedYear = req.PublishedYear
+		book.PublishedDate = ""
+	}
+	setIfPresent(&book.Publisher, req.Publisher)
+	setIfPresent(&book.PublishedDate, req.PublishedDate)
+	setIfPresent(&book.Edition, req.Edition)
+	setIfPresent(&book.Language, req.Language)
+	setIfPresent(&book.Description, req.Description)
+	setIfPresent(&book.Notes, req.Notes)
+	if req.PageCount != nil {
+		book.PageCount = *req.PageCount
+	}
+	if req.Subjects != nil {
+		book.Subjects = req.Subjects
+	}
+}
+
+func setIfPresent(dst *string, v *string) {
+	if v != nil {
+		*dst = *v
+	}
 }
 
 // 書籍情報更新
-func UpdateBook(c *gin.Context) {
-	bookID := c.Param("id")
-	var updateData struct {
-		Title       string `json:"title"`
-		Author      string `json:"author"`
-		ISBN        string `json:"isbn"`
-		Location    string `json:"location"`
-		TotalCopies int    `json:"total_copies"`
+func (h *Handler) UpdateBook(c *gin.Context) {
+	ctx := c.Request.Context()
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
 	}
 
+	var updateData UpdateBookRequest
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストデータです"})
+		respondError(c, errInvalidRequest)
 		return
 	}
 
 	// バリデーション
-	if updateData.Title == "" || updateData.Author == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "タイトルと著者は必須です"})
+	if updateData.Title == "" || (updateData.Author == "" && len(updateData.Authors) == 0) {
+		respondError(c, errMissingFields)
 		return
 	}
 	if updateData.TotalCopies < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "複製数は1以上である必要があります"})
+		respondError(c, errInvalidCopyCount)
 		return
 	}
 
-	// 現在の複製数を取得
-	var currentCopies int
-	err := config.DB.QueryRow(`
-        SELECT total_copies FROM books WHERE id = $1
-    `, bookID).Scan(&currentCopies)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "現在の複製数の取得に失敗しました"})
-		return
-	}
+	var duplicates []DuplicateBookResponse
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		// 現在の書籍情報を取得
+		book, err := tx.Books().Get(ctx, bookID)
+		if err != nil {
+			return notFoundAs(err, errBookNotFound)
+		}
+		currentCopies := book.TotalCopies
 
-	// トランザクション開始
-	tx, err := config.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トランザクション開始失敗"})
-		return
-	}
-	defer tx.Rollback()
+		updateData.apply(book)
+		if err := normalizeBook(book); err != nil {
+			return err
+		}
+		book.UpdatedAt = time.Now()
 
-	// 書籍情報を更新
-	_, err = tx.Exec(`
-        UPDATE books 
-        SET title = $1, author = $2, isbn = $3, location = $4, total_copies = $5, updated_at = $6
-        WHERE id = $7
-    `, updateData.Title, updateData.Author, updateData.ISBN, updateData.Location, updateData.TotalCopies, time.Now(), bookID)
+		// 同じコードの書籍があっても更新し、警告として返す
+		if duplicates, err = findDuplicateBooks(ctx, tx.Books(), book); err != nil {
+			return fmt.Errorf("find duplicate books: %w", err)
+		}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍情報の更新に失敗しました"})
-		return
-	}
+		// 書籍情報を更新
+		if err := tx.Books().Update(ctx, book); err != nil {
+			return fmt.Errorf("update book: %w", err)
+		}
 
-	// 複製数の変更処理
-	if updateData.TotalCopies != currentCopies {
-		if updateData.TotalCopies > currentCopies {
+		// 複製数の変更処理
+		if book.TotalCopies > currentCopies {
 			// 複製数を増やす場合：新しいbook_copiesを作成
-			for i := currentCopies; i < updateData.TotalCopies; i++ {
-				copyID := uuid.New()
-				serialNumber := bookID[:8] + "-" + uuid.New().String()[:4]
-				_, err = tx.Exec(`
-                    INSERT INTO book_copies (id, book_id, serial_number, barcode, is_available, created_at, updated_at)
-                    VALUES ($1, $2, $3, $4, $5, $6, $7)
-                `, copyID, bookID, serialNumber, updateData.ISBN, true, time.Now(), time.Now())
+			for i := currentCopies; i < book.TotalCopies; i++ {
+				err := tx.Copies().Create(ctx, &models.BookCopy{
+					ID:           uuid.New(),
+					BookID:       book.ID,
+					SerialNumber: newSerialNumber(book.ID),
+					Barcode:      book.ISBN,
+					IsAvailable:  true,
+					CreatedAt:    time.Now(),
+					UpdatedAt:    time.Now(),
+				})
 				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍コピーの作成に失敗しました"})
-					return
+					return fmt.Errorf("create book copy: %w", err)
 				}
 			}
-		} else {
+		} else if book.TotalCopies < currentCopies {
 			// 複製数を減らす場合：利用可能なbook_copiesを削除
-			deleteCount := currentCopies - updateData.TotalCopies
+			deleteCount := currentCopies - book.TotalCopies
 
 			// 利用可能な（貸出中でない）コピーの数を確認
-			var availableCopiesCount int
-			err = tx.QueryRow(`
-                SELECT COUNT(*) FROM book_copies 
-                WHERE book_id = $1 AND is_available = true
-            `, bookID).Scan(&availableCopiesCount)
+			available, err := tx.Copies().CountAvailableByBook(ctx, book.ID)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "利用可能なコピー数の取得に失敗しました"})
-				return
+				return fmt.Errorf("count available copies: %w", err)
 			}
-
-			if deleteCount > availableCopiesCount {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "貸出中のコピーがあるため、指定した数まで削除できません"})
-				return
+			if deleteCount > available {
+				return errCopiesOnLoan
 			}
 
-			// 利用可能なコピーを削除
-			_, err = tx.Exec(`
-                DELETE FROM book_copies 
-                WHERE id IN (
-                    SELECT id FROM book_copies 
-                    WHERE book_id = $1 AND is_available = true 
-                    LIMIT $2
-                )
-            `, bookID, deleteCount)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "書籍コピーの削除に失敗しました"})
-				return
+			if err := tx.Copies().DeleteAvailable(ctx, book.ID, deleteCount); err != nil {
+				return fmt.Errorf("delete book copies: %w", err)
 			}
 		}
-	}
-
-	// トランザクションをコミット
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "変更のコミットに失敗しました"})
+		return nil
+	})
+	if err != nil {
+		respondErr(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "書籍情報が更新されました"})
+	respond(c, http.StatusOK, BookUpdatedResponse{
+		MessageResponse: MessageResponse{Message: message(c, "book_updated")},
+		Duplicates:      duplicates,
+	})
 }
 
 // 貸出記録詳細取得
-func GetBorrowRecordDetails(c *gin.Context) {
-	recordID := c.Param("id")
-
-	var (
-		id, userID, bookCopyID, borrowedAt, dueDate, status     string
-		bookTitle, bookAuthor, bookType, serialNumber, userName string
-		returnedAt                                              sql.NullString
-		bookID                                                  string
-	)
-
-	err := config.DB.QueryRow(`
-        SELECT br.id, br.user_id, br.book_copy_id, br.borrowed_at, br.due_date, br.returned_at, br.status,
-               b.id as book_id, b.title, b.author, b.type, bc.serial_number, u.name as user_name
-        FROM borrow_records br
-        JOIN book_copies bc ON br.book_copy_id = bc.id
-        JOIN books b ON bc.book_id = b.id
-        JOIN users u ON br.user_id = u.id
-        WHERE br.id = $1
-    `, recordID).Scan(
-		&id, &userID, &bookCopyID, &borrowedAt, &dueDate, &returnedAt, &status,
-		&bookID, &bookTitle, &bookAuthor, &bookType, &serialNumber, &userName,
-	)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "貸出記録が見つかりません"})
+func (h *Handler) GetBorrowRecordDetails(c *gin.Context) {
+	recordID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
-		return
-	}
-
-	record := map[string]interface{}{
-		"id":            id,
-		"user_id":       userID,
-		"book_copy_id":  bookCopyID,
-		"borrowed_at":   borrowedAt,
-		"due_date":      dueDate,
-		"status":        status,
-		"book_id":       bookID,
-		"book_title":    bookTitle,
-		"book_author":   bookAuthor,
-		"book_type":     bookType,
-		"serial_number": serialNumber,
-		"user_name":     userName,
 	}
 
-	if returnedAt.Valid {
-		record["returned_at"] = returnedAt.String
+	br, err := h.store.Loans().Get(c.Request.Context(), recordID)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errLoanNotFound))
+		return
 	}
 
-	c.JSON(http.StatusOK, record)
+	respond(c, http.StatusOK, newLoanResponse(*br))
 }
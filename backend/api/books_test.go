@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"lablib/circulation"
+	"lablib/middleware"
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+// domainError は circulation のドメインエラーの apiError を返す
+func domainError(t *testing.T, err *circulation.Error) apiError {
+	t.Helper()
+	e, ok := circulationError(err)
+	if !ok {
+		t.Fatalf("circulationError(%v) failed", err)
+	}
+	return e
+}
+
+func (s *testServer) bookDetail(id uuid.UUID) BookDetailResponse {
+	s.t.Helper()
+	rec := s.do("GET", "/api/v1/books/"+id.String(), nil, "")
+	expectStatus(s.t, rec, http.StatusOK)
+	var detail BookDetailResponse
+	decode(s.t, rec, &detail)
+	return detail
+}
+
+func TestBookCRUD(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createBook(models.Book{Title: "Go言語プログラミング", Author: "山田 太郎", Type: "book", TotalCopies: 2, Location: "A-1"})
+
+	detail := s.bookDetail(id)
+	if detail.Book.Title != "Go言語プログラミング" || detail.Book.TotalCopies != 2 || detail.Book.AvailableCopies != 2 {
+		t.Fatalf("book = %+v", detail.Book)
+	}
+
+	rec := s.do("PUT", "/api/v1/admin/books/"+id.String(), UpdateBookRequest{
+		Title: "Go言語プログラミング 第2版", Author: "山田 太郎", Location: "B-2", TotalCopies: 3,
+	}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if detail = s.bookDetail(id); detail.Book.Title != "Go言語プログラミング 第2版" || detail.Book.Location != "B-2" || detail.Book.AvailableCopies != 3 {
+		t.Fatalf("updated book = %+v", detail.Book)
+	}
+
+	// 複製数は1以上
+	rec = s.do("PUT", "/api/v1/admin/books/"+id.String(), UpdateBookRequest{Title: "題名", Author: "著者"}, s.adminToken)
+	expectError(t, rec, errInvalidCopyCount)
+
+	rec = s.do("GET", "/api/v1/books?query=プログラミング", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	var list PageResponse[BookResponse]
+	decode(t, rec, &list)
+	if len(list.Items) != 1 || list.Items[0].ID != id {
+		t.Fatalf("search = %+v", list.Items)
+	}
+
+	// 除籍した書籍は一覧から消え、除籍の一覧に移る
+	rec = s.do("DELETE", "/api/v1/admin/books/"+id.String(), nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	rec = s.do("GET", "/api/v1/books", nil, "")
+	decode(t, rec, &list)
+	if len(list.Items) != 0 {
+		t.Fatalf("books after withdrawal = %+v", list.Items)
+	}
+	rec = s.do("GET", "/api/v1/admin/books/withdrawn", nil, s.adminToken)
+	decode(t, rec, &list)
+	if len(list.Items) != 1 || list.Items[0].WithdrawnAt == nil {
+		t.Fatalf("withdrawn books = %+v", list.Items)
+	}
+
+	rec = s.do("POST", "/api/v1/admin/books/"+id.String()+"/restore", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	rec = s.do("DELETE", "/api/v1/admin/books/"+id.String(), nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	// 完全な削除は管理者のみ
+	expectError(t, s.do("DELETE", "/api/v1/admin/books/"+id.String()+"/purge", nil, s.userToken), errAdminRequired)
+	expectError(t, s.do("DELETE", "/api/v1/admin/books/"+id.String()+"/purge", nil, ""), errAuthRequired)
+	expectStatus(t, s.do("GET", "/api/v1/books/"+id.String(), nil, s.adminToken), http.StatusOK)
+	rec = s.do("DELETE", "/api/v1/admin/books/"+id.String()+"/purge", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	expectError(t, s.do("GET", "/api/v1/books/"+id.String(), nil, ""), errBookNotFound)
+}
+
+func TestBookErrors(t *testing.T) {
+	s := newTestServer(t)
+	expectError(t, s.do("GET", "/api/v1/books/not-a-uuid", nil, ""), errInvalidID)
+	expectError(t, s.do("POST", "/api/v1/admin/books", []byte("{"), s.adminToken), errInvalidRequest)
+
+	// エラーにはリクエストIDと Accept-Language に合わせた文言が入る
+	req := httptest.NewRequest("GET", "/api/v1/books/"+uuid.New().String(), nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	rec := s.send(req, "")
+	var res ErrorResponse
+	decode(t, rec, &res)
+	if res.Error.Code != errBookNotFound.Code || res.Error.Message != "Book not found" || res.Error.RequestID == "" ||
+		res.Error.RequestID != rec.Header().Get(middleware.RequestIDHeader) {
+		t.Fatalf("error = %+v, X-Request-ID = %q", res.Error, rec.Header().Get(middleware.RequestIDHeader))
+	}
+
+	// 従来の /api は文字列のエラーを返す
+	rec = s.do("GET", "/api/books/not-a-uuid", nil, "")
+	expectStatus(t, rec, http.StatusBadRequest)
+	var legacy map[string]interface{}
+	decode(t, rec, &legacy)
+	if _, ok := legacy["error"].(string); !ok {
+		t.Fatalf("legacy error = %s", rec.Body.String())
+	}
+}
+
+func TestBorrowReturnRenew(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createBook(models.Book{Title: "貸出テスト", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "BC-0001"})
+	borrow := BorrowRequest{Barcode: "BC-0001", UserID: s.user.ID.String()}
+
+	rec := s.do("POST", "/api/v1/books/borrow", borrow, "")
+	expectStatus(t, rec, http.StatusOK)
+	var checkout CheckoutResponse
+	decode(t, rec, &checkout)
+	if days := time.Until(checkout.DueDate).Hours() / 24; days < 13.9 || days > 14 {
+		t.Fatalf("due date = %v", checkout.DueDate)
+	}
+
+	// 貸出中のコピーしかない場合は貸し出せない
+	expectError(t, s.do("POST", "/api/v1/books/borrow", borrow, ""), domainError(t, circulation.ErrNoAvailableCopy))
+	expectError(t, s.do("POST", "/api/v1/books/quick-borrow", QuickBorrowRequest{BookID: id.String()}, ""), domainError(t, circulation.ErrNoAvailableCopy))
+
+	detail := s.bookDetail(id)
+	if detail.Book.Available || detail.CurrentLoan == nil || detail.CurrentLoan.ID != checkout.BorrowRecordID || detail.CurrentLoan.UserID != s.user.ID {
+		t.Fatalf("detail while on loan = %+v", detail)
+	}
+	rec = s.do("GET", "/api/v1/books/borrow-record/"+checkout.BorrowRecordID.String(), nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	var record LoanResponse
+	decode(t, rec, &record)
+	if record.BookTitle != "貸出テスト" || record.UserName != s.user.Name || record.Status != "borrowed" {
+		t.Fatalf("borrow record = %+v", record)
+	}
+
+	// 貸出中の書籍は削除できない
+	expectError(t, s.do("DELETE", "/api/v1/admin/books/"+id.String(), nil, s.adminToken), errBookOnLoan)
+
+	// 延長は1回まで
+	renew := RenewRequest{BorrowRecordID: checkout.BorrowRecordID.String()}
+	rec = s.do("POST", "/api/v1/books/renew", renew, "")
+	expectStatus(t, rec, http.StatusOK)
+	var renewed RenewResponse
+	decode(t, rec, &renewed)
+	if renewed.RenewCount != 1 || renewed.DueDate.Before(checkout.DueDate) {
+		t.Fatalf("renew = %+v, checkout due %v", renewed, checkout.DueDate)
+	}
+	expectError(t, s.do("POST", "/api/v1/books/renew", renew, ""), domainError(t, circulation.ErrRenewLimitReached))
+
+	rec = s.do("POST", "/api/v1/books/return", borrow, "")
+	expectStatus(t, rec, http.StatusOK)
+	var returned ReturnResponse
+	decode(t, rec, &returned)
+	if returned.BorrowRecordID != checkout.BorrowRecordID {
+		t.Fatalf("returned %s, want %s", returned.BorrowRecordID, checkout.BorrowRecordID)
+	}
+	expectError(t, s.do("POST", "/api/v1/books/return", borrow, ""), domainError(t, circulation.ErrLoanNotFound))
+	expectError(t, s.do("POST", "/api/v1/books/renew", renew, ""), domainError(t, circulation.ErrAlreadyReturned))
+
+	rec = s.do("GET", "/api/v1/books/history", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	var history BorrowHistoryResponse
+	decode(t, rec, &history)
+	if len(history.Items) != 1 || history.Items[0].Status != "returned" || history.Items[0].RenewCount != 1 {
+		t.Fatalf("history = %+v", history.Items)
+	}
+
+	// 従来の /api は camelCase の配列を返す
+	rec = s.do("GET", "/api/books/history", nil, "")
+	var legacy []struct {
+		ItemTitle  string     `json:"itemTitle"`
+		ReturnedAt *time.Time `json:"returnedAt"`
+	}
+	decode(t, rec, &legacy)
+	if len(legacy) != 1 || legacy[0].ItemTitle != "貸出テスト" || legacy[0].ReturnedAt == nil {
+		t.Fatalf("legacy history = %s", rec.Body.String())
+	}
+
+	// 返却後は書籍ID指定で既定のユーザーに貸し出せる
+	expectStatus(t, s.do("POST", "/api/v1/books/quick-borrow", QuickBorrowRequest{BookID: id.String()}, ""), http.StatusOK)
+	if detail = s.bookDetail(id); detail.CurrentLoan == nil || detail.CurrentLoan.UserID != s.user.ID {
+		t.Fatalf("quick-borrow loan = %+v", detail.CurrentLoan)
+	}
+	if n, err := s.store.Copies().CountAvailableByBook(context.Background(), id); err != nil || n != 0 {
+		t.Fatalf("available copies = %d, %v", n, err)
+	}
+
+	rec = s.do("GET", "/api/v1/admin/rankings/all-time", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var ranking ListResponse[RankingResponse]
+	decode(t, rec, &ranking)
+	if len(ranking.Items) != 1 || ranking.Items[0].BookID != id || ranking.Items[0].BorrowCount != 2 {
+		t.Fatalf("all-time ranking = %+v", ranking.Items)
+	}
+}
+
+func TestBorrowErrors(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createBook(models.Book{Title: "除籍テスト", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "BC-0002"})
+
+	for _, tc := range []struct {
+		path string
+		body interface{}
+		want apiError
+	}{
+		{"/api/v1/books/borrow", []byte("{"), errInvalidRequest},
+		{"/api/v1/books/borrow", BorrowRequest{Barcode: "BC-0002"}, errMissingFields},
+		{"/api/v1/books/borrow", BorrowRequest{Barcode: "BC-0002", UserID: "x"}, errInvalidID},
+		{"/api/v1/books/borrow", BorrowRequest{Barcode: "BC-0002", UserID: uuid.New().String()}, domainError(t, circulation.ErrUserNotFound)},
+		{"/api/v1/books/borrow", BorrowRequest{Barcode: "NONE", UserID: s.user.ID.String()}, domainError(t, circulation.ErrNoAvailableCopy)},
+		{"/api/v1/books/quick-borrow", QuickBorrowRequest{BookID: "x"}, errInvalidID},
+		{"/api/v1/books/return", BorrowRequest{Barcode: "NONE", UserID: s.user.ID.String()}, domainError(t, circulation.ErrCopyNotFound)},
+		{"/api/v1/books/return", BorrowRequest{Barcode: "BC-0002", UserID: s.user.ID.String()}, domainError(t, circulation.ErrLoanNotFound)},
+		{"/api/v1/books/renew", RenewRequest{BorrowRecordID: "x"}, errInvalidID},
+		{"/api/v1/books/renew", RenewRequest{BorrowRecordID: uuid.New().String()}, domainError(t, circulation.ErrLoanNotFound)},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			expectError(t, s.do("POST", tc.path, tc.body, ""), tc.want)
+		})
+	}
+
+	// 除籍した書籍は貸し出せない
+	expectStatus(t, s.do("DELETE", "/api/v1/admin/books/"+id.String(), nil, s.adminToken), http.StatusOK)
+	expectError(t, s.do("POST", "/api/v1/books/quick-borrow", QuickBorrowRequest{BookID: id.String()}, ""),
+		domainError(t, circulation.ErrBookWithdrawn))
+}
+
+func TestBookMetadata(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createBook(models.Book{
+		Title:         "Goによる並行処理",
+		Authors:       []models.BookAuthor{{Name: " 山田 太郎 "}, {Name: ""}, {Name: "佐藤 次郎", Role: models.AuthorRoleAuthor}, {Name: "田中 三郎", Role: models.AuthorRoleTranslator}},
+		Type:          "book",
+		TotalCopies:   1,
+		Publisher:     "技術評論社",
+		PublishedDate: "2023-04",
+		Language:      "JA",
+		Subjects:      []string{"Go", " Go ", "並行処理", ""},
+		Notes:         "寄贈",
+	})
+	book := s.bookDetail(id).Book
+	// Author には役割が著者の氏名のみを連結する
+	wantAuthors := []BookAuthorResponse{{"山田 太郎", "author"}, {"佐藤 次郎", "author"}, {"田中 三郎", "translator"}}
+	if book.Author != "山田 太郎, 佐藤 次郎" || !reflect.DeepEqual(book.Authors, wantAuthors) {
+		t.Fatalf("authors = %q, %+v", book.Author, book.Authors)
+	}
+	if book.PublishedYear == nil || *book.PublishedYear != 2023 || book.Language != "ja" || book.Publisher != "技術評論社" || book.Notes != "寄贈" {
+		t.Fatalf("book = %+v", book)
+	}
+	if !reflect.DeepEqual(book.Subjects, []string{"Go", "並行処理"}) {
+		t.Fatalf("subjects = %q", book.Subjects)
+	}
+
+	// 件名・出版社・著者以外の役割の氏名でも検索できる
+	for _, query := range []string{"並行処理", "技術評論社", "田中"} {
+		rec := s.do("GET", "/api/v1/books?query="+query, nil, "")
+		var list PageResponse[BookResponse]
+		decode(t, rec, &list)
+		if len(list.Items) != 1 || list.Items[0].ID != id {
+			t.Fatalf("search %q = %+v", query, list.Items)
+		}
+	}
+
+	// 省略した項目は変更しない
+	edition := "第2版"
+	rec := s.do("PUT", "/api/v1/admin/books/"+id.String(), UpdateBookRequest{
+		Title: "Goによる並行処理", Author: "鈴木 一郎", TotalCopies: 1, Edition: &edition,
+	}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	book = s.bookDetail(id).Book
+	if book.Author != "鈴木 一郎" || len(book.Authors) != 1 || book.Edition != "第2版" || book.Publisher != "技術評論社" || len(book.Subjects) != 2 {
+		t.Fatalf("updated book = %+v", book)
+	}
+
+	for _, tc := range []struct {
+		book models.Book
+		want apiError
+	}{
+		{models.Book{Title: "日付", Author: "著者", TotalCopies: 1, PublishedDate: "2023/04"}, errInvalidPublishedDate},
+		{models.Book{Title: "言語", Author: "著者", TotalCopies: 1, Language: "japanese"}, errInvalidLanguage},
+		{models.Book{Title: "役割", Authors: []models.BookAuthor{{Name: "著者", Role: "illustrator"}}, TotalCopies: 1}, errInvalidAuthorRole},
+	} {
+		expectError(t, s.do("POST", "/api/v1/admin/books", tc.book, s.adminToken), tc.want)
+	}
+}
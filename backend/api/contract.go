@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"lablib/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ValidateResponse は /api/v1 のレスポンスが OpenAPI 文書と一致するか検証する。
+// method と path は文書上の表記（例: "GET", "/books/{id}"）で指定する。
+// 一致しない箇所があればすべて列挙したエラーを返す。
+func ValidateResponse(method, path string, status int, body []byte) error {
+	doc := OpenAPI()
+	op := doc.Paths[path][strings.ToLower(method)]
+	if op == nil {
+		return fmt.Errorf("%s %s は文書に定義されていません", method, path)
+	}
+	res := op.Responses[strconv.Itoa(status)]
+	if res == nil {
+		return fmt.Errorf("%s %s: ステータス %d は文書に定義されていません", method, path, status)
+	}
+	media := res.Content["application/json"]
+	if media == nil {
+		// バイナリや本文なしのレスポンスは検証しない
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("%s %s: JSONとして解釈できません: %w", method, path, err)
+	}
+
+	var problems []string
+	validateValue(doc, media.Schema, v, "$", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s %s (%d): %s", method, path, status, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateValue は v が schema を満たすか調べ、違反を problems に追加する
+func validateValue(doc *OpenAPIDocument, schema *Schema, v interface{}, at string, problems *[]string) {
+	if schema.Ref != "" {
+		schema = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	if v == nil {
+		if !schema.Nullable && schema.Type != "" {
+			*problems = append(*problems, at+": null は許可されていません")
+		}
+		return
+	}
+	for _, s := range schema.AllOf {
+		validateValue(doc, s, v, at, problems)
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("object ではありません")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				fail("必須のプロパティ %q がありません", name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := schema.Properties[k]
+			if !ok {
+				prop = schema.AdditionalProperties
+			}
+			if prop == nil {
+				fail("文書にないプロパティ %q があります", k)
+				continue
+			}
+			validateValue(doc, prop, obj[k], at+"."+k, problems)
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			fail("array ではありません")
+			return
+		}
+		for i, item := range arr {
+			validateValue(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			fail("string ではありません")
+			return
+		}
+		switch schema.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				fail("date-time の形式ではありません: %q", s)
+			}
+		case "uuid":
+			if _, err := uuid.Parse(s); err != nil {
+				fail("uuid の形式ではありません: %q", s)
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			fail("integer ではありません")
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			fail("number ではありません")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("boolean ではありません")
+		}
+	}
+}
+
+// bodyRecorder はクライアントへの書き込みと同時にレスポンス本文を記録する
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ContractValidator - /api/v1 のレスポンスを OpenAPI 文書と照合し、違反をログに出力するミドルウェア。
+// 開発時やE2Eテストの実行時に有効にし、ハンドラーと文書のずれを検出する。
+func ContractValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		rt, ok := c.Get(routeKey)
+		if !ok || isLegacy(c) {
+			return
+		}
+		// 形式を選べるルートが JSON 以外を返した場合は検証しない
+		if ct := c.Writer.Header().Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+			return
+		}
+		path, _ := openAPIPath(*rt.(*route))
+		if err := ValidateResponse(c.Request.Method, path, c.Writer.Status(), recorder.body.Bytes()); err != nil {
+			log.Printf("[%s] contract violation: %v", c.GetString(middleware.RequestIDKey), err)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+// contractFixtures はルートごとのリクエストで参照するデータ
+type contractFixtures struct {
+	loanedBook    uuid.UUID // 一般ユーザーが借りている書籍（バーコード CT-0001、画像あり）
+	loan          uuid.UUID // loanedBook の貸出
+	availableBook uuid.UUID // 貸出可能な書籍（バーコード CT-0002）
+	withdrawnBook uuid.UUID // 除籍した書籍
+	thesis        uuid.UUID // PDF のある論文
+	barcode       uuid.UUID // thesis のバーコード
+	attachment    uuid.UUID // 公開の添付ファイル
+	importJob     uuid.UUID // 書籍の取り込みジョブ
+	failedImport  uuid.UUID
+	coverJob      uuid.UUID           // 失敗した表紙画像の取得ジョブ
+	member        models.UserResponse // 貸出のないユーザー
+	inviteToken   string
+}
+
+// testPNG は 2x2 の PNG 画像を返す
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var testPDF = []byte("%PDF-1.4\n%%EOF\n")
+
+// seedContractFixtures は全ルートのリクエストが成功するようにデータを登録する
+func (s *testServer) seedContractFixtures() contractFixtures {
+	s.t.Helper()
+	var f contractFixtures
+
+	f.loanedBook = s.createBook(models.Book{Title: "契約テスト", Author: "山田 太郎", Type: "book", TotalCopies: 1, Barcode: "CT-0001"})
+	rec := s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "CT-0001", UserID: s.user.ID.String()}, "")
+	expectStatus(s.t, rec, http.StatusOK)
+	var checkout CheckoutResponse
+	decode(s.t, rec, &checkout)
+	f.loan = checkout.BorrowRecordID
+	expectStatus(s.t, s.postForm("/api/v1/admin/books/"+f.loanedBook.String()+"/image", nil,
+		[]formFile{{"image", "cover.png", testPNG(s.t)}}, s.adminToken), http.StatusOK)
+
+	f.availableBook = s.createBook(models.Book{Title: "貸出可能", Author: "鈴木 花子", Type: "book", TotalCopies: 1, Barcode: "CT-0002"})
+	f.withdrawnBook = s.createBook(models.Book{Title: "除籍済み", Author: "佐藤 次郎", Type: "book", TotalCopies: 1})
+	expectStatus(s.t, s.do("DELETE", "/api/v1/admin/books/"+f.withdrawnBook.String(), nil, s.adminToken), http.StatusOK)
+
+	b := s.generateThesisBarcode("2024", "123456")
+	f.thesis, f.barcode = b.BookID, b.ID
+	expectStatus(s.t, s.postForm("/api/v1/admin/theses/"+f.thesis.String()+"/pdf", nil,
+		[]formFile{{"pdf", "thesis.pdf", testPDF}}, s.adminToken), http.StatusOK)
+
+	rec = s.postForm("/api/v1/admin/books/"+f.loanedBook.String()+"/attachments", map[string]string{"visibility": models.VisibilityPublic},
+		[]formFile{{"file", "notes.txt", []byte("契約テストの添付ファイル")}}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var attachment AttachmentResponse
+	decode(s.t, rec, &attachment)
+	f.attachment = attachment.ID
+
+	rec = s.postForm("/api/v1/admin/books/import", nil, []formFile{{"file", "books.csv", []byte("title,author\n取り込み,著者\n")}}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var job ImportJobResponse
+	decode(s.t, rec, &job)
+	f.importJob = job.ID
+
+	// 再開できるジョブは失敗した状態で直接登録する
+	now := time.Now()
+	failedImport := models.ImportJob{ID: uuid.New(), Filename: "failed.csv", StoragePath: "missing.csv", Format: "csv",
+		Status: models.ImportStatusFailed, Error: "interrupted", CreatedAt: now, UpdatedAt: now}
+	if err := s.store.ImportJobs().Create(context.Background(), &failedImport); err != nil {
+		s.t.Fatal(err)
+	}
+	f.failedImport = failedImport.ID
+	coverJob := models.CoverJob{ID: uuid.New(), Status: models.ImportStatusFailed, Error: "interrupted", CreatedAt: now, UpdatedAt: now}
+	if err := s.store.CoverJobs().Create(context.Background(), &coverJob); err != nil {
+		s.t.Fatal(err)
+	}
+	f.coverJob = coverJob.ID
+
+	f.member = s.createUser("s2501", "契約 次郎")
+
+	rec = s.postForm("/api/v1/admin/users/import", map[string]string{"credentials": "invite"},
+		[]formFile{{"file", "users.csv", []byte("student_id,name\ns2502,招待 三郎\n")}}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var imported UserImportResponse
+	decode(s.t, rec, &imported)
+	if len(imported.Rows) != 1 || imported.Rows[0].InviteToken == "" {
+		s.t.Fatalf("user import = %+v", imported)
+	}
+	f.inviteToken = imported.Rows[0].InviteToken
+	return f
+}
+
+// contractCase はルート1件に送るリクエスト。token を省略した場合は管理者のトークンを使う。
+type contractCase struct {
+	id     string // パスパラメーターに入れる値
+	query  string
+	body   interface{} // JSON の本文
+	form   map[string]string
+	files  []formFile
+	token  string // "-" はトークンなし
+	status int    // 期待するステータス（省略時は200）
+}
+
+// contractCases は routeTable の全ルートのリクエストを "METHOD パス" をキーとして返す
+func contractCases(s *testServer, f contractFixtures) map[string]contractCase {
+	str := func(v string) *string { return &v }
+	csv := func(content string) []formFile { return []formFile{{"file", "data.csv", []byte(content)}} }
+
+	return map[string]contractCase{
+		"POST /auth/login":    {body: models.LoginRequest{StudentID: DefaultUserStudentID, Password: "Dependable61204"}, token: "-"},
+		"POST /auth/register": {body: models.RegisterRequest{StudentID: "s2503", Name: "登録 四郎", Password: "password123"}, token: "-"},
+		"POST /auth/invite":   {body: models.AcceptInviteRequest{Token: f.inviteToken, Password: "password123"}, token: "-"},
+
+		"GET /books":                    {query: "query=契約"},
+		"GET /books/search":             {query: "query=契約"},
+		"GET /books/suggest":            {query: "q=契約"},
+		"GET /books/fetch-info":         {query: "isbn=9784873117522", status: errBookInfoNotFound.Status},
+		"GET /books/:id":                {id: f.loanedBook.String()},
+		"GET /books/:id/image":          {id: f.loanedBook.String()},
+		"GET /books/borrow-record/:id":  {id: f.loan.String()},
+		"POST /books/borrow":            {body: BorrowRequest{Barcode: "CT-0002", UserID: s.user.ID.String()}},
+		"POST /books/quick-borrow":      {body: QuickBorrowRequest{BookID: f.availableBook.String()}},
+		"POST /books/return":            {body: BorrowRequest{Barcode: "CT-0001", UserID: s.user.ID.String()}},
+		"POST /books/renew":             {body: RenewRequest{BorrowRecordID: f.loan.String()}},
+		"GET /books/history":            {query: "user_id=" + s.user.ID.String()},
+		"POST /books":                   {body: models.Book{Title: "新規", Author: "著者", Type: "book", TotalCopies: 2}},
+		"PUT /books/:id":                {id: f.availableBook.String(), body: UpdateBookRequest{Title: "貸出可能（改訂）", Author: "鈴木 花子", TotalCopies: 2}},
+		"DELETE /books/:id":             {id: f.availableBook.String()},
+		"POST /users":                   {body: models.RegisterRequest{StudentID: "s2504", Name: "作成 五郎", Password: "password123"}},
+		"GET /me":                       {token: s.userToken},
+		"PUT /me":                       {body: UpdateMeRequest{Name: strPtr("一般さん（変更）")}, token: s.userToken},
+		"GET /me/history":               {token: s.userToken},
+		"POST /books/covers":            {},
+		"GET /books/covers":             {},
+		"GET /books/covers/:id":         {id: f.coverJob.String()},
+		"GET /books/covers/:id/results": {id: f.coverJob.String()},
+		"POST /books/covers/:id/resume": {id: f.coverJob.String()},
+		"GET /books/withdrawn":          {},
+		"POST /books/:id/restore":       {id: f.withdrawnBook.String()},
+		"DELETE /books/:id/purge":       {id: f.withdrawnBook.String()},
+		"DELETE /users/:id":             {id: f.member.ID.String()},
+		"GET /users/:id":                {id: s.user.ID.String()},
+		"PUT /users/:id":                {id: f.member.ID.String(), body: UpdateUserRequest{Name: strPtr("契約 次郎（変更）")}},
+		"PUT /users/:id/status":         {id: f.member.ID.String(), body: UserStatusRequest{Status: models.UserStatusGraduated}},
+		"GET /users":                    {},
+		"GET /rankings":                 {},
+		"GET /rankings/all-time":        {},
+
+		"GET /theses":            {query: "academic_year=2024"},
+		"GET /theses/index":      {},
+		"GET /theses/:id":        {id: f.thesis.String()},
+		"GET /theses/:id/pdf":    {id: f.thesis.String()},
+		"PUT /theses/:id":        {id: f.thesis.String(), body: UpdateThesisRequest{Abstract: str("概要")}},
+		"POST /theses/:id/pdf":   {id: f.thesis.String(), files: []formFile{{"pdf", "new.pdf", testPDF}}},
+		"DELETE /theses/:id/pdf": {id: f.thesis.String()},
+
+		"POST /barcode/generate-thesis": {body: ThesisBarcodeRequest{Year: "2024", StudentID: "654321", AuthorName: "卒論 花子", Title: "修士論文", Degree: "master"}},
+		"GET /barcode/saved":            {},
+		"GET /barcode/download/:id":     {id: f.barcode.String()},
+		"DELETE /barcode/:id":           {id: f.barcode.String()},
+		"POST /barcode/migrate":         {body: BarcodeMigrationRequest{DryRun: true}},
+		"GET /labels/templates":         {},
+		// 保存済みバーコードは DELETE /barcode/:id で削除するため、書籍のコピーのラベルを印刷する
+		"POST /labels":            {body: LabelSheetRequest{BookIDs: []uuid.UUID{f.loanedBook}, Template: "a4-65"}},
+		"POST /books/:id/image":   {id: f.availableBook.String(), files: []formFile{{"image", "cover.png", testPNG(s.t)}}},
+		"DELETE /books/:id/image": {id: f.loanedBook.String()},
+
+		"GET /books/:id/attachments":  {id: f.loanedBook.String()},
+		"GET /attachments/:id":        {id: f.attachment.String(), token: "-"},
+		"POST /books/:id/attachments": {id: f.loanedBook.String(), form: map[string]string{"description": "説明"}, files: []formFile{{"file", "more.txt", []byte("追加")}}},
+		"PUT /attachments/:id":        {id: f.attachment.String(), body: UpdateAttachmentRequest{Description: str("更新")}},
+		"DELETE /attachments/:id":     {id: f.attachment.String()},
+		"GET /attachments/stats":      {},
+
+		"POST /books/import":            {form: map[string]string{"dry_run": "true"}, files: csv("title,author\n新規,著者\n")},
+		"GET /books/import":             {},
+		"GET /books/import/:id":         {id: f.importJob.String()},
+		"GET /books/import/:id/rows":    {id: f.importJob.String()},
+		"POST /books/import/:id/resume": {id: f.failedImport.String()},
+
+		"POST /users/import":     {files: csv("student_id,name\ns2505,一括 六郎\n")},
+		"POST /users/deactivate": {body: DeactivateUsersRequest{StudentIDs: []string{f.member.StudentID}}},
+
+		"GET /export/books":    {},
+		"GET /export/loans":    {query: "format=xlsx"},
+		"GET /export/users":    {query: "format=ndjson"},
+		"GET /export/rankings": {},
+	}
+}
+
+// contractURL は rt の /api/v1 の URL を返す
+func contractURL(rt route, id, query string) string {
+	path := "/api/v1" + ginParam.ReplaceAllString(rt.path, id)
+	if rt.admin {
+		path = "/api/v1/admin" + ginParam.ReplaceAllString(rt.path, id)
+	}
+	if query != "" {
+		path += "?" + query
+	}
+	return path
+}
+
+// sendCase は c のリクエストを rt に送る
+func (s *testServer) sendCase(rt route, c contractCase) *httptest.ResponseRecorder {
+	s.t.Helper()
+	token := c.token
+	switch token {
+	case "":
+		token = s.adminToken
+	case "-":
+		token = ""
+	}
+	url := contractURL(rt, c.id, c.query)
+	if c.files != nil || c.form != nil {
+		body, contentType := multipartBody(s.t, c.form, c.files...)
+		req := httptest.NewRequest(rt.method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		return s.send(req, token)
+	}
+	return s.do(rt.method, url, c.body, token)
+}
+
+// checkContract はレスポンスが OpenAPI 文書の rt の定義に一致することを確かめる。
+// JSON 以外のレスポンスはステータスが文書にあることのみ確かめる。
+func checkContract(t *testing.T, rt route, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code == http.StatusInternalServerError {
+		t.Fatalf("internal error: %s", rec.Body.String())
+	}
+	path, _ := openAPIPath(rt)
+	if ct := rec.Header().Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		if OpenAPI().Paths[path][strings.ToLower(rt.method)].Responses[strconv.Itoa(rec.Code)] == nil {
+			t.Fatalf("%s %s: status %d (%s) is not documented", rt.method, path, rec.Code, ct)
+		}
+		return
+	}
+	if err := ValidateResponse(rt.method, path, rec.Code, rec.Body.Bytes()); err != nil {
+		t.Fatalf("%v; body = %s", err, rec.Body.String())
+	}
+	if rec.Code < 400 {
+		return
+	}
+	var res ErrorResponse
+	decode(t, rec, &res)
+	for _, e := range rt.errors {
+		if e.Code == res.Error.Code && e.Status == rec.Code {
+			return
+		}
+	}
+	t.Fatalf("%s %s: error %d %q is not listed in the route's errors", rt.method, path, rec.Code, res.Error.Code)
+}
+
+// 全ルートの /api/v1 のレスポンスが OpenAPI 文書と一致することを確かめる
+func TestContract(t *testing.T) {
+	probe := newTestServer(t)
+	cases := contractCases(probe, contractFixtures{})
+	for _, rt := range routeTable {
+		if _, ok := cases[rt.method+" "+rt.path]; !ok {
+			t.Errorf("no contract case for %s %s", rt.method, rt.path)
+		}
+	}
+	if len(cases) != len(routeTable) {
+		t.Errorf("%d contract cases for %d routes", len(cases), len(routeTable))
+	}
+
+	// ルートごとに別の保存先を持つサーバーを使うため、並行に実行できる
+	for _, rt := range routeTable {
+		rt := rt
+		t.Run(rt.method+" "+rt.path, func(t *testing.T) {
+			t.Parallel()
+			s := newTestServer(t)
+			c, ok := contractCases(s, s.seedContractFixtures())[rt.method+" "+rt.path]
+			if !ok {
+				t.Skip("no case")
+			}
+			want := c.status
+			if want == 0 {
+				want = http.StatusOK
+			}
+			rec := s.sendCase(rt, c)
+			if rec.Code != want {
+				t.Fatalf("status = %d, want %d; body = %s", rec.Code, want, rec.Body.String())
+			}
+			checkContract(t, rt, rec)
+
+			if !strings.Contains(rt.path, ":id") {
+				return
+			}
+			// 不正な ID・存在しない ID のエラーも文書の定義に一致する
+			rec = s.sendCase(rt, contractCase{id: "not-a-uuid", body: c.body, form: c.form, files: c.files, token: c.token})
+			expectError(t, rec, errInvalidID)
+			checkContract(t, rt, rec)
+			rec = s.sendCase(rt, contractCase{id: uuid.New().String(), body: c.body, form: c.form, files: c.files, token: c.token})
+			if rec.Code < 400 {
+				t.Fatalf("unknown id: status %d; body = %s", rec.Code, rec.Body.String())
+			}
+			checkContract(t, rt, rec)
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"lablib/codes"
+	"lablib/metadata"
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// coverFetch は1冊の表紙画像の取得結果。Status が imported 以外の場合は Message に原因のコードを入れる。
+type coverFetch struct {
+	Status    string
+	CoverURL  string
+	ImagePath string // 保存した画像のファイル名（書籍に登録する前は削除できる）
+	Message   string
+}
+
+// fetchCover は書籍の ISBN から外部サービスの表紙画像を取得し、アップロードと同じ検証・変換を行って保存する。
+// 保存した画像は書籍に登録しない（setCoverImage で登録する）。戻り値のエラーは画像の保存に失敗した場合のみ。
+func (h *Handler) fetchCover(ctx context.Context, book *models.Book) (coverFetch, error) {
+	isbn, err := codes.ISBN13(book.ISBN)
+	if err != nil {
+		return coverFetch{Status: models.CoverResultError, Message: "invalid_isbn"}, nil
+	}
+
+	record, err := h.bookInfo.Fetch(ctx, isbn)
+	if errors.Is(err, metadata.ErrNotFound) {
+		return coverFetch{Status: models.CoverResultNotFound, Message: "book_info_not_found"}, nil
+	} else if err != nil {
+		log.Printf("Book info fetch warning: %s: %v", isbn, err)
+		return coverFetch{Status: models.CoverResultError, Message: "upstream_error"}, nil
+	}
+	if record.CoverURL == "" {
+		return coverFetch{Status: models.CoverResultNotFound, Message: "cover_not_found"}, nil
+	}
+
+	f := coverFetch{Status: models.CoverResultError, CoverURL: record.CoverURL}
+	data, err := h.bookInfo.Cover(ctx, record.CoverURL, MaxImageSize)
+	switch {
+	case errors.Is(err, metadata.ErrNotFound):
+		f.Status, f.Message = models.CoverResultNotFound, "cover_not_found"
+		return f, nil
+	case errors.Is(err, metadata.ErrCoverTooLarge):
+		f.Message = errImageTooLarge.Code
+		return f, nil
+	case err != nil:
+		log.Printf("Cover fetch warning: %s: %v", record.CoverURL, err)
+		f.Message = "upstream_error"
+		return f, nil
+	}
+
+	// 手動のアップロードと同じく内容から形式を判定し、EXIF を除いて保存する
+	filename, err := h.saveBookImage(ctx, data)
+	var invalid apiError
+	if errors.As(err, &invalid) {
+		f.Message = invalid.Code
+		return f, nil
+	} else if err != nil {
+		return f, err
+	}
+	f.Status, f.ImagePath = models.CoverResultImported, filename
+	return f, nil
+}
+
+// setCoverImage は取得した表紙画像を書籍に登録する。書籍が削除された・既に画像がある場合は登録せず、f を error にする。
+func setCoverImage(ctx context.Context, tx repository.Store, bookID uuid.UUID, f *coverFetch) error {
+	if f.Status != models.CoverResultImported {
+		return nil
+	}
+	book, err := tx.Books().Get(ctx, bookID)
+	if errors.Is(err, repository.ErrNotFound) {
+		f.Status, f.Message = models.CoverResultError, errBookNotFound.Code
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if book.ImagePath != "" {
+		f.Status, f.Message = models.CoverResultError, "image_exists"
+		return nil
+	}
+	return tx.Books().SetImagePath(ctx, bookID, f.ImagePath)
+}
+
+// discardCover は書籍に登録しなかった表紙画像を削除する
+func (h *Handler) discardCover(ctx context.Context, f *coverFetch) {
+	if f.Status != models.CoverResultImported && f.ImagePath != "" {
+		h.removeBookImage(ctx, f.ImagePath)
+		f.ImagePath = ""
+	}
+}
+
+func newCoverFetchResponse(c *gin.Context, status, coverURL, imagePath, code string) CoverFetchResponse {
+	messages := []ImportMessageResponse{}
+	if code != "" {
+		messages = append(messages, ImportMessageResponse{Code: code, Message: message(c, code)})
+	}
+	return CoverFetchResponse{Status: status, CoverURL: coverURL, ImagePath: imagePath, Messages: messages}
+}
+
+func newCoverResultResponse(c *gin.Context, res models.CoverResult) CoverResultResponse {
+	return CoverResultResponse{
+		CoverFetchResponse: newCoverFetchResponse(c, res.Status, res.CoverURL, res.ImagePath, res.Message),
+		Seq:                res.Seq,
+		BookID:             res.BookID,
+		Title:              res.Title,
+		ISBN:               res.ISBN,
+	}
+}
+
+// coverTargets は表紙画像を取得する書籍（除籍しておらず、画像がなく ISBN のある書籍）を登録順に返す
+func coverTargets(ctx context.Context, books repository.BookRepository) ([]models.Book, error) {
+	noImage := false
+	found, _, err := books.Search(ctx, repository.BookFilter{
+		HasImage: &noImage,
+		Sort:     repository.Sort{Field: "created_at"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var targets []models.Book
+	for _, b := range found {
+		if b.ISBN != "" {
+			targets = append(targets, b.Book)
+		}
+	}
+	return targets, nil
+}
+
+// coverBook は1冊の表紙画像を取得し、書籍への登録・結果の保存・ジョブの件数の更新を同じトランザクションで行う
+func (h *Handler) coverBook(ctx context.Context, job *models.CoverJob, book models.Book) error {
+	f, err := h.fetchCover(ctx, &book)
+	if err != nil {
+		return err
+	}
+
+	next := *job
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		if err := setCoverImage(ctx, tx, book.ID, &f); err != nil {
+			return err
+		}
+		result := models.CoverResult{
+			JobID:     job.ID,
+			Seq:       job.ProcessedBooks + 1,
+			BookID:    &book.ID,
+			Title:     book.Title,
+			ISBN:      book.ISBN,
+			Status:    f.Status,
+			CoverURL:  f.CoverURL,
+			ImagePath: f.ImagePath,
+			Message:   f.Message,
+		}
+		if f.Status != models.CoverResultImported {
+			result.ImagePath = ""
+		}
+		if err := tx.CoverJobs().AddResult(ctx, &result); err != nil {
+			return err
+		}
+
+		switch f.Status {
+		case models.CoverResultImported:
+			next.ImportedBooks++
+		case models.CoverResultNotFound:
+			next.NotFoundBooks++
+		default:
+			next.ErrorBooks++
+		}
+		next.ProcessedBooks++
+		next.UpdatedAt = time.Now()
+		return tx.CoverJobs().Update(ctx, &next)
+	})
+	if err != nil {
+		f.Status = models.CoverResultError
+	}
+	h.discardCover(ctx, &f)
+	if err != nil {
+		return err
+	}
+	*job = next
+	return nil
+}
+
+// startCoverJobs は表紙画像の取得ジョブ ids をバックグラウンドで順に実行する。同時に実行するジョブは1件のみ。
+func (h *Handler) startCoverJobs(ids ...uuid.UUID) {
+	go func() {
+		for _, id := range ids {
+			h.covers.Lock()
+			err := h.runCoverJob(context.Background(), id)
+			h.covers.Unlock()
+			if err != nil {
+				log.Printf("Cover job %s failed: %v", id, err)
+			}
+		}
+	}()
+}
+
+// runCoverJob はジョブの未処理の書籍を処理する。失敗した場合はジョブを failed にし、処理済みの書籍の結果は残す。
+func (h *Handler) runCoverJob(ctx context.Context, id uuid.UUID) error {
+	jobs := h.store.CoverJobs()
+	job, err := jobs.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status == models.ImportStatusCompleted {
+		return nil
+	}
+
+	now := time.Now()
+	job.Status = models.ImportStatusRunning
+	job.Error = ""
+	job.UpdatedAt = now
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	if err := jobs.Update(ctx, job); err != nil {
+		return err
+	}
+
+	if err := h.processCoverJob(ctx, job); err != nil {
+		job.Status = models.ImportStatusFailed
+		job.Error = err.Error()
+		job.UpdatedAt = time.Now()
+		if uerr := jobs.Update(ctx, job); uerr != nil {
+			log.Printf("Cover job update warning: %v", uerr)
+		}
+		return err
+	}
+
+	finished := time.Now()
+	job.Status = models.ImportStatusCompleted
+	job.UpdatedAt = finished
+	job.FinishedAt = &finished
+	if err := jobs.Update(ctx, job); err != nil {
+		return err
+	}
+	log.Printf("Cover job %s completed: %d imported, %d not found, %d errors",
+		job.ID, job.ImportedBooks, job.NotFoundBooks, job.ErrorBooks)
+	return nil
+}
+
+// processCoverJob は対象の書籍のうち、このジョブの結果がまだない書籍を処理する。
+// 対象の書籍はジョブの開始（再開）時点で決め直すため、その間に画像が登録された書籍は処理しない。
+func (h *Handler) processCoverJob(ctx context.Context, job *models.CoverJob) error {
+	results, _, err := h.store.CoverJobs().Results(ctx, repository.CoverResultFilter{JobID: job.ID})
+	if err != nil {
+		return err
+	}
+	done := make(map[uuid.UUID]bool, len(results))
+	for _, res := range results {
+		if res.BookID != nil {
+			done[*res.BookID] = true
+		}
+	}
+	targets, err := coverTargets(ctx, h.store.Books())
+	if err != nil {
+		return err
+	}
+	var pending []models.Book
+	for _, b := range targets {
+		if !done[b.ID] {
+			pending = append(pending, b)
+		}
+	}
+
+	job.TotalBooks = job.ProcessedBooks + len(pending)
+	if err := h.store.CoverJobs().Update(ctx, job); err != nil {
+		return err
+	}
+	for _, b := range pending {
+		if err := h.coverBook(ctx, job, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResumeCoverJobs はサーバーの停止で中断した表紙画像の取得ジョブ（実行待ち・実行中）を古い順に再開する
+func (h *Handler) ResumeCoverJobs(ctx context.Context) (int, error) {
+	jobs, err := h.store.CoverJobs().ListUnfinished(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uuid.UUID, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	if len(ids) > 0 {
+		h.startCoverJobs(ids...)
+	}
+	return len(ids), nil
+}
+
+// FetchCovers - 画像のない書籍の表紙画像を ISBN から外部サービスで取得する（管理者のみ）。
+// ジョブを登録して書籍の処理はバックグラウンドで行う。進捗は GetCoverJob、書籍ごとの結果は GetCoverResults で確認する。
+func (h *Handler) FetchCovers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	targets, err := coverTargets(ctx, h.store.Books())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	now := time.Now()
+	job := models.CoverJob{
+		ID:         uuid.New(),
+		Status:     models.ImportStatusQueued,
+		TotalBooks: len(targets),
+		CreatedBy:  currentViewer(c).userID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := h.store.CoverJobs().Create(ctx, &job); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	h.startCoverJobs(job.ID)
+	respond(c, http.StatusOK, newCoverJobResponse(job))
+}
+
+// GetCoverJobs - 表紙画像の取得ジョブの一覧（新しい順）
+func (h *Handler) GetCoverJobs(c *gin.Context) {
+	page, err := parsePage(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	jobs, total, err := h.store.CoverJobs().List(c.Request.Context(), page)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	items := make([]CoverJobResponse, len(jobs))
+	for i, job := range jobs {
+		items[i] = newCoverJobResponse(job)
+	}
+	respond(c, http.StatusOK, newPage(items, page, total))
+}
+
+// GetCoverJob - 表紙画像の取得ジョブの状態と進捗
+func (h *Handler) GetCoverJob(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	job, err := h.store.CoverJobs().Get(c.Request.Context(), id)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errCoverJobNotFound))
+		return
+	}
+	respond(c, http.StatusOK, newCoverJobResponse(*job))
+}
+
+// GetCoverResults - 表紙画像の取得ジョブの書籍ごとの結果（処理した順）。status で結果の種類を絞り込める。
+func (h *Handler) GetCoverResults(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	filter := repository.CoverResultFilter{JobID: id, Status: c.Query("status")}
+	switch filter.Status {
+	case "", models.CoverResultImported, models.CoverResultNotFound, models.CoverResultError:
+	default:
+		respondError(c, errInvalidFilter)
+		return
+	}
+	var err error
+	if filter.Page, err = parsePage(c); err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	if _, err := h.store.CoverJobs().Get(ctx, id); err != nil {
+		respondErr(c, notFoundAs(err, errCoverJobNotFound))
+		return
+	}
+	results, total, err := h.store.CoverJobs().Results(ctx, filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	items := make([]CoverResultResponse, len(results))
+	for i, res := range results {
+		items[i] = newCoverResultResponse(c, res)
+	}
+	respond(c, http.StatusOK, newPage(items, filter.Page, total))
+}
+
+// ResumeCoverJob - 失敗した表紙画像の取得ジョブを未処理の書籍から再開する
+func (h *Handler) ResumeCoverJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var job *models.CoverJob
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if job, err = tx.CoverJobs().Get(ctx, id); err != nil {
+			return notFoundAs(err, errCoverJobNotFound)
+		}
+		if job.Status != models.ImportStatusFailed {
+			return errCoverJobNotResumable
+		}
+		job.Status = models.ImportStatusQueued
+		job.UpdatedAt = time.Now()
+		return tx.CoverJobs().Update(ctx, job)
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	h.startCoverJobs(job.ID)
+	respond(c, http.StatusOK, newCoverJobResponse(*job))
+}
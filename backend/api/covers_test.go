@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lablib/metadata"
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+// coverProvider は ISBN ごとの表紙画像の URL を返す Provider
+type coverProvider map[string]string
+
+func (p coverProvider) Name() string { return "covers" }
+
+func (p coverProvider) Lookup(ctx context.Context, isbn string) (*metadata.Record, error) {
+	coverURL, ok := p[isbn]
+	if !ok {
+		return nil, metadata.ErrNotFound
+	}
+	return &metadata.Record{Title: "表紙テスト", CoverURL: coverURL}, nil
+}
+
+// withCovers は表紙画像を返すサーバーを立て、covers（ISBN → サーバー上のパス）を返す書誌情報を使うようにする
+func (s *testServer) withCovers(covers map[string]string) {
+	s.t.Helper()
+	png := testPNG(s.t)
+	// 書誌情報の表紙画像の URL は https にそろえられる
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cover.png":
+			w.Write(png)
+		case "/text":
+			w.Write([]byte("表紙ではない"))
+		case "/large":
+			w.Write(make([]byte, MaxImageSize+1))
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	s.t.Cleanup(srv.Close)
+
+	provider := coverProvider{}
+	for isbn, path := range covers {
+		provider[isbn] = ""
+		if path != "" {
+			provider[isbn] = srv.URL + path
+		}
+	}
+	fetcher := metadata.NewFetcher([]metadata.Provider{provider}, nil)
+	fetcher.Client = srv.Client()
+	s.h.SetBookInfoFetcher(fetcher)
+}
+
+// waitCoverJob は表紙画像の取得ジョブが完了または失敗するまで待つ
+func (s *testServer) waitCoverJob(id uuid.UUID) CoverJobResponse {
+	s.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job CoverJobResponse
+		rec := s.do("GET", "/api/v1/admin/books/covers/"+id.String(), nil, s.adminToken)
+		expectStatus(s.t, rec, http.StatusOK)
+		decode(s.t, rec, &job)
+		if job.Status == models.ImportStatusCompleted || job.Status == models.ImportStatusFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("cover job %s is still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFetchCovers(t *testing.T) {
+	s := newTestServer(t)
+	s.withCovers(map[string]string{
+		"9784873117522": "/cover.png",
+		"9784062748681": "/missing.png",
+		"9784101010014": "/text",
+		"9784003101018": "/large",
+		"9784873119014": "/broken",
+		"9784062930017": "",
+	})
+	imported := s.createBook(models.Book{Title: "取得できる", Type: "book", TotalCopies: 1, ISBN: "9784873117522"})
+	for _, isbn := range []string{"9784062748681", "9784101010014", "9784003101018", "9784873119014", "9784062930017", "9784094010015"} {
+		s.createBook(models.Book{Title: "ISBN " + isbn, Type: "book", TotalCopies: 1, ISBN: isbn})
+	}
+	// ISBN のない書籍・画像のある書籍は対象にしない
+	s.createBook(models.Book{Title: "ISBN なし", Type: "book", TotalCopies: 1})
+	withImage := s.createBook(models.Book{Title: "画像あり", Type: "book", TotalCopies: 1, ISBN: "9784873117522"})
+	expectStatus(t, s.postForm("/api/v1/admin/books/"+withImage.String()+"/image", nil,
+		[]formFile{{"image", "cover.png", testPNG(t)}}, s.adminToken), http.StatusOK)
+
+	rec := s.do("POST", "/api/v1/admin/books/covers", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var job CoverJobResponse
+	decode(t, rec, &job)
+	if job.TotalBooks != 7 || job.CreatedBy == nil || *job.CreatedBy != s.admin.ID {
+		t.Fatalf("job = %+v", job)
+	}
+	job = s.waitCoverJob(job.ID)
+	if job.Status != models.ImportStatusCompleted || job.ProcessedBooks != 7 || job.ImportedBooks != 1 ||
+		job.NotFoundBooks != 3 || job.ErrorBooks != 3 || job.FinishedAt == nil {
+		t.Fatalf("completed job = %+v", job)
+	}
+
+	rec = s.do("GET", "/api/v1/admin/books/covers/"+job.ID.String()+"/results", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var results PageResponse[CoverResultResponse]
+	decode(t, rec, &results)
+	want := []struct{ status, code string }{
+		{models.CoverResultImported, ""},
+		{models.CoverResultNotFound, "cover_not_found"},
+		{models.CoverResultError, errUnsupportedImageType.Code},
+		{models.CoverResultError, errImageTooLarge.Code},
+		{models.CoverResultError, "upstream_error"},
+		{models.CoverResultNotFound, "cover_not_found"},
+		{models.CoverResultNotFound, "book_info_not_found"},
+	}
+	if len(results.Items) != len(want) {
+		t.Fatalf("results = %+v", results.Items)
+	}
+	for i, res := range results.Items {
+		code := ""
+		if len(res.Messages) > 0 {
+			code = res.Messages[0].Code
+		}
+		if res.Seq != i+1 || res.Status != want[i].status || code != want[i].code {
+			t.Errorf("result %d = %+v, want %s %q", i, res, want[i].status, want[i].code)
+		}
+	}
+	first := results.Items[0]
+	if *first.BookID != imported || !strings.HasSuffix(first.CoverURL, "/cover.png") || first.ImagePath == "" {
+		t.Fatalf("imported result = %+v", first)
+	}
+	if book := s.bookDetail(imported).Book; book.ImagePath != first.ImagePath {
+		t.Fatalf("book image = %q, want %q", book.ImagePath, first.ImagePath)
+	}
+	expectStatus(t, s.do("GET", "/api/v1/books/"+imported.String()+"/image?size=thumb", nil, ""), http.StatusOK)
+
+	rec = s.do("GET", "/api/v1/admin/books/covers/"+job.ID.String()+"/results?status=error", nil, s.adminToken)
+	decode(t, rec, &results)
+	if len(results.Items) != 3 {
+		t.Fatalf("error results = %+v", results.Items)
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/books/covers/"+job.ID.String()+"/results?status=skipped", nil, s.adminToken), errInvalidFilter)
+
+	// 完了したジョブは再開できない
+	expectError(t, s.do("POST", "/api/v1/admin/books/covers/"+job.ID.String()+"/resume", nil, s.adminToken), errCoverJobNotResumable)
+	expectError(t, s.do("GET", "/api/v1/admin/books/covers/"+uuid.New().String(), nil, s.adminToken), errCoverJobNotFound)
+	expectError(t, s.do("GET", "/api/v1/admin/books/covers/"+uuid.New().String()+"/results", nil, s.adminToken), errCoverJobNotFound)
+}
+
+// 失敗したジョブは結果のある書籍を除いて再開する
+func TestResumeCoverJob(t *testing.T) {
+	s := newTestServer(t)
+	s.withCovers(map[string]string{"9784873117522": "/cover.png", "9784062748681": "/cover.png"})
+	done := s.createBook(models.Book{Title: "処理済み", Type: "book", TotalCopies: 1, ISBN: "9784873117522"})
+	pending := s.createBook(models.Book{Title: "未処理", Type: "book", TotalCopies: 1, ISBN: "9784062748681"})
+
+	ctx := context.Background()
+	now := time.Now()
+	failed := models.CoverJob{ID: uuid.New(), Status: models.ImportStatusFailed, Error: "interrupted",
+		TotalBooks: 2, ProcessedBooks: 1, NotFoundBooks: 1, CreatedAt: now, UpdatedAt: now}
+	if err := s.store.CoverJobs().Create(ctx, &failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.CoverJobs().AddResult(ctx, &models.CoverResult{JobID: failed.ID, Seq: 1, BookID: &done,
+		Title: "処理済み", ISBN: "9784873117522", Status: models.CoverResultNotFound, Message: "cover_not_found"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, s.do("POST", "/api/v1/admin/books/covers/"+failed.ID.String()+"/resume", nil, s.adminToken), http.StatusOK)
+	job := s.waitCoverJob(failed.ID)
+	if job.Status != models.ImportStatusCompleted || job.TotalBooks != 2 || job.ProcessedBooks != 2 || job.ImportedBooks != 1 || job.Error != "" {
+		t.Fatalf("resumed job = %+v", job)
+	}
+	if s.bookDetail(done).Book.ImagePath != "" || s.bookDetail(pending).Book.ImagePath == "" {
+		t.Fatalf("images: done = %q, pending = %q", s.bookDetail(done).Book.ImagePath, s.bookDetail(pending).Book.ImagePath)
+	}
+}
+
+// 登録時の fetch_cover=true は取得できなくても登録を取り消さない
+func TestCreateBookFetchCover(t *testing.T) {
+	s := newTestServer(t)
+	s.withCovers(map[string]string{"9784873117522": "/cover.png", "9784062748681": "/text"})
+
+	for _, tc := range []struct {
+		isbn, status, code string
+	}{
+		{"9784873117522", models.CoverResultImported, ""},
+		{"9784062748681", models.CoverResultError, errUnsupportedImageType.Code},
+		{"9784101010014", models.CoverResultNotFound, "book_info_not_found"},
+	} {
+		rec := s.do("POST", "/api/v1/admin/books?fetch_cover=true", models.Book{Title: "登録時", Type: "book", TotalCopies: 1, ISBN: tc.isbn}, s.adminToken)
+		expectStatus(t, rec, http.StatusOK)
+		var res SavedBookResponse
+		decode(t, rec, &res)
+		code := ""
+		if res.Cover != nil && len(res.Cover.Messages) > 0 {
+			code = res.Cover.Messages[0].Code
+		}
+		if res.Cover == nil || res.Cover.Status != tc.status || code != tc.code {
+			t.Fatalf("%s: cover = %+v", tc.isbn, res.Cover)
+		}
+		if got := s.bookDetail(res.ID).Book.ImagePath; got != res.ImagePath || (tc.status == models.CoverResultImported) != (got != "") {
+			t.Fatalf("%s: image path = %q, response %q", tc.isbn, got, res.ImagePath)
+		}
+	}
+
+	// 指定しない場合は取得しない
+	rec := s.do("POST", "/api/v1/admin/books", models.Book{Title: "取得しない", Type: "book", TotalCopies: 1, ISBN: "9784873117522"}, s.adminToken)
+	var res SavedBookResponse
+	decode(t, rec, &res)
+	if res.Cover != nil || res.ImagePath != "" {
+		t.Fatalf("cover without fetch_cover = %+v", res.Cover)
+	}
+	expectError(t, s.do("POST", "/api/v1/admin/books?fetch_cover=maybe", models.Book{Title: "不正", Type: "book", TotalCopies: 1}, s.adminToken), errInvalidFilter)
+}
@@ -0,0 +1,995 @@
+package api
+
+import (
+	"time"
+
+	"lablib/labels"
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrorResponse - /api/v1 のエラーレスポンス
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody - エラーの内容。Code は機械判読用、Message は Accept-Language に合わせた表示用文言。
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// MessageResponse - 処理結果のメッセージのみを返すレスポンス
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// ListResponse - 一覧系エンドポイントの共通形式
+type ListResponse[T any] struct {
+	Items []T `json:"items"`
+}
+
+func newList[T any](items []T) ListResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return ListResponse[T]{Items: items}
+}
+
+// legacy は従来の /api と同じく配列のみを返す
+func (r ListResponse[T]) legacy() interface{} {
+	items := make([]interface{}, len(r.Items))
+	for i, item := range r.Items {
+		if l, ok := interface{}(item).(legacyShaper); ok {
+			items[i] = l.legacy()
+		} else {
+			items[i] = item
+		}
+	}
+	return items
+}
+
+// Pagination - 一覧のページング情報
+type Pagination struct {
+	Total   int  `json:"total"`    // 条件に一致した総件数
+	Limit   int  `json:"limit"`    // 0 の場合は件数の上限なし
+	Offset  int  `json:"offset"`   // 先頭から読み飛ばした件数
+	HasMore bool `json:"has_more"` // 続きのページがあるか
+}
+
+// PageResponse - ページング付き一覧の共通形式
+type PageResponse[T any] struct {
+	Items      []T        `json:"items"`
+	Pagination Pagination `json:"pagination"`
+}
+
+func newPage[T any](items []T, page repository.Page, total int) PageResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return PageResponse[T]{Items: items, Pagination: newPagination(page, len(items), total)}
+}
+
+// legacy は従来の /api と同じく配列のみを返す
+func (r PageResponse[T]) legacy() interface{} {
+	return ListResponse[T]{Items: r.Items}.legacy()
+}
+
+// BorrowRequest - バーコード指定の貸出・返却リクエスト
+type BorrowRequest struct {
+	Barcode string `json:"barcode"`
+	UserID  string `json:"user_id"`
+}
+
+// QuickBorrowRequest - 書籍ID指定の貸出リクエスト
+type QuickBorrowRequest struct {
+	BookID string `json:"book_id"`
+}
+
+// RenewRequest - 貸出期限の延長リクエスト
+type RenewRequest struct {
+	BorrowRecordID string `json:"borrow_record_id"`
+}
+
+// UpdateBookRequest - 書籍情報の更新リクエスト。
+// 書誌情報の項目（authors 以降）は省略すると現在の値のまま、空の値を指定すると未設定にする。
+type UpdateBookRequest struct {
+	Title       string  `json:"title"`
+	Author      string  `json:"author"`
+	ISBN        string  `json:"isbn"`
+	JAN         *string `json:"jan"`   // 省略すると現在の値のまま
+	EAN13       *string `json:"ean13"` // 省略すると現在の値のまま
+	Location    string  `json:"location"`
+	TotalCopies int     `json:"total_copies"`
+
+	Authors       []models.BookAuthor `json:"authors"`        // 指定した場合は author より優先する
+	PublishedYear *int                `json:"published_year"` // 0で未設定。published_date を指定した場合はその年
+	Publisher     *string             `json:"publisher"`
+	PublishedDate *string             `json:"published_date"`
+	Edition       *string             `json:"edition"`
+	PageCount     *int                `json:"page_count"`
+	Language      *string             `json:"language"`
+	Description   *string             `json:"description"`
+	Subjects      []string            `json:"subjects"`
+	Notes         *string             `json:"notes"`
+}
+
+// ThesisBarcodeRequest - 卒論バーコードの生成リクエスト
+type ThesisBarcodeRequest struct {
+	Year       string `json:"year" binding:"required"`        // 年度
+	StudentID  string `json:"student_id" binding:"required"`  // 学籍番号
+	AuthorName string `json:"author_name" binding:"required"` // 作者名
+	Title      string `json:"title" binding:"required"`       // 論文タイトル
+
+	// 以下は省略可能。BookID を指定すると新規登録せず既存の論文（type = "thesis" の書籍）に関連付ける。
+	BookID      *uuid.UUID `json:"book_id"`
+	Degree      string     `json:"degree"` // 既定は bachelor
+	Supervisors []string   `json:"supervisors"`
+	Abstract    string     `json:"abstract"`
+	Keywords    []string   `json:"keywords"`
+	Location    string     `json:"location"`
+}
+
+// UpdateThesisRequest - 論文情報の更新リクエスト。省略した項目（nil）は現在の値のまま。
+// タイトルや著者名は書籍の更新（PUT /admin/books/:id）で変更する。
+type UpdateThesisRequest struct {
+	AcademicYear *int     `json:"academic_year"`
+	Degree       *string  `json:"degree"`
+	StudentID    *string  `json:"student_id"`
+	Supervisors  []string `json:"supervisors"`
+	Abstract     *string  `json:"abstract"`
+	Keywords     []string `json:"keywords"`
+}
+
+// BookResponse - 書籍情報
+type BookResponse struct {
+	ID              uuid.UUID            `json:"id"`
+	Title           string               `json:"title"`
+	Author          string               `json:"author"` // 表示用に連結した著者名
+	Authors         []BookAuthorResponse `json:"authors"`
+	ISBN            string               `json:"isbn"`
+	JAN             string               `json:"jan"`
+	EAN13           string               `json:"ean13"`
+	Type            string               `json:"type"`
+	TotalCopies     int                  `json:"total_copies"`
+	AvailableCopies int                  `json:"available_copies"`
+	Available       bool                 `json:"available"`
+	Barcode         string               `json:"barcode"`
+	Location        string               `json:"location"`
+	Publisher       string               `json:"publisher"`
+	PublishedDate   string               `json:"published_date"`
+	PublishedYear   *int                 `json:"published_year"`
+	Edition         string               `json:"edition"`
+	PageCount       int                  `json:"page_count"`
+	Language        string               `json:"language"`
+	Description     string               `json:"description"`
+	Subjects        []string             `json:"subjects"`
+	Notes           string               `json:"notes"`
+	ImagePath       string               `json:"image_path"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+	// WithdrawnAt は除籍した日時（除籍していない場合は null）
+	WithdrawnAt      *time.Time `json:"withdrawn_at"`
+	WithdrawalReason string     `json:"withdrawal_reason"`
+}
+
+// DuplicateBookResponse - ISBN・JAN・EAN13 のいずれかが同じ既存の書籍
+type DuplicateBookResponse struct {
+	BookID uuid.UUID `json:"book_id"`
+	Title  string    `json:"title"`
+	Code   string    `json:"code"` // 一致した登録・更新後のコード
+}
+
+// SavedBookResponse - 書籍の登録結果。duplicates が空でない場合も登録は行われている（重複の警告）。
+type SavedBookResponse struct {
+	BookResponse
+	Duplicates []DuplicateBookResponse `json:"duplicates"`
+	Cover      *CoverFetchResponse     `json:"cover,omitempty"` // fetch_cover=true の場合の表紙画像の取得結果
+}
+
+// BookUpdatedResponse - 書籍情報の更新結果。duplicates が空でない場合も更新は行われている（重複の警告）。
+type BookUpdatedResponse struct {
+	MessageResponse
+	Duplicates []DuplicateBookResponse `json:"duplicates"`
+}
+
+// BookAuthorResponse - 著者1人（authors の順序が表示順）
+type BookAuthorResponse struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+func newBookResponse(b models.Book, availableCopies int) BookResponse {
+	authors := make([]BookAuthorResponse, 0, len(b.Authors))
+	for _, a := range b.Authors {
+		authors = append(authors, BookAuthorResponse{Name: a.Name, Role: a.Role})
+	}
+	subjects := b.Subjects
+	if subjects == nil {
+		subjects = []string{}
+	}
+	return BookResponse{
+		ID:              b.ID,
+		Title:           b.Title,
+		Author:          b.Author,
+		Authors:         authors,
+		ISBN:            b.ISBN,
+		JAN:             b.JAN,
+		EAN13:           b.EAN13,
+		Type:            b.Type,
+		TotalCopies:     b.TotalCopies,
+		AvailableCopies: availableCopies,
+		Available:       availableCopies > 0,
+		Barcode:         b.Barcode,
+		Location:        b.Location,
+		Publisher:       b.Publisher,
+		PublishedDate:   b.PublishedDate,
+		PublishedYear:   b.PublishedYear,
+		Edition:         b.Edition,
+		PageCount:       b.PageCount,
+		Language:        b.Language,
+		Description:     b.Description,
+		Subjects:        subjects,
+		Notes:           b.Notes,
+		ImagePath:       b.ImagePath,
+		CreatedAt:       b.CreatedAt,
+		UpdatedAt:       b.UpdatedAt,
+
+		WithdrawnAt:      b.WithdrawnAt,
+		WithdrawalReason: b.WithdrawalReason,
+	}
+}
+
+// FacetCountResponse - ファセットの値1つと該当する書籍数
+type FacetCountResponse struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+func newFacetCountResponses(fcs []repository.FacetCount) []FacetCountResponse {
+	res := make([]FacetCountResponse, 0, len(fcs))
+	for _, fc := range fcs {
+		res = append(res, FacetCountResponse{Value: fc.Value, Count: fc.Count})
+	}
+	return res
+}
+
+// BookFacetsResponse - 検索結果の項目ごとの件数。各項目はその項目自身の絞り込みを外して集計する。
+type BookFacetsResponse struct {
+	Type         []FacetCountResponse `json:"type"`
+	Location     []FacetCountResponse `json:"location"`
+	Author       []FacetCountResponse `json:"author"`
+	Year         []FacetCountResponse `json:"year"`
+	Availability []FacetCountResponse `json:"availability"` // "available"・"unavailable"
+}
+
+func newBookFacetsResponse(f *repository.BookFacets) BookFacetsResponse {
+	return BookFacetsResponse{
+		Type:         newFacetCountResponses(f.Type),
+		Location:     newFacetCountResponses(f.Location),
+		Author:       newFacetCountResponses(f.Author),
+		Year:         newFacetCountResponses(f.Year),
+		Availability: newFacetCountResponses(f.Availability),
+	}
+}
+
+// ThesisResponse - 論文情報。要旨とキーワードは書籍の description・subjects と同じ値。
+type ThesisResponse struct {
+	BookID       uuid.UUID            `json:"book_id"`
+	Title        string               `json:"title"`
+	Author       string               `json:"author"`
+	Authors      []BookAuthorResponse `json:"authors"`
+	AcademicYear int                  `json:"academic_year"`
+	Degree       string               `json:"degree"`
+	StudentID    string               `json:"student_id"`
+	Supervisors  []string             `json:"supervisors"`
+	Abstract     string               `json:"abstract"`
+	Keywords     []string             `json:"keywords"`
+	Barcode      string               `json:"barcode"`
+	Location     string               `json:"location"`
+	HasPDF       bool                 `json:"has_pdf"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+func newThesisResponse(t models.Thesis) ThesisResponse {
+	book := newBookResponse(t.Book, 0)
+	supervisors := t.Supervisors()
+	if supervisors == nil {
+		supervisors = []string{}
+	}
+	return ThesisResponse{
+		BookID:       t.BookID,
+		Title:        book.Title,
+		Author:       book.Author,
+		Authors:      book.Authors,
+		AcademicYear: t.AcademicYear,
+		Degree:       t.Degree,
+		StudentID:    t.StudentID,
+		Supervisors:  supervisors,
+		Abstract:     book.Description,
+		Keywords:     book.Subjects,
+		Barcode:      book.Barcode,
+		Location:     book.Location,
+		HasPDF:       t.PDFPath != "",
+		CreatedAt:    t.CreatedAt,
+		UpdatedAt:    t.UpdatedAt,
+	}
+}
+
+// ThesisIndexResponse - 論文を年度・指導教員ごとに数えた一覧（閲覧用の索引）
+type ThesisIndexResponse struct {
+	Years       []FacetCountResponse `json:"years"`
+	Supervisors []FacetCountResponse `json:"supervisors"`
+}
+
+// legacy は新設のエンドポイントのため /api でも同じ形式を返す
+func (r ThesisIndexResponse) legacy() interface{} { return r }
+
+// PDFUploadResponse - 論文PDFのアップロード結果
+type PDFUploadResponse struct {
+	Message string `json:"message"`
+	PDFPath string `json:"pdf_path"`
+}
+
+// AttachmentResponse - 書籍の添付ファイル
+type AttachmentResponse struct {
+	ID          uuid.UUID `json:"id"`
+	BookID      uuid.UUID `json:"book_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Visibility  string    `json:"visibility"`
+	Description string    `json:"description"`
+	ScanStatus  string    `json:"scan_status"`
+	Downloads   int       `json:"downloads"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func newAttachmentResponse(a models.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          a.ID,
+		BookID:      a.BookID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		SHA256:      a.SHA256,
+		Visibility:  a.Visibility,
+		Description: a.Description,
+		ScanStatus:  a.ScanStatus,
+		Downloads:   a.Downloads,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+}
+
+// UpdateAttachmentRequest - 添付ファイルの更新リクエスト。省略した項目（nil）は現在の値のまま。
+type UpdateAttachmentRequest struct {
+	Filename    *string `json:"filename"`
+	Visibility  *string `json:"visibility"`
+	Description *string `json:"description"`
+}
+
+// AttachmentStatsResponse - 添付ファイルごとのダウンロード数
+type AttachmentStatsResponse struct {
+	Attachment     AttachmentResponse `json:"attachment"`
+	Downloads      int                `json:"downloads"`
+	UniqueUsers    int                `json:"unique_users"`
+	LastDownloaded *time.Time         `json:"last_downloaded"`
+}
+
+// ImportJobResponse - 書籍の一括取り込みジョブ
+type ImportJobResponse struct {
+	ID            uuid.UUID         `json:"id"`
+	Filename      string            `json:"filename"`
+	Format        string            `json:"format"`
+	Sheet         string            `json:"sheet"`
+	Mapping       map[string]string `json:"mapping"` // 項目名 → 使用した列の見出し（空の場合は取り込まない項目）
+	DryRun        bool              `json:"dry_run"`
+	Enrich        bool              `json:"enrich"`
+	Status        string            `json:"status"` // queued・running・completed・failed
+	TotalRows     int               `json:"total_rows"`
+	ProcessedRows int               `json:"processed_rows"`
+	ImportedRows  int               `json:"imported_rows"` // ドライランでは登録できる行数
+	DuplicateRows int               `json:"duplicate_rows"`
+	ErrorRows     int               `json:"error_rows"`
+	Error         string            `json:"error"`
+	CreatedBy     *uuid.UUID        `json:"created_by"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	StartedAt     *time.Time        `json:"started_at"`
+	FinishedAt    *time.Time        `json:"finished_at"`
+}
+
+func newImportJobResponse(job models.ImportJob) ImportJobResponse {
+	mapping := job.Mapping
+	if mapping == nil {
+		mapping = map[string]string{}
+	}
+	return ImportJobResponse{
+		ID:            job.ID,
+		Filename:      job.Filename,
+		Format:        job.Format,
+		Sheet:         job.Sheet,
+		Mapping:       mapping,
+		DryRun:        job.DryRun,
+		Enrich:        job.Enrich,
+		Status:        job.Status,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		ImportedRows:  job.ImportedRows,
+		DuplicateRows: job.DuplicateRows,
+		ErrorRows:     job.ErrorRows,
+		Error:         job.Error,
+		CreatedBy:     job.CreatedBy,
+		CreatedAt:     job.CreatedAt,
+		UpdatedAt:     job.UpdatedAt,
+		StartedAt:     job.StartedAt,
+		FinishedAt:    job.FinishedAt,
+	}
+}
+
+// ImportMessageResponse - 取り込みの行のエラー・警告
+type ImportMessageResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImportRowResponse - 取り込みの1行の結果
+type ImportRowResponse struct {
+	RowNumber   int                     `json:"row_number"`
+	Status      string                  `json:"status"` // imported・duplicate・error
+	Title       string                  `json:"title"`
+	ISBN        string                  `json:"isbn"`
+	BookID      *uuid.UUID              `json:"book_id"`
+	DuplicateOf *uuid.UUID              `json:"duplicate_of"`
+	Messages    []ImportMessageResponse `json:"messages"`
+}
+
+// CoverJobResponse - 表紙画像の取得ジョブの状態と進捗
+type CoverJobResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Status         string     `json:"status"`      // queued・running・completed・failed
+	TotalBooks     int        `json:"total_books"` // 画像がなく ISBN のある書籍の数
+	ProcessedBooks int        `json:"processed_books"`
+	ImportedBooks  int        `json:"imported_books"`
+	NotFoundBooks  int        `json:"not_found_books"`
+	ErrorBooks     int        `json:"error_books"`
+	Error          string     `json:"error"`
+	CreatedBy      *uuid.UUID `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	StartedAt      *time.Time `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+}
+
+func newCoverJobResponse(job models.CoverJob) CoverJobResponse {
+	return CoverJobResponse{
+		ID:             job.ID,
+		Status:         job.Status,
+		TotalBooks:     job.TotalBooks,
+		ProcessedBooks: job.ProcessedBooks,
+		ImportedBooks:  job.ImportedBooks,
+		NotFoundBooks:  job.NotFoundBooks,
+		ErrorBooks:     job.ErrorBooks,
+		Error:          job.Error,
+		CreatedBy:      job.CreatedBy,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+		StartedAt:      job.StartedAt,
+		FinishedAt:     job.FinishedAt,
+	}
+}
+
+// CoverFetchResponse - 1冊の表紙画像の取得結果
+type CoverFetchResponse struct {
+	Status    string                  `json:"status"`     // imported・not_found・error
+	CoverURL  string                  `json:"cover_url"`  // 取得元の URL
+	ImagePath string                  `json:"image_path"` // 保存した画像のファイル名
+	Messages  []ImportMessageResponse `json:"messages"`   // not_found・error の原因
+}
+
+// CoverResultResponse - 表紙画像の取得ジョブの1冊の結果
+type CoverResultResponse struct {
+	CoverFetchResponse
+	Seq    int        `json:"seq"`
+	BookID *uuid.UUID `json:"book_id"`
+	Title  string     `json:"title"`
+	ISBN   string     `json:"isbn"`
+}
+
+// BarcodeMigrationRequest - 記録の導入前に保存されたバーコードの移行
+type BarcodeMigrationRequest struct {
+	DryRun bool `json:"dry_run"` // 対象を返すだけで変更しない
+}
+
+// BarcodeMigrationResponse - 記録の導入前に保存されたバーコードの移行の結果（dry_run の場合は移行する対象）
+type BarcodeMigrationResponse struct {
+	DryRun       bool                       `json:"dry_run"`
+	FilledParams int                        `json:"filled_params"` // 年度・学籍番号を補った記録の数
+	Duplicates   []DuplicateBarcodeResponse `json:"duplicates"`    // 記録済みのコードと重複する画像
+}
+
+// DuplicateBarcodeResponse - 残すバーコードとコードが重複する記録・画像
+type DuplicateBarcodeResponse struct {
+	Filename  string     `json:"filename"` // 保存先の barcodes/ 以下のファイル名
+	Code      string     `json:"code"`
+	BarcodeID uuid.UUID  `json:"barcode_id"` // 残すバーコード（同じコードで最初に生成したもの）
+	RecordID  *uuid.UUID `json:"record_id"`  // 削除する重複の記録（記録のない画像の場合は null）
+	Kept      bool       `json:"kept"`       // 残すバーコードの画像がないため、画像は削除しない
+}
+
+// UserImportResponse - ユーザーの一括登録の結果。初期パスワード・招待のトークンはこのレスポンスでのみ返す。
+type UserImportResponse struct {
+	DryRun  bool                    `json:"dry_run"`
+	Created int                     `json:"created"`
+	Updated int                     `json:"updated"`
+	Skipped int                     `json:"skipped"`
+	Errors  int                     `json:"errors"`
+	Rows    []UserImportRowResponse `json:"rows"`
+}
+
+// UserImportRowResponse - ユーザーの一括登録の1行の結果
+type UserImportRowResponse struct {
+	RowNumber       int                     `json:"row_number"`
+	Status          string                  `json:"status"` // created・updated・skipped・error
+	StudentID       string                  `json:"student_id"`
+	Name            string                  `json:"name"`
+	Email           string                  `json:"email"`
+	Role            string                  `json:"role"`
+	UserID          *uuid.UUID              `json:"user_id"`
+	Password        string                  `json:"password,omitempty"`     // credentials=password で作成したユーザーの初期パスワード
+	InviteToken     string                  `json:"invite_token,omitempty"` // credentials=invite で作成したユーザーの招待のトークン
+	InviteExpiresAt *time.Time              `json:"invite_expires_at,omitempty"`
+	Messages        []ImportMessageResponse `json:"messages"`
+}
+
+// DeactivateUsersRequest - ユーザーの一括利用停止
+type DeactivateUsersRequest struct {
+	StudentIDs []string `json:"student_ids"`
+	Status     string   `json:"status"` // deactivated または graduated（既定）
+	Force      bool     `json:"force"`  // 返却されていない貸出があるユーザーも利用停止にする
+}
+
+// UserLoanSummaryResponse - ユーザーの貸出の概要
+type UserLoanSummaryResponse struct {
+	OpenCount      int            `json:"open_count"`       // 返却されていない貸出の数
+	OverdueCount   int            `json:"overdue_count"`    // そのうち返却期限を過ぎたものの数
+	TotalCount     int            `json:"total_count"`      // 返却済みを含む貸出の総数
+	LastBorrowedAt *time.Time     `json:"last_borrowed_at"` // 最後に借りた日時（貸出がない場合は null）
+	OpenLoans      []LoanResponse `json:"open_loans"`       // 返却されていない貸出（返却期限の早い順）
+}
+
+// UserDetailResponse - 管理者向けのユーザーの詳細
+type UserDetailResponse struct {
+	models.UserResponse
+	Loans UserLoanSummaryResponse `json:"loans"`
+}
+
+// UpdateUserRequest - 管理者によるユーザー情報の変更。省略した項目は変更しない。
+type UpdateUserRequest struct {
+	StudentID *string `json:"student_id"`
+	Name      *string `json:"name"`
+	Email     *string `json:"email"`  // 空文字列で未設定に戻す
+	Role      *string `json:"role"`   // user・admin・guest のいずれか（models.UserRoles）
+	Status    *string `json:"status"` // active・deactivated・graduated
+	Force     bool    `json:"force"`  // 返却されていない貸出があっても利用停止にする
+}
+
+// UserStatusRequest - ユーザーの状態の変更
+type UserStatusRequest struct {
+	Status string `json:"status"` // active・deactivated・graduated
+	Force  bool   `json:"force"`  // 返却されていない貸出があっても利用停止にする
+}
+
+// DeactivateUsersResponse - 一括利用停止の結果
+type DeactivateUsersResponse struct {
+	Deactivated []models.UserResponse    `json:"deactivated"`
+	Skipped     []DeactivateSkipResponse `json:"skipped"`
+}
+
+// DeactivateSkipResponse - 利用停止にしなかったユーザーと理由
+type DeactivateSkipResponse struct {
+	StudentID string `json:"student_id"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// BookSearchResponse - ファセット付きの書籍検索結果
+type BookSearchResponse struct {
+	PageResponse[BookResponse]
+	Facets BookFacetsResponse `json:"facets"`
+}
+
+// legacy は新設のエンドポイントのため /api でも同じ形式を返す（埋め込みの PageResponse の変換を使わない）
+func (r BookSearchResponse) legacy() interface{} { return r }
+
+// SuggestionResponse - 入力補完の候補1件
+type SuggestionResponse struct {
+	Kind   string     `json:"kind"` // "title" または "author"
+	Text   string     `json:"text"`
+	BookID *uuid.UUID `json:"book_id,omitempty"` // kind が "title" の場合の書籍ID
+	Count  int        `json:"count"`             // 候補に該当する書籍数
+}
+
+// SuggestResponse - 入力補完の候補一覧
+type SuggestResponse struct {
+	Query       string               `json:"query"`
+	Suggestions []SuggestionResponse `json:"suggestions"`
+}
+
+// LoanResponse - 貸出記録
+type LoanResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	UserName     string     `json:"user_name"`
+	BookID       uuid.UUID  `json:"book_id"`
+	BookTitle    string     `json:"book_title"`
+	BookAuthor   string     `json:"book_author"`
+	BookType     string     `json:"book_type"`
+	BookCopyID   uuid.UUID  `json:"book_copy_id"`
+	SerialNumber string     `json:"serial_number"`
+	BorrowedAt   time.Time  `json:"borrowed_at"`
+	DueDate      time.Time  `json:"due_date"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+	Status       string     `json:"status"`
+	RenewCount   int        `json:"renew_count"`
+}
+
+func newLoanResponse(br models.BorrowRecord) LoanResponse {
+	return LoanResponse{
+		ID:           br.ID,
+		UserID:       br.UserID,
+		UserName:     br.User.Name,
+		BookID:       br.Book.ID,
+		BookTitle:    br.Book.Title,
+		BookAuthor:   br.Book.Author,
+		BookType:     br.Book.Type,
+		BookCopyID:   br.BookCopyID,
+		SerialNumber: br.BookCopy.SerialNumber,
+		BorrowedAt:   br.BorrowedAt,
+		DueDate:      br.DueDate,
+		ReturnedAt:   br.ReturnedAt,
+		Status:       br.Status,
+		RenewCount:   br.RenewCount,
+	}
+}
+
+func newLoanResponses(records []models.BorrowRecord) []LoanResponse {
+	loans := make([]LoanResponse, 0, len(records))
+	for _, br := range records {
+		loans = append(loans, newLoanResponse(br))
+	}
+	return loans
+}
+
+// legacyHistoryItem は従来の貸出履歴（camelCase）の1件
+func (l LoanResponse) legacyHistoryItem() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         l.ID,
+		"userId":     l.UserID,
+		"itemId":     l.BookCopyID,
+		"itemTitle":  l.BookTitle,
+		"userName":   l.UserName,
+		"borrowedAt": l.BorrowedAt,
+		"dueDate":    l.DueDate,
+		"returnedAt": l.ReturnedAt,
+		"status":     l.Status,
+	}
+}
+
+// MeResponse - ログイン中の利用者のアカウント情報
+type MeResponse struct {
+	User         models.UserResponse `json:"user"`
+	Loans        []LoanResponse      `json:"loans"`         // 返却されていない貸出（返却期限の早い順）
+	OverdueCount int                 `json:"overdue_count"` // loans のうち返却期限を過ぎたものの数
+	History      []LoanResponse      `json:"history"`       // 返却済みの貸出（返却日の新しい順に最大10件）
+	HistoryTotal int                 `json:"history_total"` // 返却済みの貸出の総数
+}
+
+// UpdateMeRequest - 利用者自身が変更できる項目。省略した項目は変更しない。
+type UpdateMeRequest struct {
+	Name          *string                         `json:"name"`
+	Email         *string                         `json:"email"` // 空文字列で未設定に戻す
+	Notifications *models.NotificationPreferences `json:"notifications"`
+}
+
+// BorrowHistoryResponse - 貸出履歴一覧
+type BorrowHistoryResponse struct {
+	PageResponse[LoanResponse]
+}
+
+func (r BorrowHistoryResponse) legacy() interface{} {
+	history := make([]map[string]interface{}, 0, len(r.Items))
+	for _, l := range r.Items {
+		history = append(history, l.legacyHistoryItem())
+	}
+	return history
+}
+
+// BookDetailResponse - 書籍詳細。CurrentLoan は貸出中のコピーがある場合の最新の貸出。
+type BookDetailResponse struct {
+	Book          BookResponse   `json:"book"`
+	CurrentLoan   *LoanResponse  `json:"current_loan,omitempty"`
+	BorrowHistory []LoanResponse `json:"borrow_history"`
+}
+
+func (r BookDetailResponse) legacy() interface{} {
+	b := r.Book
+	book := map[string]interface{}{
+		"id":           b.ID,
+		"title":        b.Title,
+		"author":       b.Author,
+		"isbn":         b.ISBN,
+		"jan":          b.JAN,
+		"ean13":        b.EAN13,
+		"type":         b.Type,
+		"total_copies": b.TotalCopies,
+		"barcode":      b.Barcode,
+		"location":     b.Location,
+		"image_path":   b.ImagePath,
+		"created_at":   b.CreatedAt,
+		"updated_at":   b.UpdatedAt,
+		"available":    b.Available,
+		// 書誌情報の項目は従来の形式への追加のみ
+		"authors":        b.Authors,
+		"publisher":      b.Publisher,
+		"published_date": b.PublishedDate,
+		"published_year": b.PublishedYear,
+		"edition":        b.Edition,
+		"page_count":     b.PageCount,
+		"language":       b.Language,
+		"description":    b.Description,
+		"subjects":       b.Subjects,
+		"notes":          b.Notes,
+	}
+	if r.CurrentLoan != nil {
+		book["borrowedBy"] = r.CurrentLoan.UserID
+		book["borrowedAt"] = r.CurrentLoan.BorrowedAt
+		book["dueDate"] = r.CurrentLoan.DueDate
+	}
+
+	history := make([]map[string]interface{}, 0, len(r.BorrowHistory))
+	for _, l := range r.BorrowHistory {
+		item := l.legacyHistoryItem()
+		delete(item, "itemId")
+		delete(item, "itemTitle")
+		history = append(history, item)
+	}
+	return map[string]interface{}{
+		"book":           book,
+		"borrow_history": history,
+	}
+}
+
+// CheckoutResponse - 貸出結果
+type CheckoutResponse struct {
+	Message        string    `json:"message"`
+	BorrowRecordID uuid.UUID `json:"borrow_record_id"`
+	DueDate        time.Time `json:"due_date"`
+}
+
+// ReturnResponse - 返却結果
+type ReturnResponse struct {
+	Message        string    `json:"message"`
+	BorrowRecordID uuid.UUID `json:"borrow_record_id"`
+	ReturnedAt     time.Time `json:"returned_at"`
+}
+
+// RenewResponse - 延長結果
+type RenewResponse struct {
+	Message        string    `json:"message"`
+	BorrowRecordID uuid.UUID `json:"borrow_record_id"`
+	DueDate        time.Time `json:"due_date"`
+	RenewCount     int       `json:"renew_count"`
+}
+
+// BookInfoResponse - 外部サービスから取得した書誌情報
+type BookInfoResponse struct {
+	ISBN          string   `json:"isbn"` // ISBN-13 に揃えたもの
+	Title         string   `json:"title"`
+	Author        string   `json:"author"` // authors を ", " で連結したもの
+	Authors       []string `json:"authors"`
+	Publisher     string   `json:"publisher"`
+	PublishedDate string   `json:"published_date"`
+	PageCount     int      `json:"page_count"`
+	Language      string   `json:"language"`
+	Description   string   `json:"description"`
+	Subjects      []string `json:"subjects"`
+	CoverURL      string   `json:"cover_url"` // 表紙画像の URL（ない場合は空）。登録時に fetch_cover=true で取り込める
+	Sources       []string `json:"sources"`   // 値を取得したサービス（"openbd"・"ndl"・"google"・"cinii"）
+}
+
+// MonthlyRankingResponse - 月間ランキングの1件
+type MonthlyRankingResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Month       string    `json:"month"`
+	BookID      uuid.UUID `json:"book_id"`
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Type        string    `json:"type"`
+	BorrowCount int       `json:"borrow_count"`
+}
+
+// RankingResponse - 全期間ランキングの1件
+type RankingResponse struct {
+	BookID      uuid.UUID `json:"book_id"`
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Type        string    `json:"type"`
+	BorrowCount int       `json:"borrow_count"`
+}
+
+func newUserResponse(u models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:            u.ID,
+		StudentID:     u.StudentID,
+		Name:          u.Name,
+		Email:         u.Email,
+		Role:          u.Role,
+		Status:        u.Status,
+		DeactivatedAt: u.DeactivatedAt,
+		AnonymizedAt:  u.AnonymizedAt,
+		AnonymizedBy:  u.AnonymizedBy,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Notifications: u.Notifications,
+	}
+}
+
+// ThesisBarcodeResponse - 卒論バーコードの生成結果
+type ThesisBarcodeResponse struct {
+	ID         uuid.UUID  `json:"id"` // ダウンロード・削除に使う ID
+	Barcode    string     `json:"barcode"`
+	Year       string     `json:"year"`
+	StudentID  string     `json:"student_id"`
+	AuthorName string     `json:"author_name"`
+	Title      string     `json:"title"`
+	Filename   string     `json:"filename"`
+	FilePath   string     `json:"file_path"`
+	ImageData  string     `json:"image_data"`
+	CreatedAt  string     `json:"created_at"`
+	Status     string     `json:"status"`
+	BookID     uuid.UUID  `json:"book_id"`
+	CopyID     *uuid.UUID `json:"copy_id"`
+	Created    bool       `json:"created"`  // 論文を新規登録した場合は true、既存の論文に関連付けた場合は false
+	Existing   bool       `json:"existing"` // 同じコードのバーコードを生成済みで、既存の記録と画像を返した場合は true
+}
+
+// SavedBarcodeResponse - 保存済みバーコード
+type SavedBarcodeResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Code       string     `json:"code"`
+	Symbology  string     `json:"symbology"`
+	BookID     *uuid.UUID `json:"book_id"`
+	CopyID     *uuid.UUID `json:"copy_id"`
+	Filename   string     `json:"filename"`
+	FilePath   string     `json:"file_path"`
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  string     `json:"created_at"`
+	Size       int64      `json:"size"`
+	Year       string     `json:"year,omitempty"`
+	StudentID  string     `json:"student_id,omitempty"`
+	AuthorName string     `json:"author_name,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Width      int        `json:"width,omitempty"`
+	Height     int        `json:"height,omitempty"`
+}
+
+// newSavedBarcodeResponse は b を返す。年度・学籍番号などは生成時の指定を返す。
+func newSavedBarcodeResponse(b models.Barcode) SavedBarcodeResponse {
+	return SavedBarcodeResponse{
+		ID:         b.ID,
+		Code:       b.Code,
+		Symbology:  b.Symbology,
+		BookID:     b.BookID,
+		CopyID:     b.CopyID,
+		Filename:   b.Filename,
+		FilePath:   BarcodesPrefix + b.StoragePath,
+		CreatedBy:  b.CreatedBy,
+		CreatedAt:  b.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		Size:       b.Size,
+		Year:       b.Params.Year,
+		StudentID:  b.Params.StudentID,
+		AuthorName: b.Params.AuthorName,
+		Title:      b.Params.Title,
+		Width:      b.Params.Width,
+		Height:     b.Params.Height,
+	}
+}
+
+// SavedBarcodesResponse - 保存済みバーコード画像の一覧
+type SavedBarcodesResponse struct {
+	PageResponse[SavedBarcodeResponse]
+}
+
+func (r SavedBarcodesResponse) legacy() interface{} {
+	return map[string]interface{}{
+		"barcodes": r.Items,
+		"count":    len(r.Items),
+	}
+}
+
+// LabelSheetRequest - ラベル用紙の PDF の作成リクエスト。ラベルは barcode_ids、copy_ids、book_ids の順に並べる。
+type LabelSheetRequest struct {
+	BarcodeIDs []uuid.UUID `json:"barcode_ids"` // 保存済みバーコード（GET /barcode/saved の id）
+	CopyIDs    []uuid.UUID `json:"copy_ids"`    // コピー（バーコードがない場合は製造番号を使う）
+	BookIDs    []uuid.UUID `json:"book_ids"`    // 書籍（すべてのコピーのラベルを印刷する）
+	// Template はプリセットの名前（既定 a4-24）。Layout を指定した場合は Layout の寸法を使う。
+	Template string              `json:"template"`
+	Layout   *LabelLayoutRequest `json:"layout"`
+	Skip     int                 `json:"skip"`    // 最初の用紙の先頭から飛ばすラベルの数（使いかけの用紙向け）
+	Outline  bool                `json:"outline"` // ラベルの枠線を描く（位置合わせの試し印刷向け）
+}
+
+// LabelLayoutRequest - ラベル用紙の寸法（mm）。用紙の大きさを省略した場合は A4 とする。
+type LabelLayoutRequest struct {
+	PageWidth   float64 `json:"page_width"`
+	PageHeight  float64 `json:"page_height"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"label_width"`
+	LabelHeight float64 `json:"label_height"`
+	MarginTop   float64 `json:"margin_top"`
+	MarginLeft  float64 `json:"margin_left"`
+	GapX        float64 `json:"gap_x"`
+	GapY        float64 `json:"gap_y"`
+}
+
+func (l LabelLayoutRequest) template() labels.Template {
+	t := labels.Template{
+		PageWidth: l.PageWidth, PageHeight: l.PageHeight,
+		Columns: l.Columns, Rows: l.Rows,
+		LabelWidth: l.LabelWidth, LabelHeight: l.LabelHeight,
+		MarginTop: l.MarginTop, MarginLeft: l.MarginLeft,
+		GapX: l.GapX, GapY: l.GapY,
+	}
+	if t.PageWidth == 0 && t.PageHeight == 0 {
+		t.PageWidth, t.PageHeight = labels.A4Width, labels.A4Height
+	}
+	return t
+}
+
+// LabelTemplateResponse - ラベル用紙のプリセット
+type LabelTemplateResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	LabelLayoutRequest
+}
+
+// LabelTemplatesResponse - ラベル用紙のプリセットの一覧
+type LabelTemplatesResponse struct {
+	Templates []LabelTemplateResponse `json:"templates"`
+	Default   string                  `json:"default"`
+}
+
+func newLabelTemplateResponse(t labels.Template) LabelTemplateResponse {
+	return LabelTemplateResponse{
+		Name:        t.Name,
+		Description: t.Description,
+		LabelLayoutRequest: LabelLayoutRequest{
+			PageWidth: t.PageWidth, PageHeight: t.PageHeight,
+			Columns: t.Columns, Rows: t.Rows,
+			LabelWidth: t.LabelWidth, LabelHeight: t.LabelHeight,
+			MarginTop: t.MarginTop, MarginLeft: t.MarginLeft,
+			GapX: t.GapX, GapY: t.GapY,
+		},
+	}
+}
+
+// FileDeletedResponse - ファイル削除結果
+type FileDeletedResponse struct {
+	Message  string `json:"message"`
+	Filename string `json:"filename"`
+}
+
+// ImageUploadResponse - 画像アップロード結果
+type ImageUploadResponse struct {
+	Message   string `json:"message"`
+	ImagePath string `json:"image_path"`
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"lablib/circulation"
+	"lablib/middleware"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiError はHTTPステータスと機械判読用のエラーコードの組。
+// メッセージは messages からリクエストの言語に合わせて選ばれる。
+type apiError struct {
+	Status int
+	Code   string
+}
+
+var (
+	errInvalidRequest        = apiError{http.StatusBadRequest, "invalid_request"}
+	errInvalidID             = apiError{http.StatusBadRequest, "invalid_id"}
+	errMissingFields         = apiError{http.StatusBadRequest, "missing_fields"}
+	errInvalidCopyCount      = apiError{http.StatusBadRequest, "invalid_copy_count"}
+	errInvalidStudentID      = apiError{http.StatusBadRequest, "invalid_student_id"}
+	errInvalidPagination     = apiError{http.StatusBadRequest, "invalid_pagination"}
+	errInvalidSort           = apiError{http.StatusBadRequest, "invalid_sort"}
+	errInvalidFilter         = apiError{http.StatusBadRequest, "invalid_filter"}
+	errInvalidPublishedDate  = apiError{http.StatusBadRequest, "invalid_published_date"}
+	errInvalidLanguage       = apiError{http.StatusBadRequest, "invalid_language"}
+	errInvalidAuthorRole     = apiError{http.StatusBadRequest, "invalid_author_role"}
+	errInvalidAcademicYear   = apiError{http.StatusBadRequest, "invalid_academic_year"}
+	errInvalidPageCount      = apiError{http.StatusBadRequest, "invalid_page_count"}
+	errInvalidISBN           = apiError{http.StatusBadRequest, "invalid_isbn"}
+	errInvalidJAN            = apiError{http.StatusBadRequest, "invalid_jan"}
+	errInvalidEAN13          = apiError{http.StatusBadRequest, "invalid_ean13"}
+	errBookPriceCode         = apiError{http.StatusBadRequest, "book_price_code"}
+	errInvalidDegree         = apiError{http.StatusBadRequest, "invalid_degree"}
+	errNotThesis             = apiError{http.StatusBadRequest, "not_a_thesis"}
+	errInvalidVisibility     = apiError{http.StatusBadRequest, "invalid_visibility"}
+	errImageRequired         = apiError{http.StatusBadRequest, "image_required"}
+	errInvalidImageSize      = apiError{http.StatusBadRequest, "invalid_image_size"}
+	errPDFRequired           = apiError{http.StatusBadRequest, "pdf_required"}
+	errFileRequired          = apiError{http.StatusBadRequest, "file_required"}
+	errInvalidExportFormat   = apiError{http.StatusBadRequest, "invalid_export_format"}
+	errInvalidMapping        = apiError{http.StatusBadRequest, "invalid_mapping"}
+	errInvalidSpreadsheet    = apiError{http.StatusBadRequest, "invalid_spreadsheet"}
+	errSheetNotFound         = apiError{http.StatusBadRequest, "sheet_not_found"}
+	errEmptyImport           = apiError{http.StatusBadRequest, "empty_import"}
+	errInvalidUserStudentID  = apiError{http.StatusBadRequest, "invalid_user_student_id"}
+	errInvalidName           = apiError{http.StatusBadRequest, "invalid_name"}
+	errInvalidEmail          = apiError{http.StatusBadRequest, "invalid_email"}
+	errInvalidRole           = apiError{http.StatusBadRequest, "invalid_role"}
+	errInvalidUserStatus     = apiError{http.StatusBadRequest, "invalid_user_status"}
+	errInvalidPassword       = apiError{http.StatusBadRequest, "invalid_password"}
+	errInvalidInvite         = apiError{http.StatusBadRequest, "invalid_invite"}
+	errInvalidLabelTemplate  = apiError{http.StatusBadRequest, "invalid_label_template"}
+	errInvalidLabelCode      = apiError{http.StatusBadRequest, "invalid_label_code"}
+	errTooManyLabels         = apiError{http.StatusBadRequest, "too_many_labels"}
+	errImageTooLarge         = apiError{http.StatusRequestEntityTooLarge, "image_too_large"}
+	errPDFTooLarge           = apiError{http.StatusRequestEntityTooLarge, "pdf_too_large"}
+	errFileTooLarge          = apiError{http.StatusRequestEntityTooLarge, "file_too_large"}
+	errImportTooLarge        = apiError{http.StatusRequestEntityTooLarge, "import_too_large"}
+	errUnsupportedImageType  = apiError{http.StatusUnsupportedMediaType, "unsupported_image_type"}
+	errUnsupportedPDFType    = apiError{http.StatusUnsupportedMediaType, "unsupported_pdf_type"}
+	errUnsupportedFileType   = apiError{http.StatusUnsupportedMediaType, "unsupported_file_type"}
+	errUnsupportedImportType = apiError{http.StatusUnsupportedMediaType, "unsupported_import_type"}
+	errFileRejected          = apiError{http.StatusUnprocessableEntity, "file_rejected"}
+	errInvalidCredentials    = apiError{http.StatusUnauthorized, "invalid_credentials"}
+	errLoginRequired         = apiError{http.StatusUnauthorized, "login_required"}
+	errAuthRequired          = apiError{http.StatusUnauthorized, middleware.CodeAuthRequired}
+	errAdminRequired         = apiError{http.StatusForbidden, middleware.CodeAdminRequired}
+	errAccountInactive       = apiError{http.StatusForbidden, middleware.CodeAccountInactive}
+	errBookNotFound          = apiError{http.StatusNotFound, "book_not_found"}
+	errThesisNotFound        = apiError{http.StatusNotFound, "thesis_not_found"}
+	errPDFNotFound           = apiError{http.StatusNotFound, "pdf_not_found"}
+	errAttachmentNotFound    = apiError{http.StatusNotFound, "attachment_not_found"}
+	errImportNotFound        = apiError{http.StatusNotFound, "import_not_found"}
+	errCoverJobNotFound      = apiError{http.StatusNotFound, "cover_job_not_found"}
+	errUserNotFound          = apiError{http.StatusNotFound, "user_not_found"}
+	errLoanNotFound          = apiError{http.StatusNotFound, "loan_not_found"}
+	errImageNotFound         = apiError{http.StatusNotFound, "image_not_found"}
+	errFileNotFound          = apiError{http.StatusNotFound, "file_not_found"}
+	errBarcodeNotFound       = apiError{http.StatusNotFound, "barcode_not_found"}
+	errCopyNotFound          = apiError{http.StatusNotFound, "copy_not_found"}
+	errBookInfoNotFound      = apiError{http.StatusNotFound, "book_info_not_found"}
+	errDuplicateStudentID    = apiError{http.StatusConflict, "duplicate_student_id"}
+	errDuplicateThesis       = apiError{http.StatusConflict, "duplicate_thesis"}
+	errBarcodeInUse          = apiError{http.StatusConflict, "barcode_in_use"}
+	errCopiesOnLoan          = apiError{http.StatusConflict, "copies_on_loan"}
+	errBookOnLoan            = apiError{http.StatusConflict, "book_on_loan"}
+	errBookNotWithdrawn      = apiError{http.StatusConflict, "book_not_withdrawn"}
+	errImportNotResumable    = apiError{http.StatusConflict, "import_not_resumable"}
+	errCoverJobNotResumable  = apiError{http.StatusConflict, "cover_job_not_resumable"}
+	errUserHasOpenLoans      = apiError{http.StatusConflict, "has_open_loans"}
+	errCannotDeactivateSelf  = apiError{http.StatusConflict, "cannot_deactivate_self"}
+	errAnonymizedUser        = apiError{http.StatusConflict, "anonymized_user"}
+	errCannotDemoteSelf      = apiError{http.StatusConflict, "cannot_demote_self"}
+	errUpstream              = apiError{http.StatusBadGateway, "upstream_error"}
+	errInternal              = apiError{http.StatusInternalServerError, middleware.CodeInternal}
+)
+
+// Error はトランザクション内の処理から apiError をそのまま返せるようにする
+func (e apiError) Error() string { return e.Code }
+
+// localizedMessage は日本語と英語のメッセージ
+type localizedMessage struct {
+	JA, EN string
+}
+
+// messages はエラーコード・メッセージキーごとの表示文言
+var messages = map[string]localizedMessage{
+	// エラー
+	"invalid_request":         {"リクエストデータが正しくありません", "Invalid request data"},
+	"invalid_id":              {"IDの形式が正しくありません", "Invalid ID format"},
+	"missing_fields":          {"必須項目が入力されていません", "Required fields are missing"},
+	"invalid_copy_count":      {"複製数は1以上である必要があります", "Total copies must be at least 1"},
+	"invalid_student_id":      {"学籍番号は6桁の数字で入力してください", "Student ID must be 6 digits"},
+	"invalid_pagination":      {"limit は1〜200、offset は0以上の整数で指定してください", "limit must be 1-200 and offset must be a non-negative integer"},
+	"invalid_sort":            {"指定された並び順は使用できません", "Unsupported sort field"},
+	"invalid_filter":          {"絞り込み条件の形式が正しくありません", "Invalid filter value"},
+	"invalid_published_date":  {"出版日は YYYY・YYYY-MM・YYYY-MM-DD のいずれかの形式で入力してください", "published_date must be YYYY, YYYY-MM or YYYY-MM-DD"},
+	"invalid_language":        {"言語は ja・en などの言語コードで入力してください", "language must be a language code such as ja or en"},
+	"invalid_author_role":     {"著者の役割は author・editor・translator・supervisor のいずれかです", "Author role must be one of author, editor, translator, supervisor"},
+	"invalid_academic_year":   {"年度は4桁の西暦で入力してください", "academic_year must be a four-digit year"},
+	"invalid_page_count":      {"ページ数は0以上の整数で入力してください", "page_count must be a non-negative integer"},
+	"invalid_isbn":            {"ISBNの形式が正しくありません", "Invalid ISBN"},
+	"invalid_jan":             {"JANコードの形式またはチェックディジットが正しくありません", "Invalid JAN code"},
+	"invalid_ean13":           {"EAN13の形式またはチェックディジットが正しくありません", "Invalid EAN-13 code"},
+	"book_price_code":         {"書籍JANコードの2段目（191・192で始まるコード）です。1段目（978で始まるコード）を入力してください", "This is the price barcode (191/192) of a Japanese book; use the upper barcode starting with 978"},
+	"invalid_degree":          {"学位は bachelor・master・doctor のいずれかです", "degree must be one of bachelor, master, doctor"},
+	"not_a_thesis":            {"指定された書籍は論文ではありません", "The specified book is not a thesis"},
+	"invalid_visibility":      {"公開範囲は public・lab・admin のいずれかです", "visibility must be one of public, lab, admin"},
+	"pdf_required":            {"PDFファイルが見つかりません", "PDF file is required"},
+	"pdf_too_large":           {"ファイルサイズが大きすぎます（最大50MB）", "PDF is too large (max 50MB)"},
+	"unsupported_pdf_type":    {"PDFファイルのみアップロードできます", "Only PDF files can be uploaded"},
+	"file_required":           {"ファイルが見つかりません", "File is required"},
+	"file_too_large":          {"ファイルサイズが大きすぎます（最大100MB）", "File is too large (max 100MB)"},
+	"unsupported_file_type":   {"添付できないファイル形式です（PDF・Office文書・画像・テキスト・ZIP）", "Unsupported file type (PDF, Office documents, images, text or ZIP)"},
+	"file_rejected":           {"ファイルの検査で問題が見つかったため登録できません", "The file was rejected by the file scanner"},
+	"invalid_export_format":   {"形式は csv・xlsx・ndjson のいずれかです", "format must be one of csv, xlsx, ndjson"},
+	"invalid_mapping":         {"列の対応が正しくありません。項目名と表の見出しを確認してください（書籍はタイトルまたはISBN、ユーザーは学籍番号と氏名の列が必要です）", "Invalid column mapping; check the field names and headers (books need a title or ISBN column, users need student ID and name columns)"},
+	"invalid_spreadsheet":     {"表を読み取れません", "The spreadsheet could not be read"},
+	"sheet_not_found":         {"指定されたシートが見つかりません", "Sheet not found"},
+	"empty_import":            {"取り込む行がありません", "There are no rows to import"},
+	"import_too_large":        {"ファイルサイズが大きすぎます（最大20MB）", "File is too large (max 20MB)"},
+	"unsupported_import_type": {"CSV または XLSX ファイルのみ取り込めます", "Only CSV and XLSX files can be imported"},
+	"import_not_found":        {"取り込みジョブが見つかりません", "Import job not found"},
+	"import_not_resumable":    {"失敗した取り込みジョブのみ再開できます", "Only failed import jobs can be resumed"},
+	"duplicate_book":          {"ISBN・JAN・EAN13 が同じ書籍が既に登録されています", "A book with the same ISBN, JAN or EAN-13 already exists"},
+	"duplicate_in_file":       {"ISBN・JAN・EAN13 が同じ行がファイル内の前の行にあります", "An earlier row in the file has the same ISBN, JAN or EAN-13"},
+	"cover_job_not_found":     {"表紙画像の取得ジョブが見つかりません", "Cover job not found"},
+	"cover_job_not_resumable": {"失敗した表紙画像の取得ジョブのみ再開できます", "Only failed cover jobs can be resumed"},
+	"cover_not_found":         {"表紙画像が見つかりません", "No cover image found"},
+	"image_exists":            {"書籍には既に画像が登録されています", "The book already has an image"},
+	"invalid_user_student_id": {"学籍番号は8文字以内の英数字で入力してください", "Student ID must be up to 8 letters or digits"},
+	"invalid_name":            {"氏名は1〜50文字で入力してください", "Name must be 1 to 50 characters"},
+	"invalid_email":           {"メールアドレスの形式が正しくありません", "Invalid email address"},
+	"invalid_role":            {"ロールは user・admin のいずれかです", "role must be one of user, admin"},
+	"invalid_user_status":     {"状態は active・deactivated・graduated のいずれかです（一括利用停止では deactivated・graduated）", "status must be one of active, deactivated, graduated (deactivated or graduated for bulk deactivation)"},
+	"invalid_password":        {"パスワードは8文字以上で入力してください", "Password must be at least 8 characters"},
+	"invalid_invite":          {"招待が無効か、有効期限が切れています", "The invitation is invalid or has expired"},
+	"account_inactive":        {"このアカウントは利用停止されています", "This account has been deactivated"},
+	"duplicate_user_in_file":  {"同じ学籍番号の行がファイル内の前の行にあります", "An earlier row in the file has the same student ID"},
+	"user_exists":             {"この学籍番号のユーザーは登録済みのため変更していません", "A user with this student ID already exists and was left unchanged"},
+	"already_inactive":        {"既に利用停止・卒業の状態です", "The user is already deactivated"},
+	"has_open_loans":          {"返却されていない貸出があります", "The user has unreturned loans"},
+	"cannot_deactivate_self":  {"自分自身は利用停止・匿名化できません", "You cannot deactivate or anonymize your own account"},
+	"anonymized_user":         {"匿名化したユーザーは変更できません", "Anonymized users cannot be changed"},
+	"cannot_demote_self":      {"自分自身の管理者権限は外せません", "You cannot remove your own admin role"},
+	"thesis_not_found":        {"論文が見つかりません", "Thesis not found"},
+	"pdf_not_found":           {"論文PDFが見つかりません", "Thesis PDF not found"},
+	"attachment_not_found":    {"添付ファイルが見つかりません", "Attachment not found"},
+	"duplicate_thesis":        {"同じ年度・学籍番号の論文が既に登録されています", "A thesis with the same academic year and student ID already exists"},
+	"barcode_in_use":          {"このバーコードは別の書籍で使用されています", "The barcode is already used by another book"},
+	"image_required":          {"画像ファイルが見つかりません", "Image file is required"},
+	"invalid_image_size":      {"画像の大きさは thumb・medium・original のいずれかを指定してください", "Image size must be thumb, medium or original"},
+	"image_too_large":         {"画像が大きすぎます（最大5MB・4000万画素）", "Image is too large (max 5MB and 40 megapixels)"},
+	"unsupported_image_type":  {"サポートされていない画像形式です", "Unsupported image type"},
+	"invalid_credentials":     {"学籍番号またはパスワードが正しくありません", "Invalid credentials"},
+	"login_required":          {"このファイルのダウンロードにはログインが必要です", "Login is required to download this file"},
+	"auth_required":           {"ログインが必要です", "Login is required"},
+	"admin_required":          {"管理者としてログインする必要があります", "Admin login is required"},
+	"book_not_found":          {"書籍が見つかりません", "Book not found"},
+	"user_not_found":          {"指定されたユーザーが見つかりません", "User not found"},
+	"copy_not_found":          {"書籍コピーが見つかりません", "Book copy not found"},
+	"no_available_copy":       {"貸出可能な書籍コピーが見つかりません", "No copy is available for checkout"},
+	"loan_not_found":          {"貸出記録が見つかりません", "Borrow record not found"},
+	"already_returned":        {"この貸出記録は既に返却済みです", "This loan has already been returned"},
+	"loan_overdue":            {"返却期限を過ぎているため延長できません", "Overdue loans cannot be renewed"},
+	"renew_limit_reached":     {"延長回数の上限に達しています", "Renewal limit reached"},
+	"image_not_found":         {"画像が見つかりません", "Image not found"},
+	"file_not_found":          {"ファイルが見つかりません", "File not found"},
+	"barcode_not_found":       {"バーコードが見つかりません", "Barcode not found"},
+	"invalid_label_template":  {"ラベル用紙の指定が正しくありません（プリセット名、またはラベルが用紙に収まる寸法を指定してください）", "Invalid label template; use a preset name or a layout whose labels fit on the page"},
+	"invalid_label_code":      {"バーコードにできない値があります（英数字・記号のみ印刷できます）", "A value cannot be printed as a barcode (only ASCII letters, digits and symbols are supported)"},
+	"too_many_labels":         {"1回に印刷できるラベルは1000枚までです", "Up to 1000 labels can be printed at once"},
+	"book_info_not_found":     {"書籍情報が見つかりません", "No book information found"},
+	"duplicate_student_id":    {"この学籍番号は既に登録されています", "Student ID is already registered"},
+	"copies_on_loan":          {"貸出中のコピーがあるため、指定した数まで削除できません", "Cannot remove copies that are on loan"},
+	"book_on_loan":            {"貸出中の書籍は除籍・削除できません", "Books on loan cannot be withdrawn or deleted"},
+	"book_not_withdrawn":      {"除籍されていない書籍です", "The book has not been withdrawn"},
+	"book_withdrawn":          {"除籍された書籍は貸出できません", "Withdrawn books cannot be checked out"},
+	"upstream_error":          {"書籍情報の取得に失敗しました", "Failed to fetch book information"},
+	"internal_error":          {"サーバー内部でエラーが発生しました", "Internal server error"},
+
+	// 成功時のメッセージ
+	"checkout_succeeded": {"貸出成功", "Checked out"},
+	"return_succeeded":   {"返却成功", "Returned"},
+	"renew_succeeded":    {"延長成功", "Renewed"},
+	"book_updated":       {"書籍情報が更新されました", "Book updated"},
+	"withdraw_succeeded": {"書籍を除籍しました（貸出の履歴は残ります）", "Book withdrawn; loan history is kept"},
+	"restore_succeeded":  {"除籍した書籍を復元しました", "Book restored"},
+	"purge_succeeded":    {"書籍を完全に削除しました", "Book permanently deleted"},
+	"user_anonymized":    {"ユーザーを匿名化しました（貸出の履歴は残ります）", "User anonymized; loan history is kept"},
+	"image_uploaded":     {"画像をアップロードしました", "Image uploaded"},
+	"image_deleted":      {"画像を削除しました", "Image deleted"},
+	"file_deleted":       {"ファイルが削除されました", "File deleted"},
+	"thesis_updated":     {"論文情報が更新されました", "Thesis updated"},
+	"pdf_uploaded":       {"論文PDFをアップロードしました", "Thesis PDF uploaded"},
+	"pdf_deleted":        {"論文PDFを削除しました", "Thesis PDF deleted"},
+	"attachment_deleted": {"添付ファイルを削除しました", "Attachment deleted"},
+}
+
+// preferredLanguage は Accept-Language から "ja" または "en" を選ぶ（既定は日本語）
+func preferredLanguage(c *gin.Context) string {
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, "ja"):
+			return "ja"
+		case strings.HasPrefix(tag, "en"):
+			return "en"
+		}
+	}
+	return "ja"
+}
+
+// message は key に対応する文言をリクエストの言語で返す
+func message(c *gin.Context, key string) string {
+	m, ok := messages[key]
+	if !ok {
+		return key
+	}
+	if preferredLanguage(c) == "en" {
+		return m.EN
+	}
+	return m.JA
+}
+
+// respondError はエラーレスポンスを書き込む。
+// /api/v1 では ErrorResponse のエンベロープを、従来の /api では {"error": メッセージ} を返す。
+func respondError(c *gin.Context, e apiError) {
+	msg := message(c, e.Code)
+	if isLegacy(c) {
+		c.AbortWithStatusJSON(e.Status, gin.H{"error": msg})
+		return
+	}
+	c.AbortWithStatusJSON(e.Status, ErrorResponse{Error: ErrorBody{
+		Code:      e.Code,
+		Message:   msg,
+		RequestID: c.GetString(middleware.RequestIDKey),
+	}})
+}
+
+// respondInternalError は原因をログに残して internal_error を返す
+func respondInternalError(c *gin.Context, err error) {
+	log.Printf("[%s] %s %s: %v", c.GetString(middleware.RequestIDKey), c.Request.Method, c.FullPath(), err)
+	respondError(c, errInternal)
+}
+
+// circulationError は circulation のドメインエラーを apiError に変換する
+func circulationError(err error) (apiError, bool) {
+	var e *circulation.Error
+	if !errors.As(err, &e) {
+		return apiError{}, false
+	}
+	switch e.Kind {
+	case circulation.KindInvalid:
+		return apiError{http.StatusBadRequest, e.Code}, true
+	case circulation.KindNotFound:
+		return apiError{http.StatusNotFound, e.Code}, true
+	case circulation.KindConflict:
+		return apiError{http.StatusConflict, e.Code}, true
+	case circulation.KindForbidden:
+		return apiError{http.StatusForbidden, e.Code}, true
+	default:
+		return apiError{}, false
+	}
+}
+
+// respondErr は err の種類に応じたエラーレスポンスを書き込む。
+// apiError と circulation のドメインエラーはそのまま、それ以外は internal_error として返す。
+func respondErr(c *gin.Context, err error) {
+	var e apiError
+	if errors.As(err, &e) {
+		respondError(c, e)
+		return
+	}
+	if e, ok := circulationError(err); ok {
+		respondError(c, e)
+		return
+	}
+	respondInternalError(c, err)
+}
+
+// notFoundAs は repository.ErrNotFound を e に置き換え、それ以外のエラーはそのまま返す
+func notFoundAs(err error, e apiError) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return e
+	}
+	return err
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+	"lablib/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 書き出しの形式
+const (
+	ExportCSV    = spreadsheet.FormatCSV
+	ExportXLSX   = spreadsheet.FormatXLSX
+	ExportNDJSON = "ndjson"
+)
+
+// exportFormats は書き出しの形式ごとの Content-Type
+var exportFormats = map[string]string{
+	ExportCSV:    "text/csv; charset=utf-8",
+	ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	ExportNDJSON: "application/x-ndjson",
+}
+
+// exportBatchSize はリポジトリから一度に読み込む件数。書き出し中に保持するのはこの件数の行のみ。
+const exportBatchSize = 500
+
+// maxExportRankings はランキングの書き出しの件数の上限
+const maxExportRankings = 10000
+
+// exportColumn は書き出す列。name は見出しと NDJSON のキーになる。
+type exportColumn[T any] struct {
+	name  string
+	value func(T) interface{}
+}
+
+// ndjsonWriter は1行を1つの JSON オブジェクトとして書き出す。キーは列の順に並べる。
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func newNDJSONWriter(w io.Writer, header []string) *ndjsonWriter {
+	nw := &ndjsonWriter{w: bufio.NewWriter(w), keys: make([][]byte, len(header))}
+	for i, name := range header {
+		nw.keys[i], _ = json.Marshal(name)
+	}
+	return nw
+}
+
+func (w *ndjsonWriter) Write(values []interface{}) error {
+	w.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		w.w.Write(w.keys[i])
+		w.w.WriteByte(':')
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.w.Write(data)
+	}
+	w.w.WriteString("}\n")
+	return nil
+}
+
+func (w *ndjsonWriter) Flush() error { return w.w.Flush() }
+func (w *ndjsonWriter) Close() error { return w.w.Flush() }
+
+// parseExportFormat は format クエリパラメーター（csv・xlsx・ndjson。既定は csv）を読み取る
+func parseExportFormat(c *gin.Context) (string, error) {
+	format := strings.ToLower(c.DefaultQuery("format", ExportCSV))
+	if _, ok := exportFormats[format]; !ok {
+		return "", errInvalidExportFormat
+	}
+	return format, nil
+}
+
+// writeExport は next が返す行を format で書き出す。next は page の範囲の行を返し、
+// exportBatchSize 件未満を返すと終わる。最初の読み込みに失敗した場合はエラーのレスポンスを返し、
+// 書き出しを始めた後の失敗はログに記録して書き出しを打ち切る。
+func writeExport[T any](c *gin.Context, name, format string, columns []exportColumn[T], next func(page repository.Page) ([]T, error)) {
+	page := repository.Page{Limit: exportBatchSize}
+	rows, err := next(page)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+	c.Header("Content-Type", exportFormats[format])
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+
+	var w spreadsheet.Writer
+	if format == ExportNDJSON {
+		w = newNDJSONWriter(c.Writer, header)
+	} else if w, err = spreadsheet.NewWriter(format, c.Writer, header); err != nil {
+		log.Printf("Export %s aborted: %v", name, err)
+		return
+	}
+
+	values := make([]interface{}, len(columns))
+	for {
+		for _, row := range rows {
+			for i, col := range columns {
+				values[i] = col.value(row)
+			}
+			if err := w.Write(values); err != nil {
+				log.Printf("Export %s aborted: %v", name, err)
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Export %s aborted: %v", name, err)
+			return
+		}
+		c.Writer.Flush()
+
+		if len(rows) < page.Limit {
+			break
+		}
+		page.Offset += len(rows)
+		if rows, err = next(page); err != nil {
+			log.Printf("Export %s aborted: %v", name, err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export %s aborted: %v", name, err)
+	}
+}
+
+// bookExportColumns は書籍の書き出しの列
+var bookExportColumns = []exportColumn[models.BookSummary]{
+	{"id", func(b models.BookSummary) interface{} { return b.ID }},
+	{"title", func(b models.BookSummary) interface{} { return b.Title }},
+	{"author", func(b models.BookSummary) interface{} { return b.Author }},
+	{"isbn", func(b models.BookSummary) interface{} { return b.ISBN }},
+	{"jan", func(b models.BookSummary) interface{} { return b.JAN }},
+	{"ean13", func(b models.BookSummary) interface{} { return b.EAN13 }},
+	{"type", func(b models.BookSummary) interface{} { return b.Type }},
+	{"total_copies", func(b models.BookSummary) interface{} { return b.TotalCopies }},
+	{"available_copies", func(b models.BookSummary) interface{} { return b.AvailableCopies }},
+	{"on_loan_copies", func(b models.BookSummary) interface{} { return b.TotalCopies - b.AvailableCopies }},
+	{"barcode", func(b models.BookSummary) interface{} { return b.Barcode }},
+	{"location", func(b models.BookSummary) interface{} { return b.Location }},
+	{"publisher", func(b models.BookSummary) interface{} { return b.Publisher }},
+	{"published_date", func(b models.BookSummary) interface{} { return b.PublishedDate }},
+	{"edition", func(b models.BookSummary) interface{} { return b.Edition }},
+	{"page_count", func(b models.BookSummary) interface{} { return b.PageCount }},
+	{"language", func(b models.BookSummary) interface{} { return b.Language }},
+	{"subjects", func(b models.BookSummary) interface{} { return strings.Join(b.Subjects, "; ") }},
+	{"notes", func(b models.BookSummary) interface{} { return b.Notes }},
+	{"created_at", func(b models.BookSummary) interface{} { return b.CreatedAt }},
+	{"updated_at", func(b models.BookSummary) interface{} { return b.UpdatedAt }},
+	{"withdrawn_at", func(b models.BookSummary) interface{} { return b.WithdrawnAt }},
+	{"withdrawal_reason", func(b models.BookSummary) interface{} { return b.WithdrawalReason }},
+}
+
+// loanExportColumns は貸出履歴の書き出しの列
+var loanExportColumns = []exportColumn[models.BorrowRecord]{
+	{"id", func(r models.BorrowRecord) interface{} { return r.ID }},
+	{"borrowed_at", func(r models.BorrowRecord) interface{} { return r.BorrowedAt }},
+	{"due_date", func(r models.BorrowRecord) interface{} { return r.DueDate }},
+	{"returned_at", func(r models.BorrowRecord) interface{} { return r.ReturnedAt }},
+	{"status", func(r models.BorrowRecord) interface{} { return r.Status }},
+	{"renew_count", func(r models.BorrowRecord) interface{} { return r.RenewCount }},
+	{"book_id", func(r models.BorrowRecord) interface{} { return r.Book.ID }},
+	{"book_title", func(r models.BorrowRecord) interface{} { return r.Book.Title }},
+	{"serial_number", func(r models.BorrowRecord) interface{} { return r.BookCopy.SerialNumber }},
+	{"barcode", func(r models.BorrowRecord) interface{} { return r.BookCopy.Barcode }},
+	{"user_id", func(r models.BorrowRecord) interface{} { return r.UserID }},
+	{"student_id", func(r models.BorrowRecord) interface{} { return r.User.StudentID }},
+	{"user_name", func(r models.BorrowRecord) interface{} { return r.User.Name }},
+}
+
+// userExportColumns はユーザーの書き出しの列（パスワードは含めない）
+var userExportColumns = []exportColumn[models.User]{
+	{"id", func(u models.User) interface{} { return u.ID }},
+	{"student_id", func(u models.User) interface{} { return u.StudentID }},
+	{"name", func(u models.User) interface{} { return u.Name }},
+	{"role", func(u models.User) interface{} { return u.Role }},
+	{"created_at", func(u models.User) interface{} { return u.CreatedAt }},
+	{"updated_at", func(u models.User) interface{} { return u.UpdatedAt }},
+}
+
+// exportRanking は月間・全期間のランキングの書き出しの1行
+type exportRanking struct {
+	rank        int
+	month       string // 全期間の場合は空
+	book        models.Book
+	borrowCount int
+}
+
+var rankingExportColumns = []exportColumn[exportRanking]{
+	{"rank", func(r exportRanking) interface{} { return r.rank }},
+	{"month", func(r exportRanking) interface{} { return r.month }},
+	{"book_id", func(r exportRanking) interface{} { return r.book.ID }},
+	{"title", func(r exportRanking) interface{} { return r.book.Title }},
+	{"author", func(r exportRanking) interface{} { return r.book.Author }},
+	{"type", func(r exportRanking) interface{} { return r.book.Type }},
+	{"borrow_count", func(r exportRanking) interface{} { return r.borrowCount }},
+}
+
+// ExportBooks - 書籍（コピー数・貸出中の数を含む）の書き出し。書籍一覧と同じ条件で絞り込める。
+// withdrawn=true の場合は除籍した書籍を書き出す。
+func (h *Handler) ExportBooks(c *gin.Context) {
+	format, err := parseExportFormat(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter, err := parseBookFilter(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	withdrawn, err := queryBool(c, "withdrawn")
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter.Withdrawn = withdrawn != nil && *withdrawn
+	if filter.Sort.Field == "" {
+		// 書き出し中に登録された書籍で順序がずれないよう登録順にする
+		filter.Sort = repository.Sort{Field: "created_at"}
+	}
+
+	writeExport(c, "books", format, bookExportColumns, func(page repository.Page) ([]models.BookSummary, error) {
+		filter.Page = page
+		books, _, err := h.store.Books().Search(c.Request.Context(), filter)
+		return books, err
+	})
+}
+
+// ExportLoans - 貸出履歴の書き出し。from・to（貸出日）・status で絞り込み、貸出日の古い順に並べる。
+func (h *Handler) ExportLoans(c *gin.Context) {
+	format, err := parseExportFormat(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter := repository.LoanFilter{Status: c.Query("status"), Sort: repository.Sort{Field: "borrowed_at"}}
+	if filter.Status != "" && filter.Status != "borrowed" && filter.Status != "returned" {
+		respondError(c, errInvalidFilter)
+		return
+	}
+	if filter.BorrowedFrom, err = queryTime(c, "from", false); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.BorrowedTo, err = queryTime(c, "to", true); err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	writeExport(c, "loans", format, loanExportColumns, func(page repository.Page) ([]models.BorrowRecord, error) {
+		filter.Page = page
+		records, _, err := h.store.Loans().List(c.Request.Context(), filter)
+		return records, err
+	})
+}
+
+// ExportUsers - ユーザーの書き出し。role で絞り込み、登録順に並べる。
+func (h *Handler) ExportUsers(c *gin.Context) {
+	format, err := parseExportFormat(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter := repository.UserFilter{Role: c.Query("role"), Sort: repository.Sort{Field: "created_at"}}
+
+	writeExport(c, "users", format, userExportColumns, func(page repository.Page) ([]models.User, error) {
+		filter.Page = page
+		users, _, err := h.store.Users().List(c.Request.Context(), filter)
+		return users, err
+	})
+}
+
+// ExportRankings - 貸出ランキングの書き出し。month（YYYY-MM）を指定した場合は月間、省略時は全期間。
+func (h *Handler) ExportRankings(c *gin.Context) {
+	format, err := parseExportFormat(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	month := c.Query("month")
+	if month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			respondError(c, errInvalidFilter)
+			return
+		}
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxExportRankings {
+			respondError(c, errInvalidPagination)
+			return
+		}
+	}
+
+	name := "rankings"
+	if month != "" {
+		name += "-" + month
+	}
+	// ランキングは件数が限られるため1回で読み込む
+	writeExport(c, name, format, rankingExportColumns, func(page repository.Page) ([]exportRanking, error) {
+		if page.Offset > 0 {
+			return nil, nil
+		}
+		var rows []exportRanking
+		if month != "" {
+			results, err := h.store.Rankings().Monthly(c.Request.Context(), month, limit)
+			if err != nil {
+				return nil, err
+			}
+			for i, r := range results {
+				rows = append(rows, exportRanking{rank: i + 1, month: r.Month, book: r.Book, borrowCount: r.BorrowCount})
+			}
+		} else {
+			results, err := h.store.Rankings().AllTime(c.Request.Context(), limit)
+			if err != nil {
+				return nil, err
+			}
+			for i, r := range results {
+				rows = append(rows, exportRanking{rank: i + 1, book: r.Book, borrowCount: r.BorrowCount})
+			}
+		}
+		return rows, nil
+	})
+}
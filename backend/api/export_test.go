@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"lablib/models"
+	"lablib/spreadsheet"
+
+	"github.com/google/uuid"
+)
+
+// readExport は CSV・XLSX の書き出しを読み直す
+func readExport(t *testing.T, format string, body []byte) [][]string {
+	t.Helper()
+	rows, err := spreadsheet.Read(format, bytes.NewReader(body), int64(len(body)), "")
+	if err != nil {
+		t.Fatalf("read %s export: %v", format, err)
+	}
+	return rows
+}
+
+// 読み込みの単位を超える件数も登録順にすべて書き出す
+func TestExportBooks(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	n := exportBatchSize + 2
+	created := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		book := models.Book{ID: uuid.New(), Title: fmt.Sprintf("書籍 %04d", i), Type: "book", TotalCopies: 1,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute), UpdatedAt: created}
+		if err := s.store.Books().Create(ctx, &book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := s.do("GET", "/api/v1/admin/export/books", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" ||
+		!strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment; filename=books-") {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	rows := readExport(t, ExportCSV, rec.Body.Bytes())
+	if len(rows) != n+1 || rows[0][1] != "title" || rows[1][1] != "書籍 0000" || rows[n][1] != fmt.Sprintf("書籍 %04d", n-1) {
+		t.Fatalf("%d rows; first = %q, last = %q", len(rows), rows[1], rows[len(rows)-1])
+	}
+
+	// 書籍一覧と同じ条件で絞り込める
+	rec = s.do("GET", "/api/v1/admin/export/books?format=xlsx&query=0001", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if rows := readExport(t, ExportXLSX, rec.Body.Bytes()); len(rows) != 2 || rows[1][1] != "書籍 0001" {
+		t.Fatalf("filtered rows = %q", rows)
+	}
+
+	expectError(t, s.do("GET", "/api/v1/admin/export/books?format=pdf", nil, s.adminToken), errInvalidExportFormat)
+	expectError(t, s.do("GET", "/api/v1/admin/export/books?year=x", nil, s.adminToken), errInvalidFilter)
+}
+
+func TestExportLoansAndUsers(t *testing.T) {
+	s := newTestServer(t)
+	s.createBook(models.Book{Title: "書き出し", Type: "book", TotalCopies: 1, Barcode: "EX-0001"})
+	expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "EX-0001", UserID: s.user.ID.String()}, s.userToken), http.StatusOK)
+
+	rec := s.do("GET", "/api/v1/admin/export/loans?format=xlsx&status=borrowed", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	rows := readExport(t, ExportXLSX, rec.Body.Bytes())
+	if len(rows) != 2 || rows[1][4] != "borrowed" || rows[1][7] != "書き出し" || rows[1][11] != DefaultUserStudentID {
+		t.Fatalf("loan rows = %q", rows)
+	}
+	rec = s.do("GET", "/api/v1/admin/export/loans?status=returned", nil, s.adminToken)
+	if rows := readExport(t, ExportCSV, rec.Body.Bytes()); len(rows) != 1 {
+		t.Fatalf("returned loan rows = %q", rows)
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/export/loans?status=lost", nil, s.adminToken), errInvalidFilter)
+
+	// NDJSON は1行に1ユーザーで、パスワードを含めない
+	rec = s.do("GET", "/api/v1/admin/export/users?format=ndjson", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var users []map[string]interface{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var u map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		users = append(users, u)
+	}
+	if len(users) != 2 || len(users[0]) != len(userExportColumns) {
+		t.Fatalf("users = %v", users)
+	}
+	for _, u := range users {
+		if _, ok := u["password"]; ok {
+			t.Fatalf("user export contains password: %v", u)
+		}
+	}
+	rec = s.do("GET", "/api/v1/admin/export/users?role=admin", nil, s.adminToken)
+	if rows := readExport(t, ExportCSV, rec.Body.Bytes()); len(rows) != 2 || rows[1][1] != DefaultAdminStudentID {
+		t.Fatalf("admin rows = %q", rows)
+	}
+	// 管理者以外には書き出しを始めない
+	for _, kind := range []string{"books", "loans", "users", "rankings"} {
+		path := "/api/v1/admin/export/" + kind + "?format=ndjson"
+		for token, want := range map[string]apiError{"": errAuthRequired, s.userToken: errAdminRequired} {
+			rec := s.do("GET", path, nil, token)
+			expectError(t, rec, want)
+			if cd := rec.Header().Get("Content-Disposition"); cd != "" {
+				t.Fatalf("%s: Content-Disposition = %q", path, cd)
+			}
+		}
+	}
+}
+
+func TestExportRankings(t *testing.T) {
+	s := newTestServer(t)
+	s.createBook(models.Book{Title: "人気の本", Type: "book", TotalCopies: 1, Barcode: "EX-0002"})
+	expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "EX-0002", UserID: s.user.ID.String()}, s.userToken), http.StatusOK)
+
+	month := time.Now().Format("2006-01")
+	rec := s.do("GET", "/api/v1/admin/export/rankings?month="+month, nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "rankings-"+month+"-") {
+		t.Fatalf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+	rows := readExport(t, ExportCSV, rec.Body.Bytes())
+	if len(rows) != 2 || rows[1][0] != "1" || rows[1][1] != month || rows[1][3] != "人気の本" || rows[1][6] != "1" {
+		t.Fatalf("ranking rows = %q", rows)
+	}
+	rec = s.do("GET", "/api/v1/admin/export/rankings", nil, s.adminToken)
+	if rows := readExport(t, ExportCSV, rec.Body.Bytes()); len(rows) != 2 || rows[1][1] != "" {
+		t.Fatalf("all-time rows = %q", rows)
+	}
+
+	expectError(t, s.do("GET", "/api/v1/admin/export/rankings?month=2024-13", nil, s.adminToken), errInvalidFilter)
+	expectError(t, s.do("GET", "/api/v1/admin/export/rankings?limit=0", nil, s.adminToken), errInvalidPagination)
+}
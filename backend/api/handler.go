@@ -0,0 +1,62 @@
+package api
+
+import (
+	"sync"
+
+	"lablib/circulation"
+	"lablib/metadata"
+	"lablib/repository"
+	"lablib/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Handler は各 HTTP ハンドラーが利用する依存関係を保持する
+type Handler struct {
+	store       repository.Store
+	circulation *circulation.Service
+	scanner     FileScanner     // nil の場合は添付ファイルを検査しない
+	blobs       storage.Storage // 書籍画像・バーコード画像・添付ファイル・論文PDFの保存先
+	bookInfo    *metadata.Fetcher
+	imports     sync.Mutex // 取り込みジョブを1件ずつ実行する
+	covers      sync.Mutex // 表紙画像の取得ジョブを1件ずつ実行する
+}
+
+// NewHandler は store と貸出サービスを使う Handler を返す。
+// 書誌情報は既定の順序のサービスから取得し、store にキャッシュする。
+// ファイルは SetStorage で変更するまで PublicDir に保存する。
+func NewHandler(store repository.Store, circ *circulation.Service) *Handler {
+	providers, _ := metadata.NewProviders(metadata.DefaultOrder, metadata.Options{})
+	return &Handler{
+		store:       store,
+		circulation: circ,
+		bookInfo:    metadata.NewFetcher(providers, store.MetadataCache()),
+		blobs:       storage.NewLocal(PublicDir),
+	}
+}
+
+// SetFileScanner はアップロードされた添付ファイルの検査に s を使うようにする
+func (h *Handler) SetFileScanner(s FileScanner) {
+	h.scanner = s
+}
+
+// SetBookInfoFetcher は書誌情報の自動取得に f を使うようにする
+func (h *Handler) SetBookInfoFetcher(f *metadata.Fetcher) {
+	h.bookInfo = f
+}
+
+// newSerialNumber は書籍IDの先頭8文字とランダムな4文字からコピーのシリアル番号を作る
+func newSerialNumber(bookID uuid.UUID) string {
+	return bookID.String()[:8] + "-" + uuid.New().String()[:4]
+}
+
+// parseIDParam はパスパラメータ name を UUID として解釈する。不正な場合はレスポンスを書き込み false を返す。
+func parseIDParam(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		respondError(c, errInvalidID)
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,742 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lablib/codes"
+	"lablib/metadata"
+	"lablib/models"
+	"lablib/repository"
+	"lablib/search"
+	"lablib/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const MaxImportSize = 20 << 20 // 20MB
+
+// importField は取り込める項目と、列の見出しから項目を推定するための名前
+type importField struct {
+	name    string
+	aliases []string
+}
+
+// importFields は書籍の取り込みで読む項目。mapping で指定しなかった項目は見出しがいずれかの名前に一致する列から読む。
+var importFields = []importField{
+	{"title", []string{"タイトル", "書名", "資料名"}},
+	{"author", []string{"authors", "著者", "著者名", "著者等"}},
+	{"isbn", []string{"ISBN", "ISBNコード"}},
+	{"jan", []string{"JAN", "JANコード"}},
+	{"ean13", []string{"EAN", "EAN13", "EANコード"}},
+	{"total_copies", []string{"copies", "冊数", "複製数", "所蔵数"}},
+	{"location", []string{"配架場所", "所在", "場所"}},
+	{"barcode", []string{"バーコード"}},
+	{"publisher", []string{"出版社", "出版者"}},
+	{"published_date", []string{"出版日", "出版年", "出版年月", "刊行年"}},
+	{"edition", []string{"版"}},
+	{"page_count", []string{"pages", "ページ数", "頁数"}},
+	{"language", []string{"言語"}},
+	{"description", []string{"内容紹介", "説明", "概要"}},
+	{"subjects", []string{"件名", "分類", "タグ"}},
+	{"notes", []string{"メモ", "備考"}},
+}
+
+// 1つのセルに複数の値を書く場合の区切り。著者名は "Smith, John" のような表記があるためカンマで区切らない。
+const (
+	authorSeparators  = ";；、／"
+	subjectSeparators = ";；、,，"
+)
+
+// importTable は取り込む表。見出しの行より後の空でない行を持つ。
+type importTable struct {
+	mapping map[string]string // 項目名 → 列の見出し（空の場合は取り込まない項目）
+	columns map[string]int    // 項目名 → 列番号
+	records []importRecord
+}
+
+// importRecord は表の1行
+type importRecord struct {
+	number int // 行番号（1始まり）
+	cells  []string
+}
+
+// importHeaderKey は見出しを比較用に正規化する（全角・大文字小文字・空白・"_" の違いを無視する）
+func importHeaderKey(s string) string {
+	return strings.NewReplacer(" ", "", "_", "").Replace(search.Normalize(s))
+}
+
+func blankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseImportTable は表を読み取り、最初の空でない行を見出しとして列と fields の項目を対応付ける。
+// mapping（項目名 → 列の見出し）で指定した項目はその列から読み、値が空の項目は取り込まない。
+func parseImportTable(format string, r io.ReaderAt, size int64, sheet string, fields []importField, mapping map[string]string) (*importTable, error) {
+	rows, err := spreadsheet.Read(format, r, size, sheet)
+	if errors.Is(err, spreadsheet.ErrSheetNotFound) {
+		return nil, errSheetNotFound
+	} else if errors.Is(err, spreadsheet.ErrUnsupported) {
+		return nil, errUnsupportedImportType
+	} else if err != nil {
+		log.Printf("Spreadsheet read warning: %v", err)
+		return nil, errInvalidSpreadsheet
+	}
+
+	header := -1
+	for i, row := range rows {
+		if !blankRow(row) {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, errEmptyImport
+	}
+	index := map[string]int{} // 正規化した見出し → 列番号
+	for i, name := range rows[header] {
+		if key := importHeaderKey(name); key != "" {
+			if _, ok := index[key]; !ok {
+				index[key] = i
+			}
+		}
+	}
+
+	t := &importTable{mapping: map[string]string{}, columns: map[string]int{}}
+	for field, column := range mapping {
+		if !isImportField(fields, field) {
+			return nil, errInvalidMapping
+		}
+		if strings.TrimSpace(column) == "" {
+			t.mapping[field] = ""
+			continue
+		}
+		i, ok := index[importHeaderKey(column)]
+		if !ok {
+			return nil, errInvalidMapping
+		}
+		t.columns[field] = i
+		t.mapping[field] = strings.TrimSpace(rows[header][i])
+	}
+	for _, f := range fields {
+		if _, ok := t.mapping[f.name]; ok {
+			continue
+		}
+		for _, name := range append([]string{f.name}, f.aliases...) {
+			if i, ok := index[importHeaderKey(name)]; ok {
+				t.columns[f.name] = i
+				t.mapping[f.name] = strings.TrimSpace(rows[header][i])
+				break
+			}
+		}
+	}
+	for i := header + 1; i < len(rows); i++ {
+		if !blankRow(rows[i]) {
+			t.records = append(t.records, importRecord{number: i + 1, cells: rows[i]})
+		}
+	}
+	if len(t.records) == 0 {
+		return nil, errEmptyImport
+	}
+	return t, nil
+}
+
+// parseBookImportTable は書籍の取り込みの表を読み取る。
+// タイトルの列がない場合は ISBN から書誌情報を補う前提で ISBN の列を必須にする。
+func parseBookImportTable(format string, r io.ReaderAt, size int64, sheet string, mapping map[string]string) (*importTable, error) {
+	t, err := parseImportTable(format, r, size, sheet, importFields, mapping)
+	if err != nil {
+		return nil, err
+	}
+	_, hasTitle := t.columns["title"]
+	_, hasISBN := t.columns["isbn"]
+	if !hasTitle && !hasISBN {
+		return nil, errInvalidMapping
+	}
+	return t, nil
+}
+
+// importFieldNames は fields の項目名の一覧
+func importFieldNames(fields []importField) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+func isImportField(fields []importField, name string) bool {
+	for _, f := range fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// value は1行の field の列の値を返す。列がない場合は空文字列を返す。
+func (t *importTable) value(rec importRecord, field string) string {
+	i, ok := t.columns[field]
+	if !ok || i >= len(rec.cells) {
+		return ""
+	}
+	return strings.TrimSpace(rec.cells[i])
+}
+
+// book は1行から書籍を作る。資料種別は book、冊数の省略時は1冊とする。
+// エラーの場合も読み取れた項目を設定した書籍を返す。
+func (t *importTable) book(rec importRecord) (*models.Book, error) {
+	get := func(field string) string { return t.value(rec, field) }
+
+	book := &models.Book{
+		Title:       get("title"),
+		ISBN:        get("isbn"),
+		JAN:         get("jan"),
+		EAN13:       get("ean13"),
+		Type:        "book",
+		TotalCopies: 1,
+		Barcode:     get("barcode"),
+		Location:    get("location"),
+		Publisher:   get("publisher"),
+		Edition:     get("edition"),
+		Language:    get("language"),
+		Description: get("description"),
+		Subjects:    splitImportList(get("subjects"), subjectSeparators),
+		Notes:       get("notes"),
+	}
+	for _, name := range splitImportList(get("author"), authorSeparators) {
+		book.Authors = append(book.Authors, models.BookAuthor{Name: name})
+	}
+
+	if v := get("total_copies"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return book, errInvalidCopyCount
+		}
+		book.TotalCopies = n
+	}
+	if v := get("page_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return book, errInvalidPageCount
+		}
+		book.PageCount = n
+	}
+	if v := get("published_date"); v != "" {
+		if book.PublishedDate = importDate(v); book.PublishedDate == "" {
+			return book, errInvalidPublishedDate
+		}
+	}
+	return book, nil
+}
+
+// splitImportList は separators のいずれかで区切られた値を、空の値を除いて返す
+func splitImportList(s, separators string) []string {
+	values := []string{}
+	for _, v := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// importDate は表の出版日を "2006"・"2006-01"・"2006-01-02" のいずれかの形にする。
+// 日付の書式の XLSX のセルはシリアル値（1900-01-01 を 1 とする日数）として読まれるため、5桁の数値は日付に戻す。
+func importDate(v string) string {
+	if n, err := strconv.ParseFloat(v, 64); err == nil && n >= 10000 && n < 100000 {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(n)).Format("2006-01-02")
+	}
+	return metadata.NormalizeDate(v)
+}
+
+// enrichImportBook は空の書誌情報を ISBN から外部サービスで補う。補えなかった場合は警告のコードを返す。
+func (h *Handler) enrichImportBook(ctx context.Context, book *models.Book) string {
+	isbn, err := codes.ISBN13(book.ISBN)
+	if book.ISBN == "" || err != nil {
+		// ISBN の誤りは normalizeBook で報告する
+		return ""
+	}
+	record, err := h.bookInfo.Fetch(ctx, isbn)
+	if errors.Is(err, metadata.ErrNotFound) {
+		return "book_info_not_found"
+	} else if err != nil {
+		log.Printf("Book info fetch warning: %s: %v", isbn, err)
+		return "upstream_error"
+	}
+
+	if book.Title == "" {
+		book.Title = record.Title
+	}
+	if len(book.Authors) == 0 {
+		for _, name := range record.Authors {
+			book.Authors = append(book.Authors, models.BookAuthor{Name: name})
+		}
+	}
+	if book.Publisher == "" {
+		book.Publisher = record.Publisher
+	}
+	if _, err := parsePublishedDate(record.PublishedDate); book.PublishedDate == "" && err == nil {
+		book.PublishedDate = record.PublishedDate
+	}
+	if book.PageCount == 0 {
+		book.PageCount = record.PageCount
+	}
+	if book.Language == "" && languagePattern.MatchString(record.Language) {
+		book.Language = record.Language
+	}
+	if book.Description == "" {
+		book.Description = record.Description
+	}
+	if len(book.Subjects) == 0 {
+		book.Subjects = record.Subjects
+	}
+	return ""
+}
+
+// importCodes は取り込み中のファイル内の重複の判定に使う書籍のコード
+func importCodes(book *models.Book) []string {
+	var list []string
+	for _, code := range []string{book.ISBN, book.JAN, book.EAN13} {
+		if code != "" {
+			list = append(list, code)
+		}
+	}
+	return list
+}
+
+// importRow は1行を検証し、ドライランでなければ登録する。
+// 行の結果の保存とジョブの件数の更新は書籍の登録と同じトランザクションで行うため、中断しても同じ行を二重に登録しない。
+// seen はファイル内で先に登録した（ドライランでは登録できる）行のコード。
+func (h *Handler) importRow(ctx context.Context, job *models.ImportJob, t *importTable, rec importRecord, seen map[string]bool) error {
+	row := models.ImportRow{JobID: job.ID, RowNumber: rec.number, Status: models.ImportRowImported, Messages: []string{}}
+	book, err := t.book(rec)
+	if err == nil && job.Enrich {
+		if warning := h.enrichImportBook(ctx, book); warning != "" {
+			row.Messages = append(row.Messages, warning)
+		}
+	}
+	if err == nil {
+		if err = normalizeBook(book); err == nil && book.Title == "" {
+			err = errMissingFields
+		}
+	}
+	row.Title, row.ISBN = book.Title, book.ISBN
+	var invalid apiError
+	if errors.As(err, &invalid) {
+		row.Status = models.ImportRowError
+		row.Messages = append(row.Messages, invalid.Code)
+	} else if err != nil {
+		return err
+	}
+
+	next := *job
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		if row.Status == models.ImportRowImported {
+			duplicates, err := findDuplicateBooks(ctx, tx.Books(), book)
+			if err != nil {
+				return fmt.Errorf("find duplicate books: %w", err)
+			}
+			switch {
+			case len(duplicates) > 0:
+				row.Status = models.ImportRowDuplicate
+				row.DuplicateOf = &duplicates[0].BookID
+				row.Messages = append(row.Messages, "duplicate_book")
+			case containsAny(seen, importCodes(book)):
+				row.Status = models.ImportRowDuplicate
+				row.Messages = append(row.Messages, "duplicate_in_file")
+			case !job.DryRun:
+				book.ID = uuid.New()
+				book.CreatedAt = time.Now()
+				book.UpdatedAt = time.Now()
+				if err := createBookWithCopies(ctx, tx, book); err != nil {
+					return err
+				}
+				row.BookID = &book.ID
+			}
+		}
+		if err := tx.ImportJobs().AddRow(ctx, &row); err != nil {
+			return fmt.Errorf("add import row: %w", err)
+		}
+
+		switch row.Status {
+		case models.ImportRowImported:
+			next.ImportedRows++
+		case models.ImportRowDuplicate:
+			next.DuplicateRows++
+		case models.ImportRowError:
+			next.ErrorRows++
+		}
+		next.ProcessedRows++
+		next.UpdatedAt = time.Now()
+		return tx.ImportJobs().Update(ctx, &next)
+	})
+	if err != nil {
+		return err
+	}
+
+	*job = next
+	if row.Status == models.ImportRowImported {
+		for _, code := range importCodes(book) {
+			seen[code] = true
+		}
+	}
+	return nil
+}
+
+func containsAny(set map[string]bool, keys []string) bool {
+	for _, k := range keys {
+		if set[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// startImport は取り込みジョブ ids をバックグラウンドで順に実行する。同時に実行するジョブは1件のみ。
+func (h *Handler) startImport(ids ...uuid.UUID) {
+	go func() {
+		for _, id := range ids {
+			h.imports.Lock()
+			err := h.runImport(context.Background(), id)
+			h.imports.Unlock()
+			if err != nil {
+				log.Printf("Import job %s failed: %v", id, err)
+			}
+		}
+	}()
+}
+
+// runImport はジョブの未処理の行を処理する。失敗した場合はジョブを failed にし、処理済みの行の結果は残す。
+func (h *Handler) runImport(ctx context.Context, id uuid.UUID) error {
+	jobs := h.store.ImportJobs()
+	job, err := jobs.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status == models.ImportStatusCompleted {
+		return nil
+	}
+
+	now := time.Now()
+	job.Status = models.ImportStatusRunning
+	job.Error = ""
+	job.UpdatedAt = now
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	if err := jobs.Update(ctx, job); err != nil {
+		return err
+	}
+
+	if err := h.processImport(ctx, job); err != nil {
+		job.Status = models.ImportStatusFailed
+		job.Error = err.Error()
+		job.UpdatedAt = time.Now()
+		if uerr := jobs.Update(ctx, job); uerr != nil {
+			log.Printf("Import job update warning: %v", uerr)
+		}
+		return err
+	}
+
+	finished := time.Now()
+	job.Status = models.ImportStatusCompleted
+	job.UpdatedAt = finished
+	job.FinishedAt = &finished
+	if err := jobs.Update(ctx, job); err != nil {
+		return err
+	}
+	// 完了したジョブは再開しないためファイルを削除する
+	h.deleteBlobs(ctx, ImportsPrefix+job.StoragePath)
+	log.Printf("Import job %s completed: %d imported, %d duplicates, %d errors",
+		job.ID, job.ImportedRows, job.DuplicateRows, job.ErrorRows)
+	return nil
+}
+
+// processImport は保存したファイルを読み直し、ProcessedRows より後の行を処理する
+func (h *Handler) processImport(ctx context.Context, job *models.ImportJob) error {
+	data, err := h.readBlob(ctx, ImportsPrefix+job.StoragePath)
+	if err != nil {
+		return err
+	}
+	table, err := parseBookImportTable(job.Format, bytes.NewReader(data), int64(len(data)), job.Sheet, job.Mapping)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for i, rec := range table.records {
+		if i < job.ProcessedRows {
+			// 処理済みの行はファイル内の重複の判定のためにコードだけを読む
+			if book, err := table.book(rec); err == nil && normalizeBook(book) == nil {
+				for _, code := range importCodes(book) {
+					seen[code] = true
+				}
+			}
+			continue
+		}
+		if err := h.importRow(ctx, job, table, rec, seen); err != nil {
+			return fmt.Errorf("row %d: %w", rec.number, err)
+		}
+	}
+	return nil
+}
+
+// ResumeImports はサーバーの停止で中断した取り込みジョブ（実行待ち・実行中）を古い順に再開する
+func (h *Handler) ResumeImports(ctx context.Context) (int, error) {
+	jobs, err := h.store.ImportJobs().ListUnfinished(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uuid.UUID, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	if len(ids) > 0 {
+		h.startImport(ids...)
+	}
+	return len(ids), nil
+}
+
+// formBool は true/false のフォーム項目を読み取る。指定がなければ false を返す。
+func formBool(c *gin.Context, name string) (bool, error) {
+	v := c.PostForm(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errInvalidRequest
+	}
+	return b, nil
+}
+
+// readImportFile はアップロードされた表（file）を読み込み、内容・ファイル名・形式を返す
+func readImportFile(c *gin.Context) ([]byte, string, string, error) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil, "", "", errFileRequired
+	}
+	defer file.Close()
+	if header.Size > MaxImportSize {
+		return nil, "", "", errImportTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxImportSize+1))
+	if err != nil || len(data) == 0 {
+		return nil, "", "", errFileRequired
+	}
+	if len(data) > MaxImportSize {
+		return nil, "", "", errImportTooLarge
+	}
+
+	filename := cleanFilename(header.Filename)
+	format, err := spreadsheet.DetectFormat(filename, data)
+	if err != nil {
+		return nil, "", "", errUnsupportedImportType
+	}
+	return data, filename, format, nil
+}
+
+// newImportRowResponse は行の結果のメッセージのコードをリクエストの言語の文言にする
+func newImportRowResponse(c *gin.Context, row models.ImportRow) ImportRowResponse {
+	messages := make([]ImportMessageResponse, len(row.Messages))
+	for i, code := range row.Messages {
+		messages[i] = ImportMessageResponse{Code: code, Message: message(c, code)}
+	}
+	return ImportRowResponse{
+		RowNumber:   row.RowNumber,
+		Status:      row.Status,
+		Title:       row.Title,
+		ISBN:        row.ISBN,
+		BookID:      row.BookID,
+		DuplicateOf: row.DuplicateOf,
+		Messages:    messages,
+	}
+}
+
+// ImportBooks - CSV・XLSX からの書籍の一括取り込み（管理者のみ）。
+// 表を検証してジョブを登録し、行の処理はバックグラウンドで行う。進捗は GetImportJob で確認する。
+// ISBN・JAN・EAN13 が登録済みの書籍と同じ行は登録しないため、同じファイルを再度取り込んでも二重に登録されない。
+func (h *Handler) ImportBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	dryRun, err := formBool(c, "dry_run")
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	enrich, err := formBool(c, "enrich")
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	var mapping map[string]string
+	if v := c.PostForm("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &mapping); err != nil {
+			respondError(c, errInvalidMapping)
+			return
+		}
+	}
+	sheet := strings.TrimSpace(c.PostForm("sheet"))
+
+	data, filename, format, err := readImportFile(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	// 列の対応の誤りはジョブを登録する前に返す
+	table, err := parseBookImportTable(format, bytes.NewReader(data), int64(len(data)), sheet, mapping)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	// 再開するジョブが別のサーバーで動いてもファイルを読めるよう、保存先（ローカルまたは S3）に保存する
+	storageName := uuid.New().String() + "." + format
+	if err := h.blobs.Put(ctx, ImportsPrefix+storageName, bytes.NewReader(data), int64(len(data)), ""); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	now := time.Now()
+	job := models.ImportJob{
+		ID:          uuid.New(),
+		Filename:    filename,
+		StoragePath: storageName,
+		Format:      format,
+		Sheet:       sheet,
+		Mapping:     table.mapping,
+		DryRun:      dryRun,
+		Enrich:      enrich,
+		Status:      models.ImportStatusQueued,
+		TotalRows:   len(table.records),
+		CreatedBy:   currentViewer(c).userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.store.ImportJobs().Create(ctx, &job); err != nil {
+		h.deleteBlobs(ctx, ImportsPrefix+storageName)
+		respondInternalError(c, err)
+		return
+	}
+
+	h.startImport(job.ID)
+	respond(c, http.StatusOK, newImportJobResponse(job))
+}
+
+// GetImportJobs - 取り込みジョブの一覧（新しい順）
+func (h *Handler) GetImportJobs(c *gin.Context) {
+	page, err := parsePage(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	jobs, total, err := h.store.ImportJobs().List(c.Request.Context(), page)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	items := make([]ImportJobResponse, len(jobs))
+	for i, job := range jobs {
+		items[i] = newImportJobResponse(job)
+	}
+	respond(c, http.StatusOK, newPage(items, page, total))
+}
+
+// GetImportJob - 取り込みジョブの状態と進捗
+func (h *Handler) GetImportJob(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	job, err := h.store.ImportJobs().Get(c.Request.Context(), id)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errImportNotFound))
+		return
+	}
+	respond(c, http.StatusOK, newImportJobResponse(*job))
+}
+
+// GetImportRows - 取り込みジョブの行ごとの結果（行番号順）。status で結果の種類を絞り込める。
+func (h *Handler) GetImportRows(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	filter := repository.ImportRowFilter{JobID: id, Status: c.Query("status")}
+	switch filter.Status {
+	case "", models.ImportRowImported, models.ImportRowDuplicate, models.ImportRowError:
+	default:
+		respondError(c, errInvalidFilter)
+		return
+	}
+	var err error
+	if filter.Page, err = parsePage(c); err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	if _, err := h.store.ImportJobs().Get(ctx, id); err != nil {
+		respondErr(c, notFoundAs(err, errImportNotFound))
+		return
+	}
+	rows, total, err := h.store.ImportJobs().Rows(ctx, filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	items := make([]ImportRowResponse, len(rows))
+	for i, row := range rows {
+		items[i] = newImportRowResponse(c, row)
+	}
+	respond(c, http.StatusOK, newPage(items, filter.Page, total))
+}
+
+// ResumeImportJob - 失敗した取り込みジョブを未処理の行から再開する
+func (h *Handler) ResumeImportJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var job *models.ImportJob
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if job, err = tx.ImportJobs().Get(ctx, id); err != nil {
+			return notFoundAs(err, errImportNotFound)
+		}
+		if job.Status != models.ImportStatusFailed {
+			return errImportNotResumable
+		}
+		job.Status = models.ImportStatusQueued
+		job.UpdatedAt = time.Now()
+		return tx.ImportJobs().Update(ctx, job)
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	h.startImport(job.ID)
+	respond(c, http.StatusOK, newImportJobResponse(*job))
+}
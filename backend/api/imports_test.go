@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"lablib/models"
+	"lablib/storage"
+
+	"github.com/google/uuid"
+)
+
+// importBooks は CSV を取り込み、ジョブの終了を待って結果を返す
+func (s *testServer) importBooks(csv string, fields map[string]string) ImportJobResponse {
+	s.t.Helper()
+	rec := s.postForm("/api/v1/admin/books/import", fields, []formFile{{"file", "books.csv", []byte(csv)}}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var job ImportJobResponse
+	decode(s.t, rec, &job)
+	return s.waitImport(job.ID)
+}
+
+// waitImport は取り込みジョブが完了または失敗するまで待つ
+func (s *testServer) waitImport(id uuid.UUID) ImportJobResponse {
+	s.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job ImportJobResponse
+		rec := s.do("GET", "/api/v1/admin/books/import/"+id.String(), nil, s.adminToken)
+		expectStatus(s.t, rec, http.StatusOK)
+		decode(s.t, rec, &job)
+		if job.Status == models.ImportStatusCompleted || job.Status == models.ImportStatusFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("import job %s is still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// importRows は取り込みジョブの行ごとの結果を返す
+func (s *testServer) importRows(id uuid.UUID, query string) []ImportRowResponse {
+	s.t.Helper()
+	rec := s.do("GET", "/api/v1/admin/books/import/"+id.String()+"/rows"+query, nil, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var rows PageResponse[ImportRowResponse]
+	decode(s.t, rec, &rows)
+	return rows.Items
+}
+
+const testImportCSV = "書名,著者,ISBN,冊数\n" +
+	"新しい本,山田 太郎,978-4-87311-752-2,2\n" +
+	",著者のみ,,1\n" +
+	"登録済み,鈴木 花子,9784062748681,1\n" +
+	"同じ本,山田 太郎,9784873117522,1\n"
+
+func TestImportBooks(t *testing.T) {
+	s := newTestServer(t)
+	existing := s.createBook(models.Book{Title: "登録済み", Type: "book", TotalCopies: 1, ISBN: "9784062748681"})
+
+	// ドライランは結果のみで登録しない
+	job := s.importBooks(testImportCSV, map[string]string{"dry_run": "true"})
+	if job.Status != models.ImportStatusCompleted || !job.DryRun || job.TotalRows != 4 ||
+		job.ImportedRows != 1 || job.DuplicateRows != 2 || job.ErrorRows != 1 {
+		t.Fatalf("dry run = %+v", job)
+	}
+	if job.Mapping["title"] != "書名" || job.Mapping["isbn"] != "ISBN" || job.Mapping["total_copies"] != "冊数" {
+		t.Fatalf("mapping = %v", job.Mapping)
+	}
+	var books PageResponse[BookResponse]
+	decode(t, s.do("GET", "/api/v1/books?type=book", nil, ""), &books)
+	if books.Pagination.Total != 1 {
+		t.Fatalf("dry run created books: %+v", books.Items)
+	}
+
+	job = s.importBooks(testImportCSV, nil)
+	if job.Status != models.ImportStatusCompleted || job.ImportedRows != 1 || job.DuplicateRows != 2 || job.ErrorRows != 1 || job.FinishedAt == nil {
+		t.Fatalf("import = %+v", job)
+	}
+	rows := s.importRows(job.ID, "")
+	if len(rows) != 4 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	imported, invalid, duplicate, inFile := rows[0], rows[1], rows[2], rows[3]
+	if imported.RowNumber != 2 || imported.Status != models.ImportRowImported || imported.BookID == nil || imported.ISBN != "9784873117522" {
+		t.Fatalf("imported row = %+v", imported)
+	}
+	if invalid.Status != models.ImportRowError || len(invalid.Messages) != 1 {
+		t.Fatalf("invalid row = %+v", invalid)
+	}
+	if duplicate.Status != models.ImportRowDuplicate || duplicate.DuplicateOf == nil || *duplicate.DuplicateOf != existing {
+		t.Fatalf("duplicate row = %+v", duplicate)
+	}
+	// 同じファイルの前の行で登録した書籍とも重複する
+	if inFile.Status != models.ImportRowDuplicate || inFile.DuplicateOf == nil || *inFile.DuplicateOf != *imported.BookID {
+		t.Fatalf("duplicate in file row = %+v", inFile)
+	}
+	if detail := s.bookDetail(*imported.BookID); detail.Book.Author != "山田 太郎" || detail.Book.TotalCopies != 2 || detail.Book.AvailableCopies != 2 {
+		t.Fatalf("imported book = %+v", detail.Book)
+	}
+	if rows := s.importRows(job.ID, "?status=duplicate"); len(rows) != 2 {
+		t.Fatalf("duplicate rows = %+v", rows)
+	}
+	expectError(t, s.do("GET", "/api/v1/admin/books/import/"+job.ID.String()+"/rows?status=unknown", nil, s.adminToken), errInvalidFilter)
+
+	// 同じファイルをもう一度取り込んでも二重に登録しない
+	job = s.importBooks(testImportCSV, nil)
+	if job.ImportedRows != 0 || job.DuplicateRows != 3 {
+		t.Fatalf("second import = %+v", job)
+	}
+
+	var jobs PageResponse[ImportJobResponse]
+	decode(t, s.do("GET", "/api/v1/admin/books/import", nil, s.adminToken), &jobs)
+	if len(jobs.Items) != 3 || jobs.Items[0].ID != job.ID {
+		t.Fatalf("jobs = %+v", jobs.Items)
+	}
+}
+
+func TestImportBooksErrors(t *testing.T) {
+	s := newTestServer(t)
+	path := "/api/v1/admin/books/import"
+	csv := func(content string) []formFile { return []formFile{{"file", "books.csv", []byte(content)}} }
+
+	expectError(t, s.postForm(path, nil, nil, s.adminToken), errFileRequired)
+	expectError(t, s.postForm(path, nil, []formFile{{"file", "books.xls", []byte("title\n本\n")}}, s.adminToken), errUnsupportedImportType)
+	expectError(t, s.postForm(path, nil, csv("title\n"), s.adminToken), errEmptyImport)
+	expectError(t, s.postForm(path, nil, csv("著者\n山田\n"), s.adminToken), errInvalidMapping)
+	expectError(t, s.postForm(path, map[string]string{"mapping": `{"title": "題名"}`}, csv("title\n本\n"), s.adminToken), errInvalidMapping)
+	expectError(t, s.postForm(path, map[string]string{"mapping": `{"unknown": "title"}`}, csv("title\n本\n"), s.adminToken), errInvalidMapping)
+	expectError(t, s.postForm(path, map[string]string{"mapping": `{`}, csv("title\n本\n"), s.adminToken), errInvalidMapping)
+	expectError(t, s.do("GET", path+"/"+uuid.New().String(), nil, s.adminToken), errImportNotFound)
+
+	// 見出しの推定は mapping で上書きできる
+	job := s.importBooks("title,題名\n使わない,使う\n", map[string]string{"mapping": `{"title": "題名"}`})
+	if rows := s.importRows(job.ID, ""); len(rows) != 1 || rows[0].Title != "使う" {
+		t.Fatalf("rows = %+v", rows)
+	}
+}
+
+// 失敗したジョブは処理済みの行の後から再開する
+func TestResumeImport(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	storageName := uuid.New().String() + ".csv"
+	data := "title\n一冊目\n二冊目\n"
+	if err := s.blobs.Put(ctx, ImportsPrefix+storageName, strings.NewReader(data), int64(len(data)), ""); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	failed := models.ImportJob{ID: uuid.New(), Filename: "books.csv", StoragePath: storageName, Format: "csv",
+		Status: models.ImportStatusFailed, Error: "interrupted", TotalRows: 2, ProcessedRows: 1, ImportedRows: 1,
+		CreatedAt: now, UpdatedAt: now}
+	if err := s.store.ImportJobs().Create(ctx, &failed); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/v1/admin/books/import/" + failed.ID.String() + "/resume"
+	expectStatus(t, s.do("POST", path, nil, s.adminToken), http.StatusOK)
+	job := s.waitImport(failed.ID)
+	if job.Status != models.ImportStatusCompleted || job.ProcessedRows != 2 || job.ImportedRows != 2 || job.Error != "" {
+		t.Fatalf("resumed job = %+v", job)
+	}
+	if rows := s.importRows(job.ID, ""); len(rows) != 1 || rows[0].Title != "二冊目" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if _, err := s.blobs.Stat(ctx, ImportsPrefix+storageName); !errors.Is(err, storage.ErrNotExist) {
+		t.Fatalf("import file was not removed: %v", err)
+	}
+
+	expectError(t, s.do("POST", path, nil, s.adminToken), errImportNotResumable)
+	expectError(t, s.do("POST", "/api/v1/admin/books/import/"+uuid.New().String()+"/resume", nil, s.adminToken), errImportNotFound)
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"lablib/labels"
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxLabels は1回に印刷できるラベルの数
+const maxLabels = 1000
+
+// ラベル用紙のプリセット一覧API（管理者専用）
+func (h *Handler) GetLabelTemplates(c *gin.Context) {
+	templates := labels.Templates()
+	res := LabelTemplatesResponse{Templates: make([]LabelTemplateResponse, 0, len(templates)), Default: labels.DefaultTemplate}
+	for _, t := range templates {
+		res.Templates = append(res.Templates, newLabelTemplateResponse(t))
+	}
+	respond(c, http.StatusOK, res)
+}
+
+// ラベル用紙のPDF作成API（管理者専用）。
+// 保存済みバーコード・コピー（書籍を指定した場合はそのすべてのコピー）ごとにバーコード・番号・タイトル（幅に収まらない場合は省略）・配置場所を印刷する。
+func (h *Handler) PrintLabels(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req LabelSheetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	if len(req.BarcodeIDs)+len(req.CopyIDs)+len(req.BookIDs) == 0 {
+		respondError(c, errMissingFields)
+		return
+	}
+
+	template, err := labelTemplate(req)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	r := labelResolver{store: h.store, books: map[uuid.UUID]*models.Book{}}
+	var sheet []labels.Label
+	for _, id := range req.BarcodeIDs {
+		l, err := r.barcode(ctx, id)
+		if err != nil {
+			respondErr(c, err)
+			return
+		}
+		sheet = append(sheet, l)
+	}
+	for _, id := range req.CopyIDs {
+		l, err := r.copy(ctx, id)
+		if err != nil {
+			respondErr(c, err)
+			return
+		}
+		sheet = append(sheet, l)
+	}
+	for _, id := range req.BookIDs {
+		l, err := r.bookCopies(ctx, id)
+		if err != nil {
+			respondErr(c, err)
+			return
+		}
+		sheet = append(sheet, l...)
+	}
+	if len(sheet) > maxLabels {
+		respondError(c, errTooManyLabels)
+		return
+	}
+
+	// 作成に失敗した場合にエラーを返せるよう、書き込む前にすべてのページを作成する
+	var buf bytes.Buffer
+	err = labels.Render(&buf, template, sheet, labels.Options{Skip: req.Skip, Outline: req.Outline})
+	switch {
+	case errors.Is(err, labels.ErrInvalidCode):
+		respondError(c, errInvalidLabelCode)
+		return
+	case errors.Is(err, labels.ErrInvalidTemplate):
+		respondError(c, errInvalidLabelTemplate)
+		return
+	case err != nil:
+		respondInternalError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("labels-%s.pdf", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// labelTemplate はリクエストのラベル用紙を返す。プリセットがない・寸法が正しくない場合は errInvalidLabelTemplate を返す。
+func labelTemplate(req LabelSheetRequest) (labels.Template, error) {
+	var t labels.Template
+	if req.Layout != nil {
+		t = req.Layout.template()
+	} else {
+		name := req.Template
+		if name == "" {
+			name = labels.DefaultTemplate
+		}
+		var ok bool
+		if t, ok = labels.LookupTemplate(name); !ok {
+			return t, errInvalidLabelTemplate
+		}
+	}
+	if t.Validate() != nil || req.Skip < 0 || req.Skip >= t.PerPage() {
+		return t, errInvalidLabelTemplate
+	}
+	return t, nil
+}
+
+// labelResolver はラベルに印刷する書籍のタイトル・配置場所を求める（同じ書籍は1回だけ取得する）
+type labelResolver struct {
+	store repository.Store
+	books map[uuid.UUID]*models.Book
+}
+
+// book は id の書籍を返す。削除された書籍は nil を返す。
+func (r labelResolver) book(ctx context.Context, id uuid.UUID) (*models.Book, error) {
+	if b, ok := r.books[id]; ok {
+		return b, nil
+	}
+	b, err := r.store.Books().Get(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	r.books[id] = b
+	return b, nil
+}
+
+// barcode は保存済みバーコードのラベルを返す。
+// 関連付けた書籍・コピーがあればそのタイトル・配置場所、なければ生成時に指定したタイトルを使う。
+func (r labelResolver) barcode(ctx context.Context, id uuid.UUID) (labels.Label, error) {
+	b, err := r.store.Barcodes().Get(ctx, id)
+	if err != nil {
+		return labels.Label{}, notFoundAs(err, errBarcodeNotFound)
+	}
+	l := labels.Label{Code: b.Code, Title: b.Params.Title}
+	if b.BookID != nil {
+		book, err := r.book(ctx, *b.BookID)
+		if err != nil {
+			return l, err
+		}
+		if book != nil {
+			l.Title, l.Location = book.Title, book.Location
+		}
+	}
+	if b.CopyID != nil {
+		bc, err := r.store.Copies().Get(ctx, *b.CopyID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return l, err
+		}
+		if bc != nil && bc.Location != nil && *bc.Location != "" {
+			l.Location = *bc.Location
+		}
+	}
+	return l, nil
+}
+
+// copy はコピーのラベルを返す
+func (r labelResolver) copy(ctx context.Context, id uuid.UUID) (labels.Label, error) {
+	bc, err := r.store.Copies().Get(ctx, id)
+	if err != nil {
+		return labels.Label{}, notFoundAs(err, errCopyNotFound)
+	}
+	book, err := r.book(ctx, bc.BookID)
+	if err != nil {
+		return labels.Label{}, err
+	}
+	return copyLabel(*bc, book), nil
+}
+
+// bookCopies は書籍のすべてのコピーのラベルを返す
+func (r labelResolver) bookCopies(ctx context.Context, id uuid.UUID) ([]labels.Label, error) {
+	book, err := r.book(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if book == nil {
+		return nil, errBookNotFound
+	}
+	copies, err := r.store.Copies().ListByBook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]labels.Label, len(copies))
+	for i, bc := range copies {
+		list[i] = copyLabel(bc, book)
+	}
+	return list, nil
+}
+
+// copyLabel は書籍 book（削除された場合は nil）のコピー bc のラベルを返す。
+// コピーにバーコードがない場合は製造番号をバーコードにする。
+func copyLabel(bc models.BookCopy, book *models.Book) labels.Label {
+	l := labels.Label{Code: bc.Barcode}
+	if l.Code == "" {
+		l.Code = bc.SerialNumber
+	}
+	if book != nil {
+		l.Title, l.Location = book.Title, book.Location
+	}
+	if bc.Location != nil && *bc.Location != "" {
+		l.Location = *bc.Location
+	}
+	return l
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"lablib/labels"
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+func TestLabelTemplates(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do("GET", "/api/v1/admin/labels/templates", nil, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var res LabelTemplatesResponse
+	decode(t, rec, &res)
+	if res.Default != labels.DefaultTemplate || len(res.Templates) != len(labels.Templates()) {
+		t.Fatalf("templates = %+v", res)
+	}
+	for _, tmpl := range res.Templates {
+		if tmpl.PageWidth != labels.A4Width || tmpl.Columns < 1 || tmpl.Description == "" {
+			t.Errorf("template = %+v", tmpl)
+		}
+	}
+}
+
+func TestPrintLabels(t *testing.T) {
+	s := newTestServer(t)
+	book := s.createBook(models.Book{Title: "ラベルの書籍", Type: "book", TotalCopies: 2, Location: "棚A"})
+	barcode := s.generateThesisBarcode("2024", "123456")
+
+	for name, req := range map[string]LabelSheetRequest{
+		"book":    {BookIDs: []uuid.UUID{book}},
+		"barcode": {BarcodeIDs: []uuid.UUID{barcode.ID}, Template: "a4-65", Skip: 64, Outline: true},
+		"layout": {BookIDs: []uuid.UUID{book}, Layout: &LabelLayoutRequest{
+			Columns: 2, Rows: 5, LabelWidth: 90, LabelHeight: 50, MarginTop: 10, MarginLeft: 10, GapX: 5, GapY: 2}},
+	} {
+		rec := s.do("POST", "/api/v1/admin/labels", req, s.adminToken)
+		expectStatus(t, rec, http.StatusOK)
+		if rec.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-1.4")) ||
+			!strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment; filename=labels-") {
+			t.Fatalf("%s: %s, %q", name, rec.Header(), rec.Body.Bytes()[:min(rec.Body.Len(), 16)])
+		}
+	}
+
+	for _, tc := range []struct {
+		req  LabelSheetRequest
+		want apiError
+	}{
+		{LabelSheetRequest{}, errMissingFields},
+		{LabelSheetRequest{BookIDs: []uuid.UUID{book}, Template: "a4-99"}, errInvalidLabelTemplate},
+		{LabelSheetRequest{BookIDs: []uuid.UUID{book}, Skip: 24}, errInvalidLabelTemplate},
+		{LabelSheetRequest{BookIDs: []uuid.UUID{book}, Skip: -1}, errInvalidLabelTemplate},
+		{LabelSheetRequest{BookIDs: []uuid.UUID{book}, Layout: &LabelLayoutRequest{Columns: 3, Rows: 1, LabelWidth: 100, LabelHeight: 30}}, errInvalidLabelTemplate},
+		{LabelSheetRequest{BarcodeIDs: []uuid.UUID{uuid.New()}}, errBarcodeNotFound},
+		{LabelSheetRequest{CopyIDs: []uuid.UUID{uuid.New()}}, errCopyNotFound},
+		{LabelSheetRequest{BookIDs: []uuid.UUID{uuid.New()}}, errBookNotFound},
+	} {
+		expectError(t, s.do("POST", "/api/v1/admin/labels", tc.req, s.adminToken), tc.want)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// meHistoryLimit は GET /me に含める返却済みの貸出の件数（それより前の履歴は GET /me/history で取得する）
+const meHistoryLimit = 10
+
+// currentUser はトークンの user_id の利用者を返す。
+// ログインしていない場合は auth_required、利用停止・卒業・匿名化したユーザーの場合は account_inactive を返す。
+func currentUser(c *gin.Context, users repository.UserRepository) (*models.User, error) {
+	v := currentViewer(c)
+	if v.userID == nil {
+		return nil, errAuthRequired
+	}
+	user, err := users.Get(c.Request.Context(), *v.userID)
+	if err != nil {
+		return nil, notFoundAs(err, errAuthRequired)
+	}
+	if !user.Active() {
+		return nil, errAccountInactive
+	}
+	return user, nil
+}
+
+// GetMe - ログイン中の利用者のプロフィール・貸出中の書籍・最近の貸出履歴
+func (h *Handler) GetMe(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := currentUser(c, h.store.Users())
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	loans, _, err := h.store.Loans().List(ctx, repository.LoanFilter{
+		UserID: user.ID,
+		Status: "borrowed",
+		Sort:   repository.Sort{Field: "due_date"},
+	})
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	history, total, err := h.store.Loans().List(ctx, repository.LoanFilter{
+		UserID: user.ID,
+		Status: "returned",
+		Sort:   repository.Sort{Field: "returned_at", Desc: true},
+		Page:   repository.Page{Limit: meHistoryLimit},
+	})
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	now := time.Now()
+	overdue := 0
+	for _, l := range loans {
+		if l.DueDate.Before(now) {
+			overdue++
+		}
+	}
+	respond(c, http.StatusOK, MeResponse{
+		User:         newUserResponse(*user),
+		Loans:        newLoanResponses(loans),
+		OverdueCount: overdue,
+		History:      newLoanResponses(history),
+		HistoryTotal: total,
+	})
+}
+
+// UpdateMe - ログイン中の利用者の氏名（表示名）・メールアドレス・通知の設定の変更。省略した項目は変更しない。
+func (h *Handler) UpdateMe(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req UpdateMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	if req.Name != nil {
+		*req.Name = strings.TrimSpace(*req.Name)
+		if err := validateName(*req.Name); err != nil {
+			respondErr(c, err)
+			return
+		}
+	}
+	if req.Email != nil {
+		*req.Email = strings.TrimSpace(*req.Email)
+		if err := validateEmail(*req.Email); err != nil {
+			respondErr(c, err)
+			return
+		}
+	}
+
+	var user *models.User
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		if user, err = currentUser(c, tx.Users()); err != nil {
+			return err
+		}
+		if req.Name != nil {
+			user.Name = *req.Name
+		}
+		if req.Email != nil {
+			user.Email = *req.Email
+		}
+		if req.Notifications != nil {
+			user.Notifications = *req.Notifications
+		}
+		user.UpdatedAt = time.Now()
+		return tx.Users().Update(ctx, user)
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	respond(c, http.StatusOK, newUserResponse(*user))
+}
+
+// GetMyHistory - ログイン中の利用者の貸出履歴。GET /books/history と同じ条件で絞り込めるが、利用者は指定できない。
+func (h *Handler) GetMyHistory(c *gin.Context) {
+	user, err := currentUser(c, h.store.Users())
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter, err := parseLoanFilter(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	filter.UserID = user.ID
+
+	records, total, err := h.store.Loans().List(c.Request.Context(), filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, BorrowHistoryResponse{newPage(newLoanResponses(records), filter.Page, total)})
+}
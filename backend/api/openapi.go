@@ -0,0 +1,384 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Schema - OpenAPI 3.0 のスキーマオブジェクト（このAPIで使う範囲のみ）
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// OpenAPIDocument - OpenAPI 3.0 の文書
+type OpenAPIDocument struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Servers    []openAPIServer                  `json:"servers"`
+	Tags       []openAPITag                     `json:"tags"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type openAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type openAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Tags        []string             `json:"tags"`
+	Parameters  []parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// タグの説明（表示順）
+var openAPITags = []openAPITag{
+	{Name: "auth", Description: "認証"},
+	{Name: "me", Description: "ログイン中の利用者のアカウント"},
+	{Name: "books", Description: "書籍の検索・閲覧"},
+	{Name: "circulation", Description: "貸出・返却・延長"},
+	{Name: "theses", Description: "論文の閲覧・管理"},
+	{Name: "admin", Description: "管理者向けの書籍・ユーザー管理"},
+	{Name: "barcodes", Description: "卒論バーコード"},
+	{Name: "images", Description: "書籍画像・表紙画像の取得"},
+	{Name: "attachments", Description: "書籍の添付ファイル"},
+	{Name: "imports", Description: "CSV・XLSX からの書籍の一括取り込み・ユーザーの一括登録"},
+	{Name: "exports", Description: "書籍・貸出履歴・ユーザー・ランキングの書き出し"},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *OpenAPIDocument
+)
+
+// OpenAPI は routeTable から生成した OpenAPI 文書を返す。文書は初回呼び出し時に1度だけ生成される。
+func OpenAPI() *OpenAPIDocument {
+	openAPIOnce.Do(func() {
+		openAPIDoc = buildOpenAPI(routeTable)
+	})
+	return openAPIDoc
+}
+
+// buildOpenAPI は routes の定義とリクエスト・レスポンスの Go の型から文書を組み立てる
+func buildOpenAPI(routes []route) *OpenAPIDocument {
+	sb := &schemaBuilder{schemas: map[string]*Schema{}}
+	errorRef := sb.schemaFor(reflect.TypeOf(ErrorResponse{}))
+
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   "lablib API",
+			Version: "1.0.0",
+			Description: "研究室図書管理システムのAPI。この文書は /api/v1 のレスポンス形式を記述する。" +
+				"従来の /api も同じパスで利用できるが、一部のレスポンスは旧形式で返り、エラーは {\"error\": メッセージ} となる。",
+		},
+		Servers: []openAPIServer{
+			{URL: "/api/v1", Description: "型付きレスポンス"},
+		},
+		Tags:       openAPITags,
+		Paths:      map[string]map[string]*operation{},
+		Components: openAPIComponents{Schemas: sb.schemas},
+	}
+
+	for _, rt := range routes {
+		path, params := openAPIPath(rt)
+		op := &operation{
+			OperationID: handlerName(rt.handler),
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Parameters:  params,
+			Responses:   map[string]*response{},
+		}
+
+		for _, q := range rt.query {
+			op.Parameters = append(op.Parameters, parameter{
+				Name:        q.name,
+				In:          "query",
+				Description: q.description,
+				Required:    q.required,
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		// JSON と multipart のどちらでも受け付けるルートは両方を列挙する
+		if rt.request != nil || len(rt.form) > 0 {
+			op.RequestBody = &requestBody{Required: true, Content: map[string]*mediaType{}}
+		}
+		if rt.request != nil {
+			op.RequestBody.Content["application/json"] = &mediaType{Schema: sb.schemaFor(reflect.TypeOf(rt.request))}
+		}
+		if len(rt.form) > 0 {
+			form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			for _, f := range rt.form {
+				if f.text {
+					form.Properties[f.name] = &Schema{Type: "string", Description: f.description}
+					continue
+				}
+				form.Properties[f.name] = &Schema{Type: "string", Format: "binary", Description: f.description}
+				form.Required = append(form.Required, f.name)
+			}
+			op.RequestBody.Content["multipart/form-data"] = &mediaType{Schema: form}
+		}
+
+		success := &response{Description: "成功"}
+		switch {
+		case rt.contentType != "":
+			success.Content = map[string]*mediaType{}
+			for _, ct := range strings.Split(rt.contentType, ",") {
+				success.Content[strings.TrimSpace(ct)] = &mediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+			}
+			if rt.response != nil {
+				success.Content["application/json"] = &mediaType{Schema: sb.schemaFor(reflect.TypeOf(rt.response))}
+			}
+		case rt.response != nil:
+			success.Content = map[string]*mediaType{
+				"application/json": {Schema: sb.schemaFor(reflect.TypeOf(rt.response))},
+			}
+		}
+		op.Responses["200"] = success
+		if rt.ranges {
+			op.Responses["206"] = &response{Description: "Range で指定した部分", Content: success.Content}
+			op.Responses["304"] = &response{Description: "If-None-Match・If-Modified-Since に一致（本文なし）"}
+			op.Responses["416"] = &response{Description: "Range が範囲外（本文なし）"}
+		}
+		if rt.redirect {
+			op.Responses["302"] = &response{Description: "保存先の署名付き URL（Location、有効期限5分）へのリダイレクト"}
+		}
+
+		// エラーはステータスごとにまとめ、返しうるコードを説明に列挙する
+		codes := map[int][]string{}
+		errs := rt.errors
+		if rt.admin {
+			// 管理者専用ルートはハンドラーの前に AuthMiddleware・AdminMiddleware で拒否しうる
+			errs = append([]apiError{errAuthRequired, errAdminRequired, errAccountInactive}, errs...)
+		}
+		for _, e := range errs {
+			if !slices.Contains(codes[e.Status], e.Code) {
+				codes[e.Status] = append(codes[e.Status], e.Code)
+			}
+		}
+		codes[errInternal.Status] = append(codes[errInternal.Status], errInternal.Code)
+		for status, list := range codes {
+			op.Responses[strconv.Itoa(status)] = &response{
+				Description: http.StatusText(status) + ": " + strings.Join(list, ", "),
+				Content:     map[string]*mediaType{"application/json": {Schema: errorRef}},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*operation{}
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+	return doc
+}
+
+var ginParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// openAPIPath は gin 形式のパスを OpenAPI 形式に変換し、パスパラメーターを返す
+func openAPIPath(rt route) (string, []parameter) {
+	path := rt.path
+	if rt.admin {
+		path = "/admin" + path
+	}
+
+	var params []parameter
+	for _, m := range ginParam.FindAllStringSubmatch(path, -1) {
+		p := parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		if m[1] == "id" {
+			p.Schema.Format = "uuid"
+		}
+		params = append(params, p)
+	}
+	return ginParam.ReplaceAllString(path, "{$1}"), params
+}
+
+// handlerName は operationId に使うハンドラーのメソッド名を返す
+func handlerName(fn func(*Handler, *gin.Context)) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// schemaBuilder は Go の型を JSON タグに従ってスキーマに変換する。
+// 名前付きの構造体は components/schemas に登録し、$ref で参照する。
+type schemaBuilder struct {
+	schemas map[string]*Schema
+}
+
+func (sb *schemaBuilder) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner := sb.schemaFor(t.Elem())
+		if inner.Ref != "" {
+			return &Schema{AllOf: []*Schema{inner}, Nullable: true}
+		}
+		inner.Nullable = true
+		return inner
+	case reflect.Struct:
+		name := schemaName(t)
+		if name == "" {
+			return sb.structSchema(t)
+		}
+		if _, ok := sb.schemas[name]; !ok {
+			// 再帰的な型に備えて先に登録しておく
+			sb.schemas[name] = &Schema{}
+			*sb.schemas[name] = *sb.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: sb.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: sb.schemaFor(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		// interface{} など任意の値
+		return &Schema{}
+	}
+}
+
+// structSchema は構造体のフィールドを properties に展開する。埋め込みフィールドは親に平坦化する。
+func (sb *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			embedded := sb.structSchema(f.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = sb.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// schemaName は components/schemas で使う型名を返す。
+// ジェネリック型は "ListResponse[lablib/api.BookResponse]" を "BookResponseList" のように変換する。
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	base, arg, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+	arg = strings.TrimSuffix(arg, "]")
+	if i := strings.LastIndex(arg, "."); i >= 0 {
+		arg = arg[i+1:]
+	}
+	return arg + strings.TrimSuffix(base, "Response")
+}
+
+// ServeOpenAPI - OpenAPI 文書を返す
+func ServeOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPI())
+}
+
+//go:embed docs/index.html
+var docsPage []byte
+
+// ServeDocs - OpenAPI 文書を表示するAPIリファレンスのページ
+func ServeDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 一覧系エンドポイントの件数の既定値と上限
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// parsePage は limit・offset クエリパラメーターを読み取る。
+// 従来の /api では既存クライアントとの互換のため、limit の指定がなければ全件を返す。
+func parsePage(c *gin.Context) (repository.Page, error) {
+	page := repository.Page{Limit: defaultPageLimit}
+	if isLegacy(c) {
+		page.Limit = 0
+	}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return page, errInvalidPagination
+		}
+		page.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, errInvalidPagination
+		}
+		page.Offset = n
+	}
+	return page, nil
+}
+
+// parseSort は sort クエリパラメーター（"title" や降順の "-created_at"）を allowed と照合して読み取る
+func parseSort(c *gin.Context, allowed []string) (repository.Sort, error) {
+	v := c.Query("sort")
+	if v == "" {
+		return repository.Sort{}, nil
+	}
+	sort := repository.Sort{Field: strings.TrimPrefix(v, "-"), Desc: strings.HasPrefix(v, "-")}
+	for _, field := range allowed {
+		if field == sort.Field {
+			return sort, nil
+		}
+	}
+	return sort, errInvalidSort
+}
+
+// queryBool は true/false のクエリパラメーターを読み取る。指定がなければ nil を返す。
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, errInvalidFilter
+	}
+	return &b, nil
+}
+
+// queryUUID は UUID のクエリパラメーターを読み取る。指定がなければ uuid.Nil を返す。
+func queryUUID(c *gin.Context, name string) (uuid.UUID, error) {
+	v := c.Query(name)
+	if v == "" {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.Nil, errInvalidFilter
+	}
+	return id, nil
+}
+
+// queryTime は日付（YYYY-MM-DD）または RFC3339 形式のクエリパラメーターを読み取る。
+// 日付のみの場合はローカル時刻のその日の0時、endOfDay が true なら翌日の0時とする。
+func queryTime(c *gin.Context, name string, endOfDay bool) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errInvalidFilter
+	}
+	return t, nil
+}
+
+// newPagination は取得範囲と総件数からページングの情報を作る
+func newPagination(page repository.Page, count, total int) Pagination {
+	return Pagination{
+		Total:   total,
+		Limit:   page.Limit,
+		Offset:  page.Offset,
+		HasMore: page.Offset+count < total,
+	}
+}
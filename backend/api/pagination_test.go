@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"lablib/models"
+)
+
+func TestBookListPaging(t *testing.T) {
+	s := newTestServer(t)
+	for _, title := range []string{"C言語入門", "A言語入門", "B言語入門"} {
+		s.createBook(models.Book{Title: title, Author: "著者", Type: "book", TotalCopies: 1, Location: "A-1"})
+	}
+	s.createBook(models.Book{Title: "D論文", Author: "著者", Type: "thesis", TotalCopies: 1, Location: "B-1"})
+
+	list := func(query string) PageResponse[BookResponse] {
+		t.Helper()
+		rec := s.do("GET", "/api/v1/books?"+query, nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		var page PageResponse[BookResponse]
+		decode(t, rec, &page)
+		return page
+	}
+	titles := func(page PageResponse[BookResponse]) []string {
+		var titles []string
+		for _, b := range page.Items {
+			titles = append(titles, b.Title)
+		}
+		return titles
+	}
+
+	page := list("type=book&sort=title&limit=2")
+	if got := titles(page); len(got) != 2 || got[0] != "A言語入門" || got[1] != "B言語入門" {
+		t.Fatalf("first page = %v", got)
+	}
+	if page.Pagination != (Pagination{Total: 3, Limit: 2, Offset: 0, HasMore: true}) {
+		t.Fatalf("pagination = %+v", page.Pagination)
+	}
+	page = list("type=book&sort=title&limit=2&offset=2")
+	if got := titles(page); len(got) != 1 || got[0] != "C言語入門" || page.Pagination.HasMore {
+		t.Fatalf("second page = %v, %+v", got, page.Pagination)
+	}
+	if got := titles(list("sort=-title&location=B-1")); len(got) != 1 || got[0] != "D論文" {
+		t.Fatalf("location filter = %v", got)
+	}
+	if page = list(""); page.Pagination.Total != 4 || page.Pagination.Limit != defaultPageLimit {
+		t.Fatalf("default pagination = %+v", page.Pagination)
+	}
+
+	for _, query := range []string{"limit=0", "limit=201", "limit=x", "offset=-1"} {
+		expectError(t, s.do("GET", "/api/v1/books?"+query, nil, ""), errInvalidPagination)
+	}
+	expectError(t, s.do("GET", "/api/v1/books?sort=password", nil, ""), errInvalidSort)
+	expectError(t, s.do("GET", "/api/v1/books?available=maybe", nil, ""), errInvalidFilter)
+
+	// 従来の /api は limit の指定がなければ全件を配列で返す
+	rec := s.do("GET", "/api/books", nil, "")
+	var legacy []BookResponse
+	decode(t, rec, &legacy)
+	if len(legacy) != 4 {
+		t.Fatalf("legacy list = %d books", len(legacy))
+	}
+}
+
+func TestHistoryFilters(t *testing.T) {
+	s := newTestServer(t)
+	s.createBook(models.Book{Title: "返却済み", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "PG-0001"})
+	s.createBook(models.Book{Title: "貸出中", Author: "著者", Type: "book", TotalCopies: 1, Barcode: "PG-0002"})
+	for _, barcode := range []string{"PG-0001", "PG-0002"} {
+		expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: barcode, UserID: s.user.ID.String()}, ""), http.StatusOK)
+	}
+	expectStatus(t, s.do("POST", "/api/v1/books/return", BorrowRequest{Barcode: "PG-0001", UserID: s.user.ID.String()}, ""), http.StatusOK)
+
+	history := func(query string) []LoanResponse {
+		t.Helper()
+		rec := s.do("GET", "/api/v1/books/history?"+query, nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		var res BorrowHistoryResponse
+		decode(t, rec, &res)
+		return res.Items
+	}
+	if items := history("status=returned"); len(items) != 1 || items[0].BookTitle != "返却済み" {
+		t.Fatalf("returned = %+v", items)
+	}
+	if items := history("user_id=" + s.user.ID.String() + "&sort=book_title"); len(items) != 2 || items[0].BookTitle != "貸出中" {
+		t.Fatalf("user history = %+v", items)
+	}
+	if items := history("user_id=" + s.admin.ID.String()); len(items) != 0 {
+		t.Fatalf("admin history = %+v", items)
+	}
+	if items := history("overdue=true"); len(items) != 0 {
+		t.Fatalf("overdue = %+v", items)
+	}
+	if items := history("from=2000-01-01&to=2000-01-31"); len(items) != 0 {
+		t.Fatalf("history in 2000 = %+v", items)
+	}
+	for _, query := range []string{"user_id=x", "from=yesterday", "overdue=maybe"} {
+		expectError(t, s.do("GET", "/api/v1/books/history?"+query, nil, ""), errInvalidFilter)
+	}
+}
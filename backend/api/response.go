@@ -0,0 +1,43 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// APIバージョン。/api は既存フロントエンド向けの従来形式、/api/v1 は型付きレスポンスとエラーエンベロープを返す。
+const (
+	VersionLegacy = "legacy"
+	VersionV1     = "v1"
+
+	versionKey = "api_version"
+)
+
+// WithVersion はルートグループのAPIバージョンをコンテキストに設定する
+func WithVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionKey, version)
+		c.Next()
+	}
+}
+
+func isLegacy(c *gin.Context) bool {
+	return c.GetString(versionKey) == VersionLegacy
+}
+
+// legacyShaper は従来の /api で返していた形式に変換できるレスポンス
+type legacyShaper interface {
+	legacy() interface{}
+}
+
+// respond はレスポンスを書き込む。従来の /api では legacyShaper による変換を行う。
+func respond(c *gin.Context, status int, body interface{}) {
+	if l, ok := body.(legacyShaper); ok && isLegacy(c) {
+		body = l.legacy()
+	}
+	c.JSON(status, body)
+}
+
+// respondMessage はメッセージのみのレスポンスを書き込む
+func respondMessage(c *gin.Context, status int, key string) {
+	respond(c, status, MessageResponse{Message: message(c, key)})
+}
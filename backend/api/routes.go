@@ -0,0 +1,695 @@
+package api
+
+import (
+	"strings"
+
+	"lablib/circulation"
+	"lablib/middleware"
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// route - エンドポイント1件の定義。
+// RegisterRoutes によるルーティングと OpenAPI 文書の生成はどちらもこの定義から行う。
+type route struct {
+	method  string
+	path    string // グループからの相対パス（gin 形式）
+	public  bool   // 認証不要のルート
+	admin   bool   // /admin 配下のルート
+	handler func(*Handler, *gin.Context)
+
+	summary string
+	tag     string
+	query   []queryParam
+	// request はリクエストボディの型のゼロ値。multipart の場合は form を指定する（両方を受け付ける場合は両方）。
+	request interface{}
+	form    []formField
+	// response は成功時のレスポンスの型のゼロ値。contentType を指定した場合はバイナリを返す
+	// （クエリパラメーターで形式を選べる場合はカンマ区切りで列挙する。response も指定した場合は JSON も返す）。
+	response    interface{}
+	contentType string
+	// ranges はバイナリの Range・条件付きリクエストに対応する（206・304・416を返しうる）
+	ranges bool
+	// redirect は保存先が S3 の場合に署名付き URL へリダイレクトする（302を返しうる）
+	redirect bool
+	errors   []apiError
+}
+
+// queryParam - クエリパラメーター
+type queryParam struct {
+	name        string
+	description string
+	required    bool
+}
+
+// formField - multipart/form-data の項目。text でない項目はファイルとして必須にする。
+type formField struct {
+	name        string
+	description string
+	text        bool
+}
+
+// routeKey は gin.Context に現在のルート定義を保存するキー
+const routeKey = "api_route"
+
+// routeTable - 全エンドポイントの定義。パスはグループ（/api・/api/v1）からの相対パス。
+var routeTable = []route{
+	// 認証
+	{
+		method: "POST", path: "/auth/login", public: true, handler: (*Handler).Login,
+		summary: "ログイン", tag: "auth",
+		request: models.LoginRequest{}, response: models.LoginResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidCredentials, errAccountInactive},
+	},
+	{
+		method: "POST", path: "/auth/register", public: true, handler: (*Handler).Register,
+		summary: "ユーザー登録", tag: "auth",
+		request: models.RegisterRequest{}, response: models.UserResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidUserStudentID, errInvalidName, errInvalidEmail, errDuplicateStudentID},
+	},
+	{
+		method: "POST", path: "/auth/invite", public: true, handler: (*Handler).AcceptInvite,
+		summary: "招待のトークンによるパスワードの設定とログイン", tag: "auth",
+		request: models.AcceptInviteRequest{}, response: models.LoginResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidPassword, errInvalidInvite, errAccountInactive},
+	},
+
+	// マイアカウント
+	{
+		method: "GET", path: "/me", handler: (*Handler).GetMe,
+		summary: "ログイン中の利用者のプロフィール・貸出中の書籍・最近の貸出履歴・予約・延滞料金（予約と延滞料金は未実装のため空の一覧と 0）", tag: "me",
+		response: MeResponse{},
+		errors:   []apiError{errAuthRequired, errAccountInactive},
+	},
+	{
+		method: "PUT", path: "/me", handler: (*Handler).UpdateMe,
+		summary: "氏名・メールアドレス・通知の設定の変更", tag: "me",
+		request: UpdateMeRequest{}, response: models.UserResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidName, errInvalidEmail, errAuthRequired, errAccountInactive},
+	},
+	{
+		method: "GET", path: "/me/history", handler: (*Handler).GetMyHistory,
+		summary: "ログイン中の利用者の貸出履歴", tag: "me",
+		query: listQuery(repository.LoanSortFields,
+			queryParam{name: "book_id", description: "書籍ID"},
+			queryParam{name: "status", description: "borrowed または returned"},
+			queryParam{name: "from", description: "貸出日の下限（YYYY-MM-DD または RFC3339）"},
+			queryParam{name: "to", description: "貸出日の上限（YYYY-MM-DD の場合はその日を含む）"},
+			queryParam{name: "overdue", description: "true: 返却期限を過ぎた未返却の貸出のみ"}),
+		response: BorrowHistoryResponse{},
+		errors:   []apiError{errAuthRequired, errAccountInactive, errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+
+	// 図書管理
+	{
+		method: "GET", path: "/books", handler: (*Handler).GetBooks,
+		summary: "書籍一覧・検索", tag: "books",
+		query:    bookListQuery(),
+		response: PageResponse[BookResponse]{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+	{
+		method: "GET", path: "/books/search", handler: (*Handler).SearchBooks,
+		summary: "ファセット付きの書籍検索", tag: "books",
+		query:    bookListQuery(),
+		response: BookSearchResponse{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+	{
+		method: "GET", path: "/books/suggest", handler: (*Handler).SuggestBooks,
+		summary: "タイトル・著者の入力補完", tag: "books",
+		query: []queryParam{
+			{name: "q", description: "入力中の検索語（GET /books の query と同じ形式）"},
+			{name: "limit", description: "候補の最大件数（既定値10、20を超える指定は20とする）"},
+		},
+		response: SuggestResponse{},
+		errors:   []apiError{errInvalidPagination},
+	},
+	{
+		method: "GET", path: "/books/fetch-info", handler: (*Handler).FetchBookInfo,
+		summary: "ISBNから書誌情報を取得", tag: "books",
+		query:    []queryParam{{name: "isbn", description: "ISBN（ISBN-10 または ISBN-13。ハイフンは省略可）", required: true}},
+		response: BookInfoResponse{},
+		errors:   []apiError{errMissingFields, errInvalidISBN, errBookPriceCode, errBookInfoNotFound, errUpstream},
+	},
+	{
+		method: "GET", path: "/books/:id", handler: (*Handler).GetBookDetails,
+		summary: "書籍詳細", tag: "books",
+		response: BookDetailResponse{},
+		errors:   []apiError{errInvalidID, errBookNotFound},
+	},
+	{
+		method: "GET", path: "/books/:id/image", handler: (*Handler).GetBookImage,
+		summary: "書籍画像の取得", tag: "images",
+		query: []queryParam{
+			{name: "size", description: "画像の大きさ（thumb: 長辺200px、medium: 長辺800px、original: 元の大きさ。既定値 original）"},
+		},
+		contentType: "image/*", ranges: true, redirect: true,
+		errors: []apiError{errInvalidImageSize, errInvalidID, errBookNotFound, errImageNotFound},
+	},
+	{
+		method: "GET", path: "/books/borrow-record/:id", handler: (*Handler).GetBorrowRecordDetails,
+		summary: "貸出記録の詳細", tag: "circulation",
+		response: LoanResponse{},
+		errors:   []apiError{errInvalidID, errLoanNotFound},
+	},
+	{
+		method: "POST", path: "/books/borrow", handler: (*Handler).BorrowBook,
+		summary: "バーコード指定の貸出", tag: "circulation",
+		request: BorrowRequest{}, response: CheckoutResponse{},
+		errors: withDomainErrors([]apiError{errInvalidRequest, errMissingFields, errInvalidID}, circulation.ErrUserNotFound, circulation.ErrUserInactive, circulation.ErrNoAvailableCopy, circulation.ErrBookWithdrawn),
+	},
+	{
+		method: "POST", path: "/books/quick-borrow", handler: (*Handler).QuickBorrowBook,
+		summary: "書籍ID指定の貸出（デフォルトユーザー）", tag: "circulation",
+		request: QuickBorrowRequest{}, response: CheckoutResponse{},
+		errors: withDomainErrors([]apiError{errInvalidRequest, errMissingFields, errInvalidID}, circulation.ErrUserInactive, circulation.ErrNoAvailableCopy, circulation.ErrBookWithdrawn),
+	},
+	{
+		method: "POST", path: "/books/return", handler: (*Handler).ReturnBook,
+		summary: "返却", tag: "circulation",
+		request: BorrowRequest{}, response: ReturnResponse{},
+		errors: withDomainErrors([]apiError{errInvalidRequest, errMissingFields, errInvalidID}, circulation.ErrCopyNotFound, circulation.ErrLoanNotFound),
+	},
+	{
+		method: "POST", path: "/books/renew", handler: (*Handler).RenewBook,
+		summary: "貸出期限の延長", tag: "circulation",
+		request: RenewRequest{}, response: RenewResponse{},
+		errors: withDomainErrors([]apiError{errInvalidRequest, errInvalidID}, circulation.ErrLoanNotFound, circulation.ErrUserInactive, circulation.ErrAlreadyReturned, circulation.ErrOverdue, circulation.ErrRenewLimitReached),
+	},
+	{
+		method: "GET", path: "/books/history", handler: (*Handler).GetBorrowHistory,
+		summary: "貸出履歴", tag: "circulation",
+		query: listQuery(repository.LoanSortFields,
+			queryParam{name: "user_id", description: "利用者ID"},
+			queryParam{name: "book_id", description: "書籍ID"},
+			queryParam{name: "status", description: "borrowed または returned"},
+			queryParam{name: "from", description: "貸出日の下限（YYYY-MM-DD または RFC3339）"},
+			queryParam{name: "to", description: "貸出日の上限（YYYY-MM-DD の場合はその日を含む）"},
+			queryParam{name: "overdue", description: "true: 返却期限を過ぎた未返却の貸出のみ"}),
+		response: BorrowHistoryResponse{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+
+	// 管理者専用
+	{
+		method: "POST", path: "/books", admin: true, handler: (*Handler).CreateBook,
+		summary: "書籍登録", tag: "admin",
+		query: []queryParam{
+			{name: "fetch_cover", description: "true: ISBN から外部サービスの表紙画像を取得して書籍画像にする（取得できなくても登録は行う）"},
+		},
+		request: models.Book{}, response: SavedBookResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidPublishedDate, errInvalidLanguage, errInvalidAuthorRole,
+			errInvalidISBN, errInvalidJAN, errInvalidEAN13, errBookPriceCode, errInvalidFilter},
+	},
+	{
+		method: "PUT", path: "/books/:id", admin: true, handler: (*Handler).UpdateBook,
+		summary: "書籍情報の更新", tag: "admin",
+		request: UpdateBookRequest{}, response: BookUpdatedResponse{},
+		errors: []apiError{errInvalidID, errInvalidRequest, errMissingFields, errInvalidCopyCount,
+			errInvalidPublishedDate, errInvalidLanguage, errInvalidAuthorRole, errInvalidISBN, errInvalidJAN, errInvalidEAN13,
+			errBookPriceCode, errBookNotFound, errCopiesOnLoan},
+	},
+	{
+		method: "DELETE", path: "/books/:id", admin: true, handler: (*Handler).DeleteBook,
+		summary: "書籍の除籍（検索・貸出の対象外にし、貸出の履歴は残す）", tag: "admin",
+		query:    []queryParam{{name: "reason", description: "除籍の理由"}},
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errBookNotFound, errBookOnLoan},
+	},
+	{
+		method: "GET", path: "/books/withdrawn", admin: true, handler: (*Handler).GetWithdrawnBooks,
+		summary: "除籍した書籍の一覧", tag: "admin",
+		query:    bookListQuery(),
+		response: PageResponse[BookResponse]{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+	{
+		method: "POST", path: "/books/:id/restore", admin: true, handler: (*Handler).RestoreBook,
+		summary: "除籍した書籍の復元", tag: "admin",
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errBookNotFound, errBookNotWithdrawn},
+	},
+	{
+		method: "DELETE", path: "/books/:id/purge", admin: true, handler: (*Handler).PurgeBook,
+		summary: "除籍した書籍の完全な削除（貸出の履歴・ファイルも削除する）", tag: "admin",
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errBookNotFound, errBookNotWithdrawn, errBookOnLoan},
+	},
+	{
+		method: "POST", path: "/users", admin: true, handler: (*Handler).CreateUser,
+		summary: "ユーザー作成", tag: "admin",
+		request: models.RegisterRequest{}, response: models.UserResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidUserStudentID, errInvalidName, errInvalidEmail, errDuplicateStudentID},
+	},
+	{
+		method: "DELETE", path: "/users/:id", admin: true, handler: (*Handler).DeleteUser,
+		summary: "ユーザー削除（貸出の履歴を残すため匿名化する）", tag: "admin",
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errUserNotFound, errUserHasOpenLoans, errCannotDeactivateSelf},
+	},
+	{
+		method: "GET", path: "/users/:id", admin: true, handler: (*Handler).GetUser,
+		summary: "ユーザーの詳細と貸出の概要", tag: "admin",
+		response: UserDetailResponse{},
+		errors:   []apiError{errInvalidID, errUserNotFound},
+	},
+	{
+		method: "PUT", path: "/users/:id", admin: true, handler: (*Handler).UpdateUser,
+		summary: "ユーザーの学籍番号・氏名・メールアドレス・ロール・状態の変更", tag: "admin",
+		request: UpdateUserRequest{}, response: UserDetailResponse{},
+		errors: []apiError{errInvalidID, errInvalidRequest, errInvalidUserStudentID, errInvalidName, errInvalidEmail,
+			errInvalidRole, errInvalidUserStatus, errUserNotFound, errDuplicateStudentID, errUserHasOpenLoans,
+			errCannotDeactivateSelf, errCannotDemoteSelf, errAnonymizedUser},
+	},
+	{
+		method: "PUT", path: "/users/:id/status", admin: true, handler: (*Handler).SetUserStatus,
+		summary: "ユーザーの利用停止・卒業・利用再開", tag: "admin",
+		request: UserStatusRequest{}, response: models.UserResponse{},
+		errors: []apiError{errInvalidID, errInvalidRequest, errInvalidUserStatus, errUserNotFound,
+			errUserHasOpenLoans, errCannotDeactivateSelf, errAnonymizedUser},
+	},
+	{
+		method: "GET", path: "/users", admin: true, handler: (*Handler).GetUsers,
+		summary: "ユーザー一覧", tag: "admin",
+		query: listQuery(repository.UserSortFields,
+			queryParam{name: "query", description: "学籍番号・氏名の部分一致"},
+			queryParam{name: "role", description: "user または admin"},
+			queryParam{name: "status", description: "active・deactivated・graduated"}),
+		response: PageResponse[models.UserResponse]{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+	{
+		method: "GET", path: "/rankings", admin: true, handler: (*Handler).GetMonthlyRankings,
+		summary: "月間ランキング", tag: "admin",
+		query:    []queryParam{{name: "month", description: "対象月（YYYY-MM、省略時は今月）"}},
+		response: ListResponse[MonthlyRankingResponse]{},
+	},
+	{
+		method: "GET", path: "/rankings/all-time", admin: true, handler: (*Handler).GetAllTimeRankings,
+		summary: "全期間ランキング", tag: "admin",
+		response: ListResponse[RankingResponse]{},
+	},
+
+	// 論文
+	{
+		method: "GET", path: "/theses", handler: (*Handler).ListTheses,
+		summary: "論文の一覧", tag: "theses",
+		query: listQuery(repository.ThesisSortFields,
+			queryParam{name: "query", description: "検索語（GET /books の query と同じ形式）"},
+			queryParam{name: "academic_year", description: "年度"},
+			queryParam{name: "supervisor", description: "指導教員（完全一致）"},
+			queryParam{name: "degree", description: "学位: " + strings.Join(models.Degrees, ", ")}),
+		response: PageResponse[ThesisResponse]{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidAcademicYear, errInvalidDegree},
+	},
+	{
+		method: "GET", path: "/theses/index", handler: (*Handler).GetThesisIndex,
+		summary: "年度・指導教員ごとの論文数", tag: "theses",
+		response: ThesisIndexResponse{},
+	},
+	{
+		method: "GET", path: "/theses/:id", handler: (*Handler).GetThesis,
+		summary: "論文の詳細", tag: "theses",
+		response: ThesisResponse{},
+		errors:   []apiError{errInvalidID, errThesisNotFound},
+	},
+	{
+		method: "GET", path: "/theses/:id/pdf", handler: (*Handler).GetThesisPDF,
+		summary: "論文PDFの取得", tag: "theses",
+		contentType: "application/pdf", ranges: true, redirect: true,
+		errors: []apiError{errInvalidID, errThesisNotFound, errPDFNotFound},
+	},
+	{
+		method: "PUT", path: "/theses/:id", admin: true, handler: (*Handler).UpdateThesis,
+		summary: "論文情報の更新", tag: "theses",
+		request: UpdateThesisRequest{}, response: MessageResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidID, errMissingFields, errBookNotFound, errNotThesis,
+			errInvalidAcademicYear, errInvalidDegree, errInvalidStudentID, errDuplicateThesis},
+	},
+	{
+		method: "POST", path: "/theses/:id/pdf", admin: true, handler: (*Handler).UploadThesisPDF,
+		summary: "論文PDFのアップロード", tag: "theses",
+		form:     []formField{{name: "pdf", description: "PDF（最大50MB）"}},
+		response: PDFUploadResponse{},
+		errors:   []apiError{errInvalidID, errThesisNotFound, errPDFRequired, errPDFTooLarge, errUnsupportedPDFType},
+	},
+	{
+		method: "DELETE", path: "/theses/:id/pdf", admin: true, handler: (*Handler).DeleteThesisPDF,
+		summary: "論文PDFの削除", tag: "theses",
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errThesisNotFound, errPDFNotFound},
+	},
+
+	// 添付ファイル
+	{
+		method: "GET", path: "/books/:id/attachments", handler: (*Handler).GetBookAttachments,
+		summary: "書籍の添付ファイル一覧（閲覧できる公開範囲のもののみ）", tag: "attachments",
+		response: ListResponse[AttachmentResponse]{},
+		errors:   []apiError{errInvalidID, errBookNotFound},
+	},
+	{
+		method: "GET", path: "/attachments/:id", handler: (*Handler).DownloadAttachment,
+		summary: "添付ファイルのダウンロード", tag: "attachments",
+		query:       []queryParam{{name: "inline", description: "true: ブラウザで表示する（Content-Disposition: inline）"}},
+		contentType: "application/octet-stream", ranges: true, redirect: true,
+		errors: []apiError{errInvalidID, errLoginRequired, errAttachmentNotFound},
+	},
+	{
+		method: "POST", path: "/books/:id/attachments", admin: true, handler: (*Handler).UploadAttachment,
+		summary: "添付ファイルのアップロード", tag: "attachments",
+		form: []formField{
+			{name: "file", description: "PDF・Office文書（docx・xlsx・pptx）・画像・テキスト・ZIP（最大100MB）"},
+			{name: "visibility", description: "公開範囲: " + strings.Join(models.Visibilities, ", ") + "（既定は lab）", text: true},
+			{name: "description", description: "説明", text: true},
+		},
+		response: AttachmentResponse{},
+		errors: []apiError{errInvalidID, errBookNotFound, errFileRequired, errFileTooLarge,
+			errUnsupportedFileType, errInvalidVisibility, errFileRejected},
+	},
+	{
+		method: "PUT", path: "/attachments/:id", admin: true, handler: (*Handler).UpdateAttachment,
+		summary: "添付ファイルの更新", tag: "attachments",
+		request: UpdateAttachmentRequest{}, response: AttachmentResponse{},
+		errors: []apiError{errInvalidRequest, errInvalidID, errAttachmentNotFound, errMissingFields,
+			errUnsupportedFileType, errInvalidVisibility},
+	},
+	{
+		method: "DELETE", path: "/attachments/:id", admin: true, handler: (*Handler).DeleteAttachment,
+		summary: "添付ファイルの削除", tag: "attachments",
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errAttachmentNotFound},
+	},
+	{
+		method: "GET", path: "/attachments/stats", admin: true, handler: (*Handler).GetAttachmentStats,
+		summary: "添付ファイルのダウンロード統計", tag: "attachments",
+		query: []queryParam{
+			{name: "book_id", description: "書籍ID"},
+			{name: "from", description: "集計の開始日（YYYY-MM-DD または RFC3339）"},
+			{name: "to", description: "集計の終了日（その日を含む）"},
+			{name: "limit", description: "取得件数（1〜200、既定値50。従来の /api では省略時に全件）"},
+		},
+		response: ListResponse[AttachmentStatsResponse]{},
+		errors:   []apiError{errInvalidFilter, errInvalidPagination},
+	},
+
+	// 書籍の一括取り込み
+	{
+		method: "POST", path: "/books/import", admin: true, handler: (*Handler).ImportBooks,
+		summary: "CSV・XLSX からの書籍の一括取り込み（バックグラウンドで実行するジョブを登録する）", tag: "imports",
+		form: []formField{
+			{name: "file", description: "CSV（UTF-8 または Shift_JIS）または XLSX（最大20MB）。最初の空でない行を見出しとする"},
+			{name: "mapping", description: `項目名と列の見出しの対応（JSON。例: {"title": "書名", "isbn": "ISBN"}）。省略した項目は見出しから推定し、空文字列を指定した項目は取り込まない。項目名: ` + strings.Join(importFieldNames(importFields), ", "), text: true},
+			{name: "dry_run", description: "true: 検証と重複の確認のみ行い登録しない", text: true},
+			{name: "enrich", description: "true: 空の書誌情報を ISBN から外部サービスで補う", text: true},
+			{name: "sheet", description: "XLSX のシート名（省略時は最初のシート）", text: true},
+		},
+		response: ImportJobResponse{},
+		errors: []apiError{errFileRequired, errImportTooLarge, errUnsupportedImportType, errInvalidRequest,
+			errInvalidMapping, errInvalidSpreadsheet, errSheetNotFound, errEmptyImport},
+	},
+	{
+		method: "POST", path: "/users/import", admin: true, handler: (*Handler).ImportUsers,
+		summary: "CSV・XLSX の名簿からのユーザーの一括登録（初期パスワード・招待のトークンを発行する）", tag: "imports",
+		form: []formField{
+			{name: "file", description: "CSV（UTF-8 または Shift_JIS）または XLSX（最大20MB）。最初の空でない行を見出しとする"},
+			{name: "mapping", description: `項目名と列の見出しの対応（JSON）。省略した項目は見出しから推定する。項目名: ` + strings.Join(importFieldNames(userImportFields), ", "), text: true},
+			{name: "existing", description: "学籍番号が登録済みの行の扱い。skip: 変更しない（既定）、update: 氏名・メールアドレス・ロール（列に値がある場合のみ。自分自身の管理者権限は外さない）を更新して利用停止を解除する", text: true},
+			{name: "credentials", description: "作成したユーザーに発行する資格情報。password: 初期パスワード（既定）、invite: 招待のトークン（有効期間14日、POST /auth/invite で使う）", text: true},
+			{name: "format", description: "結果の形式。json（既定）、csv・xlsx: 配布用の資格情報の一覧表", text: true},
+			{name: "dry_run", description: "true: 検証と登録済みの確認のみ行い登録しない", text: true},
+			{name: "sheet", description: "XLSX のシート名（省略時は最初のシート）", text: true},
+		},
+		response: UserImportResponse{}, contentType: exportFormats[ExportCSV] + "," + exportFormats[ExportXLSX],
+		errors: []apiError{errFileRequired, errImportTooLarge,
+			errUnsupportedImportType, errInvalidRequest, errInvalidMapping, errInvalidSpreadsheet, errSheetNotFound, errEmptyImport},
+	},
+	{
+		method: "POST", path: "/users/deactivate", admin: true, handler: (*Handler).DeactivateUsers,
+		summary: "ユーザーの一括利用停止（卒業など。貸出の履歴は残す）", tag: "imports",
+		request: DeactivateUsersRequest{},
+		form: []formField{
+			{name: "file", description: "学籍番号の列を持つ CSV・XLSX（JSON の student_ids の代わり）"},
+			{name: "status", description: "graduated（既定）または deactivated", text: true},
+			{name: "force", description: "true: 返却されていない貸出があるユーザーも利用停止にする", text: true},
+			{name: "sheet", description: "XLSX のシート名（省略時は最初のシート）", text: true},
+		},
+		response: DeactivateUsersResponse{},
+		errors: []apiError{errInvalidRequest, errMissingFields, errInvalidUserStatus, errFileRequired, errImportTooLarge,
+			errUnsupportedImportType, errInvalidMapping, errInvalidSpreadsheet, errSheetNotFound, errEmptyImport},
+	},
+	{
+		method: "GET", path: "/books/import", admin: true, handler: (*Handler).GetImportJobs,
+		summary: "取り込みジョブの一覧（新しい順）", tag: "imports",
+		query: []queryParam{
+			{name: "limit", description: "取得件数（1〜200、既定値50。従来の /api では省略時に全件）"},
+			{name: "offset", description: "読み飛ばす件数"},
+		},
+		response: PageResponse[ImportJobResponse]{},
+		errors:   []apiError{errInvalidPagination},
+	},
+	{
+		method: "GET", path: "/books/import/:id", admin: true, handler: (*Handler).GetImportJob,
+		summary: "取り込みジョブの状態と進捗", tag: "imports",
+		response: ImportJobResponse{},
+		errors:   []apiError{errInvalidID, errImportNotFound},
+	},
+	{
+		method: "GET", path: "/books/import/:id/rows", admin: true, handler: (*Handler).GetImportRows,
+		summary: "取り込みジョブの行ごとの結果（検証エラー・重複）", tag: "imports",
+		query: []queryParam{
+			{name: "status", description: "結果の種類: imported, duplicate, error"},
+			{name: "limit", description: "取得件数（1〜200、既定値50。従来の /api では省略時に全件）"},
+			{name: "offset", description: "読み飛ばす件数"},
+		},
+		response: PageResponse[ImportRowResponse]{},
+		errors:   []apiError{errInvalidID, errInvalidFilter, errInvalidPagination, errImportNotFound},
+	},
+	{
+		method: "POST", path: "/books/import/:id/resume", admin: true, handler: (*Handler).ResumeImportJob,
+		summary: "失敗した取り込みジョブを未処理の行から再開する", tag: "imports",
+		response: ImportJobResponse{},
+		errors:   []apiError{errInvalidID, errImportNotFound, errImportNotResumable},
+	},
+
+	// 書き出し
+	{
+		method: "GET", path: "/export/books", admin: true, handler: (*Handler).ExportBooks,
+		summary: "書籍（コピー数・貸出中の数を含む）の書き出し", tag: "exports",
+		query: append(exportQuery(),
+			queryParam{name: "query", description: "検索語（書籍一覧と同じ形式）"},
+			queryParam{name: "type", description: "資料種別"},
+			queryParam{name: "location", description: "配架場所"},
+			queryParam{name: "available", description: "true: 貸出可能なコピーがある書籍のみ、false: ない書籍のみ"},
+			queryParam{name: "withdrawn", description: "true: 除籍した書籍のみ（既定は除籍していない書籍のみ）"},
+			queryParam{name: "sort", description: "並び順（既定は登録順）: " + strings.Join(repository.BookSortFields, ", ")}),
+		contentType: exportContentTypes,
+		errors:      []apiError{errInvalidExportFormat, errInvalidFilter, errInvalidSort},
+	},
+	{
+		method: "GET", path: "/export/loans", admin: true, handler: (*Handler).ExportLoans,
+		summary: "貸出履歴の書き出し（貸出日の古い順）", tag: "exports",
+		query: append(exportQuery(),
+			queryParam{name: "from", description: "貸出日の開始（YYYY-MM-DD または RFC3339）"},
+			queryParam{name: "to", description: "貸出日の終了（その日を含む）"},
+			queryParam{name: "status", description: "borrowed または returned"}),
+		contentType: exportContentTypes,
+		errors:      []apiError{errInvalidExportFormat, errInvalidFilter},
+	},
+	{
+		method: "GET", path: "/export/users", admin: true, handler: (*Handler).ExportUsers,
+		summary: "ユーザーの書き出し（登録順）", tag: "exports",
+		query:       append(exportQuery(), queryParam{name: "role", description: "ロール"}),
+		contentType: exportContentTypes,
+		errors:      []apiError{errInvalidExportFormat},
+	},
+	{
+		method: "GET", path: "/export/rankings", admin: true, handler: (*Handler).ExportRankings,
+		summary: "貸出ランキングの書き出し", tag: "exports",
+		query: append(exportQuery(),
+			queryParam{name: "month", description: "月（YYYY-MM）。省略時は全期間"},
+			queryParam{name: "limit", description: "件数（1〜10000、既定値100）"}),
+		contentType: exportContentTypes,
+		errors:      []apiError{errInvalidExportFormat, errInvalidFilter, errInvalidPagination},
+	},
+
+	// バーコード生成機能
+	{
+		method: "POST", path: "/barcode/generate-thesis", admin: true, handler: (*Handler).GenerateThesisBarcode,
+		summary: "卒論バーコードの生成", tag: "barcodes",
+		request: ThesisBarcodeRequest{}, response: ThesisBarcodeResponse{},
+		errors: []apiError{errMissingFields, errInvalidStudentID, errInvalidAcademicYear, errInvalidDegree,
+			errInvalidAuthorRole, errBookNotFound, errNotThesis, errDuplicateThesis, errBarcodeInUse},
+	},
+	{
+		method: "GET", path: "/barcode/saved", admin: true, handler: (*Handler).GetSavedBarcodes,
+		summary: "保存済みバーコードの一覧", tag: "barcodes",
+		query: listQuery(repository.BarcodeSortFields,
+			queryParam{name: "code", description: "コード（前方一致）"},
+			queryParam{name: "symbology", description: "バーコードの種類（ean13）"},
+			queryParam{name: "book_id", description: "関連付けた書籍のID"},
+			queryParam{name: "copy_id", description: "関連付けたコピーのID"},
+			queryParam{name: "created_by", description: "生成した管理者のユーザーID"},
+			queryParam{name: "year", description: "年度"},
+			queryParam{name: "student_id", description: "学籍番号"}),
+		response: SavedBarcodesResponse{},
+		errors:   []apiError{errInvalidPagination, errInvalidSort, errInvalidFilter},
+	},
+	{
+		method: "GET", path: "/barcode/download/:id", admin: true, handler: (*Handler).DownloadBarcodeImage,
+		summary: "バーコード画像のダウンロード", tag: "barcodes",
+		contentType: "application/octet-stream", ranges: true, redirect: true,
+		errors: []apiError{errInvalidID, errBarcodeNotFound, errFileNotFound},
+	},
+	{
+		method: "DELETE", path: "/barcode/:id", admin: true, handler: (*Handler).DeleteBarcodeImage,
+		summary: "バーコード画像の削除", tag: "barcodes",
+		response: FileDeletedResponse{},
+		errors:   []apiError{errInvalidID, errBarcodeNotFound},
+	},
+	{
+		method: "POST", path: "/barcode/migrate", admin: true, handler: (*Handler).MigrateSavedBarcodes,
+		summary: "記録の導入前に保存されたバーコードの移行（年度・学籍番号の補完と重複する画像の削除）", tag: "barcodes",
+		request: BarcodeMigrationRequest{}, response: BarcodeMigrationResponse{},
+		errors: []apiError{errInvalidRequest},
+	},
+	{
+		method: "GET", path: "/labels/templates", admin: true, handler: (*Handler).GetLabelTemplates,
+		summary: "ラベル用紙のプリセットの一覧", tag: "barcodes",
+		response: LabelTemplatesResponse{},
+	},
+	{
+		method: "POST", path: "/labels", admin: true, handler: (*Handler).PrintLabels,
+		summary: "バーコードラベルの用紙の PDF の作成（保存済みバーコード・コピーごとにバーコード・番号・タイトル・配置場所を印刷する）", tag: "barcodes",
+		request: LabelSheetRequest{}, contentType: "application/pdf",
+		errors: []apiError{errInvalidRequest, errMissingFields, errTooManyLabels, errInvalidLabelTemplate,
+			errInvalidLabelCode, errBarcodeNotFound, errCopyNotFound, errBookNotFound},
+	},
+
+	// 書籍画像管理機能
+	{
+		method: "POST", path: "/books/:id/image", admin: true, handler: (*Handler).UploadBookImage,
+		summary: "書籍画像のアップロード", tag: "images",
+		form:     []formField{{name: "image", description: "JPEG・PNG・GIF・WebP（最大5MB・4000万画素）。EXIF は向きの補正に使った後に除かれる"}},
+		response: ImageUploadResponse{},
+		errors:   []apiError{errInvalidID, errBookNotFound, errImageRequired, errImageTooLarge, errUnsupportedImageType},
+	},
+	{
+		method: "DELETE", path: "/books/:id/image", admin: true, handler: (*Handler).DeleteBookImage,
+		summary: "書籍画像の削除", tag: "images",
+		response: MessageResponse{},
+		errors:   []apiError{errInvalidID, errBookNotFound, errImageNotFound},
+	},
+	{
+		method: "POST", path: "/books/covers", admin: true, handler: (*Handler).FetchCovers,
+		summary: "画像のない書籍の表紙画像を ISBN から外部サービスで取得する（バックグラウンドで実行するジョブを登録する）", tag: "images",
+		response: CoverJobResponse{},
+	},
+	{
+		method: "GET", path: "/books/covers", admin: true, handler: (*Handler).GetCoverJobs,
+		summary: "表紙画像の取得ジョブの一覧（新しい順）", tag: "images",
+		query: []queryParam{
+			{name: "limit", description: "取得件数（1〜200、既定値50。従来の /api では省略時に全件）"},
+			{name: "offset", description: "読み飛ばす件数"},
+		},
+		response: PageResponse[CoverJobResponse]{},
+		errors:   []apiError{errInvalidPagination},
+	},
+	{
+		method: "GET", path: "/books/covers/:id", admin: true, handler: (*Handler).GetCoverJob,
+		summary: "表紙画像の取得ジョブの状態と進捗", tag: "images",
+		response: CoverJobResponse{},
+		errors:   []apiError{errInvalidID, errCoverJobNotFound},
+	},
+	{
+		method: "GET", path: "/books/covers/:id/results", admin: true, handler: (*Handler).GetCoverResults,
+		summary: "表紙画像の取得ジョブの書籍ごとの結果（処理した順）", tag: "images",
+		query: []queryParam{
+			{name: "status", description: "結果の種類: imported, not_found, error"},
+			{name: "limit", description: "取得件数（1〜200、既定値50。従来の /api では省略時に全件）"},
+			{name: "offset", description: "読み飛ばす件数"},
+		},
+		response: PageResponse[CoverResultResponse]{},
+		errors:   []apiError{errInvalidID, errInvalidFilter, errInvalidPagination, errCoverJobNotFound},
+	},
+	{
+		method: "POST", path: "/books/covers/:id/resume", admin: true, handler: (*Handler).ResumeCoverJob,
+		summary: "失敗した表紙画像の取得ジョブを未処理の書籍から再開する", tag: "images",
+		response: CoverJobResponse{},
+		errors:   []apiError{errInvalidID, errCoverJobNotFound, errCoverJobNotResumable},
+	},
+}
+
+// listQuery は一覧系エンドポイントの絞り込み条件に sort・limit・offset を加える
+func listQuery(sortFields []string, filters ...queryParam) []queryParam {
+	return append(filters,
+		queryParam{name: "sort", description: "並び順（先頭に - を付けると降順）: " + strings.Join(sortFields, ", ")},
+		queryParam{name: "limit", description: "取得件数（1〜200、既定値50。従来の /api では省略時に全件）"},
+		queryParam{name: "offset", description: "読み飛ばす件数"},
+	)
+}
+
+// exportContentTypes は書き出しのエンドポイントが返す形式
+const exportContentTypes = "text/csv, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet, application/x-ndjson"
+
+// exportQuery は書き出しに共通のクエリパラメーター
+func exportQuery() []queryParam {
+	return []queryParam{{name: "format", description: "形式: csv（BOM 付き UTF-8）, xlsx, ndjson（既定は csv）"}}
+}
+
+// bookListQuery は書籍一覧・検索に共通のクエリパラメーター
+func bookListQuery() []queryParam {
+	return listQuery(repository.BookSortFields,
+		queryParam{name: "query", description: "検索語。タイトル・著者・コード・件名・出版社・内容紹介を対象とし、空白区切りの語はすべてを含む書籍に一致する。title:・author:・isbn: で項目を指定できる。指定時の既定の並び順は関連度順"},
+		queryParam{name: "type", description: "資料種別"},
+		queryParam{name: "location", description: "配架場所"},
+		queryParam{name: "author", description: "著者（いずれかの著者の氏名と完全一致）"},
+		queryParam{name: "year", description: "出版年"},
+		queryParam{name: "publisher", description: "出版社（完全一致）"},
+		queryParam{name: "language", description: "言語コード（ja・en など）"},
+		queryParam{name: "subject", description: "件名（完全一致）"},
+		queryParam{name: "available", description: "true: 貸出可能なコピーがある書籍のみ、false: ない書籍のみ"})
+}
+
+// withDomainErrors は errs に circulation のドメインエラーを加える
+func withDomainErrors(errs []apiError, domain ...*circulation.Error) []apiError {
+	for _, err := range domain {
+		if e, ok := circulationError(err); ok {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// RegisterRoutes - g 配下に全エンドポイントを登録する。
+// 従来の /api と /api/v1 の両方から呼ばれ、レスポンス形式は WithVersion で切り替わる。
+func (h *Handler) RegisterRoutes(g *gin.RouterGroup) {
+	// 認証が必要なルート
+	auth := g.Group("")
+	//auth.Use(middleware.AuthMiddleware())
+	// 添付ファイルの公開範囲の判定のため、トークンがあれば利用者を識別する
+	auth.Use(middleware.OptionalAuth(h.store.Users()))
+
+	// 管理者専用ルート
+	admin := auth.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(h.store.Users(), respondAuthError), middleware.AdminMiddleware(respondAuthError))
+
+	for i := range routeTable {
+		rt := &routeTable[i]
+		group := auth
+		if rt.admin {
+			group = admin
+		}
+		group.Handle(rt.method, rt.path, func(c *gin.Context) {
+			c.Set(routeKey, rt)
+			rt.handler(h, c)
+		})
+	}
+}
+
+// respondAuthError は認証・認可のミドルウェアのエラーを他のエラーと同じ形式で返す
+func respondAuthError(c *gin.Context, status int, code string) {
+	respondError(c, apiError{Status: status, Code: code})
+}
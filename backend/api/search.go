@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"lablib/repository"
+	"lablib/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 入力補完の候補数の既定値と上限（超える指定は上限に丸める）、候補を探す検索結果の件数
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+	suggestCandidates   = 50
+)
+
+// SearchBooks - ファセット付きの書籍検索。絞り込み条件は GetBooks と同じで、
+// 結果に資料種別・配架場所・著者・出版年・貸出可否ごとの件数を付ける。
+func (h *Handler) SearchBooks(c *gin.Context) {
+	filter, err := parseBookFilter(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	results, total, err := h.store.Books().Search(ctx, filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	facets, err := h.store.Books().Facets(ctx, filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, BookSearchResponse{
+		PageResponse: newPage(newBookResponses(results), filter.Page, total),
+		Facets:       newBookFacetsResponse(facets),
+	})
+}
+
+// SuggestBooks - 検索欄の入力補完。q に一致するタイトルと著者を返す。
+// 関連度順の検索結果の上位から候補を作り、q で始まる候補を先に並べる。
+func (h *Handler) SuggestBooks(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	limit := defaultSuggestLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondError(c, errInvalidPagination)
+			return
+		}
+		limit = min(n, maxSuggestLimit)
+	}
+
+	res := SuggestResponse{Query: q, Suggestions: []SuggestionResponse{}}
+	query := search.Parse(q)
+	if query.Empty() {
+		respond(c, http.StatusOK, res)
+		return
+	}
+
+	results, _, err := h.store.Books().Search(c.Request.Context(), repository.BookFilter{
+		Query: q,
+		Sort:  repository.Sort{Field: "relevance"},
+		Page:  repository.Page{Limit: suggestCandidates},
+	})
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	// タイトルは書籍ごと、著者は同じ著者の書籍をまとめて1件にする。
+	// タイトルと著者の両方にまたがる入力（"夏目 こころ" など）やコードに一致した書籍はタイトルを候補にする。
+	var titles, authors []SuggestionResponse
+	authorIndex := map[string]int{}
+	for _, b := range results {
+		byTitle := query.Match(search.Document{Title: b.Title})
+		byAuthor := b.Author != "" && query.Match(search.Document{Author: b.Author})
+		if byTitle || !byAuthor {
+			id := b.ID
+			titles = append(titles, SuggestionResponse{Kind: "title", Text: b.Title, BookID: &id, Count: 1})
+		}
+		if byAuthor {
+			if i, ok := authorIndex[b.Author]; ok {
+				authors[i].Count++
+			} else {
+				authorIndex[b.Author] = len(authors)
+				authors = append(authors, SuggestionResponse{Kind: "author", Text: b.Author, Count: 1})
+			}
+		}
+	}
+
+	prefix := search.Normalize(q)
+	suggestions := append(titles, authors...)
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return strings.HasPrefix(search.Normalize(suggestions[i].Text), prefix) &&
+			!strings.HasPrefix(search.Normalize(suggestions[j].Text), prefix)
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	if suggestions != nil {
+		res.Suggestions = suggestions
+	}
+
+	// 入力のたびに呼ばれるため、同じ入力の再問い合わせはブラウザのキャッシュで返す
+	c.Header("Cache-Control", "private, max-age=30")
+	respond(c, http.StatusOK, res)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"lablib/models"
+)
+
+func TestSearchFacets(t *testing.T) {
+	s := newTestServer(t)
+	year := func(y int) *int { return &y }
+	s.createBook(models.Book{Title: "吾輩は猫である", Author: "夏目 漱石", Type: "book", TotalCopies: 1, Location: "A-1", Barcode: "SF-0001", PublishedYear: year(1905)})
+	s.createBook(models.Book{Title: "こころ", Author: "夏目 漱石", Type: "book", TotalCopies: 1, Location: "A-2", PublishedYear: year(1914)})
+	s.createBook(models.Book{Title: "夏目漱石の研究", Author: "山田 太郎", Type: "thesis", TotalCopies: 1, Location: "A-1", PublishedYear: year(2024)})
+	s.createBook(models.Book{Title: "無関係", Author: "鈴木 花子", Type: "book", TotalCopies: 1, Location: "B-1"})
+	expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "SF-0001", UserID: s.user.ID.String()}, ""), http.StatusOK)
+
+	search := func(query string) BookSearchResponse {
+		t.Helper()
+		rec := s.do("GET", "/api/v1/books/search?"+query, nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		var res BookSearchResponse
+		decode(t, rec, &res)
+		return res
+	}
+
+	res := search("query=夏目")
+	if res.Pagination.Total != 3 {
+		t.Fatalf("total = %d", res.Pagination.Total)
+	}
+	if want := []FacetCountResponse{{"book", 2}, {"thesis", 1}}; !equalFacets(res.Facets.Type, want) {
+		t.Errorf("type facet = %+v", res.Facets.Type)
+	}
+	if want := []FacetCountResponse{{"A-1", 2}, {"A-2", 1}}; !equalFacets(res.Facets.Location, want) {
+		t.Errorf("location facet = %+v", res.Facets.Location)
+	}
+	if want := []FacetCountResponse{{"2024", 1}, {"1914", 1}, {"1905", 1}}; !equalFacets(res.Facets.Year, want) {
+		t.Errorf("year facet = %+v", res.Facets.Year)
+	}
+	if want := []FacetCountResponse{{"available", 2}, {"unavailable", 1}}; !equalFacets(res.Facets.Availability, want) {
+		t.Errorf("availability facet = %+v", res.Facets.Availability)
+	}
+
+	// 絞り込んだ項目のファセットはその絞り込みを外して数える
+	res = search("query=夏目&type=book")
+	if res.Pagination.Total != 2 || len(res.Facets.Type) != 2 || len(res.Facets.Year) != 2 {
+		t.Fatalf("filtered = %+v", res)
+	}
+	if res = search("author=夏目+漱石&year=1914"); len(res.Items) != 1 || res.Items[0].Title != "こころ" {
+		t.Fatalf("author and year = %+v", res.Items)
+	}
+	expectError(t, s.do("GET", "/api/v1/books/search?year=x", nil, ""), errInvalidFilter)
+}
+
+func equalFacets(got, want []FacetCountResponse) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSuggest(t *testing.T) {
+	s := newTestServer(t)
+	s.createBook(models.Book{Title: "吾輩は猫である", Author: "夏目 漱石", Type: "book", TotalCopies: 1})
+	s.createBook(models.Book{Title: "こころ", Author: "夏目 漱石", Type: "book", TotalCopies: 1})
+	s.createBook(models.Book{Title: "夏目漱石の研究", Author: "山田 太郎", Type: "thesis", TotalCopies: 1})
+
+	suggest := func(query string) SuggestResponse {
+		t.Helper()
+		rec := s.do("GET", "/api/v1/books/suggest?"+query, nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		var res SuggestResponse
+		decode(t, rec, &res)
+		return res
+	}
+
+	res := suggest("q=夏目")
+	if len(res.Suggestions) != 2 {
+		t.Fatalf("suggestions = %+v", res.Suggestions)
+	}
+	// 同じ著者の書籍は1件にまとめ、前方一致の候補を先に並べる
+	for _, sg := range res.Suggestions {
+		switch sg.Kind {
+		case "title":
+			if sg.Text != "夏目漱石の研究" || sg.BookID == nil || sg.Count != 1 {
+				t.Errorf("title suggestion = %+v", sg)
+			}
+		case "author":
+			if sg.Text != "夏目 漱石" || sg.BookID != nil || sg.Count != 2 {
+				t.Errorf("author suggestion = %+v", sg)
+			}
+		}
+	}
+
+	if res = suggest("q=夏目+こころ"); len(res.Suggestions) != 1 || res.Suggestions[0].Text != "こころ" {
+		t.Fatalf("title and author = %+v", res.Suggestions)
+	}
+	if res = suggest("q=夏目&limit=1"); len(res.Suggestions) != 1 {
+		t.Fatalf("limit = %+v", res.Suggestions)
+	}
+	if res = suggest("q=　"); res.Suggestions == nil || len(res.Suggestions) != 0 {
+		t.Fatalf("empty query = %+v", res)
+	}
+	expectError(t, s.do("GET", "/api/v1/books/suggest?q=a&limit=0", nil, ""), errInvalidPagination)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"testing"
+	"time"
+
+	"lablib/circulation"
+	"lablib/metadata"
+	"lablib/middleware"
+	"lablib/models"
+	"lablib/repository"
+	"lablib/repository/memory"
+	"lablib/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	middleware.JWTSecret = []byte("lablib-test-secret-0123456789abcdef")
+	os.Exit(m.Run())
+}
+
+// testServer はメモリのデータストア・保存先を使う API サーバー。
+// 書誌情報の取得先は設定せず、外部のサービスには接続しない。
+type testServer struct {
+	t      *testing.T
+	store  repository.Store
+	blobs  *storage.Memory
+	h      *Handler
+	router *gin.Engine
+
+	admin, user           *models.User // CreateDefaultUsers で作成したユーザー
+	adminToken, userToken string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	store := memory.NewStore()
+	h := NewHandler(store, circulation.NewService(store, circulation.DefaultPolicy()))
+	blobs := storage.NewMemory()
+	h.SetStorage(blobs)
+	h.SetBookInfoFetcher(metadata.NewFetcher(nil, store.MetadataCache()))
+	if err := CreateDefaultUsers(store); err != nil {
+		t.Fatalf("CreateDefaultUsers: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	h.RegisterRoutes(r.Group("/api", WithVersion(VersionLegacy)))
+	h.RegisterRoutes(r.Group("/api/v1", WithVersion(VersionV1)))
+
+	s := &testServer{t: t, store: store, blobs: blobs, h: h, router: r}
+	s.admin = s.userByStudentID(DefaultAdminStudentID)
+	s.user = s.userByStudentID(DefaultUserStudentID)
+	s.adminToken, s.userToken = s.token(s.admin), s.token(s.user)
+	return s
+}
+
+// token は u としてログインしたときと同じ JWT を返す
+func (s *testServer) token(u *models.User) string {
+	s.t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": u.ID.String(),
+		"role":    u.Role,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString(middleware.JWTSecret)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+func (s *testServer) userByStudentID(studentID string) *models.User {
+	s.t.Helper()
+	u, err := s.store.Users().GetByStudentID(context.Background(), studentID)
+	if err != nil {
+		s.t.Fatalf("GetByStudentID(%s): %v", studentID, err)
+	}
+	return u
+}
+
+// do は body（nil・[]byte・それ以外は JSON にする）を token の認証で送り、レスポンスを返す
+func (s *testServer) do(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var r io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		r = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("marshal request: %v", err)
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+	req := httptest.NewRequest(method, path, r)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.send(req, token)
+}
+
+// formFile は multipart で送るファイル
+type formFile struct {
+	field, name string
+	data        []byte
+}
+
+// multipartBody は fields と files の multipart/form-data の本文と Content-Type を返す
+func multipartBody(t *testing.T, fields map[string]string, files ...formFile) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		// Content-Type はブラウザと同じく内容から決める
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, f.field, f.name))
+		h.Set("Content-Type", http.DetectContentType(f.data))
+		part, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(f.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), w.FormDataContentType()
+}
+
+// postForm は multipart/form-data のリクエストを送る
+func (s *testServer) postForm(path string, fields map[string]string, files []formFile, token string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	body, contentType := multipartBody(s.t, fields, files...)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return s.send(req, token)
+}
+
+// send は req を送り、レスポンスを返す。token が空でなければ Bearer 認証を付ける
+func (s *testServer) send(req *http.Request, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode はレスポンスの本文を v に読み込む
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// expectStatus はステータスが want でない場合にテストを失敗させる
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body = %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+// expectError は /api/v1 のエラーエンベロープのステータスとコードを確かめる
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, want apiError) {
+	t.Helper()
+	expectStatus(t, rec, want.Status)
+	var res ErrorResponse
+	decode(t, rec, &res)
+	if res.Error.Code != want.Code {
+		t.Fatalf("error code = %q, want %q", res.Error.Code, want.Code)
+	}
+}
+
+// createBook は書籍を登録し、その ID を返す
+func (s *testServer) createBook(book models.Book) uuid.UUID {
+	s.t.Helper()
+	rec := s.do("POST", "/api/v1/admin/books", book, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var res BookResponse
+	decode(s.t, rec, &res)
+	return res.ID
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxPDFSize = 50 << 20 //50MB
+)
+
+// parseAcademicYear は年度（4桁の西暦）を検証する
+func parseAcademicYear(s string) (int, error) {
+	year, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || year < 1000 || year > 9999 {
+		return 0, errInvalidAcademicYear
+	}
+	return year, nil
+}
+
+// cleanStudentID は学籍番号からハイフンと空白を除き、6桁の数字であることを確認する
+func cleanStudentID(s string) (string, error) {
+	id := strings.ReplaceAll(s, "-", "")
+	id = strings.ReplaceAll(id, " ", "")
+	if len(id) != 6 {
+		return "", errInvalidStudentID
+	}
+	for _, char := range id {
+		if char < '0' || char > '9' {
+			return "", errInvalidStudentID
+		}
+	}
+	return id, nil
+}
+
+// parseDegree は学位を検証する。空の場合は def を返す。
+func parseDegree(s, def string) (string, error) {
+	degree := strings.ToLower(strings.TrimSpace(s))
+	if degree == "" {
+		return def, nil
+	}
+	if !containsString(models.Degrees, degree) {
+		return "", errInvalidDegree
+	}
+	return degree, nil
+}
+
+// withSupervisors は著者のうち指導教員を supervisors に置き換える
+func withSupervisors(authors []models.BookAuthor, supervisors []string) []models.BookAuthor {
+	result := make([]models.BookAuthor, 0, len(authors)+len(supervisors))
+	for _, a := range authors {
+		if a.Role != models.AuthorRoleSupervisor {
+			result = append(result, a)
+		}
+	}
+	for _, name := range supervisors {
+		result = append(result, models.BookAuthor{Name: name, Role: models.AuthorRoleSupervisor})
+	}
+	return result
+}
+
+// findThesisBarcodeTarget は卒論バーコードを関連付ける論文の書籍を探す。
+// book_id の指定、同じ年度・学籍番号の論文の順に探し、どちらもなければ nil を返す（新規登録）。
+func findThesisBarcodeTarget(ctx context.Context, tx repository.Store, req ThesisBarcodeRequest, year int, studentID string) (*models.Book, *models.Thesis, error) {
+	existing, err := tx.Theses().FindByStudent(ctx, year, studentID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, fmt.Errorf("find thesis: %w", err)
+	}
+
+	if req.BookID == nil {
+		if existing == nil {
+			return nil, nil, nil
+		}
+		book := existing.Book
+		return &book, existing, nil
+	}
+
+	if existing != nil && existing.BookID != *req.BookID {
+		return nil, nil, errDuplicateThesis
+	}
+	book, err := tx.Books().Get(ctx, *req.BookID)
+	if err != nil {
+		return nil, nil, notFoundAs(err, errBookNotFound)
+	}
+	if book.Type != "thesis" {
+		return nil, nil, errNotThesis
+	}
+	return book, existing, nil
+}
+
+// registerThesis は卒論バーコードの生成に合わせて論文を目録に登録する。
+// 既存の論文に関連付ける場合はリクエストの内容で書誌情報を更新し、コピーのバーコードを付け替える。
+// 新規登録した場合は created に true を返す。
+func registerThesis(ctx context.Context, tx repository.Store, req ThesisBarcodeRequest, year int, studentID, barcode string) (book *models.Book, created bool, err error) {
+	book, thesis, err := findThesisBarcodeTarget(ctx, tx, req, year, studentID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 同じバーコードが別の書籍で使われていないか
+	bc, err := tx.Copies().FindByBarcode(ctx, barcode)
+	switch {
+	case err == nil && (book == nil || bc.BookID != book.ID):
+		return nil, false, errBarcodeInUse
+	case err != nil && !errors.Is(err, repository.ErrNotFound):
+		return nil, false, fmt.Errorf("find copy: %w", err)
+	}
+
+	degreeDefault := models.DegreeBachelor
+	if thesis != nil {
+		degreeDefault = thesis.Degree
+	}
+	degree, err := parseDegree(req.Degree, degreeDefault)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	created = book == nil
+	if created {
+		book = &models.Book{ID: uuid.New(), Type: "thesis", CreatedAt: now}
+	}
+	supervisors := req.Supervisors
+	if supervisors == nil {
+		supervisors = (&models.Thesis{Book: *book}).Supervisors()
+	}
+	book.Title = req.Title
+	book.Author = ""
+	book.Authors = withSupervisors([]models.BookAuthor{{Name: req.AuthorName, Role: models.AuthorRoleAuthor}}, supervisors)
+	book.Barcode = barcode
+	if book.PublishedYear == nil && book.PublishedDate == "" {
+		book.PublishedYear = &year
+	}
+	if req.Abstract != "" {
+		book.Description = req.Abstract
+	}
+	if req.Keywords != nil {
+		book.Subjects = req.Keywords
+	}
+	if req.Location != "" {
+		book.Location = req.Location
+	}
+	// 論文は1冊を所蔵する。既存の書籍にコピーがあればそのバーコードを付け替える。
+	newCopy := book.TotalCopies == 0
+	if newCopy {
+		book.TotalCopies = 1
+	}
+	if err := normalizeBook(book); err != nil {
+		return nil, false, err
+	}
+	book.UpdatedAt = now
+
+	if created {
+		err = tx.Books().Create(ctx, book)
+	} else {
+		err = tx.Books().Update(ctx, book)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("save book: %w", err)
+	}
+
+	if newCopy {
+		err = tx.Copies().Create(ctx, &models.BookCopy{
+			ID:           uuid.New(),
+			BookID:       book.ID,
+			SerialNumber: newSerialNumber(book.ID),
+			Barcode:      barcode,
+			IsAvailable:  true,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
+	} else {
+		err = tx.Copies().SetBarcodeByBook(ctx, book.ID, barcode)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("save book copy: %w", err)
+	}
+
+	// 種別が thesis の既存の書籍に論文情報がなければ作成する
+	newThesis := thesis == nil
+	if newThesis {
+		thesis = &models.Thesis{BookID: book.ID, CreatedAt: now}
+	}
+	thesis.AcademicYear = year
+	thesis.Degree = degree
+	thesis.StudentID = studentID
+	thesis.UpdatedAt = now
+	if err := saveThesis(ctx, tx, thesis, newThesis); err != nil {
+		return nil, false, err
+	}
+	return book, created, nil
+}
+
+// saveThesis は論文情報を作成または更新する。年度・学籍番号の重複は errDuplicateThesis を返す。
+func saveThesis(ctx context.Context, tx repository.Store, thesis *models.Thesis, create bool) error {
+	var err error
+	if create {
+		err = tx.Theses().Create(ctx, thesis)
+	} else {
+		err = tx.Theses().Update(ctx, thesis)
+	}
+	if errors.Is(err, repository.ErrConflict) {
+		return errDuplicateThesis
+	}
+	if err != nil {
+		return fmt.Errorf("save thesis: %w", err)
+	}
+	return nil
+}
+
+// ListTheses - 論文の一覧。academic_year・supervisor・degree・query で絞り込み、既定では新しい年度から並べる。
+func (h *Handler) ListTheses(c *gin.Context) {
+	filter := repository.ThesisFilter{
+		Query:      c.Query("query"),
+		Supervisor: c.Query("supervisor"),
+	}
+	var err error
+	if v := c.Query("academic_year"); v != "" {
+		if filter.AcademicYear, err = parseAcademicYear(v); err != nil {
+			respondErr(c, err)
+			return
+		}
+	}
+	if filter.Degree, err = parseDegree(c.Query("degree"), ""); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.Sort, err = parseSort(c, repository.ThesisSortFields); err != nil {
+		respondErr(c, err)
+		return
+	}
+	if filter.Page, err = parsePage(c); err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	theses, total, err := h.store.Theses().List(c.Request.Context(), filter)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	items := make([]ThesisResponse, 0, len(theses))
+	for _, t := range theses {
+		items = append(items, newThesisResponse(t))
+	}
+	respond(c, http.StatusOK, newPage(items, filter.Page, total))
+}
+
+// GetThesisIndex - 論文を年度・指導教員ごとに数えた一覧。閲覧画面の絞り込みに使う。
+func (h *Handler) GetThesisIndex(c *gin.Context) {
+	index, err := h.store.Theses().Index(c.Request.Context())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, ThesisIndexResponse{
+		Years:       newFacetCountResponses(index.Years),
+		Supervisors: newFacetCountResponses(index.Supervisors),
+	})
+}
+
+// findThesis は :id パラメータの論文を取得する。見つからない場合はレスポンスを書き込み false を返す。
+func (h *Handler) findThesis(c *gin.Context) (*models.Thesis, bool) {
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	thesis, err := h.store.Theses().Get(c.Request.Context(), bookID)
+	if err != nil {
+		respondErr(c, notFoundAs(err, errThesisNotFound))
+		return nil, false
+	}
+	return thesis, true
+}
+
+// GetThesis - 論文の詳細
+func (h *Handler) GetThesis(c *gin.Context) {
+	thesis, ok := h.findThesis(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, newThesisResponse(*thesis))
+}
+
+// UpdateThesis - 論文情報の更新（管理者のみ）。
+// 論文情報のない type = "thesis" の書籍には、academic_year と student_id を指定して論文情報を作成できる。
+func (h *Handler) UpdateThesis(c *gin.Context) {
+	ctx := c.Request.Context()
+	bookID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req UpdateThesisRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errInvalidRequest)
+		return
+	}
+
+	err := h.store.WithTx(ctx, func(tx repository.Store) error {
+		book, err := tx.Books().Get(ctx, bookID)
+		if err != nil {
+			return notFoundAs(err, errBookNotFound)
+		}
+		if book.Type != "thesis" {
+			return errNotThesis
+		}
+
+		now := time.Now()
+		thesis, err := tx.Theses().Get(ctx, bookID)
+		create := errors.Is(err, repository.ErrNotFound)
+		if err != nil && !create {
+			return fmt.Errorf("get thesis: %w", err)
+		}
+		if create {
+			if req.AcademicYear == nil || req.StudentID == nil {
+				return errMissingFields
+			}
+			thesis = &models.Thesis{BookID: bookID, Degree: models.DegreeBachelor, CreatedAt: now}
+		}
+
+		if req.AcademicYear != nil {
+			if thesis.AcademicYear, err = parseAcademicYear(strconv.Itoa(*req.AcademicYear)); err != nil {
+				return err
+			}
+		}
+		if req.Degree != nil {
+			if thesis.Degree, err = parseDegree(*req.Degree, thesis.Degree); err != nil {
+				return err
+			}
+		}
+		if req.StudentID != nil {
+			if thesis.StudentID, err = cleanStudentID(*req.StudentID); err != nil {
+				return err
+			}
+		}
+		thesis.UpdatedAt = now
+
+		if req.Supervisors != nil {
+			book.Authors = withSupervisors(book.Authors, req.Supervisors)
+			book.Author = ""
+		}
+		setIfPresent(&book.Description, req.Abstract)
+		if req.Keywords != nil {
+			book.Subjects = req.Keywords
+		}
+		if err := normalizeBook(book); err != nil {
+			return err
+		}
+		book.UpdatedAt = now
+		if err := tx.Books().Update(ctx, book); err != nil {
+			return fmt.Errorf("update book: %w", err)
+		}
+		return saveThesis(ctx, tx, thesis, create)
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, "thesis_updated")
+}
+
+// GetThesisPDF - 論文PDFの取得（全ユーザー共通）。保存先が S3 の場合は署名付き URL へリダイレクトする。
+func (h *Handler) GetThesisPDF(c *gin.Context) {
+	thesis, ok := h.findThesis(c)
+	if !ok {
+		return
+	}
+	if thesis.PDFPath == "" {
+		respondError(c, errPDFNotFound)
+		return
+	}
+
+	filename := fmt.Sprintf("thesis_%d_%s.pdf", thesis.AcademicYear, thesis.StudentID)
+	h.serveBlob(c, ThesisPDFPrefix+thesis.PDFPath, errPDFNotFound, blobOptions{
+		ContentType:        "application/pdf",
+		ContentDisposition: fmt.Sprintf("inline; filename=%q", filename),
+	})
+}
+
+// pdfMagic はPDFファイルの先頭のバイト列
+var pdfMagic = []byte("%PDF-")
+
+// UploadThesisPDF - 論文PDFのアップロード（管理者のみ）。既存のPDFは置き換える。
+func (h *Handler) UploadThesisPDF(c *gin.Context) {
+	thesis, ok := h.findThesis(c)
+	if !ok {
+		return
+	}
+
+	file, header, err := c.Request.FormFile("pdf")
+	if err != nil {
+		respondError(c, errPDFRequired)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > MaxPDFSize {
+		respondError(c, errPDFTooLarge)
+		return
+	}
+
+	// Content-Type はブラウザによって異なるため、ファイルの先頭で判定する
+	head := make([]byte, len(pdfMagic))
+	if _, err := io.ReadFull(file, head); err != nil || !bytes.Equal(head, pdfMagic) {
+		respondError(c, errUnsupportedPDFType)
+		return
+	}
+
+	ctx := c.Request.Context()
+	filename := uuid.New().String() + ".pdf"
+	key := ThesisPDFPrefix + filename
+	if err := h.blobs.Put(ctx, key, io.MultiReader(bytes.NewReader(head), file), header.Size, "application/pdf"); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	if err := h.store.Theses().SetPDFPath(ctx, thesis.BookID, filename); err != nil {
+		h.deleteBlobs(ctx, key)
+		respondInternalError(c, err)
+		return
+	}
+
+	// 既存のPDFは新しいPDFの登録後に削除する
+	if thesis.PDFPath != "" {
+		h.deleteBlobs(ctx, ThesisPDFPrefix+thesis.PDFPath)
+	}
+
+	respond(c, http.StatusOK, PDFUploadResponse{
+		Message: message(c, "pdf_uploaded"),
+		PDFPath: filename,
+	})
+}
+
+// DeleteThesisPDF - 論文PDFの削除（管理者のみ）
+func (h *Handler) DeleteThesisPDF(c *gin.Context) {
+	thesis, ok := h.findThesis(c)
+	if !ok {
+		return
+	}
+	if thesis.PDFPath == "" {
+		respondError(c, errPDFNotFound)
+		return
+	}
+
+	if err := h.store.Theses().SetPDFPath(c.Request.Context(), thesis.BookID, ""); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	h.deleteBlobs(c.Request.Context(), ThesisPDFPrefix+thesis.PDFPath)
+
+	respondMessage(c, http.StatusOK, "pdf_deleted")
+}
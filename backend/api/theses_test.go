@@ -0,0 +1,168 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+// generateThesisBarcode は卒論バーコードを生成し、その結果を返す
+func (s *testServer) generateThesisBarcode(year, studentID string) ThesisBarcodeResponse {
+	s.t.Helper()
+	rec := s.do("POST", "/api/v1/admin/barcode/generate-thesis", ThesisBarcodeRequest{
+		Year: year, StudentID: studentID, AuthorName: "卒論 太郎", Title: "卒業論文",
+	}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var res ThesisBarcodeResponse
+	decode(s.t, rec, &res)
+	return res
+}
+
+func (s *testServer) thesis(id uuid.UUID) ThesisResponse {
+	s.t.Helper()
+	rec := s.do("GET", "/api/v1/theses/"+id.String(), nil, "")
+	expectStatus(s.t, rec, http.StatusOK)
+	var res ThesisResponse
+	decode(s.t, rec, &res)
+	return res
+}
+
+func TestThesisRegistration(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do("POST", "/api/v1/admin/barcode/generate-thesis", ThesisBarcodeRequest{
+		Year: "2024", StudentID: "12-3456", AuthorName: "卒論 太郎", Title: "機械学習による蔵書推薦",
+		Degree: "Master", Supervisors: []string{"田中 教授"}, Abstract: "概要", Keywords: []string{"機械学習", "推薦"}, Location: "論文棚",
+	}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var generated ThesisBarcodeResponse
+	decode(t, rec, &generated)
+	if !generated.Created || generated.BookID == uuid.Nil || generated.StudentID != "123456" {
+		t.Fatalf("generated = %+v", generated)
+	}
+
+	thesis := s.thesis(generated.BookID)
+	if thesis.AcademicYear != 2024 || thesis.Degree != models.DegreeMaster || thesis.StudentID != "123456" ||
+		thesis.Barcode != generated.Barcode || thesis.Location != "論文棚" || thesis.Abstract != "概要" || thesis.HasPDF {
+		t.Fatalf("thesis = %+v", thesis)
+	}
+	if !reflect.DeepEqual(thesis.Supervisors, []string{"田中 教授"}) || !reflect.DeepEqual(thesis.Keywords, []string{"機械学習", "推薦"}) {
+		t.Fatalf("supervisors = %q, keywords = %q", thesis.Supervisors, thesis.Keywords)
+	}
+	// 論文は1冊の書籍として貸し出せる
+	// 書籍の著者は執筆者のみで、指導教員は含めない
+	if detail := s.bookDetail(generated.BookID); detail.Book.Type != "thesis" || detail.Book.AvailableCopies != 1 || detail.Book.Author != "卒論 太郎" {
+		t.Fatalf("book = %+v", detail.Book)
+	}
+
+	// 同じ年度・学籍番号で再生成すると既存の論文のバーコードを付け替える
+	again := s.generateThesisBarcode("2024", "123456")
+	if again.Created || again.BookID != generated.BookID {
+		t.Fatalf("regenerated = %+v", again)
+	}
+	if thesis = s.thesis(generated.BookID); thesis.Barcode != again.Barcode || thesis.Degree != models.DegreeMaster ||
+		!reflect.DeepEqual(thesis.Supervisors, []string{"田中 教授"}) {
+		t.Fatalf("thesis after regenerate = %+v", thesis)
+	}
+
+	other := s.generateThesisBarcode("2024", "654321")
+	book := s.createBook(models.Book{Title: "一般書", Author: "著者", Type: "book", TotalCopies: 1})
+	for _, tc := range []struct {
+		req  ThesisBarcodeRequest
+		want apiError
+	}{
+		{ThesisBarcodeRequest{Year: "24", StudentID: "123456", AuthorName: "著者", Title: "題名"}, errInvalidAcademicYear},
+		{ThesisBarcodeRequest{Year: "2024", StudentID: "12345", AuthorName: "著者", Title: "題名"}, errInvalidStudentID},
+		{ThesisBarcodeRequest{Year: "2024", StudentID: "111111", AuthorName: "著者", Title: "題名", Degree: "phd"}, errInvalidDegree},
+		{ThesisBarcodeRequest{Year: "2024", StudentID: "111111", AuthorName: "著者", Title: "題名", BookID: &book}, errNotThesis},
+		{ThesisBarcodeRequest{Year: "2024", StudentID: "123456", AuthorName: "著者", Title: "題名", BookID: &other.BookID}, errDuplicateThesis},
+	} {
+		expectError(t, s.do("POST", "/api/v1/admin/barcode/generate-thesis", tc.req, s.adminToken), tc.want)
+	}
+}
+
+func TestThesisBrowse(t *testing.T) {
+	s := newTestServer(t)
+	for _, tc := range []struct {
+		year, studentID, degree, supervisor string
+	}{
+		{"2023", "230001", "bachelor", "田中 教授"},
+		{"2024", "240001", "bachelor", "田中 教授"},
+		{"2024", "240002", "master", "佐藤 教授"},
+	} {
+		rec := s.do("POST", "/api/v1/admin/barcode/generate-thesis", ThesisBarcodeRequest{
+			Year: tc.year, StudentID: tc.studentID, AuthorName: "学生 " + tc.studentID, Title: "論文 " + tc.studentID,
+			Degree: tc.degree, Supervisors: []string{tc.supervisor},
+		}, s.adminToken)
+		expectStatus(t, rec, http.StatusOK)
+	}
+
+	list := func(query string) []ThesisResponse {
+		t.Helper()
+		rec := s.do("GET", "/api/v1/theses?"+query, nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		var page PageResponse[ThesisResponse]
+		decode(t, rec, &page)
+		return page.Items
+	}
+	if items := list("academic_year=2024"); len(items) != 2 {
+		t.Fatalf("2024 = %+v", items)
+	}
+	if items := list("supervisor=田中+教授&degree=bachelor"); len(items) != 2 {
+		t.Fatalf("supervisor = %+v", items)
+	}
+	if items := list("degree=master"); len(items) != 1 || items[0].StudentID != "240002" {
+		t.Fatalf("master = %+v", items)
+	}
+	expectError(t, s.do("GET", "/api/v1/theses?academic_year=x", nil, ""), errInvalidAcademicYear)
+	expectError(t, s.do("GET", "/api/v1/theses?degree=phd", nil, ""), errInvalidDegree)
+
+	rec := s.do("GET", "/api/v1/theses/index", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	var index ThesisIndexResponse
+	decode(t, rec, &index)
+	if want := []FacetCountResponse{{"2024", 2}, {"2023", 1}}; !reflect.DeepEqual(index.Years, want) {
+		t.Errorf("years = %+v", index.Years)
+	}
+	if want := []FacetCountResponse{{"田中 教授", 2}, {"佐藤 教授", 1}}; !reflect.DeepEqual(index.Supervisors, want) {
+		t.Errorf("supervisors = %+v", index.Supervisors)
+	}
+}
+
+func TestThesisUpdateAndPDF(t *testing.T) {
+	s := newTestServer(t)
+	id := s.generateThesisBarcode("2024", "123456").BookID
+
+	degree, abstract := "doctor", "新しい要旨"
+	rec := s.do("PUT", "/api/v1/admin/theses/"+id.String(), UpdateThesisRequest{Degree: &degree, Abstract: &abstract, Supervisors: []string{"鈴木 教授"}}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if thesis := s.thesis(id); thesis.Degree != "doctor" || thesis.Abstract != "新しい要旨" || !reflect.DeepEqual(thesis.Supervisors, []string{"鈴木 教授"}) {
+		t.Fatalf("updated thesis = %+v", thesis)
+	}
+	book := s.createBook(models.Book{Title: "一般書", Author: "著者", Type: "book", TotalCopies: 1})
+	expectError(t, s.do("PUT", "/api/v1/admin/theses/"+book.String(), UpdateThesisRequest{}, s.adminToken), errNotThesis)
+	bad := "phd"
+	expectError(t, s.do("PUT", "/api/v1/admin/theses/"+id.String(), UpdateThesisRequest{Degree: &bad}, s.adminToken), errInvalidDegree)
+
+	pdf := testPDF
+	expectError(t, s.do("GET", "/api/v1/theses/"+id.String()+"/pdf", nil, ""), errPDFNotFound)
+	expectError(t, s.postForm("/api/v1/admin/theses/"+id.String()+"/pdf", nil, nil, s.adminToken), errPDFRequired)
+	expectError(t, s.postForm("/api/v1/admin/theses/"+id.String()+"/pdf", nil, []formFile{{"pdf", "thesis.pdf", []byte("not a pdf")}}, s.adminToken), errUnsupportedPDFType)
+
+	expectStatus(t, s.postForm("/api/v1/admin/theses/"+id.String()+"/pdf", nil, []formFile{{"pdf", "thesis.pdf", pdf}}, s.adminToken), http.StatusOK)
+	if !s.thesis(id).HasPDF {
+		t.Fatal("has_pdf = false after upload")
+	}
+	rec = s.do("GET", "/api/v1/theses/"+id.String()+"/pdf", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != string(pdf) {
+		t.Fatalf("pdf = %q", rec.Body.String())
+	}
+
+	expectStatus(t, s.do("DELETE", "/api/v1/admin/theses/"+id.String()+"/pdf", nil, s.adminToken), http.StatusOK)
+	expectError(t, s.do("DELETE", "/api/v1/admin/theses/"+id.String()+"/pdf", nil, s.adminToken), errPDFNotFound)
+	expectError(t, s.do("GET", "/api/v1/theses/"+uuid.New().String(), nil, ""), errThesisNotFound)
+}
@@ -0,0 +1,445 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+	"lablib/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userImportFields はユーザーの一括登録で読む項目
+var userImportFields = []importField{
+	{"student_id", []string{"学籍番号", "学生番号", "ID"}},
+	{"name", []string{"氏名", "名前"}},
+	{"email", []string{"mail", "メール", "メールアドレス"}},
+	{"role", []string{"ロール", "権限"}},
+}
+
+// 一括登録の行の結果
+const (
+	userImportCreated = "created"
+	userImportUpdated = "updated"
+	userImportSkipped = "skipped"
+	userImportError   = "error"
+)
+
+// 登録済みの学籍番号の行の扱い（existing）
+const (
+	existingSkip   = "skip"   // 変更しない
+	existingUpdate = "update" // 氏名・メールアドレス・ロール（列に値がある場合）を更新し、利用停止を解除する
+)
+
+// 作成したユーザーに発行する資格情報（credentials）
+const (
+	credentialsPassword = "password" // 初期パスワード
+	credentialsInvite   = "invite"   // 利用者自身がパスワードを設定する招待のトークン
+)
+
+// credentialSheetFormats は一括登録の結果の形式。json 以外は資格情報の一覧表としてダウンロードさせる。
+var credentialSheetFormats = []string{"json", ExportCSV, ExportXLSX}
+
+// parseUserImportTable はユーザーの一括登録の表を読み取る。学籍番号と氏名の列を必須にする。
+func parseUserImportTable(format string, r io.ReaderAt, size int64, sheet string, mapping map[string]string) (*importTable, error) {
+	t, err := parseImportTable(format, r, size, sheet, userImportFields, mapping)
+	if err != nil {
+		return nil, err
+	}
+	_, hasStudentID := t.columns["student_id"]
+	_, hasName := t.columns["name"]
+	if !hasStudentID || !hasName {
+		return nil, errInvalidMapping
+	}
+	return t, nil
+}
+
+// validUserStudentID は users.student_id に保存できる学籍番号（8文字以内の英数字）かどうかを返す
+func validUserStudentID(id string) bool {
+	if id == "" || len(id) > 8 {
+		return false
+	}
+	for _, r := range id {
+		if !('0' <= r && r <= '9' || 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// user は1行からユーザーを作る。ロールの列がない・空の場合は Role を空にする
+// （作成するユーザーは user、登録済みのユーザーはロールを変更しない）。
+// エラーの場合も読み取れた項目を設定したユーザーを返す。
+func (t *importTable) user(rec importRecord) (*models.User, error) {
+	u := &models.User{
+		StudentID: t.value(rec, "student_id"),
+		Name:      t.value(rec, "name"),
+		Email:     t.value(rec, "email"),
+		Role:      strings.ToLower(t.value(rec, "role")),
+	}
+
+	if !validUserStudentID(u.StudentID) {
+		return u, errInvalidUserStudentID
+	}
+	if u.Name == "" {
+		return u, errMissingFields
+	}
+	if err := validateName(u.Name); err != nil {
+		return u, err
+	}
+	if err := validateEmail(u.Email); err != nil {
+		return u, err
+	}
+	if u.Role != "" && !containsString(models.UserRoles, u.Role) {
+		return u, errInvalidRole
+	}
+	return u, nil
+}
+
+// userImportOptions は一括登録のフォーム項目
+type userImportOptions struct {
+	existing    string
+	credentials string
+	dryRun      bool
+	self        *uuid.UUID // 一括登録する管理者（自分自身の管理者権限は外さない）
+}
+
+// importUser は検証済みの1行を登録し、row に結果を設定する。
+// 作成したユーザーの資格情報は row にのみ設定し、パスワードはハッシュ、招待のトークンは SHA-256 だけを保存する。
+// 行の内容で登録できない場合は apiError を返す。
+func importUser(ctx context.Context, tx repository.Store, u *models.User, opts userImportOptions, row *UserImportRowResponse) error {
+	existing, err := tx.Users().GetByStudentID(ctx, u.StudentID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	now := time.Now()
+
+	if existing != nil {
+		row.UserID = &existing.ID
+		if opts.existing == existingSkip {
+			row.Status = userImportSkipped
+			return nil
+		}
+		if u.Role != "" && u.Role != "admin" && existing.Role == "admin" && opts.self != nil && *opts.self == existing.ID {
+			return errCannotDemoteSelf
+		}
+		row.Status = userImportUpdated
+		if u.Role != "" {
+			existing.Role = u.Role
+		}
+		row.Role = existing.Role
+		if opts.dryRun {
+			return nil
+		}
+		existing.Name = u.Name
+		existing.Email = u.Email
+		existing.Status = models.UserStatusActive
+		existing.DeactivatedAt = nil
+		existing.UpdatedAt = now
+		return tx.Users().Update(ctx, existing)
+	}
+
+	row.Status = userImportCreated
+	if u.Role == "" {
+		u.Role = "user"
+	}
+	row.Role = u.Role
+	if opts.dryRun {
+		return nil
+	}
+	// 招待の場合も推測できないパスワードを設定し、招待を使うまでログインできないようにする
+	password, err := randomPassword()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.ID = uuid.New()
+	u.Password = string(hashedPassword)
+	u.Status = models.UserStatusActive
+	u.CreatedAt = now
+	u.UpdatedAt = now
+	if err := tx.Users().Create(ctx, u); err != nil {
+		return fmt.Errorf("create user %s: %w", u.StudentID, err)
+	}
+	row.UserID = &u.ID
+
+	if opts.credentials == credentialsPassword {
+		row.Password = password
+		return nil
+	}
+	token, hash, err := newInviteToken()
+	if err != nil {
+		return err
+	}
+	invite := models.UserInvite{TokenHash: hash, UserID: u.ID, ExpiresAt: now.Add(InviteTTL), CreatedAt: now}
+	if err := tx.Users().CreateInvite(ctx, &invite); err != nil {
+		return err
+	}
+	row.InviteToken = token
+	row.InviteExpiresAt = &invite.ExpiresAt
+	return nil
+}
+
+// ImportUsers - CSV・XLSX の名簿からのユーザーの一括登録（管理者のみ）。
+// すべての行を1つのトランザクションで登録し、エラーの行は登録せずに結果に含める。
+// 作成したユーザーの初期パスワード・招待のトークンは結果でのみ返し、format=csv・xlsx の場合は配布用の一覧表としてダウンロードさせる。
+func (h *Handler) ImportUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	opts := userImportOptions{
+		existing:    c.DefaultPostForm("existing", existingSkip),
+		credentials: c.DefaultPostForm("credentials", credentialsPassword),
+		self:        currentViewer(c).userID,
+	}
+	if opts.existing != existingSkip && opts.existing != existingUpdate {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	if opts.credentials != credentialsPassword && opts.credentials != credentialsInvite {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	format := strings.ToLower(c.DefaultPostForm("format", "json"))
+	if !containsString(credentialSheetFormats, format) {
+		respondError(c, errInvalidRequest)
+		return
+	}
+	var err error
+	if opts.dryRun, err = formBool(c, "dry_run"); err != nil {
+		respondErr(c, err)
+		return
+	}
+	var mapping map[string]string
+	if v := c.PostForm("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &mapping); err != nil {
+			respondError(c, errInvalidMapping)
+			return
+		}
+	}
+	sheet := strings.TrimSpace(c.PostForm("sheet"))
+
+	data, _, fileFormat, err := readImportFile(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	table, err := parseUserImportTable(fileFormat, bytes.NewReader(data), int64(len(data)), sheet, mapping)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	var result UserImportResponse
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		result = UserImportResponse{DryRun: opts.dryRun, Rows: make([]UserImportRowResponse, 0, len(table.records))}
+		seen := map[string]bool{}
+		for _, rec := range table.records {
+			u, err := table.user(rec)
+			row := UserImportRowResponse{
+				RowNumber: rec.number,
+				StudentID: u.StudentID,
+				Name:      u.Name,
+				Email:     u.Email,
+				Role:      u.Role,
+				Messages:  []ImportMessageResponse{},
+			}
+			code := ""
+			var invalid apiError
+			switch {
+			case errors.As(err, &invalid):
+				code = invalid.Code
+			case seen[u.StudentID]:
+				code = "duplicate_user_in_file"
+			default:
+				seen[u.StudentID] = true
+				if err := importUser(ctx, tx, u, opts, &row); errors.As(err, &invalid) {
+					code = invalid.Code
+				} else if err != nil {
+					return err
+				} else if row.Status == userImportSkipped {
+					code = "user_exists"
+				}
+			}
+			if code != "" {
+				if row.Status == "" {
+					row.Status = userImportError
+				}
+				row.Messages = append(row.Messages, ImportMessageResponse{Code: code, Message: message(c, code)})
+			}
+
+			switch row.Status {
+			case userImportCreated:
+				result.Created++
+			case userImportUpdated:
+				result.Updated++
+			case userImportSkipped:
+				result.Skipped++
+			case userImportError:
+				result.Errors++
+			}
+			result.Rows = append(result.Rows, row)
+		}
+		return nil
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	log.Printf("Users imported: %d created, %d updated, %d skipped, %d errors (dry run: %t)",
+		result.Created, result.Updated, result.Skipped, result.Errors, result.DryRun)
+	// 資格情報を含むため、中継するプロキシやブラウザに保存させない
+	c.Header("Cache-Control", "no-store")
+	if format == "json" {
+		respond(c, http.StatusOK, result)
+		return
+	}
+	writeCredentialSheet(c, format, result.Rows)
+}
+
+// writeCredentialSheet は一括登録の結果を配布用の一覧表として書き出す
+func writeCredentialSheet(c *gin.Context, format string, rows []UserImportRowResponse) {
+	header := []string{"row_number", "student_id", "name", "email", "role", "status", "password", "invite_token", "invite_expires_at", "message"}
+	filename := fmt.Sprintf("user-credentials-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Type", exportFormats[format])
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+
+	w, err := spreadsheet.NewWriter(format, c.Writer, header)
+	if err != nil {
+		log.Printf("Credential sheet aborted: %v", err)
+		return
+	}
+	for _, row := range rows {
+		messages := make([]string, len(row.Messages))
+		for i, m := range row.Messages {
+			messages[i] = m.Message
+		}
+		values := []interface{}{row.RowNumber, row.StudentID, row.Name, row.Email, row.Role, row.Status,
+			row.Password, row.InviteToken, row.InviteExpiresAt, strings.Join(messages, " ")}
+		if err := w.Write(values); err != nil {
+			log.Printf("Credential sheet aborted: %v", err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Credential sheet aborted: %v", err)
+	}
+}
+
+// parseDeactivateRequest は一括利用停止のリクエストを読み取る。
+// 対象は JSON の student_ids、または multipart の file（学籍番号の列を持つ CSV・XLSX）で指定する。
+func parseDeactivateRequest(c *gin.Context) (*DeactivateUsersRequest, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		force, err := formBool(c, "force")
+		if err != nil {
+			return nil, err
+		}
+		data, _, format, err := readImportFile(c)
+		if err != nil {
+			return nil, err
+		}
+		fields := []importField{userImportFields[0]}
+		t, err := parseImportTable(format, bytes.NewReader(data), int64(len(data)), strings.TrimSpace(c.PostForm("sheet")), fields, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := t.columns["student_id"]; !ok {
+			return nil, errInvalidMapping
+		}
+		req := &DeactivateUsersRequest{Status: c.PostForm("status"), Force: force}
+		for _, rec := range t.records {
+			req.StudentIDs = append(req.StudentIDs, t.value(rec, "student_id"))
+		}
+		return req, nil
+	}
+
+	var req DeactivateUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, errInvalidRequest
+	}
+	return &req, nil
+}
+
+// DeactivateUsers - 卒業・修了などによるユーザーの一括利用停止（管理者のみ）。
+// ユーザーは削除せずに状態を変えるため、貸出の履歴やランキングはそのまま残る。
+// 返却されていない貸出があるユーザー（force を指定しない場合）と自分自身は利用停止にせず、理由を skipped に含める。
+func (h *Handler) DeactivateUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	req, err := parseDeactivateRequest(c)
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+	if req.Status == "" {
+		req.Status = models.UserStatusGraduated
+	}
+	if req.Status != models.UserStatusGraduated && req.Status != models.UserStatusDeactivated {
+		respondError(c, errInvalidUserStatus)
+		return
+	}
+	if len(req.StudentIDs) == 0 {
+		respondError(c, errMissingFields)
+		return
+	}
+	self := currentViewer(c).userID
+
+	var result DeactivateUsersResponse
+	err = h.store.WithTx(ctx, func(tx repository.Store) error {
+		result = DeactivateUsersResponse{Deactivated: []models.UserResponse{}, Skipped: []DeactivateSkipResponse{}}
+		skip := func(studentID, code string) {
+			result.Skipped = append(result.Skipped, DeactivateSkipResponse{StudentID: studentID, Code: code, Message: message(c, code)})
+		}
+		seen := map[string]bool{}
+		for _, studentID := range req.StudentIDs {
+			studentID = strings.TrimSpace(studentID)
+			if studentID == "" || seen[studentID] {
+				continue
+			}
+			seen[studentID] = true
+
+			user, err := tx.Users().GetByStudentID(ctx, studentID)
+			if errors.Is(err, repository.ErrNotFound) {
+				skip(studentID, "user_not_found")
+				continue
+			} else if err != nil {
+				return err
+			}
+			if !user.Active() {
+				skip(studentID, "already_inactive")
+				continue
+			}
+			var invalid apiError
+			if err := changeUserStatus(ctx, tx, user, req.Status, req.Force, self); errors.As(err, &invalid) {
+				skip(studentID, invalid.Code)
+				continue
+			} else if err != nil {
+				return err
+			}
+			result.Deactivated = append(result.Deactivated, newUserResponse(*user))
+		}
+		return nil
+	})
+	if err != nil {
+		respondErr(c, err)
+		return
+	}
+
+	log.Printf("Users deactivated: %d (skipped: %d)", len(result.Deactivated), len(result.Skipped))
+	respond(c, http.StatusOK, result)
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lablib/models"
+)
+
+// importUsers はユーザーの名簿 CSV を一括登録する
+func (s *testServer) importUsers(csv string, fields map[string]string) UserImportResponse {
+	s.t.Helper()
+	rec := s.postForm("/api/v1/admin/users/import", fields, []formFile{{"file", "users.csv", []byte(csv)}}, s.adminToken)
+	expectStatus(s.t, rec, http.StatusOK)
+	var res UserImportResponse
+	decode(s.t, rec, &res)
+	return res
+}
+
+// login は学籍番号とパスワードでログインする
+func (s *testServer) login(studentID, password string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.do("POST", "/api/v1/auth/login", models.LoginRequest{StudentID: studentID, Password: password}, "")
+}
+
+const testRosterCSV = "学籍番号,氏名,メールアドレス,権限\n" +
+	"s2401,佐藤 花子,hanako@example.com,\n" +
+	"s2402,鈴木 一郎,,admin\n" +
+	"s2401,重複 花子,,\n" +
+	"s-2403,記号 三郎,,\n" +
+	"s2404,,,\n" +
+	"s2405,不正 メール,not-an-address,\n" +
+	"s2406,不正 権限,,owner\n" +
+	DefaultUserStudentID + ",一般 ユーザー,,\n"
+
+func TestImportUsers(t *testing.T) {
+	s := newTestServer(t)
+
+	// ドライランは登録しない
+	res := s.importUsers(testRosterCSV, map[string]string{"dry_run": "true"})
+	if !res.DryRun || res.Created != 2 || res.Skipped != 1 || res.Errors != 5 || res.Rows[0].Password != "" {
+		t.Fatalf("dry run = %+v", res)
+	}
+	expectError(t, s.login("s2401", "password123"), errInvalidCredentials)
+
+	res = s.importUsers(testRosterCSV, nil)
+	if res.Created != 2 || res.Updated != 0 || res.Skipped != 1 || res.Errors != 5 || len(res.Rows) != 8 {
+		t.Fatalf("import = %+v", res)
+	}
+	wantCodes := []string{"", "", "duplicate_user_in_file", "invalid_user_student_id", "missing_fields", "invalid_email", "invalid_role", "user_exists"}
+	for i, row := range res.Rows {
+		code := ""
+		if len(row.Messages) > 0 {
+			code = row.Messages[0].Code
+		}
+		if row.RowNumber != i+2 || code != wantCodes[i] {
+			t.Errorf("row %d = %+v, want code %q", i, row, wantCodes[i])
+		}
+	}
+	hanako, ichiro := res.Rows[0], res.Rows[1]
+	if hanako.Status != userImportCreated || hanako.Role != "user" || hanako.Email != "hanako@example.com" || len(hanako.Password) != initialPasswordLength {
+		t.Fatalf("created row = %+v", hanako)
+	}
+	if ichiro.Role != "admin" || ichiro.Password == "" || ichiro.InviteToken != "" {
+		t.Fatalf("admin row = %+v", ichiro)
+	}
+
+	// 発行した初期パスワードでログインできる
+	rec := s.login("s2401", hanako.Password)
+	expectStatus(t, rec, http.StatusOK)
+	var login models.LoginResponse
+	decode(t, rec, &login)
+	if login.User.ID != *hanako.UserID || login.User.Email != "hanako@example.com" {
+		t.Fatalf("login = %+v", login.User)
+	}
+
+	// 登録済みのユーザーは existing=update で更新する
+	res = s.importUsers("student_id,name,role\ns2401,佐藤 花子（改姓）,admin\n", map[string]string{"existing": "update"})
+	if res.Updated != 1 || res.Rows[0].Password != "" || *res.Rows[0].UserID != *hanako.UserID {
+		t.Fatalf("update = %+v", res)
+	}
+	u := s.userByStudentID("s2401")
+	if u.Name != "佐藤 花子（改姓）" || u.Role != "admin" || u.Email != "" {
+		t.Fatalf("updated user = %+v", u)
+	}
+}
+
+func TestImportUsersErrors(t *testing.T) {
+	s := newTestServer(t)
+	path := "/api/v1/admin/users/import"
+	csv := []formFile{{"file", "users.csv", []byte("student_id,name\ns2401,佐藤 花子\n")}}
+
+	expectError(t, s.postForm(path, nil, nil, s.adminToken), errFileRequired)
+	expectError(t, s.postForm(path, nil, []formFile{{"file", "users.csv", []byte("student_id\ns2401\n")}}, s.adminToken), errInvalidMapping)
+	for _, fields := range []map[string]string{
+		{"existing": "replace"},
+		{"credentials": "email"},
+		{"format": "pdf"},
+	} {
+		expectError(t, s.postForm(path, fields, csv, s.adminToken), errInvalidRequest)
+	}
+
+	// 資格情報の一覧表は CSV でダウンロードさせ、保存させない
+	rec := s.postForm(path, map[string]string{"format": "csv"}, csv, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Cache-Control") != "no-store" || rec.Header().Get("Content-Type") != exportFormats[ExportCSV] {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	rows := readExport(t, ExportCSV, rec.Body.Bytes())
+	if len(rows) != 2 || rows[0][6] != "password" || rows[1][1] != "s2401" || rows[1][5] != userImportCreated || len(rows[1][6]) != initialPasswordLength {
+		t.Fatalf("credential sheet = %q", rows)
+	}
+}
+
+func TestAcceptInvite(t *testing.T) {
+	s := newTestServer(t)
+	res := s.importUsers("student_id,name\ns2401,佐藤 花子\ns2402,鈴木 一郎\n", map[string]string{"credentials": "invite"})
+	if res.Created != 2 || res.Rows[0].InviteToken == "" || res.Rows[0].Password != "" || res.Rows[0].InviteExpiresAt == nil {
+		t.Fatalf("import = %+v", res)
+	}
+	token := res.Rows[0].InviteToken
+	accept := func(token, password string) *httptest.ResponseRecorder {
+		return s.do("POST", "/api/v1/auth/invite", models.AcceptInviteRequest{Token: token, Password: password}, "")
+	}
+
+	expectError(t, accept(token, "short"), errInvalidPassword)
+	expectError(t, accept("unknown", "password123"), errInvalidInvite)
+
+	rec := accept(token, "password123")
+	expectStatus(t, rec, http.StatusOK)
+	var login models.LoginResponse
+	decode(t, rec, &login)
+	if login.Token == "" || login.User.StudentID != "s2401" {
+		t.Fatalf("login = %+v", login)
+	}
+	expectStatus(t, s.login("s2401", "password123"), http.StatusOK)
+	// 招待は1回だけ使える
+	expectError(t, accept(token, "password456"), errInvalidInvite)
+
+	// 利用停止したユーザーの招待は使えない
+	expectStatus(t, s.do("POST", "/api/v1/admin/users/deactivate", DeactivateUsersRequest{StudentIDs: []string{"s2402"}}, s.adminToken), http.StatusOK)
+	expectError(t, accept(res.Rows[1].InviteToken, "password123"), errAccountInactive)
+}
+
+func TestDeactivateUsers(t *testing.T) {
+	s := newTestServer(t)
+	res := s.importUsers("student_id,name\ns2401,佐藤 花子\ns2402,鈴木 一郎\ns2403,高橋 次郎\n", nil)
+	s.createBook(models.Book{Title: "未返却", Type: "book", TotalCopies: 1, Barcode: "DU-0001"})
+	expectStatus(t, s.do("POST", "/api/v1/books/borrow", BorrowRequest{Barcode: "DU-0001", UserID: res.Rows[1].UserID.String()}, s.adminToken), http.StatusOK)
+
+	rec := s.do("POST", "/api/v1/admin/users/deactivate", DeactivateUsersRequest{
+		StudentIDs: []string{"s2401", " s2401 ", "s2402", "unknown", "", DefaultAdminStudentID},
+	}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	var result DeactivateUsersResponse
+	decode(t, rec, &result)
+	if len(result.Deactivated) != 1 || result.Deactivated[0].StudentID != "s2401" || result.Deactivated[0].Status != models.UserStatusGraduated {
+		t.Fatalf("deactivated = %+v", result.Deactivated)
+	}
+	skipped := map[string]string{}
+	for _, sk := range result.Skipped {
+		skipped[sk.StudentID] = sk.Code
+	}
+	want := map[string]string{"s2402": "has_open_loans", "unknown": "user_not_found", DefaultAdminStudentID: "cannot_deactivate_self"}
+	if len(skipped) != len(want) {
+		t.Fatalf("skipped = %+v", result.Skipped)
+	}
+	for id, code := range want {
+		if skipped[id] != code {
+			t.Errorf("skipped[%s] = %q, want %q", id, skipped[id], code)
+		}
+	}
+
+	// 利用停止したユーザーはログインできない
+	expectError(t, s.login("s2401", res.Rows[0].Password), errAccountInactive)
+
+	// 学籍番号の列を持つファイルでも指定できる
+	rec = s.postForm("/api/v1/admin/users/deactivate", map[string]string{"status": models.UserStatusDeactivated},
+		[]formFile{{"file", "graduates.csv", []byte("学籍番号,氏名\ns2401,佐藤 花子\ns2403,高橋 次郎\n")}}, s.adminToken)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &result)
+	if len(result.Deactivated) != 1 || result.Deactivated[0].Status != models.UserStatusDeactivated ||
+		len(result.Skipped) != 1 || result.Skipped[0].Code != "already_inactive" {
+		t.Fatalf("file deactivation = %+v", result)
+	}
+
+	// 一括登録の existing=update で利用停止を解除する
+	res = s.importUsers("student_id,name\ns2401,佐藤 花子\n", map[string]string{"existing": "update"})
+	if u := s.userByStudentID("s2401"); res.Updated != 1 || !u.Active() || u.DeactivatedAt != nil {
+		t.Fatalf("reactivated user = %+v", u)
+	}
+
+	path := "/api/v1/admin/users/deactivate"
+	expectError(t, s.do("POST", path, DeactivateUsersRequest{}, s.adminToken), errMissingFields)
+	expectError(t, s.do("POST", path, DeactivateUsersRequest{StudentIDs: []string{"s2403"}, Status: "active"}, s.adminToken), errInvalidUserStatus)
+	expectError(t, s.postForm(path, nil, []formFile{{"file", "ids.csv", []byte("氏名\n佐藤 花子\n")}}, s.adminToken), errInvalidMapping)
+}
@@ -0,0 +1,208 @@
+// Package circulation は貸出・返却・延長のルールを実装する。
+// HTTP ハンドラーや将来の CLI・キオスク端末はこのパッケージを経由して貸出状態を変更する。
+package circulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+// Policy は貸出期間などの運用ルール
+type Policy struct {
+	LoanPeriod  time.Duration // 貸出期間
+	MaxRenewals int           // 1件の貸出で延長できる回数
+}
+
+// DefaultPolicy は貸出期間2週間・延長1回までのルールを返す
+func DefaultPolicy() Policy {
+	return Policy{
+		LoanPeriod:  14 * 24 * time.Hour,
+		MaxRenewals: 1,
+	}
+}
+
+// Service は貸出・返却の各操作を提供する
+type Service struct {
+	store  repository.Store
+	policy Policy
+	now    func() time.Time
+}
+
+// NewService は store と policy を使う Service を返す
+func NewService(store repository.Store, policy Policy) *Service {
+	return &Service{store: store, policy: policy, now: time.Now}
+}
+
+// CheckoutRequest は貸出対象の指定。Barcode と BookID のどちらか一方を指定する。
+type CheckoutRequest struct {
+	UserID  uuid.UUID
+	Barcode string
+	BookID  uuid.UUID
+}
+
+// Checkout は貸出可能なコピーを1件選び、貸出記録の作成・コピーの状態更新・月次ランキングの加算を
+// 1つのトランザクションで行う
+func (s *Service) Checkout(ctx context.Context, req CheckoutRequest) (*models.BorrowRecord, error) {
+	if req.Barcode == "" && req.BookID == uuid.Nil {
+		return nil, ErrInvalidRequest
+	}
+
+	var record *models.BorrowRecord
+	err := s.store.WithTx(ctx, func(tx repository.Store) error {
+		if err := s.checkUser(ctx, tx, req.UserID); err != nil {
+			return err
+		}
+
+		var bookCopy *models.BookCopy
+		var err error
+		if req.Barcode != "" {
+			bookCopy, err = tx.Copies().FindAvailableByBarcode(ctx, req.Barcode)
+		} else {
+			bookCopy, err = tx.Copies().FindAvailableByBook(ctx, req.BookID)
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNoAvailableCopy
+		} else if err != nil {
+			return fmt.Errorf("書籍コピー取得エラー: %w", err)
+		}
+		book, err := tx.Books().Get(ctx, bookCopy.BookID)
+		if err != nil {
+			return fmt.Errorf("書籍取得エラー: %w", err)
+		}
+		if book.WithdrawnAt != nil {
+			return ErrBookWithdrawn
+		}
+
+		borrowedAt := s.now()
+		record = &models.BorrowRecord{
+			ID:         uuid.New(),
+			UserID:     req.UserID,
+			BookCopyID: bookCopy.ID,
+			BorrowedAt: borrowedAt,
+			DueDate:    borrowedAt.Add(s.policy.LoanPeriod),
+			Status:     "borrowed",
+		}
+		if err := tx.Loans().Create(ctx, record); err != nil {
+			return fmt.Errorf("貸出記録作成エラー: %w", err)
+		}
+
+		if err := tx.Copies().SetAvailable(ctx, bookCopy.ID, false); err != nil {
+			return fmt.Errorf("書籍コピー状態更新エラー: %w", err)
+		}
+
+		if err := tx.Rankings().Increment(ctx, borrowedAt.Format("2006-01"), bookCopy.BookID); err != nil {
+			return fmt.Errorf("ランキング更新エラー: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// CheckinRequest は返却対象の指定
+type CheckinRequest struct {
+	UserID  uuid.UUID
+	Barcode string
+}
+
+// Checkin は利用者が借りているコピーを返却済みにし、貸出可能に戻す
+func (s *Service) Checkin(ctx context.Context, req CheckinRequest) (*models.BorrowRecord, error) {
+	if req.Barcode == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	var record *models.BorrowRecord
+	err := s.store.WithTx(ctx, func(tx repository.Store) error {
+		bookCopy, err := tx.Copies().FindByBarcode(ctx, req.Barcode)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrCopyNotFound
+		} else if err != nil {
+			return fmt.Errorf("書籍コピー取得エラー: %w", err)
+		}
+
+		record, err = tx.Loans().FindOpen(ctx, bookCopy.ID, req.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrLoanNotFound
+		} else if err != nil {
+			return fmt.Errorf("貸出記録取得エラー: %w", err)
+		}
+
+		returnedAt := s.now()
+		if err := tx.Loans().MarkReturned(ctx, record.ID, returnedAt); err != nil {
+			return fmt.Errorf("返却記録更新エラー: %w", err)
+		}
+		record.ReturnedAt = &returnedAt
+		record.Status = "returned"
+
+		if err := tx.Copies().SetAvailable(ctx, bookCopy.ID, true); err != nil {
+			return fmt.Errorf("書籍コピー状態更新エラー: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Renew は返却期限前の貸出を、現在時刻から貸出期間分延長する
+func (s *Service) Renew(ctx context.Context, loanID uuid.UUID) (*models.BorrowRecord, error) {
+	var record *models.BorrowRecord
+	err := s.store.WithTx(ctx, func(tx repository.Store) error {
+		var err error
+		record, err = tx.Loans().Get(ctx, loanID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrLoanNotFound
+		} else if err != nil {
+			return fmt.Errorf("貸出記録取得エラー: %w", err)
+		}
+
+		if err := s.checkUser(ctx, tx, record.UserID); err != nil {
+			return err
+		}
+
+		now := s.now()
+		switch {
+		case record.Status != "borrowed":
+			return ErrAlreadyReturned
+		case now.After(record.DueDate):
+			return ErrOverdue
+		case record.RenewCount >= s.policy.MaxRenewals:
+			return ErrRenewLimitReached
+		}
+
+		record.DueDate = now.Add(s.policy.LoanPeriod)
+		record.RenewCount++
+		if err := tx.Loans().UpdateDueDate(ctx, record.ID, record.DueDate, record.RenewCount); err != nil {
+			return fmt.Errorf("返却期限更新エラー: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// checkUser は貸出・延長の対象のユーザーが存在し、利用停止・卒業の状態でないことを確認する
+func (s *Service) checkUser(ctx context.Context, tx repository.Store, userID uuid.UUID) error {
+	user, err := tx.Users().Get(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return fmt.Errorf("ユーザー確認エラー: %w", err)
+	}
+	if !user.Active() {
+		return ErrUserInactive
+	}
+	return nil
+}
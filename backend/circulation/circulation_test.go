@@ -0,0 +1,293 @@
+package circulation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+	"lablib/repository/memory"
+
+	"github.com/google/uuid"
+)
+
+// fixture はメモリのデータストアに利用者1人と書籍1冊を登録した Service
+type fixture struct {
+	t     *testing.T
+	store *memory.Store
+	svc   *Service
+	now   time.Time
+	user  models.User
+	book  models.Book
+}
+
+// newFixture は barcode のコピーを copies 冊持つ書籍を登録する
+func newFixture(t *testing.T, barcode string, copies int) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{t: t, store: memory.NewStore(), now: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)}
+	f.svc = NewService(f.store, DefaultPolicy())
+	f.svc.now = func() time.Time { return f.now }
+
+	f.user = models.User{ID: uuid.New(), StudentID: "s2401", Name: "佐藤 花子", Role: "user", Status: models.UserStatusActive}
+	if err := f.store.Users().Create(ctx, &f.user); err != nil {
+		t.Fatal(err)
+	}
+	f.book = models.Book{ID: uuid.New(), Title: "貸出テスト", Type: "book", TotalCopies: copies, Barcode: barcode}
+	if err := f.store.Books().Create(ctx, &f.book); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < copies; i++ {
+		c := models.BookCopy{ID: uuid.New(), BookID: f.book.ID, Barcode: barcode, IsAvailable: true}
+		if err := f.store.Copies().Create(ctx, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func (f *fixture) available() int {
+	f.t.Helper()
+	n, err := f.store.Copies().CountAvailableByBook(context.Background(), f.book.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return n
+}
+
+func (f *fixture) checkout() *models.BorrowRecord {
+	f.t.Helper()
+	record, err := f.svc.Checkout(context.Background(), CheckoutRequest{UserID: f.user.ID, Barcode: f.book.Barcode})
+	if err != nil {
+		f.t.Fatalf("Checkout: %v", err)
+	}
+	return record
+}
+
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %v", err, want)
+	}
+}
+
+func TestCheckoutCheckin(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "BC-0001", 2)
+
+	record := f.checkout()
+	if record.Status != "borrowed" || !record.BorrowedAt.Equal(f.now) || !record.DueDate.Equal(f.now.Add(14*24*time.Hour)) {
+		t.Fatalf("record = %+v", record)
+	}
+	if n := f.available(); n != 1 {
+		t.Fatalf("available copies = %d", n)
+	}
+	ranking, err := f.store.Rankings().Monthly(ctx, "2024-04", 10)
+	if err != nil || len(ranking) != 1 || ranking[0].BorrowCount != 1 {
+		t.Fatalf("ranking = %+v, %v", ranking, err)
+	}
+
+	// 書籍ID指定では残りのコピーを貸し出す
+	if _, err := f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID, BookID: f.book.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.available(); n != 0 {
+		t.Fatalf("available copies = %d", n)
+	}
+
+	f.now = f.now.Add(time.Hour)
+	returned, err := f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID, Barcode: "BC-0001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returned.Status != "returned" || returned.ReturnedAt == nil || !returned.ReturnedAt.Equal(f.now) {
+		t.Fatalf("returned = %+v", returned)
+	}
+	if n := f.available(); n != 1 {
+		t.Fatalf("available copies after checkin = %d", n)
+	}
+	stored, err := f.store.Loans().Get(ctx, returned.ID)
+	if err != nil || stored.Status != "returned" || stored.ReturnedAt == nil {
+		t.Fatalf("stored loan = %+v, %v", stored, err)
+	}
+}
+
+// 同じコピーは同時に1人にしか貸し出せない
+func TestCheckoutSameCopyTwice(t *testing.T) {
+	f := newFixture(t, "BC-0002", 1)
+	f.checkout()
+	_, err := f.svc.Checkout(context.Background(), CheckoutRequest{UserID: f.user.ID, Barcode: "BC-0002"})
+	expectErr(t, err, ErrNoAvailableCopy)
+	_, err = f.svc.Checkout(context.Background(), CheckoutRequest{UserID: f.user.ID, BookID: f.book.ID})
+	expectErr(t, err, ErrNoAvailableCopy)
+
+	// 同時に貸し出しても成功するのは1件だけ
+	f = newFixture(t, "BC-0003", 1)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.svc.Checkout(context.Background(), CheckoutRequest{UserID: f.user.ID, Barcode: "BC-0003"})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			} else if !errors.Is(err, ErrNoAvailableCopy) {
+				t.Errorf("Checkout: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("%d checkouts succeeded", succeeded)
+	}
+	loans, _, err := f.store.Loans().List(context.Background(), repository.LoanFilter{})
+	if err != nil || len(loans) != 1 {
+		t.Fatalf("loans = %d, %v", len(loans), err)
+	}
+}
+
+func TestCheckoutCheckinErrors(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "BC-0004", 1)
+
+	_, err := f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID})
+	expectErr(t, err, ErrInvalidRequest)
+	_, err = f.svc.Checkout(ctx, CheckoutRequest{UserID: uuid.New(), Barcode: "BC-0004"})
+	expectErr(t, err, ErrUserNotFound)
+	_, err = f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID, Barcode: "NONE"})
+	expectErr(t, err, ErrNoAvailableCopy)
+
+	_, err = f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID})
+	expectErr(t, err, ErrInvalidRequest)
+	_, err = f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID, Barcode: "NONE"})
+	expectErr(t, err, ErrCopyNotFound)
+	_, err = f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID, Barcode: "BC-0004"})
+	expectErr(t, err, ErrLoanNotFound)
+
+	// 他の利用者が借りているコピーは返却できない
+	f.checkout()
+	_, err = f.svc.Checkin(ctx, CheckinRequest{UserID: uuid.New(), Barcode: "BC-0004"})
+	expectErr(t, err, ErrLoanNotFound)
+	if n := f.available(); n != 0 {
+		t.Fatalf("available copies = %d", n)
+	}
+
+}
+
+func TestRenew(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "BC-0005", 1)
+	record := f.checkout()
+
+	// 延長後の返却期限は延長した時点から貸出期間後
+	f.now = f.now.Add(10 * 24 * time.Hour)
+	renewed, err := f.svc.Renew(ctx, record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.RenewCount != 1 || !renewed.DueDate.Equal(f.now.Add(14*24*time.Hour)) {
+		t.Fatalf("renewed = %+v", renewed)
+	}
+	stored, err := f.store.Loans().Get(ctx, record.ID)
+	if err != nil || stored.RenewCount != 1 || !stored.DueDate.Equal(renewed.DueDate) {
+		t.Fatalf("stored loan = %+v, %v", stored, err)
+	}
+
+	// 延長は1回まで
+	_, err = f.svc.Renew(ctx, record.ID)
+	expectErr(t, err, ErrRenewLimitReached)
+
+	_, err = f.svc.Renew(ctx, uuid.New())
+	expectErr(t, err, ErrLoanNotFound)
+
+	if _, err := f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID, Barcode: "BC-0005"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.svc.Renew(ctx, record.ID)
+	expectErr(t, err, ErrAlreadyReturned)
+}
+
+// 利用停止・卒業したユーザーには貸出・延長できないが、返却はできる
+func TestInactiveUser(t *testing.T) {
+	ctx := context.Background()
+	for _, status := range []string{models.UserStatusDeactivated, models.UserStatusGraduated} {
+		f := newFixture(t, "BC-0008", 1)
+		record := f.checkout()
+		f.user.Status = status
+		if err := f.store.Users().Update(ctx, &f.user); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID, Barcode: "BC-0008"})
+		expectErr(t, err, ErrUserInactive)
+		_, err = f.svc.Renew(ctx, record.ID)
+		expectErr(t, err, ErrUserInactive)
+		if _, err := f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID, Barcode: "BC-0008"}); err != nil {
+			t.Fatalf("%s: Checkin: %v", status, err)
+		}
+		_, err = f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID, Barcode: "BC-0008"})
+		expectErr(t, err, ErrUserInactive)
+		if n := f.available(); n != 1 {
+			t.Fatalf("%s: available copies = %d", status, n)
+		}
+	}
+}
+
+// 除籍した書籍は貸し出せないが、貸出中のコピーは返却できる
+func TestWithdrawnBook(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "BC-0009", 1)
+	withdraw := func(at *time.Time) {
+		t.Helper()
+		if err := f.store.Books().SetWithdrawn(ctx, f.book.ID, at, "紛失"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	withdraw(&f.now)
+
+	_, err := f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID, Barcode: "BC-0009"})
+	expectErr(t, err, ErrBookWithdrawn)
+	_, err = f.svc.Checkout(ctx, CheckoutRequest{UserID: f.user.ID, BookID: f.book.ID})
+	expectErr(t, err, ErrBookWithdrawn)
+
+	// 除籍を取り消すと貸し出せ、貸出中に除籍しても返却できる
+	withdraw(nil)
+	f.checkout()
+	withdraw(&f.now)
+	if _, err := f.svc.Checkin(ctx, CheckinRequest{UserID: f.user.ID, Barcode: "BC-0009"}); err != nil {
+		t.Fatalf("Checkin: %v", err)
+	}
+	if n := f.available(); n != 1 {
+		t.Fatalf("available copies = %d", n)
+	}
+}
+
+// 返却期限を過ぎた貸出は延長できない
+func TestRenewOverdue(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "BC-0006", 1)
+	record := f.checkout()
+
+	// 返却期限ちょうどは延長できる
+	f.now = record.DueDate
+	if _, err := f.svc.Renew(ctx, record.ID); err != nil {
+		t.Fatalf("Renew at the due date: %v", err)
+	}
+
+	f = newFixture(t, "BC-0007", 1)
+	record = f.checkout()
+	f.now = record.DueDate.Add(time.Second)
+	_, err := f.svc.Renew(ctx, record.ID)
+	expectErr(t, err, ErrOverdue)
+	stored, _ := f.store.Loans().Get(ctx, record.ID)
+	if stored.RenewCount != 0 || !stored.DueDate.Equal(record.DueDate) {
+		t.Fatalf("overdue loan was changed: %+v", stored)
+	}
+}
@@ -0,0 +1,34 @@
+package circulation
+
+// Error は貸出・返却ルールに違反した場合のドメインエラー。
+// HTTP ステータスへの変換は api パッケージで一括して行う。
+type Error struct {
+	Kind    Kind
+	Code    string // 機械判読用のエラーコード
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Kind はドメインエラーの分類
+type Kind int
+
+const (
+	KindInvalid   Kind = iota + 1 // リクエスト内容が不正
+	KindNotFound                  // 対象が存在しない
+	KindConflict                  // 現在の状態では実行できない
+	KindForbidden                 // 利用者が実行を許可されていない
+)
+
+var (
+	ErrInvalidRequest    = &Error{KindInvalid, "invalid_request", "バーコードまたは書籍IDが必要です"}
+	ErrUserNotFound      = &Error{KindNotFound, "user_not_found", "指定されたユーザーが見つかりません"}
+	ErrUserInactive      = &Error{KindForbidden, "account_inactive", "利用停止中のユーザーには貸出・延長できません"}
+	ErrCopyNotFound      = &Error{KindNotFound, "copy_not_found", "書籍コピーが見つかりません"}
+	ErrNoAvailableCopy   = &Error{KindNotFound, "no_available_copy", "貸出可能な書籍コピーが見つかりません"}
+	ErrBookWithdrawn     = &Error{KindConflict, "book_withdrawn", "除籍された書籍は貸出できません"}
+	ErrLoanNotFound      = &Error{KindNotFound, "loan_not_found", "貸出記録が見つかりません"}
+	ErrAlreadyReturned   = &Error{KindConflict, "already_returned", "この貸出記録は既に返却済みです"}
+	ErrOverdue           = &Error{KindConflict, "loan_overdue", "返却期限を過ぎているため延長できません"}
+	ErrRenewLimitReached = &Error{KindConflict, "renew_limit_reached", "延長回数の上限に達しています"}
+)
@@ -0,0 +1,149 @@
+// Package codes は書籍・物品に付いている ISBN・EAN-13・JAN コードの正規化と検証を提供する。
+// 入力はハイフン・空白・全角数字を含んでいてもよく、結果はハイフンなしの数字（ISBN-10 のチェックディジット X は大文字）になる。
+package codes
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrInvalid は桁数・文字種・チェックディジットのいずれかが正しくない場合のエラー
+	ErrInvalid = errors.New("コードの形式またはチェックディジットが正しくありません")
+	// ErrBookPrice は書籍JANコードの2段目（191・192で始まる分類・価格のコード）を ISBN などとして指定した場合のエラー
+	ErrBookPrice = errors.New("書籍JANコードの2段目（分類・価格コード）です")
+)
+
+// Normalize は ISBN・JAN などのコードを比較用に正規化する（ハイフン・空白の除去、英字の小文字化）。
+// "978-4-00-000000-0" と "9784000000000" は同じ値になる。
+func Normalize(s string) string {
+	s = norm.NFKC.String(s)
+	var b strings.Builder
+	for _, r := range s {
+		if r == '-' || r == '‐' || r == '−' || unicode.IsSpace(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// Clean はハイフン・空白を除き、全角の数字・英字を半角に、英字を大文字にする
+func Clean(s string) string {
+	return strings.ToUpper(Normalize(s))
+}
+
+// CheckDigit は数字のみの body に続く GS1（EAN-13・EAN-8・ISBN-13）のチェックディジットを返す。
+// 右端の桁から順に 3・1 の重みを掛けた合計から求める。
+func CheckDigit(body string) int {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		d := int(body[len(body)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// validGS1 は code が数字のみで、末尾が正しいチェックディジットかどうかを返す
+func validGS1(code string) bool {
+	return len(code) > 1 && isDigits(code) && CheckDigit(code[:len(code)-1]) == int(code[len(code)-1]-'0')
+}
+
+// IsBookPrice は code が書籍JANコードの2段目（"192" + 分類コード4桁 + 本体価格5桁 + チェックディジット）かどうかを返す。
+// 191 は2段目の旧形式。
+func IsBookPrice(code string) bool {
+	code = Clean(code)
+	return len(code) == 13 && (strings.HasPrefix(code, "191") || strings.HasPrefix(code, "192")) && validGS1(code)
+}
+
+// ISBN13 は ISBN-10 または ISBN-13 を検証し、ISBN-13 を返す
+func ISBN13(s string) (string, error) {
+	code := Clean(s)
+	switch len(code) {
+	case 13:
+		if IsBookPrice(code) {
+			return "", ErrBookPrice
+		}
+		if !(strings.HasPrefix(code, "978") || strings.HasPrefix(code, "979")) || !validGS1(code) {
+			return "", ErrInvalid
+		}
+		return code, nil
+	case 10:
+		if !isDigits(code[:9]) || !(isDigits(code[9:]) || code[9] == 'X') {
+			return "", ErrInvalid
+		}
+		// 左から 10・9・…・1 の重みを掛けた合計が 11 で割り切れる（X は 10）
+		sum := 0
+		for i := 0; i < 10; i++ {
+			d := 10
+			if code[i] != 'X' {
+				d = int(code[i] - '0')
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", ErrInvalid
+		}
+		body := "978" + code[:9]
+		return body + string(rune('0'+CheckDigit(body))), nil
+	}
+	return "", ErrInvalid
+}
+
+// ISBN10 は 978 で始まる ISBN-13 を ISBN-10 に変換する。979 で始まる ISBN-13 には対応する ISBN-10 がない。
+func ISBN10(isbn13 string) (string, bool) {
+	code := Clean(isbn13)
+	if len(code) != 13 || !strings.HasPrefix(code, "978") || !validGS1(code) {
+		return "", false
+	}
+	body := code[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", true
+	}
+	return body + string(rune('0'+check)), true
+}
+
+// EAN13 は13桁の EAN-13 を検証して返す。書籍JANコードの2段目は ErrBookPrice を返す。
+func EAN13(s string) (string, error) {
+	code := Clean(s)
+	if len(code) != 13 || !validGS1(code) {
+		return "", ErrInvalid
+	}
+	if IsBookPrice(code) {
+		return "", ErrBookPrice
+	}
+	return code, nil
+}
+
+// JAN は JAN コード（13桁の標準タイプまたは8桁の短縮タイプ）を検証して返す。
+// 書籍JANコードの2段目は ErrBookPrice を返す。
+func JAN(s string) (string, error) {
+	code := Clean(s)
+	if len(code) == 13 {
+		return EAN13(code)
+	}
+	if len(code) != 8 || !validGS1(code) {
+		return "", ErrInvalid
+	}
+	return code, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
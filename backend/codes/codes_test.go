@@ -0,0 +1,152 @@
+package codes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"978-4-00-000000-0", "9784000000000"},
+		{"９７８－４－００", "978400"},
+		{"4‐00−00000 X", "40000000x"},
+		{"BC-0001", "bc0001"},
+	} {
+		if got := Normalize(tc.in); got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	for body, want := range map[string]int{
+		"978487311752": 2,
+		"979103230569": 0,
+		"490123456789": 4,
+		"4912345":      6, // JAN 短縮タイプ
+		"192019300600": 1,
+		"978000000000": 2,
+	} {
+		if got := CheckDigit(body); got != want {
+			t.Errorf("CheckDigit(%q) = %d, want %d", body, got, want)
+		}
+	}
+}
+
+func TestISBN13(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      error
+	}{
+		{"9784873117522", "9784873117522", nil},
+		{"978-4-87311-752-2", "9784873117522", nil},
+		{" 978 4873 117522 ", "9784873117522", nil},
+		{"９７８－４－８７３１１－７５２－２", "9784873117522", nil},
+		{"9791032305690", "9791032305690", nil},
+		{"4873117526", "9784873117522", nil},
+		{"4-87311-752-6", "9784873117522", nil},
+		{"080442957X", "9780804429573", nil},
+		{"0-8044-2957-x", "9780804429573", nil},
+		{"０８０４４２９５７Ｘ", "9780804429573", nil},
+
+		{"9784873117523", "", ErrInvalid}, // チェックディジット違い
+		{"4873117527", "", ErrInvalid},
+		{"0804429570", "", ErrInvalid},
+		{"X804429570", "", ErrInvalid},    // X は末尾のみ
+		{"4901234567894", "", ErrInvalid}, // ISBN でない EAN-13
+		{"978487311752", "", ErrInvalid},
+		{"97848731175222", "", ErrInvalid},
+		{"978-4-87311-752-A", "", ErrInvalid},
+		{"", "", ErrInvalid},
+		{"1920193006001", "", ErrBookPrice},
+		{"1910193006002", "", ErrBookPrice},
+	} {
+		got, err := ISBN13(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("ISBN13(%q) = %q, %v; want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestISBN10(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"9784873117522", "4873117526", true},
+		{"978-4-87311-752-2", "4873117526", true},
+		{"9780804429573", "080442957X", true},
+		{"９７８０８０４４２９５７３", "080442957X", true},
+		{"9791032305690", "", false}, // 979 には ISBN-10 がない
+		{"9784873117523", "", false},
+		{"4873117526", "", false},
+		{"", "", false},
+	} {
+		got, ok := ISBN10(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ISBN10(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestIsBookPrice(t *testing.T) {
+	for in, want := range map[string]bool{
+		"1920193006001":    true,
+		"192-0193-00600-1": true,
+		"1910193006002":    true, // 旧形式
+		"1920193006002":    false,
+		"1930193006000":    false,
+		"9784873117522":    false,
+		"192019300600":     false,
+		"１９２０１９３００６００１": true,
+	} {
+		if got := IsBookPrice(in); got != want {
+			t.Errorf("IsBookPrice(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestEAN13AndJAN(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      error
+	}{
+		{"4901234567894", "4901234567894", nil},
+		{"49-01234-56789-4", "4901234567894", nil},
+		{"４９０１２３４５６７８９４", "4901234567894", nil},
+		{"9784873117522", "9784873117522", nil},
+		{"4901234567895", "", ErrInvalid},
+		{"490123456789", "", ErrInvalid},
+		{"1920193006001", "", ErrBookPrice},
+	} {
+		got, err := EAN13(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("EAN13(%q) = %q, %v; want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+		got, err = JAN(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("JAN(%q) = %q, %v; want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+
+	// 短縮タイプは JAN のみ
+	for _, tc := range []struct {
+		in, want string
+		err      error
+	}{
+		{"49123456", "49123456", nil},
+		{"4912-3456", "49123456", nil},
+		{"49123457", "", ErrInvalid},
+		{"4912345A", "", ErrInvalid},
+	} {
+		got, err := JAN(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("JAN(%q) = %q, %v; want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+	if _, err := EAN13("49123456"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("EAN13(8 digits) err = %v", err)
+	}
+}
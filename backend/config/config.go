@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"log"
@@ -52,4 +53,22 @@ func InitDB() {
 	}
 
 	log.Println("Successfully connected to database")
-} 
\ No newline at end of file
+}
+
+// JWTSecret はトークンの署名に使う鍵を環境変数 JWT_SECRET から読み込む。
+// 設定されていない場合は起動ごとに乱数の鍵を作る（再起動すると発行済みのトークンは使えなくなる）。
+func JWTSecret() []byte {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if len(secret) < 32 {
+			log.Println("Warning: JWT_SECRET is shorter than 32 bytes")
+		}
+		return []byte(secret)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("JWT_SECRET is not set; using a random secret (tokens are invalidated on restart)")
+	return secret
+}
@@ -4,7 +4,14 @@ CREATE TABLE IF NOT EXISTS users (
     student_id VARCHAR(8) UNIQUE NOT NULL,
     name VARCHAR(50) NOT NULL,
     password TEXT NOT NULL,
-    role VARCHAR(10) NOT NULL CHECK (role IN ('user', 'admin')),
+    role VARCHAR(10) NOT NULL CHECK (role IN ('user', 'admin', 'guest')), -- guest は自分で登録したユーザー
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'deactivated', 'graduated')),
+    deactivated_at TIMESTAMP,
+    anonymized_at TIMESTAMP, -- 削除（匿名化）した日時。貸出の履歴のためにユーザーの行は残す
+    anonymized_by UUID REFERENCES users(id) ON DELETE SET NULL, -- 匿名化した管理者
+    notify_due_reminder BOOLEAN NOT NULL DEFAULT true,
+    notify_overdue BOOLEAN NOT NULL DEFAULT true,
     created_at TIMESTAMP NOT NULL,
     updated_at TIMESTAMP NOT NULL
 );
@@ -22,10 +29,155 @@ CREATE TABLE IF NOT EXISTS books (
     barcode TEXT,
     location TEXT,
     image_path TEXT,
+    publisher TEXT NOT NULL DEFAULT '',
+    published_date VARCHAR(10) NOT NULL DEFAULT '', -- YYYY、YYYY-MM、YYYY-MM-DD のいずれか
+    published_year INTEGER,
+    edition TEXT NOT NULL DEFAULT '',
+    page_count INTEGER NOT NULL DEFAULT 0,
+    language VARCHAR(16) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    subjects TEXT[] NOT NULL DEFAULT '{}',
+    notes TEXT NOT NULL DEFAULT '',
+    search_text TEXT,       -- 正規化したタイトル・著者・コード（英数字の部分一致用）
+    search_vector TSVECTOR, -- 日本語を unigram/bigram に分けた検索語
+    withdrawn_at TIMESTAMP, -- 除籍した日時（除籍した書籍は削除せずに残す）
+    withdrawal_reason TEXT NOT NULL DEFAULT '',
     created_at TIMESTAMP NOT NULL,
     updated_at TIMESTAMP NOT NULL
 );
 
+-- 著者テーブル（書籍ごとの著者を表示順に保持。books.author は表示用に連結した氏名）
+CREATE TABLE IF NOT EXISTS book_authors (
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'author' CHECK (role IN ('author', 'editor', 'translator', 'supervisor')),
+    PRIMARY KEY (book_id, position)
+);
+
+-- 論文テーブル（books の type = 'thesis' の論文固有の情報。指導教員は book_authors の role = 'supervisor'）
+CREATE TABLE IF NOT EXISTS theses (
+    book_id UUID PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    academic_year INTEGER NOT NULL,
+    degree VARCHAR(10) NOT NULL DEFAULT 'bachelor' CHECK (degree IN ('bachelor', 'master', 'doctor')),
+    student_id VARCHAR(20) NOT NULL,
+    pdf_path TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (academic_year, student_id)
+);
+
+-- 添付ファイルテーブル（書籍に添付した電子ファイル。ファイル本体は storage_path に保存する）
+CREATE TABLE IF NOT EXISTS book_attachments (
+    id UUID PRIMARY KEY,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    storage_path TEXT NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'lab' CHECK (visibility IN ('public', 'lab', 'admin')),
+    description TEXT NOT NULL DEFAULT '',
+    scan_status VARCHAR(16) NOT NULL DEFAULT 'unscanned',
+    uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- 添付ファイルのダウンロード記録（統計用）
+CREATE TABLE IF NOT EXISTS attachment_downloads (
+    id BIGSERIAL PRIMARY KEY,
+    attachment_id UUID NOT NULL REFERENCES book_attachments(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    downloaded_at TIMESTAMP NOT NULL
+);
+
+-- 外部の書誌情報サービスへの問い合わせ結果のキャッシュ（data が NULL の場合は該当なし）
+CREATE TABLE IF NOT EXISTS book_metadata_cache (
+    isbn VARCHAR(13) NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    data JSONB,
+    fetched_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (isbn, provider)
+);
+
+-- 書籍の一括取り込みジョブ（mapping は項目名から列の見出しへの対応）
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id UUID PRIMARY KEY,
+    filename TEXT NOT NULL,
+    storage_path TEXT NOT NULL,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'xlsx')),
+    sheet TEXT NOT NULL DEFAULT '',
+    mapping JSONB NOT NULL DEFAULT '{}',
+    dry_run BOOLEAN NOT NULL DEFAULT false,
+    enrich BOOLEAN NOT NULL DEFAULT false,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    duplicate_rows INTEGER NOT NULL DEFAULT 0,
+    error_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+-- 取り込みの行ごとの結果
+CREATE TABLE IF NOT EXISTS import_job_rows (
+    job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('imported', 'duplicate', 'error')),
+    title TEXT NOT NULL DEFAULT '',
+    isbn VARCHAR(13) NOT NULL DEFAULT '',
+    book_id UUID REFERENCES books(id) ON DELETE SET NULL,
+    duplicate_of UUID REFERENCES books(id) ON DELETE SET NULL,
+    messages TEXT[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY (job_id, row_number)
+);
+
+-- 表紙画像の取得ジョブ
+CREATE TABLE IF NOT EXISTS cover_jobs (
+    id UUID PRIMARY KEY,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    total_books INTEGER NOT NULL DEFAULT 0,
+    processed_books INTEGER NOT NULL DEFAULT 0,
+    imported_books INTEGER NOT NULL DEFAULT 0,
+    not_found_books INTEGER NOT NULL DEFAULT 0,
+    error_books INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+-- 表紙画像の取得の書籍ごとの結果
+CREATE TABLE IF NOT EXISTS cover_job_results (
+    job_id UUID NOT NULL REFERENCES cover_jobs(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    book_id UUID REFERENCES books(id) ON DELETE SET NULL,
+    title TEXT NOT NULL DEFAULT '',
+    isbn VARCHAR(13) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL CHECK (status IN ('imported', 'not_found', 'error')),
+    cover_url TEXT NOT NULL DEFAULT '',
+    image_path TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (job_id, seq)
+);
+
+-- パスワード設定の招待（トークンは SHA-256 のハッシュのみを保存）
+CREATE TABLE IF NOT EXISTS user_invites (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
 -- 図書コピーテーブル
 CREATE TABLE IF NOT EXISTS book_copies (
     id UUID PRIMARY KEY,
@@ -38,15 +190,33 @@ CREATE TABLE IF NOT EXISTS book_copies (
     updated_at TIMESTAMP NOT NULL
 );
 
+-- 生成したバーコード（storage_path は保存先の barcodes/ 以下の画像のファイル名で、サーバー側で生成する）。
+-- 同じ種類・コードのバーコードは1件のみ記録し、再度の生成では既存の記録と画像を返す。
+-- params は生成時の指定（卒論バーコードの年度・学籍番号・著者名・タイトル、画像の幅・高さ）。
+CREATE TABLE IF NOT EXISTS barcodes (
+    id UUID PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    symbology VARCHAR(16) NOT NULL DEFAULT 'ean13',
+    book_id UUID REFERENCES books(id) ON DELETE SET NULL,
+    copy_id UUID REFERENCES book_copies(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    storage_path TEXT NOT NULL UNIQUE,
+    size BIGINT NOT NULL DEFAULT 0,
+    params JSONB NOT NULL DEFAULT '{}',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
 -- 貸出記録テーブル
 CREATE TABLE IF NOT EXISTS borrow_records (
     id UUID PRIMARY KEY,
-    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT, -- ユーザーは削除せず匿名化する（貸出の履歴を残す）
     book_copy_id UUID NOT NULL REFERENCES book_copies(id) ON DELETE CASCADE,
     borrowed_at TIMESTAMP NOT NULL,
     due_date TIMESTAMP NOT NULL,
     returned_at TIMESTAMP,
     status VARCHAR(10) NOT NULL CHECK (status IN ('borrowed', 'returned')),
+    renew_count INTEGER NOT NULL DEFAULT 0,
     CONSTRAINT valid_return_date CHECK (
         (returned_at IS NULL) OR
         (returned_at >= borrowed_at)
@@ -78,5 +248,98 @@ CREATE INDEX IF NOT EXISTS idx_books_ean13 ON books(ean13);
 CREATE INDEX IF NOT EXISTS idx_book_copies_book_id ON book_copies(book_id);
 CREATE INDEX IF NOT EXISTS idx_borrow_records_user_id ON borrow_records(user_id);
 CREATE INDEX IF NOT EXISTS idx_borrow_records_book_copy_id ON borrow_records(book_copy_id);
-CREATE INDEX IF NOT EXISTS idx_admin_logs_admin_id ON admin_logs(admin_id);
-CREATE INDEX IF NOT EXISTS idx_monthly_rankings_month ON monthly_rankings(month);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_monthly_rankings_month ON monthly_rankings(month);
+-- 一覧の絞り込み・並び替え用
+CREATE INDEX IF NOT EXISTS idx_books_type ON books(type);
+CREATE INDEX IF NOT EXISTS idx_books_location ON books(location);
+CREATE INDEX IF NOT EXISTS idx_borrow_records_borrowed_at ON borrow_records(borrowed_at);
+CREATE INDEX IF NOT EXISTS idx_borrow_records_open_due_date ON borrow_records(due_date) WHERE returned_at IS NULL;
+
+-- 既存データベース向けの列追加。
+-- 新しく作成するデータベースでは上の CREATE TABLE に含まれており、何もしない。
+-- 既存のデータベースは、このファイル全体を再度適用すると列・テーブル・索引が追加される（README 参照）。
+ALTER TABLE borrow_records ADD COLUMN IF NOT EXISTS renew_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_text TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS published_year INTEGER;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher TEXT NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS published_date VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS edition TEXT NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS page_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS subjects TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS withdrawn_at TIMESTAMP;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS withdrawal_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'deactivated', 'graduated'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_due_reminder BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_overdue BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE barcodes ADD COLUMN IF NOT EXISTS symbology VARCHAR(16) NOT NULL DEFAULT 'ean13';
+ALTER TABLE barcodes ADD COLUMN IF NOT EXISTS copy_id UUID REFERENCES book_copies(id) ON DELETE SET NULL;
+ALTER TABLE barcodes ADD COLUMN IF NOT EXISTS params JSONB NOT NULL DEFAULT '{}';
+
+-- 既存データベースの貸出記録の外部キーを ON DELETE CASCADE から ON DELETE RESTRICT に変更する
+-- （ユーザーの削除で貸出の履歴が消えないようにする）
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'borrow_records_user_id_fkey' AND confdeltype = 'c') THEN
+        ALTER TABLE borrow_records DROP CONSTRAINT borrow_records_user_id_fkey;
+        ALTER TABLE borrow_records ADD CONSTRAINT borrow_records_user_id_fkey
+            FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+    END IF;
+END $$;
+
+-- 既存データベースのロールの制約に guest（自分で登録したユーザー）を追加する
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_role_check'
+               AND pg_get_constraintdef(oid) NOT LIKE '%guest%') THEN
+        ALTER TABLE users DROP CONSTRAINT users_role_check;
+        ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'guest'));
+    END IF;
+END $$;
+
+-- 著者テーブル導入前の書籍は books.author を1人目の著者とする
+INSERT INTO book_authors (book_id, position, name)
+SELECT b.id, 0, b.author FROM books b
+WHERE b.author <> '' AND NOT EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id);
+
+-- books.author は役割が著者の氏名のみを連結する（以前は指導教員・編者なども含めていた）
+UPDATE books b SET author = a.names
+FROM (SELECT book_id, COALESCE(string_agg(name, ', ' ORDER BY position) FILTER (WHERE role = 'author'), '') AS names
+      FROM book_authors GROUP BY book_id) a
+WHERE b.id = a.book_id AND b.author <> a.names;
+
+-- 蔵書検索用の索引。search_vector はアプリケーションが日本語を unigram/bigram に分けて作成し、
+-- search_text（正規化済みのタイトル・著者・コード）は英数字の部分一致に pg_trgm の索引を使う。
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_books_search_text_trgm ON books USING GIN (search_text gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_books_published_year ON books(published_year);
+CREATE INDEX IF NOT EXISTS idx_books_publisher ON books(publisher);
+CREATE INDEX IF NOT EXISTS idx_books_subjects ON books USING GIN (subjects);
+CREATE INDEX IF NOT EXISTS idx_books_withdrawn_at ON books(withdrawn_at) WHERE withdrawn_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_book_authors_name ON book_authors(name);
+CREATE INDEX IF NOT EXISTS idx_theses_student_id ON theses(student_id);
+CREATE INDEX IF NOT EXISTS idx_book_attachments_book_id ON book_attachments(book_id);
+-- 同じ種類・コードのバーコードの記録が重複している既存のデータベースでは作成しない
+-- （POST /api/v1/admin/barcode/migrate で重複を削除してから、このファイルを再度適用する）
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM barcodes WHERE code <> '' GROUP BY symbology, code HAVING COUNT(*) > 1) THEN
+        RAISE WARNING 'barcodes has duplicate codes; run POST /api/v1/admin/barcode/migrate and apply schema.sql again';
+    ELSE
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_barcodes_symbology_code ON barcodes(symbology, code) WHERE code <> '';
+    END IF;
+END $$;
+CREATE INDEX IF NOT EXISTS idx_barcodes_code ON barcodes(code);
+CREATE INDEX IF NOT EXISTS idx_barcodes_book_id ON barcodes(book_id);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);
+CREATE INDEX IF NOT EXISTS idx_user_invites_user_id ON user_invites(user_id);
+CREATE INDEX IF NOT EXISTS idx_attachment_downloads_attachment_id ON attachment_downloads(attachment_id, downloaded_at);
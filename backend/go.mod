@@ -10,6 +10,7 @@ require (
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.9.0
 	golang.org/x/image v0.15.0
+	golang.org/x/text v0.14.0
 )
 
 require (
@@ -35,7 +36,6 @@ require (
 	golang.org/x/arch v0.3.0 // indirect
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,186 @@
+// Package imaging は書籍画像の検証と、表示用に縮小した画像（レンディション）の生成を行う。
+// 画像は内容からデコードして形式を判定し、EXIF の向きを補正したうえで再エンコードするため、
+// 保存する画像には EXIF などのメタデータが残らない。
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// レンディションの大きさ
+const (
+	SizeThumb    = "thumb"    // 一覧表示用
+	SizeMedium   = "medium"   // 詳細表示用
+	SizeOriginal = "original" // 向きを補正しただけの元の大きさ
+)
+
+// Sizes は生成するレンディションの大きさ
+var Sizes = []string{SizeThumb, SizeMedium, SizeOriginal}
+
+// maxEdge は各大きさの長辺のピクセル数の上限（これより小さい画像は拡大しない）
+var maxEdge = map[string]int{
+	SizeThumb:  200,
+	SizeMedium: 800,
+}
+
+// MaxPixels はデコードする画像の画素数の上限（小さなファイルで巨大な画像を展開させる攻撃への対策）
+const MaxPixels = 40_000_000
+
+// jpegQuality は JPEG で書き出す場合の品質
+const jpegQuality = 85
+
+var (
+	// ErrUnsupported は JPEG・PNG・GIF・WebP のいずれとしてもデコードできない場合のエラー
+	ErrUnsupported = errors.New("JPEG・PNG・GIF・WebP の画像ではありません")
+	// ErrTooManyPixels は画素数が MaxPixels を超える場合のエラー
+	ErrTooManyPixels = errors.New("画像の画素数が多すぎます")
+)
+
+// 書き出す形式
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Image は向きを補正したデコード済みの画像
+type Image struct {
+	img image.Image
+	// Format は書き出す形式。透過のある画像は FormatPNG、それ以外は FormatJPEG。
+	Format string
+}
+
+// Decode は data の形式を内容から判定してデコードし、EXIF の Orientation に従って向きを補正する。
+// アニメーション GIF は最初のコマのみを使う。
+func Decode(data []byte) (*Image, error) {
+	format := detect(data)
+	if format == "" {
+		return nil, ErrUnsupported
+	}
+	cfg, err := decodeConfig(format, data)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width < 1 || cfg.Height < 1 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, err := decode(format, data)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	img = orient(img, orientation(format, data))
+
+	out := FormatJPEG
+	if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		out = FormatPNG
+	}
+	return &Image{img: img, Format: out}, nil
+}
+
+// detect は先頭のバイト列から形式を判定する。判定できない場合は空を返す。
+func detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	}
+	return ""
+}
+
+func decodeConfig(format string, data []byte) (image.Config, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case "jpeg":
+		return jpeg.DecodeConfig(r)
+	case "png":
+		return png.DecodeConfig(r)
+	case "gif":
+		return gif.DecodeConfig(r)
+	default:
+		return webp.DecodeConfig(r)
+	}
+}
+
+func decode(format string, data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case "jpeg":
+		return jpeg.Decode(r)
+	case "png":
+		return png.Decode(r)
+	case "gif":
+		return gif.Decode(r)
+	default:
+		return webp.Decode(r)
+	}
+}
+
+// Bounds は向きを補正した画像の大きさを返す
+func (im *Image) Bounds() image.Rectangle {
+	return im.img.Bounds()
+}
+
+// Ext は書き出す形式の拡張子を返す
+func (im *Image) Ext() string {
+	if im.Format == FormatPNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// Encode は size の大きさに縮小した画像を w に書き出す
+func (im *Image) Encode(w io.Writer, size string) error {
+	img := fit(im.img, maxEdge[size])
+	if im.Format == FormatPNG {
+		return png.Encode(w, img)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+}
+
+// fit は長辺が max ピクセル以下になるよう縦横比を保って縮小する。max が0または画像が小さい場合はそのまま返す。
+func fit(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if max <= 0 || (w <= max && h <= max) {
+		return src
+	}
+	if w >= h {
+		w, h = max, h*max/w
+	} else {
+		w, h = w*max/h, max
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// Filename は元の画像のファイル名 name から size のレンディションのファイル名を返す。
+// 元の大きさは name のまま、それ以外は "<name の拡張子を除いた部分>_<size><拡張子>" になる。
+func Filename(name, size string) string {
+	if size == SizeOriginal || size == "" {
+		return name
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_" + size + ext
+}
@@ -0,0 +1,165 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testJPEG は w×h の JPEG を作る。orientation が1以上の場合は EXIF の Orientation を埋め込む。
+func testJPEG(t *testing.T, w, h, orientation int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if orientation < 1 {
+		return data
+	}
+	exif := exifSegment(buildTIFF(binary.BigEndian, tiffEntry{0x0112, uint16(orientation)}))
+	return append(append(append([]byte{}, data[:2]...), exif...), data[2:]...)
+}
+
+func testPNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	// EXIF で90度回転する画像は縦横が入れ替わる
+	img, err := Decode(testJPEG(t, 40, 20, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 || img.Format != FormatJPEG || img.Ext() != ".jpg" {
+		t.Fatalf("rotated jpeg: bounds = %v, format = %s", b, img.Format)
+	}
+	img, err = Decode(testJPEG(t, 40, 20, 0))
+	if err != nil || img.Bounds().Dx() != 40 {
+		t.Fatalf("jpeg: %v, %v", img, err)
+	}
+
+	// 透過のある画像は PNG で書き出す
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	transparent.Set(1, 1, color.NRGBA{R: 255, A: 128})
+	img, err = Decode(testPNG(t, transparent))
+	if err != nil || img.Format != FormatPNG || img.Ext() != ".png" {
+		t.Fatalf("transparent png: %+v, %v", img, err)
+	}
+	opaque := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+	if img, err = Decode(testPNG(t, opaque)); err != nil || img.Format != FormatJPEG {
+		t.Fatalf("opaque png: %+v, %v", img, err)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 3, 5), color.Palette{color.Black, color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	if img, err = Decode(buf.Bytes()); err != nil || img.Bounds().Dy() != 5 {
+		t.Fatalf("gif: %+v, %v", img, err)
+	}
+
+	png := testPNG(t, opaque)
+	for name, data := range map[string][]byte{
+		"empty":          nil,
+		"text":           []byte("not an image"),
+		"svg":            []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`),
+		"png header":     png[:8],
+		"truncated png":  png[:len(png)/2],
+		"truncated jpeg": testJPEG(t, 40, 20, 0)[:200],
+		"riff":           []byte("RIFF\x00\x00\x00\x00WEBPVP8 \x00\x00\x00\x00"),
+	} {
+		if _, err := Decode(data); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: err = %v, want ErrUnsupported", name, err)
+		}
+	}
+}
+
+// 画素数の多い画像は展開する前に拒否する
+func TestDecodeMaxPixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	// GIF の論理画面の大きさ（ヘッダの直後）を書き換える
+	withSize := func(w, h uint16) []byte {
+		data := append([]byte{}, buf.Bytes()...)
+		binary.LittleEndian.PutUint16(data[6:], w)
+		binary.LittleEndian.PutUint16(data[8:], h)
+		return data
+	}
+	for _, size := range [][2]uint16{{65535, 65535}, {10000, 4001}, {0, 10}, {10, 0}} {
+		if _, err := Decode(withSize(size[0], size[1])); !errors.Is(err, ErrTooManyPixels) {
+			t.Errorf("%dx%d: err = %v, want ErrTooManyPixels", size[0], size[1], err)
+		}
+	}
+	// 上限ちょうどは画素数では拒否しない（データが足りないため、デコードはできない）
+	if _, err := Decode(withSize(10000, 4000)); errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("10000x4000: err = %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	img, err := Decode(testJPEG(t, 1000, 500, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for size, want := range map[string]image.Point{
+		SizeThumb:    {200, 100},
+		SizeMedium:   {800, 400},
+		SizeOriginal: {1000, 500},
+	} {
+		var buf bytes.Buffer
+		if err := img.Encode(&buf, size); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := jpeg.DecodeConfig(&buf)
+		if err != nil || cfg.Width != want.X || cfg.Height != want.Y {
+			t.Errorf("%s: %dx%d, %v; want %v", size, cfg.Width, cfg.Height, err, want)
+		}
+	}
+
+	// 小さい画像は拡大せず、細長い画像も1ピクセル以上にする
+	for _, tc := range []struct {
+		w, h int
+		size string
+		want image.Point
+	}{
+		{100, 50, SizeThumb, image.Point{100, 50}},
+		{50, 400, SizeThumb, image.Point{25, 200}},
+		{2000, 1, SizeThumb, image.Point{200, 1}},
+	} {
+		got := fit(image.NewGray(image.Rect(0, 0, tc.w, tc.h)), maxEdge[tc.size]).Bounds().Size()
+		if got != tc.want {
+			t.Errorf("fit(%dx%d, %s) = %v, want %v", tc.w, tc.h, tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestFilename(t *testing.T) {
+	for _, tc := range []struct{ name, size, want string }{
+		{"abc.jpg", SizeThumb, "abc_thumb.jpg"},
+		{"abc.png", SizeMedium, "abc_medium.png"},
+		{"abc.jpg", SizeOriginal, "abc.jpg"},
+		{"abc.jpg", "", "abc.jpg"},
+		{"abc", SizeThumb, "abc_thumb"},
+	} {
+		if got := Filename(tc.name, tc.size); got != tc.want {
+			t.Errorf("Filename(%q, %q) = %q, want %q", tc.name, tc.size, got, tc.want)
+		}
+	}
+}
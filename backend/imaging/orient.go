@@ -0,0 +1,146 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// orientation は JPEG・WebP に埋め込まれた EXIF の Orientation（1〜8）を返す。
+// EXIF がない・読めない場合は 1（補正なし）を返す。
+func orientation(format string, data []byte) int {
+	var exif []byte
+	switch format {
+	case "jpeg":
+		exif = jpegExif(data)
+	case "webp":
+		exif = webpExif(data)
+	}
+	if exif == nil {
+		return 1
+	}
+	return tiffOrientation(exif)
+}
+
+// jpegExif は JPEG の APP1 セグメントから TIFF 形式の EXIF を取り出す
+func jpegExif(data []byte) []byte {
+	p := 2 // SOI
+	for p+4 <= len(data) {
+		if data[p] != 0xFF {
+			return nil
+		}
+		marker := data[p+1]
+		// SOS 以降は画像データなので探さない
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		n := int(binary.BigEndian.Uint16(data[p+2:]))
+		if n < 2 || p+2+n > len(data) {
+			return nil
+		}
+		seg := data[p+4 : p+2+n]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:]
+		}
+		p += 2 + n
+	}
+	return nil
+}
+
+// webpExif は WebP の EXIF チャンクを取り出す
+func webpExif(data []byte) []byte {
+	p := 12 // "RIFF" サイズ "WEBP"
+	for p+8 <= len(data) {
+		id := string(data[p : p+4])
+		n := int(binary.LittleEndian.Uint32(data[p+4:]))
+		if n < 0 || p+8+n > len(data) {
+			return nil
+		}
+		if id == "EXIF" {
+			// 一部のエンコーダは JPEG と同じ "Exif\0\0" を先頭に付ける
+			return bytes.TrimPrefix(data[p+8:p+8+n], []byte("Exif\x00\x00"))
+		}
+		p += 8 + n + n%2
+	}
+	return nil
+}
+
+// tiffOrientation は TIFF 形式の EXIF の IFD0 から Orientation（0x0112）を読む
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		e := ifd + 2 + i*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:]) != 0x0112 {
+			continue
+		}
+		// 型は SHORT、値はエントリ内に左詰めで入る
+		if v := int(order.Uint16(tiff[e+8:])); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// orient は EXIF の Orientation に従って画像を正しい向きにする
+func orient(src image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	s := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(s, s.Bounds(), src, b.Min, draw.Src)
+
+	dw, dh := w, h
+	if o >= 5 {
+		// 5〜8 は縦横が入れ替わる
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // 左右反転
+				dx, dy = w-1-x, y
+			case 3: // 180度回転
+				dx, dy = w-1-x, h-1-y
+			case 4: // 上下反転
+				dx, dy = x, h-1-y
+			case 5: // 左上と右下を結ぶ線で反転
+				dx, dy = y, x
+			case 6: // 時計回りに90度回転
+				dx, dy = h-1-y, x
+			case 7: // 右上と左下を結ぶ線で反転
+				dx, dy = h-1-y, w-1-x
+			case 8: // 反時計回りに90度回転
+				dx, dy = y, w-1-x
+			}
+			si := s.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], s.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,206 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// tiffEntry は IFD0 の SHORT 型のエントリ
+type tiffEntry struct {
+	tag, value uint16
+}
+
+// buildTIFF は entries を IFD0 に持つ TIFF 形式の EXIF を作る
+func buildTIFF(order binary.ByteOrder, entries ...tiffEntry) []byte {
+	b := make([]byte, 8+2+len(entries)*12+4) // 最後の4バイトは次の IFD のオフセット（0）
+	if order == binary.LittleEndian {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	order.PutUint16(b[2:], 42)
+	order.PutUint32(b[4:], 8)
+	order.PutUint16(b[8:], uint16(len(entries)))
+	for i, e := range entries {
+		p := 10 + i*12
+		order.PutUint16(b[p:], e.tag)
+		order.PutUint16(b[p+2:], 3) // SHORT
+		order.PutUint32(b[p+4:], 1)
+		order.PutUint16(b[p+8:], e.value)
+	}
+	return b
+}
+
+// jpegSegment は JPEG のマーカーセグメントを作る
+func jpegSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// jpegWithSegments は SOI の後に segments を並べ、SOS で終わる JPEG の先頭部分を作る
+func jpegWithSegments(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, seg := range segments {
+		data = append(data, seg...)
+	}
+	return append(data, jpegSegment(0xDA, []byte{0, 0})...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// webpWithChunks は chunks（ID と内容）を並べた WebP を作る
+func webpWithChunks(chunks ...[2]string) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range chunks {
+		data = append(data, c[0]...)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(c[1])))
+		data = append(data, c[1]...)
+		if len(c[1])%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func TestOrientation(t *testing.T) {
+	le, be := binary.LittleEndian, binary.BigEndian
+	rotated := buildTIFF(be, tiffEntry{0x0112, 6})
+	app0 := jpegSegment(0xE0, []byte("JFIF\x00\x01\x01"))
+
+	for _, tc := range []struct {
+		name   string
+		format string
+		data   []byte
+		want   int
+	}{
+		{"big endian", "jpeg", jpegWithSegments(exifSegment(rotated)), 6},
+		{"little endian", "jpeg", jpegWithSegments(exifSegment(buildTIFF(le, tiffEntry{0x0112, 8}))), 8},
+		{"after APP0", "jpeg", jpegWithSegments(app0, exifSegment(buildTIFF(le, tiffEntry{0x0112, 3}))), 3},
+		{"after other tags", "jpeg", jpegWithSegments(exifSegment(buildTIFF(be, tiffEntry{0x010F, 1}, tiffEntry{0x0112, 5}))), 5},
+		{"no exif", "jpeg", jpegWithSegments(app0), 1},
+		{"no orientation tag", "jpeg", jpegWithSegments(exifSegment(buildTIFF(be, tiffEntry{0x010F, 6}))), 1},
+		{"out of range", "jpeg", jpegWithSegments(exifSegment(buildTIFF(be, tiffEntry{0x0112, 9}))), 1},
+		{"zero", "jpeg", jpegWithSegments(exifSegment(buildTIFF(be, tiffEntry{0x0112, 0}))), 1},
+		{"not exif APP1", "jpeg", jpegWithSegments(jpegSegment(0xE1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), rotated...))), 1},
+		{"exif after SOS", "jpeg", append(jpegWithSegments(), exifSegment(rotated)...), 1},
+		{"webp", "webp", webpWithChunks([2]string{"VP8X", "0123456789"}, [2]string{"EXIF", string(rotated)}), 6},
+		{"webp exif header", "webp", webpWithChunks([2]string{"EXIF", "Exif\x00\x00" + string(rotated)}), 6},
+		{"webp odd chunk", "webp", webpWithChunks([2]string{"ICCP", "abc"}, [2]string{"EXIF", string(rotated)}), 6},
+		{"webp without exif", "webp", webpWithChunks([2]string{"VP8 ", "0123"}), 1},
+		// PNG・GIF の EXIF は読まない
+		{"png", "png", jpegWithSegments(exifSegment(rotated)), 1},
+	} {
+		if got := orientation(tc.format, tc.data); got != tc.want {
+			t.Errorf("%s: orientation = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+// 壊れた EXIF は範囲外を読まずに補正なしとして扱う
+func TestOrientationMalformed(t *testing.T) {
+	be := binary.BigEndian
+	rotated := buildTIFF(be, tiffEntry{0x0112, 6})
+	valid := jpegWithSegments(exifSegment(rotated))
+
+	cases := map[string][]byte{
+		"empty":            nil,
+		"only SOI":         {0xFF, 0xD8},
+		"no marker":        append([]byte{0xFF, 0xD8, 0x00}, valid[2:]...),
+		"short length":     {0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01},
+		"length past end":  append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}, valid[6:]...),
+		"short tiff":       jpegWithSegments(exifSegment([]byte("MM\x00\x2a"))),
+		"bad byte order":   jpegWithSegments(exifSegment(append([]byte("XX"), rotated[2:]...))),
+		"ifd before start": jpegWithSegments(exifSegment(append(append([]byte{}, rotated[:4]...), append([]byte{0, 0, 0, 4}, rotated[8:]...)...))),
+		"ifd past end":     jpegWithSegments(exifSegment(append(append([]byte{}, rotated[:4]...), append([]byte{0x7F, 0xFF, 0xFF, 0xFF}, rotated[8:]...)...))),
+		"count past end":   jpegWithSegments(exifSegment(append(append([]byte{}, rotated[:8]...), 0xFF, 0xFF))),
+		"webp truncated":   webpWithChunks([2]string{"EXIF", string(rotated)})[:20],
+		"webp huge chunk":  append([]byte("RIFF\x00\x00\x00\x00WEBPEXIF\xff\xff\xff\xff"), rotated...),
+	}
+	// 正しい EXIF のセグメントを途中で切ったものもすべて補正なしになる
+	for i := 0; i < 2+len(exifSegment(rotated)); i++ {
+		if got := orientation("jpeg", valid[:i]); got != 1 {
+			t.Errorf("truncated to %d bytes: orientation = %d", i, got)
+		}
+	}
+	for name, data := range cases {
+		for _, format := range []string{"jpeg", "webp"} {
+			if got := orientation(format, data); got != 1 {
+				t.Errorf("%s (%s): orientation = %d, want 1", name, format, got)
+			}
+		}
+	}
+}
+
+// labeledImage は3×2の画像で、各画素の R を A〜F の文字にする
+//
+//	A B C
+//	D E F
+func labeledImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			img.Set(x, y, color.RGBA{R: byte('A' + y*3 + x), A: 255})
+		}
+	}
+	return img
+}
+
+// labels は画像の各行の画素の文字を返す
+func labels(img image.Image) []string {
+	b := img.Bounds()
+	rows := make([]string, 0, b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var row []byte
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8))
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	for o, want := range map[int][]string{
+		0: {"ABC", "DEF"},
+		1: {"ABC", "DEF"},
+		2: {"CBA", "FED"},     // 左右反転
+		3: {"FED", "CBA"},     // 180度回転
+		4: {"DEF", "ABC"},     // 上下反転
+		5: {"AD", "BE", "CF"}, // 転置
+		6: {"DA", "EB", "FC"}, // 時計回りに90度回転
+		7: {"FC", "EB", "DA"}, // 反転置
+		8: {"CF", "BE", "AD"}, // 反時計回りに90度回転
+		9: {"ABC", "DEF"},     // 範囲外は補正しない
+	} {
+		got := labels(orient(labeledImage(), o))
+		if len(got) != len(want) {
+			t.Errorf("orientation %d: rows = %q, want %q", o, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("orientation %d: rows = %q, want %q", o, got, want)
+				break
+			}
+		}
+	}
+
+	// 原点が (0, 0) でない画像も補正できる
+	sub := image.NewRGBA(image.Rect(5, 5, 8, 7))
+	src := labeledImage()
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			sub.Set(x+5, y+5, src.At(x, y))
+		}
+	}
+	if got := labels(orient(sub, 6)); len(got) != 3 || got[0] != "DA" || got[2] != "FC" {
+		t.Fatalf("sub image: rows = %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+package labels
+
+import (
+	"fmt"
+	"strconv"
+
+	"lablib/codes"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+)
+
+// symbol はバーコードの線の並び。bars の true が黒のモジュール。
+type symbol struct {
+	bars  []bool
+	quiet int // 左右に必要な余白のモジュール数
+}
+
+// encode は code を EAN-13（13桁の数字でチェックディジットが正しい場合）または Code 128 にする
+func encode(code string) (symbol, error) {
+	if code == "" {
+		return symbol{}, fmt.Errorf("%w: empty", ErrInvalidCode)
+	}
+	var bc barcode.Barcode
+	var err error
+	quiet := 10
+	if isEAN13(code) {
+		bc, err = ean.Encode(code)
+		quiet = 11
+	} else {
+		bc, err = code128.Encode(code)
+	}
+	if err != nil {
+		return symbol{}, fmt.Errorf("%w: %q", ErrInvalidCode, code)
+	}
+
+	bounds := bc.Bounds()
+	s := symbol{bars: make([]bool, bounds.Dx()), quiet: quiet}
+	for x := range s.bars {
+		r, _, _, _ := bc.At(bounds.Min.X+x, bounds.Min.Y).RGBA()
+		s.bars[x] = r == 0
+	}
+	return s, nil
+}
+
+// isEAN13 は code が13桁の数字で、チェックディジットが正しいかを返す
+func isEAN13(code string) bool {
+	if len(code) != 13 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return strconv.Itoa(codes.CheckDigit(code[:12])) == code[12:]
+}
@@ -0,0 +1,44 @@
+package labels
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		code  string
+		ean   bool
+		quiet int
+	}{
+		{"9784873117522", true, 11},
+		{"2024123456784", false, 10}, // チェックディジットが正しくない
+		{"978487311752", false, 10},  // 12桁
+		{"97848731175220", false, 10},
+		{"978487311752X", false, 10},
+		{"LAB-0001", false, 10},
+		{"123", false, 10},
+	} {
+		s, err := encode(tc.code)
+		if err != nil {
+			t.Errorf("%s: %v", tc.code, err)
+			continue
+		}
+		if isEAN13(tc.code) != tc.ean || s.quiet != tc.quiet {
+			t.Errorf("%s: ean = %v, quiet = %d", tc.code, isEAN13(tc.code), s.quiet)
+		}
+		// EAN-13 は95モジュールでガードバー（101）で始まる。Code 128 は 11×文字数+35 モジュール以上になる。
+		if tc.ean && (len(s.bars) != 95 || !s.bars[0] || s.bars[1] || !s.bars[2] || !s.bars[94]) {
+			t.Errorf("%s: EAN-13 bars = %v", tc.code, s.bars)
+		}
+		if !tc.ean && (len(s.bars) < 11*len(tc.code)/2+35 || !s.bars[0] || !s.bars[len(s.bars)-1]) {
+			t.Errorf("%s: Code 128 has %d modules", tc.code, len(s.bars))
+		}
+	}
+
+	for _, code := range []string{"", "日本語", "tab\x80"} {
+		if _, err := encode(code); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("encode(%q) = %v, want ErrInvalidCode", code, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package labels は A4 などのラベル用紙に印刷するバーコードラベルの PDF を作成する。
+// バーコードの線は矩形として描くため、拡大・印刷しても粗くならない。
+// 文字は PDF の標準フォントを埋め込まずに使い、英数字のみの文字列は Helvetica、
+// 日本語を含む文字列は HeiseiKakuGo-W5（Adobe-Japan1）で表示する（字形は閲覧・印刷する環境のフォントになる）。
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+var (
+	// ErrInvalidTemplate はラベル用紙の指定が正しくない（ラベルが用紙に収まらないなど）
+	ErrInvalidTemplate = errors.New("invalid label template")
+	// ErrInvalidCode はバーコードにできない値
+	ErrInvalidCode = errors.New("invalid barcode value")
+)
+
+// Template はラベル用紙のレイアウト。長さの単位は mm。
+// ラベルは左上から右へ、行の終わりで次の行へと並べる。
+type Template struct {
+	Name        string
+	Description string
+	PageWidth   float64 // 用紙の幅・高さ
+	PageHeight  float64
+	Columns     int // 1枚の用紙の列数・行数
+	Rows        int
+	LabelWidth  float64 // ラベル1枚の幅・高さ
+	LabelHeight float64
+	MarginTop   float64 // 用紙の上端・左端から最初のラベルまでの余白
+	MarginLeft  float64
+	GapX        float64 // 左右・上下に隣り合うラベルの間隔
+	GapY        float64
+}
+
+// ラベルの大きさの下限（バーコードと番号が収まる大きさ）
+const (
+	minLabelWidth  = 25
+	minLabelHeight = 12
+)
+
+// Validate はレイアウトの値が正しく、すべてのラベルが用紙に収まるかを確かめる
+func (t Template) Validate() error {
+	switch {
+	case t.PageWidth <= 0 || t.PageHeight <= 0 || t.PageWidth > 1000 || t.PageHeight > 1000:
+		return fmt.Errorf("%w: page size must be between 0 and 1000mm", ErrInvalidTemplate)
+	case t.Columns < 1 || t.Rows < 1 || t.Columns > 20 || t.Rows > 40:
+		return fmt.Errorf("%w: columns must be 1-20 and rows 1-40", ErrInvalidTemplate)
+	case t.LabelWidth < minLabelWidth || t.LabelHeight < minLabelHeight:
+		return fmt.Errorf("%w: labels must be at least %dx%dmm", ErrInvalidTemplate, minLabelWidth, minLabelHeight)
+	case t.MarginTop < 0 || t.MarginLeft < 0 || t.GapX < 0 || t.GapY < 0:
+		return fmt.Errorf("%w: margins and gaps must not be negative", ErrInvalidTemplate)
+	}
+	// 寸法の丸めの誤差は許容する
+	const tolerance = 0.05
+	if t.MarginLeft+float64(t.Columns)*t.LabelWidth+float64(t.Columns-1)*t.GapX > t.PageWidth+tolerance {
+		return fmt.Errorf("%w: labels exceed the page width", ErrInvalidTemplate)
+	}
+	if t.MarginTop+float64(t.Rows)*t.LabelHeight+float64(t.Rows-1)*t.GapY > t.PageHeight+tolerance {
+		return fmt.Errorf("%w: labels exceed the page height", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+// PerPage は1枚の用紙のラベルの数を返す
+func (t Template) PerPage() int {
+	return t.Columns * t.Rows
+}
+
+// A4 用紙の大きさ（mm）
+const (
+	A4Width  = 210
+	A4Height = 297
+)
+
+// DefaultTemplate は指定がない場合に使うプリセットの名前
+const DefaultTemplate = "a4-24"
+
+// templates は市販の A4 ラベル用紙（A-ONE など）でよく使われる面付けのプリセット。
+// 製品によって余白が異なる場合は、寸法を指定したレイアウトを使う。
+var templates = map[string]Template{
+	"a4-12": {Description: "A4 12面（2列×6行、86.4×42.3mm）", Columns: 2, Rows: 6,
+		LabelWidth: 86.4, LabelHeight: 42.3, MarginTop: 21.6, MarginLeft: 18.6},
+	"a4-21": {Description: "A4 21面（3列×7行、70×42.3mm）", Columns: 3, Rows: 7,
+		LabelWidth: 70, LabelHeight: 42.3, MarginTop: 0.45},
+	"a4-24": {Description: "A4 24面（3列×8行、70×33.9mm）", Columns: 3, Rows: 8,
+		LabelWidth: 70, LabelHeight: 33.9, MarginTop: 12.9},
+	"a4-44": {Description: "A4 44面（4列×11行、48.3×25.4mm）", Columns: 4, Rows: 11,
+		LabelWidth: 48.3, LabelHeight: 25.4, MarginTop: 8.8, MarginLeft: 8.4},
+	"a4-65": {Description: "A4 65面（5列×13行、38.1×21.2mm。A-ONE 28315 など）", Columns: 5, Rows: 13,
+		LabelWidth: 38.1, LabelHeight: 21.2, MarginTop: 10.7, MarginLeft: 4.75, GapX: 2.5},
+}
+
+// LookupTemplate は名前のプリセットを返す
+func LookupTemplate(name string) (Template, bool) {
+	t, ok := templates[name]
+	if !ok {
+		return Template{}, false
+	}
+	t.Name = name
+	t.PageWidth, t.PageHeight = A4Width, A4Height
+	return t, true
+}
+
+// Templates はすべてのプリセットを名前順に返す
+func Templates() []Template {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]Template, 0, len(names))
+	for _, name := range names {
+		t, _ := LookupTemplate(name)
+		list = append(list, t)
+	}
+	return list
+}
+
+// Label はラベル1枚に印刷する内容
+type Label struct {
+	// Code はバーコードにする値。13桁の数字でチェックディジットが正しい場合は EAN-13、それ以外は Code 128 にする。
+	Code     string
+	Title    string // ラベルの幅に収まらない場合は末尾を省略する
+	Location string
+}
+
+// Options はラベルの印刷の指定
+type Options struct {
+	Skip    int  // 最初の用紙の先頭から飛ばすラベルの数（使いかけの用紙に印刷する場合）
+	Outline bool // ラベルの枠線を描く（用紙との位置合わせの試し印刷向け）
+}
+
+// Render は t の用紙に labels を並べた PDF を w に書き込む
+func Render(w io.Writer, t Template, labels []Label, opts Options) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if opts.Skip < 0 || opts.Skip >= t.PerPage() {
+		return fmt.Errorf("%w: skip must be less than the labels per page (%d)", ErrInvalidTemplate, t.PerPage())
+	}
+
+	// 用紙を作る前にすべての値をバーコードにできるか確かめる
+	symbols := make([]symbol, len(labels))
+	for i, l := range labels {
+		s, err := encode(l.Code)
+		if err != nil {
+			return err
+		}
+		symbols[i] = s
+	}
+
+	doc := newDocument(t.PageWidth*mm, t.PageHeight*mm)
+	var p *page
+	for i, l := range labels {
+		slot := opts.Skip + i
+		if slot%t.PerPage() == 0 || p == nil {
+			p = doc.addPage()
+		}
+		k := slot % t.PerPage()
+		row, col := k/t.Columns, k%t.Columns
+		x := t.MarginLeft + float64(col)*(t.LabelWidth+t.GapX)
+		top := t.PageHeight - t.MarginTop - float64(row)*(t.LabelHeight+t.GapY)
+		if opts.Outline {
+			p.outline(x*mm, (top-t.LabelHeight)*mm, t.LabelWidth*mm, t.LabelHeight*mm)
+		}
+		drawLabel(p, x*mm, (top-t.LabelHeight)*mm, t.LabelWidth*mm, t.LabelHeight*mm, l, symbols[i])
+	}
+	if p == nil {
+		doc.addPage()
+	}
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// mm は 1mm のポイント数（PDF の座標の単位は 1/72 インチ）
+const mm = 72 / 25.4
+
+// drawLabel は左下が (x, y)、幅 w・高さ h（ポイント）のラベルに l を描く。
+// 上からタイトル・バーコード・番号・配置場所の順に並べ、残りの高さをバーコードに使う。
+func drawLabel(p *page, x, y, w, h float64, l Label, s symbol) {
+	pad := min(1.5*mm, h*0.08)
+	size := max(5, min(9, h*0.11)) // 文字の大きさ（ポイント）
+	width := w - 2*pad
+	center := x + w/2
+
+	top := y + h - pad
+	if title := shorten(l.Title, size, width); title != "" {
+		p.textCentered(title, size, center, top-size*0.8)
+		top -= size * 1.05
+	}
+	bottom := y + pad
+	if location := shorten(l.Location, size, width); location != "" {
+		p.textCentered(location, size, center, bottom+size*0.22)
+		bottom += size * 1.05
+	}
+	p.textCentered(shorten(l.Code, size, width), size, center, bottom+size*0.22)
+	bottom += size * 1.05
+
+	// バーの幅（モジュール）は左右の余白（クワイエットゾーン）を含めて収まる大きさにする（最大 0.5mm）
+	module := min(width/float64(len(s.bars)+2*s.quiet), 0.5*mm)
+	barX := center - module*float64(len(s.bars))/2
+	barBottom, barTop := bottom+0.3*mm, top-0.5*mm
+	for i := 0; i < len(s.bars); {
+		if !s.bars[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s.bars) && s.bars[j] {
+			j++
+		}
+		p.rect(barX+float64(i)*module, barBottom, float64(j-i)*module, barTop-barBottom)
+		i = j
+	}
+}
@@ -0,0 +1,91 @@
+package labels
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	list := Templates()
+	if len(list) != len(templates) {
+		t.Fatalf("%d templates", len(list))
+	}
+	for i, tmpl := range list {
+		if i > 0 && list[i-1].Name >= tmpl.Name {
+			t.Errorf("templates are not sorted: %s, %s", list[i-1].Name, tmpl.Name)
+		}
+		if err := tmpl.Validate(); err != nil {
+			t.Errorf("%s: %v", tmpl.Name, err)
+		}
+	}
+	if _, ok := LookupTemplate(DefaultTemplate); !ok {
+		t.Fatalf("default template %s is missing", DefaultTemplate)
+	}
+	if _, ok := LookupTemplate("a4-99"); ok {
+		t.Fatal("unknown template found")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	base, _ := LookupTemplate("a4-24")
+	for name, modify := range map[string]func(*Template){
+		"no page":       func(t *Template) { t.PageWidth = 0 },
+		"huge page":     func(t *Template) { t.PageHeight = 1001 },
+		"no columns":    func(t *Template) { t.Columns = 0 },
+		"too many rows": func(t *Template) { t.Rows = 41 },
+		"small label":   func(t *Template) { t.LabelHeight = 11 },
+		"negative gap":  func(t *Template) { t.GapX = -1 },
+		"too wide":      func(t *Template) { t.MarginLeft = 1 },
+		"too tall":      func(t *Template) { t.GapY = 2 },
+	} {
+		tmpl := base
+		modify(&tmpl)
+		if err := tmpl.Validate(); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl, _ := LookupTemplate("a4-24")
+	sheet := func(n int) []Label {
+		list := make([]Label, n)
+		for i := range list {
+			list[i] = Label{Code: fmt.Sprintf("LAB-%04d", i), Title: "日本語のとても長いタイトルの書籍", Location: "A-1"}
+		}
+		return list
+	}
+	for _, tc := range []struct {
+		labels, skip, pages int
+	}{
+		{0, 0, 1},
+		{1, 0, 1},
+		{24, 0, 1},
+		{25, 0, 2},
+		{2, 23, 2},
+		{1, 23, 1},
+	} {
+		var buf bytes.Buffer
+		if err := Render(&buf, tmpl, sheet(tc.labels), Options{Skip: tc.skip, Outline: true}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte(fmt.Sprintf("/Count %d ", tc.pages))) {
+			t.Errorf("%d labels, skip %d: want %d pages", tc.labels, tc.skip, tc.pages)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, skip := range []int{-1, 24} {
+		if err := Render(&buf, tmpl, sheet(1), Options{Skip: skip}); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("skip %d: err = %v", skip, err)
+		}
+	}
+	if err := Render(&buf, tmpl, []Label{{Code: "OK"}, {Code: "日本"}}, Options{}); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("invalid code: err = %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("wrote a PDF for an invalid request")
+	}
+}
@@ -0,0 +1,197 @@
+package labels
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// document は最小限の PDF（用紙の大きさが同じページと2つのフォント）を組み立てる
+type document struct {
+	width, height float64 // ポイント
+	pages         []*page
+}
+
+func newDocument(width, height float64) *document {
+	return &document{width: width, height: height}
+}
+
+// page は1ページの描画命令（コンテンツストリーム）
+type page struct {
+	bytes.Buffer
+}
+
+func (d *document) addPage() *page {
+	p := &page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// フォントの名前（ページのリソースでの名前）
+const (
+	fontLatin    = "F1" // Helvetica（WinAnsiEncoding）
+	fontJapanese = "F2" // HeiseiKakuGo-W5（UniJIS-UCS2-H。文字列は UTF-16BE）
+)
+
+// rect は左下が (x, y) の矩形を塗りつぶす
+func (p *page) rect(x, y, w, h float64) {
+	fmt.Fprintf(p, "%s %s %s %s re f\n", num(x), num(y), num(w), num(h))
+}
+
+// outline は左下が (x, y) の矩形の枠線を灰色の細い線で描く
+func (p *page) outline(x, y, w, h float64) {
+	fmt.Fprintf(p, "q 0.6 G 0.25 w %s %s %s %s re S Q\n", num(x), num(y), num(w), num(h))
+}
+
+// textCentered は s の中央が center、ベースラインが y になるように大きさ size で描く
+func (p *page) textCentered(s string, size, center, y float64) {
+	if s == "" {
+		return
+	}
+	x := center - textWidth(s, size)/2
+	if isLatin(s) {
+		fmt.Fprintf(p, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", fontLatin, num(size), num(x), num(y), escapeLatin(s))
+		return
+	}
+	fmt.Fprintf(p, "BT /%s %s Tf %s %s Td <%s> Tj ET\n", fontJapanese, num(size), num(x), num(y), encodeUCS2(s))
+}
+
+// num は座標・大きさを小数点以下2桁までの文字列にする
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" { // 丸めると0になる負の値
+		return "0"
+	}
+	return s
+}
+
+// WriteTo は PDF を w に書き込む。オブジェクトの番号は
+// 1: カタログ、2: ページツリー、3〜6: フォント、7以降: ページとコンテンツストリームの組。
+func (d *document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 7+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %s %s] >>",
+		strings.Join(kids, " "), len(d.pages), num(d.width), num(d.height)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type0 /BaseFont /HeiseiKakuGo-W5 /Encoding /UniJIS-UCS2-H /DescendantFonts [5 0 R] >>")
+	// ASCII（CID 1〜95）と半角カナ（CID 231〜632）は半角、それ以外は全角の幅とする
+	object("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /HeiseiKakuGo-W5" +
+		" /CIDSystemInfo << /Registry (Adobe) /Ordering (Japan1) /Supplement 2 >>" +
+		" /FontDescriptor 6 0 R /DW 1000 /W [1 95 500 231 632 500] >>")
+	object("<< /Type /FontDescriptor /FontName /HeiseiKakuGo-W5 /Flags 4 /FontBBox [-92 -250 1010 922]" +
+		" /ItalicAngle 0 /Ascent 752 /Descent -221 /CapHeight 737 /StemV 114 >>")
+
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Contents %d 0 R /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> >>",
+			8+2*i, fontLatin, fontJapanese))
+
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		if _, err := zw.Write(p.Bytes()); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.WriteTo(w)
+}
+
+// helveticaWidths は Helvetica の ASCII（0x20〜0x7E）の文字幅（1000分の1ポイント単位）
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // 0x20〜0x2F
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0x30〜0x3F
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // 0x40〜0x4F
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // 0x50〜0x5F
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // 0x60〜0x6F
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // 0x70〜0x7E
+}
+
+// isLatin は s が印字できる ASCII 文字のみかどうか（Helvetica で描くか）を返す
+func isLatin(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// textWidth は s を大きさ size で描いた幅（ポイント）を返す
+func textWidth(s string, size float64) float64 {
+	latin := isLatin(s)
+	w := 0
+	for _, r := range s {
+		switch {
+		case latin:
+			w += helveticaWidths[r-0x20]
+		case r < 0x80 || (r >= 0xff61 && r <= 0xff9f):
+			w += 500
+		default:
+			w += 1000
+		}
+	}
+	return float64(w) * size / 1000
+}
+
+// shorten は s の空白を詰め、幅が width（ポイント）を超える場合は末尾を省略記号に置き換える
+func shorten(s string, size, width float64) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if textWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for n := len(runes) - 1; n > 0; n-- {
+		head := strings.TrimSpace(string(runes[:n]))
+		ellipsis := "..."
+		if !isLatin(head) {
+			ellipsis = "…"
+		}
+		if textWidth(head+ellipsis, size) <= width {
+			return head + ellipsis
+		}
+	}
+	return ""
+}
+
+// escapeLatin は PDF の文字列リテラルの特殊文字をエスケープする
+func escapeLatin(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+// encodeUCS2 は s を UniJIS-UCS2-H の文字列（UTF-16BE の16進数）にする。
+// UCS-2 で表せない文字（基本多言語面の外）と制御文字は〓にする。
+func encodeUCS2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r > 0xffff || utf16.IsSurrogate(r) {
+			r = '〓'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package labels
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// xref の各オブジェクトのオフセットとstartxref が実際の位置を指す
+func TestWriteToOffsets(t *testing.T) {
+	doc := newDocument(A4Width*mm, A4Height*mm)
+	doc.addPage().textCentered("ABC", 9, 100, 100)
+	doc.addPage().textCentered("日本語", 9, 100, 100)
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.Bytes()
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("no startxref: %q", pdf[max(0, len(pdf)-64):])
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n0 11\n0000000000 65535 f \n")) {
+		t.Fatalf("startxref %d points at %q", xref, pdf[xref:min(len(pdf), xref+32)])
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xref:], -1)
+	if len(entries) != 10 {
+		t.Fatalf("%d xref entries, want 10", len(entries))
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := strconv.Itoa(i+1) + " 0 obj\n"
+		if !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Errorf("object %d: offset %d points at %q", i+1, off, pdf[off:min(len(pdf), off+16)])
+		}
+	}
+	if !bytes.Contains(pdf, []byte("/Kids [7 0 R 9 0 R] /Count 2")) || !bytes.Contains(pdf, []byte("trailer\n<< /Size 11 /Root 1 0 R >>")) {
+		t.Fatal("page tree or trailer does not match the objects")
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want float64
+	}{
+		{"", 0},
+		{"A", 6.67},
+		{"il", 4.44},
+		{"…", 10}, // 省略記号は全角
+		{"日本", 20},
+		{"Aあ", 15}, // 日本語を含む文字列の ASCII は半角
+		{"ｱｲ", 10}, // 半角カナ
+	} {
+		if got := textWidth(tc.s, 10); got < tc.want-0.001 || got > tc.want+0.001 {
+			t.Errorf("textWidth(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestShorten(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		width float64
+		want  string
+	}{
+		{"ABC", 100, "ABC"},
+		{"  A \n B\tC ", 100, "A B C"},
+		{"ABCDEFGHIJ", 30, "ABC..."},
+		// 日本語の省略記号は全角の幅で数える（"日本…" は30ポイント）
+		{"日本語のタイトル", 35, "日本…"},
+		{"日本語のタイトル", 29.9, "日…"},
+		{"日本語のタイトル", 80, "日本語のタイトル"},
+		// 省略した結果が英数字のみの場合は Helvetica の "..." にする
+		{"ABCD 日本語", 40, "ABCD..."},
+		{"日本", 15, ""},
+		{"", 10, ""},
+	} {
+		got := shorten(tc.s, 10, tc.width)
+		if got != tc.want {
+			t.Errorf("shorten(%q, %v) = %q, want %q", tc.s, tc.width, got, tc.want)
+		}
+		if got != "" && textWidth(got, 10) > tc.width {
+			t.Errorf("shorten(%q, %v) = %q is %v wide", tc.s, tc.width, got, textWidth(got, 10))
+		}
+	}
+}
+
+func TestEncodeUCS2(t *testing.T) {
+	for s, want := range map[string]string{
+		"":     "",
+		"A":    "0041",
+		"日本":   "65E5672C",
+		"…":    "2026",
+		"ｱ":    "FF71",
+		"a\nb": "0061" + "3013" + "0062", // 制御文字
+		"😀":    "3013",                   // 基本多言語面の外
+		"�":    "FFFD",
+	} {
+		if got := encodeUCS2(s); got != want {
+			t.Errorf("encodeUCS2(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestEscapeAndNum(t *testing.T) {
+	if got := escapeLatin(`a(b)\c`); got != `a\(b\)\\c` {
+		t.Errorf("escapeLatin = %s", got)
+	}
+	for v, want := range map[float64]string{0: "0", 2: "2", 1.5: "1.5", 1.234: "1.23", 0.004: "0", -0.001: "0", -1.25: "-1.25", 595.28: "595.28"} {
+		if got := num(v); got != want {
+			t.Errorf("num(%v) = %s, want %s", v, got, want)
+		}
+	}
+}
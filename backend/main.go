@@ -1,31 +1,142 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"lablib/api"
+	"lablib/circulation"
 	"lablib/config"
+	"lablib/metadata"
+	"lablib/middleware"
+	"lablib/repository"
+	"lablib/repository/memory"
+	"lablib/repository/postgres"
+	"lablib/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// データベースの初期化
-	config.InitDB()
+	// データストアの初期化（LABLIB_STORE=memory の場合はPostgreSQLなしで起動する）
+	var store repository.Store
+	if os.Getenv("LABLIB_STORE") == "memory" {
+		log.Println("Using in-memory store")
+		store = memory.NewStore()
+	} else {
+		config.InitDB()
+		store = postgres.NewStore(config.DB)
+	}
+	// トークンの署名に使う鍵（JWT_SECRET）
+	middleware.JWTSecret = config.JWTSecret()
+
+	h := api.NewHandler(store, circulation.NewService(store, circulation.DefaultPolicy()))
+
+	// 添付ファイルの検査コマンド（例: LABLIB_SCAN_COMMAND="clamdscan --no-summary"）
+	if cmd := strings.Fields(os.Getenv("LABLIB_SCAN_COMMAND")); len(cmd) > 0 {
+		log.Printf("Scanning attachments with %s", cmd[0])
+		h.SetFileScanner(api.CommandScanner{Command: cmd[0], Args: cmd[1:]})
+	}
+
+	// 書籍画像・バーコード画像・添付ファイル・論文PDF・取り込むファイルの保存先（LABLIB_STORAGE=local・s3・memory、既定は local）。
+	// S3 互換ストレージ（MinIO など）は LABLIB_S3_ENDPOINT を指定するとパス形式でアクセスする。
+	s3Endpoint := os.Getenv("LABLIB_S3_ENDPOINT")
+	pathStyle := s3Endpoint != ""
+	if s := os.Getenv("LABLIB_S3_PATH_STYLE"); s != "" {
+		pathStyle = s == "1" || strings.EqualFold(s, "true")
+	}
+	storageDir := os.Getenv("LABLIB_STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = api.PublicDir
+	}
+	blobs, err := storage.New(os.Getenv("LABLIB_STORAGE"), storage.Options{
+		Dir: storageDir,
+		S3: storage.S3Config{
+			Endpoint:  s3Endpoint,
+			Region:    os.Getenv("LABLIB_S3_REGION"),
+			Bucket:    os.Getenv("LABLIB_S3_BUCKET"),
+			AccessKey: os.Getenv("LABLIB_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("LABLIB_S3_SECRET_KEY"),
+			PathStyle: pathStyle,
+		},
+	})
+	if err != nil {
+		log.Fatal("Error configuring file storage:", err)
+	}
+	h.SetStorage(blobs)
+
+	// 書誌情報の取得先と優先順（例: LABLIB_METADATA_PROVIDERS="ndl,openbd"）と1つのサービスの待ち時間
+	order := metadata.DefaultOrder
+	if s := os.Getenv("LABLIB_METADATA_PROVIDERS"); s != "" {
+		order = strings.Split(s, ",")
+	}
+	providers, err := metadata.NewProviders(order, metadata.Options{
+		GoogleAPIKey: os.Getenv("LABLIB_GOOGLE_BOOKS_KEY"),
+		CiNiiAppID:   os.Getenv("LABLIB_CINII_APPID"),
+	})
+	if err != nil {
+		log.Fatal("Error configuring metadata providers:", err)
+	}
+	bookInfo := metadata.NewFetcher(providers, store.MetadataCache())
+	if s := os.Getenv("LABLIB_METADATA_TIMEOUT"); s != "" {
+		if bookInfo.Timeout, err = time.ParseDuration(s); err != nil {
+			log.Fatal("Error parsing LABLIB_METADATA_TIMEOUT:", err)
+		}
+	}
+	h.SetBookInfoFetcher(bookInfo)
+
+	// 検索索引が未作成の書籍（列追加前に登録された書籍など）の索引を作成
+	if n, err := store.Books().RebuildSearchIndex(context.Background()); err != nil {
+		log.Fatal("Error building search index:", err)
+	} else if n > 0 {
+		log.Printf("Built search index for %d books", n)
+	}
+
+	// 記録の導入前に保存されたバーコード画像を記録（ID でダウンロード・削除できるようにする）。
+	// 記録済みのコードと重複する画像は削除せず、件数だけを出力する。
+	if n, dup, err := h.RegisterSavedBarcodes(context.Background()); err != nil {
+		log.Fatal("Error registering saved barcodes:", err)
+	} else {
+		if n > 0 {
+			log.Printf("Registered %d saved barcodes", n)
+		}
+		if dup > 0 {
+			log.Printf("Found %d duplicate barcode images; remove them with POST /api/v1/admin/barcode/migrate", dup)
+		}
+	}
+
+	// サーバーの停止で中断した書籍の取り込み・表紙画像の取得を再開
+	if n, err := h.ResumeImports(context.Background()); err != nil {
+		log.Fatal("Error resuming import jobs:", err)
+	} else if n > 0 {
+		log.Printf("Resuming %d import jobs", n)
+	}
+	if n, err := h.ResumeCoverJobs(context.Background()); err != nil {
+		log.Fatal("Error resuming cover jobs:", err)
+	} else if n > 0 {
+		log.Printf("Resuming %d cover jobs", n)
+	}
 
 	// デフォルトユーザーの作成
-	if err := api.CreateDefaultUsers(); err != nil {
+	if err := api.CreateDefaultUsers(store); err != nil {
 		log.Fatal("Error creating default users:", err)
 	}
 
 	// Ginルーターの初期化
 	r := gin.Default()
 
+	// リクエストIDの付与
+	r.Use(middleware.RequestID())
+
 	// CORSミドルウェアの設定
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept-Language, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -33,50 +144,21 @@ func main() {
 		c.Next()
 	})
 
-	// 認証ルート
-	r.POST("/api/auth/login", api.Login)
-	r.POST("/api/auth/register", api.Register)
-
-	// 認証が必要なルート
-	auth := r.Group("/api")
-	//auth.Use(middleware.AuthMiddleware())
-	{
-		// 図書管理
-		auth.GET("/books", api.GetBooks)
-		auth.GET("/books/fetch-info", api.FetchBookInfo)
-		auth.GET("/books/:id", api.GetBookDetails)
-		auth.GET("/books/:id/image", api.GetBookImage)
-		auth.GET("/books/borrow-record/:id", api.GetBorrowRecordDetails)
-		auth.POST("/books/borrow", api.BorrowBook)
-		auth.POST("/books/quick-borrow", api.QuickBorrowBook)
-		auth.POST("/books/return", api.ReturnBook)
-		auth.GET("/books/history", api.GetBorrowHistory)
-
-		// 管理者専用ルート
-		admin := auth.Group("/admin")
-		//admin.Use(middleware.AdminMiddleware())
-		{
-			admin.POST("/books", api.CreateBook)
-			admin.PUT("/books/:id", api.UpdateBook)
-			admin.DELETE("/books/:id", api.DeleteBook)
-			admin.POST("/users", api.CreateUser)
-			admin.DELETE("/users/:id", api.DeleteUser)
-			admin.GET("/users", api.GetUsers)
-			admin.GET("/rankings", api.GetMonthlyRankings)
-			admin.GET("/rankings/all-time", api.GetAllTimeRankings)
-
-			// バーコード生成機能
-			admin.POST("/barcode/generate-thesis", api.GenerateThesisBarcode)
-			admin.GET("/barcode/saved", api.GetSavedBarcodes)
-			admin.GET("/barcode/download/:filename", api.DownloadBarcodeImage)
-			admin.DELETE("/barcode/:filename", api.DeleteBarcodeImage)
-
-			// 書籍画像管理機能（追加）
-			admin.POST("/books/:id/image", api.UploadBookImage)
-			admin.DELETE("/books/:id/image", api.DeleteBookImage)
-		}
+	// レスポンスとOpenAPI文書の照合（LABLIB_VALIDATE_RESPONSES=1 の場合のみ）
+	if os.Getenv("LABLIB_VALIDATE_RESPONSES") != "" {
+		log.Println("Validating /api/v1 responses against the OpenAPI document")
+		r.Use(api.ContractValidator())
 	}
 
+	// APIドキュメント
+	r.GET("/api/openapi.json", api.ServeOpenAPI)
+	r.GET("/api/docs", api.ServeDocs)
+
+	// 従来の /api は既存クライアント向けに旧形式のレスポンスを返す
+	h.RegisterRoutes(r.Group("/api", api.WithVersion(api.VersionLegacy)))
+	// /api/v1 は型付きレスポンスとエラーエンベロープを返す
+	h.RegisterRoutes(r.Group("/api/v1", api.WithVersion(api.VersionV1)))
+
 	// サーバーの起動
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal("Error starting server:", err)
@@ -0,0 +1,94 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// GoogleBooks は Google Books API（https://developers.google.com/books）
+type GoogleBooks struct {
+	BaseURL string // 省略時は https://www.googleapis.com/books/v1
+	APIKey  string
+	Client  *http.Client
+}
+
+func (g *GoogleBooks) Name() string { return "google" }
+
+func (g *GoogleBooks) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = "https://www.googleapis.com/books/v1"
+	}
+	q := url.Values{"q": {"isbn:" + isbn}}
+	if g.APIKey != "" {
+		q.Set("key", g.APIKey)
+	}
+	resp, err := get(ctx, g.Client, base+"/volumes?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Items []struct {
+			VolumeInfo struct {
+				Title         string            `json:"title"`
+				Subtitle      string            `json:"subtitle"`
+				Authors       []string          `json:"authors"`
+				Publisher     string            `json:"publisher"`
+				PublishedDate string            `json:"publishedDate"`
+				PageCount     int               `json:"pageCount"`
+				Language      string            `json:"language"`
+				Description   string            `json:"description"`
+				Categories    []string          `json:"categories"`
+				ImageLinks    map[string]string `json:"imageLinks"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	v := result.Items[0].VolumeInfo
+	title := v.Title
+	if v.Subtitle != "" {
+		title += " : " + v.Subtitle
+	}
+	return &Record{
+		Title:         title,
+		Authors:       v.Authors,
+		Publisher:     v.Publisher,
+		PublishedDate: v.PublishedDate,
+		PageCount:     v.PageCount,
+		Language:      v.Language,
+		Description:   v.Description,
+		Subjects:      v.Categories,
+		CoverURL:      googleCover(v.ImageLinks),
+	}, nil
+}
+
+// googleImageSizes は imageLinks の大きさ（大きい順）
+var googleImageSizes = []string{"extraLarge", "large", "medium", "small", "thumbnail", "smallThumbnail"}
+
+// googleCover は imageLinks のうち最も大きい画像の URL を返す。
+// 表紙の端をめくったように加工する edge=curl は外す。
+func googleCover(links map[string]string) string {
+	for _, size := range googleImageSizes {
+		if link := links[size]; link != "" {
+			u, err := url.Parse(link)
+			if err != nil {
+				return ""
+			}
+			q := u.Query()
+			q.Del("edge")
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,300 @@
+// Package metadata は ISBN から外部の書誌情報サービス（openBD・国立国会図書館サーチ・Google Books・CiNii Books）を
+// 検索し、結果を1件の書誌情報にまとめる。
+// 問い合わせ結果はサービスごとにキャッシュ（book_metadata_cache）に保存し、同じ ISBN の問い合わせではネットワークにアクセスしない。
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+)
+
+var (
+	// ErrNotFound は該当する資料がない場合のエラー
+	ErrNotFound = errors.New("書誌情報が見つかりません")
+	// ErrCoverTooLarge は表紙画像が上限の大きさを超える場合のエラー
+	ErrCoverTooLarge = errors.New("表紙画像が大きすぎます")
+)
+
+// Record は1つのサービスから取得した、またはそれらをまとめた書誌情報
+type Record struct {
+	Title         string   `json:"title"`
+	Authors       []string `json:"authors"`
+	Publisher     string   `json:"publisher"`
+	PublishedDate string   `json:"published_date"` // "2006"・"2006-01"・"2006-01-02" のいずれか
+	PageCount     int      `json:"page_count"`
+	Language      string   `json:"language"` // ISO 639-1（"ja" など）。2文字のコードがない言語は ISO 639-2
+	Description   string   `json:"description"`
+	Subjects      []string `json:"subjects"`
+	CoverURL      string   `json:"cover_url,omitempty"` // 表紙画像の URL（http・https のみ）
+	Sources       []string `json:"sources,omitempty"`   // まとめた結果の場合、該当する資料があったサービスの名前（優先順）
+}
+
+// clean は前後の空白を除き、空の著者・件名をなくす
+func (r *Record) clean() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Authors = nonEmpty(r.Authors)
+	r.Publisher = strings.TrimSpace(r.Publisher)
+	r.PublishedDate = NormalizeDate(r.PublishedDate)
+	r.Language = strings.ToLower(strings.TrimSpace(r.Language))
+	r.Description = strings.TrimSpace(r.Description)
+	r.Subjects = nonEmpty(r.Subjects)
+	r.CoverURL = coverURL(r.CoverURL)
+}
+
+// coverURL は表紙画像の URL を検証し、http を https にする。使えない URL は空文字列を返す。
+func coverURL(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Scheme = "https"
+	return u.String()
+}
+
+// Provider は1つの書誌情報サービス
+type Provider interface {
+	// Name はキャッシュと Record.Sources に使う名前
+	Name() string
+	// Lookup は ISBN-13（codes.ISBN13 で正規化したもの）で資料を検索する。該当する資料がない場合は ErrNotFound を返す。
+	Lookup(ctx context.Context, isbn string) (*Record, error)
+}
+
+// 既定の待ち時間とキャッシュの有効期間
+const (
+	DefaultTimeout     = 5 * time.Second
+	DefaultTTL         = 30 * 24 * time.Hour
+	DefaultNegativeTTL = 24 * time.Hour // 該当なしの結果の有効期間（後から登録される資料があるため短くする）
+)
+
+// Fetcher は複数のサービスに同時に問い合わせ、優先順に結果をまとめる
+type Fetcher struct {
+	providers []Provider
+	cache     repository.MetadataCacheRepository // nil の場合はキャッシュしない
+
+	Timeout     time.Duration // 1つのサービスの応答を待つ時間
+	TTL         time.Duration // 取得した書誌情報のキャッシュの有効期間
+	NegativeTTL time.Duration // 該当なしの結果のキャッシュの有効期間
+	Client      *http.Client  // 表紙画像の取得に使う。nil の場合は http.DefaultClient
+
+	now func() time.Time
+}
+
+// NewFetcher は providers を優先順に使い、結果を cache に保存する Fetcher を返す
+func NewFetcher(providers []Provider, cache repository.MetadataCacheRepository) *Fetcher {
+	return &Fetcher{
+		providers:   providers,
+		cache:       cache,
+		Timeout:     DefaultTimeout,
+		TTL:         DefaultTTL,
+		NegativeTTL: DefaultNegativeTTL,
+		now:         time.Now,
+	}
+}
+
+// Fetch は ISBN-13 の書誌情報を各サービスから取得してまとめる。
+// どのサービスにも該当する資料がなければ ErrNotFound、該当がなく通信に失敗したサービスがあればそのエラーを返す。
+func (f *Fetcher) Fetch(ctx context.Context, isbn string) (*Record, error) {
+	type result struct {
+		record *Record
+		err    error
+	}
+	results := make([]result, len(f.providers))
+	var wg sync.WaitGroup
+	for i, p := range f.providers {
+		wg.Add(1)
+		go func(i int, p Provider) {
+			defer wg.Done()
+			results[i].record, results[i].err = f.lookup(ctx, p, isbn)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var records []*Record
+	var sources []string
+	var errs []error
+	for i, r := range results {
+		switch {
+		case r.err == nil:
+			records = append(records, r.record)
+			sources = append(sources, f.providers[i].Name())
+		case !errors.Is(r.err, ErrNotFound):
+			errs = append(errs, fmt.Errorf("%s: %w", f.providers[i].Name(), r.err))
+		}
+	}
+	if len(records) == 0 {
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return nil, ErrNotFound
+	}
+
+	merged := merge(records)
+	merged.Sources = sources
+	return merged, nil
+}
+
+// lookup はキャッシュにない場合に p に問い合わせ、結果をキャッシュに保存する。通信エラーは保存しない。
+func (f *Fetcher) lookup(ctx context.Context, p Provider, isbn string) (*Record, error) {
+	if record, ok := f.cached(ctx, p.Name(), isbn); ok {
+		if record == nil {
+			return nil, ErrNotFound
+		}
+		return record, nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+	record, err := p.Lookup(lookupCtx, isbn)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if record != nil {
+		record.clean()
+	}
+	f.store(ctx, p.Name(), isbn, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Cover は表紙画像 coverURL（Record.CoverURL）を取得する。
+// 画像がない場合は ErrNotFound、limit バイトを超える場合は ErrCoverTooLarge を返す。画像はキャッシュしない。
+func (f *Fetcher) Cover(ctx context.Context, coverURL string, limit int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+	resp, err := get(ctx, f.Client, coverURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > limit {
+		return nil, ErrCoverTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrCoverTooLarge
+	}
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// cached は有効期間内のキャッシュを返す。該当なしの結果がキャッシュされている場合は nil, true を返す。
+func (f *Fetcher) cached(ctx context.Context, provider, isbn string) (*Record, bool) {
+	if f.cache == nil {
+		return nil, false
+	}
+	entry, err := f.cache.Get(ctx, isbn, provider)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("Metadata cache warning: %v", err)
+		}
+		return nil, false
+	}
+
+	ttl := f.TTL
+	if entry.Data == nil {
+		ttl = f.NegativeTTL
+	}
+	if f.now().Sub(entry.FetchedAt) > ttl {
+		return nil, false
+	}
+	if entry.Data == nil {
+		return nil, true
+	}
+	var record Record
+	if err := json.Unmarshal(entry.Data, &record); err != nil {
+		return nil, false
+	}
+	return &record, true
+}
+
+// store は問い合わせ結果をキャッシュに保存する。record が nil の場合は該当なしとして保存する。
+func (f *Fetcher) store(ctx context.Context, provider, isbn string, record *Record) {
+	if f.cache == nil {
+		return
+	}
+	entry := models.MetadataCacheEntry{ISBN: isbn, Provider: provider, FetchedAt: f.now()}
+	if record != nil {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		entry.Data = data
+	}
+	if err := f.cache.Put(ctx, &entry); err != nil {
+		log.Printf("Metadata cache warning: %v", err)
+	}
+}
+
+// merge は records を優先順にまとめる。各項目は値のある最初の結果を使い、
+// 出版日は同じ日付をより詳しく表す結果があればそちらを使う。件名はすべての結果を重複なく並べる。
+func merge(records []*Record) *Record {
+	m := &Record{Authors: []string{}, Subjects: []string{}}
+	seen := map[string]bool{}
+	for _, r := range records {
+		if m.Title == "" {
+			m.Title = r.Title
+		}
+		if len(m.Authors) == 0 && len(r.Authors) > 0 {
+			m.Authors = append([]string(nil), r.Authors...)
+		}
+		if m.Publisher == "" {
+			m.Publisher = r.Publisher
+		}
+		if len(r.PublishedDate) > len(m.PublishedDate) && strings.HasPrefix(r.PublishedDate, m.PublishedDate) {
+			m.PublishedDate = r.PublishedDate
+		}
+		if m.PageCount == 0 {
+			m.PageCount = r.PageCount
+		}
+		if m.Language == "" {
+			m.Language = r.Language
+		}
+		if m.Description == "" {
+			m.Description = r.Description
+		}
+		if m.CoverURL == "" {
+			m.CoverURL = r.CoverURL
+		}
+		for _, s := range r.Subjects {
+			if !seen[s] {
+				seen[s] = true
+				m.Subjects = append(m.Subjects, s)
+			}
+		}
+	}
+	return m
+}
+
+// nonEmpty は前後の空白を除き、空の値と重複を除いた ss を返す
+func nonEmpty(ss []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, s := range ss {
+		s = strings.TrimSpace(s)
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lablib/repository/memory"
+)
+
+// fakeProvider は record または err を delay 後に返す Provider
+type fakeProvider struct {
+	name   string
+	record *Record
+	err    error
+	delay  time.Duration
+	calls  atomic.Int32
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	p.calls.Add(1)
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	r := *p.record
+	return &r, nil
+}
+
+// 各項目は優先順で値のある最初の結果を使い、応答の速さには左右されない
+func TestFetchMergeOrder(t *testing.T) {
+	first := &fakeProvider{name: "first", delay: 30 * time.Millisecond, record: &Record{
+		Title: " ノルウェイの森 ", Authors: []string{"村上春樹", ""}, PublishedDate: "2004",
+		Subjects: []string{"小説"}, CoverURL: "ftp://example.com/cover.jpg",
+	}}
+	missing := &fakeProvider{name: "missing", err: ErrNotFound}
+	second := &fakeProvider{name: "second", record: &Record{
+		Title: "Norwegian Wood", Authors: []string{"Haruki Murakami"}, Publisher: "講談社", PublishedDate: "2004.9.15",
+		PageCount: 302, Language: "JPN", Description: "内容紹介", Subjects: []string{"日本文学", "小説"},
+		CoverURL: "http://example.com/cover.jpg",
+	}}
+	third := &fakeProvider{name: "third", record: &Record{PublishedDate: "2005-01", Publisher: "別の出版社"}}
+
+	f := NewFetcher([]Provider{first, missing, second, third}, nil)
+	got, err := f.Fetch(context.Background(), testISBN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Record{
+		Title: "ノルウェイの森", Authors: []string{"村上春樹"}, Publisher: "講談社",
+		// 出版日は同じ日付をより詳しく表す結果を使い、異なる日付（2005-01）は使わない
+		PublishedDate: "2004-09-15", PageCount: 302, Language: "jpn", Description: "内容紹介",
+		Subjects: []string{"小説", "日本文学"}, CoverURL: "https://example.com/cover.jpg",
+		Sources: []string{"first", "second", "third"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Fetch =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	upstream := errors.New("connection refused")
+	f := NewFetcher([]Provider{
+		&fakeProvider{name: "missing", err: ErrNotFound},
+		&fakeProvider{name: "down", err: upstream},
+	}, nil)
+	if _, err := f.Fetch(context.Background(), testISBN); !errors.Is(err, upstream) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch = %v, want the upstream error", err)
+	}
+
+	f = NewFetcher([]Provider{&fakeProvider{name: "missing", err: ErrNotFound}}, nil)
+	if _, err := f.Fetch(context.Background(), testISBN); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch = %v, want ErrNotFound", err)
+	}
+	if _, err := NewFetcher(nil, nil).Fetch(context.Background(), testISBN); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch without providers = %v, want ErrNotFound", err)
+	}
+
+	// 通信に失敗したサービスがあっても、他のサービスの結果を返す
+	f = NewFetcher([]Provider{
+		&fakeProvider{name: "down", err: upstream},
+		&fakeProvider{name: "ok", record: &Record{Title: "タイトル"}},
+	}, nil)
+	if r, err := f.Fetch(context.Background(), testISBN); err != nil || r.Title != "タイトル" || !reflect.DeepEqual(r.Sources, []string{"ok"}) {
+		t.Fatalf("Fetch = %+v, %v", r, err)
+	}
+}
+
+// 応答の遅いサービスは Timeout で打ち切り、他のサービスの結果を待たせない
+func TestFetchTimeout(t *testing.T) {
+	slow := &fakeProvider{name: "slow", delay: 10 * time.Second, record: &Record{Title: "遅い"}}
+	fast := &fakeProvider{name: "fast", record: &Record{Title: "速い"}}
+	f := NewFetcher([]Provider{slow, fast}, memory.NewStore().MetadataCache())
+	f.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	r, err := f.Fetch(context.Background(), testISBN)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Fetch took %v", elapsed)
+	}
+	if err != nil || r.Title != "速い" || !reflect.DeepEqual(r.Sources, []string{"fast"}) {
+		t.Fatalf("Fetch = %+v, %v", r, err)
+	}
+
+	// 打ち切ったサービスの結果はキャッシュせず、次の問い合わせで再び問い合わせる
+	f.Fetch(context.Background(), testISBN)
+	if slow.calls.Load() != 2 || fast.calls.Load() != 1 {
+		t.Fatalf("calls: slow %d, fast %d", slow.calls.Load(), fast.calls.Load())
+	}
+
+	slowOnly := NewFetcher([]Provider{&fakeProvider{name: "slow", delay: 10 * time.Second}}, nil)
+	slowOnly.Timeout = 50 * time.Millisecond
+	if _, err := slowOnly.Fetch(context.Background(), testISBN); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Fetch = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestFetchCache(t *testing.T) {
+	found := &fakeProvider{name: "found", record: &Record{Title: "タイトル", Language: "JA"}}
+	missing := &fakeProvider{name: "missing", err: ErrNotFound}
+	f := NewFetcher([]Provider{found, missing}, memory.NewStore().MetadataCache())
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	fetch := func() *Record {
+		t.Helper()
+		r, err := f.Fetch(context.Background(), testISBN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	expectCalls := func(wantFound, wantMissing int32) {
+		t.Helper()
+		if found.calls.Load() != wantFound || missing.calls.Load() != wantMissing {
+			t.Fatalf("calls: found %d, missing %d; want %d, %d", found.calls.Load(), missing.calls.Load(), wantFound, wantMissing)
+		}
+	}
+
+	first := fetch()
+	expectCalls(1, 1)
+	// キャッシュの結果は問い合わせた結果と同じ（clean 済み）
+	if cached := fetch(); !reflect.DeepEqual(cached, first) || cached.Language != "ja" {
+		t.Fatalf("cached = %+v, first = %+v", cached, first)
+	}
+	expectCalls(1, 1)
+
+	// 該当なしの結果は NegativeTTL、書誌情報は TTL を過ぎると問い合わせ直す
+	now = now.Add(f.NegativeTTL + time.Second)
+	fetch()
+	expectCalls(1, 2)
+	now = now.Add(f.TTL)
+	fetch()
+	expectCalls(2, 3)
+
+	// 別の ISBN は別にキャッシュする
+	if _, err := f.Fetch(context.Background(), "9784000000002"); err != nil {
+		t.Fatal(err)
+	}
+	expectCalls(3, 4)
+}
+
+// 通信エラーはキャッシュしない
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	api := newTestAPI(t, http.StatusServiceUnavailable, "")
+	f := NewFetcher([]Provider{&OpenBD{BaseURL: api.URL}}, memory.NewStore().MetadataCache())
+	for i := 0; i < 2; i++ {
+		if _, err := f.Fetch(context.Background(), testISBN); err == nil || errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch = %v, want an upstream error", err)
+		}
+	}
+	if n := api.count(); n != 2 {
+		t.Fatalf("%d requests, want 2", n)
+	}
+}
+
+func TestCover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cover.jpg":
+			w.Write([]byte("0123456789"))
+		case "/empty.jpg":
+		case "/chunked.jpg":
+			// Content-Length のない応答も上限で打ち切る
+			w.(http.Flusher).Flush()
+			w.Write([]byte("0123456789ab"))
+		case "/error":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	f := NewFetcher(nil, nil)
+
+	data, err := f.Cover(context.Background(), srv.URL+"/cover.jpg", 10)
+	if err != nil || string(data) != "0123456789" {
+		t.Fatalf("Cover = %q, %v", data, err)
+	}
+	for path, want := range map[string]error{
+		"/cover.jpg":   ErrCoverTooLarge, // 上限 9 バイト
+		"/chunked.jpg": ErrCoverTooLarge,
+		"/empty.jpg":   ErrNotFound,
+		"/missing.jpg": ErrNotFound,
+	} {
+		if _, err := f.Cover(context.Background(), srv.URL+path, 9); !errors.Is(err, want) {
+			t.Errorf("%s: err = %v, want %v", path, err, want)
+		}
+	}
+	if _, err := f.Cover(context.Background(), srv.URL+"/error", 10); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("server error: err = %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NDLSearch は国立国会図書館サーチの OpenSearch API（https://ndlsearch.ndl.go.jp/help/api）。
+// 国内で出版されたほぼすべての資料を検索できる。
+type NDLSearch struct {
+	BaseURL string // 省略時は https://ndlsearch.ndl.go.jp/api/opensearch
+	Client  *http.Client
+}
+
+func (n *NDLSearch) Name() string { return "ndl" }
+
+// ndlValue は xsi:type 属性を持つことがある要素。xsi:type のある件名は分類記号を表す。
+type ndlValue struct {
+	Type  string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ndlItem は検索結果（RSS）の1件のうち使用する項目
+type ndlItem struct {
+	Title       string     `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Volume      string     `xml:"http://ndl.go.jp/dcndl/terms/ volume"`
+	Creators    []string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Publishers  []string   `xml:"http://purl.org/dc/elements/1.1/ publisher"`
+	Issued      []ndlValue `xml:"http://purl.org/dc/terms/ issued"`
+	Extent      []string   `xml:"extent"`
+	Languages   []string   `xml:"http://purl.org/dc/elements/1.1/ language"`
+	Subjects    []ndlValue `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Description []string   `xml:"http://purl.org/dc/elements/1.1/ description"`
+}
+
+func (n *NDLSearch) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	base := n.BaseURL
+	if base == "" {
+		base = "https://ndlsearch.ndl.go.jp/api/opensearch"
+	}
+	resp, err := get(ctx, n.Client, base+"?"+url.Values{"isbn": {isbn}, "cnt": {"1"}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var feed struct {
+		Items []ndlItem `xml:"channel>item"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+	if len(feed.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	item := feed.Items[0]
+	r := &Record{Title: strings.TrimSpace(item.Title + " " + item.Volume)}
+	for _, c := range item.Creators {
+		r.Authors = append(r.Authors, ndlName(c))
+	}
+	if len(item.Publishers) > 0 {
+		r.Publisher = item.Publishers[0]
+	}
+	// W3CDTF 形式の出版日を優先する
+	for _, d := range item.Issued {
+		if r.PublishedDate == "" || strings.HasSuffix(d.Type, "W3CDTF") {
+			r.PublishedDate = d.Value
+		}
+	}
+	for _, e := range item.Extent {
+		if m := ndlPages.FindStringSubmatch(e); m != nil && r.PageCount == 0 {
+			r.PageCount, _ = strconv.Atoi(m[1])
+		}
+	}
+	if len(item.Languages) > 0 {
+		r.Language = languageCode(item.Languages[0])
+	}
+	for _, s := range item.Subjects {
+		if s.Type == "" {
+			r.Subjects = append(r.Subjects, s.Value)
+		}
+	}
+	if len(item.Description) > 0 {
+		r.Description = item.Description[0]
+	}
+	return r, nil
+}
+
+// ndlPages は数量（"320p ; 19cm" など）のページ数
+var ndlPages = regexp.MustCompile(`([0-9]+)\s*p`)
+
+// ndlLifeDates は典拠形の氏名の末尾の生没年（", 1949-" など）
+var ndlLifeDates = regexp.MustCompile(`,\s*[0-9]{4}-([0-9]{4})?$`)
+
+// ndlName は典拠形の氏名（"村上, 春樹, 1949-"）から生没年を除き、日本語の氏名は姓と名を続けて書く（"村上春樹"）
+func ndlName(s string) string {
+	s = ndlLifeDates.ReplaceAllString(strings.TrimSpace(s), "")
+	family, given, ok := strings.Cut(s, ",")
+	if !ok {
+		return trimRole(s)
+	}
+	given = strings.TrimSpace(given)
+	if isJapanese(family) && isJapanese(given) {
+		return family + given
+	}
+	return s
+}
+
+func isJapanese(s string) bool {
+	for _, r := range s {
+		if !unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) && r != 'ー' && r != '々' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OpenBD は openBD（https://openbd.jp）。出版社が提供する国内の新刊・既刊の書誌情報を返す。
+type OpenBD struct {
+	BaseURL string // 省略時は https://api.openbd.jp/v1
+	Client  *http.Client
+}
+
+func (o *OpenBD) Name() string { return "openbd" }
+
+// openBDItem は openBD の書誌情報のうち使用する項目（ONIX 形式）
+type openBDItem struct {
+	Summary struct {
+		Title     string `json:"title"`
+		Volume    string `json:"volume"`
+		Publisher string `json:"publisher"`
+		Pubdate   string `json:"pubdate"`
+		Author    string `json:"author"` // "著者名／著 訳者名／訳" の形式
+		Cover     string `json:"cover"`  // 表紙画像の URL（ない場合は空）
+	} `json:"summary"`
+	Onix struct {
+		DescriptiveDetail struct {
+			Contributor []struct {
+				PersonName struct {
+					Content string `json:"content"`
+				} `json:"PersonName"`
+			} `json:"Contributor"`
+			Extent []struct {
+				ExtentType  string `json:"ExtentType"`
+				ExtentValue string `json:"ExtentValue"`
+			} `json:"Extent"`
+			Language []struct {
+				LanguageCode string `json:"LanguageCode"`
+			} `json:"Language"`
+			Subject []struct {
+				SubjectHeadingText string `json:"SubjectHeadingText"`
+			} `json:"Subject"`
+		} `json:"DescriptiveDetail"`
+		CollateralDetail struct {
+			TextContent []struct {
+				TextType string `json:"TextType"`
+				Text     string `json:"Text"`
+			} `json:"TextContent"`
+		} `json:"CollateralDetail"`
+	} `json:"onix"`
+}
+
+func (o *OpenBD) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	base := o.BaseURL
+	if base == "" {
+		base = "https://api.openbd.jp/v1"
+	}
+	resp, err := get(ctx, o.Client, base+"/get?"+url.Values{"isbn": {isbn}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 該当する資料がない ISBN は null になる
+	var items []*openBDItem
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 || items[0] == nil {
+		return nil, ErrNotFound
+	}
+
+	item := items[0]
+	detail := item.Onix.DescriptiveDetail
+	r := &Record{
+		Title:         strings.TrimSpace(item.Summary.Title + " " + item.Summary.Volume),
+		Publisher:     item.Summary.Publisher,
+		PublishedDate: item.Summary.Pubdate,
+		CoverURL:      item.Summary.Cover,
+	}
+	for _, c := range detail.Contributor {
+		r.Authors = append(r.Authors, c.PersonName.Content)
+	}
+	if len(r.Authors) == 0 {
+		for _, a := range strings.Fields(item.Summary.Author) {
+			r.Authors = append(r.Authors, trimRole(a))
+		}
+	}
+	// ExtentType 11 はページ数
+	for _, e := range detail.Extent {
+		if e.ExtentType == "11" {
+			r.PageCount, _ = strconv.Atoi(e.ExtentValue)
+		}
+	}
+	if len(detail.Language) > 0 {
+		r.Language = languageCode(detail.Language[0].LanguageCode)
+	}
+	for _, s := range detail.Subject {
+		r.Subjects = append(r.Subjects, s.SubjectHeadingText)
+	}
+	// TextType 03 は内容紹介、02 は短い紹介文
+	for _, textType := range []string{"03", "02"} {
+		for _, t := range item.Onix.CollateralDetail.TextContent {
+			if t.TextType == textType && r.Description == "" {
+				r.Description = t.Text
+			}
+		}
+	}
+	return r, nil
+}
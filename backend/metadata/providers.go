@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultOrder は既定の問い合わせ先と優先順。日本の資料に強いサービスを先にする。
+var DefaultOrder = []string{"openbd", "ndl", "google", "cinii"}
+
+// Options はサービスごとの設定
+type Options struct {
+	GoogleAPIKey string       // 省略時はキーなしで問い合わせる
+	CiNiiAppID   string       // 空の場合は CiNii Books を使わない（アプリケーションIDが必須のため）
+	Client       *http.Client // 省略時は http.DefaultClient
+}
+
+// NewProviders は names（"openbd"・"ndl"・"google"・"cinii"）の順に Provider を作る
+func NewProviders(names []string, opts Options) ([]Provider, error) {
+	var providers []Provider
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "openbd":
+			providers = append(providers, &OpenBD{Client: opts.Client})
+		case "ndl":
+			providers = append(providers, &NDLSearch{Client: opts.Client})
+		case "google":
+			providers = append(providers, &GoogleBooks{APIKey: opts.GoogleAPIKey, Client: opts.Client})
+		case "cinii":
+			if opts.CiNiiAppID != "" {
+				providers = append(providers, &CiNiiBooks{AppID: opts.CiNiiAppID, Client: opts.Client})
+			}
+		case "":
+		default:
+			return nil, fmt.Errorf("不明な書誌情報サービス: %s", name)
+		}
+	}
+	return providers, nil
+}
+
+// get は url を GET する。404 の場合は ErrNotFound、200 以外の場合はエラーを返す。
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+}
+
+var digitRuns = regexp.MustCompile(`[0-9]+`)
+
+// NormalizeDate は "20200115"・"2020.1"・"2020年1月15日"・"2020-01-15" などの日付を
+// "2006"・"2006-01"・"2006-01-02" のいずれかの形に揃える。年が読み取れない場合は空文字列を返す。
+func NormalizeDate(s string) string {
+	runs := digitRuns.FindAllString(s, 3)
+	if len(runs) == 1 && len(runs[0]) > 4 {
+		// 区切りのない "20200115"・"202001"
+		d := runs[0]
+		runs = []string{d[:4]}
+		for d = d[4:]; len(d) >= 2 && len(runs) < 3; d = d[2:] {
+			runs = append(runs, d[:2])
+		}
+	}
+	if len(runs) == 0 || len(runs[0]) != 4 {
+		return ""
+	}
+
+	date := runs[0]
+	limits := []int{12, 31}
+	for i, r := range runs[1:] {
+		n, err := strconv.Atoi(r)
+		if err != nil || n < 1 || n > limits[i] {
+			break
+		}
+		date += fmt.Sprintf("-%02d", n)
+	}
+	return date
+}
+
+// iso639 は ISO 639-2 の言語コードと ISO 639-1 の対応
+var iso639 = map[string]string{
+	"jpn": "ja", "eng": "en", "chi": "zh", "zho": "zh", "kor": "ko",
+	"ger": "de", "deu": "de", "fre": "fr", "fra": "fr", "spa": "es",
+	"ita": "it", "rus": "ru", "por": "pt", "lat": "la",
+}
+
+// languageCode は ISO 639-2 の言語コードを、対応する ISO 639-1 のコードがあればそれに変換する
+func languageCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if c, ok := iso639[code]; ok {
+		return c
+	}
+	return code
+}
+
+// roleSuffixes は責任表示（"村上春樹 著" など）の末尾にある役割
+var roleSuffixes = []string{"編著", "共著", "監修", "著", "編", "訳"}
+
+// trimRole は責任表示から末尾の役割と区切り（"／"）を除いて氏名を返す
+func trimRole(s string) string {
+	s = strings.TrimSpace(s)
+	if name, _, ok := strings.Cut(s, "／"); ok {
+		return strings.TrimSpace(name)
+	}
+	for _, suffix := range roleSuffixes {
+		if name := strings.TrimSpace(strings.TrimSuffix(s, suffix)); name != s && name != "" {
+			return name
+		}
+	}
+	return s
+}
@@ -0,0 +1,236 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const testISBN = "9784062748681"
+
+// testAPI は status と body を返すサーバーを起動し、受け取ったリクエストの URL を記録する
+type testAPI struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+func newTestAPI(t *testing.T, status int, body string) *testAPI {
+	t.Helper()
+	api := &testAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.requests = append(api.requests, r.URL)
+		api.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// count は受け取ったリクエストの数を返す
+func (a *testAPI) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.requests)
+}
+
+// lastQuery は最後のリクエストのパスとクエリを返す
+func (a *testAPI) lastQuery(t *testing.T) (string, url.Values) {
+	t.Helper()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.requests) == 0 {
+		t.Fatal("no request")
+	}
+	u := a.requests[len(a.requests)-1]
+	return u.Path, u.Query()
+}
+
+func expectRecord(t *testing.T, got *Record, err error, want *Record) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("record =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestOpenBD(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, `[{
+		"summary": {"title": "ノルウェイの森", "volume": "上", "publisher": "講談社", "pubdate": "20040915",
+			"author": "村上春樹／著", "cover": "http://cover.openbd.jp/9784062748681.jpg"},
+		"onix": {
+			"DescriptiveDetail": {
+				"Contributor": [{"PersonName": {"content": "村上 春樹"}}],
+				"Extent": [{"ExtentType": "22", "ExtentValue": "15"}, {"ExtentType": "11", "ExtentValue": "302"}],
+				"Language": [{"LanguageCode": "jpn"}],
+				"Subject": [{"SubjectHeadingText": "小説"}]
+			},
+			"CollateralDetail": {"TextContent": [{"TextType": "02", "Text": "短い紹介"}, {"TextType": "03", "Text": "内容紹介"}]}
+		}
+	}]`)
+	r, err := (&OpenBD{BaseURL: api.URL}).Lookup(context.Background(), testISBN)
+	expectRecord(t, r, err, &Record{
+		Title: "ノルウェイの森 上", Authors: []string{"村上 春樹"}, Publisher: "講談社", PublishedDate: "20040915",
+		PageCount: 302, Language: "ja", Description: "内容紹介", Subjects: []string{"小説"},
+		CoverURL: "http://cover.openbd.jp/9784062748681.jpg",
+	})
+	if path, q := api.lastQuery(t); path != "/get" || q.Get("isbn") != testISBN {
+		t.Fatalf("request = %s?%s", path, q.Encode())
+	}
+}
+
+// ONIX に著者がない場合は summary の責任表示から役割を除いて使う
+func TestOpenBDSummaryAuthors(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, `[{"summary": {"title": "ノルウェイの森", "author": "村上春樹／著 安西水丸／イラスト"}, "onix": {}}]`)
+	r, err := (&OpenBD{BaseURL: api.URL}).Lookup(context.Background(), testISBN)
+	expectRecord(t, r, err, &Record{Title: "ノルウェイの森", Authors: []string{"村上春樹", "安西水丸"}})
+}
+
+func TestOpenBDNotFound(t *testing.T) {
+	// 該当する資料がない ISBN は null になる
+	api := newTestAPI(t, http.StatusOK, `[null]`)
+	if _, err := (&OpenBD{BaseURL: api.URL}).Lookup(context.Background(), testISBN); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup = %v, want ErrNotFound", err)
+	}
+}
+
+const ndlFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/"
+	xmlns:dcndl="http://ndl.go.jp/dcndl/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+<channel>
+	<title>ISBN検索</title>
+	<item>
+		<title>ノルウェイの森 上 (講談社文庫)</title>
+		<dc:title>ノルウェイの森</dc:title>
+		<dcndl:volume>上</dcndl:volume>
+		<dc:creator>村上, 春樹, 1949-</dc:creator>
+		<dc:creator>Rubin, Jay</dc:creator>
+		<dc:publisher>講談社</dc:publisher>
+		<dcterms:issued>2004</dcterms:issued>
+		<dcterms:issued xsi:type="dcterms:W3CDTF">2004-09</dcterms:issued>
+		<dcterms:extent>302p ; 15cm</dcterms:extent>
+		<dc:language xsi:type="dcterms:ISO639-2">jpn</dc:language>
+		<dc:subject>小説</dc:subject>
+		<dc:subject xsi:type="dcndl:NDC9">913.6</dc:subject>
+		<dc:description>内容紹介</dc:description>
+	</item>
+</channel>
+</rss>`
+
+func TestNDLSearch(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, ndlFeed)
+	r, err := (&NDLSearch{BaseURL: api.URL + "/api/opensearch"}).Lookup(context.Background(), testISBN)
+	expectRecord(t, r, err, &Record{
+		Title: "ノルウェイの森 上", Authors: []string{"村上春樹", "Rubin, Jay"}, Publisher: "講談社", PublishedDate: "2004-09",
+		PageCount: 302, Language: "ja", Description: "内容紹介", Subjects: []string{"小説"},
+	})
+	if path, q := api.lastQuery(t); path != "/api/opensearch" || q.Get("isbn") != testISBN || q.Get("cnt") != "1" {
+		t.Fatalf("request = %s?%s", path, q.Encode())
+	}
+}
+
+func TestNDLSearchNotFound(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, `<rss version="2.0"><channel><title>ISBN検索</title></channel></rss>`)
+	if _, err := (&NDLSearch{BaseURL: api.URL}).Lookup(context.Background(), testISBN); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGoogleBooks(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, `{"totalItems": 1, "items": [{"volumeInfo": {
+		"title": "The Go Programming Language", "subtitle": "A Tour", "authors": ["Alan A. A. Donovan", "Brian W. Kernighan"],
+		"publisher": "Addison-Wesley", "publishedDate": "2015-10-26", "pageCount": 380, "language": "en",
+		"description": "概要", "categories": ["Computers"],
+		"imageLinks": {"smallThumbnail": "http://books.google.com/books/content?id=1&zoom=5&edge=curl",
+			"thumbnail": "http://books.google.com/books/content?id=1&zoom=1&edge=curl"}
+	}}]}`)
+	r, err := (&GoogleBooks{BaseURL: api.URL, APIKey: "secret"}).Lookup(context.Background(), "9780134190440")
+	expectRecord(t, r, err, &Record{
+		Title: "The Go Programming Language : A Tour", Authors: []string{"Alan A. A. Donovan", "Brian W. Kernighan"},
+		Publisher: "Addison-Wesley", PublishedDate: "2015-10-26", PageCount: 380, Language: "en", Description: "概要",
+		Subjects: []string{"Computers"}, CoverURL: "http://books.google.com/books/content?id=1&zoom=1",
+	})
+	if path, q := api.lastQuery(t); path != "/volumes" || q.Get("q") != "isbn:9780134190440" || q.Get("key") != "secret" {
+		t.Fatalf("request = %s?%s", path, q.Encode())
+	}
+}
+
+func TestGoogleBooksNotFound(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, `{"kind": "books#volumes", "totalItems": 0}`)
+	if _, err := (&GoogleBooks{BaseURL: api.URL}).Lookup(context.Background(), testISBN); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup = %v, want ErrNotFound", err)
+	}
+	if _, q := api.lastQuery(t); q.Has("key") {
+		t.Fatalf("key is sent without APIKey: %s", q.Encode())
+	}
+}
+
+func TestCiNiiBooks(t *testing.T) {
+	// 値が1つの項目は配列にならない
+	api := newTestAPI(t, http.StatusOK, `{"@graph": [{"items": [{
+		"title": "ノルウェイの森", "dc:creator": "村上春樹著 ; 安西水丸訳", "dc:publisher": ["講談社", "講談社文庫"],
+		"dc:pubDate": "2004", "dc:language": "jpn", "dc:subject": ["小説", "日本文学"]
+	}]}]}`)
+	r, err := (&CiNiiBooks{BaseURL: api.URL, AppID: "app"}).Lookup(context.Background(), testISBN)
+	expectRecord(t, r, err, &Record{
+		Title: "ノルウェイの森", Authors: []string{"村上春樹", "安西水丸"}, Publisher: "講談社", PublishedDate: "2004",
+		Language: "ja", Subjects: []string{"小説", "日本文学"},
+	})
+	if _, q := api.lastQuery(t); q.Get("isbn") != testISBN || q.Get("appid") != "app" || q.Get("format") != "json" {
+		t.Fatalf("request query = %s", q.Encode())
+	}
+}
+
+func TestCiNiiBooksNotFound(t *testing.T) {
+	api := newTestAPI(t, http.StatusOK, `{"@graph": [{"items": []}]}`)
+	if _, err := (&CiNiiBooks{BaseURL: api.URL, AppID: "app"}).Lookup(context.Background(), testISBN); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup = %v, want ErrNotFound", err)
+	}
+}
+
+// HTTP 404 は該当なし、それ以外の失敗は通信エラーとして返す
+func TestProviderHTTPErrors(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusServiceUnavailable} {
+		api := newTestAPI(t, status, "")
+		providers := []Provider{
+			&OpenBD{BaseURL: api.URL},
+			&NDLSearch{BaseURL: api.URL},
+			&GoogleBooks{BaseURL: api.URL},
+			&CiNiiBooks{BaseURL: api.URL, AppID: "app"},
+		}
+		for _, p := range providers {
+			_, err := p.Lookup(context.Background(), testISBN)
+			if notFound := errors.Is(err, ErrNotFound); err == nil || notFound != (status == http.StatusNotFound) {
+				t.Errorf("%s: HTTP %d: Lookup = %v", p.Name(), status, err)
+			}
+		}
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	for in, want := range map[string]string{
+		"20200115":    "2020-01-15",
+		"202001":      "2020-01",
+		"2020.1":      "2020-01",
+		"2020年1月15日":  "2020-01-15",
+		"2020-01-15":  "2020-01-15",
+		"2020-13":     "2020",
+		"c2020":       "2020",
+		"平成2年":        "",
+		"":            "",
+		"2015-10-26T": "2015-10-26",
+	} {
+		if got := NormalizeDate(in); got != want {
+			t.Errorf("NormalizeDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
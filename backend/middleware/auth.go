@@ -1,63 +1,150 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
+	"lablib/models"
+	"lablib/repository"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// JWTSecret はトークンの署名に使う鍵。起動時に config.JWTSecret() で設定する（空の場合はトークンを受け付けない）
+var JWTSecret []byte
+
+// 認証・認可に失敗した場合のエラーコード
+const (
+	CodeAuthRequired    = "auth_required"    // トークンがない・不正（401）
+	CodeAdminRequired   = "admin_required"   // 管理者でない（403）
+	CodeAccountInactive = "account_inactive" // 利用停止・卒業・匿名化したユーザー（403）
+	CodeInternal        = "internal_error"   // ユーザーを読み込めない（500）
 )
 
-var JWTSecret = []byte("mock-token") // 本番環境では環境変数から取得
+// UserStore はトークンの利用者を読み込む（repository.UserRepository が満たす）
+type UserStore interface {
+	Get(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
+
+// ErrorResponder は認証・認可に失敗したリクエストに status とエラーコードの応答を書き込み、処理を中断する
+type ErrorResponder func(c *gin.Context, status int, code string)
+
+// abortJSON は respond が nil の場合の応答（{"error": "..."}）
+func abortJSON(c *gin.Context, status int, code string) {
+	msg := "Authorization is required"
+	switch code {
+	case CodeAdminRequired:
+		msg = "Admin access required"
+	case CodeAccountInactive:
+		msg = "Account is inactive"
+	case CodeInternal:
+		msg = "Internal server error"
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": msg})
+}
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware は有効なトークンのないリクエストを respond（nil の場合は {"error": "..."}）で拒否する。
+// ロール・状態はトークンの内容ではなく users から読み込むため、ロールの変更・利用停止はすぐに反映される。
+func AuthMiddleware(users UserStore, respond ErrorResponder) gin.HandlerFunc {
+	if respond == nil {
+		respond = abortJSON
+	}
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			respond(c, http.StatusUnauthorized, CodeAuthRequired)
 			return
 		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+		id, ok := parseToken(tokenString)
+		if !ok {
+			respond(c, http.StatusUnauthorized, CodeAuthRequired)
 			return
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return JWTSecret, nil
-		})
-
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		user, err := users.Get(c.Request.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			respond(c, http.StatusUnauthorized, CodeAuthRequired)
+			return
+		} else if err != nil {
+			log.Printf("[%s] loading token user %s: %v", c.GetString(RequestIDKey), id, err)
+			respond(c, http.StatusInternalServerError, CodeInternal)
 			return
 		}
-
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", claims["user_id"])
-			c.Set("role", claims["role"])
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+		// 利用停止・卒業・匿名化したユーザーのトークンは有効期限内でも使えない
+		if !user.Active() {
+			respond(c, http.StatusForbidden, CodeAccountInactive)
 			return
 		}
+		setUser(c, user)
+		c.Next()
+	}
+}
+
+// parseToken は JWTSecret で署名されたトークンを検証し、利用者の ID を返す
+func parseToken(tokenString string) (uuid.UUID, bool) {
+	if len(JWTSecret) == 0 {
+		return uuid.Nil, false
+	}
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return JWTSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return uuid.Nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return uuid.Nil, false
+	}
+	s, _ := claims["user_id"].(string)
+	id, err := uuid.Parse(s)
+	return id, err == nil
+}
+
+// setUser は後続のハンドラーのために利用者の ID とロールを設定する
+func setUser(c *gin.Context, user *models.User) {
+	c.Set("user_id", user.ID.String())
+	c.Set("role", user.Role)
+}
+
+// OptionalAuth は有効なトークンがあれば AuthMiddleware と同じく user_id・role を設定する。
+// トークンがない・不正な場合も拒否せず、ログインしていないリクエストとして続ける。
+// 利用中でないユーザーは user_id のみ設定し（ハンドラーが account_inactive を返せるように）、ロールは設定しない。
+func OptionalAuth(users UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("role"); !exists {
+			if tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+				if id, ok := parseToken(tokenString); ok {
+					if user, err := users.Get(c.Request.Context(), id); err == nil && user.Active() {
+						setUser(c, user)
+					} else if err == nil {
+						c.Set("user_id", user.ID.String())
+					} else if !errors.Is(err, repository.ErrNotFound) {
+						log.Printf("[%s] loading token user %s: %v", c.GetString(RequestIDKey), id, err)
+					}
+				}
+			}
+		}
+		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// AdminMiddleware は AuthMiddleware の後に置き、管理者でないリクエストを respond で拒否する
+func AdminMiddleware(respond ErrorResponder) gin.HandlerFunc {
+	if respond == nil {
+		respond = abortJSON
+	}
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
+		if !exists || role != models.RoleAdmin {
+			respond(c, http.StatusForbidden, CodeAdminRequired)
 			return
 		}
 		c.Next()
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeader はリクエストIDを受け渡すヘッダー
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey は gin.Context にリクエストIDを保存するキー
+	RequestIDKey = "request_id"
+)
+
+// RequestID はリクエストごとにIDを割り当て、レスポンスヘッダーとコンテキストに設定する。
+// クライアントが X-Request-ID を送った場合はその値を引き継ぐ。
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.New().String()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 添付ファイルの公開範囲
+const (
+	VisibilityPublic = "public" // ログインしていない利用者にも公開
+	VisibilityLab    = "lab"    // ログインした研究室のメンバーのみ
+	VisibilityAdmin  = "admin"  // 管理者のみ
+)
+
+// Visibilities は Attachment.Visibility に指定できる値
+var Visibilities = []string{VisibilityPublic, VisibilityLab, VisibilityAdmin}
+
+// ウイルススキャンなどの検査の結果
+const (
+	ScanStatusUnscanned = "unscanned" // 検査が設定されていない
+	ScanStatusClean     = "clean"
+)
+
+// Attachment は書籍に添付した電子ファイル（論文PDF・マニュアル・補足資料など）
+type Attachment struct {
+	ID          uuid.UUID  `json:"id"`
+	BookID      uuid.UUID  `json:"book_id"`
+	Filename    string     `json:"filename"`     // アップロード時のファイル名（ダウンロード時の名前）
+	StoragePath string     `json:"storage_path"` // 保存先のファイル名
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"` // 内容のSHA-256（16進数）
+	Visibility  string     `json:"visibility"`
+	Description string     `json:"description"`
+	ScanStatus  string     `json:"scan_status"`
+	UploadedBy  *uuid.UUID `json:"uploaded_by"`
+	Downloads   int        `json:"downloads"` // ダウンロード回数（取得時に集計）
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// AttachmentDownload は添付ファイルのダウンロード1回分の記録
+type AttachmentDownload struct {
+	AttachmentID uuid.UUID  `json:"attachment_id"`
+	UserID       *uuid.UUID `json:"user_id"` // ログインしていない場合は nil
+	DownloadedAt time.Time  `json:"downloaded_at"`
+}
+
+// AttachmentStats は添付ファイルごとのダウンロード数の集計
+type AttachmentStats struct {
+	Attachment     Attachment `json:"attachment"`
+	Downloads      int        `json:"downloads"`
+	UniqueUsers    int        `json:"unique_users"`
+	LastDownloaded *time.Time `json:"last_downloaded"`
+}
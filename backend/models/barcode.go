@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// バーコードの種類（Barcode.Symbology）
+const (
+	BarcodeSymbologyEAN13 = "ean13"
+)
+
+// Barcode は生成して保存したバーコード。同じ種類・コードのバーコードは1件のみ記録する。
+// 画像のダウンロード・削除は ID で指定し、利用者の指定したファイル名やパスは使わない。
+type Barcode struct {
+	ID          uuid.UUID     `json:"id"`
+	Code        string        `json:"code"`
+	Symbology   string        `json:"symbology"`
+	BookID      *uuid.UUID    `json:"book_id"`      // 登録・関連付けた書籍（書籍の削除後は nil）
+	CopyID      *uuid.UUID    `json:"copy_id"`      // コードを付けたコピー（コピーの削除後は nil）
+	Filename    string        `json:"filename"`     // ダウンロード時のファイル名
+	StoragePath string        `json:"storage_path"` // 保存先でのファイル名（サーバー側で生成する）
+	Size        int64         `json:"size"`
+	Params      BarcodeParams `json:"params"`
+	CreatedBy   *uuid.UUID    `json:"created_by"` // 生成を依頼した管理者
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// BarcodeParams はバーコードの生成時の指定
+type BarcodeParams struct {
+	Year       string `json:"year,omitempty"` // 卒論バーコードの年度・学籍番号・著者名・タイトル
+	StudentID  string `json:"student_id,omitempty"`
+	AuthorName string `json:"author_name,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Width      int    `json:"width,omitempty"` // 画像の幅・高さ（ピクセル）
+	Height     int    `json:"height,omitempty"`
+}
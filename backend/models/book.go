@@ -7,25 +7,66 @@ import (
 )
 
 type Book struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Author      string    `json:"author"`
-	ISBN        string    `json:"isbn"`
-	JAN         string    `json:"jan"`
-	EAN13       string    `json:"ean13"`
-	Type        string    `json:"type"`
-	TotalCopies int       `json:"total_copies"`
-	Barcode     string    `json:"barcode"`
-	Location    string    `json:"location"`
-	ImagePath   string    `json:"image_path"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title"`
+	// Author は表示用の著者名。Authors の氏名を ", " で連結したもので、Authors を省略した場合は
+	// この値を1人目の著者として扱う。
+	Author      string       `json:"author"`
+	Authors     []BookAuthor `json:"authors"`
+	ISBN        string       `json:"isbn"`
+	JAN         string       `json:"jan"`
+	EAN13       string       `json:"ean13"`
+	Type        string       `json:"type"`
+	TotalCopies int          `json:"total_copies"`
+	Barcode     string       `json:"barcode"`
+	Location    string       `json:"location"`
+	Publisher   string       `json:"publisher"`
+	// PublishedDate は出版日。"2006"・"2006-01"・"2006-01-02" のいずれかの精度で持つ。
+	PublishedDate string `json:"published_date"`
+	// PublishedYear は出版年（卒論は提出年度）。PublishedDate がある場合はその年。不明な場合は nil。
+	PublishedYear *int      `json:"published_year,omitempty"`
+	Edition       string    `json:"edition"`
+	PageCount     int       `json:"page_count"` // 0は不明
+	Language      string    `json:"language"`   // ISO 639-1 の言語コード（"ja"・"en" など）
+	Description   string    `json:"description"`
+	Subjects      []string  `json:"subjects"` // 件名（分類タグ）
+	Notes         string    `json:"notes"`    // 管理用のメモ
+	ImagePath     string    `json:"image_path"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// WithdrawnAt は除籍した日時。除籍した書籍は検索・貸出の対象外になるが、貸出の履歴・統計のために残し、復元できる。
+	WithdrawnAt      *time.Time `json:"withdrawn_at,omitempty"`
+	WithdrawalReason string     `json:"withdrawal_reason,omitempty"`
+}
+
+// 著者の役割
+const (
+	AuthorRoleAuthor     = "author"
+	AuthorRoleEditor     = "editor"
+	AuthorRoleTranslator = "translator"
+	AuthorRoleSupervisor = "supervisor" // 卒論の指導教員
+)
+
+// AuthorRoles は BookAuthor.Role に指定できる値
+var AuthorRoles = []string{AuthorRoleAuthor, AuthorRoleEditor, AuthorRoleTranslator, AuthorRoleSupervisor}
+
+// BookAuthor は書籍の著者1人。Book.Authors の順序が表示順（先頭が第一著者）。
+type BookAuthor struct {
+	Name string `json:"name"`
+	Role string `json:"role"` // AuthorRoles のいずれか。省略時は "author"
+}
+
+// BookSummary は一覧表示用に貸出可能数を付与した書籍情報
+type BookSummary struct {
+	Book
+	AvailableCopies int `json:"available_copies"`
 }
 
 type BookCopy struct {
 	ID           uuid.UUID `json:"id"`
 	BookID       uuid.UUID `json:"book_id"`
 	SerialNumber string    `json:"serial_number"`
+	Barcode      string    `json:"barcode"`
 	IsAvailable  bool      `json:"is_available"`
 	Location     *string   `json:"location,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
@@ -40,6 +81,7 @@ type BorrowRecord struct {
 	DueDate    time.Time  `json:"due_date"`
 	ReturnedAt *time.Time `json:"returned_at,omitempty"`
 	Status     string     `json:"status"`
+	RenewCount int        `json:"renew_count"`
 	Book       Book       `json:"book"`
 	BookCopy   BookCopy   `json:"book_copy"`
 	User       User       `json:"user"`
@@ -60,3 +102,10 @@ type MonthlyRanking struct {
 	BorrowCount int       `json:"borrow_count"`
 	Book        Book      `json:"book"`
 }
+
+// AllTimeRanking は全期間の貸出回数を集計したランキング項目
+type AllTimeRanking struct {
+	BookID      uuid.UUID `json:"book_id"`
+	BorrowCount int       `json:"borrow_count"`
+	Book        Book      `json:"book"`
+}
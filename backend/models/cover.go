@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 表紙画像の取得の書籍ごとの結果
+const (
+	CoverResultImported = "imported"  // 表紙画像を保存した
+	CoverResultNotFound = "not_found" // 書誌情報・表紙画像がない
+	CoverResultError    = "error"     // 取得・検証・保存に失敗した
+)
+
+// CoverJob は画像のない書籍の表紙画像を外部の書誌情報サービスから取得するジョブ。
+// 状態は取り込みジョブと同じ ImportStatus* を使う。
+// 書籍は1冊ずつ処理し、結果のある書籍を除いて途中から再開できる。
+type CoverJob struct {
+	ID uuid.UUID `json:"id"`
+
+	Status         string `json:"status"`
+	TotalBooks     int    `json:"total_books"` // 開始時点で画像がなく ISBN のある書籍の数
+	ProcessedBooks int    `json:"processed_books"`
+	ImportedBooks  int    `json:"imported_books"`
+	NotFoundBooks  int    `json:"not_found_books"`
+	ErrorBooks     int    `json:"error_books"`
+	Error          string `json:"error"` // 中断した原因
+
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// CoverResult は表紙画像の取得の1冊の結果
+type CoverResult struct {
+	JobID     uuid.UUID  `json:"job_id"`
+	Seq       int        `json:"seq"`     // 処理した順番（1始まり）
+	BookID    *uuid.UUID `json:"book_id"` // 書籍を削除した場合は nil
+	Title     string     `json:"title"`
+	ISBN      string     `json:"isbn"`
+	Status    string     `json:"status"`
+	CoverURL  string     `json:"cover_url"`  // 取得元の URL
+	ImagePath string     `json:"image_path"` // 保存した画像のファイル名
+	Message   string     `json:"message"`    // not_found・error の原因のコード（"cover_not_found" など）
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 一括取り込みジョブの状態
+const (
+	ImportStatusQueued    = "queued"    // 実行待ち
+	ImportStatusRunning   = "running"   // 実行中
+	ImportStatusCompleted = "completed" // すべての行を処理した
+	ImportStatusFailed    = "failed"    // 途中で中断した（再開できる）
+)
+
+// 取り込みの行ごとの結果
+const (
+	ImportRowImported  = "imported"  // 登録した（ドライランでは登録できる）
+	ImportRowDuplicate = "duplicate" // ISBN などが同じ書籍が既にあるため登録しない
+	ImportRowError     = "error"     // 入力に誤りがあるため登録しない
+)
+
+// ImportJob は CSV・XLSX からの書籍の一括取り込み。
+// 行は1行ずつトランザクションで登録し、ProcessedRows まで処理済みとして途中から再開できる。
+type ImportJob struct {
+	ID          uuid.UUID         `json:"id"`
+	Filename    string            `json:"filename"`     // アップロード時のファイル名
+	StoragePath string            `json:"storage_path"` // 保存先のファイル名
+	Format      string            `json:"format"`       // "csv" または "xlsx"
+	Sheet       string            `json:"sheet"`        // XLSX のシート名（空の場合は最初のシート）
+	Mapping     map[string]string `json:"mapping"`      // 項目名（"title" など）→ 列の見出し
+	DryRun      bool              `json:"dry_run"`      // 検証のみで登録しない
+	Enrich      bool              `json:"enrich"`       // 空の書誌情報を ISBN から外部サービスで補う
+
+	Status        string `json:"status"`
+	TotalRows     int    `json:"total_rows"`     // 見出しと空行を除いた行数
+	ProcessedRows int    `json:"processed_rows"` // 処理済みの行数
+	ImportedRows  int    `json:"imported_rows"`
+	DuplicateRows int    `json:"duplicate_rows"`
+	ErrorRows     int    `json:"error_rows"`
+	Error         string `json:"error"` // 中断した原因
+
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// ImportRow は取り込みの1行の結果
+type ImportRow struct {
+	JobID       uuid.UUID  `json:"job_id"`
+	RowNumber   int        `json:"row_number"` // 表の行番号（見出しの行を含めて1始まり）
+	Status      string     `json:"status"`
+	Title       string     `json:"title"`
+	ISBN        string     `json:"isbn"`
+	BookID      *uuid.UUID `json:"book_id"`      // 登録した書籍
+	DuplicateOf *uuid.UUID `json:"duplicate_of"` // Status が duplicate の場合の既存の書籍
+	Messages    []string   `json:"messages"`     // エラー・警告のコード（"invalid_isbn" など）
+}
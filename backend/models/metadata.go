@@ -0,0 +1,15 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MetadataCacheEntry は外部の書誌情報サービスへの問い合わせ結果のキャッシュ。
+// ISBN とサービス（プロバイダー）ごとに1件保存する。
+type MetadataCacheEntry struct {
+	ISBN      string          `json:"isbn"`     // ISBN-13
+	Provider  string          `json:"provider"` // "google"・"openbd"・"ndl"・"cinii"
+	Data      json.RawMessage `json:"data"`     // 取得した書誌情報。該当する資料がなかった場合は nil
+	FetchedAt time.Time       `json:"fetched_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 学位の種類
+const (
+	DegreeBachelor = "bachelor"
+	DegreeMaster   = "master"
+	DegreeDoctor   = "doctor"
+)
+
+// Degrees は Thesis.Degree に指定できる値
+var Degrees = []string{DegreeBachelor, DegreeMaster, DegreeDoctor}
+
+// Thesis は論文（books の type = 'thesis'）固有の情報。
+// 著者・指導教員（役割 supervisor の著者）・要旨（Description）・キーワード（Subjects）は
+// 書籍の書誌情報として保存し、通常の書籍と同じく検索の対象にする。
+type Thesis struct {
+	BookID       uuid.UUID `json:"book_id"`
+	AcademicYear int       `json:"academic_year"` // 年度
+	Degree       string    `json:"degree"`
+	StudentID    string    `json:"student_id"`
+	PDFPath      string    `json:"pdf_path"` // 論文PDFのファイル名。未登録の場合は空
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Book         Book      `json:"book"`
+}
+
+// Supervisors は指導教員の氏名を表示順に返す
+func (t Thesis) Supervisors() []string {
+	var names []string
+	for _, a := range t.Book.Authors {
+		if a.Role == AuthorRoleSupervisor {
+			names = append(names, a.Name)
+		}
+	}
+	return names
+}
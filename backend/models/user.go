@@ -6,23 +6,88 @@ import (
 	"github.com/google/uuid"
 )
 
+// ユーザーの状態
+const (
+	UserStatusActive      = "active"      // 利用中
+	UserStatusDeactivated = "deactivated" // 利用停止
+	UserStatusGraduated   = "graduated"   // 卒業・修了（利用停止と同じく、ログインできない）
+)
+
+// UserStatuses は User.Status に指定できる値
+var UserStatuses = []string{UserStatusActive, UserStatusDeactivated, UserStatusGraduated}
+
+// ユーザーのロール
+const (
+	RoleUser  = "user"  // 研究室のメンバー（管理者が作成・一括登録したユーザー）
+	RoleAdmin = "admin" // 管理者
+	RoleGuest = "guest" // 自分で登録したユーザー。管理者が user にするまで研究室のメンバーとして扱わない
+)
+
+// UserRoles は User.Role に指定できる値
+var UserRoles = []string{RoleUser, RoleAdmin, RoleGuest}
+
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	StudentID string    `json:"student_id"`
-	Name      string    `json:"name"`
-	Password  string    `json:"password"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uuid.UUID  `json:"id"`
+	StudentID     string     `json:"student_id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Password      string     `json:"-"` // bcrypt のハッシュ。JSON には含めない
+	Role          string     `json:"role"`
+	Status        string     `json:"status"`         // UserStatuses のいずれか
+	DeactivatedAt *time.Time `json:"deactivated_at"` // 利用停止・卒業にした日時
+	AnonymizedAt  *time.Time `json:"anonymized_at"`  // 匿名化（削除）した日時
+	AnonymizedBy  *uuid.UUID `json:"anonymized_by"`  // 匿名化（削除）した管理者
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Notifications NotificationPreferences `json:"notifications"`
+}
+
+// NotificationPreferences は利用者ごとの通知の設定
+type NotificationPreferences struct {
+	DueReminder bool `json:"due_reminder"` // 返却期限が近づいたときの通知
+	Overdue     bool `json:"overdue"`      // 返却期限を過ぎたときの通知
+}
+
+// DefaultNotificationPreferences は新規ユーザーの通知の設定（すべて有効）
+var DefaultNotificationPreferences = NotificationPreferences{DueReminder: true, Overdue: true}
+
+// Active はログイン・貸出ができる状態かどうかを返す
+func (u User) Active() bool {
+	return u.Status == UserStatusActive
+}
+
+// UserInvite はパスワードを利用者自身が設定するための招待。トークンは SHA-256 のハッシュのみを保存する。
+type UserInvite struct {
+	TokenHash string     `json:"-"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	StudentID string    `json:"student_id"`
-	Name      string    `json:"name"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uuid.UUID  `json:"id"`
+	StudentID     string     `json:"student_id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	Status        string     `json:"status"`
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	AnonymizedAt  *time.Time `json:"anonymized_at"`
+	AnonymizedBy  *uuid.UUID `json:"anonymized_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Notifications NotificationPreferences `json:"notifications"`
+}
+
+// RegisterRequest はユーザー登録・管理者によるユーザー作成のリクエスト
+type RegisterRequest struct {
+	StudentID string `json:"student_id" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	Email     string `json:"email"`
+	Password  string `json:"password" binding:"required"`
 }
 
 type LoginRequest struct {
@@ -30,6 +95,12 @@ type LoginRequest struct {
 	Password  string `json:"password" binding:"required"`
 }
 
+// AcceptInviteRequest は招待のトークンでパスワードを設定するリクエスト
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 type LoginResponse struct {
 	Token string       `json:"token"`
 	User  UserResponse `json:"user"`
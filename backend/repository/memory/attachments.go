@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type attachmentRepo struct{ db *db }
+
+// withDownloads はダウンロード回数を数えて埋める（呼び出し側でロックを取得すること）
+func (r attachmentRepo) withDownloads(a models.Attachment) models.Attachment {
+	a.Downloads = 0
+	for _, d := range r.db.data.downloads {
+		if d.AttachmentID == a.ID {
+			a.Downloads++
+		}
+	}
+	return a
+}
+
+func (r attachmentRepo) ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.Attachment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var attachments []models.Attachment
+	for _, a := range r.db.data.attachments {
+		if a.BookID == bookID {
+			attachments = append(attachments, r.withDownloads(a))
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if !attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+		}
+		return attachments[i].ID.String() < attachments[j].ID.String()
+	})
+	return attachments, nil
+}
+
+func (r attachmentRepo) Get(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	a, ok := r.db.data.attachments[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	a = r.withDownloads(a)
+	return &a, nil
+}
+
+func (r attachmentRepo) Create(ctx context.Context, attachment *models.Attachment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.books[attachment.BookID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.db.data.attachments[attachment.ID]; ok {
+		return repository.ErrConflict
+	}
+	a := *attachment
+	a.Downloads = 0
+	r.db.data.attachments[a.ID] = a
+	return nil
+}
+
+func (r attachmentRepo) Update(ctx context.Context, attachment *models.Attachment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	a, ok := r.db.data.attachments[attachment.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	a.Filename = attachment.Filename
+	a.Visibility = attachment.Visibility
+	a.Description = attachment.Description
+	a.UpdatedAt = attachment.UpdatedAt
+	r.db.data.attachments[a.ID] = a
+	return nil
+}
+
+func (r attachmentRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.attachments[id]; !ok {
+		return repository.ErrNotFound
+	}
+	r.db.removeAttachment(id)
+	return nil
+}
+
+// removeAttachment は添付ファイルとそのダウンロード記録を削除する（呼び出し側でロックを取得すること）
+func (db *db) removeAttachment(id uuid.UUID) {
+	delete(db.data.attachments, id)
+	downloads := db.data.downloads[:0]
+	for _, d := range db.data.downloads {
+		if d.AttachmentID != id {
+			downloads = append(downloads, d)
+		}
+	}
+	db.data.downloads = downloads
+}
+
+func (r attachmentRepo) RecordDownload(ctx context.Context, download *models.AttachmentDownload) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.attachments[download.AttachmentID]; !ok {
+		return repository.ErrNotFound
+	}
+	r.db.data.downloads = append(r.db.data.downloads, *download)
+	return nil
+}
+
+func (r attachmentRepo) Stats(ctx context.Context, filter repository.AttachmentStatsFilter) ([]models.AttachmentStats, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	byID := map[uuid.UUID]*models.AttachmentStats{}
+	users := map[uuid.UUID]map[uuid.UUID]bool{}
+	for _, d := range r.db.data.downloads {
+		a, ok := r.db.data.attachments[d.AttachmentID]
+		switch {
+		case !ok,
+			filter.BookID != uuid.Nil && a.BookID != filter.BookID,
+			!filter.From.IsZero() && d.DownloadedAt.Before(filter.From),
+			!filter.To.IsZero() && !d.DownloadedAt.Before(filter.To):
+			continue
+		}
+		s, ok := byID[a.ID]
+		if !ok {
+			s = &models.AttachmentStats{Attachment: r.withDownloads(a)}
+			byID[a.ID] = s
+			users[a.ID] = map[uuid.UUID]bool{}
+		}
+		s.Downloads++
+		if d.UserID != nil {
+			users[a.ID][*d.UserID] = true
+		}
+		if s.LastDownloaded == nil || d.DownloadedAt.After(*s.LastDownloaded) {
+			at := d.DownloadedAt
+			s.LastDownloaded = &at
+		}
+	}
+
+	stats := make([]models.AttachmentStats, 0, len(byID))
+	for id, s := range byID {
+		s.UniqueUsers = len(users[id])
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Downloads != stats[j].Downloads {
+			return stats[i].Downloads > stats[j].Downloads
+		}
+		return stats[i].Attachment.Filename < stats[j].Attachment.Filename
+	})
+	if filter.Limit > 0 && len(stats) > filter.Limit {
+		stats = stats[:filter.Limit]
+	}
+	return stats, nil
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type barcodeRepo struct{ db *db }
+
+var barcodeLess = map[string]func(a, b models.Barcode) bool{
+	"filename":   func(a, b models.Barcode) bool { return a.Filename < b.Filename },
+	"code":       func(a, b models.Barcode) bool { return a.Code < b.Code },
+	"created_at": func(a, b models.Barcode) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"size":       func(a, b models.Barcode) bool { return a.Size < b.Size },
+}
+
+func (r barcodeRepo) List(ctx context.Context, filter repository.BarcodeFilter) ([]models.Barcode, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var barcodes []models.Barcode
+	for _, b := range r.db.data.barcodes {
+		if filter.Code != "" && !strings.HasPrefix(b.Code, filter.Code) {
+			continue
+		}
+		if filter.Symbology != "" && b.Symbology != filter.Symbology {
+			continue
+		}
+		if filter.BookID != uuid.Nil && (b.BookID == nil || *b.BookID != filter.BookID) {
+			continue
+		}
+		if filter.CopyID != uuid.Nil && (b.CopyID == nil || *b.CopyID != filter.CopyID) {
+			continue
+		}
+		if filter.CreatedBy != uuid.Nil && (b.CreatedBy == nil || *b.CreatedBy != filter.CreatedBy) {
+			continue
+		}
+		if filter.Year != "" && b.Params.Year != filter.Year {
+			continue
+		}
+		if filter.StudentID != "" && b.Params.StudentID != filter.StudentID {
+			continue
+		}
+		barcodes = append(barcodes, b)
+	}
+	// 同じ値の順序を ID で固定してから並べ替える
+	sort.Slice(barcodes, func(i, j int) bool { return barcodes[i].ID.String() < barcodes[j].ID.String() })
+	if err := sortBy(barcodes, filter.Sort, "filename", barcodeLess); err != nil {
+		return nil, 0, err
+	}
+	return paginate(barcodes, filter.Page), len(barcodes), nil
+}
+
+func (r barcodeRepo) Get(ctx context.Context, id uuid.UUID) (*models.Barcode, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	b, ok := r.db.data.barcodes[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &b, nil
+}
+
+func (r barcodeRepo) FindByCode(ctx context.Context, symbology, code string) (*models.Barcode, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, b := range r.db.data.barcodes {
+		if b.Symbology == symbology && b.Code == code {
+			return &b, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r barcodeRepo) Create(ctx context.Context, barcode *models.Barcode) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, b := range r.db.data.barcodes {
+		if b.ID == barcode.ID || b.StoragePath == barcode.StoragePath {
+			return repository.ErrConflict
+		}
+		if barcode.Code != "" && b.Symbology == barcode.Symbology && b.Code == barcode.Code {
+			return repository.ErrConflict
+		}
+	}
+	r.db.data.barcodes[barcode.ID] = *barcode
+	return nil
+}
+
+func (r barcodeRepo) SetLinks(ctx context.Context, id uuid.UUID, bookID, copyID *uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.data.barcodes[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	b.BookID, b.CopyID = bookID, copyID
+	r.db.data.barcodes[id] = b
+	return nil
+}
+
+func (r barcodeRepo) SetParams(ctx context.Context, id uuid.UUID, params models.BarcodeParams) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.data.barcodes[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	b.Params = params
+	r.db.data.barcodes[id] = b
+	return nil
+}
+
+func (r barcodeRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.barcodes[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.db.data.barcodes, id)
+	return nil
+}
+
+// clearBarcodeCopy はコピーの削除時にバーコードのコピーへの関連付けを外す
+func (db *db) clearBarcodeCopy(copyID uuid.UUID) {
+	for id, b := range db.data.barcodes {
+		if b.CopyID != nil && *b.CopyID == copyID {
+			b.CopyID = nil
+			db.data.barcodes[id] = b
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"lablib/codes"
+	"lablib/models"
+	"lablib/repository"
+	"lablib/search"
+
+	"github.com/google/uuid"
+)
+
+type bookRepo struct{ db *db }
+
+// bookLess は repository.BookSortFields ごとの比較関数
+var bookLess = map[string]func(a, b models.BookSummary) bool{
+	"title":            func(a, b models.BookSummary) bool { return a.Title < b.Title },
+	"author":           func(a, b models.BookSummary) bool { return a.Author < b.Author },
+	"type":             func(a, b models.BookSummary) bool { return a.Type < b.Type },
+	"location":         func(a, b models.BookSummary) bool { return a.Location < b.Location },
+	"available_copies": func(a, b models.BookSummary) bool { return a.AvailableCopies < b.AvailableCopies },
+	"created_at":       func(a, b models.BookSummary) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"updated_at":       func(a, b models.BookSummary) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+}
+
+// bookMatch は filter の絞り込み条件（Sort・Page 以外）に一致するかを返す
+func bookMatch(b models.BookSummary, filter repository.BookFilter, query search.Query) bool {
+	switch {
+	case filter.Withdrawn != (b.WithdrawnAt != nil),
+		filter.Type != "" && b.Type != filter.Type,
+		filter.Location != "" && b.Location != filter.Location,
+		filter.Author != "" && !hasAuthor(b.Book, filter.Author),
+		filter.Year != 0 && (b.PublishedYear == nil || *b.PublishedYear != filter.Year),
+		filter.Publisher != "" && b.Publisher != filter.Publisher,
+		filter.Language != "" && b.Language != filter.Language,
+		filter.Subject != "" && !contains(b.Subjects, filter.Subject),
+		filter.Available != nil && *filter.Available != (b.AvailableCopies > 0),
+		filter.HasImage != nil && *filter.HasImage != (b.ImagePath != ""):
+		return false
+	}
+	return query.Match(repository.SearchDocument(b.Book))
+}
+
+func hasAuthor(b models.Book, name string) bool {
+	for _, a := range b.Authors {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneBook は保存する書籍がスライスを呼び出し側と共有しないようにコピーする
+func cloneBook(b models.Book) models.Book {
+	b.Authors = append([]models.BookAuthor(nil), b.Authors...)
+	for i := range b.Authors {
+		if b.Authors[i].Role == "" {
+			b.Authors[i].Role = models.AuthorRoleAuthor
+		}
+	}
+	b.Subjects = append([]string(nil), b.Subjects...)
+	return b
+}
+
+// summaries は全書籍に貸出可能数を付与して返す。呼び出し側でロックを取得すること。
+func (r bookRepo) summaries() []models.BookSummary {
+	available := map[uuid.UUID]int{}
+	for _, bc := range r.db.data.copies {
+		if bc.IsAvailable {
+			available[bc.BookID]++
+		}
+	}
+	books := make([]models.BookSummary, 0, len(r.db.data.books))
+	for _, b := range r.db.data.books {
+		books = append(books, models.BookSummary{Book: b, AvailableCopies: available[b.ID]})
+	}
+	return books
+}
+
+func (r bookRepo) Search(ctx context.Context, filter repository.BookFilter) ([]models.BookSummary, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	query := search.Parse(filter.Query)
+	scores := map[uuid.UUID]float64{}
+	var books []models.BookSummary
+	for _, b := range r.summaries() {
+		if !bookMatch(b, filter, query) {
+			continue
+		}
+		scores[b.ID] = query.Score(repository.SearchDocument(b.Book))
+		books = append(books, b)
+	}
+
+	// マップの順序に依存しないよう ID 順に並べてから並べ替える
+	sort.Slice(books, func(i, j int) bool { return books[i].ID.String() < books[j].ID.String() })
+
+	sortField := filter.Sort
+	if sortField.Field == "relevance" || (sortField.Field == "" && !query.Empty()) {
+		// 関連度順（同点はタイトル順）。検索語がない場合はタイトル順になる。
+		sortField = repository.Sort{Field: "title"}
+		if err := sortBy(books, sortField, "title", bookLess); err != nil {
+			return nil, 0, err
+		}
+		sort.SliceStable(books, func(i, j int) bool { return scores[books[i].ID] > scores[books[j].ID] })
+	} else if err := sortBy(books, sortField, "title", bookLess); err != nil {
+		return nil, 0, err
+	}
+	return paginate(books, filter.Page), len(books), nil
+}
+
+// Facets は PostgreSQL 実装と同じく、各項目をその項目自身の絞り込みを外して集計する
+func (r bookRepo) Facets(ctx context.Context, filter repository.BookFilter) (*repository.BookFacets, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	query := search.Parse(filter.Query)
+	books := r.summaries()
+	countAll := func(without func(*repository.BookFilter), values func(models.BookSummary) []string) map[string]int {
+		f := filter
+		without(&f)
+		counts := map[string]int{}
+		for _, b := range books {
+			if !bookMatch(b, f, query) {
+				continue
+			}
+			seen := map[string]bool{}
+			for _, v := range values(b) {
+				if v != "" && !seen[v] {
+					seen[v] = true
+					counts[v]++
+				}
+			}
+		}
+		return counts
+	}
+	count := func(without func(*repository.BookFilter), value func(models.BookSummary) string) map[string]int {
+		return countAll(without, func(b models.BookSummary) []string { return []string{value(b)} })
+	}
+
+	return &repository.BookFacets{
+		Type: facetByCount(count(func(f *repository.BookFilter) { f.Type = "" },
+			func(b models.BookSummary) string { return b.Type }), 0),
+		Location: facetByCount(count(func(f *repository.BookFilter) { f.Location = "" },
+			func(b models.BookSummary) string { return b.Location }), repository.FacetLimit),
+		Author: facetByCount(countAll(func(f *repository.BookFilter) { f.Author = "" },
+			func(b models.BookSummary) []string {
+				names := make([]string, len(b.Authors))
+				for i, a := range b.Authors {
+					names[i] = a.Name
+				}
+				return names
+			}), repository.FacetLimit),
+		Year: facetByValue(count(func(f *repository.BookFilter) { f.Year = 0 },
+			func(b models.BookSummary) string {
+				if b.PublishedYear == nil {
+					return ""
+				}
+				return strconv.Itoa(*b.PublishedYear)
+			}), true),
+		Availability: facetByValue(count(func(f *repository.BookFilter) { f.Available = nil },
+			func(b models.BookSummary) string {
+				if b.AvailableCopies > 0 {
+					return "available"
+				}
+				return "unavailable"
+			}), false),
+	}, nil
+}
+
+// facetByCount は件数の多い順（同数は値の順）に並べ、limit 件（0なら全件）に切り詰める
+func facetByCount(counts map[string]int, limit int) []repository.FacetCount {
+	facets := facetByValue(counts, false)
+	sort.SliceStable(facets, func(i, j int) bool { return facets[i].Count > facets[j].Count })
+	if limit > 0 && len(facets) > limit {
+		facets = facets[:limit]
+	}
+	return facets
+}
+
+// facetByValue は値の順に並べる。年は桁数が揃っているため文字列の比較で順序が決まる。
+func facetByValue(counts map[string]int, desc bool) []repository.FacetCount {
+	facets := make([]repository.FacetCount, 0, len(counts))
+	for v, n := range counts {
+		facets = append(facets, repository.FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if desc {
+			return facets[i].Value > facets[j].Value
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
+
+func (r bookRepo) RebuildSearchIndex(ctx context.Context) (int, error) {
+	// 検索時に索引語を作るため、事前の索引は不要
+	return 0, nil
+}
+
+func (r bookRepo) Get(ctx context.Context, id uuid.UUID) (*models.Book, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	b, ok := r.db.data.books[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	b = cloneBook(b)
+	return &b, nil
+}
+
+func (r bookRepo) FindByCodes(ctx context.Context, keys []string) ([]models.Book, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var books []models.Book
+	for _, b := range r.db.data.books {
+		for _, code := range []string{b.ISBN, b.JAN, b.EAN13} {
+			if code != "" && contains(keys, codes.Clean(code)) {
+				books = append(books, cloneBook(b))
+				break
+			}
+		}
+	}
+	sort.Slice(books, func(i, j int) bool {
+		if !books[i].CreatedAt.Equal(books[j].CreatedAt) {
+			return books[i].CreatedAt.Before(books[j].CreatedAt)
+		}
+		return books[i].ID.String() < books[j].ID.String()
+	})
+	return books, nil
+}
+
+func (r bookRepo) Create(ctx context.Context, book *models.Book) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.books[book.ID]; ok {
+		return repository.ErrConflict
+	}
+	r.db.data.books[book.ID] = cloneBook(*book)
+	return nil
+}
+
+func (r bookRepo) Update(ctx context.Context, book *models.Book) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	old, ok := r.db.data.books[book.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	updated := cloneBook(*book)
+	updated.ImagePath = old.ImagePath
+	updated.WithdrawnAt = old.WithdrawnAt
+	updated.WithdrawalReason = old.WithdrawalReason
+	updated.CreatedAt = old.CreatedAt
+	r.db.data.books[book.ID] = updated
+	return nil
+}
+
+func (r bookRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.books[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.db.data.books, id)
+	delete(r.db.data.theses, id)
+	for aid, a := range r.db.data.attachments {
+		if a.BookID == id {
+			r.db.removeAttachment(aid)
+		}
+	}
+	r.db.clearImportedBook(id)
+	return nil
+}
+
+func (r bookRepo) SetImagePath(ctx context.Context, id uuid.UUID, imagePath string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.data.books[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	b.ImagePath = imagePath
+	b.UpdatedAt = time.Now()
+	r.db.data.books[id] = b
+	return nil
+}
+
+func (r bookRepo) SetWithdrawn(ctx context.Context, id uuid.UUID, withdrawnAt *time.Time, reason string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.data.books[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if withdrawnAt != nil {
+		at := *withdrawnAt
+		withdrawnAt = &at
+	}
+	b.WithdrawnAt = withdrawnAt
+	b.WithdrawalReason = reason
+	b.UpdatedAt = time.Now()
+	r.db.data.books[id] = b
+	return nil
+}
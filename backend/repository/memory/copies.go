@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type copyRepo struct{ db *db }
+
+// sortedCopies は作成順に並べたコピーを返す（呼び出し側でロックを取得すること）
+func (r copyRepo) sortedCopies() []models.BookCopy {
+	copies := make([]models.BookCopy, 0, len(r.db.data.copies))
+	for _, bc := range r.db.data.copies {
+		copies = append(copies, bc)
+	}
+	sort.Slice(copies, func(i, j int) bool { return copies[i].CreatedAt.Before(copies[j].CreatedAt) })
+	return copies
+}
+
+func (r copyRepo) find(match func(models.BookCopy) bool) (*models.BookCopy, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, bc := range r.sortedCopies() {
+		if match(bc) {
+			return &bc, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r copyRepo) Create(ctx context.Context, bc *models.BookCopy) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.books[bc.BookID]; !ok {
+		return repository.ErrNotFound
+	}
+	r.db.data.copies[bc.ID] = *bc
+	return nil
+}
+
+func (r copyRepo) Get(ctx context.Context, id uuid.UUID) (*models.BookCopy, error) {
+	return r.find(func(bc models.BookCopy) bool { return bc.ID == id })
+}
+
+func (r copyRepo) ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.BookCopy, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var copies []models.BookCopy
+	for _, bc := range r.sortedCopies() {
+		if bc.BookID == bookID {
+			copies = append(copies, bc)
+		}
+	}
+	return copies, nil
+}
+
+func (r copyRepo) FindByBarcode(ctx context.Context, barcode string) (*models.BookCopy, error) {
+	return r.find(func(bc models.BookCopy) bool { return bc.Barcode == barcode })
+}
+
+func (r copyRepo) FindAvailableByBarcode(ctx context.Context, barcode string) (*models.BookCopy, error) {
+	return r.find(func(bc models.BookCopy) bool { return bc.Barcode == barcode && bc.IsAvailable })
+}
+
+func (r copyRepo) FindAvailableByBook(ctx context.Context, bookID uuid.UUID) (*models.BookCopy, error) {
+	return r.find(func(bc models.BookCopy) bool { return bc.BookID == bookID && bc.IsAvailable })
+}
+
+func (r copyRepo) CountAvailableByBook(ctx context.Context, bookID uuid.UUID) (int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	n := 0
+	for _, bc := range r.db.data.copies {
+		if bc.BookID == bookID && bc.IsAvailable {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r copyRepo) SetAvailable(ctx context.Context, id uuid.UUID, available bool) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	bc, ok := r.db.data.copies[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	bc.IsAvailable = available
+	bc.UpdatedAt = time.Now()
+	r.db.data.copies[id] = bc
+	return nil
+}
+
+func (r copyRepo) SetBarcodeByBook(ctx context.Context, bookID uuid.UUID, barcode string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for id, bc := range r.db.data.copies {
+		if bc.BookID == bookID {
+			bc.Barcode = barcode
+			bc.UpdatedAt = time.Now()
+			r.db.data.copies[id] = bc
+		}
+	}
+	return nil
+}
+
+func (r copyRepo) DeleteAvailable(ctx context.Context, bookID uuid.UUID, n int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, bc := range r.sortedCopies() {
+		if n == 0 {
+			break
+		}
+		if bc.BookID == bookID && bc.IsAvailable {
+			delete(r.db.data.copies, bc.ID)
+			r.db.clearBarcodeCopy(bc.ID)
+			n--
+		}
+	}
+	return nil
+}
+
+func (r copyRepo) DeleteByBook(ctx context.Context, bookID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for id, bc := range r.db.data.copies {
+		if bc.BookID == bookID {
+			delete(r.db.data.copies, id)
+			r.db.clearBarcodeCopy(id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type coverJobRepo struct{ db *db }
+
+func (r coverJobRepo) Create(ctx context.Context, job *models.CoverJob) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.coverJobs[job.ID]; ok {
+		return repository.ErrConflict
+	}
+	r.db.data.coverJobs[job.ID] = *job
+	return nil
+}
+
+func (r coverJobRepo) Get(ctx context.Context, id uuid.UUID) (*models.CoverJob, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	j, ok := r.db.data.coverJobs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &j, nil
+}
+
+// sorted は作成順（新しい順の場合は desc）に並べたジョブを返す（呼び出し側でロックを取得すること）
+func (r coverJobRepo) sorted(desc bool) []models.CoverJob {
+	jobs := make([]models.CoverJob, 0, len(r.db.data.coverJobs))
+	for _, j := range r.db.data.coverJobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt) == desc
+		}
+		return jobs[i].ID.String() < jobs[j].ID.String()
+	})
+	return jobs
+}
+
+func (r coverJobRepo) List(ctx context.Context, page repository.Page) ([]models.CoverJob, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	jobs := r.sorted(true)
+	return paginate(jobs, page), len(jobs), nil
+}
+
+func (r coverJobRepo) ListUnfinished(ctx context.Context) ([]models.CoverJob, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var jobs []models.CoverJob
+	for _, j := range r.sorted(false) {
+		if j.Status == models.ImportStatusQueued || j.Status == models.ImportStatusRunning {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+func (r coverJobRepo) Update(ctx context.Context, job *models.CoverJob) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	j, ok := r.db.data.coverJobs[job.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	j.Status = job.Status
+	j.TotalBooks = job.TotalBooks
+	j.ProcessedBooks = job.ProcessedBooks
+	j.ImportedBooks = job.ImportedBooks
+	j.NotFoundBooks = job.NotFoundBooks
+	j.ErrorBooks = job.ErrorBooks
+	j.Error = job.Error
+	j.UpdatedAt = job.UpdatedAt
+	j.StartedAt = job.StartedAt
+	j.FinishedAt = job.FinishedAt
+	r.db.data.coverJobs[job.ID] = j
+	return nil
+}
+
+func (r coverJobRepo) AddResult(ctx context.Context, result *models.CoverResult) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.coverJobs[result.JobID]; !ok {
+		return repository.ErrNotFound
+	}
+	rows := r.db.data.coverRows[result.JobID]
+	i := sort.Search(len(rows), func(i int) bool { return rows[i].Seq >= result.Seq })
+	if i < len(rows) && rows[i].Seq == result.Seq {
+		return repository.ErrConflict
+	}
+	rows = append(rows, models.CoverResult{})
+	copy(rows[i+1:], rows[i:])
+	rows[i] = *result
+	r.db.data.coverRows[result.JobID] = rows
+	return nil
+}
+
+func (r coverJobRepo) Results(ctx context.Context, filter repository.CoverResultFilter) ([]models.CoverResult, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var results []models.CoverResult
+	for _, res := range r.db.data.coverRows[filter.JobID] {
+		if filter.Status == "" || res.Status == filter.Status {
+			results = append(results, res)
+		}
+	}
+	return paginate(results, filter.Page), len(results), nil
+}
@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type importJobRepo struct{ db *db }
+
+func cloneImportJob(j models.ImportJob) models.ImportJob {
+	mapping := make(map[string]string, len(j.Mapping))
+	for k, v := range j.Mapping {
+		mapping[k] = v
+	}
+	j.Mapping = mapping
+	return j
+}
+
+func (r importJobRepo) Create(ctx context.Context, job *models.ImportJob) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.importJobs[job.ID]; ok {
+		return repository.ErrConflict
+	}
+	r.db.data.importJobs[job.ID] = cloneImportJob(*job)
+	return nil
+}
+
+func (r importJobRepo) Get(ctx context.Context, id uuid.UUID) (*models.ImportJob, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	j, ok := r.db.data.importJobs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	j = cloneImportJob(j)
+	return &j, nil
+}
+
+// sorted は作成順（新しい順の場合は desc）に並べたジョブを返す（呼び出し側でロックを取得すること）
+func (r importJobRepo) sorted(desc bool) []models.ImportJob {
+	jobs := make([]models.ImportJob, 0, len(r.db.data.importJobs))
+	for _, j := range r.db.data.importJobs {
+		jobs = append(jobs, cloneImportJob(j))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt) == desc
+		}
+		return jobs[i].ID.String() < jobs[j].ID.String()
+	})
+	return jobs
+}
+
+func (r importJobRepo) List(ctx context.Context, page repository.Page) ([]models.ImportJob, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	jobs := r.sorted(true)
+	return paginate(jobs, page), len(jobs), nil
+}
+
+func (r importJobRepo) ListUnfinished(ctx context.Context) ([]models.ImportJob, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var jobs []models.ImportJob
+	for _, j := range r.sorted(false) {
+		if j.Status == models.ImportStatusQueued || j.Status == models.ImportStatusRunning {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+func (r importJobRepo) Update(ctx context.Context, job *models.ImportJob) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	j, ok := r.db.data.importJobs[job.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	j.Status = job.Status
+	j.TotalRows = job.TotalRows
+	j.ProcessedRows = job.ProcessedRows
+	j.ImportedRows = job.ImportedRows
+	j.DuplicateRows = job.DuplicateRows
+	j.ErrorRows = job.ErrorRows
+	j.Error = job.Error
+	j.UpdatedAt = job.UpdatedAt
+	j.StartedAt = job.StartedAt
+	j.FinishedAt = job.FinishedAt
+	r.db.data.importJobs[job.ID] = j
+	return nil
+}
+
+func (r importJobRepo) AddRow(ctx context.Context, row *models.ImportRow) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.importJobs[row.JobID]; !ok {
+		return repository.ErrNotFound
+	}
+	rows := r.db.data.importRows[row.JobID]
+	i := sort.Search(len(rows), func(i int) bool { return rows[i].RowNumber >= row.RowNumber })
+	if i < len(rows) && rows[i].RowNumber == row.RowNumber {
+		return repository.ErrConflict
+	}
+	stored := *row
+	stored.Messages = append([]string{}, row.Messages...)
+	rows = append(rows, models.ImportRow{})
+	copy(rows[i+1:], rows[i:])
+	rows[i] = stored
+	r.db.data.importRows[row.JobID] = rows
+	return nil
+}
+
+func (r importJobRepo) Rows(ctx context.Context, filter repository.ImportRowFilter) ([]models.ImportRow, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var rows []models.ImportRow
+	for _, row := range r.db.data.importRows[filter.JobID] {
+		if filter.Status == "" || row.Status == filter.Status {
+			row.Messages = append([]string{}, row.Messages...)
+			rows = append(rows, row)
+		}
+	}
+	return paginate(rows, filter.Page), len(rows), nil
+}
+
+// clearImportedBook は削除した書籍を取り込み・表紙画像の取得の結果と生成したバーコードから外す（呼び出し側でロックを取得すること）
+func (db *db) clearImportedBook(id uuid.UUID) {
+	for jobID, rows := range db.data.importRows {
+		for i, row := range rows {
+			if row.BookID != nil && *row.BookID == id {
+				rows[i].BookID = nil
+			}
+			if row.DuplicateOf != nil && *row.DuplicateOf == id {
+				rows[i].DuplicateOf = nil
+			}
+		}
+		db.data.importRows[jobID] = rows
+	}
+	for jobID, rows := range db.data.coverRows {
+		for i, row := range rows {
+			if row.BookID != nil && *row.BookID == id {
+				rows[i].BookID = nil
+			}
+		}
+		db.data.coverRows[jobID] = rows
+	}
+	for barcodeID, b := range db.data.barcodes {
+		if b.BookID != nil && *b.BookID == id {
+			b.BookID = nil
+			db.data.barcodes[barcodeID] = b
+		}
+	}
+}
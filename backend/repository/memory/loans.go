@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type loanRepo struct{ db *db }
+
+// hydrate は PostgreSQL 実装の JOIN と同じ表示用フィールドを埋める（呼び出し側でロックを取得すること）
+func (r loanRepo) hydrate(br models.BorrowRecord) (models.BorrowRecord, bool) {
+	bc, ok := r.db.data.copies[br.BookCopyID]
+	if !ok {
+		return br, false
+	}
+	b, ok := r.db.data.books[bc.BookID]
+	if !ok {
+		return br, false
+	}
+	u, ok := r.db.data.users[br.UserID]
+	if !ok {
+		return br, false
+	}
+	br.Book = models.Book{ID: b.ID, Title: b.Title, Author: b.Author, Type: b.Type}
+	br.BookCopy = models.BookCopy{ID: bc.ID, BookID: bc.BookID, SerialNumber: bc.SerialNumber}
+	br.User = models.User{ID: u.ID, StudentID: u.StudentID, Name: u.Name}
+	return br, true
+}
+
+func (r loanRepo) list(match func(models.BorrowRecord) bool) []models.BorrowRecord {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var records []models.BorrowRecord
+	for _, br := range r.db.data.loans {
+		br, ok := r.hydrate(br)
+		if ok && match(br) {
+			records = append(records, br)
+		}
+	}
+	sortLoans(records)
+	return records
+}
+
+func (r loanRepo) Create(ctx context.Context, br *models.BorrowRecord) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.users[br.UserID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.db.data.copies[br.BookCopyID]; !ok {
+		return repository.ErrNotFound
+	}
+	r.db.data.loans[br.ID] = *br
+	return nil
+}
+
+func (r loanRepo) Get(ctx context.Context, id uuid.UUID) (*models.BorrowRecord, error) {
+	records := r.list(func(br models.BorrowRecord) bool { return br.ID == id })
+	if len(records) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &records[0], nil
+}
+
+func (r loanRepo) FindOpen(ctx context.Context, copyID, userID uuid.UUID) (*models.BorrowRecord, error) {
+	records := r.list(func(br models.BorrowRecord) bool {
+		return br.BookCopyID == copyID && br.UserID == userID && br.Status == "borrowed"
+	})
+	if len(records) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &records[0], nil
+}
+
+// loanLess は repository.LoanSortFields ごとの比較関数
+var loanLess = map[string]func(a, b models.BorrowRecord) bool{
+	"borrowed_at": func(a, b models.BorrowRecord) bool { return a.BorrowedAt.Before(b.BorrowedAt) },
+	"due_date":    func(a, b models.BorrowRecord) bool { return a.DueDate.Before(b.DueDate) },
+	"returned_at": func(a, b models.BorrowRecord) bool {
+		// PostgreSQL の ASC と同様に未返却（NULL）を最後にする
+		if a.ReturnedAt == nil || b.ReturnedAt == nil {
+			return a.ReturnedAt != nil && b.ReturnedAt == nil
+		}
+		return a.ReturnedAt.Before(*b.ReturnedAt)
+	},
+	"status":     func(a, b models.BorrowRecord) bool { return a.Status < b.Status },
+	"user_name":  func(a, b models.BorrowRecord) bool { return a.User.Name < b.User.Name },
+	"book_title": func(a, b models.BorrowRecord) bool { return a.Book.Title < b.Book.Title },
+}
+
+func (r loanRepo) List(ctx context.Context, filter repository.LoanFilter) ([]models.BorrowRecord, int, error) {
+	now := time.Now()
+	records := r.list(func(br models.BorrowRecord) bool {
+		switch {
+		case filter.UserID != uuid.Nil && br.UserID != filter.UserID,
+			filter.BookID != uuid.Nil && br.Book.ID != filter.BookID,
+			filter.Status != "" && br.Status != filter.Status,
+			!filter.BorrowedFrom.IsZero() && br.BorrowedAt.Before(filter.BorrowedFrom),
+			!filter.BorrowedTo.IsZero() && !br.BorrowedAt.Before(filter.BorrowedTo),
+			filter.Overdue && (br.ReturnedAt != nil || !br.DueDate.Before(now)):
+			return false
+		}
+		return true
+	})
+
+	sort := filter.Sort
+	if sort.Field == "" {
+		sort = repository.Sort{Field: "borrowed_at", Desc: true}
+	}
+	if err := sortBy(records, sort, "borrowed_at", loanLess); err != nil {
+		return nil, 0, err
+	}
+	return paginate(records, filter.Page), len(records), nil
+}
+
+func (r loanRepo) ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.BorrowRecord, error) {
+	return r.list(func(br models.BorrowRecord) bool { return br.Book.ID == bookID }), nil
+}
+
+func (r loanRepo) MarkReturned(ctx context.Context, id uuid.UUID, returnedAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	br, ok := r.db.data.loans[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	br.ReturnedAt = &returnedAt
+	br.Status = "returned"
+	r.db.data.loans[id] = br
+	return nil
+}
+
+func (r loanRepo) UpdateDueDate(ctx context.Context, id uuid.UUID, dueDate time.Time, renewCount int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	br, ok := r.db.data.loans[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	br.DueDate = dueDate
+	br.RenewCount = renewCount
+	r.db.data.loans[id] = br
+	return nil
+}
+
+func (r loanRepo) CountOpenByBook(ctx context.Context, bookID uuid.UUID) (int, error) {
+	records := r.list(func(br models.BorrowRecord) bool {
+		return br.Book.ID == bookID && br.ReturnedAt == nil
+	})
+	return len(records), nil
+}
+
+func (r loanRepo) CountOpenByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	n := 0
+	for _, br := range r.db.data.loans {
+		if br.UserID == userID && br.ReturnedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r loanRepo) DeleteReturnedByBook(ctx context.Context, bookID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for id, br := range r.db.data.loans {
+		bc, ok := r.db.data.copies[br.BookCopyID]
+		if ok && bc.BookID == bookID && br.ReturnedAt != nil {
+			delete(r.db.data.loans, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"context"
+
+	"lablib/models"
+	"lablib/repository"
+)
+
+type metadataCacheRepo struct{ db *db }
+
+func (r metadataCacheRepo) Get(ctx context.Context, isbn, provider string) (*models.MetadataCacheEntry, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	e, ok := r.db.data.metadata[metadataKey{isbn, provider}]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	e.Data = append([]byte(nil), e.Data...)
+	return &e, nil
+}
+
+func (r metadataCacheRepo) Put(ctx context.Context, entry *models.MetadataCacheEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e := *entry
+	e.Data = append([]byte(nil), entry.Data...)
+	r.db.data.metadata[metadataKey{e.ISBN, e.Provider}] = e
+	return nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+type rankingRepo struct{ db *db }
+
+func (r rankingRepo) Increment(ctx context.Context, month string, bookID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key := rankingKey{month: month, bookID: bookID}
+	mr, ok := r.db.data.rankings[key]
+	if !ok {
+		mr = models.MonthlyRanking{ID: uuid.New(), Month: month, BookID: bookID}
+	}
+	mr.BorrowCount++
+	r.db.data.rankings[key] = mr
+	return nil
+}
+
+func (r rankingRepo) Monthly(ctx context.Context, month string, limit int) ([]models.MonthlyRanking, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var rankings []models.MonthlyRanking
+	for key, mr := range r.db.data.rankings {
+		b, ok := r.db.data.books[key.bookID]
+		if key.month != month || !ok {
+			continue
+		}
+		mr.Book = models.Book{ID: b.ID, Title: b.Title, Author: b.Author, Type: b.Type}
+		rankings = append(rankings, mr)
+	}
+	sort.SliceStable(rankings, func(i, j int) bool { return rankings[i].BorrowCount > rankings[j].BorrowCount })
+	if len(rankings) > limit {
+		rankings = rankings[:limit]
+	}
+	return rankings, nil
+}
+
+func (r rankingRepo) AllTime(ctx context.Context, limit int) ([]models.AllTimeRanking, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	totals := map[uuid.UUID]int{}
+	for key, mr := range r.db.data.rankings {
+		totals[key.bookID] += mr.BorrowCount
+	}
+
+	var rankings []models.AllTimeRanking
+	for bookID, total := range totals {
+		b, ok := r.db.data.books[bookID]
+		if !ok || total == 0 {
+			continue
+		}
+		rankings = append(rankings, models.AllTimeRanking{
+			BookID:      bookID,
+			BorrowCount: total,
+			Book:        models.Book{ID: b.ID, Title: b.Title, Author: b.Author, Type: b.Type},
+		})
+	}
+	sort.SliceStable(rankings, func(i, j int) bool { return rankings[i].BorrowCount > rankings[j].BorrowCount })
+	if len(rankings) > limit {
+		rankings = rankings[:limit]
+	}
+	return rankings, nil
+}
+
+func (r rankingRepo) DeleteByBook(ctx context.Context, bookID uuid.UUID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for key := range r.db.data.rankings {
+		if key.bookID == bookID {
+			delete(r.db.data.rankings, key)
+		}
+	}
+	return nil
+}
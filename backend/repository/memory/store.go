@@ -0,0 +1,204 @@
+// Package memory は repository インターフェースのインメモリ実装を提供する。
+// PostgreSQL なしでハンドラーのテストや開発用サーバーを動かすために使う。
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type rankingKey struct {
+	month  string
+	bookID uuid.UUID
+}
+
+type metadataKey struct {
+	isbn     string
+	provider string
+}
+
+type data struct {
+	books    map[uuid.UUID]models.Book
+	copies   map[uuid.UUID]models.BookCopy
+	loans    map[uuid.UUID]models.BorrowRecord
+	users    map[uuid.UUID]models.User
+	rankings map[rankingKey]models.MonthlyRanking
+	theses   map[uuid.UUID]models.Thesis // Book は保存せず、取得時に books から埋める
+	// attachments の Downloads は保存せず、取得時に downloads から数える
+	attachments map[uuid.UUID]models.Attachment
+	downloads   []models.AttachmentDownload
+	metadata    map[metadataKey]models.MetadataCacheEntry
+	importJobs  map[uuid.UUID]models.ImportJob
+	importRows  map[uuid.UUID][]models.ImportRow // ジョブごとの行の結果（行番号順）
+	coverJobs   map[uuid.UUID]models.CoverJob
+	coverRows   map[uuid.UUID][]models.CoverResult // ジョブごとの書籍の結果（処理した順）
+	invites     map[string]models.UserInvite       // トークンのハッシュ → 招待
+	barcodes    map[uuid.UUID]models.Barcode
+}
+
+func newData() data {
+	return data{
+		books:    map[uuid.UUID]models.Book{},
+		copies:   map[uuid.UUID]models.BookCopy{},
+		loans:    map[uuid.UUID]models.BorrowRecord{},
+		users:    map[uuid.UUID]models.User{},
+		rankings: map[rankingKey]models.MonthlyRanking{},
+		theses:   map[uuid.UUID]models.Thesis{},
+
+		attachments: map[uuid.UUID]models.Attachment{},
+		metadata:    map[metadataKey]models.MetadataCacheEntry{},
+		importJobs:  map[uuid.UUID]models.ImportJob{},
+		importRows:  map[uuid.UUID][]models.ImportRow{},
+		coverJobs:   map[uuid.UUID]models.CoverJob{},
+		coverRows:   map[uuid.UUID][]models.CoverResult{},
+		invites:     map[string]models.UserInvite{},
+		barcodes:    map[uuid.UUID]models.Barcode{},
+	}
+}
+
+func (d data) clone() data {
+	c := newData()
+	for k, v := range d.books {
+		c.books[k] = v
+	}
+	for k, v := range d.copies {
+		c.copies[k] = v
+	}
+	for k, v := range d.loans {
+		c.loans[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.rankings {
+		c.rankings[k] = v
+	}
+	for k, v := range d.theses {
+		c.theses[k] = v
+	}
+	for k, v := range d.attachments {
+		c.attachments[k] = v
+	}
+	c.downloads = append(c.downloads, d.downloads...)
+	for k, v := range d.metadata {
+		c.metadata[k] = v
+	}
+	for k, v := range d.importJobs {
+		c.importJobs[k] = v
+	}
+	for k, v := range d.importRows {
+		c.importRows[k] = append([]models.ImportRow(nil), v...)
+	}
+	for k, v := range d.coverJobs {
+		c.coverJobs[k] = v
+	}
+	for k, v := range d.coverRows {
+		c.coverRows[k] = append([]models.CoverResult(nil), v...)
+	}
+	for k, v := range d.invites {
+		c.invites[k] = v
+	}
+	for k, v := range d.barcodes {
+		c.barcodes[k] = v
+	}
+	return c
+}
+
+type db struct {
+	mu   sync.RWMutex
+	txMu sync.Mutex
+	data data
+}
+
+// Store - メモリ上のマップをバックエンドとする repository.Store。
+// トランザクションはスナップショットによるロールバックで表現し、同時に1つだけ実行される。
+type Store struct {
+	db   *db
+	inTx bool
+}
+
+// NewStore は空の Store を返す
+func NewStore() *Store {
+	return &Store{db: &db{data: newData()}}
+}
+
+func (s *Store) Books() repository.BookRepository             { return bookRepo{s.db} }
+func (s *Store) Copies() repository.CopyRepository            { return copyRepo{s.db} }
+func (s *Store) Loans() repository.LoanRepository             { return loanRepo{s.db} }
+func (s *Store) Users() repository.UserRepository             { return userRepo{s.db} }
+func (s *Store) Rankings() repository.RankingRepository       { return rankingRepo{s.db} }
+func (s *Store) Theses() repository.ThesisRepository          { return thesisRepo{s.db} }
+func (s *Store) Attachments() repository.AttachmentRepository { return attachmentRepo{s.db} }
+func (s *Store) MetadataCache() repository.MetadataCacheRepository {
+	return metadataCacheRepo{s.db}
+}
+func (s *Store) ImportJobs() repository.ImportJobRepository { return importJobRepo{s.db} }
+func (s *Store) CoverJobs() repository.CoverJobRepository   { return coverJobRepo{s.db} }
+func (s *Store) Barcodes() repository.BarcodeRepository     { return barcodeRepo{s.db} }
+
+// WithTx は fn 実行前の状態を保存し、fn がエラーを返した場合に復元する
+func (s *Store) WithTx(ctx context.Context, fn func(tx repository.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.db.txMu.Lock()
+	defer s.db.txMu.Unlock()
+
+	s.db.mu.RLock()
+	snapshot := s.db.data.clone()
+	s.db.mu.RUnlock()
+
+	if err := fn(&Store{db: s.db, inTx: true}); err != nil {
+		s.db.mu.Lock()
+		s.db.data = snapshot
+		s.db.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// sortLoans は貸出日時の新しい順に並べ替える
+func sortLoans(records []models.BorrowRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].BorrowedAt.After(records[j].BorrowedAt)
+	})
+}
+
+// sortBy は s に従って items を並べ替える。less は許可されたフィールドごとの比較関数。
+func sortBy[T any](items []T, s repository.Sort, def string, less map[string]func(a, b T) bool) error {
+	field := s.Field
+	if field == "" {
+		field = def
+	}
+	cmp, ok := less[field]
+	if !ok {
+		return fmt.Errorf("unsupported sort field: %q", s.Field)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if s.Desc {
+			return cmp(items[j], items[i])
+		}
+		return cmp(items[i], items[j])
+	})
+	return nil
+}
+
+// paginate は items から p の範囲を切り出す
+func paginate[T any](items []T, p repository.Page) []T {
+	if p.Offset >= len(items) {
+		return nil
+	}
+	items = items[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+	"lablib/search"
+
+	"github.com/google/uuid"
+)
+
+type thesisRepo struct{ db *db }
+
+// thesisLess は repository.ThesisSortFields ごとの比較関数
+var thesisLess = map[string]func(a, b models.Thesis) bool{
+	"academic_year": func(a, b models.Thesis) bool { return a.AcademicYear < b.AcademicYear },
+	"title":         func(a, b models.Thesis) bool { return a.Book.Title < b.Book.Title },
+	"author":        func(a, b models.Thesis) bool { return a.Book.Author < b.Book.Author },
+	"student_id":    func(a, b models.Thesis) bool { return a.StudentID < b.StudentID },
+	"created_at":    func(a, b models.Thesis) bool { return a.CreatedAt.Before(b.CreatedAt) },
+}
+
+// list は書籍を埋めた論文のうち match に一致するものを返す
+func (r thesisRepo) list(match func(models.Thesis) bool) []models.Thesis {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var theses []models.Thesis
+	for _, t := range r.db.data.theses {
+		b, ok := r.db.data.books[t.BookID]
+		if !ok {
+			continue
+		}
+		t.Book = cloneBook(b)
+		if match(t) {
+			theses = append(theses, t)
+		}
+	}
+	// マップの順序に依存しないようタイトル順を基準にする
+	sort.Slice(theses, func(i, j int) bool {
+		if theses[i].Book.Title != theses[j].Book.Title {
+			return theses[i].Book.Title < theses[j].Book.Title
+		}
+		return theses[i].BookID.String() < theses[j].BookID.String()
+	})
+	return theses
+}
+
+func (r thesisRepo) List(ctx context.Context, filter repository.ThesisFilter) ([]models.Thesis, int, error) {
+	query := search.Parse(filter.Query)
+	theses := r.list(func(t models.Thesis) bool {
+		switch {
+		case t.Book.WithdrawnAt != nil,
+			filter.AcademicYear != 0 && t.AcademicYear != filter.AcademicYear,
+			filter.Degree != "" && t.Degree != filter.Degree,
+			filter.Supervisor != "" && !contains(t.Supervisors(), filter.Supervisor):
+			return false
+		}
+		return query.Match(repository.SearchDocument(t.Book))
+	})
+
+	s := filter.Sort
+	if s.Field == "" {
+		s = repository.Sort{Field: "academic_year", Desc: true}
+	}
+	if err := sortBy(theses, s, "academic_year", thesisLess); err != nil {
+		return nil, 0, err
+	}
+	return paginate(theses, filter.Page), len(theses), nil
+}
+
+func (r thesisRepo) Get(ctx context.Context, bookID uuid.UUID) (*models.Thesis, error) {
+	theses := r.list(func(t models.Thesis) bool { return t.BookID == bookID })
+	if len(theses) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &theses[0], nil
+}
+
+func (r thesisRepo) FindByStudent(ctx context.Context, academicYear int, studentID string) (*models.Thesis, error) {
+	theses := r.list(func(t models.Thesis) bool {
+		return t.AcademicYear == academicYear && t.StudentID == studentID
+	})
+	if len(theses) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &theses[0], nil
+}
+
+// duplicate は同じ年度・学籍番号の別の論文があるかを返す（呼び出し側でロックを取得すること）
+func (r thesisRepo) duplicate(t *models.Thesis) bool {
+	for _, other := range r.db.data.theses {
+		if other.BookID != t.BookID && other.AcademicYear == t.AcademicYear && other.StudentID == t.StudentID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r thesisRepo) Create(ctx context.Context, thesis *models.Thesis) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.books[thesis.BookID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.db.data.theses[thesis.BookID]; ok || r.duplicate(thesis) {
+		return repository.ErrConflict
+	}
+	t := *thesis
+	t.Book = models.Book{}
+	r.db.data.theses[t.BookID] = t
+	return nil
+}
+
+func (r thesisRepo) Update(ctx context.Context, thesis *models.Thesis) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	old, ok := r.db.data.theses[thesis.BookID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if r.duplicate(thesis) {
+		return repository.ErrConflict
+	}
+	old.AcademicYear = thesis.AcademicYear
+	old.Degree = thesis.Degree
+	old.StudentID = thesis.StudentID
+	old.UpdatedAt = thesis.UpdatedAt
+	r.db.data.theses[thesis.BookID] = old
+	return nil
+}
+
+func (r thesisRepo) SetPDFPath(ctx context.Context, bookID uuid.UUID, pdfPath string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.data.theses[bookID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	t.PDFPath = pdfPath
+	t.UpdatedAt = time.Now()
+	r.db.data.theses[bookID] = t
+	return nil
+}
+
+func (r thesisRepo) Index(ctx context.Context) (*repository.ThesisIndex, error) {
+	years := map[string]int{}
+	supervisors := map[string]int{}
+	for _, t := range r.list(func(t models.Thesis) bool { return t.Book.WithdrawnAt == nil }) {
+		years[strconv.Itoa(t.AcademicYear)]++
+		seen := map[string]bool{}
+		for _, name := range t.Supervisors() {
+			if !seen[name] {
+				seen[name] = true
+				supervisors[name]++
+			}
+		}
+	}
+	return &repository.ThesisIndex{
+		Years:       facetByValue(years, true),
+		Supervisors: facetByCount(supervisors, 0),
+	}, nil
+}
@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type userRepo struct{ db *db }
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (r userRepo) Create(ctx context.Context, u *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, existing := range r.db.data.users {
+		if existing.StudentID == u.StudentID {
+			return repository.ErrConflict
+		}
+	}
+	u.Notifications = models.DefaultNotificationPreferences
+	r.db.data.users[u.ID] = *u
+	return nil
+}
+
+func (r userRepo) Get(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	u, ok := r.db.data.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r userRepo) GetByStudentID(ctx context.Context, studentID string) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, u := range r.db.data.users {
+		if u.StudentID == studentID {
+			return &u, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// userLess は repository.UserSortFields ごとの比較関数
+var userLess = map[string]func(a, b models.User) bool{
+	"student_id": func(a, b models.User) bool { return a.StudentID < b.StudentID },
+	"name":       func(a, b models.User) bool { return a.Name < b.Name },
+	"role":       func(a, b models.User) bool { return a.Role < b.Role },
+	"created_at": func(a, b models.User) bool { return a.CreatedAt.Before(b.CreatedAt) },
+}
+
+func (r userRepo) List(ctx context.Context, filter repository.UserFilter) ([]models.User, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.db.data.users))
+	for _, u := range r.db.data.users {
+		if filter.Query != "" && !containsFold(u.StudentID, filter.Query) && !containsFold(u.Name, filter.Query) {
+			continue
+		}
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.Status != "" && u.Status != filter.Status {
+			continue
+		}
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.String() < users[j].ID.String() })
+	if err := sortBy(users, filter.Sort, "student_id", userLess); err != nil {
+		return nil, 0, err
+	}
+	return paginate(users, filter.Page), len(users), nil
+}
+
+func (r userRepo) Update(ctx context.Context, u *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.data.users[u.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for _, other := range r.db.data.users {
+		if other.ID != u.ID && other.StudentID == u.StudentID {
+			return repository.ErrConflict
+		}
+	}
+	existing.StudentID = u.StudentID
+	existing.Name = u.Name
+	existing.Email = u.Email
+	existing.Role = u.Role
+	existing.Status = u.Status
+	existing.DeactivatedAt = u.DeactivatedAt
+	existing.UpdatedAt = u.UpdatedAt
+	existing.Notifications = u.Notifications
+	r.db.data.users[u.ID] = existing
+	return nil
+}
+
+func (r userRepo) SetPassword(ctx context.Context, id uuid.UUID, password string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.data.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.Password = password
+	u.UpdatedAt = time.Now()
+	r.db.data.users[id] = u
+	return nil
+}
+
+func (r userRepo) Anonymize(ctx context.Context, id uuid.UUID, studentID string, by *uuid.UUID, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.data.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for _, existing := range r.db.data.users {
+		if existing.ID != id && existing.StudentID == studentID {
+			return repository.ErrConflict
+		}
+	}
+	u.StudentID = studentID
+	u.Name = ""
+	u.Email = ""
+	u.Password = ""
+	if u.Status == models.UserStatusActive {
+		u.Status = models.UserStatusDeactivated
+	}
+	if u.DeactivatedAt == nil {
+		u.DeactivatedAt = &at
+	}
+	u.AnonymizedAt = &at
+	u.AnonymizedBy = by
+	u.UpdatedAt = at
+	r.db.data.users[id] = u
+
+	for hash, invite := range r.db.data.invites {
+		if invite.UserID == id {
+			delete(r.db.data.invites, hash)
+		}
+	}
+	return nil
+}
+
+func (r userRepo) CreateInvite(ctx context.Context, invite *models.UserInvite) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.users[invite.UserID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.db.data.invites[invite.TokenHash]; ok {
+		return repository.ErrConflict
+	}
+	r.db.data.invites[invite.TokenHash] = *invite
+	return nil
+}
+
+func (r userRepo) GetInvite(ctx context.Context, tokenHash string) (*models.UserInvite, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	invite, ok := r.db.data.invites[tokenHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &invite, nil
+}
+
+func (r userRepo) UseInvite(ctx context.Context, tokenHash string, usedAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	invite, ok := r.db.data.invites[tokenHash]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for hash, other := range r.db.data.invites {
+		if other.UserID == invite.UserID && other.UsedAt == nil {
+			other.UsedAt = &usedAt
+			r.db.data.invites[hash] = other
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type attachmentRepo struct{ q querier }
+
+const attachmentColumns = `a.id, a.book_id, a.filename, a.storage_path, a.content_type, a.size, a.sha256,
+    a.visibility, a.description, a.scan_status, a.uploaded_by, a.created_at, a.updated_at,
+    (SELECT COUNT(*) FROM attachment_downloads d WHERE d.attachment_id = a.id)`
+
+// attachmentDest は attachmentColumns の順序で a に読み取る Scan の引数を返す
+func attachmentDest(a *models.Attachment) []interface{} {
+	return []interface{}{
+		&a.ID, &a.BookID, &a.Filename, &a.StoragePath, &a.ContentType, &a.Size, &a.SHA256,
+		&a.Visibility, &a.Description, &a.ScanStatus, &a.UploadedBy, &a.CreatedAt, &a.UpdatedAt,
+		&a.Downloads,
+	}
+}
+
+func (r attachmentRepo) ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.Attachment, error) {
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT `+attachmentColumns+`
+        FROM book_attachments a
+        WHERE a.book_id = $1
+        ORDER BY a.created_at, a.id
+    `, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(attachmentDest(&a)...); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (r attachmentRepo) Get(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+	var a models.Attachment
+	err := r.q.QueryRowContext(ctx, `
+        SELECT `+attachmentColumns+` FROM book_attachments a WHERE a.id = $1
+    `, id).Scan(attachmentDest(&a)...)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &a, nil
+}
+
+func (r attachmentRepo) Create(ctx context.Context, a *models.Attachment) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO book_attachments (id, book_id, filename, storage_path, content_type, size, sha256,
+            visibility, description, scan_status, uploaded_by, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+    `, a.ID, a.BookID, a.Filename, a.StoragePath, a.ContentType, a.Size, a.SHA256,
+		a.Visibility, a.Description, a.ScanStatus, a.UploadedBy, a.CreatedAt, a.UpdatedAt)
+	return conflict(err)
+}
+
+func (r attachmentRepo) Update(ctx context.Context, a *models.Attachment) error {
+	return affected(r.q.ExecContext(ctx, `
+        UPDATE book_attachments SET filename = $1, visibility = $2, description = $3, updated_at = $4
+        WHERE id = $5
+    `, a.Filename, a.Visibility, a.Description, a.UpdatedAt, a.ID))
+}
+
+func (r attachmentRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return affected(r.q.ExecContext(ctx, "DELETE FROM book_attachments WHERE id = $1", id))
+}
+
+func (r attachmentRepo) RecordDownload(ctx context.Context, d *models.AttachmentDownload) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO attachment_downloads (attachment_id, user_id, downloaded_at) VALUES ($1, $2, $3)
+    `, d.AttachmentID, d.UserID, d.DownloadedAt)
+	return err
+}
+
+func (r attachmentRepo) Stats(ctx context.Context, filter repository.AttachmentStatsFilter) ([]models.AttachmentStats, error) {
+	var c conditions
+	if filter.BookID != uuid.Nil {
+		c.add("a.book_id = ?", filter.BookID)
+	}
+	if !filter.From.IsZero() {
+		c.add("d.downloaded_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		c.add("d.downloaded_at < ?", filter.To)
+	}
+	query := `
+        SELECT ` + attachmentColumns + `,
+            COUNT(*) AS n, COUNT(DISTINCT d.user_id), MAX(d.downloaded_at)
+        FROM attachment_downloads d
+        JOIN book_attachments a ON a.id = d.attachment_id` + c.where() + `
+        GROUP BY a.id
+        ORDER BY n DESC, a.filename` + limitOffset(repository.Page{Limit: filter.Limit})
+
+	rows, err := r.q.QueryContext(ctx, query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.AttachmentStats
+	for rows.Next() {
+		var s models.AttachmentStats
+		var last time.Time
+		dest := append(attachmentDest(&s.Attachment), &s.Downloads, &s.UniqueUsers, &last)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		s.LastDownloaded = &last
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type barcodeRepo struct{ q querier }
+
+const barcodeColumns = `id, code, symbology, book_id, copy_id, filename, storage_path, size, params, created_by, created_at`
+
+// barcodeScanner は barcodeColumns の順序でバーコードを読み取る
+type barcodeScanner struct {
+	barcode models.Barcode
+	params  []byte
+}
+
+func (s *barcodeScanner) dest() []interface{} {
+	b := &s.barcode
+	return []interface{}{&b.ID, &b.Code, &b.Symbology, &b.BookID, &b.CopyID, &b.Filename, &b.StoragePath, &b.Size, &s.params, &b.CreatedBy, &b.CreatedAt}
+}
+
+func (s *barcodeScanner) result() (models.Barcode, error) {
+	if err := json.Unmarshal(s.params, &s.barcode.Params); err != nil {
+		return s.barcode, err
+	}
+	return s.barcode, nil
+}
+
+var barcodeSortColumns = map[string]string{
+	"filename":   "filename",
+	"code":       "code",
+	"created_at": "created_at",
+	"size":       "size",
+}
+
+func (r barcodeRepo) List(ctx context.Context, filter repository.BarcodeFilter) ([]models.Barcode, int, error) {
+	var c conditions
+	if filter.Code != "" {
+		c.add("code LIKE ?", escapeLike(filter.Code)+"%")
+	}
+	if filter.Symbology != "" {
+		c.add("symbology = ?", filter.Symbology)
+	}
+	if filter.BookID != uuid.Nil {
+		c.add("book_id = ?", filter.BookID)
+	}
+	if filter.CopyID != uuid.Nil {
+		c.add("copy_id = ?", filter.CopyID)
+	}
+	if filter.CreatedBy != uuid.Nil {
+		c.add("created_by = ?", filter.CreatedBy)
+	}
+	if filter.Year != "" {
+		c.add("params->>'year' = ?", filter.Year)
+	}
+	if filter.StudentID != "" {
+		c.add("params->>'student_id' = ?", filter.StudentID)
+	}
+	order, err := orderBy(filter.Sort, barcodeSortColumns, "filename", "id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM barcodes`+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, `SELECT `+barcodeColumns+` FROM barcodes`+c.where()+order+limitOffset(filter.Page), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var barcodes []models.Barcode
+	for rows.Next() {
+		var s barcodeScanner
+		if err := rows.Scan(s.dest()...); err != nil {
+			return nil, 0, err
+		}
+		b, err := s.result()
+		if err != nil {
+			return nil, 0, err
+		}
+		barcodes = append(barcodes, b)
+	}
+	return barcodes, total, rows.Err()
+}
+
+func (r barcodeRepo) Get(ctx context.Context, id uuid.UUID) (*models.Barcode, error) {
+	return r.get(ctx, `SELECT `+barcodeColumns+` FROM barcodes WHERE id = $1`, id)
+}
+
+func (r barcodeRepo) FindByCode(ctx context.Context, symbology, code string) (*models.Barcode, error) {
+	return r.get(ctx, `SELECT `+barcodeColumns+` FROM barcodes WHERE symbology = $1 AND code = $2`, symbology, code)
+}
+
+func (r barcodeRepo) get(ctx context.Context, query string, args ...interface{}) (*models.Barcode, error) {
+	var s barcodeScanner
+	if err := r.q.QueryRowContext(ctx, query, args...).Scan(s.dest()...); err != nil {
+		return nil, notFound(err)
+	}
+	b, err := s.result()
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r barcodeRepo) Create(ctx context.Context, b *models.Barcode) error {
+	params, err := json.Marshal(b.Params)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+        INSERT INTO barcodes (`+barcodeColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+    `, b.ID, b.Code, b.Symbology, b.BookID, b.CopyID, b.Filename, b.StoragePath, b.Size, params, b.CreatedBy, b.CreatedAt)
+	return conflict(err)
+}
+
+func (r barcodeRepo) SetLinks(ctx context.Context, id uuid.UUID, bookID, copyID *uuid.UUID) error {
+	return affected(r.q.ExecContext(ctx, "UPDATE barcodes SET book_id = $2, copy_id = $3 WHERE id = $1", id, bookID, copyID))
+}
+
+func (r barcodeRepo) SetParams(ctx context.Context, id uuid.UUID, params models.BarcodeParams) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return affected(r.q.ExecContext(ctx, "UPDATE barcodes SET params = $2 WHERE id = $1", id, b))
+}
+
+func (r barcodeRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return affected(r.q.ExecContext(ctx, "DELETE FROM barcodes WHERE id = $1", id))
+}
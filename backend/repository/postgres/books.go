@@ -0,0 +1,471 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+	"lablib/search"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type bookRepo struct{ q querier }
+
+const bookColumns = `b.id, b.title, b.author, b.isbn, b.jan, b.ean13, b.type, b.total_copies,
+        b.barcode, b.location, b.published_year, b.publisher, b.published_date, b.edition, b.page_count,
+        b.language, b.description, b.subjects, b.notes, b.image_path, b.withdrawn_at, b.withdrawal_reason,
+        b.created_at, b.updated_at`
+
+// bookScanner は scanBook が読み取る列の順序を保持する
+type bookScanner struct {
+	book                                    models.Book
+	isbn, jan, ean13, barcode, loc, imgPath sql.NullString
+	year                                    sql.NullInt64
+	subjects                                pq.StringArray
+	withdrawnAt                             sql.NullTime
+}
+
+func (s *bookScanner) dest() []interface{} {
+	return []interface{}{
+		&s.book.ID, &s.book.Title, &s.book.Author, &s.isbn,
+		&s.jan, &s.ean13, &s.book.Type, &s.book.TotalCopies,
+		&s.barcode, &s.loc, &s.year, &s.book.Publisher, &s.book.PublishedDate, &s.book.Edition, &s.book.PageCount,
+		&s.book.Language, &s.book.Description, &s.subjects, &s.book.Notes, &s.imgPath, &s.withdrawnAt, &s.book.WithdrawalReason,
+		&s.book.CreatedAt, &s.book.UpdatedAt,
+	}
+}
+
+func (s *bookScanner) result() models.Book {
+	s.book.ISBN = s.isbn.String
+	s.book.JAN = s.jan.String
+	s.book.EAN13 = s.ean13.String
+	s.book.Barcode = s.barcode.String
+	s.book.Location = s.loc.String
+	s.book.ImagePath = s.imgPath.String
+	if s.year.Valid {
+		year := int(s.year.Int64)
+		s.book.PublishedYear = &year
+	}
+	s.book.Subjects = []string(s.subjects)
+	if s.withdrawnAt.Valid {
+		s.book.WithdrawnAt = &s.withdrawnAt.Time
+	}
+	return s.book
+}
+
+// bookSortColumns は repository.BookSortFields と列の対応
+var bookSortColumns = map[string]string{
+	"title":            "b.title",
+	"author":           "b.author",
+	"type":             "b.type",
+	"location":         "b.location",
+	"available_copies": "available_copies",
+	"created_at":       "b.created_at",
+	"updated_at":       "b.updated_at",
+}
+
+// availableExists は貸出可能なコピーがあることを表す条件
+const availableExists = "EXISTS (SELECT 1 FROM book_copies bc WHERE bc.book_id = b.id AND bc.is_available = true)"
+
+// bookConditions は filter の絞り込み条件（Sort・Page 以外）を WHERE 句にする
+func bookConditions(filter repository.BookFilter, query search.Query) conditions {
+	var c conditions
+	if filter.Withdrawn {
+		c.add("b.withdrawn_at IS NOT NULL")
+	} else {
+		c.add("b.withdrawn_at IS NULL")
+	}
+	for _, t := range query.Terms {
+		// 語ごとに全文検索の索引で絞り込み、英数字は pg_trgm の索引による部分一致でも補う
+		if sub := t.Substring(); sub != "" {
+			c.add("(b.search_vector @@ ?::tsquery OR b.search_text LIKE ?)", t.TSQuery(), "%"+escapeLike(sub)+"%")
+		} else {
+			c.add("b.search_vector @@ ?::tsquery", t.TSQuery())
+		}
+	}
+	if filter.Type != "" {
+		c.add("b.type = ?", filter.Type)
+	}
+	if filter.Location != "" {
+		c.add("b.location = ?", filter.Location)
+	}
+	if filter.Author != "" {
+		c.add("EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id AND ba.name = ?)", filter.Author)
+	}
+	if filter.Publisher != "" {
+		c.add("b.publisher = ?", filter.Publisher)
+	}
+	if filter.Language != "" {
+		c.add("b.language = ?", filter.Language)
+	}
+	if filter.Subject != "" {
+		c.add("b.subjects @> ARRAY[?]::text[]", filter.Subject)
+	}
+	if filter.Year != 0 {
+		c.add("b.published_year = ?", filter.Year)
+	}
+	if filter.Available != nil {
+		if *filter.Available {
+			c.add(availableExists)
+		} else {
+			c.add("NOT " + availableExists)
+		}
+	}
+	if filter.HasImage != nil {
+		if *filter.HasImage {
+			c.add("COALESCE(b.image_path, '') <> ''")
+		} else {
+			c.add("COALESCE(b.image_path, '') = ''")
+		}
+	}
+	return c
+}
+
+func (r bookRepo) Search(ctx context.Context, filter repository.BookFilter) ([]models.BookSummary, int, error) {
+	query := search.Parse(filter.Query)
+	c := bookConditions(filter, query)
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM books b`+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args := c.args
+	var order string
+	if relevanceOrder(filter.Sort, query) {
+		args = append(args, query.TSQuery())
+		order = fmt.Sprintf(" ORDER BY ts_rank(b.search_vector, $%d::tsquery) DESC, b.title, b.id", len(args))
+	} else {
+		sort := filter.Sort
+		if sort.Field == "relevance" {
+			sort = repository.Sort{}
+		}
+		var err error
+		if order, err = orderBy(sort, bookSortColumns, "title", "b.id"); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	rows, err := r.q.QueryContext(ctx, `
+    SELECT `+bookColumns+`,
+        (SELECT COUNT(*) FROM book_copies bc WHERE bc.book_id = b.id AND bc.is_available = true) AS available_copies
+    FROM books b`+c.where()+order+limitOffset(filter.Page), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var books []models.BookSummary
+	for rows.Next() {
+		var s bookScanner
+		var available int
+		if err := rows.Scan(append(s.dest(), &available)...); err != nil {
+			return nil, 0, err
+		}
+		books = append(books, models.BookSummary{Book: s.result(), AvailableCopies: available})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uuid.UUID, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+	}
+	authors, err := r.authors(ctx, ids...)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range books {
+		books[i].Authors = authors[books[i].ID]
+	}
+	return books, total, nil
+}
+
+// authors は書籍ごとの著者を表示順に返す
+func (r bookRepo) authors(ctx context.Context, ids ...uuid.UUID) (map[uuid.UUID][]models.BookAuthor, error) {
+	authors := map[uuid.UUID][]models.BookAuthor{}
+	if len(ids) == 0 {
+		return authors, nil
+	}
+	keys := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT book_id, name, role FROM book_authors
+        WHERE book_id = ANY($1::uuid[])
+        ORDER BY book_id, position`, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var a models.BookAuthor
+		if err := rows.Scan(&id, &a.Name, &a.Role); err != nil {
+			return nil, err
+		}
+		authors[id] = append(authors[id], a)
+	}
+	return authors, rows.Err()
+}
+
+// replaceAuthors は書籍の著者を book.Authors の順で置き換える
+func (r bookRepo) replaceAuthors(ctx context.Context, book *models.Book) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM book_authors WHERE book_id = $1", book.ID); err != nil {
+		return err
+	}
+	for i, a := range book.Authors {
+		role := a.Role
+		if role == "" {
+			role = models.AuthorRoleAuthor
+		}
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO book_authors (book_id, position, name, role) VALUES ($1, $2, $3, $4)",
+			book.ID, i, a.Name, role)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subjectsArray は件名を text[] の引数にする（nil は NULL ではなく空配列）
+func subjectsArray(subjects []string) pq.StringArray {
+	if subjects == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(subjects)
+}
+
+// Facets は項目ごとに GROUP BY で集計する。各項目はその項目自身の絞り込みを外して集計するため、
+// 選択中の値以外の候補と件数も返る。
+func (r bookRepo) Facets(ctx context.Context, filter repository.BookFilter) (*repository.BookFacets, error) {
+	query := search.Parse(filter.Query)
+	var facets repository.BookFacets
+	var err error
+
+	f := filter
+	f.Type = ""
+	if facets.Type, err = r.facet(ctx, bookConditions(f, query), "b.type", "", "", "n DESC, value", 0); err != nil {
+		return nil, err
+	}
+	f = filter
+	f.Location = ""
+	if facets.Location, err = r.facet(ctx, bookConditions(f, query), "b.location", "", "b.location <> ''",
+		"n DESC, value", repository.FacetLimit); err != nil {
+		return nil, err
+	}
+	f = filter
+	f.Author = ""
+	if facets.Author, err = r.facet(ctx, bookConditions(f, query), "ba.name",
+		"JOIN book_authors ba ON ba.book_id = b.id", "", "n DESC, value", repository.FacetLimit); err != nil {
+		return nil, err
+	}
+	f = filter
+	f.Year = 0
+	if facets.Year, err = r.facet(ctx, bookConditions(f, query), "b.published_year", "", "b.published_year IS NOT NULL",
+		"value DESC", 0); err != nil {
+		return nil, err
+	}
+	f = filter
+	f.Available = nil
+	if facets.Availability, err = r.facet(ctx, bookConditions(f, query),
+		"CASE WHEN "+availableExists+" THEN 'available' ELSE 'unavailable' END", "", "", "value", 0); err != nil {
+		return nil, err
+	}
+	return &facets, nil
+}
+
+// facet は expr の値ごとの書籍数を集計する。join は集計に使う表の結合、extra は集計対象から除く値の条件、
+// limit が0なら全件。
+func (r bookRepo) facet(ctx context.Context, c conditions, expr, join, extra, order string, limit int) ([]repository.FacetCount, error) {
+	if extra != "" {
+		c.add(extra)
+	}
+	rows, err := r.q.QueryContext(ctx, `SELECT `+expr+` AS value, COUNT(DISTINCT b.id) AS n FROM books b `+join+c.where()+
+		` GROUP BY value ORDER BY `+order+limitOffset(repository.Page{Limit: limit}), c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []repository.FacetCount{}
+	for rows.Next() {
+		var fc repository.FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, fc)
+	}
+	return counts, rows.Err()
+}
+
+// relevanceOrder は関連度順に並べるかを返す。検索語がない場合は関連度順を指定されてもタイトル順にする。
+func relevanceOrder(sort repository.Sort, query search.Query) bool {
+	return !query.Empty() && (sort.Field == "" || sort.Field == "relevance")
+}
+
+// escapeLike は LIKE のワイルドカードをエスケープする
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// repeat は同じ値を n 個並べた引数を返す
+func repeat(v interface{}, n int) []interface{} {
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = v
+	}
+	return args
+}
+
+func (r bookRepo) Get(ctx context.Context, id uuid.UUID) (*models.Book, error) {
+	var s bookScanner
+	err := r.q.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books b WHERE b.id = $1`, id).Scan(s.dest()...)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	book := s.result()
+	authors, err := r.authors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	book.Authors = authors[id]
+	return &book, nil
+}
+
+// cleanCode は codes.Clean と同じ比較用の正規化を行う SQL 式（全角文字は対象外）
+func cleanCode(column string) string {
+	return `UPPER(REPLACE(REPLACE(` + column + `, '-', ''), ' ', ''))`
+}
+
+func (r bookRepo) FindByCodes(ctx context.Context, keys []string) ([]models.Book, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+bookColumns+` FROM books b
+        WHERE `+cleanCode("b.isbn")+` = ANY($1) OR `+cleanCode("b.jan")+` = ANY($1) OR `+cleanCode("b.ean13")+` = ANY($1)
+        ORDER BY b.created_at, b.id`, pq.StringArray(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	var ids []uuid.UUID
+	for rows.Next() {
+		var s bookScanner
+		if err := rows.Scan(s.dest()...); err != nil {
+			return nil, err
+		}
+		books = append(books, s.result())
+		ids = append(ids, s.book.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	authors, err := r.authors(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range books {
+		books[i].Authors = authors[books[i].ID]
+	}
+	return books, nil
+}
+
+func (r bookRepo) Create(ctx context.Context, book *models.Book) error {
+	doc := repository.SearchDocument(*book)
+	_, err := r.q.ExecContext(ctx, `
+    INSERT INTO books (id, title, author, isbn, jan, ean13, type, total_copies, barcode, location, published_year,
+        publisher, published_date, edition, page_count, language, description, subjects, notes,
+        created_at, updated_at, search_text, search_vector)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22,
+        $23::tsvector)
+`,
+		book.ID, book.Title, book.Author, book.ISBN,
+		book.JAN, book.EAN13, book.Type, book.TotalCopies,
+		book.Barcode, book.Location, book.PublishedYear,
+		book.Publisher, book.PublishedDate, book.Edition, book.PageCount,
+		book.Language, book.Description, subjectsArray(book.Subjects), book.Notes,
+		book.CreatedAt, book.UpdatedAt, doc.Text(), doc.TSVector(),
+	)
+	if err != nil {
+		return conflict(err)
+	}
+	return r.replaceAuthors(ctx, book)
+}
+
+func (r bookRepo) Update(ctx context.Context, book *models.Book) error {
+	doc := repository.SearchDocument(*book)
+	err := affected(r.q.ExecContext(ctx, `
+        UPDATE books
+        SET title = $1, author = $2, isbn = $3, jan = $4, ean13 = $5, type = $6,
+            total_copies = $7, barcode = $8, location = $9, published_year = $10,
+            publisher = $11, published_date = $12, edition = $13, page_count = $14,
+            language = $15, description = $16, subjects = $17, notes = $18, updated_at = $19,
+            search_text = $20, search_vector = $21::tsvector
+        WHERE id = $22
+    `, book.Title, book.Author, book.ISBN, book.JAN, book.EAN13, book.Type,
+		book.TotalCopies, book.Barcode, book.Location, book.PublishedYear,
+		book.Publisher, book.PublishedDate, book.Edition, book.PageCount,
+		book.Language, book.Description, subjectsArray(book.Subjects), book.Notes, book.UpdatedAt,
+		doc.Text(), doc.TSVector(), book.ID))
+	if err != nil {
+		return err
+	}
+	return r.replaceAuthors(ctx, book)
+}
+
+func (r bookRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return affected(r.q.ExecContext(ctx, "DELETE FROM books WHERE id = $1", id))
+}
+
+func (r bookRepo) SetImagePath(ctx context.Context, id uuid.UUID, imagePath string) error {
+	path := sql.NullString{String: imagePath, Valid: imagePath != ""}
+	return affected(r.q.ExecContext(ctx,
+		"UPDATE books SET image_path = $1, updated_at = NOW() WHERE id = $2", path, id))
+}
+
+func (r bookRepo) SetWithdrawn(ctx context.Context, id uuid.UUID, withdrawnAt *time.Time, reason string) error {
+	return affected(r.q.ExecContext(ctx,
+		"UPDATE books SET withdrawn_at = $1, withdrawal_reason = $2, updated_at = NOW() WHERE id = $3",
+		withdrawnAt, reason, id))
+}
+
+func (r bookRepo) RebuildSearchIndex(ctx context.Context) (int, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+bookColumns+` FROM books b WHERE b.search_vector IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	var books []models.Book
+	for rows.Next() {
+		var s bookScanner
+		if err := rows.Scan(s.dest()...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		books = append(books, s.result())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, b := range books {
+		doc := repository.SearchDocument(b)
+		_, err := r.q.ExecContext(ctx,
+			"UPDATE books SET search_text = $1, search_vector = $2::tsvector WHERE id = $3",
+			doc.Text(), doc.TSVector(), b.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(books), nil
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+type copyRepo struct{ q querier }
+
+const copyColumns = `id, book_id, serial_number, barcode, is_available, location, created_at, updated_at`
+
+func scanCopy(row interface{ Scan(...interface{}) error }) (*models.BookCopy, error) {
+	var bc models.BookCopy
+	var barcode, location sql.NullString
+	err := row.Scan(&bc.ID, &bc.BookID, &bc.SerialNumber, &barcode, &bc.IsAvailable,
+		&location, &bc.CreatedAt, &bc.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	bc.Barcode = barcode.String
+	if location.Valid {
+		bc.Location = &location.String
+	}
+	return &bc, nil
+}
+
+func (r copyRepo) Create(ctx context.Context, bc *models.BookCopy) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO book_copies (id, book_id, serial_number, barcode, is_available, location, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, bc.ID, bc.BookID, bc.SerialNumber, bc.Barcode, bc.IsAvailable, bc.Location, bc.CreatedAt, bc.UpdatedAt)
+	return err
+}
+
+func (r copyRepo) Get(ctx context.Context, id uuid.UUID) (*models.BookCopy, error) {
+	return scanCopy(r.q.QueryRowContext(ctx, `SELECT `+copyColumns+` FROM book_copies WHERE id = $1`, id))
+}
+
+func (r copyRepo) ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.BookCopy, error) {
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT `+copyColumns+` FROM book_copies WHERE book_id = $1 ORDER BY created_at, id
+    `, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var copies []models.BookCopy
+	for rows.Next() {
+		bc, err := scanCopy(rows)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, *bc)
+	}
+	return copies, rows.Err()
+}
+
+func (r copyRepo) FindByBarcode(ctx context.Context, barcode string) (*models.BookCopy, error) {
+	return scanCopy(r.q.QueryRowContext(ctx, `
+        SELECT `+copyColumns+` FROM book_copies WHERE barcode = $1 LIMIT 1
+    `, barcode))
+}
+
+func (r copyRepo) FindAvailableByBarcode(ctx context.Context, barcode string) (*models.BookCopy, error) {
+	return scanCopy(r.q.QueryRowContext(ctx, `
+        SELECT `+copyColumns+` FROM book_copies WHERE barcode = $1 AND is_available = true
+        LIMIT 1 FOR UPDATE SKIP LOCKED
+    `, barcode))
+}
+
+func (r copyRepo) FindAvailableByBook(ctx context.Context, bookID uuid.UUID) (*models.BookCopy, error) {
+	return scanCopy(r.q.QueryRowContext(ctx, `
+        SELECT `+copyColumns+` FROM book_copies
+        WHERE book_id = $1 AND is_available = true
+        LIMIT 1 FOR UPDATE SKIP LOCKED
+    `, bookID))
+}
+
+func (r copyRepo) CountAvailableByBook(ctx context.Context, bookID uuid.UUID) (int, error) {
+	var n int
+	err := r.q.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM book_copies
+        WHERE book_id = $1 AND is_available = true
+    `, bookID).Scan(&n)
+	return n, err
+}
+
+func (r copyRepo) SetAvailable(ctx context.Context, id uuid.UUID, available bool) error {
+	return affected(r.q.ExecContext(ctx, `
+        UPDATE book_copies SET is_available = $1, updated_at = NOW() WHERE id = $2
+    `, available, id))
+}
+
+func (r copyRepo) SetBarcodeByBook(ctx context.Context, bookID uuid.UUID, barcode string) error {
+	_, err := r.q.ExecContext(ctx, `
+        UPDATE book_copies SET barcode = $1, updated_at = NOW() WHERE book_id = $2
+    `, barcode, bookID)
+	return err
+}
+
+func (r copyRepo) DeleteAvailable(ctx context.Context, bookID uuid.UUID, n int) error {
+	_, err := r.q.ExecContext(ctx, `
+        DELETE FROM book_copies
+        WHERE id IN (
+            SELECT id FROM book_copies
+            WHERE book_id = $1 AND is_available = true
+            LIMIT $2
+        )
+    `, bookID, n)
+	return err
+}
+
+func (r copyRepo) DeleteByBook(ctx context.Context, bookID uuid.UUID) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM book_copies WHERE book_id = $1", bookID)
+	return err
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type coverJobRepo struct{ q querier }
+
+const coverJobColumns = `id, status, total_books, processed_books, imported_books, not_found_books, error_books, error,
+    created_by, created_at, updated_at, started_at, finished_at`
+
+func coverJobDest(j *models.CoverJob) []interface{} {
+	return []interface{}{
+		&j.ID, &j.Status, &j.TotalBooks, &j.ProcessedBooks, &j.ImportedBooks, &j.NotFoundBooks, &j.ErrorBooks, &j.Error,
+		&j.CreatedBy, &j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt,
+	}
+}
+
+func (r coverJobRepo) Create(ctx context.Context, job *models.CoverJob) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO cover_jobs (`+coverJobColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+    `, job.ID, job.Status, job.TotalBooks, job.ProcessedBooks, job.ImportedBooks, job.NotFoundBooks, job.ErrorBooks, job.Error,
+		job.CreatedBy, job.CreatedAt, job.UpdatedAt, job.StartedAt, job.FinishedAt)
+	return conflict(err)
+}
+
+func (r coverJobRepo) Get(ctx context.Context, id uuid.UUID) (*models.CoverJob, error) {
+	var job models.CoverJob
+	err := r.q.QueryRowContext(ctx, `SELECT `+coverJobColumns+` FROM cover_jobs WHERE id = $1`, id).Scan(coverJobDest(&job)...)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &job, nil
+}
+
+func (r coverJobRepo) List(ctx context.Context, page repository.Page) ([]models.CoverJob, int, error) {
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM cover_jobs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	jobs, err := r.query(ctx, `SELECT `+coverJobColumns+` FROM cover_jobs ORDER BY created_at DESC, id`+limitOffset(page))
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+func (r coverJobRepo) ListUnfinished(ctx context.Context) ([]models.CoverJob, error) {
+	return r.query(ctx, `SELECT `+coverJobColumns+` FROM cover_jobs
+        WHERE status IN ($1, $2) ORDER BY created_at, id`, models.ImportStatusQueued, models.ImportStatusRunning)
+}
+
+func (r coverJobRepo) query(ctx context.Context, query string, args ...interface{}) ([]models.CoverJob, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.CoverJob
+	for rows.Next() {
+		var job models.CoverJob
+		if err := rows.Scan(coverJobDest(&job)...); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (r coverJobRepo) Update(ctx context.Context, job *models.CoverJob) error {
+	return affected(r.q.ExecContext(ctx, `
+        UPDATE cover_jobs
+        SET status = $1, total_books = $2, processed_books = $3, imported_books = $4, not_found_books = $5,
+            error_books = $6, error = $7, updated_at = $8, started_at = $9, finished_at = $10
+        WHERE id = $11
+    `, job.Status, job.TotalBooks, job.ProcessedBooks, job.ImportedBooks, job.NotFoundBooks,
+		job.ErrorBooks, job.Error, job.UpdatedAt, job.StartedAt, job.FinishedAt, job.ID))
+}
+
+func (r coverJobRepo) AddResult(ctx context.Context, result *models.CoverResult) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO cover_job_results (job_id, seq, book_id, title, isbn, status, cover_url, image_path, message)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `, result.JobID, result.Seq, result.BookID, result.Title, result.ISBN, result.Status,
+		result.CoverURL, result.ImagePath, result.Message)
+	return conflict(err)
+}
+
+func (r coverJobRepo) Results(ctx context.Context, filter repository.CoverResultFilter) ([]models.CoverResult, int, error) {
+	var c conditions
+	c.add("job_id = ?", filter.JobID)
+	if filter.Status != "" {
+		c.add("status = ?", filter.Status)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM cover_job_results`+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT job_id, seq, book_id, title, isbn, status, cover_url, image_path, message
+        FROM cover_job_results`+c.where()+` ORDER BY seq`+limitOffset(filter.Page), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []models.CoverResult
+	for rows.Next() {
+		var res models.CoverResult
+		if err := rows.Scan(&res.JobID, &res.Seq, &res.BookID, &res.Title, &res.ISBN, &res.Status,
+			&res.CoverURL, &res.ImagePath, &res.Message); err != nil {
+			return nil, 0, err
+		}
+		results = append(results, res)
+	}
+	return results, total, rows.Err()
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type importJobRepo struct{ q querier }
+
+const importJobColumns = `id, filename, storage_path, format, sheet, mapping, dry_run, enrich, status,
+    total_rows, processed_rows, imported_rows, duplicate_rows, error_rows, error,
+    created_by, created_at, updated_at, started_at, finished_at`
+
+// importJobScanner は importJobColumns の順序でジョブを読み取る
+type importJobScanner struct {
+	job     models.ImportJob
+	mapping []byte
+}
+
+func (s *importJobScanner) dest() []interface{} {
+	j := &s.job
+	return []interface{}{
+		&j.ID, &j.Filename, &j.StoragePath, &j.Format, &j.Sheet, &s.mapping, &j.DryRun, &j.Enrich, &j.Status,
+		&j.TotalRows, &j.ProcessedRows, &j.ImportedRows, &j.DuplicateRows, &j.ErrorRows, &j.Error,
+		&j.CreatedBy, &j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt,
+	}
+}
+
+func (s *importJobScanner) result() (models.ImportJob, error) {
+	s.job.Mapping = map[string]string{}
+	if err := json.Unmarshal(s.mapping, &s.job.Mapping); err != nil {
+		return s.job, err
+	}
+	return s.job, nil
+}
+
+func (r importJobRepo) Create(ctx context.Context, job *models.ImportJob) error {
+	mapping, err := json.Marshal(job.Mapping)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+        INSERT INTO import_jobs (`+importJobColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+    `, job.ID, job.Filename, job.StoragePath, job.Format, job.Sheet, mapping, job.DryRun, job.Enrich, job.Status,
+		job.TotalRows, job.ProcessedRows, job.ImportedRows, job.DuplicateRows, job.ErrorRows, job.Error,
+		job.CreatedBy, job.CreatedAt, job.UpdatedAt, job.StartedAt, job.FinishedAt)
+	return err
+}
+
+func (r importJobRepo) Get(ctx context.Context, id uuid.UUID) (*models.ImportJob, error) {
+	var s importJobScanner
+	err := r.q.QueryRowContext(ctx, `SELECT `+importJobColumns+` FROM import_jobs WHERE id = $1`, id).Scan(s.dest()...)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	job, err := s.result()
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r importJobRepo) List(ctx context.Context, page repository.Page) ([]models.ImportJob, int, error) {
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM import_jobs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	jobs, err := r.query(ctx, `SELECT `+importJobColumns+` FROM import_jobs ORDER BY created_at DESC, id`+limitOffset(page))
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+func (r importJobRepo) ListUnfinished(ctx context.Context) ([]models.ImportJob, error) {
+	return r.query(ctx, `SELECT `+importJobColumns+` FROM import_jobs
+        WHERE status IN ($1, $2) ORDER BY created_at, id`, models.ImportStatusQueued, models.ImportStatusRunning)
+}
+
+func (r importJobRepo) query(ctx context.Context, query string, args ...interface{}) ([]models.ImportJob, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.ImportJob
+	for rows.Next() {
+		var s importJobScanner
+		if err := rows.Scan(s.dest()...); err != nil {
+			return nil, err
+		}
+		job, err := s.result()
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (r importJobRepo) Update(ctx context.Context, job *models.ImportJob) error {
+	return affected(r.q.ExecContext(ctx, `
+        UPDATE import_jobs
+        SET status = $1, total_rows = $2, processed_rows = $3, imported_rows = $4, duplicate_rows = $5,
+            error_rows = $6, error = $7, updated_at = $8, started_at = $9, finished_at = $10
+        WHERE id = $11
+    `, job.Status, job.TotalRows, job.ProcessedRows, job.ImportedRows, job.DuplicateRows,
+		job.ErrorRows, job.Error, job.UpdatedAt, job.StartedAt, job.FinishedAt, job.ID))
+}
+
+func (r importJobRepo) AddRow(ctx context.Context, row *models.ImportRow) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO import_job_rows (job_id, row_number, status, title, isbn, book_id, duplicate_of, messages)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, row.JobID, row.RowNumber, row.Status, row.Title, row.ISBN, row.BookID, row.DuplicateOf,
+		pq.StringArray(row.Messages))
+	return conflict(err)
+}
+
+func (r importJobRepo) Rows(ctx context.Context, filter repository.ImportRowFilter) ([]models.ImportRow, int, error) {
+	var c conditions
+	c.add("job_id = ?", filter.JobID)
+	if filter.Status != "" {
+		c.add("status = ?", filter.Status)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM import_job_rows`+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT job_id, row_number, status, title, isbn, book_id, duplicate_of, messages
+        FROM import_job_rows`+c.where()+` ORDER BY row_number`+limitOffset(filter.Page), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var result []models.ImportRow
+	for rows.Next() {
+		var row models.ImportRow
+		var messages pq.StringArray
+		if err := rows.Scan(&row.JobID, &row.RowNumber, &row.Status, &row.Title, &row.ISBN,
+			&row.BookID, &row.DuplicateOf, &messages); err != nil {
+			return nil, 0, err
+		}
+		row.Messages = messages
+		result = append(result, row)
+	}
+	return result, total, rows.Err()
+}
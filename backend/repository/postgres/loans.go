@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type loanRepo struct{ q querier }
+
+const loanSelect = `
+        SELECT br.id, br.user_id, br.book_copy_id, br.borrowed_at, br.due_date, br.returned_at, br.status,
+               br.renew_count, b.id, b.title, b.author, b.type, bc.serial_number, u.student_id, u.name
+        FROM borrow_records br
+        JOIN book_copies bc ON br.book_copy_id = bc.id
+        JOIN books b ON bc.book_id = b.id
+        JOIN users u ON br.user_id = u.id`
+
+func scanLoan(row interface{ Scan(...interface{}) error }) (*models.BorrowRecord, error) {
+	var br models.BorrowRecord
+	var returnedAt sql.NullTime
+	err := row.Scan(
+		&br.ID, &br.UserID, &br.BookCopyID, &br.BorrowedAt, &br.DueDate, &returnedAt, &br.Status,
+		&br.RenewCount, &br.Book.ID, &br.Book.Title, &br.Book.Author, &br.Book.Type, &br.BookCopy.SerialNumber,
+		&br.User.StudentID, &br.User.Name,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if returnedAt.Valid {
+		br.ReturnedAt = &returnedAt.Time
+	}
+	br.BookCopy.ID = br.BookCopyID
+	br.BookCopy.BookID = br.Book.ID
+	br.User.ID = br.UserID
+	return &br, nil
+}
+
+func (r loanRepo) list(ctx context.Context, query string, args ...interface{}) ([]models.BorrowRecord, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.BorrowRecord
+	for rows.Next() {
+		br, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *br)
+	}
+	return records, rows.Err()
+}
+
+func (r loanRepo) Create(ctx context.Context, br *models.BorrowRecord) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO borrow_records (id, user_id, book_copy_id, borrowed_at, due_date, status)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, br.ID, br.UserID, br.BookCopyID, br.BorrowedAt, br.DueDate, br.Status)
+	return err
+}
+
+func (r loanRepo) Get(ctx context.Context, id uuid.UUID) (*models.BorrowRecord, error) {
+	return scanLoan(r.q.QueryRowContext(ctx, loanSelect+` WHERE br.id = $1`, id))
+}
+
+func (r loanRepo) FindOpen(ctx context.Context, copyID, userID uuid.UUID) (*models.BorrowRecord, error) {
+	return scanLoan(r.q.QueryRowContext(ctx, loanSelect+`
+        WHERE br.book_copy_id = $1 AND br.user_id = $2 AND br.status = 'borrowed'
+        LIMIT 1`, copyID, userID))
+}
+
+// loanSortColumns は repository.LoanSortFields と列の対応
+var loanSortColumns = map[string]string{
+	"borrowed_at": "br.borrowed_at",
+	"due_date":    "br.due_date",
+	"returned_at": "br.returned_at",
+	"status":      "br.status",
+	"user_name":   "u.name",
+	"book_title":  "b.title",
+}
+
+func (r loanRepo) List(ctx context.Context, filter repository.LoanFilter) ([]models.BorrowRecord, int, error) {
+	var c conditions
+	if filter.UserID != uuid.Nil {
+		c.add("br.user_id = ?", filter.UserID)
+	}
+	if filter.BookID != uuid.Nil {
+		c.add("bc.book_id = ?", filter.BookID)
+	}
+	if filter.Status != "" {
+		c.add("br.status = ?", filter.Status)
+	}
+	if !filter.BorrowedFrom.IsZero() {
+		c.add("br.borrowed_at >= ?", filter.BorrowedFrom)
+	}
+	if !filter.BorrowedTo.IsZero() {
+		c.add("br.borrowed_at < ?", filter.BorrowedTo)
+	}
+	if filter.Overdue {
+		c.add("br.returned_at IS NULL AND br.due_date < NOW()")
+	}
+
+	sort := filter.Sort
+	if sort.Field == "" {
+		sort = repository.Sort{Field: "borrowed_at", Desc: true}
+	}
+	order, err := orderBy(sort, loanSortColumns, "borrowed_at", "br.id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = r.q.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM borrow_records br
+        JOIN book_copies bc ON br.book_copy_id = bc.id
+        JOIN books b ON bc.book_id = b.id
+        JOIN users u ON br.user_id = u.id`+c.where(), c.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	records, err := r.list(ctx, loanSelect+c.where()+order+limitOffset(filter.Page), c.args...)
+	return records, total, err
+}
+
+func (r loanRepo) ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.BorrowRecord, error) {
+	return r.list(ctx, loanSelect+` WHERE bc.book_id = $1 ORDER BY br.borrowed_at DESC`, bookID)
+}
+
+func (r loanRepo) MarkReturned(ctx context.Context, id uuid.UUID, returnedAt time.Time) error {
+	return affected(r.q.ExecContext(ctx, `
+        UPDATE borrow_records
+        SET returned_at = $1, status = 'returned'
+        WHERE id = $2
+    `, returnedAt, id))
+}
+
+func (r loanRepo) UpdateDueDate(ctx context.Context, id uuid.UUID, dueDate time.Time, renewCount int) error {
+	return affected(r.q.ExecContext(ctx, `
+        UPDATE borrow_records
+        SET due_date = $1, renew_count = $2
+        WHERE id = $3
+    `, dueDate, renewCount, id))
+}
+
+func (r loanRepo) CountOpenByBook(ctx context.Context, bookID uuid.UUID) (int, error) {
+	var n int
+	err := r.q.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM borrow_records br
+        JOIN book_copies bc ON br.book_copy_id = bc.id
+        WHERE bc.book_id = $1 AND br.returned_at IS NULL
+    `, bookID).Scan(&n)
+	return n, err
+}
+
+func (r loanRepo) CountOpenByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.q.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM borrow_records WHERE user_id = $1 AND returned_at IS NULL
+    `, userID).Scan(&n)
+	return n, err
+}
+
+func (r loanRepo) DeleteReturnedByBook(ctx context.Context, bookID uuid.UUID) error {
+	_, err := r.q.ExecContext(ctx, `
+        DELETE FROM borrow_records
+        WHERE book_copy_id IN (
+            SELECT id FROM book_copies WHERE book_id = $1
+        ) AND returned_at IS NOT NULL
+    `, bookID)
+	return err
+}
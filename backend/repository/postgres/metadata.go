@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+
+	"lablib/models"
+)
+
+type metadataCacheRepo struct{ q querier }
+
+func (r metadataCacheRepo) Get(ctx context.Context, isbn, provider string) (*models.MetadataCacheEntry, error) {
+	e := models.MetadataCacheEntry{ISBN: isbn, Provider: provider}
+	var data []byte
+	err := r.q.QueryRowContext(ctx, `
+        SELECT data, fetched_at FROM book_metadata_cache WHERE isbn = $1 AND provider = $2
+    `, isbn, provider).Scan(&data, &e.FetchedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	e.Data = data
+	return &e, nil
+}
+
+func (r metadataCacheRepo) Put(ctx context.Context, entry *models.MetadataCacheEntry) error {
+	var data interface{}
+	if entry.Data != nil {
+		data = []byte(entry.Data)
+	}
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO book_metadata_cache (isbn, provider, data, fetched_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (isbn, provider) DO UPDATE SET data = EXCLUDED.data, fetched_at = EXCLUDED.fetched_at
+    `, entry.ISBN, entry.Provider, data, entry.FetchedAt)
+	return err
+}
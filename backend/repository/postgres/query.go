@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"lablib/repository"
+)
+
+// conditions は WHERE 句と引数を組み立てる。条件中の "?" は追加順に $1, $2, ... へ置き換えられる。
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+func (c *conditions) add(clause string, args ...interface{}) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		clause = strings.Replace(clause, "?", "$"+strconv.Itoa(len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// orderBy は s を ORDER BY 句に変換する。columns は許可されたフィールド名から列への対応で、
+// 対応のないフィールドはエラーになる。tiebreak はページングの順序を安定させるための列。
+func orderBy(s repository.Sort, columns map[string]string, def, tiebreak string) (string, error) {
+	field := s.Field
+	if field == "" {
+		field = def
+	}
+	column, ok := columns[field]
+	if !ok {
+		return "", fmt.Errorf("unsupported sort field: %q", s.Field)
+	}
+	dir := "ASC"
+	if s.Desc {
+		dir = "DESC NULLS LAST"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s", column, dir, tiebreak), nil
+}
+
+// limitOffset は p を LIMIT/OFFSET 句に変換する
+func limitOffset(p repository.Page) string {
+	clause := ""
+	if p.Limit > 0 {
+		clause += " LIMIT " + strconv.Itoa(p.Limit)
+	}
+	if p.Offset > 0 {
+		clause += " OFFSET " + strconv.Itoa(p.Offset)
+	}
+	return clause
+}
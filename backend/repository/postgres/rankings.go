@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+type rankingRepo struct{ q querier }
+
+func (r rankingRepo) Increment(ctx context.Context, month string, bookID uuid.UUID) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO monthly_rankings (id, month, book_id, borrow_count)
+        VALUES ($1, $2, $3, 1)
+        ON CONFLICT (month, book_id) DO UPDATE
+        SET borrow_count = monthly_rankings.borrow_count + 1
+    `, uuid.New(), month, bookID)
+	return err
+}
+
+func (r rankingRepo) Monthly(ctx context.Context, month string, limit int) ([]models.MonthlyRanking, error) {
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT mr.id, mr.month, mr.book_id, mr.borrow_count,
+               b.title, b.author, b.type
+        FROM monthly_rankings mr
+        JOIN books b ON mr.book_id = b.id
+        WHERE mr.month = $1
+        ORDER BY mr.borrow_count DESC
+        LIMIT $2
+    `, month, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rankings []models.MonthlyRanking
+	for rows.Next() {
+		var mr models.MonthlyRanking
+		err := rows.Scan(&mr.ID, &mr.Month, &mr.BookID, &mr.BorrowCount,
+			&mr.Book.Title, &mr.Book.Author, &mr.Book.Type)
+		if err != nil {
+			return nil, err
+		}
+		mr.Book.ID = mr.BookID
+		rankings = append(rankings, mr)
+	}
+	return rankings, rows.Err()
+}
+
+func (r rankingRepo) AllTime(ctx context.Context, limit int) ([]models.AllTimeRanking, error) {
+	rows, err := r.q.QueryContext(ctx, `
+        SELECT b.id, b.title, b.author, b.type,
+               COALESCE(SUM(mr.borrow_count), 0) as total_borrow_count
+        FROM books b
+        LEFT JOIN monthly_rankings mr ON b.id = mr.book_id
+        GROUP BY b.id, b.title, b.author, b.type
+        HAVING COALESCE(SUM(mr.borrow_count), 0) > 0
+        ORDER BY total_borrow_count DESC
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rankings []models.AllTimeRanking
+	for rows.Next() {
+		var ar models.AllTimeRanking
+		err := rows.Scan(&ar.BookID, &ar.Book.Title, &ar.Book.Author, &ar.Book.Type, &ar.BorrowCount)
+		if err != nil {
+			return nil, err
+		}
+		ar.Book.ID = ar.BookID
+		rankings = append(rankings, ar)
+	}
+	return rankings, rows.Err()
+}
+
+func (r rankingRepo) DeleteByBook(ctx context.Context, bookID uuid.UUID) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM monthly_rankings WHERE book_id = $1", bookID)
+	return err
+}
@@ -0,0 +1,94 @@
+// Package postgres は repository インターフェースの PostgreSQL 実装を提供する。
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"lablib/repository"
+
+	"github.com/lib/pq"
+)
+
+// querier は *sql.DB と *sql.Tx の共通部分
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Store - PostgreSQL をバックエンドとする repository.Store
+type Store struct {
+	db *sql.DB
+	q  querier
+}
+
+// NewStore は db を使う Store を返す
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, q: db}
+}
+
+func (s *Store) Books() repository.BookRepository             { return bookRepo{s.q} }
+func (s *Store) Copies() repository.CopyRepository            { return copyRepo{s.q} }
+func (s *Store) Loans() repository.LoanRepository             { return loanRepo{s.q} }
+func (s *Store) Users() repository.UserRepository             { return userRepo{s.q} }
+func (s *Store) Rankings() repository.RankingRepository       { return rankingRepo{s.q} }
+func (s *Store) Theses() repository.ThesisRepository          { return thesisRepo{s.q} }
+func (s *Store) Attachments() repository.AttachmentRepository { return attachmentRepo{s.q} }
+func (s *Store) MetadataCache() repository.MetadataCacheRepository {
+	return metadataCacheRepo{s.q}
+}
+func (s *Store) ImportJobs() repository.ImportJobRepository { return importJobRepo{s.q} }
+func (s *Store) CoverJobs() repository.CoverJobRepository   { return coverJobRepo{s.q} }
+func (s *Store) Barcodes() repository.BarcodeRepository     { return barcodeRepo{s.q} }
+
+// WithTx は fn をトランザクション内で実行する。既にトランザクション内であればそのまま実行する。
+func (s *Store) WithTx(ctx context.Context, fn func(tx repository.Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Store{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// notFound は sql.ErrNoRows を repository.ErrNotFound に変換する
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// conflict は一意制約違反を repository.ErrConflict に変換する
+func conflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return repository.ErrConflict
+	}
+	return err
+}
+
+// affected は更新件数が 0 の場合に repository.ErrNotFound を返す
+func affected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
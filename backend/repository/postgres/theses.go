@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+
+	"lablib/models"
+	"lablib/repository"
+	"lablib/search"
+
+	"github.com/google/uuid"
+)
+
+type thesisRepo struct{ q querier }
+
+const thesisColumns = `t.book_id, t.academic_year, t.degree, t.student_id, t.pdf_path, t.created_at, t.updated_at, ` + bookColumns
+
+// thesisScanner は thesisColumns の順序で論文と書籍を読み取る
+type thesisScanner struct {
+	thesis models.Thesis
+	book   bookScanner
+}
+
+func (s *thesisScanner) dest() []interface{} {
+	return append([]interface{}{
+		&s.thesis.BookID, &s.thesis.AcademicYear, &s.thesis.Degree, &s.thesis.StudentID,
+		&s.thesis.PDFPath, &s.thesis.CreatedAt, &s.thesis.UpdatedAt,
+	}, s.book.dest()...)
+}
+
+func (s *thesisScanner) result() models.Thesis {
+	s.thesis.Book = s.book.result()
+	return s.thesis
+}
+
+// thesisSortColumns は repository.ThesisSortFields と列の対応
+var thesisSortColumns = map[string]string{
+	"academic_year": "t.academic_year",
+	"title":         "b.title",
+	"author":        "b.author",
+	"student_id":    "t.student_id",
+	"created_at":    "t.created_at",
+}
+
+func (r thesisRepo) List(ctx context.Context, filter repository.ThesisFilter) ([]models.Thesis, int, error) {
+	c := bookConditions(repository.BookFilter{}, search.Parse(filter.Query))
+	if filter.AcademicYear != 0 {
+		c.add("t.academic_year = ?", filter.AcademicYear)
+	}
+	if filter.Degree != "" {
+		c.add("t.degree = ?", filter.Degree)
+	}
+	if filter.Supervisor != "" {
+		c.add("EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id AND ba.role = 'supervisor' AND ba.name = ?)",
+			filter.Supervisor)
+	}
+	const from = ` FROM theses t JOIN books b ON b.id = t.book_id`
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*)`+from+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sort := filter.Sort
+	if sort.Field == "" {
+		sort = repository.Sort{Field: "academic_year", Desc: true}
+	}
+	order, err := orderBy(sort, thesisSortColumns, "academic_year", "b.title, b.id")
+	if err != nil {
+		return nil, 0, err
+	}
+	theses, err := r.query(ctx, `SELECT `+thesisColumns+from+c.where()+order+limitOffset(filter.Page), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return theses, total, nil
+}
+
+// query は論文を読み取り、書籍の著者を埋めて返す
+func (r thesisRepo) query(ctx context.Context, query string, args ...interface{}) ([]models.Thesis, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var theses []models.Thesis
+	for rows.Next() {
+		var s thesisScanner
+		if err := rows.Scan(s.dest()...); err != nil {
+			return nil, err
+		}
+		theses = append(theses, s.result())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(theses))
+	for i, t := range theses {
+		ids[i] = t.BookID
+	}
+	authors, err := bookRepo{r.q}.authors(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range theses {
+		theses[i].Book.Authors = authors[theses[i].BookID]
+	}
+	return theses, nil
+}
+
+func (r thesisRepo) getBy(ctx context.Context, where string, args ...interface{}) (*models.Thesis, error) {
+	theses, err := r.query(ctx, `SELECT `+thesisColumns+` FROM theses t JOIN books b ON b.id = t.book_id WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(theses) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &theses[0], nil
+}
+
+func (r thesisRepo) Get(ctx context.Context, bookID uuid.UUID) (*models.Thesis, error) {
+	return r.getBy(ctx, "t.book_id = $1", bookID)
+}
+
+func (r thesisRepo) FindByStudent(ctx context.Context, academicYear int, studentID string) (*models.Thesis, error) {
+	return r.getBy(ctx, "t.academic_year = $1 AND t.student_id = $2", academicYear, studentID)
+}
+
+func (r thesisRepo) Create(ctx context.Context, thesis *models.Thesis) error {
+	_, err := r.q.ExecContext(ctx, `
+        INSERT INTO theses (book_id, academic_year, degree, student_id, pdf_path, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, thesis.BookID, thesis.AcademicYear, thesis.Degree, thesis.StudentID, thesis.PDFPath,
+		thesis.CreatedAt, thesis.UpdatedAt)
+	return conflict(err)
+}
+
+func (r thesisRepo) Update(ctx context.Context, thesis *models.Thesis) error {
+	res, err := r.q.ExecContext(ctx, `
+        UPDATE theses SET academic_year = $1, degree = $2, student_id = $3, updated_at = $4
+        WHERE book_id = $5
+    `, thesis.AcademicYear, thesis.Degree, thesis.StudentID, thesis.UpdatedAt, thesis.BookID)
+	return affected(res, conflict(err))
+}
+
+func (r thesisRepo) SetPDFPath(ctx context.Context, bookID uuid.UUID, pdfPath string) error {
+	return affected(r.q.ExecContext(ctx,
+		"UPDATE theses SET pdf_path = $1, updated_at = NOW() WHERE book_id = $2", pdfPath, bookID))
+}
+
+func (r thesisRepo) Index(ctx context.Context) (*repository.ThesisIndex, error) {
+	var index repository.ThesisIndex
+	var err error
+	if index.Years, err = r.counts(ctx, `
+        SELECT t.academic_year, COUNT(*) AS n
+        FROM theses t JOIN books b ON b.id = t.book_id WHERE b.withdrawn_at IS NULL
+        GROUP BY t.academic_year ORDER BY t.academic_year DESC`); err != nil {
+		return nil, err
+	}
+	if index.Supervisors, err = r.counts(ctx, `
+        SELECT ba.name, COUNT(DISTINCT t.book_id) AS n
+        FROM theses t JOIN book_authors ba ON ba.book_id = t.book_id AND ba.role = 'supervisor'
+            JOIN books b ON b.id = t.book_id
+        WHERE b.withdrawn_at IS NULL
+        GROUP BY ba.name ORDER BY n DESC, ba.name`); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+func (r thesisRepo) counts(ctx context.Context, query string) ([]repository.FacetCount, error) {
+	rows, err := r.q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []repository.FacetCount{}
+	for rows.Next() {
+		var fc repository.FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, fc)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"lablib/models"
+	"lablib/repository"
+
+	"github.com/google/uuid"
+)
+
+type userRepo struct{ q querier }
+
+const userColumns = `id, student_id, name, email, password, role, status, deactivated_at, anonymized_at, anonymized_by, created_at, updated_at,
+	notify_due_reminder, notify_overdue`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.StudentID, &u.Name, &u.Email, &u.Password, &u.Role, &u.Status, &u.DeactivatedAt, &u.AnonymizedAt, &u.AnonymizedBy, &u.CreatedAt, &u.UpdatedAt,
+		&u.Notifications.DueReminder, &u.Notifications.Overdue)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &u, nil
+}
+
+func (r userRepo) Create(ctx context.Context, u *models.User) error {
+	u.Notifications = models.DefaultNotificationPreferences
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO users (id, student_id, name, email, password, role, status, deactivated_at, created_at, updated_at,
+		    notify_due_reminder, notify_overdue)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`,
+		u.ID, u.StudentID, u.Name, u.Email, u.Password,
+		u.Role, u.Status, u.DeactivatedAt, u.CreatedAt, u.UpdatedAt,
+		u.Notifications.DueReminder, u.Notifications.Overdue,
+	)
+	return conflict(err)
+}
+
+func (r userRepo) Get(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return scanUser(r.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+func (r userRepo) GetByStudentID(ctx context.Context, studentID string) (*models.User, error) {
+	return scanUser(r.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE student_id = $1`, studentID))
+}
+
+// userSortColumns は repository.UserSortFields と列の対応
+var userSortColumns = map[string]string{
+	"student_id": "student_id",
+	"name":       "name",
+	"role":       "role",
+	"created_at": "created_at",
+}
+
+func (r userRepo) List(ctx context.Context, filter repository.UserFilter) ([]models.User, int, error) {
+	var c conditions
+	if filter.Query != "" {
+		c.add(`(student_id ILIKE ? ESCAPE '\' OR name ILIKE ? ESCAPE '\')`, repeat("%"+escapeLike(filter.Query)+"%", 2)...)
+	}
+	if filter.Role != "" {
+		c.add("role = ?", filter.Role)
+	}
+	if filter.Status != "" {
+		c.add("status = ?", filter.Status)
+	}
+
+	order, err := orderBy(filter.Sort, userSortColumns, "student_id", "id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, `SELECT `+userColumns+` FROM users`+c.where()+order+limitOffset(filter.Page), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *u)
+	}
+	return users, total, rows.Err()
+}
+
+func (r userRepo) Update(ctx context.Context, u *models.User) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE users
+		SET name = $2, email = $3, role = $4, status = $5, deactivated_at = $6, updated_at = $7,
+		    notify_due_reminder = $8, notify_overdue = $9, student_id = $10
+		WHERE id = $1
+	`, u.ID, u.Name, u.Email, u.Role, u.Status, u.DeactivatedAt, u.UpdatedAt,
+		u.Notifications.DueReminder, u.Notifications.Overdue, u.StudentID)
+	return affected(res, conflict(err))
+}
+
+func (r userRepo) SetPassword(ctx context.Context, id uuid.UUID, password string) error {
+	res, err := r.q.ExecContext(ctx, "UPDATE users SET password = $2, updated_at = NOW() WHERE id = $1", id, password)
+	return affected(res, err)
+}
+
+func (r userRepo) Anonymize(ctx context.Context, id uuid.UUID, studentID string, by *uuid.UUID, at time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE users
+		SET student_id = $2, name = '', email = '', password = '',
+		    status = CASE WHEN status = 'active' THEN 'deactivated' ELSE status END,
+		    deactivated_at = COALESCE(deactivated_at, $3), anonymized_at = $3, anonymized_by = $4, updated_at = $3
+		WHERE id = $1
+	`, id, studentID, at, by)
+	if err := affected(res, conflict(err)); err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, "DELETE FROM user_invites WHERE user_id = $1", id)
+	return err
+}
+
+func (r userRepo) CreateInvite(ctx context.Context, invite *models.UserInvite) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO user_invites (token_hash, user_id, expires_at, used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, invite.TokenHash, invite.UserID, invite.ExpiresAt, invite.UsedAt, invite.CreatedAt)
+	return conflict(err)
+}
+
+func (r userRepo) GetInvite(ctx context.Context, tokenHash string) (*models.UserInvite, error) {
+	var invite models.UserInvite
+	err := r.q.QueryRowContext(ctx, `
+		SELECT token_hash, user_id, expires_at, used_at, created_at
+		FROM user_invites WHERE token_hash = $1
+		FOR UPDATE
+	`, tokenHash).Scan(&invite.TokenHash, &invite.UserID, &invite.ExpiresAt, &invite.UsedAt, &invite.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &invite, nil
+}
+
+func (r userRepo) UseInvite(ctx context.Context, tokenHash string, usedAt time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE user_invites SET used_at = $2
+		WHERE used_at IS NULL AND user_id = (SELECT user_id FROM user_invites WHERE token_hash = $1)
+	`, tokenHash, usedAt)
+	return affected(res, err)
+}
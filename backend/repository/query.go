@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"lablib/models"
+	"lablib/search"
+
+	"github.com/google/uuid"
+)
+
+// Page は一覧取得の範囲。Limit が0の場合は Offset 以降を全件返す。
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Sort は一覧の並び順。Field は各 *SortFields に含まれる名前のみ指定できる。
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// 並び替えに使えるフィールド。API のクエリパラメーターの許可リストを兼ねる。
+var (
+	BookSortFields    = []string{"relevance", "title", "author", "type", "location", "available_copies", "created_at", "updated_at"}
+	LoanSortFields    = []string{"borrowed_at", "due_date", "returned_at", "status", "user_name", "book_title"}
+	UserSortFields    = []string{"student_id", "name", "role", "created_at"}
+	ThesisSortFields  = []string{"academic_year", "title", "author", "student_id", "created_at"}
+	BarcodeSortFields = []string{"filename", "code", "created_at", "size"}
+)
+
+// ImportRowFilter は取り込みの行ごとの結果の検索条件
+type ImportRowFilter struct {
+	JobID  uuid.UUID
+	Status string // 空の場合はすべて
+	Page   Page
+}
+
+// CoverResultFilter は表紙画像の取得の書籍ごとの結果の検索条件
+type CoverResultFilter struct {
+	JobID  uuid.UUID
+	Status string // 空の場合はすべて
+	Page   Page
+}
+
+// BarcodeFilter は保存済みバーコードの一覧の条件。ゼロ値の項目は条件に含めない。既定の並び順はファイル名順。
+// Year・StudentID は生成時の指定（Barcode.Params）と比べる。
+type BarcodeFilter struct {
+	Code      string // 前方一致
+	Symbology string
+	BookID    uuid.UUID
+	CopyID    uuid.UUID
+	CreatedBy uuid.UUID
+	Year      string
+	StudentID string
+	Sort      Sort
+	Page      Page
+}
+
+// BookFilter は書籍一覧の検索条件
+type BookFilter struct {
+	Query     string // search.Parse の形式の検索文字列。指定時の既定の並び順は relevance（関連度順）
+	Type      string
+	Location  string
+	Author    string // いずれかの著者の氏名の完全一致（ファセットの値で絞り込む場合に使う）
+	Year      int    // 出版年
+	Publisher string
+	Language  string
+	Subject   string // 件名のいずれかと完全一致
+	Available *bool  // true: 貸出可能なコピーがある書籍のみ、false: ない書籍のみ
+	Withdrawn bool   // true: 除籍した書籍のみ、false: 除籍していない書籍のみ
+	HasImage  *bool  // true: 画像のある書籍のみ、false: ない書籍のみ
+	Sort      Sort
+	Page      Page
+}
+
+// FacetCount はファセットの値1つと該当する書籍数
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// BookFacets は検索結果の項目ごとの件数。各項目は件数の多い順（同数は値の順）で、
+// Year は新しい年順、Availability は "available"・"unavailable" の順に並ぶ。
+// 件数が0の値は含めない。Author は共著の書籍を著者ごとに数える。
+type BookFacets struct {
+	Type         []FacetCount
+	Location     []FacetCount
+	Author       []FacetCount
+	Year         []FacetCount
+	Availability []FacetCount
+}
+
+// FacetLimit は件数の多い値だけを返すファセット（著者・配架場所）の上限
+const FacetLimit = 20
+
+// LoanFilter は貸出記録一覧の検索条件。ゼロ値の項目は条件に含めない。
+type LoanFilter struct {
+	UserID       uuid.UUID
+	BookID       uuid.UUID
+	Status       string    // "borrowed" または "returned"
+	BorrowedFrom time.Time // 貸出日時がこの時刻以降
+	BorrowedTo   time.Time // 貸出日時がこの時刻より前
+	Overdue      bool      // 返却期限を過ぎた未返却の貸出のみ
+	Sort         Sort
+	Page         Page
+}
+
+// AttachmentStatsFilter は添付ファイルのダウンロード集計の条件。ゼロ値の項目は条件に含めない。
+type AttachmentStatsFilter struct {
+	BookID uuid.UUID
+	From   time.Time // ダウンロード日時がこの時刻以降
+	To     time.Time // ダウンロード日時がこの時刻より前
+	Limit  int
+}
+
+// UserFilter はユーザー一覧の検索条件
+type UserFilter struct {
+	Query  string // 学籍番号・氏名の部分一致
+	Role   string
+	Status string
+	Sort   Sort
+	Page   Page
+}
+
+// ThesisFilter は論文一覧の検索条件。ゼロ値の項目は条件に含めない。既定の並び順は年度の新しい順。
+type ThesisFilter struct {
+	Query        string // BookFilter.Query と同じ形式（要旨・キーワードも対象）
+	AcademicYear int
+	Degree       string
+	Supervisor   string // 指導教員の氏名の完全一致
+	Sort         Sort
+	Page         Page
+}
+
+// ThesisIndex は論文の閲覧用の索引。Years は新しい年度順、Supervisors は論文数の多い順（同数は氏名順）。
+type ThesisIndex struct {
+	Years       []FacetCount
+	Supervisors []FacetCount
+}
+
+// SearchDocument は書籍の検索索引の対象となるフィールドを返す。
+// 著者には Author（役割が著者の氏名のみ）ではなく Authors の全員を含め、編者・指導教員の氏名でも検索できるようにする。
+func SearchDocument(b models.Book) search.Document {
+	author := b.Author
+	if len(b.Authors) > 0 {
+		names := make([]string, len(b.Authors))
+		for i, a := range b.Authors {
+			names[i] = a.Name
+		}
+		author = strings.Join(names, ", ")
+	}
+	return search.Document{
+		Title:       b.Title,
+		Author:      author,
+		Codes:       []string{b.ISBN, b.JAN, b.EAN13, b.Barcode},
+		Subjects:    b.Subjects,
+		Publisher:   b.Publisher,
+		Description: b.Description,
+	}
+}
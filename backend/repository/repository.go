@@ -0,0 +1,210 @@
+// Package repository はハンドラーとデータストアを分離するためのインターフェースを定義する。
+// 実装は repository/postgres（本番用）と repository/memory（テスト・開発用）にある。
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"lablib/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound は対象のレコードが存在しない場合に返される
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict は一意制約に違反する場合に返される
+	ErrConflict = errors.New("record already exists")
+)
+
+// BookRepository - 書籍（books）の永続化
+type BookRepository interface {
+	// Search は filter に一致する書籍のうち filter.Page の範囲と、一致した総件数を返す
+	Search(ctx context.Context, filter BookFilter) ([]models.BookSummary, int, error)
+	// Facets は filter の条件（Sort・Page は無視）に一致する書籍の項目ごとの件数を返す
+	Facets(ctx context.Context, filter BookFilter) (*BookFacets, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Book, error)
+	// FindByCodes は ISBN・JAN・EAN13 のいずれかが keys（codes.Clean で正規化したコード）に一致する書籍を登録順に返す。
+	// 登録済みの値はハイフン・空白を除き、英字を大文字にして比較する。
+	FindByCodes(ctx context.Context, keys []string) ([]models.Book, error)
+	Create(ctx context.Context, book *models.Book) error
+	Update(ctx context.Context, book *models.Book) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	SetImagePath(ctx context.Context, id uuid.UUID, imagePath string) error
+	// SetWithdrawn は書籍を除籍する（withdrawnAt が nil の場合は除籍を取り消す）
+	SetWithdrawn(ctx context.Context, id uuid.UUID, withdrawnAt *time.Time, reason string) error
+	// RebuildSearchIndex は検索索引が未作成の書籍の索引を作成し、作成した件数を返す
+	RebuildSearchIndex(ctx context.Context) (int, error)
+}
+
+// CopyRepository - 書籍コピー（book_copies）の永続化
+type CopyRepository interface {
+	Create(ctx context.Context, copy *models.BookCopy) error
+	Get(ctx context.Context, id uuid.UUID) (*models.BookCopy, error)
+	// ListByBook は書籍のコピーを登録順に返す
+	ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.BookCopy, error)
+	FindByBarcode(ctx context.Context, barcode string) (*models.BookCopy, error)
+	// FindAvailable* はトランザクション内で呼ばれた場合、取得したコピーを行ロックする
+	FindAvailableByBarcode(ctx context.Context, barcode string) (*models.BookCopy, error)
+	FindAvailableByBook(ctx context.Context, bookID uuid.UUID) (*models.BookCopy, error)
+	CountAvailableByBook(ctx context.Context, bookID uuid.UUID) (int, error)
+	SetAvailable(ctx context.Context, id uuid.UUID, available bool) error
+	// SetBarcodeByBook は書籍のすべてのコピーのバーコードを barcode にする
+	SetBarcodeByBook(ctx context.Context, bookID uuid.UUID, barcode string) error
+	// DeleteAvailable は貸出中でないコピーを最大 n 件削除する
+	DeleteAvailable(ctx context.Context, bookID uuid.UUID, n int) error
+	DeleteByBook(ctx context.Context, bookID uuid.UUID) error
+}
+
+// LoanRepository - 貸出記録（borrow_records）の永続化。
+// 取得系メソッドは Book・BookCopy・User の表示用フィールドを埋めて返す。
+type LoanRepository interface {
+	Create(ctx context.Context, record *models.BorrowRecord) error
+	Get(ctx context.Context, id uuid.UUID) (*models.BorrowRecord, error)
+	FindOpen(ctx context.Context, copyID, userID uuid.UUID) (*models.BorrowRecord, error)
+	// List は filter に一致する貸出記録のうち filter.Page の範囲と、一致した総件数を返す
+	List(ctx context.Context, filter LoanFilter) ([]models.BorrowRecord, int, error)
+	ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.BorrowRecord, error)
+	MarkReturned(ctx context.Context, id uuid.UUID, returnedAt time.Time) error
+	UpdateDueDate(ctx context.Context, id uuid.UUID, dueDate time.Time, renewCount int) error
+	CountOpenByBook(ctx context.Context, bookID uuid.UUID) (int, error)
+	CountOpenByUser(ctx context.Context, userID uuid.UUID) (int, error)
+	DeleteReturnedByBook(ctx context.Context, bookID uuid.UUID) error
+}
+
+// UserRepository - ユーザー（users）の永続化
+type UserRepository interface {
+	// Create は学籍番号が重複する場合 ErrConflict を返す。通知の設定は既定値（models.DefaultNotificationPreferences）にする。
+	Create(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByStudentID(ctx context.Context, studentID string) (*models.User, error)
+	// List は filter に一致するユーザーのうち filter.Page の範囲と、一致した総件数を返す
+	List(ctx context.Context, filter UserFilter) ([]models.User, int, error)
+	// Update は学籍番号・氏名・メールアドレス・ロール・状態・通知の設定を更新する（パスワードは変更しない）。
+	// 学籍番号が他のユーザーと重複する場合 ErrConflict を返す。
+	Update(ctx context.Context, user *models.User) error
+	SetPassword(ctx context.Context, id uuid.UUID, password string) error
+	// Anonymize は学籍番号を studentID に置き換え、氏名・メールアドレス・パスワードを消して利用停止にし、招待を削除する。
+	// 匿名化した管理者 by と日時 at を記録する。
+	// ユーザーの行は残すため、貸出の履歴は匿名のユーザーの記録として残る。
+	Anonymize(ctx context.Context, id uuid.UUID, studentID string, by *uuid.UUID, at time.Time) error
+
+	CreateInvite(ctx context.Context, invite *models.UserInvite) error
+	// GetInvite はトークンのハッシュから招待を返す。トランザクション内で呼ばれた場合は行ロックする。
+	GetInvite(ctx context.Context, tokenHash string) (*models.UserInvite, error)
+	// UseInvite は招待を使用済みにし、同じユーザーの未使用の招待を無効にする
+	UseInvite(ctx context.Context, tokenHash string, usedAt time.Time) error
+}
+
+// RankingRepository - 月次ランキング（monthly_rankings）の永続化
+type RankingRepository interface {
+	Increment(ctx context.Context, month string, bookID uuid.UUID) error
+	Monthly(ctx context.Context, month string, limit int) ([]models.MonthlyRanking, error)
+	AllTime(ctx context.Context, limit int) ([]models.AllTimeRanking, error)
+	DeleteByBook(ctx context.Context, bookID uuid.UUID) error
+}
+
+// ThesisRepository - 論文固有の情報（theses）の永続化。
+// 取得系メソッドは Book（著者を含む）を埋めて返す。書籍の削除時には合わせて削除される。
+type ThesisRepository interface {
+	// List は filter に一致する論文（除籍した書籍を除く）のうち filter.Page の範囲と、一致した総件数を返す
+	List(ctx context.Context, filter ThesisFilter) ([]models.Thesis, int, error)
+	Get(ctx context.Context, bookID uuid.UUID) (*models.Thesis, error)
+	FindByStudent(ctx context.Context, academicYear int, studentID string) (*models.Thesis, error)
+	// Create は同じ年度・学籍番号の論文がある場合 ErrConflict を返す
+	Create(ctx context.Context, thesis *models.Thesis) error
+	Update(ctx context.Context, thesis *models.Thesis) error
+	SetPDFPath(ctx context.Context, bookID uuid.UUID, pdfPath string) error
+	// Index は年度・指導教員ごとの論文数（除籍した書籍を除く）を返す
+	Index(ctx context.Context) (*ThesisIndex, error)
+}
+
+// AttachmentRepository - 書籍の添付ファイル（book_attachments）とダウンロード記録の永続化。
+// 取得系メソッドは Downloads（ダウンロード回数）を埋めて返す。書籍の削除時には合わせて削除される。
+type AttachmentRepository interface {
+	// ListByBook は書籍の添付ファイルを登録順に返す
+	ListByBook(ctx context.Context, bookID uuid.UUID) ([]models.Attachment, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Attachment, error)
+	Create(ctx context.Context, attachment *models.Attachment) error
+	// Update は公開範囲・説明・ファイル名を更新する
+	Update(ctx context.Context, attachment *models.Attachment) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	RecordDownload(ctx context.Context, download *models.AttachmentDownload) error
+	// Stats は filter に一致するダウンロードを添付ファイルごとに集計し、ダウンロード数の多い順に返す
+	Stats(ctx context.Context, filter AttachmentStatsFilter) ([]models.AttachmentStats, error)
+}
+
+// MetadataCacheRepository - 外部の書誌情報サービスへの問い合わせ結果（book_metadata_cache）の永続化
+type MetadataCacheRepository interface {
+	Get(ctx context.Context, isbn, provider string) (*models.MetadataCacheEntry, error)
+	// Put は同じ ISBN・プロバイダーの結果があれば置き換える
+	Put(ctx context.Context, entry *models.MetadataCacheEntry) error
+}
+
+// ImportJobRepository - 書籍の一括取り込みジョブ（import_jobs）と行ごとの結果（import_job_rows）の永続化
+type ImportJobRepository interface {
+	Create(ctx context.Context, job *models.ImportJob) error
+	Get(ctx context.Context, id uuid.UUID) (*models.ImportJob, error)
+	// List はジョブを新しい順に返す
+	List(ctx context.Context, page Page) ([]models.ImportJob, int, error)
+	// ListUnfinished は実行待ち・実行中のジョブを古い順に返す
+	ListUnfinished(ctx context.Context) ([]models.ImportJob, error)
+	// Update は状態・件数・エラー・開始終了時刻を更新する
+	Update(ctx context.Context, job *models.ImportJob) error
+	// AddRow は行の結果を保存する。同じ行の結果が既にあれば ErrConflict を返す。
+	AddRow(ctx context.Context, row *models.ImportRow) error
+	// Rows は filter に一致する行の結果を行番号順に返す
+	Rows(ctx context.Context, filter ImportRowFilter) ([]models.ImportRow, int, error)
+}
+
+// CoverJobRepository - 表紙画像の取得ジョブ（cover_jobs）と書籍ごとの結果（cover_job_results）の永続化
+type CoverJobRepository interface {
+	Create(ctx context.Context, job *models.CoverJob) error
+	Get(ctx context.Context, id uuid.UUID) (*models.CoverJob, error)
+	// List はジョブを新しい順に返す
+	List(ctx context.Context, page Page) ([]models.CoverJob, int, error)
+	// ListUnfinished は実行待ち・実行中のジョブを古い順に返す
+	ListUnfinished(ctx context.Context) ([]models.CoverJob, error)
+	// Update は状態・件数・エラー・開始終了時刻を更新する
+	Update(ctx context.Context, job *models.CoverJob) error
+	// AddResult は書籍の結果を保存する。同じ順番の結果が既にあれば ErrConflict を返す。
+	AddResult(ctx context.Context, result *models.CoverResult) error
+	// Results は filter に一致する結果を処理した順に返す
+	Results(ctx context.Context, filter CoverResultFilter) ([]models.CoverResult, int, error)
+}
+
+// BarcodeRepository - 生成したバーコード（barcodes）の永続化。書籍・コピーの削除時には BookID・CopyID が nil になる。
+type BarcodeRepository interface {
+	// List は filter の並び順のうち filter.Page の範囲と総件数を返す
+	List(ctx context.Context, filter BarcodeFilter) ([]models.Barcode, int, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Barcode, error)
+	// FindByCode は種類・コードが一致するバーコードを返す。ない場合は ErrNotFound を返す。
+	FindByCode(ctx context.Context, symbology, code string) (*models.Barcode, error)
+	// Create は同じ storage_path のバーコード、または同じ種類・コード（空でない）のバーコードがある場合 ErrConflict を返す
+	Create(ctx context.Context, barcode *models.Barcode) error
+	// SetLinks は関連付ける書籍・コピーを bookID・copyID にする
+	SetLinks(ctx context.Context, id uuid.UUID, bookID, copyID *uuid.UUID) error
+	// SetParams は生成時の指定を params にする
+	SetParams(ctx context.Context, id uuid.UUID, params models.BarcodeParams) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// Store は各リポジトリをまとめ、トランザクション境界を提供する
+type Store interface {
+	Books() BookRepository
+	Copies() CopyRepository
+	Loans() LoanRepository
+	Users() UserRepository
+	Rankings() RankingRepository
+	Theses() ThesisRepository
+	Attachments() AttachmentRepository
+	MetadataCache() MetadataCacheRepository
+	ImportJobs() ImportJobRepository
+	CoverJobs() CoverJobRepository
+	Barcodes() BarcodeRepository
+
+	// WithTx は fn をトランザクション内で実行する。fn がエラーを返した場合はロールバックする。
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
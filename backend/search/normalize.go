@@ -0,0 +1,131 @@
+// Package search は蔵書検索のための正規化・分かち書き・クエリ解析を提供する。
+// 日本語は形態素解析を行わず、文字の unigram と bigram を索引語とする。
+// PostgreSQL の全文検索（tsvector）とインメモリ実装の両方がこのパッケージの結果を使うため、
+// どちらのデータストアでも同じ検索結果になる。
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// kanjiVariants は人名・書名でよく使われる異体字と常用字の対応
+var kanjiVariants = map[rune]rune{
+	'髙': '高', '﨑': '崎', '嵜': '崎', '邊': '辺', '邉': '辺',
+	'齋': '斎', '齊': '斉', '濱': '浜', '澤': '沢', '櫻': '桜',
+	'國': '国', '學': '学', '廣': '広', '德': '徳', '槇': '槙',
+}
+
+// Normalize は検索用に文字列を正規化する。
+//   - NFKC による全角英数字・半角カナの統一（"ＡＢＣ" → "abc"、"ｶﾞ" → "ガ"）
+//   - 英字の小文字化
+//   - ひらがなをカタカナに統一
+//   - 異体字を常用字に統一
+//   - 連続する空白を1つにまとめる
+func Normalize(s string) string {
+	s = norm.NFKC.String(s)
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(foldRune(r))
+	}
+	return b.String()
+}
+
+func foldRune(r rune) rune {
+	switch {
+	case r >= 'ぁ' && r <= 'ゖ', r == 'ゝ', r == 'ゞ':
+		return r + 0x60
+	case r < unicode.MaxASCII:
+		return unicode.ToLower(r)
+	}
+	if v, ok := kanjiVariants[r]; ok {
+		return v
+	}
+	return unicode.ToLower(r)
+}
+
+// isCJK は分かち書きせずに n-gram で索引する文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hiragana, r) || r == 'ー' || r == '々'
+}
+
+// isWord は英数字の語を構成する文字
+func isWord(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r)
+}
+
+// segment は正規化済みの s を英数字の語と日本語の連なりに分ける
+func segment(s string) (words []string, runs [][]rune) {
+	var word []rune
+	var run []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+		if len(run) > 0 {
+			runs = append(runs, run)
+			run = nil
+		}
+	}
+	for _, r := range s {
+		switch {
+		case isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			run = append(run, r)
+		case isWord(r):
+			if len(run) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words, runs
+}
+
+// IndexTokens は s を索引語に分ける。英数字は語単位、日本語は unigram と bigram。
+func IndexTokens(s string) []string {
+	words, runs := segment(Normalize(s))
+	tokens := words
+	for _, run := range runs {
+		for i := range run {
+			tokens = append(tokens, string(run[i]))
+			if i+1 < len(run) {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+	}
+	return tokens
+}
+
+// queryTokens は検索語を索引語に分ける。日本語は2文字以上なら bigram のみ、1文字なら unigram を使う。
+func queryTokens(s string) (words, grams []string) {
+	words, runs := segment(Normalize(s))
+	for _, run := range runs {
+		if len(run) == 1 {
+			grams = append(grams, string(run))
+			continue
+		}
+		for i := 0; i+1 < len(run); i++ {
+			grams = append(grams, string(run[i:i+2]))
+		}
+	}
+	return words, grams
+}
@@ -0,0 +1,41 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"ＡＢＣ　ｄｅｆ１２３", "abc def123"}, // NFKC で全角英数字・全角空白を統一
+		{"ｶﾞｯｺｳ", "ガッコウ"},            // 半角カナ
+		{"がっこう", "ガッコウ"},             // ひらがなはカタカナに
+		{"いすゞ ゝ", "イスヾ ヽ"},
+		{"髙橋 邊 﨑", "高橋 辺 崎"}, // 異体字
+		{"Go言語", "go言語"},
+		{"  a \t\n b  ", "a b"},
+		{"", ""},
+	} {
+		if got := Normalize(tc.in); got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestIndexTokens(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"Go言語 入門", []string{"go", "言", "言語", "語", "入", "入門", "門"}},
+		{"ＳＱＬ実践", []string{"sql", "実", "実践", "践"}},
+		{"O'Reilly", []string{"o", "reilly"}},
+		{"！？", nil},
+	} {
+		if got := IndexTokens(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("IndexTokens(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
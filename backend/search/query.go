@@ -0,0 +1,350 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"lablib/codes"
+)
+
+// 検索対象のフィールド
+const (
+	FieldAny    = ""
+	FieldTitle  = "title"
+	FieldAuthor = "author"
+	FieldCode   = "code" // ISBN・JAN・EAN13・バーコード
+)
+
+// fieldAliases はクエリ中の "author:" などの修飾子とフィールドの対応
+var fieldAliases = map[string]string{
+	"title":   FieldTitle,
+	"タイトル":    FieldTitle,
+	"書名":      FieldTitle,
+	"author":  FieldAuthor,
+	"著者":      FieldAuthor,
+	"isbn":    FieldCode,
+	"jan":     FieldCode,
+	"ean":     FieldCode,
+	"code":    FieldCode,
+	"barcode": FieldCode,
+}
+
+// フィールドごとの tsvector の重み。PostgreSQL の ts_rank の既定値（A=1.0, B=0.4, C=0.2, D=0.1）で順位付けされる。
+// 件名・出版社・内容紹介は修飾子で指定できず、件名はコードと同じ C、出版社と内容紹介は D で索引する。
+var fieldWeights = map[string]byte{
+	FieldTitle:  'A',
+	FieldAuthor: 'B',
+	FieldCode:   'C',
+}
+
+// 修飾子のないフィールドの重み
+const (
+	subjectWeight = 'C'
+	otherWeight   = 'D'
+)
+
+// Term は検索語1つ。Field が FieldAny の場合はすべてのフィールドが対象。
+type Term struct {
+	Field string
+	Text  string
+}
+
+// Query は解析済みの検索クエリ。すべての Term に一致する書籍が結果となる（AND検索）。
+type Query struct {
+	Terms []Term
+}
+
+// Parse は検索文字列を解析する。
+//   - 空白（全角空白を含む）で区切った語はすべてを含む書籍に一致する
+//   - "..." で囲んだ部分は空白を含めて1語として扱う
+//   - "author:夏目" のように修飾子を付けるとそのフィールドのみを検索する
+//   - 数字とハイフンのみの語（ハイフン付きISBNなど）はハイフンを除いて前方一致で検索する
+func Parse(s string) Query {
+	var q Query
+	for _, raw := range splitTerms(quotes.Replace(Normalize(s))) {
+		field := FieldAny
+		if name, rest, ok := strings.Cut(raw, ":"); ok && rest != "" {
+			if f, known := fieldAliases[strings.ToLower(name)]; known {
+				field, raw = f, rest
+			}
+		}
+		raw = strings.Trim(raw, `"`)
+		t := Term{Field: field, Text: raw}
+		if !t.empty() {
+			q.Terms = append(q.Terms, t)
+		}
+	}
+	return q
+}
+
+// quotes は全角の引用符を統一する（全角コロンなどは Normalize で半角になる）
+var quotes = strings.NewReplacer("”", `"`, "“", `"`)
+
+// splitTerms は空白で区切る。引用符内の空白は区切りとしない。
+func splitTerms(s string) []string {
+	var terms []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if cur.Len() > 0 {
+				terms = append(terms, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		terms = append(terms, cur.String())
+	}
+	return terms
+}
+
+func looksLikeCode(s string) bool {
+	digits := 0
+	for _, r := range Normalize(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '-' || r == 'x':
+		default:
+			return false
+		}
+	}
+	return digits >= 4
+}
+
+// Empty は検索語が1つもないかを返す
+func (q Query) Empty() bool { return len(q.Terms) == 0 }
+
+func (t Term) empty() bool {
+	words, grams := t.tokens()
+	return len(words) == 0 && len(grams) == 0
+}
+
+// tokens は検索語を前方一致で比べる語と完全一致で比べる bigram に分ける。
+// コードは1つの語として扱い、ハイフンの有無にかかわらず一致させる。
+func (t Term) tokens() (words, grams []string) {
+	if t.Field == FieldCode || (t.Field == FieldAny && looksLikeCode(t.Text)) {
+		if c := codes.Normalize(t.Text); c != "" {
+			return []string{c}, nil
+		}
+		return nil, nil
+	}
+	return queryTokens(t.Text)
+}
+
+// Substring は部分一致で補う検索語を返す。英数字のみの3文字以上の語は、語の途中への一致を
+// search_text の部分一致（pg_trgm の索引を使う）で補う。対象外の場合は空文字列。
+func (t Term) Substring() string {
+	if t.Field != FieldAny {
+		return ""
+	}
+	s := Normalize(t.Text)
+	if len([]rune(s)) < 3 {
+		return ""
+	}
+	for _, r := range s {
+		if !isWord(r) && r != ' ' {
+			return ""
+		}
+	}
+	return s
+}
+
+// Document は索引の対象となる書籍のフィールド
+type Document struct {
+	Title       string
+	Author      string
+	Codes       []string
+	Subjects    []string
+	Publisher   string
+	Description string
+}
+
+// lexeme は重み付きの索引語
+type lexeme struct {
+	text   string
+	weight byte
+}
+
+func (d Document) lexemes() []lexeme {
+	var lx []lexeme
+	for _, tok := range IndexTokens(d.Title) {
+		lx = append(lx, lexeme{tok, fieldWeights[FieldTitle]})
+	}
+	for _, tok := range IndexTokens(d.Author) {
+		lx = append(lx, lexeme{tok, fieldWeights[FieldAuthor]})
+	}
+	for _, code := range d.Codes {
+		if c := codes.Normalize(code); c != "" {
+			lx = append(lx, lexeme{c, fieldWeights[FieldCode]})
+		}
+	}
+	for _, subject := range d.Subjects {
+		for _, tok := range IndexTokens(subject) {
+			lx = append(lx, lexeme{tok, subjectWeight})
+		}
+	}
+	for _, tok := range IndexTokens(d.Publisher + " " + d.Description) {
+		lx = append(lx, lexeme{tok, otherWeight})
+	}
+	return lx
+}
+
+// Text は部分一致検索用の正規化済みテキスト（books.search_text）を返す。
+// 長文になりうる内容紹介は含めない。
+func (d Document) Text() string {
+	parts := []string{Normalize(d.Title), Normalize(d.Author)}
+	for _, code := range d.Codes {
+		if c := codes.Normalize(code); c != "" {
+			parts = append(parts, c)
+		}
+	}
+	for _, subject := range d.Subjects {
+		parts = append(parts, Normalize(subject))
+	}
+	if d.Publisher != "" {
+		parts = append(parts, Normalize(d.Publisher))
+	}
+	return strings.Join(parts, " ")
+}
+
+// TSVector は PostgreSQL の tsvector 型の入力形式（例: 'キカイ':1A 'カイ':2A）を返す。
+// データベースのロケールに依存しないよう、to_tsvector ではなくアプリケーション側で分かち書きする。
+func (d Document) TSVector() string {
+	var b strings.Builder
+	for i, lx := range d.lexemes() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		pos := i + 1
+		if pos > 16383 { // tsvector の位置の上限
+			pos = 16383
+		}
+		b.WriteString(quoteLexeme(lx.text))
+		b.WriteString(":" + strconv.Itoa(pos))
+		b.WriteByte(lx.weight)
+	}
+	return b.String()
+}
+
+// TSQuery は PostgreSQL の tsquery 型の入力形式を返す。英数字の語は前方一致、
+// 修飾子付きの語は該当フィールドの重みに限定する。
+func (t Term) TSQuery() string {
+	weight := ""
+	if w, ok := fieldWeights[t.Field]; ok {
+		weight = string(w)
+	}
+	words, grams := t.tokens()
+	var parts []string
+	for _, w := range words {
+		parts = append(parts, quoteLexeme(w)+":*"+weight)
+	}
+	for _, g := range grams {
+		p := quoteLexeme(g)
+		if weight != "" {
+			p += ":" + weight
+		}
+		parts = append(parts, p)
+	}
+	return "(" + strings.Join(parts, " & ") + ")"
+}
+
+// TSQuery はすべての検索語を AND で結合した tsquery を返す（順位付けに使う）
+func (q Query) TSQuery() string {
+	parts := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		parts[i] = t.TSQuery()
+	}
+	return strings.Join(parts, " & ")
+}
+
+// quoteLexeme は tsvector・tsquery の入力形式で語を引用する
+func quoteLexeme(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(s) + "'"
+}
+
+// Match は d が q のすべての検索語に一致するかを返す（インメモリ実装用。PostgreSQL の条件と同じ判定）
+func (q Query) Match(d Document) bool {
+	lx := d.lexemes()
+	text := d.Text()
+	for _, t := range q.Terms {
+		if !t.matchLexemes(lx) && (t.Substring() == "" || !strings.Contains(text, t.Substring())) {
+			return false
+		}
+	}
+	return true
+}
+
+// Score は d に対する q の関連度を返す。一致した索引語の重みの合計で、タイトルの一致が最も高い。
+func (q Query) Score(d Document) float64 {
+	lx := d.lexemes()
+	score := 0.0
+	for _, t := range q.Terms {
+		words, grams := t.tokens()
+		for _, l := range lx {
+			if !t.weightAllowed(l.weight) {
+				continue
+			}
+			for _, w := range words {
+				if strings.HasPrefix(l.text, w) {
+					score += rankWeight(l.weight)
+				}
+			}
+			for _, g := range grams {
+				if l.text == g {
+					score += rankWeight(l.weight)
+				}
+			}
+		}
+	}
+	return score
+}
+
+func rankWeight(w byte) float64 {
+	switch w {
+	case 'A':
+		return 1.0
+	case 'B':
+		return 0.4
+	case 'C':
+		return 0.2
+	default:
+		return 0.1
+	}
+}
+
+func (t Term) weightAllowed(w byte) bool {
+	want, ok := fieldWeights[t.Field]
+	return !ok || want == w
+}
+
+func (t Term) matchLexemes(lx []lexeme) bool {
+	words, grams := t.tokens()
+	has := func(match func(string) bool) bool {
+		for _, l := range lx {
+			if t.weightAllowed(l.weight) && match(l.text) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, w := range words {
+		if !has(func(s string) bool { return strings.HasPrefix(s, w) }) {
+			return false
+		}
+	}
+	for _, g := range grams {
+		if !has(func(s string) bool { return s == g }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []Term
+	}{
+		{"夏目 漱石", []Term{{FieldAny, "夏目"}, {FieldAny, "漱石"}}},
+		{"author:夏目　こころ", []Term{{FieldAuthor, "夏目"}, {FieldAny, "ココロ"}}},
+		{"著者：夏目", []Term{{FieldAuthor, "夏目"}}}, // 全角コロン
+		{"TITLE:入門 書名:Go", []Term{{FieldTitle, "入門"}, {FieldTitle, "go"}}},
+		{"isbn:978-4-00", []Term{{FieldCode, "978-4-00"}}},
+		{`"machine learning" title:入門`, []Term{{FieldAny, "machine learning"}, {FieldTitle, "入門"}}},
+		{"“機械 学習”", []Term{{FieldAny, "機械 学習"}}},
+		{"unknown:abc", []Term{{FieldAny, "unknown:abc"}}}, // 未知の修飾子は語の一部
+		{"author:", []Term{{FieldAny, "author:"}}},
+		{"title:！？ 　", nil}, // 索引語にならない語は除く
+		{"", nil},
+	} {
+		if got := Parse(tc.in).Terms; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+	if !Parse(" 　").Empty() || Parse("a").Empty() {
+		t.Error("Empty")
+	}
+}
+
+func TestTSQuery(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"機械学習", "('機械' & '械学' & '学習')"},
+		{"title:機械", "('機械':A)"},
+		{"学", "('学')"},
+		{"Go", "('go':*)"},
+		{"author:go", "('go':*B)"},
+		{"978-4-00", "('978400':*)"},
+		{"isbn:4-00 go", "('400':*C) & ('go':*)"},
+		// コードは英数字以外もそのまま索引語になるため、tsquery の演算子や引用符を含んでも1語として引用する
+		{`isbn:a'b\c:*&|!`, `('a''b\\c:*&|!':*C)`},
+		{`code:'`, `('''':*C)`},
+	} {
+		if got := Parse(tc.in).TSQuery(); got != tc.want {
+			t.Errorf("Parse(%q).TSQuery() = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestQuoteLexeme(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"go", "'go'"},
+		{"it's", "'it''s'"},
+		{`a\b`, `'a\\b'`},
+		{`\'`, `'\\'''`},
+		{":*&|!()", "':*&|!()'"},
+		{"", "''"},
+	} {
+		if got := quoteLexeme(tc.in); got != tc.want {
+			t.Errorf("quoteLexeme(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTSVector(t *testing.T) {
+	d := Document{Title: "入門", Author: "O'Brien", Codes: []string{"978-4", "", "a'b"}}
+	want := `'入':1A '入門':2A '門':3A 'o':4B 'brien':5B '9784':6C 'a''b':7C`
+	if got := d.TSVector(); got != want {
+		t.Errorf("TSVector() = %s, want %s", got, want)
+	}
+	if got := d.Text(); got != "入門 o'brien 9784 a'b" {
+		t.Errorf("Text() = %q", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	d := Document{Title: "機械学習プログラミング", Author: "山田 太郎", Codes: []string{"978-4-00-000000-0"}}
+	for _, tc := range []struct {
+		query string
+		want  bool
+	}{
+		{"機械学習", true},
+		{"きかい", false},
+		{"プログラミング 山田", true},
+		{"ぷろぐらみんぐ", true}, // ひらがなでもカタカナに一致する
+		{"title:機械", true},
+		{"author:機械", false},
+		{"学", true},
+		{"習プ", true},
+		{"学機", false},
+		{"9784000", true},
+		{"978-4-00", true},
+		{"isbn:4000", false}, // コードは前方一致のみ
+		{"機械 鈴木", false},
+	} {
+		if got := Parse(tc.query).Match(d); got != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	// 3文字以上の英数字は語の途中にも一致する
+	d = Document{Title: "Programming Go"}
+	for _, tc := range []struct {
+		query string
+		want  bool
+	}{
+		{"prog", true},
+		{"gram", true},
+		{"ra", false},
+		{"title:gram", false},
+		{"ＧＯ", true},
+	} {
+		if got := Parse(tc.query).Match(d); got != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	q := Parse("山田")
+	title := q.Score(Document{Title: "山田の本", Author: "鈴木"})
+	author := q.Score(Document{Title: "本", Author: "山田"})
+	none := q.Score(Document{Title: "本", Author: "鈴木"})
+	if !(title > author && author > none && none == 0) {
+		t.Fatalf("scores: title %v, author %v, none %v", title, author, none)
+	}
+}
@@ -0,0 +1,284 @@
+// Package spreadsheet は CSV と Excel（XLSX）の表を、行ごとのセルの文字列として読み書きする。
+// XLSX は Office Open XML の zip を直接読み書きし、書式・数式・結合セルは扱わない。
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// 表の形式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var (
+	// ErrUnsupported は CSV・XLSX のいずれでもない場合のエラー
+	ErrUnsupported = errors.New("CSV または XLSX ファイルではありません")
+	// ErrSheetNotFound は指定したシートが XLSX にない場合のエラー
+	ErrSheetNotFound = errors.New("シートが見つかりません")
+)
+
+// utf8BOM は Excel が UTF-8 の CSV の先頭に付ける BOM
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// maxPartSize は XLSX 内の1つのファイルを展開する上限（圧縮率の極端なファイル対策）
+var maxPartSize int64 = 200 << 20
+
+// DetectFormat は拡張子と先頭の内容から形式を判定する
+func DetectFormat(filename string, head []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		if bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+			return FormatXLSX, nil
+		}
+	case ".csv", ".txt":
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) && !bytes.ContainsRune(head, 0) {
+			return FormatCSV, nil
+		}
+	}
+	return "", ErrUnsupported
+}
+
+// Read は format の表を読み取る。sheet は XLSX のシート名で、空の場合は最初のシートを読む。
+func Read(format string, r io.ReaderAt, size int64, sheet string) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(io.NewSectionReader(r, 0, size))
+	case FormatXLSX:
+		return ReadXLSX(r, size, sheet)
+	}
+	return nil, ErrUnsupported
+}
+
+// ReadCSV は CSV を読み取る。UTF-8（BOM 付きを含む）として読めない場合は Shift_JIS として読む。
+// 行ごとの列数は揃っていなくてもよい。
+func ReadCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		if data, err = japanese.ShiftJIS.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("文字コードを判定できません: %w", err)
+		}
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	return cr.ReadAll()
+}
+
+// xlsxText は共有文字列・インライン文字列の本文。書式付きの文字列は r ごとに分かれる。
+// ふりがな（rPh）は含めない。
+type xlsxText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.R) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.R {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// ReadXLSX は XLSX の sheet（空の場合は最初のシート）を読み取る。
+// 戻り値の添字は行番号 - 1 で、値のない行は nil になる。
+func ReadXLSX(r io.ReaderAt, size int64, sheet string) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := xlsxSheetPath(files, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			SI []xlsxText `xml:"si"`
+		}
+		if err := decodePart(f, &sst); err != nil {
+			return nil, err
+		}
+		shared = make([]string, len(sst.SI))
+		for i, si := range sst.SI {
+			shared[i] = si.String()
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, ErrSheetNotFound
+	}
+	var ws struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R  string   `xml:"r,attr"`
+				T  string   `xml:"t,attr"`
+				V  string   `xml:"v"`
+				IS xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodePart(f, &ws); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for i, row := range ws.Rows {
+		n := row.R
+		if n < 1 {
+			n = i + 1
+		}
+		for len(rows) < n {
+			rows = append(rows, nil)
+		}
+		var cells []string
+		for j, c := range row.Cells {
+			col := columnIndex(c.R)
+			if col < 0 {
+				col = j
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			cells[col] = cellValue(c.T, c.V, c.IS, shared)
+		}
+		rows[n-1] = cells
+	}
+	return rows, nil
+}
+
+// xlsxSheetPath は名前が sheet のシート（空の場合は最初のシート）の zip 内のパスを返す
+func xlsxSheetPath(files map[string]*zip.File, sheet string) (string, error) {
+	f, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", ErrUnsupported
+	}
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodePart(f, &wb); err != nil {
+		return "", err
+	}
+	rid := ""
+	for _, s := range wb.Sheets {
+		if sheet == "" || s.Name == sheet {
+			rid = s.RID
+			break
+		}
+	}
+	if rid == "" {
+		return "", ErrSheetNotFound
+	}
+
+	f, ok = files["xl/_rels/workbook.xml.rels"]
+	if !ok {
+		return "", ErrUnsupported
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(f, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == rid {
+			// Target は xl/ からの相対パスまたは "/xl/..." の絶対パス
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "", ErrSheetNotFound
+}
+
+// decodePart は zip 内の XML ファイルを v に読み込む
+func decodePart(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// cellValue はセルの型 t に応じて値を文字列にする
+func cellValue(t, v string, is xlsxText, shared []string) string {
+	switch t {
+	case "s":
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i]
+	case "inlineStr":
+		return is.String()
+	case "b":
+		if v == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "str", "e":
+		return v
+	}
+	// 数値。ISBN などの長い整数が指数表記で保存されている場合は整数に戻す
+	if strings.ContainsAny(v, "Ee") {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+	return v
+}
+
+// columnIndex はセル参照（"B12" など）の列番号（0 始まり）を返す。列のない参照は -1 を返す。
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
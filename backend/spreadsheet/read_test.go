@@ -0,0 +1,214 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="書籍" sheetId="1" r:id="rId1"/><sheet name="雑誌" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>title</t></si>
+<si><t>isbn</t></si>
+<si><r><t>吾輩は</t></r><r><t>猫である</t></r><rPh><t>ワガハイハネコデアル</t></rPh></si>
+</sst>`
+	testSheet1 = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>9.784873117522E12</v></c><c r="D2" t="b"><v>1</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>インライン</t></is></c><c r="B4" t="str"><v>数式の結果</v></c><c r="C4" t="s"><v>99</v></c></row>
+</sheetData></worksheet>`
+	testSheet2 = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row><c><v>1</v></c><c t="e"><v>#N/A</v></c></row>
+</sheetData></worksheet>`
+)
+
+// buildZip は files（zip 内のパス → 内容）の zip を作る
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testXLSX はシートを2つ持つ XLSX の zip 内のファイルを返す
+func testXLSX() map[string]string {
+	return map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/sheet1.xml":   testSheet1,
+		"xl/worksheets/sheet2.xml":   testSheet2,
+	}
+}
+
+func readXLSX(data []byte, sheet string) ([][]string, error) {
+	return ReadXLSX(bytes.NewReader(data), int64(len(data)), sheet)
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildZip(t, testXLSX())
+	rows, err := readXLSX(data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"title", "isbn"},
+		{"吾輩は猫である", "9784873117522", "", "TRUE"},
+		nil, // 値のない行
+		{"インライン", "数式の結果", ""}, // 範囲外の共有文字列は空
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %q, want %q", rows, want)
+	}
+
+	// シート名で選んだシート。行番号・セル参照のない行は順に並べる
+	rows, err = readXLSX(data, "雑誌")
+	if err != nil || !reflect.DeepEqual(rows, [][]string{{"1", "#N/A"}}) {
+		t.Fatalf("rows = %q, %v", rows, err)
+	}
+	if _, err := readXLSX(data, "論文"); !errors.Is(err, ErrSheetNotFound) {
+		t.Fatalf("unknown sheet: err = %v", err)
+	}
+
+	// 共有文字列がなくてもインライン文字列は読める
+	files := testXLSX()
+	delete(files, "xl/sharedStrings.xml")
+	rows, err = readXLSX(buildZip(t, files), "")
+	if err != nil || rows[0][0] != "" || rows[3][0] != "インライン" {
+		t.Fatalf("rows without shared strings = %q, %v", rows, err)
+	}
+}
+
+func TestReadXLSXMalformed(t *testing.T) {
+	without := func(name string) map[string]string {
+		files := testXLSX()
+		delete(files, name)
+		return files
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		err  error // nil の場合はエラーであることのみ確かめる
+	}{
+		"not a zip":        {[]byte("title,isbn\n"), ErrUnsupported},
+		"truncated zip":    {buildZip(t, testXLSX())[:100], ErrUnsupported},
+		"no workbook":      {buildZip(t, without("xl/workbook.xml")), ErrUnsupported},
+		"no relationships": {buildZip(t, without("xl/_rels/workbook.xml.rels")), ErrUnsupported},
+		"no sheet file":    {buildZip(t, without("xl/worksheets/sheet1.xml")), ErrSheetNotFound},
+		"no sheets":        {buildZip(t, withPart(testXLSX(), "xl/workbook.xml", `<workbook><sheets/></workbook>`)), ErrSheetNotFound},
+		"unknown rel":      {buildZip(t, withPart(testXLSX(), "xl/_rels/workbook.xml.rels", `<Relationships/>`)), ErrSheetNotFound},
+		"broken workbook":  {buildZip(t, withPart(testXLSX(), "xl/workbook.xml", `<workbook><sheets>`)), nil},
+		"broken sheet":     {buildZip(t, withPart(testXLSX(), "xl/worksheets/sheet1.xml", `<worksheet><sheetData><row>`)), nil},
+		"broken strings":   {buildZip(t, withPart(testXLSX(), "xl/sharedStrings.xml", `<sst><si>`)), nil},
+	} {
+		rows, err := readXLSX(tc.data, "")
+		if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+			t.Errorf("%s: rows = %q, err = %v, want %v", name, rows, err, tc.err)
+		}
+	}
+}
+
+// 展開後の大きさが上限を超えるファイルは途中までしか読まない
+func TestReadXLSXPartSizeLimit(t *testing.T) {
+	defer func(n int64) { maxPartSize = n }(maxPartSize)
+	maxPartSize = 4 << 10
+
+	// 圧縮すると小さくなる大きなシート
+	sheet := `<worksheet><sheetData>` + strings.Repeat(`<row><c t="inlineStr"><is><t>あ</t></is></c></row>`, 1000) + `</sheetData></worksheet>`
+	data := buildZip(t, withPart(testXLSX(), "xl/worksheets/sheet1.xml", sheet))
+	if len(data) > int(maxPartSize) {
+		t.Fatalf("test zip is %d bytes", len(data))
+	}
+	if rows, err := readXLSX(data, ""); err == nil {
+		t.Fatalf("read %d rows beyond the limit", len(rows))
+	}
+
+	// 上限以内なら読める
+	maxPartSize = 1 << 20
+	rows, err := readXLSX(data, "")
+	if err != nil || len(rows) != 1000 {
+		t.Fatalf("rows = %d, %v", len(rows), err)
+	}
+}
+
+// withPart は files の name を content に置き換える
+func withPart(files map[string]string, name, content string) map[string]string {
+	files[name] = content
+	return files
+}
+
+func TestReadCSV(t *testing.T) {
+	want := [][]string{{"title", "isbn"}, {"吾輩は猫である", "9784873117522", "余分な列"}, {"坊っちゃん"}}
+	content := "title,isbn\n吾輩は猫である,9784873117522,余分な列\n坊っちゃん\n"
+	sjis, err := japanese.ShiftJIS.NewEncoder().String(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"utf-8":     []byte(content),
+		"utf-8 BOM": append([]byte{0xEF, 0xBB, 0xBF}, content...),
+		"shift_jis": []byte(sjis),
+	} {
+		rows, err := ReadCSV(bytes.NewReader(data))
+		if err != nil || !reflect.DeepEqual(rows, want) {
+			t.Errorf("%s: rows = %q, %v", name, rows, err)
+		}
+	}
+
+	// 引用符の閉じていない行も読む
+	rows, err := ReadCSV(strings.NewReader("\"a \"b\" c\",d\n"))
+	if err != nil || len(rows) != 1 || rows[0][1] != "d" {
+		t.Fatalf("lazy quotes: rows = %q, %v", rows, err)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	xlsx := buildZip(t, testXLSX())
+	for _, tc := range []struct {
+		name string
+		head []byte
+		want string
+		err  error
+	}{
+		{"books.xlsx", xlsx, FormatXLSX, nil},
+		{"BOOKS.XLSX", xlsx, FormatXLSX, nil},
+		{"books.csv", []byte("title\n"), FormatCSV, nil},
+		{"books.txt", []byte("title\n"), FormatCSV, nil},
+		{"books.xlsx", []byte("title\n"), "", ErrUnsupported},
+		{"books.csv", xlsx, "", ErrUnsupported},
+		{"books.csv", []byte("a\x00b"), "", ErrUnsupported},
+		{"books.xls", []byte("title\n"), "", ErrUnsupported},
+	} {
+		got, err := DetectFormat(tc.name, tc.head)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("DetectFormat(%q) = %q, %v; want %q, %v", tc.name, got, err, tc.want, tc.err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testHeader = []string{"書名", "冊数", "価格", "貸出可", "登録日時", "返却日時", "メモ"}
+	testTime   = time.Date(2024, 4, 1, 9, 30, 0, 0, time.Local)
+	testValues = [][]interface{}{
+		{"吾輩は猫である", 2, 1234.5, true, testTime, (*time.Time)(nil), "改行\nを含む <&> \"引用\""},
+		{"  前後の空白  ", int64(9784873117522), 0.25, false, time.Time{}, &testTime, nil},
+	}
+	// testRows は testValues をセルの文字列にしたもの
+	testRows = [][]string{
+		testHeader,
+		{"吾輩は猫である", "2", "1234.5", "TRUE", "2024-04-01 09:30:00", "", "改行\nを含む <&> \"引用\""},
+		{"  前後の空白  ", "9784873117522", "0.25", "FALSE", "", "2024-04-01 09:30:00", ""},
+	}
+)
+
+// writeTable は format の Writer で header と values を書き出す
+func writeTable(t *testing.T, format string, header []string, values [][]interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range values {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 書き出した表を Read で読み直すと同じセルになる
+func TestWriteReadRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatXLSX} {
+		data := writeTable(t, format, testHeader, testValues)
+		got, err := DetectFormat("export."+format, data)
+		if err != nil || got != format {
+			t.Fatalf("DetectFormat(%s) = %q, %v", format, got, err)
+		}
+		rows, err := Read(format, bytes.NewReader(data), int64(len(data)), "")
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		// XLSX の空のセルは書かないため、行末の空のセルは読み取った行にない
+		if format == FormatXLSX {
+			rows[2] = append(rows[2], "")
+		}
+		if !reflect.DeepEqual(rows, testRows) {
+			t.Errorf("%s: rows =\n%q\nwant\n%q", format, rows, testRows)
+		}
+	}
+	if _, err := NewWriter("ods", &bytes.Buffer{}, testHeader); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("NewWriter(ods) err = %v", err)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	data := writeTable(t, FormatCSV, []string{"a", "b"}, [][]interface{}{{"=1+1", "-2"}, {-3, "@SUM(A1)"}})
+	if !bytes.HasPrefix(data, utf8BOM) {
+		t.Fatalf("no BOM: %q", data)
+	}
+	// 数式として解釈される文字列のみ "'" を付け、数値は変えない
+	want := "a,b\r\n'=1+1,'-2\r\n-3,'@SUM(A1)\r\n"
+	if got := string(bytes.TrimPrefix(data, utf8BOM)); got != want {
+		t.Fatalf("csv = %q, want %q", got, want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	header := make([]string, 30)
+	for i := range header {
+		header[i] = columnName(i)
+	}
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "貸出/履歴:2024[4月]", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]interface{}{"制御文字\x01を含む"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// シート名は使えない文字を除いて読める
+	data := buf.Bytes()
+	rows, err := ReadXLSX(bytes.NewReader(data), int64(len(data)), "貸出履歴20244月")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || len(rows[0]) != 30 || rows[0][25] != "Z" || rows[0][26] != "AA" || rows[0][29] != "AD" {
+		t.Fatalf("header = %q", rows[0])
+	}
+	// XML で使えない文字は置き換える
+	if rows[1][0] != "制御文字�を含む" {
+		t.Fatalf("row = %q", rows[1])
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+		if got := columnIndex(want + "12"); got != i {
+			t.Errorf("columnIndex(%q) = %d, want %d", want+"12", got, i)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	for in, want := range map[string]string{
+		"books":                 "books",
+		"a/b\\c[d]e:f*g?h":      "abcdefgh",
+		"":                      "Sheet1",
+		"[]":                    "Sheet1",
+		strings.Repeat("あ", 40): strings.Repeat("あ", 31),
+	} {
+		if got := sheetName(in); got != want {
+			t.Errorf("sheetName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 保存先の実装はいずれも Storage の説明どおりに動く
+func TestConformance(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Local":           func(t *testing.T) Storage { return NewLocal(t.TempDir()) },
+		"Memory":          func(t *testing.T) Storage { return NewMemory() },
+		"S3":              func(t *testing.T) Storage { return newFakeS3(t).storage(t, true) },
+		"S3VirtualHosted": func(t *testing.T) Storage { return newFakeS3(t).storage(t, false) },
+	}
+	for name, newStorage := range backends {
+		newStorage := newStorage
+		t.Run(name, func(t *testing.T) { testConformance(t, newStorage) })
+	}
+}
+
+func testConformance(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	put := func(t *testing.T, s Storage, key, content, contentType string) {
+		t.Helper()
+		if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), contentType); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	read := func(t *testing.T, s Storage, key string) (string, Info) {
+		t.Helper()
+		o, err := s.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", key, err)
+		}
+		defer o.Close()
+		data, err := io.ReadAll(o)
+		if err != nil {
+			t.Fatalf("read %s: %v", key, err)
+		}
+		return string(data), o.Info()
+	}
+	expectNotExist := func(t *testing.T, s Storage, key string) {
+		t.Helper()
+		if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotExist) {
+			t.Fatalf("Stat(%s) = %v, want ErrNotExist", key, err)
+		}
+		if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotExist) {
+			t.Fatalf("Open(%s) = %v, want ErrNotExist", key, err)
+		}
+	}
+
+	t.Run("PutOpenStat", func(t *testing.T) {
+		s := newStorage(t)
+		start := time.Now().Add(-time.Second)
+		put(t, s, "images/books/a.png", "PNG DATA", "image/png")
+
+		data, info := read(t, s, "images/books/a.png")
+		if data != "PNG DATA" {
+			t.Fatalf("content = %q", data)
+		}
+		if info.Key != "images/books/a.png" || info.Size != 8 || info.ContentType != "image/png" {
+			t.Fatalf("Open info = %+v", info)
+		}
+		if info.ModTime.Before(start.Truncate(time.Second)) || info.ModTime.After(time.Now().Add(time.Minute)) {
+			t.Fatalf("ModTime = %v", info.ModTime)
+		}
+		st, err := s.Stat(ctx, "images/books/a.png")
+		if err != nil || st.Key != info.Key || st.Size != info.Size || st.ContentType != info.ContentType {
+			t.Fatalf("Stat = %+v, %v; Open info = %+v", st, err, info)
+		}
+	})
+
+	t.Run("ContentTypeFromExtension", func(t *testing.T) {
+		s := newStorage(t)
+		put(t, s, "theses/a.pdf", "%PDF-", "")
+		if st, err := s.Stat(ctx, "theses/a.pdf"); err != nil || st.ContentType != "application/pdf" {
+			t.Fatalf("Stat = %+v, %v", st, err)
+		}
+	})
+
+	t.Run("UnknownSize", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.Put(ctx, "imports/a.csv", strings.NewReader("title\nA\n"), -1, "text/csv"); err != nil {
+			t.Fatal(err)
+		}
+		if data, info := read(t, s, "imports/a.csv"); data != "title\nA\n" || info.Size != 8 {
+			t.Fatalf("content = %q, info = %+v", data, info)
+		}
+	})
+
+	t.Run("ShortBody", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.Put(ctx, "attachments/short.txt", strings.NewReader("short"), 100, ""); err == nil {
+			t.Fatal("Put with a short body succeeded")
+		}
+		expectNotExist(t, s, "attachments/short.txt")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		s := newStorage(t)
+		put(t, s, "barcodes/a.png", "old content", "image/png")
+		put(t, s, "barcodes/a.png", "new", "image/png")
+		if data, info := read(t, s, "barcodes/a.png"); data != "new" || info.Size != 3 {
+			t.Fatalf("content = %q, info = %+v", data, info)
+		}
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		s := newStorage(t)
+		put(t, s, "attachments/digits.txt", "0123456789", "text/plain")
+		o, err := s.Open(ctx, "attachments/digits.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.Close()
+
+		readN := func(n int) string {
+			t.Helper()
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(o, buf); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			return string(buf)
+		}
+		seek := func(offset int64, whence int, want int64) {
+			t.Helper()
+			if pos, err := o.Seek(offset, whence); err != nil || pos != want {
+				t.Fatalf("Seek(%d, %d) = %d, %v; want %d", offset, whence, pos, err, want)
+			}
+		}
+		if got := readN(2); got != "01" {
+			t.Fatalf("read = %q", got)
+		}
+		seek(4, io.SeekStart, 4)
+		if got := readN(3); got != "456" {
+			t.Fatalf("read after SeekStart = %q", got)
+		}
+		seek(1, io.SeekCurrent, 8)
+		if got := readN(1); got != "8" {
+			t.Fatalf("read after SeekCurrent = %q", got)
+		}
+		seek(-3, io.SeekEnd, 7)
+		if rest, err := io.ReadAll(o); err != nil || string(rest) != "789" {
+			t.Fatalf("read after SeekEnd = %q, %v", rest, err)
+		}
+		// ServeContent は末尾に移動して大きさを調べる
+		seek(0, io.SeekEnd, 10)
+		if n, err := o.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+			t.Fatalf("read at end = %d, %v", n, err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		s := newStorage(t)
+		expectNotExist(t, s, "images/books/missing.png")
+		if err := s.Delete(ctx, "images/books/missing.png"); err != nil {
+			t.Fatalf("Delete of a missing object = %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStorage(t)
+		put(t, s, "barcodes/a.png", "a", "")
+		put(t, s, "barcodes/b.png", "b", "")
+		if err := s.Delete(ctx, "barcodes/a.png"); err != nil {
+			t.Fatal(err)
+		}
+		expectNotExist(t, s, "barcodes/a.png")
+		if data, _ := read(t, s, "barcodes/b.png"); data != "b" {
+			t.Fatalf("other object = %q", data)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		s := newStorage(t)
+		for _, key := range []string{"barcodes/b.png", "barcodes/a.png", "barcodes/sub/c.png", "barcodesX/d.png", "images/e.png"} {
+			put(t, s, key, key, "")
+		}
+		keys := func(prefix string) []string {
+			t.Helper()
+			list, err := s.List(ctx, prefix)
+			if err != nil {
+				t.Fatalf("List(%q): %v", prefix, err)
+			}
+			out := []string{}
+			for _, info := range list {
+				if info.Size != int64(len(info.Key)) || info.ContentType != "image/png" || info.ModTime.IsZero() {
+					t.Errorf("List(%q): %+v", prefix, info)
+				}
+				out = append(out, info.Key)
+			}
+			return out
+		}
+		for prefix, want := range map[string][]string{
+			"barcodes/":    {"barcodes/a.png", "barcodes/b.png", "barcodes/sub/c.png"},
+			"barcodes":     {"barcodes/a.png", "barcodes/b.png", "barcodes/sub/c.png", "barcodesX/d.png"},
+			"barcodes/b":   {"barcodes/b.png"},
+			"":             {"barcodes/a.png", "barcodes/b.png", "barcodes/sub/c.png", "barcodesX/d.png", "images/e.png"},
+			"attachments/": {},
+		} {
+			if got := keys(prefix); !reflect.DeepEqual(got, want) {
+				t.Errorf("List(%q) = %v, want %v", prefix, got, want)
+			}
+		}
+	})
+
+	t.Run("SpecialCharacters", func(t *testing.T) {
+		s := newStorage(t)
+		key := "attachments/報告書 (最終版)+v2&a=b.pdf"
+		put(t, s, key, "%PDF-", "")
+		if data, info := read(t, s, key); data != "%PDF-" || info.Key != key {
+			t.Fatalf("content = %q, info = %+v", data, info)
+		}
+		if list, err := s.List(ctx, "attachments/"); err != nil || len(list) != 1 || list[0].Key != key {
+			t.Fatalf("List = %+v, %v", list, err)
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		expectNotExist(t, s, key)
+	})
+
+	t.Run("InvalidKeys", func(t *testing.T) {
+		s := newStorage(t)
+		for _, key := range []string{"", "../secret.txt", "barcodes/../../secret.txt", "/etc/passwd", `barcodes\a.png`, "."} {
+			if err := s.Put(ctx, key, bytes.NewReader(nil), 0, ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put(%q) = %v", key, err)
+			}
+			if _, err := s.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Open(%q) = %v", key, err)
+			}
+			if _, err := s.Stat(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Stat(%q) = %v", key, err)
+			}
+			if err := s.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Delete(%q) = %v", key, err)
+			}
+		}
+	})
+}
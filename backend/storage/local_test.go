@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalPath(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLocal(dir)
+
+	got, err := l.path("barcodes//a.png")
+	if err != nil || got != filepath.Join(dir, "barcodes", "a.png") {
+		t.Fatalf("path(barcodes//a.png) = %q, %v", got, err)
+	}
+
+	for _, key := range []string{"../secret.txt", "barcodes/../../secret.txt", "/etc/passwd", `..\secret.txt`, ".."} {
+		if got, err := l.path(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("path(%q) = %q, %v; want ErrInvalidKey", key, got, err)
+		}
+	}
+
+	// 有効なキーのパスはすべて dir の中になる
+	for _, key := range []string{"a", "barcodes/a.png", "a/b/c/d.txt", "barcodes/..a"} {
+		got, err := l.path(key)
+		if err != nil {
+			t.Errorf("path(%q): %v", key, err)
+			continue
+		}
+		if rel, err := filepath.Rel(dir, got); err != nil || strings.HasPrefix(rel, "..") {
+			t.Errorf("path(%q) = %q is outside %q", key, got, dir)
+		}
+	}
+}
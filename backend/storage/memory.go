@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory はメモリ上の保存先。サーバーを止めると内容は失われる（開発・テスト用）。
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	info Info
+}
+
+// NewMemory は空の Memory を返す
+func NewMemory() *Memory {
+	return &Memory{objects: map[string]memoryObject{}}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return io.ErrUnexpectedEOF
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, info: Info{
+		Key:         key,
+		Size:        int64(len(data)),
+		ModTime:     time.Now(),
+		ContentType: contentTypeOf(key, contentType),
+	}}
+	return nil
+}
+
+// memoryReader は保存した内容のコピーを読む
+type memoryReader struct {
+	*bytes.Reader
+	info Info
+}
+
+func (r *memoryReader) Info() Info   { return r.info }
+func (r *memoryReader) Close() error { return nil }
+
+func (m *Memory) Open(ctx context.Context, key string) (Object, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	o, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	// 保存した内容は置き換えられるだけで書き換えられないため、そのまま読んでよい
+	return &memoryReader{Reader: bytes.NewReader(o.data), info: o.info}, nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (Info, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return Info{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	o, ok := m.objects[key]
+	if !ok {
+		return Info{}, ErrNotExist
+	}
+	return o.info, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var list []Info
+	for key, o := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			list = append(list, o.info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload は本文のハッシュを署名に含めない場合の x-amz-content-sha256 の値
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// maxPresignExpires は署名付き URL の有効期限の上限（AWS Signature Version 4 の制限）
+const maxPresignExpires = 7 * 24 * time.Hour
+
+// S3Config は S3 互換ストレージの接続先
+type S3Config struct {
+	// Endpoint は接続先の URL（例: http://minio:9000）。空の場合は AWS S3 のリージョンのエンドポイント。
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle はバケットをホスト名ではなくパスで指定する（MinIO はこちら）
+	PathStyle bool
+	// Client は HTTP クライアント。nil の場合は http.DefaultClient。
+	Client *http.Client
+}
+
+// S3 は S3 互換のオブジェクトストレージ（AWS S3・MinIO など）を保存先とする。
+// リクエストは AWS Signature Version 4 で署名する。
+type S3 struct {
+	cfg  S3Config
+	base *url.URL
+	now  func() time.Time
+}
+
+// NewS3 は cfg の S3 を返す
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 のバケットが指定されていません")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("S3 のアクセスキーが指定されていません")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("S3 のエンドポイントが正しくありません: %s", endpoint)
+	}
+	return &S3{cfg: cfg, base: base, now: time.Now}, nil
+}
+
+func (s *S3) client() *http.Client {
+	if s.cfg.Client != nil {
+		return s.cfg.Client
+	}
+	return http.DefaultClient
+}
+
+// objectURL は key のオブジェクトの URL を返す。key が空の場合はバケットの URL。
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.base
+	p := strings.TrimRight(u.Path, "/")
+	if s.cfg.PathStyle {
+		p += "/" + s.cfg.Bucket
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = p + "/" + key
+	u.RawPath = p + "/" + uriEncode(key, false)
+	return &u
+}
+
+// do は署名したリクエストを送る
+func (s *S3) do(ctx context.Context, method string, u *url.URL, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, s.now().UTC())
+	return s.client().Do(req)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		// S3 の PUT には Content-Length が必要なため、長さが分からない場合は読み込んでから送る
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	header := http.Header{"Content-Type": {contentTypeOf(key, contentType)}}
+	res, err := s.do(ctx, http.MethodPut, s.objectURL(key), header, io.LimitReader(r, size), size)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return Info{}, err
+	}
+	res, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, nil, 0)
+	if err != nil {
+		return Info{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return Info{}, s3Error(res)
+	}
+	return s3Info(key, res.Header), nil
+}
+
+// Open は HEAD で情報を取得し、内容は読み取る位置から Range 付きの GET で必要になった時点で取得する
+func (s *S3) Open(ctx context.Context, key string) (Object, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{s: s, ctx: ctx, info: info}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 && res.StatusCode != http.StatusNotFound {
+		return s3Error(res)
+	}
+	return nil
+}
+
+// listResult は ListObjectsV2 のレスポンス
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List は ListObjectsV2 で取得する。ListObjectsV2 はキーの順に返すため並べ替えない。
+// Content-Type は一覧に含まれないため拡張子から判定する。
+func (s *S3) List(ctx context.Context, prefix string) ([]Info, error) {
+	var list []Info
+	token := ""
+	for {
+		u := s.objectURL("")
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+
+		res, err := s.do(ctx, http.MethodGet, u, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode/100 != 2 {
+			err := s3Error(res)
+			res.Body.Close()
+			return nil, err
+		}
+		var out listResult
+		err = xml.NewDecoder(res.Body).Decode(&out)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range out.Contents {
+			list = append(list, Info{Key: c.Key, Size: c.Size, ModTime: c.LastModified, ContentType: contentTypeOf(c.Key, "")})
+		}
+		if !out.IsTruncated || out.NextContinuationToken == "" {
+			return list, nil
+		}
+		token = out.NextContinuationToken
+	}
+}
+
+// SignedURL は GET 用の署名付き URL（クエリ文字列で署名する）を返す
+func (s *S3) SignedURL(ctx context.Context, key string, expires time.Duration, opts URLOptions) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expires <= 0 || expires > maxPresignExpires {
+		return "", fmt.Errorf("署名付き URL の有効期限が正しくありません: %s", expires)
+	}
+	t := s.now().UTC()
+	u := s.objectURL(key)
+
+	q := url.Values{}
+	if opts.ContentType != "" {
+		q.Set("response-content-type", opts.ContentType)
+	}
+	if opts.ContentDisposition != "" {
+		q.Set("response-content-disposition", opts.ContentDisposition)
+	}
+	if opts.CacheControl != "" {
+		q.Set("response-cache-control", opts.CacheControl)
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+s.scope(t))
+	q.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery(q),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	q.Set("X-Amz-Signature", s.signature(t, canonical))
+	u.RawQuery = canonicalQuery(q)
+	return u.String(), nil
+}
+
+// sign はリクエストに Authorization ヘッダーを付ける。本文は署名に含めない（UNSIGNED-PAYLOAD）。
+func (s *S3) sign(req *http.Request, t time.Time) {
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if k == "content-type" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signed,
+		unsignedPayload,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, s.scope(t), signed, s.signature(t, canonical)))
+}
+
+// scope は署名のスコープ（日付/リージョン/s3/aws4_request）を返す
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+// signature は正規リクエストから署名を計算する
+func (s *S3) signature(t time.Time, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalQuery はクエリをキーの順に並べ、AWS の規則でエンコードする
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode は英数字と "-_.~" 以外をパーセントエンコードする。encodeSlash が false の場合は "/" を残す。
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3Info(key string, h http.Header) Info {
+	info := Info{Key: key, ContentType: h.Get("Content-Type")}
+	info.Size, _ = strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	info.ModTime, _ = http.ParseTime(h.Get("Last-Modified"))
+	if info.ContentType == "" {
+		info.ContentType = contentTypeOf(key, "")
+	}
+	return info
+}
+
+// s3Error はエラーのレスポンスをエラーにする
+func s3Error(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&body)
+	if body.Code != "" {
+		return fmt.Errorf("S3: %s: %s (%s)", res.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("S3: %s", res.Status)
+}
+
+// s3Object は S3 のオブジェクトを読む。Seek した位置から Range 付きの GET で読み直す。
+type s3Object struct {
+	s    *S3
+	ctx  context.Context
+	info Info
+	pos  int64
+	body io.ReadCloser
+}
+
+func (o *s3Object) Info() Info { return o.info }
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.info.Size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", o.pos)}}
+		res, err := o.s.do(o.ctx, http.MethodGet, o.s.objectURL(o.info.Key), header, nil, 0)
+		if err != nil {
+			return 0, err
+		}
+		if res.StatusCode/100 != 2 {
+			defer res.Body.Close()
+			return 0, s3Error(res)
+		}
+		o.body = res.Body
+	}
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	pos := offset
+	switch whence {
+	case io.SeekCurrent:
+		pos += o.pos
+	case io.SeekEnd:
+		pos += o.info.Size
+	}
+	if pos < 0 {
+		return 0, errors.New("storage: 負の位置には移動できません")
+	}
+	if pos != o.pos && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
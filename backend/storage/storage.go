@@ -0,0 +1,121 @@
+// Package storage は書籍画像・バーコード画像・添付ファイルなどのファイル（オブジェクト）の保存先を抽象化する。
+// 保存先はローカルのディレクトリ（Local）、S3 互換のオブジェクトストレージ（S3。MinIO を含む）、
+// メモリ（Memory。開発・テスト用）から設定で選ぶ。
+// オブジェクトは "images/books/xxx.jpg" のような "/" 区切りのキーで指定する。
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotExist はキーのオブジェクトがない場合のエラー
+	ErrNotExist = errors.New("オブジェクトが見つかりません")
+	// ErrInvalidKey は空・絶対パス・".." を含むなど、使えないキーの場合のエラー
+	ErrInvalidKey = errors.New("オブジェクトのキーが正しくありません")
+)
+
+// Info はオブジェクトの情報
+type Info struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Object は読み取り用に開いたオブジェクト。http.ServeContent にそのまま渡せる。
+type Object interface {
+	io.ReadSeekCloser
+	Info() Info
+}
+
+// Storage はオブジェクトの保存先
+type Storage interface {
+	// Put は r から size バイトを読んで key に保存する。同じキーのオブジェクトは置き換える。
+	// 保存が完了するまで、書きかけの内容が Open で読まれることはない。
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open は key のオブジェクトを開く。ない場合は ErrNotExist を返す。
+	Open(ctx context.Context, key string) (Object, error)
+	// Stat は key のオブジェクトの情報を返す。ない場合は ErrNotExist を返す。
+	Stat(ctx context.Context, key string) (Info, error)
+	// Delete は key のオブジェクトを削除する。ない場合も nil を返す。
+	Delete(ctx context.Context, key string) error
+	// List はキーが prefix で始まるオブジェクトをキーの順に返す
+	List(ctx context.Context, prefix string) ([]Info, error)
+}
+
+// URLOptions は署名付き URL でオブジェクトを取得したときのレスポンスヘッダー（空の項目は保存時の値）
+type URLOptions struct {
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+}
+
+// URLSigner は有効期限付きの署名付き URL を発行し、オブジェクトを直接配信できる保存先
+type URLSigner interface {
+	SignedURL(ctx context.Context, key string, expires time.Duration, opts URLOptions) (string, error)
+}
+
+// 保存先の種類
+const (
+	KindLocal  = "local"
+	KindS3     = "s3"
+	KindMemory = "memory"
+)
+
+// Options は保存先の設定
+type Options struct {
+	Dir string   // local: 保存先のディレクトリ
+	S3  S3Config // s3: 接続先
+}
+
+// New は kind（"local"・"s3"・"memory"）の保存先を返す
+func New(kind string, opts Options) (Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case KindLocal, "":
+		if opts.Dir == "" {
+			return nil, errors.New("保存先のディレクトリが指定されていません")
+		}
+		return NewLocal(opts.Dir), nil
+	case KindS3:
+		return NewS3(opts.S3)
+	case KindMemory:
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("不明な保存先: %s", kind)
+}
+
+// CleanKey はキーを検証し、余分な "/" を除いた形にする
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." || seg == "." {
+			return "", ErrInvalidKey
+		}
+	}
+	key = path.Clean(key)
+	if key == "." {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
+
+// contentTypeOf は保存時に Content-Type の指定がない場合に拡張子から判定する
+func contentTypeOf(key, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCleanKey(t *testing.T) {
+	valid := map[string]string{
+		"barcodes/a.png":      "barcodes/a.png",
+		"barcodes//a.png":     "barcodes/a.png",
+		"barcodes/a.png/":     "barcodes/a.png",
+		"images/books/x.jpg":  "images/books/x.jpg",
+		"barcodes/..a.png":    "barcodes/..a.png",
+		"barcodes/a..png":     "barcodes/a..png",
+		"barcodes/%2e%2e.png": "barcodes/%2e%2e.png", // キーは URL デコードしない
+	}
+	for key, want := range valid {
+		got, err := CleanKey(key)
+		if err != nil || got != want {
+			t.Errorf("CleanKey(%q) = %q, %v; want %q", key, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		".",
+		"..",
+		"../secret.txt",
+		"barcodes/../secret.txt",
+		"barcodes/../../secret.txt",
+		"barcodes/./a.png",
+		"/etc/passwd",
+		"//etc/passwd",
+		`..\secret.txt`,
+		`barcodes\..\secret.txt`,
+		`C:\Windows\win.ini`,
+	}
+	for _, key := range invalid {
+		if got, err := CleanKey(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("CleanKey(%q) = %q, %v; want ErrInvalidKey", key, got, err)
+		}
+	}
+}
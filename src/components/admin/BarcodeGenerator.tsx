@@ -3,6 +3,7 @@ import { QrCode, Download, Trash2, Printer, Save } from 'lucide-react';
 import axios from 'axios';
 
 interface GeneratedBarcode {
+  id: string;
   barcode: string;
   year: string;
   student_id: string;
@@ -13,15 +14,26 @@ interface GeneratedBarcode {
   image_data: string;
   created_at: string;
   status: string;
+  book_id: string;
+  copy_id: string | null;
+  created: boolean;
+  existing: boolean;
 }
 
 interface SavedBarcode {
+  id: string;
+  code: string;
+  symbology: string;
+  book_id: string | null;
+  copy_id: string | null;
   filename: string;
   file_path: string;
   created_at: string;
   size: number;
   year?: string;
   student_id?: string;
+  author_name?: string;
+  title?: string;
 }
 
 const BarcodeGenerator: React.FC = () => {
@@ -29,7 +41,11 @@ const BarcodeGenerator: React.FC = () => {
     year: new Date().getFullYear().toString(),
     student_id: '',
     author_name: '',
-    title: ''
+    title: '',
+    degree: 'bachelor',
+    supervisor: '',
+    abstract: '',
+    keywords: ''
   });
   
   const [generatedBarcode, setGeneratedBarcode] = useState<GeneratedBarcode | null>(null);
@@ -39,11 +55,14 @@ const BarcodeGenerator: React.FC = () => {
   const [success, setSuccess] = useState<string | null>(null);
   const [error, setError] = useState<string | null>(null);
 
+  // 管理者用のAPIにはログイン中のトークンを送る
+  const authHeaders = () => ({ Authorization: `Bearer ${localStorage.getItem('token')}` });
+
   // 保存されたバーコード一覧を取得
   const fetchSavedBarcodes = async () => {
     try {
       setIsLoading(true);
-      const response = await axios.get('/api/admin/barcode/saved');
+      const response = await axios.get('/api/admin/barcode/saved', { headers: authHeaders() });
       setSavedBarcodes(response.data.barcodes || []);
     } catch (err) {
       console.error('保存されたバーコード取得エラー:', err);
@@ -75,9 +94,21 @@ const BarcodeGenerator: React.FC = () => {
       setIsGenerating(true);
       setError(null);
       
-      const response = await axios.post('/api/admin/barcode/generate-thesis', formData);
+      // 指導教員・キーワードは空の場合は送らず、登録済みの論文の値を残す
+      const { supervisor, keywords, ...rest } = formData;
+      const response = await axios.post('/api/admin/barcode/generate-thesis', {
+        ...rest,
+        supervisors: supervisor.trim() ? [supervisor.trim()] : undefined,
+        keywords: keywords.trim()
+          ? keywords.split(/[,、]/).map((k) => k.trim()).filter(Boolean)
+          : undefined
+      }, { headers: authHeaders() });
       setGeneratedBarcode(response.data);
-      setSuccess('バーコードが生成され、ファイルに保存されました（番号付き画像）');
+      setSuccess(
+        response.data.created
+          ? 'バーコードが生成され、論文を蔵書に登録しました（番号付き画像）'
+          : 'バーコードが生成され、登録済みの論文に関連付けました（番号付き画像）'
+      );
       
       // 保存済みリストを更新
       await fetchSavedBarcodes();
@@ -90,9 +121,10 @@ const BarcodeGenerator: React.FC = () => {
   };
 
   // バーコード画像ダウンロード
-  const downloadBarcode = async (filename: string) => {
+  const downloadBarcode = async (id: string, filename: string) => {
     try {
-      const response = await axios.get(`/api/admin/barcode/download/${filename}`, {
+      const response = await axios.get(`/api/admin/barcode/download/${id}`, {
+        headers: authHeaders(),
         responseType: 'blob'
       });
       
@@ -111,12 +143,36 @@ const BarcodeGenerator: React.FC = () => {
     }
   };
 
+  // 保存済みバーコードのラベル用紙（PDF）のダウンロード
+  const downloadLabels = async (ids: string[]) => {
+    if (ids.length === 0) return;
+    try {
+      const response = await axios.post('/api/admin/labels', { barcode_ids: ids }, {
+        headers: authHeaders(),
+        responseType: 'blob'
+      });
+
+      const url = window.URL.createObjectURL(new Blob([response.data], { type: 'application/pdf' }));
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = 'labels.pdf';
+      document.body.appendChild(link);
+      link.click();
+      document.body.removeChild(link);
+      window.URL.revokeObjectURL(url);
+
+      setSuccess(`${ids.length} 件のラベルを作成しました`);
+    } catch (err) {
+      setError('ラベルの作成に失敗しました');
+    }
+  };
+
   // バーコード画像削除
-  const deleteBarcode = async (filename: string) => {
+  const deleteBarcode = async (id: string, filename: string) => {
     if (!confirm(`${filename} を削除しますか？`)) return;
     
     try {
-      await axios.delete(`/api/admin/barcode/${filename}`);
+      await axios.delete(`/api/admin/barcode/${id}`, { headers: authHeaders() });
       setSuccess(`${filename} を削除しました`);
       await fetchSavedBarcodes();
     } catch (err) {
@@ -244,6 +300,61 @@ const BarcodeGenerator: React.FC = () => {
             />
           </div>
 
+          <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">
+                学位
+              </label>
+              <select
+                value={formData.degree}
+                onChange={(e) => setFormData({ ...formData, degree: e.target.value })}
+                className="w-full px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm focus:outline-none focus:ring-2 focus:ring-indigo-500 dark:bg-gray-700 dark:text-white"
+              >
+                <option value="bachelor">学士</option>
+                <option value="master">修士</option>
+                <option value="doctor">博士</option>
+              </select>
+            </div>
+
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">
+                指導教員
+              </label>
+              <input
+                type="text"
+                value={formData.supervisor}
+                onChange={(e) => setFormData({ ...formData, supervisor: e.target.value })}
+                className="w-full px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm focus:outline-none focus:ring-2 focus:ring-indigo-500 dark:bg-gray-700 dark:text-white"
+                placeholder="例: 佐藤一郎"
+              />
+            </div>
+          </div>
+
+          <div>
+            <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">
+              要旨
+            </label>
+            <textarea
+              value={formData.abstract}
+              onChange={(e) => setFormData({ ...formData, abstract: e.target.value })}
+              className="w-full px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm focus:outline-none focus:ring-2 focus:ring-indigo-500 dark:bg-gray-700 dark:text-white"
+              rows={3}
+            />
+          </div>
+
+          <div>
+            <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">
+              キーワード
+            </label>
+            <input
+              type="text"
+              value={formData.keywords}
+              onChange={(e) => setFormData({ ...formData, keywords: e.target.value })}
+              className="w-full px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm focus:outline-none focus:ring-2 focus:ring-indigo-500 dark:bg-gray-700 dark:text-white"
+              placeholder="例: 画像認識, 深層学習（カンマ区切り）"
+            />
+          </div>
+
           <button
             type="submit"
             disabled={isGenerating}
@@ -310,7 +421,7 @@ const BarcodeGenerator: React.FC = () => {
             
             <div className="flex justify-center space-x-3">
               <button
-                onClick={() => downloadBarcode(generatedBarcode.filename)}
+                onClick={() => downloadBarcode(generatedBarcode.id, generatedBarcode.filename)}
                 className="inline-flex items-center px-4 py-2 border border-transparent rounded-md text-sm text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500"
               >
                 <Download className="mr-2 h-4 w-4" />
@@ -335,13 +446,23 @@ const BarcodeGenerator: React.FC = () => {
           <h3 className="text-lg font-medium text-gray-900 dark:text-white">
             保存されたバーコード一覧（番号付き画像）
           </h3>
-          <button
-            onClick={fetchSavedBarcodes}
-            disabled={isLoading}
-            className="inline-flex items-center px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md text-sm text-gray-700 dark:text-gray-200 hover:bg-gray-100 dark:hover:bg-gray-700"
-          >
-            更新
-          </button>
+          <div className="flex space-x-2">
+            <button
+              onClick={() => downloadLabels(savedBarcodes.map((b) => b.id))}
+              disabled={isLoading || savedBarcodes.length === 0}
+              className="inline-flex items-center px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md text-sm text-gray-700 dark:text-gray-200 hover:bg-gray-100 dark:hover:bg-gray-700"
+            >
+              <Printer className="mr-2 h-4 w-4" />
+              ラベル用紙（PDF）
+            </button>
+            <button
+              onClick={fetchSavedBarcodes}
+              disabled={isLoading}
+              className="inline-flex items-center px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md text-sm text-gray-700 dark:text-gray-200 hover:bg-gray-100 dark:hover:bg-gray-700"
+            >
+              更新
+            </button>
+          </div>
         </div>
 
         {isLoading ? (
@@ -371,8 +492,8 @@ const BarcodeGenerator: React.FC = () => {
                 </tr>
               </thead>
               <tbody className="bg-white dark:bg-gray-800 divide-y divide-gray-200 dark:divide-gray-700">
-                {savedBarcodes.map((barcode, index) => (
-                  <tr key={index}>
+                {savedBarcodes.map((barcode) => (
+                  <tr key={barcode.id}>
                     <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-900 dark:text-white">
                       {barcode.filename}
                     </td>
@@ -391,14 +512,14 @@ const BarcodeGenerator: React.FC = () => {
                     <td className="px-6 py-4 whitespace-nowrap text-right text-sm font-medium">
                       <div className="flex justify-end space-x-2">
                         <button
-                          onClick={() => downloadBarcode(barcode.filename)}
+                          onClick={() => downloadBarcode(barcode.id, barcode.filename)}
                           className="text-blue-600 hover:text-blue-900 dark:text-blue-400 dark:hover:text-blue-300"
                           title="ダウンロード（番号付き）"
                         >
                           <Download className="h-4 w-4" />
                         </button>
                         <button
-                          onClick={() => deleteBarcode(barcode.filename)}
+                          onClick={() => deleteBarcode(barcode.id, barcode.filename)}
                           className="text-red-600 hover:text-red-900 dark:text-red-400 dark:hover:text-red-300"
                           title="削除"
                         >
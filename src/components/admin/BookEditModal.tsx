@@ -91,13 +91,18 @@ const BookEditModal: React.FC<BookEditModalProps> = ({ book, isOpen, onClose, on
 
     try {
       const token = localStorage.getItem('token');
-      await axios.put(`/api/admin/books/${book.id}`, formData, {
+      const response = await axios.put(`/api/admin/books/${book.id}`, formData, {
         headers: {
           Authorization: `Bearer ${token}`,
         },
       });
 
-      setSuccess('書籍情報を更新しました');
+      const duplicates: { title: string; code: string }[] = response.data.duplicates ?? [];
+      setSuccess(
+        duplicates.length
+          ? `書籍情報を更新しました（同じコードの書籍があります: ${duplicates.map(d => `${d.title}（${d.code}）`).join('、')}）`
+          : '書籍情報を更新しました'
+      );
       setTimeout(() => {
         onUpdate();
         onClose();
@@ -12,18 +12,41 @@ interface FormData {
   isbn: string;
   location: string;
   copies: number;
+  publisher: string;
+  published_date: string;
+  edition: string;
+  page_count: string;
+  language: string;
+  subjects: string;
+  description: string;
+  notes: string;
 }
 
+const initialFormData: FormData = {
+  title: '',
+  author: '',
+  type: 'book',
+  barcode: '',
+  isbn: '',
+  location: '',
+  copies: 1,
+  publisher: '',
+  published_date: '',
+  edition: '',
+  page_count: '',
+  language: '',
+  subjects: '',
+  description: '',
+  notes: '',
+};
+
+const inputClass =
+  'w-full px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm focus:outline-none focus:ring-2 focus:ring-indigo-500 dark:bg-gray-700 dark:text-white';
+
 const BookRegistration: React.FC = () => {
-  const [formData, setFormData] = useState<FormData>({
-    title: '',
-    author: '',
-    type: 'book',
-    barcode: '',
-    isbn: '',
-    location: '',
-    copies: 1,
-  });
+  const [formData, setFormData] = useState<FormData>(initialFormData);
+  // ISBN検索で取得した著者（著者欄を編集しなければ複数の著者として登録する）
+  const [fetchedAuthors, setFetchedAuthors] = useState<string[]>([]);
 
   const [isLoading, setIsLoading] = useState(false);
   const [isSearching, setIsSearching] = useState(false);
@@ -32,7 +55,9 @@ const BookRegistration: React.FC = () => {
   const [selectedImage, setSelectedImage] = useState<File | null>(null);
   const [imagePreview, setImagePreview] = useState<string | null>(null);
 
-  const handleInputChange = (e: React.ChangeEvent<HTMLInputElement | HTMLSelectElement>) => {
+  const handleInputChange = (
+    e: React.ChangeEvent<HTMLInputElement | HTMLSelectElement | HTMLTextAreaElement>
+  ) => {
     const { name, value } = e.target;
     setFormData(prev => ({
       ...prev,
@@ -54,12 +79,22 @@ const BookRegistration: React.FC = () => {
       });
 
       if (response.data) {
+        const info = response.data;
         setFormData(prev => ({
           ...prev,
-          title: response.data.title || prev.title,
-          author: response.data.author || prev.author,
+          title: info.title || prev.title,
+          author: info.author || prev.author,
+          publisher: info.publisher || prev.publisher,
+          published_date: info.published_date || prev.published_date,
+          page_count: info.page_count ? String(info.page_count) : prev.page_count,
+          language: info.language || prev.language,
+          subjects: info.subjects?.length ? info.subjects.join(', ') : prev.subjects,
+          description: info.description || prev.description,
         }));
-        setSuccess('書籍情報を取得しました');
+        setFetchedAuthors(info.authors ?? []);
+        setSuccess(
+          info.sources?.length ? `書籍情報を取得しました（${info.sources.join(', ')}）` : '書籍情報を取得しました'
+        );
         setTimeout(() => setSuccess(null), 2000);
       }
     } catch (err: any) {
@@ -122,9 +157,17 @@ const BookRegistration: React.FC = () => {
     try {
       const token = localStorage.getItem('token');
       
+      const { subjects, page_count, ...rest } = formData;
+      const authors =
+        fetchedAuthors.length > 0 && fetchedAuthors.join(', ') === formData.author
+          ? fetchedAuthors.map((name) => ({ name, role: 'author' }))
+          : undefined;
       const response = await axios.post('/api/admin/books', {
-        ...formData,
+        ...rest,
+        authors,
         total_copies: formData.copies,
+        page_count: parseInt(page_count) || 0,
+        subjects: subjects.split(/[,、]/).map((v) => v.trim()).filter(Boolean),
       }, {
         headers: { Authorization: `Bearer ${token}` },
       });
@@ -145,17 +188,15 @@ const BookRegistration: React.FC = () => {
         }
       }
 
-      setSuccess('書籍を登録しました');
+      const duplicates: { title: string; code: string }[] = response.data.duplicates ?? [];
+      setSuccess(
+        duplicates.length
+          ? `書籍を登録しました（同じコードの書籍があります: ${duplicates.map(d => `${d.title}（${d.code}）`).join('、')}）`
+          : '書籍を登録しました'
+      );
       
-      setFormData({
-        title: '',
-        author: '',
-        type: 'book',
-        barcode: '',
-        isbn: '',
-        location: '',
-        copies: 1,
-      });
+      setFormData(initialFormData);
+      setFetchedAuthors([]);
       setSelectedImage(null);
       setImagePreview(null);
 
@@ -357,6 +398,53 @@ const BookRegistration: React.FC = () => {
           />
         </div>
 
+        <details className="border border-gray-200 dark:border-gray-700 rounded-md p-3">
+          <summary className="cursor-pointer text-sm font-medium text-gray-700 dark:text-gray-300">
+            書誌情報 <span className="text-gray-500 text-xs">(任意)</span>
+          </summary>
+          <div className="mt-3 grid grid-cols-1 md:grid-cols-2 gap-4">
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">出版社</label>
+              <input name="publisher" type="text" value={formData.publisher} onChange={handleInputChange} className={inputClass} />
+            </div>
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">出版日</label>
+              <input
+                name="published_date"
+                type="text"
+                value={formData.published_date}
+                onChange={handleInputChange}
+                className={inputClass}
+                placeholder="例: 2020、2020-04、2020-04-01"
+              />
+            </div>
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">版</label>
+              <input name="edition" type="text" value={formData.edition} onChange={handleInputChange} className={inputClass} placeholder="例: 第2版" />
+            </div>
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">ページ数</label>
+              <input name="page_count" type="number" min="0" value={formData.page_count} onChange={handleInputChange} className={inputClass} />
+            </div>
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">言語</label>
+              <input name="language" type="text" value={formData.language} onChange={handleInputChange} className={inputClass} placeholder="例: ja、en" />
+            </div>
+            <div>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">件名</label>
+              <input name="subjects" type="text" value={formData.subjects} onChange={handleInputChange} className={inputClass} placeholder="カンマ区切り（例: 機械学習, 統計）" />
+            </div>
+            <div className="md:col-span-2">
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">内容紹介</label>
+              <textarea name="description" rows={3} value={formData.description} onChange={handleInputChange} className={inputClass} />
+            </div>
+            <div className="md:col-span-2">
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">メモ</label>
+              <textarea name="notes" rows={2} value={formData.notes} onChange={handleInputChange} className={inputClass} placeholder="管理用のメモ" />
+            </div>
+          </div>
+        </details>
+
         <button
           type="submit"
           disabled={isLoading}
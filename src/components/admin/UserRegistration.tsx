@@ -321,7 +321,7 @@ const UserRegistration: React.FC = () => {
                         ? 'bg-purple-100 dark:bg-purple-900 text-purple-800 dark:text-purple-200'
                         : 'bg-indigo-100 dark:bg-indigo-900 text-indigo-800 dark:text-indigo-200'
                     }`}>
-                      {user.role === 'admin' ? '管理者' : '一般'}
+                      {user.role === 'admin' ? '管理者' : user.role === 'guest' ? 'ゲスト' : '一般'}
                     </span>
                   </td>
                   <td className="px-6 py-4 whitespace-nowrap text-right text-sm font-medium">
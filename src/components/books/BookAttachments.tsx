@@ -0,0 +1,88 @@
+import React, { useEffect, useState } from 'react';
+import { Paperclip, Download } from 'lucide-react';
+import axios from 'axios';
+import { BookAttachment } from '../../types';
+
+interface BookAttachmentsProps {
+  bookId: string;
+}
+
+const VISIBILITY_LABELS: Record<BookAttachment['visibility'], string> = {
+  public: '公開',
+  lab: '研究室内',
+  admin: '管理者のみ',
+};
+
+// ファイルサイズを KB・MB で表示する
+const formatSize = (size: number) =>
+  size >= 1024 * 1024 ? `${(size / 1024 / 1024).toFixed(1)} MB` : `${Math.ceil(size / 1024)} KB`;
+
+const BookAttachments: React.FC<BookAttachmentsProps> = ({ bookId }) => {
+  const [attachments, setAttachments] = useState<BookAttachment[]>([]);
+  const [error, setError] = useState<string | null>(null);
+
+  // ログインしている場合はトークンを送り、研究室内の添付ファイルも表示する
+  const authHeaders = () => {
+    const token = localStorage.getItem('token');
+    return token ? { Authorization: `Bearer ${token}` } : undefined;
+  };
+
+  useEffect(() => {
+    axios
+      .get(`/api/books/${bookId}/attachments`, { headers: authHeaders() })
+      .then((response) => setAttachments(response.data ?? []))
+      .catch(() => setAttachments([]));
+  }, [bookId]);
+
+  const download = async (attachment: BookAttachment) => {
+    try {
+      setError(null);
+      const response = await axios.get(`/api/attachments/${attachment.id}`, {
+        headers: authHeaders(),
+        responseType: 'blob',
+      });
+      const url = window.URL.createObjectURL(new Blob([response.data], { type: attachment.content_type }));
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = attachment.filename;
+      document.body.appendChild(link);
+      link.click();
+      document.body.removeChild(link);
+      window.URL.revokeObjectURL(url);
+    } catch {
+      setError('ダウンロードに失敗しました');
+    }
+  };
+
+  if (attachments.length === 0) return null;
+
+  return (
+    <div className="border-t border-gray-200 dark:border-gray-700 pt-6 mb-6">
+      <h2 className="text-lg font-semibold text-gray-900 dark:text-white mb-4">添付ファイル</h2>
+      {error && <p className="text-sm text-red-600 dark:text-red-400 mb-2">{error}</p>}
+      <ul className="divide-y divide-gray-200 dark:divide-gray-700">
+        {attachments.map((a) => (
+          <li key={a.id} className="flex items-center gap-3 py-2">
+            <Paperclip className="h-4 w-4 text-gray-500 dark:text-gray-400" />
+            <div className="flex-1 min-w-0">
+              <p className="truncate text-gray-900 dark:text-white">{a.filename}</p>
+              <p className="text-xs text-gray-500 dark:text-gray-400">
+                {formatSize(a.size)}・{VISIBILITY_LABELS[a.visibility]}
+                {a.description && `・${a.description}`}
+              </p>
+            </div>
+            <button
+              onClick={() => download(a)}
+              className="inline-flex items-center px-3 py-1 rounded-md text-sm text-white bg-indigo-600 hover:bg-indigo-700"
+            >
+              <Download className="mr-1 h-4 w-4" />
+              ダウンロード
+            </button>
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+};
+
+export default BookAttachments;
@@ -1,9 +1,17 @@
 import React, { useState } from 'react';
-import { LibraryItem, BorrowingRecord } from '../../types';
+import { LibraryItem, BorrowingRecord, AuthorRole } from '../../types';
 import { Book, FileText, User, Calendar, ArrowLeft, BookCheck } from 'lucide-react';
 import { formatDate, isOverdue } from '../../utils/dates';
 import { Link } from 'react-router-dom';
 import axios from 'axios';
+import BookAttachments from './BookAttachments';
+
+const AUTHOR_ROLE_LABELS: Record<AuthorRole, string> = {
+  author: '著',
+  editor: '編',
+  translator: '訳',
+  supervisor: '指導',
+};
 
 interface BookDetailsProps {
   item: LibraryItem;
@@ -108,14 +116,35 @@ const BookDetails: React.FC<BookDetailsProps> = ({ item, borrowingHistory, onBor
           {item.title}
         </h1>
         <p className="text-lg text-gray-700 dark:text-gray-300 mb-6">
-          {item.author}
+          {item.authors && item.authors.length > 0
+            ? item.authors
+                .map((a) => (a.role === 'author' ? a.name : `${a.name}（${AUTHOR_ROLE_LABELS[a.role]}）`))
+                .join('、')
+            : item.author}
         </p>
+
+        {item.subjects && item.subjects.length > 0 && (
+          <div className="flex flex-wrap gap-2 mb-6">
+            {item.subjects.map((subject) => (
+              <span
+                key={subject}
+                className="px-2 py-1 text-xs rounded-full bg-indigo-100 dark:bg-indigo-900 text-indigo-700 dark:text-indigo-200"
+              >
+                {subject}
+              </span>
+            ))}
+          </div>
+        )}
+
+        {item.description && (
+          <p className="text-gray-700 dark:text-gray-300 mb-6 whitespace-pre-line">{item.description}</p>
+        )}
         
         {/* 書籍画像表示 */}
         {item.image_path && (
           <div className="mb-6">
             <img
-              src={`/api/books/${item.id}/image`}
+              src={`/api/books/${item.id}/image?size=medium`}
               alt={`${item.title}の表紙`}
               className="max-w-xs max-h-64 object-cover rounded-lg shadow-md mx-auto block"
               onError={(e) => {
@@ -148,6 +177,43 @@ const BookDetails: React.FC<BookDetailsProps> = ({ item, borrowingHistory, onBor
                 <span className="text-gray-600 dark:text-gray-400">保管場所:</span>
                 <span className="font-medium text-gray-900 dark:text-white">{item.location}</span>
               </div>
+
+              {item.publisher && (
+                <div className="flex justify-between">
+                  <span className="text-gray-600 dark:text-gray-400">出版社:</span>
+                  <span className="font-medium text-gray-900 dark:text-white">{item.publisher}</span>
+                </div>
+              )}
+
+              {(item.published_date || item.published_year) && (
+                <div className="flex justify-between">
+                  <span className="text-gray-600 dark:text-gray-400">出版日:</span>
+                  <span className="font-medium text-gray-900 dark:text-white">
+                    {item.published_date || item.published_year}
+                  </span>
+                </div>
+              )}
+
+              {item.edition && (
+                <div className="flex justify-between">
+                  <span className="text-gray-600 dark:text-gray-400">版:</span>
+                  <span className="font-medium text-gray-900 dark:text-white">{item.edition}</span>
+                </div>
+              )}
+
+              {!!item.page_count && (
+                <div className="flex justify-between">
+                  <span className="text-gray-600 dark:text-gray-400">ページ数:</span>
+                  <span className="font-medium text-gray-900 dark:text-white">{item.page_count}ページ</span>
+                </div>
+              )}
+
+              {item.language && (
+                <div className="flex justify-between">
+                  <span className="text-gray-600 dark:text-gray-400">言語:</span>
+                  <span className="font-medium text-gray-900 dark:text-white">{item.language}</span>
+                </div>
+              )}
               
               {item.copies && (
                 <div className="flex justify-between">
@@ -197,6 +263,8 @@ const BookDetails: React.FC<BookDetailsProps> = ({ item, borrowingHistory, onBor
           )}
         </div>
         
+        <BookAttachments bookId={item.id} />
+
         <div className="border-t border-gray-200 dark:border-gray-700 pt-6">
           <h2 className="text-lg font-semibold text-gray-900 dark:text-white mb-4">
             貸出履歴
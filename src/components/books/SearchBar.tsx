@@ -1,18 +1,92 @@
-import React, { useState } from 'react';
-import { Search } from 'lucide-react';
+import React, { useEffect, useRef, useState } from 'react';
+import { Search, BookOpen, User } from 'lucide-react';
+import axios from 'axios';
 
 interface SearchBarProps {
   onSearch: (query: string) => void;
 }
 
+interface Suggestion {
+  kind: 'title' | 'author';
+  text: string;
+  book_id?: string;
+  count: number;
+}
+
+// 入力が止まってから候補を問い合わせるまでの待ち時間（ミリ秒）
+const SUGGEST_DELAY = 200;
+
 const SearchBar: React.FC<SearchBarProps> = ({ onSearch }) => {
   const [query, setQuery] = useState('');
-  
+  const [suggestions, setSuggestions] = useState<Suggestion[]>([]);
+  const [open, setOpen] = useState(false);
+  const [active, setActive] = useState(-1);
+  // 候補を選んで検索した直後は、同じ入力で候補を再表示しない
+  const skipSuggest = useRef(false);
+
+  useEffect(() => {
+    if (skipSuggest.current) {
+      skipSuggest.current = false;
+      return;
+    }
+    const q = query.trim();
+    if (!q) {
+      setSuggestions([]);
+      return;
+    }
+    let cancelled = false;
+    const timer = setTimeout(async () => {
+      try {
+        const response = await axios.get('/api/books/suggest', { params: { q } });
+        if (!cancelled) {
+          setSuggestions(response.data.suggestions ?? []);
+          setActive(-1);
+          setOpen(true);
+        }
+      } catch {
+        if (!cancelled) setSuggestions([]);
+      }
+    }, SUGGEST_DELAY);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [query]);
+
+  const submit = (q: string) => {
+    setOpen(false);
+    onSearch(q);
+  };
+
   const handleSubmit = (e: React.FormEvent) => {
     e.preventDefault();
-    onSearch(query);
+    submit(query);
+  };
+
+  // 著者の候補は著者名に限定して検索する
+  const selectSuggestion = (s: Suggestion) => {
+    const q = s.kind === 'author' ? `author:"${s.text}"` : s.text;
+    skipSuggest.current = true;
+    setQuery(q);
+    submit(q);
   };
-  
+
+  const handleKeyDown = (e: React.KeyboardEvent<HTMLInputElement>) => {
+    if (!open || suggestions.length === 0) return;
+    if (e.key === 'ArrowDown') {
+      e.preventDefault();
+      setActive((i) => (i + 1) % suggestions.length);
+    } else if (e.key === 'ArrowUp') {
+      e.preventDefault();
+      setActive((i) => (i <= 0 ? suggestions.length - 1 : i - 1));
+    } else if (e.key === 'Enter' && active >= 0) {
+      e.preventDefault();
+      selectSuggestion(suggestions[active]);
+    } else if (e.key === 'Escape') {
+      setOpen(false);
+    }
+  };
+
   return (
     <form onSubmit={handleSubmit} className="w-full max-w-2xl">
       <div className="relative">
@@ -23,9 +97,15 @@ const SearchBar: React.FC<SearchBarProps> = ({ onSearch }) => {
           type="search"
           value={query}
           onChange={(e) => setQuery(e.target.value)}
+          onKeyDown={handleKeyDown}
+          onFocus={() => suggestions.length > 0 && setOpen(true)}
+          onBlur={() => setOpen(false)}
           className="block w-full p-4 pl-10 text-gray-900 dark:text-white dark:placeholder-gray-400 border border-gray-300 dark:border-gray-600 rounded-lg bg-white dark:bg-gray-700 focus:ring-indigo-500 focus:border-indigo-500"
           placeholder="タイトル、著者、ISBN、バーコードで検索..."
           aria-label="検索"
+          aria-autocomplete="list"
+          aria-expanded={open && suggestions.length > 0}
+          role="combobox"
         />
         <button
           type="submit"
@@ -33,9 +113,41 @@ const SearchBar: React.FC<SearchBarProps> = ({ onSearch }) => {
         >
           検索
         </button>
+        {open && suggestions.length > 0 && (
+          <ul
+            role="listbox"
+            className="absolute z-10 mt-1 w-full bg-white dark:bg-gray-700 border border-gray-300 dark:border-gray-600 rounded-lg shadow-lg overflow-hidden"
+          >
+            {suggestions.map((s, i) => (
+              <li
+                key={`${s.kind}-${s.book_id ?? s.text}`}
+                role="option"
+                aria-selected={i === active}
+                // blur より先に選択を処理する
+                onMouseDown={(e) => {
+                  e.preventDefault();
+                  selectSuggestion(s);
+                }}
+                className={`flex items-center gap-2 px-4 py-2 cursor-pointer text-gray-900 dark:text-white ${
+                  i === active ? 'bg-indigo-50 dark:bg-gray-600' : 'hover:bg-gray-50 dark:hover:bg-gray-600'
+                }`}
+              >
+                {s.kind === 'author' ? (
+                  <User className="w-4 h-4 text-gray-500 dark:text-gray-400" />
+                ) : (
+                  <BookOpen className="w-4 h-4 text-gray-500 dark:text-gray-400" />
+                )}
+                <span className="flex-1 truncate">{s.text}</span>
+                {s.kind === 'author' && (
+                  <span className="text-xs text-gray-500 dark:text-gray-400">{s.count}件</span>
+                )}
+              </li>
+            ))}
+          </ul>
+        )}
       </div>
     </form>
   );
 };
 
-export default SearchBar;
\ No newline at end of file
+export default SearchBar;
@@ -33,13 +33,16 @@ const RankingPage: React.FC = () => {
     try {
       setLoading(true);
       let response;
+      // ランキングは管理者用のAPIのため、ログイン中のトークンを送る
+      const headers = { Authorization: `Bearer ${localStorage.getItem('token')}` };
       
       if (rankingType === 'monthly') {
         response = await axios.get('/api/admin/rankings', {
+          headers,
           params: { month: selectedMonth },
         });
       } else {
-        response = await axios.get('/api/admin/rankings/all-time');
+        response = await axios.get('/api/admin/rankings/all-time', { headers });
       }
       
       const rankingData = response.data || [];
@@ -32,6 +32,36 @@ export interface LibraryItem {
   borrowedAt?: string;
   dueDate?: string;
   image_path?: string;
+  authors?: BookAuthor[];
+  publisher?: string;
+  published_date?: string;
+  published_year?: number | null;
+  edition?: string;
+  page_count?: number;
+  language?: string;
+  description?: string;
+  subjects?: string[];
+  notes?: string;
+}
+
+export type AuthorRole = 'author' | 'editor' | 'translator' | 'supervisor';
+
+export interface BookAuthor {
+  name: string;
+  role: AuthorRole;
+}
+
+export interface BookAttachment {
+  id: string;
+  book_id: string;
+  filename: string;
+  content_type: string;
+  size: number;
+  sha256: string;
+  visibility: 'public' | 'lab' | 'admin';
+  description?: string;
+  downloads: number;
+  created_at: string;
 }
 
 export interface BorrowingRecord {